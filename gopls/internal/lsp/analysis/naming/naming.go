@@ -0,0 +1,323 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package naming defines an Analyzer that checks that declared names follow
+// the Go naming conventions.
+package naming
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/gopls/internal/lsp/analysis/renamefix"
+)
+
+const Doc = `check that names follow the Go naming conventions
+
+This checker reports the declared names that do not follow the Go naming
+conventions:
+
+	var user_name string // underscores: userName
+	func ServeHttp()      // initialisms: ServeHTTP
+
+and the receivers whose names differ from those of the other methods of
+their type. In gopls, its suggested fixes rename the name with the rename
+engine, and thus update all of its references in the workspace.`
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "naming",
+	Doc:      Doc,
+	Requires: []*analysis.Analyzer{},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, f := range find(pass.Fset, pass.Files, pass.TypesInfo) {
+		pass.Report(analysis.Diagnostic{
+			Pos:            f.Ident.Pos(),
+			End:            f.Ident.End(),
+			Category:       renamefix.Category,
+			Message:        f.Message,
+			SuggestedFixes: []analysis.SuggestedFix{renamefix.Fix(f.Ident, f.NewName)},
+		})
+	}
+	return nil, nil
+}
+
+// A finding is a declared name that does not follow the naming conventions.
+type finding struct {
+	Ident   *ast.Ident // the declaring identifier
+	NewName string     // the name that it should have
+	Message string
+}
+
+// find returns the findings of the names declared in files, in order.
+func find(fset *token.FileSet, files []*ast.File, info *types.Info) []finding {
+	var findings []finding
+	found := make(map[*ast.Ident]bool)
+	for _, file := range files {
+		if isGenerated(file) {
+			continue
+		}
+		isTest := strings.HasSuffix(fset.File(file.Pos()).Name(), "_test.go")
+		ast.Inspect(file, func(n ast.Node) bool {
+			id, ok := n.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			kind := objectKind(info.Defs[id])
+			if kind == "" || id.Name == "_" || strings.HasPrefix(id.Name, "_") {
+				return true
+			}
+			if isTest && kind == "func" && testFuncName.MatchString(id.Name) {
+				return true
+			}
+			newName := lintName(id.Name)
+			if newName == id.Name || !hasLower(id.Name) {
+				return true
+			}
+			msg := fmt.Sprintf("%s %s should be %s", kind, id.Name, newName)
+			if strings.Contains(id.Name, "_") {
+				msg = "don't use underscores in Go names; " + msg
+			}
+			found[id] = true
+			findings = append(findings, finding{Ident: id, NewName: newName, Message: msg})
+			return true
+		})
+	}
+	return append(findings, receiverFindings(files, info, found)...)
+}
+
+// receiverFindings returns the findings of the receivers named differently
+// from those of the other methods of their type, which should be named as
+// most of them are, or else as the first. Receivers already in found are
+// ignored.
+func receiverFindings(files []*ast.File, info *types.Info, found map[*ast.Ident]bool) []finding {
+	var typeNames []*types.TypeName
+	receivers := make(map[*types.TypeName][]*ast.Ident)
+	for _, file := range files {
+		if isGenerated(file) {
+			continue
+		}
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || len(fn.Recv.List) == 0 || len(fn.Recv.List[0].Names) == 0 {
+				continue
+			}
+			id := fn.Recv.List[0].Names[0]
+			obj := info.Defs[id]
+			if obj == nil || id.Name == "_" || found[id] {
+				continue
+			}
+			t := obj.Type()
+			if ptr, ok := t.(*types.Pointer); ok {
+				t = ptr.Elem()
+			}
+			named, ok := t.(*types.Named)
+			if !ok {
+				continue
+			}
+			tn := named.Obj()
+			if receivers[tn] == nil {
+				typeNames = append(typeNames, tn)
+			}
+			receivers[tn] = append(receivers[tn], id)
+		}
+	}
+	var findings []finding
+	for _, tn := range typeNames {
+		ids := receivers[tn]
+		count := make(map[string]int)
+		preferred := ids[0].Name
+		for _, id := range ids {
+			count[id.Name]++
+			if count[id.Name] > count[preferred] {
+				preferred = id.Name
+			}
+		}
+		for _, id := range ids {
+			if id.Name != preferred {
+				findings = append(findings, finding{
+					Ident:   id,
+					NewName: preferred,
+					Message: fmt.Sprintf("receiver name %s should be %s, as in the other methods of %s", id.Name, preferred, tn.Name()),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// objectKind returns the kind of the declared object obj, as used in the
+// messages of the findings, or "" if its name is not checked.
+func objectKind(obj types.Object) string {
+	switch obj := obj.(type) {
+	case *types.Func:
+		if obj.Type().(*types.Signature).Recv() != nil {
+			return "method"
+		}
+		return "func"
+	case *types.TypeName:
+		return "type"
+	case *types.Const:
+		return "const"
+	case *types.Var:
+		if obj.Embedded() {
+			return "" // the name of the embedded type
+		}
+		if obj.IsField() {
+			return "field"
+		}
+		return "var"
+	}
+	return ""
+}
+
+// testFuncName matches the names of the functions of tests, which may
+// contain underscores.
+var testFuncName = regexp.MustCompile(`^(Test|Benchmark|Example|Fuzz)`)
+
+var generatedRx = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// isGenerated reports whether file is generated.
+func isGenerated(file *ast.File) bool {
+	for _, group := range file.Comments {
+		if group.Pos() > file.Package {
+			break
+		}
+		for _, c := range group.List {
+			if generatedRx.MatchString(c.Text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasLower reports whether name contains a lower case letter. The names
+// without, such as MAX_SIZE, are left unchanged.
+func hasLower(name string) bool {
+	for _, r := range name {
+		if unicode.IsLower(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// lintName returns the name that name should have: without underscores,
+// and with the common initialisms in a consistent case.
+func lintName(name string) string {
+	// Fast path for simple cases: "_" and all lowercase.
+	if name == "_" {
+		return name
+	}
+	allLower := true
+	for _, r := range name {
+		if !unicode.IsLower(r) {
+			allLower = false
+			break
+		}
+	}
+	if allLower {
+		return name
+	}
+
+	// Split camelCase at any lower->upper transition, and split on
+	// underscores. Check each word for common initialisms.
+	runes := []rune(name)
+	w, i := 0, 0 // index of start of word, scan
+	for i+1 <= len(runes) {
+		eow := false // whether we hit the end of a word
+		if i+1 == len(runes) {
+			eow = true
+		} else if runes[i+1] == '_' {
+			// underscore; shift the remainder forward over any run of underscores
+			eow = true
+			n := 1
+			for i+n+1 < len(runes) && runes[i+n+1] == '_' {
+				n++
+			}
+			// Leave at most one underscore if the underscore is between two digits.
+			if i+n+1 < len(runes) && unicode.IsDigit(runes[i]) && unicode.IsDigit(runes[i+n+1]) {
+				n--
+			}
+			copy(runes[i+1:], runes[i+n+1:])
+			runes = runes[:len(runes)-n]
+		} else if unicode.IsLower(runes[i]) && !unicode.IsLower(runes[i+1]) {
+			// lower->non-lower
+			eow = true
+		}
+		i++
+		if !eow {
+			continue
+		}
+
+		// [w,i) is a word.
+		word := string(runes[w:i])
+		if u := strings.ToUpper(word); commonInitialisms[u] {
+			// Keep consistent case, which is lowercase only at the start.
+			if w == 0 && unicode.IsLower(runes[w]) {
+				u = strings.ToLower(u)
+			}
+			// All the common initialisms are ASCII,
+			// so we can replace the bytes exactly.
+			copy(runes[w:], []rune(u))
+		} else if w > 0 && strings.ToLower(word) == word {
+			// already all lowercase, and not the first word, so uppercase the first character.
+			runes[w] = unicode.ToUpper(runes[w])
+		}
+		w = i
+	}
+	return string(runes)
+}
+
+// commonInitialisms is the set of common initialisms, whose case should be
+// consistent.
+var commonInitialisms = map[string]bool{
+	"ACL":   true,
+	"API":   true,
+	"ASCII": true,
+	"CPU":   true,
+	"CSS":   true,
+	"DNS":   true,
+	"EOF":   true,
+	"GUID":  true,
+	"HTML":  true,
+	"HTTP":  true,
+	"HTTPS": true,
+	"ID":    true,
+	"IP":    true,
+	"JSON":  true,
+	"LHS":   true,
+	"QPS":   true,
+	"RAM":   true,
+	"RHS":   true,
+	"RPC":   true,
+	"SLA":   true,
+	"SMTP":  true,
+	"SQL":   true,
+	"SSH":   true,
+	"TCP":   true,
+	"TLS":   true,
+	"TTL":   true,
+	"UDP":   true,
+	"UI":    true,
+	"UID":   true,
+	"UUID":  true,
+	"URI":   true,
+	"URL":   true,
+	"UTF8":  true,
+	"VM":    true,
+	"XML":   true,
+	"XMPP":  true,
+	"XSRF":  true,
+	"XSS":   true,
+}
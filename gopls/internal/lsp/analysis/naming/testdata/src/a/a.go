@@ -0,0 +1,25 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package a
+
+const MAX_SIZE = 10
+
+var user_name string // want "don't use underscores in Go names; var user_name should be userName"
+
+var userId int // want "var userId should be userID"
+
+type Server struct {
+	BaseUrl string // want "field BaseUrl should be BaseURL"
+}
+
+func (s *Server) ServeHttp() {} // want "method ServeHttp should be ServeHTTP"
+
+func (s *Server) Start() {}
+
+func (srv *Server) Stop() {} // want "receiver name srv should be s, as in the other methods of Server"
+
+func parse_json(data []byte) {} // want "don't use underscores in Go names; func parse_json should be parseJSON"
+
+func f(_ int, _x int, ok bool) {}
@@ -0,0 +1,11 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package a
+
+import "testing"
+
+func TestServer_Stop(t *testing.T) {}
+
+func test_helper() {} // want "don't use underscores in Go names; func test_helper should be testHelper"
@@ -0,0 +1,57 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package renamefix defines the suggested fixes by which analyzers request
+// the renaming of an object, with all of its references.
+//
+// Such a fix renames only the given identifier, so that drivers that know
+// nothing of it still apply a valid, if partial, edit. gopls instead
+// resolves it with its rename engine when the user selects it, renaming
+// the object throughout the workspace.
+package renamefix
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Category is the category of the diagnostics whose suggested fixes are
+// returned by Fix. Drivers recognize these fixes by it, rather than by
+// their messages, which are meant for users.
+const Category = "rename"
+
+// Fix returns the suggested fix renaming the object declared or referred
+// to by id to newName. The diagnostic suggesting it must have the
+// category Category.
+func Fix(id *ast.Ident, newName string) analysis.SuggestedFix {
+	return analysis.SuggestedFix{
+		Message: "Rename to " + newName,
+		TextEdits: []analysis.TextEdit{{
+			Pos:     id.Pos(),
+			End:     id.End(),
+			NewText: []byte(newName),
+		}},
+	}
+}
+
+// NewName returns the new name of fix, a suggested fix of diag, which is
+// the text of its edit, and whether fix is one returned by Fix. The
+// category of diag may be prefixed by the name of its analyzer, as
+// drivers such as gopls do.
+func NewName(diag *analysis.Diagnostic, fix analysis.SuggestedFix) (string, bool) {
+	if diag.Category != Category && !strings.HasSuffix(diag.Category, "."+Category) {
+		return "", false
+	}
+	if len(fix.TextEdits) != 1 {
+		return "", false
+	}
+	newName := string(fix.TextEdits[0].NewText)
+	if !token.IsIdentifier(newName) {
+		return "", false
+	}
+	return newName, true
+}
@@ -0,0 +1,40 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package renamefix_test
+
+import (
+	"go/ast"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/gopls/internal/lsp/analysis/renamefix"
+)
+
+func TestNewName(t *testing.T) {
+	id := ast.NewIdent("userId")
+	fix := renamefix.Fix(id, "userID")
+	for _, category := range []string{"rename", "naming.rename"} {
+		diag := &analysis.Diagnostic{Category: category, SuggestedFixes: []analysis.SuggestedFix{fix}}
+		if got, ok := renamefix.NewName(diag, fix); !ok || got != "userID" {
+			t.Errorf("NewName(%q diagnostic, Fix(userId, userID)) = %q, %v, want userID, true", category, got, ok)
+		}
+	}
+	for _, test := range []struct {
+		category string
+		fix      analysis.SuggestedFix
+	}{
+		// The message of a fix does not make it a rename.
+		{"", fix},
+		{"naming", fix},
+		{"unrename", fix},
+		{"rename", analysis.SuggestedFix{Message: "Rename to userID"}},
+		{"rename", analysis.SuggestedFix{Message: "Rename to user.ID", TextEdits: []analysis.TextEdit{{NewText: []byte("user.ID")}}}},
+	} {
+		diag := &analysis.Diagnostic{Category: test.category, SuggestedFixes: []analysis.SuggestedFix{test.fix}}
+		if got, ok := renamefix.NewName(diag, test.fix); ok {
+			t.Errorf("NewName(%q diagnostic, %+v) = %q, true, want false", test.category, test.fix, got)
+		}
+	}
+}
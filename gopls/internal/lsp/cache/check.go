@@ -147,6 +147,9 @@ func (s *snapshot) buildPackageHandle(ctx context.Context, id PackageID, mode so
 	phKey := computePackageKey(m.ID, compiledGoFiles, m, depKey, mode, experimentalKey)
 	promise, release := s.store.Promise(phKey, func(ctx context.Context, arg interface{}) interface{} {
 		pkg, err := typeCheckImpl(ctx, arg.(*snapshot), goFiles, compiledGoFiles, m.Metadata, mode, deps)
+		if pkg != nil {
+			pkg.key = phKey
+		}
 		return typeCheckResult{pkg, err}
 	})
 
@@ -15,6 +15,7 @@ import (
 
 	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/gopls/internal/lsp/analysis/renamefix"
 	"golang.org/x/tools/gopls/internal/lsp/command"
 	"golang.org/x/tools/gopls/internal/lsp/protocol"
 	"golang.org/x/tools/gopls/internal/lsp/source"
@@ -315,6 +316,26 @@ func suggestedAnalysisFixes(snapshot *snapshot, pkg *pkg, diag *analysis.Diagnos
 				NewText: string(e.NewText),
 			})
 		}
+		if newName, ok := renamefix.NewName(diag, fix); ok {
+			// The rename is computed only when the fix is applied, as
+			// it may edit the whole workspace. Its single edit locates
+			// the renamed identifier.
+			for uri, e := range edits {
+				cmd, err := command.NewApplyFixCommand(fix.Message, command.ApplyFixArgs{
+					Fix:     source.RenameName,
+					URI:     protocol.URIFromSpanURI(uri),
+					Range:   e[0].Range,
+					NewName: newName,
+				})
+				if err != nil {
+					return nil, err
+				}
+				for _, kind := range kinds {
+					fixes = append(fixes, source.SuggestedFixFromCommand(cmd, kind))
+				}
+			}
+			continue
+		}
 		for _, kind := range kinds {
 			fixes = append(fixes, source.SuggestedFix{
 				Title:      fix.Message,
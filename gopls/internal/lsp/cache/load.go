@@ -12,7 +12,9 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -44,6 +46,10 @@ func (s *snapshot) load(ctx context.Context, allowNetwork bool, scopes ...loadSc
 	var query []string
 	var containsDir bool // for logging
 
+	// Keep track of the queries of standalone files, which go/packages
+	// merges into a single package if there are several of them.
+	standalone := make(map[span.URI]string)
+
 	// Keep track of module query -> module path so that we can later correlate query
 	// errors with errors.
 	moduleQueries := make(map[string]string)
@@ -71,6 +77,7 @@ func (s *snapshot) load(ctx context.Context, allowNetwork bool, scopes ...loadSc
 			}
 			if isStandaloneFile(contents, s.view.Options().StandaloneTags) {
 				query = append(query, uri.Filename())
+				standalone[uri] = uri.Filename()
 			} else {
 				query = append(query, fmt.Sprintf("file=%s", uri.Filename()))
 			}
@@ -207,6 +214,22 @@ func (s *snapshot) load(ctx context.Context, allowNetwork bool, scopes ...loadSc
 		if s.view.allFilesExcluded(pkg, filterer) {
 			continue
 		}
+		if len(standalone) > 1 && source.IsCommandLineArguments(pkg.ID) {
+			filePkgs, err := s.splitStandalonePackage(ctx, pkg)
+			if err != nil {
+				return err
+			}
+			for _, filePkg := range filePkgs {
+				fileQuery, ok := standalone[span.URIFromPath(filePkg.GoFiles[0])]
+				if !ok {
+					return fmt.Errorf("loading standalone files together: unexpected file %s", filePkg.GoFiles[0])
+				}
+				if err := buildMetadata(ctx, filePkg, cfg, []string{fileQuery}, newMetadata, nil); err != nil {
+					return err
+				}
+			}
+			continue
+		}
 		if err := buildMetadata(ctx, pkg, cfg, query, newMetadata, nil); err != nil {
 			return err
 		}
@@ -473,6 +496,57 @@ func makeWorkspaceDir(ctx context.Context, workspace *workspace, fs source.FileS
 	return tmpdir, nil
 }
 
+// splitStandalonePackage splits pkg, the package that go/packages merges
+// the standalone files of a query into, into the packages that the
+// queries of each of them would have loaded, using the imports of the
+// files in the snapshot.
+func (s *snapshot) splitStandalonePackage(ctx context.Context, pkg *packages.Package) ([]*packages.Package, error) {
+	imports := make(map[string][]string)
+	for _, filename := range pkg.GoFiles {
+		fh := s.FindFile(span.URIFromPath(filename))
+		if fh == nil {
+			return nil, fmt.Errorf("loading standalone files together: no file for %s", filename)
+		}
+		pgf, err := s.ParseGo(ctx, fh, source.ParseHeader)
+		if err != nil {
+			return nil, err
+		}
+		for _, imp := range pgf.File.Imports {
+			if path, err := strconv.Unquote(imp.Path.Value); err == nil {
+				imports[filename] = append(imports[filename], path)
+			}
+		}
+	}
+	return splitPackage(pkg, imports)
+}
+
+// splitPackage splits pkg into one package for each of its files, which
+// imports the packages among those of pkg that the file does, as given by
+// imports. It fails if pkg has errors, which may be due to its files not
+// forming a package, or preprocessed files.
+func splitPackage(pkg *packages.Package, imports map[string][]string) ([]*packages.Package, error) {
+	if len(pkg.Errors) > 0 {
+		return nil, fmt.Errorf("loading standalone files together: %v", pkg.Errors[0])
+	}
+	if !reflect.DeepEqual(pkg.CompiledGoFiles, pkg.GoFiles) {
+		return nil, fmt.Errorf("loading standalone files together: some files are preprocessed")
+	}
+	var filePkgs []*packages.Package
+	for _, filename := range pkg.GoFiles {
+		filePkg := *pkg
+		filePkg.GoFiles = []string{filename}
+		filePkg.CompiledGoFiles = []string{filename}
+		filePkg.Imports = make(map[string]*packages.Package)
+		for _, path := range imports[filename] {
+			if imported, ok := pkg.Imports[path]; ok {
+				filePkg.Imports[path] = imported
+			}
+		}
+		filePkgs = append(filePkgs, &filePkg)
+	}
+	return filePkgs, nil
+}
+
 // buildMetadata populates the updates map with metadata updates to
 // apply, based on the given pkg. It recurs through pkg.Imports to ensure that
 // metadata exists for all dependencies.
@@ -13,14 +13,17 @@ import (
 	"go/scanner"
 	"go/token"
 	"go/types"
+	"math"
 	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync/atomic"
 
 	"golang.org/x/tools/gopls/internal/lsp/protocol"
 	"golang.org/x/tools/gopls/internal/lsp/safetoken"
 	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
 	"golang.org/x/tools/internal/diff"
 	"golang.org/x/tools/internal/event"
 	"golang.org/x/tools/internal/event/tag"
@@ -626,6 +629,216 @@ func fixSrc(f *ast.File, tf *token.File, src []byte) (newSrc []byte) {
 	return newSrc
 }
 
+// derivedSnapshots counts the snapshots derived by RecoverSwallowedDecls,
+// whose IDs count down from the largest one so that they differ from
+// those of their parents and of the snapshots of the views, which count up.
+var derivedSnapshots uint64
+
+// RecoverSwallowedDecls returns a snapshot derived from s in which the
+// open Go files with syntax errors have the source that recoverSwallowedDecls
+// gives them, and a function to release it.
+//
+// The derived snapshot holds the same versions of the files as s, but its
+// own ID, as their contents differ, and is never the snapshot of the view.
+func (s *snapshot) RecoverSwallowedDecls(ctx context.Context) (source.Snapshot, func(), error) {
+	changes := make(map[span.URI]*fileChange)
+	for _, fh := range s.openFiles() {
+		o, ok := fh.(*overlay)
+		if !ok || o.kind != source.Go {
+			continue
+		}
+		pgf, err := s.ParseGo(ctx, o, source.ParseFull)
+		if err != nil {
+			return nil, nil, err
+		}
+		if pgf.ParseErr == nil {
+			continue
+		}
+		recovered := recoverSwallowedDecls(o.uri.Filename(), o.text)
+		if recovered == nil {
+			continue
+		}
+		changes[o.uri] = &fileChange{
+			content: recovered,
+			exists:  true,
+			fileHandle: &overlay{
+				session: o.session,
+				uri:     o.uri,
+				text:    recovered,
+				hash:    source.HashOf(recovered),
+				version: o.version,
+				kind:    o.kind,
+			},
+		}
+	}
+	if len(changes) == 0 {
+		return s, s.Acquire(), nil
+	}
+	derived, release := s.clone(ctx, s.backgroundCtx, changes, false)
+	derived.id = math.MaxUint64 - atomic.AddUint64(&derivedSnapshots, 1)
+	return derived, func() {
+		release()
+		derived.cancel()
+		s.view.destroy(derived, "RecoverSwallowedDecls")
+	}, nil
+}
+
+// recoverSwallowedDecls returns src, the source of a Go file, once the
+// declarations that its syntax errors swallow are recovered by repeated
+// calls to fixSwallowedDecls, or nil if there are none. Unlike the
+// repairs of parseGoImpl, this discards code, so it is left to the
+// features that can make do without it, such as renaming.
+func recoverSwallowedDecls(filename string, src []byte) []byte {
+	var recovered []byte
+	for i := 0; i < 10; i++ {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, filename, src, parser.AllErrors|parser.ParseComments)
+		errs, _ := err.(scanner.ErrorList)
+		if errs == nil || f == nil || f.Pos() == token.NoPos {
+			break
+		}
+		newSrc := fixSwallowedDecls(f, fset.File(f.Pos()), src, errs)
+		if newSrc == nil {
+			break
+		}
+		src, recovered = newSrc, newSrc
+	}
+	return recovered
+}
+
+// fixSwallowedDecls blanks out the source of the first declaration of f
+// that a syntax error makes extend over the top-level declarations that
+// follow it, from the line of the error, or else from the start of its
+// body, to the first of these declarations, so that they can be parsed.
+// For example:
+//
+//	func f() {
+//		println()
+//		if x := 1; x > {
+//		}
+//	}
+//
+//	func g() {}
+//
+// becomes
+//
+//	func f() {
+//		println()
+//	}
+//
+//
+//
+//	func g() {}
+//
+// Newlines are kept, and the blanked bytes closing the blocks left open,
+// so that the offsets and lines of the rest of the file do not change.
+func fixSwallowedDecls(f *ast.File, tf *token.File, src []byte, errs scanner.ErrorList) []byte {
+	for _, decl := range f.Decls {
+		start, err := safetoken.Offset(tf, decl.Pos())
+		if err != nil {
+			continue
+		}
+		end, err := safetoken.Offset(tf, decl.End())
+		if err != nil {
+			end = len(src)
+		}
+		errOffset := -1
+		for _, e := range errs {
+			if start <= e.Pos.Offset && e.Pos.Offset < end {
+				errOffset = e.Pos.Offset
+				break
+			}
+		}
+		if errOffset < 0 {
+			continue
+		}
+		errLine := bytes.LastIndexByte(src[:errOffset], '\n') + 1
+		// The error may be reported on the line of the swallowed
+		// declaration itself, so it too is a candidate.
+		next := nextTopLevelDecl(src, errLine-1, end)
+		if next < 0 {
+			continue
+		}
+
+		body := start
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Body != nil && fn.Body.Lbrace.IsValid() {
+			lbrace, err := safetoken.Offset(tf, fn.Body.Lbrace)
+			if err != nil {
+				continue
+			}
+			body = lbrace + 1
+		}
+		for _, from := range []int{errLine, body} {
+			if from < body || from >= next {
+				continue
+			}
+			braces, ok := openBraces(src[start:from])
+			if !ok {
+				continue
+			}
+			newSrc := append([]byte(nil), src...)
+			for i := from; i < next; i++ {
+				switch {
+				case newSrc[i] == '\n':
+				case braces > 0:
+					newSrc[i] = '}'
+					braces--
+				default:
+					newSrc[i] = ' '
+				}
+			}
+			if braces == 0 {
+				return newSrc
+			}
+		}
+	}
+	return nil
+}
+
+// nextTopLevelDecl returns the offset of the first line of src[from:to],
+// excluding the one at from, that starts a top-level declaration, or -1.
+func nextTopLevelDecl(src []byte, from, to int) int {
+	if from < 0 {
+		from = 0
+	}
+	for i := from; i < to; i++ {
+		if src[i] != '\n' {
+			continue
+		}
+		for _, keyword := range []string{"func", "type", "var", "const", "import"} {
+			line := src[i+1:]
+			if bytes.HasPrefix(line, []byte(keyword)) && len(line) > len(keyword) && strings.IndexByte(" \t(", line[len(keyword)]) >= 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// openBraces returns the number of curly braces that src leaves open, and
+// whether it leaves no parentheses or brackets open.
+func openBraces(src []byte) (int, bool) {
+	fset := token.NewFileSet()
+	var s scanner.Scanner
+	s.Init(fset.AddFile("", -1, len(src)), src, nil, 0)
+	braces, others := 0, 0
+	for {
+		_, tok, _ := s.Scan()
+		switch tok {
+		case token.EOF:
+			return braces, others == 0
+		case token.LBRACE:
+			braces++
+		case token.RBRACE:
+			braces--
+		case token.LPAREN, token.LBRACK:
+			others++
+		case token.RPAREN, token.RBRACK:
+			others--
+		}
+	}
+}
+
 // fixMissingCurlies adds in curly braces for block statements that
 // are missing curly braces. For example:
 //
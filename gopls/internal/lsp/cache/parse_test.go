@@ -9,6 +9,7 @@ import (
 	"go/ast"
 	"go/format"
 	"go/parser"
+	"go/scanner"
 	"go/token"
 	"go/types"
 	"reflect"
@@ -44,6 +45,56 @@ func TestArrayLength(t *testing.T) {
 	}
 }
 
+func TestFixSwallowedDecls(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string // the source once fixed
+	}{
+		{
+			"package a\n\nfunc f() {\n\tprintln()\n\tif x := 1; x > {\n\t}\n}\n\nfunc g() {}\n",
+			"package a\n\nfunc f() {\n\tprintln()\n}                \n  \n \n\nfunc g() {}\n",
+		},
+		{
+			// The open parenthesis leaves only the body to discard.
+			"package a\n\nfunc f() {\n\tprintln(\n}\n\nfunc g() {}\n",
+			"package a\n\nfunc f() {\n}        \n \n\nfunc g() {}\n",
+		},
+		{
+			"package a\n\nvar x = [\n\nfunc g() {}\n",
+			"package a\n\n         \n\nfunc g() {}\n",
+		},
+		{
+			// The error is on the first line.
+			"package p; func f() { if x := 1; x > {\n}\n}\nfunc g() {}\n",
+			"package p; func f() {}                \n \n \nfunc g() {}\n",
+		},
+		{
+			// Nothing follows the error.
+			"package a\n\nfunc f() {\n\tif x > {\n}\n",
+			"package a\n\nfunc f() {\n\tif x > {\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		src := []byte(tt.src)
+		fset := token.NewFileSet()
+		for i := 0; i < 10; i++ {
+			f, err := parser.ParseFile(fset, "a.go", src, parser.AllErrors)
+			if err == nil {
+				break
+			}
+			newSrc := fixSwallowedDecls(f, fset.File(f.Pos()), src, err.(scanner.ErrorList))
+			if newSrc == nil {
+				break
+			}
+			src = newSrc
+		}
+		if got := string(src); got != tt.want {
+			t.Errorf("fixSwallowedDecls(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
 func TestTrim(t *testing.T) {
 	tests := []struct {
 		name string
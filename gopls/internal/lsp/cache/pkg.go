@@ -20,6 +20,7 @@ import (
 // pkg contains the type information needed by the source package.
 type pkg struct {
 	m               *Metadata
+	key             packageHandleKey
 	mode            source.ParseMode
 	goFiles         []*source.ParsedGoFile
 	compiledGoFiles []*source.ParsedGoFile
@@ -189,3 +190,7 @@ func (p *pkg) HasListOrParseErrors() bool {
 func (p *pkg) HasTypeErrors() bool {
 	return len(p.typeErrors) != 0
 }
+
+func (p *pkg) GetTypeErrors() []types.Error {
+	return p.typeErrors
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"go/types"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/gopls/internal/lsp/source"
+)
+
+// maxRenameCacheEntries bounds the number of entries of a renameCache,
+// beyond which the least recently used entry is evicted.
+const maxRenameCacheEntries = 256
+
+// A renameCache holds the results of the per-package analyses of renames
+// that are expensive to compute, such as the interface satisfaction
+// constraints established by a package, across the snapshots of a view.
+//
+// The results refer to the type objects of the package they were computed
+// for, so an entry is valid only as long as that package is unchanged: its
+// key, the hash of its files, metadata and dependencies, must be the same,
+// and so must the identity of its type-checked form, which is computed
+// again if it is evicted from the snapshot's own cache. The rename options
+// of the view the analyses depend on must be unchanged too.
+//
+// An entry is evicted when a lookup finds it no longer valid, when its
+// package is invalidated by a new snapshot, and when it is the least
+// recently used of a full cache.
+type renameCache struct {
+	mu                      sync.Mutex
+	entries                 map[renameCacheKey]*renameCacheEntry
+	clock                   int // incremented by each lookup
+	hits, misses, evictions int
+}
+
+type renameCacheKey struct {
+	kind string // the analysis, such as "constraints"
+	id   PackageID
+}
+
+type renameCacheEntry struct {
+	pkgKey   packageHandleKey // the key of the package
+	types    *types.Package   // the type-checked package
+	options  source.Hash      // the hash of the rename options of the view
+	value    interface{}
+	size     int           // the number of results in value
+	duration time.Duration // the time taken to compute value
+	hits     int
+	used     int // the clock of the last lookup of the entry
+}
+
+func newRenameCache() *renameCache {
+	return &renameCache{entries: make(map[renameCacheKey]*renameCacheEntry)}
+}
+
+// get returns the result of the analysis kind of p under the options
+// hashed by options, from the cache if it holds a valid entry, and
+// computed by compute otherwise, along with the number of its results.
+func (c *renameCache) get(kind string, p *pkg, options source.Hash, compute func() (interface{}, int)) interface{} {
+	key := renameCacheKey{kind, p.m.ID}
+
+	c.mu.Lock()
+	c.clock++
+	if e, ok := c.entries[key]; ok {
+		if e.pkgKey == p.key && e.types == p.types && e.options == options {
+			e.hits++
+			e.used = c.clock
+			c.hits++
+			c.mu.Unlock()
+			return e.value
+		}
+		delete(c.entries, key)
+		c.evictions++
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	start := time.Now()
+	value, size := compute()
+	e := &renameCacheEntry{
+		pkgKey:   p.key,
+		types:    p.types,
+		options:  options,
+		value:    value,
+		size:     size,
+		duration: time.Since(start),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e.used = c.clock
+	c.entries[key] = e
+	for len(c.entries) > maxRenameCacheEntries {
+		var lru renameCacheKey
+		oldest := -1
+		for k, e := range c.entries {
+			if oldest < 0 || e.used < oldest {
+				lru, oldest = k, e.used
+			}
+		}
+		delete(c.entries, lru)
+		c.evictions++
+	}
+	return value
+}
+
+// invalidate evicts the entries of the packages ids.
+func (c *renameCache) invalidate(ids map[PackageID]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if ids[key.id] {
+			delete(c.entries, key)
+			c.evictions++
+		}
+	}
+}
+
+// state returns the description of the content of c.
+func (c *renameCache) state() source.RenameCacheState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state := source.RenameCacheState{Hits: c.hits, Misses: c.misses, Evictions: c.evictions}
+	for key, e := range c.entries {
+		state.Entries = append(state.Entries, source.RenameCacheEntry{
+			Kind:       key.kind,
+			Package:    string(key.id),
+			PackageKey: source.Hash(e.pkgKey).String(),
+			Options:    e.options.String(),
+			Size:       e.size,
+			Hits:       e.hits,
+			Duration:   e.duration,
+		})
+	}
+	sort.Slice(state.Entries, func(i, j int) bool {
+		ei, ej := state.Entries[i], state.Entries[j]
+		if ei.Package != ej.Package {
+			return ei.Package < ej.Package
+		}
+		return ei.Kind < ej.Kind
+	})
+	return state
+}
+
+func (v *View) RenameAnalysis(kind string, p source.Package, compute func() (interface{}, int)) interface{} {
+	pkg, ok := p.(*pkg)
+	if !ok {
+		value, _ := compute()
+		return value
+	}
+	return v.renameCache.get(kind, pkg, source.RenameOptionsHash(v.Options()), compute)
+}
+
+func (v *View) RenameCacheState() source.RenameCacheState {
+	return v.renameCache.state()
+}
@@ -0,0 +1,114 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"fmt"
+	"go/types"
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/lsp/source"
+)
+
+func renameCachePackage(id, content string, typesPkg *types.Package) *pkg {
+	return &pkg{
+		m:     &Metadata{ID: PackageID(id)},
+		key:   packageHandleKey(source.HashOf([]byte(content))),
+		types: typesPkg,
+	}
+}
+
+func TestRenameCacheInvalidation(t *testing.T) {
+	c := newRenameCache()
+	p := renameCachePackage("a", "a", types.NewPackage("a", "a"))
+	options := source.DefaultOptions()
+	computed := 0
+	lookup := func(p *pkg, options *source.Options) {
+		c.get("constraints", p, source.RenameOptionsHash(options), func() (interface{}, int) {
+			computed++
+			return computed, 0
+		})
+	}
+
+	lookup(p, options)
+	lookup(p, options)
+	if computed != 1 {
+		t.Fatalf("analysis computed %d times for an unchanged package, want 1", computed)
+	}
+
+	// Options that only shape the edits do not invalidate the cache.
+	editOptions := options.Clone()
+	editOptions.RenameInStrings = !options.RenameInStrings
+	lookup(p, editOptions)
+	if computed != 1 {
+		t.Errorf("analysis computed again after a change of RenameInStrings")
+	}
+
+	edited := renameCachePackage("a", "a'", p.types)
+	rechecked := renameCachePackage("a", "a'", types.NewPackage("a", "a"))
+	otherOptions := options.Clone()
+	otherOptions.RenameSkipTestFiles = !options.RenameSkipTestFiles
+	for _, changed := range []struct {
+		desc    string
+		pkg     *pkg
+		options *source.Options
+	}{
+		{"content", edited, options},
+		{"type checking", rechecked, options},
+		{"options", rechecked, otherOptions},
+	} {
+		before := computed
+		lookup(changed.pkg, changed.options)
+		if computed != before+1 {
+			t.Errorf("analysis not computed again after a change of %s", changed.desc)
+		}
+	}
+
+	state := c.state()
+	if state.Hits != 2 || state.Misses != 4 || state.Evictions != 3 {
+		t.Errorf("got %d hits, %d misses and %d evictions, want 2, 4 and 3", state.Hits, state.Misses, state.Evictions)
+	}
+	if len(state.Entries) != 1 {
+		t.Errorf("got %d entries, want 1", len(state.Entries))
+	}
+
+	c.invalidate(map[PackageID]bool{"a": true})
+	if state := c.state(); len(state.Entries) != 0 || state.Evictions != 4 {
+		t.Errorf("got %d entries and %d evictions after invalidating the package, want 0 and 4", len(state.Entries), state.Evictions)
+	}
+}
+
+func TestRenameCacheLRU(t *testing.T) {
+	c := newRenameCache()
+	options := source.RenameOptionsHash(source.DefaultOptions())
+	pkgs := make([]*pkg, maxRenameCacheEntries+1)
+	for i := range pkgs {
+		id := fmt.Sprint(i)
+		pkgs[i] = renameCachePackage(id, id, types.NewPackage(id, id))
+	}
+	lookup := func(p *pkg) (computed bool) {
+		c.get("constraints", p, options, func() (interface{}, int) {
+			computed = true
+			return nil, 0
+		})
+		return computed
+	}
+
+	for _, p := range pkgs[:maxRenameCacheEntries] {
+		lookup(p)
+	}
+	lookup(pkgs[0]) // pkgs[1] is now the least recently used
+	lookup(pkgs[maxRenameCacheEntries])
+
+	if state := c.state(); len(state.Entries) != maxRenameCacheEntries || state.Evictions != 1 {
+		t.Errorf("got %d entries and %d evictions, want %d and 1", len(state.Entries), state.Evictions, maxRenameCacheEntries)
+	}
+	if lookup(pkgs[0]) {
+		t.Errorf("recently used entry evicted")
+	}
+	if !lookup(pkgs[1]) {
+		t.Errorf("least recently used entry not evicted")
+	}
+}
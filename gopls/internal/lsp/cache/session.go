@@ -240,6 +240,7 @@ func (s *Session) createView(ctx context.Context, name string, folder span.URI,
 		folder:               folder,
 		moduleUpgrades:       map[span.URI]map[string]string{},
 		vulns:                map[span.URI][]govulncheck.Vuln{},
+		renameCache:          newRenameCache(),
 		filesByURI:           map[span.URI]*fileBase{},
 		filesByBase:          map[string][]*fileBase{},
 		rootURI:              root,
@@ -834,6 +834,50 @@ func (s *snapshot) GetReverseDependencies(ctx context.Context, id string) ([]sou
 	return pkgs, nil
 }
 
+func (s *snapshot) ReverseDependencyIDs(ctx context.Context, id string) ([]string, error) {
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	meta := s.meta
+	s.mu.Unlock()
+	var ids []string
+	for rdep := range meta.reverseTransitiveClosure(s.useInvalidMetadata(), PackageID(id)) {
+		if rdep != PackageID(id) {
+			ids = append(ids, string(rdep))
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (s *snapshot) CheckPackages(ctx context.Context, ids []string) ([]source.Package, error) {
+	var pkgs []source.Package
+	var errs source.PackagesError
+	for _, id := range ids {
+		pkgID := PackageID(id)
+		pkg, err := s.checkedPackage(ctx, pkgID, s.workspaceParseMode(pkgID))
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			e := &source.PackageError{ID: id, Path: id, Err: err}
+			s.mu.Lock()
+			if m := s.meta.metadata[pkgID]; m != nil {
+				e.Path, e.Files = string(m.PkgPath), m.CompiledGoFiles
+			}
+			s.mu.Unlock()
+			errs = append(errs, e)
+			continue
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	if len(errs) > 0 {
+		return pkgs, errs
+	}
+	return pkgs, nil
+}
+
 func (s *snapshot) checkedPackage(ctx context.Context, id PackageID, mode source.ParseMode) (*pkg, error) {
 	ph, err := s.buildPackageHandle(ctx, id, mode)
 	if err != nil {
@@ -1114,6 +1158,17 @@ func (s *snapshot) activePackageHandles(ctx context.Context) ([]*packageHandle,
 	return phs, nil
 }
 
+func (s *snapshot) ActivePackageIDs(ctx context.Context) ([]string, error) {
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, id := range s.activePackageIDs() {
+		ids = append(ids, string(id))
+	}
+	return ids, nil
+}
+
 // Symbols extracts and returns the symbols for each file in all the snapshot's views.
 func (s *snapshot) Symbols(ctx context.Context) map[span.URI][]source.Symbol {
 	var (
@@ -1168,6 +1223,34 @@ func (s *snapshot) MetadataForFile(ctx context.Context, uri span.URI) ([]source.
 }
 
 func (s *snapshot) KnownPackages(ctx context.Context) ([]source.Package, error) {
+	ids, err := s.knownPackageIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pkgs []source.Package
+	for _, id := range ids {
+		pkg, err := s.checkedPackage(ctx, id, s.workspaceParseMode(id))
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs, nil
+}
+
+func (s *snapshot) KnownPackageIDs(ctx context.Context) ([]string, error) {
+	ids, err := s.knownPackageIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var strs []string
+	for _, id := range ids {
+		strs = append(strs, string(id))
+	}
+	return strs, nil
+}
+
+func (s *snapshot) knownPackageIDs(ctx context.Context) ([]PackageID, error) {
 	if err := s.awaitLoaded(ctx); err != nil {
 		return nil, err
 	}
@@ -1183,16 +1266,7 @@ func (s *snapshot) KnownPackages(ctx context.Context) ([]source.Package, error)
 		ids = append(ids, id)
 	}
 	s.mu.Unlock()
-
-	var pkgs []source.Package
-	for _, id := range ids {
-		pkg, err := s.checkedPackage(ctx, id, s.workspaceParseMode(id))
-		if err != nil {
-			return nil, err
-		}
-		pkgs = append(pkgs, pkg)
-	}
-	return pkgs, nil
+	return ids, nil
 }
 
 func (s *snapshot) AllValidMetadata(ctx context.Context) ([]source.Metadata, error) {
@@ -1594,7 +1668,11 @@ func (s *snapshot) reloadOrphanedFiles(ctx context.Context) error {
 	files := s.orphanedFiles()
 
 	// Files without a valid package declaration can't be loaded. Don't try.
-	var scopes []loadScope
+	//
+	// Standalone files are loaded by a query of their own, as go/packages
+	// merges the standalone files of a query into a single package, which
+	// load splits up again.
+	var scopes, standalone []loadScope
 	for _, file := range files {
 		pgf, err := s.ParseGo(ctx, file, source.ParseHeader)
 		if err != nil {
@@ -1604,17 +1682,19 @@ func (s *snapshot) reloadOrphanedFiles(ctx context.Context) error {
 			continue
 		}
 
-		scopes = append(scopes, fileLoadScope(file.URI()))
+		scope := fileLoadScope(file.URI())
+		if isStandaloneFile(pgf.Src, s.view.Options().StandaloneTags) {
+			standalone = append(standalone, scope)
+		} else {
+			scopes = append(scopes, scope)
+		}
 	}
 
-	if len(scopes) == 0 {
-		return nil
+	load := func(scopes []loadScope) error {
+		// The regtests match this exact log message, keep them in sync.
+		event.Log(ctx, "reloadOrphanedFiles reloading", tag.Query.Of(scopes))
+		return s.load(ctx, false, scopes...)
 	}
-
-	// The regtests match this exact log message, keep them in sync.
-	event.Log(ctx, "reloadOrphanedFiles reloading", tag.Query.Of(scopes))
-	err := s.load(ctx, false, scopes...)
-
 	// If we failed to load some files, i.e. they have no metadata,
 	// mark the failures so we don't bother retrying until the file's
 	// content changes.
@@ -1624,7 +1704,10 @@ func (s *snapshot) reloadOrphanedFiles(ctx context.Context) error {
 	//
 	// Check for context cancellation so that we don't incorrectly mark files
 	// as unloadable, but don't return before setting all workspace packages.
-	if ctx.Err() == nil && err != nil {
+	markUnloadable := func(scopes []loadScope, err error) {
+		if ctx.Err() != nil {
+			return
+		}
 		event.Error(ctx, "reloadOrphanedFiles: failed to load", err, tag.Query.Of(scopes))
 		s.mu.Lock()
 		for _, scope := range scopes {
@@ -1635,6 +1718,28 @@ func (s *snapshot) reloadOrphanedFiles(ctx context.Context) error {
 		}
 		s.mu.Unlock()
 	}
+
+	if len(scopes) > 0 {
+		if err := load(scopes); err != nil {
+			markUnloadable(scopes, err)
+		}
+	}
+	if len(standalone) > 0 {
+		err := load(standalone)
+		if err != nil && ctx.Err() == nil && len(standalone) > 1 {
+			// Fall back to loading the standalone files one at a time, as
+			// they fail to load together if they are in different
+			// directories or packages, for example.
+			event.Error(ctx, "reloadOrphanedFiles: failed to load standalone files together", err, tag.Query.Of(standalone))
+			for _, scope := range standalone {
+				if err := load([]loadScope{scope}); err != nil {
+					markUnloadable([]loadScope{scope}, err)
+				}
+			}
+		} else if err != nil {
+			markUnloadable(standalone, err)
+		}
+	}
 	return nil
 }
 
@@ -2117,6 +2222,10 @@ func (s *snapshot) invalidatePackagesLocked(ids map[PackageID]bool) {
 		}
 	}
 
+	// Evict the analyses of renames of the packages, which are shared
+	// by the snapshots of the view.
+	s.view.renameCache.invalidate(ids)
+
 	// Copy actions.
 	// TODO(adonovan): opt: avoid iteration over s.actions.
 	var actionsToDelete []actionKey
@@ -8,7 +8,14 @@
 package cache
 
 import (
+	"os"
+	"path/filepath"
+	"sort"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/internal/testenv"
 )
 
 func TestIsStandaloneFile(t *testing.T) {
@@ -94,3 +101,86 @@ func TestIsStandaloneFile(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitPackage(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":     "module example.com\n\ngo 1.16\n",
+		"lib/lib.go": "package lib\n\nfunc F() {}\n",
+		"a.go":       "//go:build ignore\n\npackage main\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/lib\"\n)\n\nfunc main() { fmt.Println(); lib.F() }\n",
+		"b.go":       "//go:build ignore\n\npackage main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println() }\n",
+		"c.go":       "//go:build ignore\n\npackage other\n",
+	}
+	for name, content := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	imports := map[string][]string{
+		"a.go": {"fmt", "example.com/lib"},
+		"b.go": {"fmt"},
+	}
+	load := func(names ...string) *packages.Package {
+		cfg := &packages.Config{
+			Dir:  dir,
+			Mode: packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles | packages.NeedImports,
+		}
+		var patterns []string
+		for _, name := range names {
+			patterns = append(patterns, filepath.Join(dir, name))
+		}
+		pkgs, err := packages.Load(cfg, patterns...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// go/packages merges the files into a single package.
+		if len(pkgs) != 1 || len(pkgs[0].GoFiles) != len(names) {
+			t.Fatalf("loading %v: got %d packages, want one with all the files", names, len(pkgs))
+		}
+		return pkgs[0]
+	}
+	fileImports := func(pkg *packages.Package) map[string][]string {
+		result := make(map[string][]string)
+		for _, filename := range pkg.GoFiles {
+			result[filename] = imports[filepath.Base(filename)]
+		}
+		return result
+	}
+
+	pkg := load("a.go", "b.go")
+	filePkgs, err := splitPackage(pkg, fileImports(pkg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string][]string)
+	for _, filePkg := range filePkgs {
+		if len(filePkg.GoFiles) != 1 || filePkg.Name != "main" {
+			t.Fatalf("split package %s has files %v", filePkg.Name, filePkg.GoFiles)
+		}
+		var paths []string
+		for path := range filePkg.Imports {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		got[filepath.Base(filePkg.GoFiles[0])] = paths
+	}
+	want := map[string][]string{
+		"a.go": {"example.com/lib", "fmt"},
+		"b.go": {"fmt"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("imports of the split packages (-want +got):\n%s", diff)
+	}
+
+	// Files of different packages do not form a package.
+	pkg = load("a.go", "c.go")
+	if _, err := splitPackage(pkg, fileImports(pkg)); err == nil {
+		t.Errorf("splitting the files of packages main and other succeeded, want an error")
+	}
+}
@@ -63,6 +63,9 @@ type View struct {
 
 	vulns map[span.URI][]govulncheck.Vuln
 
+	// renameCache holds the analyses of renames across snapshots.
+	renameCache *renameCache
+
 	// keep track of files by uri and by basename, a single file may be mapped
 	// to multiple uris, and the same basename may map to multiple files
 	filesByURI  map[span.URI]*fileBase
@@ -998,6 +1001,10 @@ func (v *View) IsGoPrivatePath(target string) bool {
 	return globsMatchPath(v.goprivate, target)
 }
 
+func (v *View) GOPATH() []string {
+	return filepath.SplitList(v.gopath)
+}
+
 func (v *View) ModuleUpgrades(modfile span.URI) map[string]string {
 	v.mu.Lock()
 	defer v.mu.Unlock()
@@ -360,6 +360,8 @@ func (c *connection) initialize(ctx context.Context, options func(*source.Option
 		ContentFormat: []protocol.MarkupKind{opts.PreferredContentFormat},
 	}
 	params.Capabilities.TextDocument.DocumentSymbol.HierarchicalDocumentSymbolSupport = opts.HierarchicalDocumentSymbolSupport
+	// Annotated edits are only applied on request; see rename.
+	params.Capabilities.TextDocument.Rename.HonorsChangeAnnotations = true
 	params.Capabilities.TextDocument.SemanticTokens = protocol.SemanticTokensClientCapabilities{}
 	params.Capabilities.TextDocument.SemanticTokens.Formats = []string{"relative"}
 	params.Capabilities.TextDocument.SemanticTokens.Requests.Range = true
@@ -588,6 +590,19 @@ func (c *connection) AddFile(ctx context.Context, uri span.URI) *cmdFile {
 	return file
 }
 
+// fileVersion returns the version of the file uri known to the server:
+// 1 once it has been opened by AddFile, and 0 before, when the server
+// reads it from disk.
+func (c *connection) fileVersion(uri span.URI) int32 {
+	c.Client.filesMu.Lock()
+	defer c.Client.filesMu.Unlock()
+
+	if file, ok := c.Client.files[uri]; ok && file.added {
+		return 1
+	}
+	return 0
+}
+
 func (c *connection) semanticTokens(ctx context.Context, p *protocol.SemanticTokensRangeParams) (*protocol.SemanticTokens, error) {
 	// use range to avoid limits on full
 	resp, err := c.Server.SemanticTokensRange(ctx, p)
@@ -0,0 +1,200 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/internal/diff"
+)
+
+// A filePatch is the change of a file written as part of a patch.
+type filePatch struct {
+	filename    string      // the absolute path of the file
+	newFilename string      // its path once renamed, or ""
+	content     string      // its content before the change
+	edits       []diff.Edit // the edits of content
+}
+
+// gitPatch returns the changes of files as a patch in the format of git
+// diff, which git apply accepts, with the paths of the files relative to
+// root.
+func gitPatch(root string, files []filePatch) (string, error) {
+	relPath := func(filename string) (string, error) {
+		rel, err := filepath.Rel(root, filename)
+		if err != nil {
+			return "", err
+		}
+		if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", fmt.Errorf("%s is outside of %s", filename, root)
+		}
+		return filepath.ToSlash(rel), nil
+	}
+	var b strings.Builder
+	for _, f := range files {
+		oldRel, err := relPath(f.filename)
+		if err != nil {
+			return "", err
+		}
+		newRel := oldRel
+		if f.newFilename != "" {
+			if newRel, err = relPath(f.newFilename); err != nil {
+				return "", err
+			}
+		}
+		unified, err := diff.ToUnified("a/"+oldRel, "b/"+newRel, f.content, f.edits)
+		if err != nil {
+			return "", err
+		}
+		if unified == "" && oldRel == newRel {
+			continue
+		}
+		fmt.Fprintf(&b, "diff --git a/%s b/%s\n", oldRel, newRel)
+		if oldRel != newRel {
+			fmt.Fprintf(&b, "rename from %s\nrename to %s\n", oldRel, newRel)
+		}
+		b.WriteString(unified)
+	}
+	return b.String(), nil
+}
+
+// addFileRenames returns files, extended with the files that renames
+// move, each with its new path. The renames apply in turn: renaming a
+// directory moves all the files within it, including those that the
+// previous renames moved there. The files are sorted by path.
+func addFileRenames(files []filePatch, renames []protocol.RenameFile) ([]filePatch, error) {
+	tracked := make(map[string]bool)
+	for _, f := range files {
+		tracked[f.filename] = true
+	}
+	for _, r := range renames {
+		oldPath, newPath := fileURI(r.OldURI).Filename(), fileURI(r.NewURI).Filename()
+		err := filepath.Walk(oldPath, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil // moved there by a previous rename
+				}
+				return err
+			}
+			if info.IsDir() || tracked[path] {
+				return nil
+			}
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			tracked[path] = true
+			files = append(files, filePatch{filename: path, content: string(content)})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		for i, f := range files {
+			current := f.filename
+			if f.newFilename != "" {
+				current = f.newFilename
+			}
+			if current == oldPath || strings.HasPrefix(current, oldPath+string(filepath.Separator)) {
+				files[i].newFilename = newPath + current[len(oldPath):]
+			}
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].filename < files[j].filename })
+	return files, nil
+}
+
+// workspaceRoot returns the directory of the go.work file of the workspace
+// containing dir, or else of the go.mod file of its module, or else dir.
+func workspaceRoot(dir string) string {
+	for _, name := range []string{"go.work", "go.mod"} {
+		for d := dir; ; {
+			if _, err := os.Stat(filepath.Join(d, name)); err == nil {
+				return d
+			}
+			parent := filepath.Dir(d)
+			if parent == d {
+				break
+			}
+			d = parent
+		}
+	}
+	return dir
+}
+
+// moduleRoot returns the directory of the go.mod file of the module
+// containing filename, or "" if there is none.
+func moduleRoot(filename string) string {
+	for dir := filepath.Dir(filename); ; {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// writeModulePatches writes the changes of files to dir as patches, one
+// per module root, relative to which their paths are given, so that the
+// patch of each module applies to its repository with git apply. The files
+// outside of modules form a patch relative to root. The patch of a module
+// is named after its path, with the slashes replaced by underscores.
+// writeModulePatches returns the names of the patches it writes.
+func writeModulePatches(dir, root string, files []filePatch) ([]string, error) {
+	byRoot := make(map[string][]filePatch)
+	for _, f := range files {
+		modRoot := moduleRoot(f.filename)
+		if modRoot == "" {
+			modRoot = root
+		}
+		byRoot[modRoot] = append(byRoot[modRoot], f)
+	}
+	var roots []string
+	for modRoot := range byRoot {
+		roots = append(roots, modRoot)
+	}
+	sort.Strings(roots)
+
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for _, modRoot := range roots {
+		patch, err := gitPatch(modRoot, byRoot[modRoot])
+		if err != nil {
+			return nil, err
+		}
+		if patch == "" {
+			continue
+		}
+		name := filepath.Base(modRoot)
+		if data, err := ioutil.ReadFile(filepath.Join(modRoot, "go.mod")); err == nil {
+			if path := modfile.ModulePath(data); path != "" {
+				name = strings.ReplaceAll(path, "/", "_")
+			}
+		}
+		for base, i := name, 2; seen[name]; i++ {
+			name = fmt.Sprintf("%s.%d", base, i)
+		}
+		seen[name] = true
+		filename := filepath.Join(dir, name+".patch")
+		if err := ioutil.WriteFile(filename, []byte(patch), 0666); err != nil {
+			return nil, err
+		}
+		names = append(names, filename)
+	}
+	return names, nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/diff"
+)
+
+func TestWriteModulePatches(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"a/go.mod":      "module example.com/a\n",
+		"a/pkg/a.go":    "package pkg\n\nfunc F() {}\n",
+		"b/go.mod":      "module example.com/b\n",
+		"b/b.go":        "package b\n\nvar _ = pkg.F\n",
+		"loose/main.go": "package main\n",
+	} {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := []filePatch{
+		{filename: filepath.Join(root, "a", "pkg", "a.go"), content: "package pkg\n\nfunc F() {}\n", edits: []diff.Edit{{Start: 18, End: 19, New: "G"}}},
+		{filename: filepath.Join(root, "b", "b.go"), content: "package b\n\nvar _ = pkg.F\n", edits: []diff.Edit{{Start: 23, End: 24, New: "G"}}},
+		{filename: filepath.Join(root, "loose", "main.go"), content: "package main\n", edits: []diff.Edit{{Start: 8, End: 12, New: "other"}}},
+	}
+	dir := filepath.Join(root, "patches")
+	names, err := writeModulePatches(dir, root, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"example.com_a.patch": "diff --git a/pkg/a.go b/pkg/a.go\n--- a/pkg/a.go\n+++ b/pkg/a.go\n" +
+			"@@ -1,3 +1,3 @@\n package pkg\n \n-func F() {}\n+func G() {}\n",
+		"example.com_b.patch": "diff --git a/b.go b/b.go\n--- a/b.go\n+++ b/b.go\n" +
+			"@@ -1,3 +1,3 @@\n package b\n \n-var _ = pkg.F\n+var _ = pkg.G\n",
+		// The files outside of modules are relative to the root.
+		filepath.Base(root) + ".patch": "diff --git a/loose/main.go b/loose/main.go\n--- a/loose/main.go\n+++ b/loose/main.go\n" +
+			"@@ -1 +1 @@\n-package main\n+package other\n",
+	}
+	if len(names) != len(want) {
+		t.Fatalf("writeModulePatches wrote %q, want %d patches", names, len(want))
+	}
+	for _, name := range names {
+		got, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w, ok := want[filepath.Base(name)]; !ok || string(got) != w {
+			t.Errorf("%s = %q, want %q", filepath.Base(name), got, w)
+		}
+	}
+}
+
+func TestGitPatchRenames(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":       "module example.com\n",
+		"old/old.go":   "package old\n",
+		"old/other.go": "package old\n\nvar V int\n",
+	} {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	uri := func(name string) protocol.DocumentURI {
+		return protocol.URIFromSpanURI(span.URIFromPath(filepath.Join(root, filepath.FromSlash(name))))
+	}
+	files := []filePatch{
+		{filename: filepath.Join(root, "old", "old.go"), content: "package old\n", edits: []diff.Edit{{Start: 8, End: 11, New: "new"}}},
+	}
+	// The file is renamed within the old directory, which is then renamed.
+	files, err := addFileRenames(files, []protocol.RenameFile{
+		{OldURI: uri("old/old.go"), NewURI: uri("old/new.go")},
+		{OldURI: uri("old"), NewURI: uri("new")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := gitPatch(root, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "diff --git a/old/old.go b/new/new.go\nrename from old/old.go\nrename to new/new.go\n" +
+		"--- a/old/old.go\n+++ b/new/new.go\n@@ -1 +1 @@\n-package old\n+package new\n" +
+		"diff --git a/old/other.go b/new/other.go\nrename from old/other.go\nrename to new/other.go\n"
+	if got != want {
+		t.Errorf("gitPatch = %q, want %q", got, want)
+	}
+}
@@ -6,10 +6,12 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 
+	"golang.org/x/tools/gopls/internal/lsp/command"
 	"golang.org/x/tools/gopls/internal/lsp/protocol"
 	"golang.org/x/tools/gopls/internal/span"
 	"golang.org/x/tools/internal/tool"
@@ -31,6 +33,10 @@ Example:
 	$ # 1-indexed location (:line:column or :#offset) of the target identifier
 	$ gopls prepare_rename helper/helper.go:8:6
 	$ gopls prepare_rename helper/helper.go:#53
+
+If a rename is valid at the location, prepare_rename prints the range of
+the identifier that would be renamed, followed by a tab and its current
+text. Otherwise, it fails with the reason the rename is refused.
 `)
 	printFlagDefaults(f)
 }
@@ -70,6 +76,9 @@ func (r *prepareRename) Run(ctx context.Context, args ...string) error {
 		return fmt.Errorf("prepare_rename failed: %w", err)
 	}
 	if result == nil {
+		if reason := refusalReason(ctx, conn, p.TextDocumentPositionParams); reason != "" {
+			return fmt.Errorf("%w: %s", ErrInvalidRenamePosition, reason)
+		}
 		return ErrInvalidRenamePosition
 	}
 
@@ -79,6 +88,33 @@ func (r *prepareRename) Run(ctx context.Context, args ...string) error {
 		return err
 	}
 
-	fmt.Println(s)
+	fmt.Printf("%v\t%s\n", s, result.Placeholder)
 	return nil
 }
+
+// refusalReason asks the server why the object at the given position can't
+// be renamed, returning "" if it gives no reason.
+func refusalReason(ctx context.Context, conn *connection, params protocol.TextDocumentPositionParams) string {
+	cmd, err := command.NewRenameCandidatesCommand("", params)
+	if err != nil {
+		return ""
+	}
+	res, err := conn.ExecuteCommand(ctx, &protocol.ExecuteCommandParams{Command: cmd.Command, Arguments: cmd.Arguments})
+	if err != nil {
+		return err.Error()
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		return ""
+	}
+	var result command.RenameCandidatesResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return ""
+	}
+	for _, cand := range result.Candidates {
+		if cand.Reason != "" {
+			return cand.Reason
+		}
+	}
+	return ""
+}
@@ -0,0 +1,35 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrepareRename(t *testing.T) {
+	dir := writeModule(t, map[string]string{"a.go": "package a\n\nfunc F() {}\n\nvar _ = 1\n"})
+	filename := filepath.Join(dir, "a.go")
+
+	// A valid rename prints the range of the identifier and its text.
+	got, err := runGopls(t, dir, "prepare_rename", filename+":3:6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filename + ":3:6-7\tF\n"; got != want {
+		t.Errorf("prepare_rename printed %q, want %q", got, want)
+	}
+
+	// A refused rename fails with the reason of the refusal.
+	_, err = runGopls(t, dir, "prepare_rename", filename+":5:5")
+	if !errors.Is(err, ErrInvalidRenamePosition) {
+		t.Fatalf("prepare_rename of _ failed with %v, want %v", err, ErrInvalidRenamePosition)
+	}
+	if want := `can't rename "_"`; !strings.Contains(err.Error(), want) {
+		t.Errorf("prepare_rename of _ failed with %q, want the reason %q", err, want)
+	}
+}
@@ -6,13 +6,17 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strings"
 
+	"golang.org/x/tools/gopls/internal/lsp/command"
 	"golang.org/x/tools/gopls/internal/lsp/protocol"
 	"golang.org/x/tools/gopls/internal/lsp/source"
 	"golang.org/x/tools/gopls/internal/span"
@@ -22,9 +26,23 @@ import (
 
 // rename implements the rename verb for gopls.
 type rename struct {
-	Diff     bool `flag:"d,diff" help:"display diffs instead of rewriting files"`
-	Write    bool `flag:"w,write" help:"write result to (source) file instead of stdout"`
-	Preserve bool `flag:"preserve" help:"preserve original files"`
+	Diff          bool   `flag:"d,diff" help:"display diffs instead of rewriting files"`
+	Write         bool   `flag:"w,write" help:"write result to (source) file instead of stdout"`
+	Preserve      bool   `flag:"preserve" help:"preserve original files"`
+	Force         bool   `flag:"force" help:"rename even if conflicts are introduced, applying the conflicting edits"`
+	AllowBreaking bool   `flag:"allow-breaking-external" help:"rename even if references outside the edit scope of the rename, such as those of the module cache, are broken"`
+	Format        bool   `flag:"format" help:"format the edited files and fix their imports, as goimports does"`
+	DryRun        bool   `flag:"dry-run" help:"print a report of the effects of the rename instead of its edits"`
+	Verify        bool   `flag:"verify" help:"type-check the affected packages with the edits applied, and fail on errors before changing anything"`
+	Annotations   bool   `flag:"annotations" help:"with -d, also display the optional edits that are not applied, grouped by change annotation"`
+	Apply         string `flag:"apply-annotations" help:"apply the optional edits with the given change annotations: all, none, or a comma-separated list of ids or groups (default: those not needing confirmation)"`
+	JSON          bool   `flag:"json" help:"write the edits as JSON Lines, one object per edit"`
+	Patch         bool   `flag:"patch" help:"print the edits as a patch for git apply, relative to the workspace root"`
+	PatchDir      string `flag:"patch-dir" help:"write the edits to the given directory as patches for git apply, one per module"`
+	Manifest      string `flag:"manifest" help:"also write the manifest of the rename, the names that its applied edits change, to the given file as JSON"`
+	Offset        string `flag:"offset" help:"the position of the renamed identifier, as a byte offset of the form file.go:#123, as gorename takes it"`
+	From          string `flag:"from" help:"the renamed object, as a specifier of the form \"example.com/pkg\".Type.Method, as gorename takes it"`
+	To            string `flag:"to" help:"the new name, as gorename takes it"`
 
 	app *Application
 }
@@ -41,18 +59,132 @@ Example:
 	$ gopls rename helper/helper.go:8:6 Foo
 	$ gopls rename helper/helper.go:#53 Foo
 
+For the scripts and editor integrations built around gorename, the position
+and the new name can also be given by the -offset and -to flags, which take
+them as gorename does. Like gorename, rename then writes the edited files in
+place, unless another output is requested, such as with -d. The -from flag
+specifies the renamed object instead of its position, in the forms that
+gorename accepts, which the packages of the workspace resolve:
+
+	"example.com/pkg".Type.Method     method of a package-level named type
+	(*"example.com/pkg".Type).Method  the same, in another syntax
+	"example.com/pkg".Type.field      field of a package-level named struct type
+	"example.com/pkg".Func            package member (const, func, var, type)
+	"example.com/pkg".Func::x         local object x within a function or method
+	"example.com/pkg"::x              object x anywhere within a package
+	file.go::x                        object x within the file file.go
+
+	$ gopls rename -offset helper/helper.go:#53 -to Foo
+	$ gopls rename -from '"example.com/helper".Helper.Bar' -to Foo
+
+Some edits, such as the renaming of the implementations of a renamed
+interface method, are optional: the server annotates them, in groups such
+as "implementations" or "comments", and marks those that need the user's
+confirmation according to the renameConfirmations setting. By default, only
+the optional edits that need no confirmation are applied. -apply-annotations
+selects the applied edits instead: "all", "none", or a comma-separated list
+of annotation ids, of the form group/name, or of groups. -d -annotations
+displays the diffs of the optional edits that are not applied after the
+others, in a section per annotation.
+
+	$ gopls rename -d -annotations helper/helper.go:8:6 Foo
+	$ gopls rename -w -apply-annotations=implementations,strings/reflect helper/helper.go:8:6 Foo
+
+A rename that would introduce conflicts, such as the shadowing of a
+reference, fails unless -force is given. With -force, the edits at the
+conflicting sites are applied, and each conflict is reported on stderr.
+
+A rename of an exported object referred to outside its edit scope, by the
+packages of the module cache or of other workspace folders, fails unless
+-allow-breaking-external is given, listing the references it would break.
+
+With -format, the edited Go files are also formatted, and their imports
+sorted and fixed, as goimports does.
+
+With -w, the files and directories that the rename moves, such as the
+directory of a renamed package along with the non-Go files it contains, are
+renamed too.
+
+With -verify, rename first type-checks the affected packages as they would
+be with the applied edits, in memory, and fails with their errors, if any,
+without changing anything.
+
+With -patch, rename changes nothing, but prints its edits as a patch in the
+format of git diff, whose paths are relative to the root of the go.work
+workspace, or else of the module, so that it can be piped to git apply.
+The patch of a package rename also renames the files of the package.
+
+	$ gopls rename -patch helper/helper.go:1:9 util | git apply
+
+With -patch-dir, rename changes nothing, but writes its edits to the given
+directory as patches in the format of git diff, one per module, whose paths
+are relative to the module root. A rename spanning the modules of a go.work
+workspace that live in different repositories can thus be applied to each
+repository with git apply, and proposed as coordinated changes.
+
+	$ gopls rename -patch-dir=/tmp/patches helper/helper.go:8:6 Foo
+	$ git -C ../helper apply /tmp/patches/example.com_helper.patch
+
+With -json, rename changes nothing, but writes its edits to stdout as JSON
+Lines, one object per edit, so that the consumers of large renames can
+process them one at a time rather than decode a whole workspace edit. The
+lines are written only once the whole response of the server has arrived,
+not as the rename is computed. An edit of a file is an object with the
+fields uri, range and newText, and a renaming of a file or directory one
+with the fields oldUri and newUri; both have the field annotationId if the
+edit is optional. Only the applied edits are written. -json cannot be
+combined with the flags of the other outputs: -w, -d, -preserve,
+-annotations, -patch, -patch-dir and -dry-run.
+
+	$ gopls rename -json helper/helper.go:8:6 Foo
+	{"uri":"file:///.../helper.go","range":{...},"newText":"Foo"}
+
+With -manifest, rename also writes the manifest of its applied edits to the
+given file, as the gopls.rename_manifest command returns it: a JSON object
+listing the names that the rename changes, with their kinds and the
+positions of their declarations, so that the repositories depending on the
+renamed objects can be updated by a tool reading it.
+
+	$ gopls rename -w -manifest=rename.json helper/helper.go:8:6 Foo
+
+With -dry-run, rename changes nothing, but prints a report of the files the
+rename edits, its groups of optional edits, its conflicts and warnings, the
+implementations outside the workspace that it leaves unchanged, and the
+packages it affects.
+
 rename-flags:
 `)
 	printFlagDefaults(f)
 }
 
 // Run renames the specified identifier and either;
-// - if -w is specified, updates the file(s) in place;
+// - if -w, or -offset without another output, is specified, updates the file(s) in place;
+// - if -json is specified, writes the edits as JSON Lines;
+// - if -patch is specified, prints a patch of the changes for git apply;
+// - if -patch-dir is specified, writes patches of the changes, one per module;
 // - if -d is specified, prints out unified diffs of the changes; or
 // - otherwise, prints the new versions to stdout.
 func (r *rename) Run(ctx context.Context, args ...string) error {
-	if len(args) != 2 {
-		return tool.CommandLineErrorf("definition expects 2 arguments (position, new name)")
+	position, newName, err := r.positionAndName(args)
+	if err != nil {
+		return err
+	}
+	if err := r.checkJSON(); err != nil {
+		return err
+	}
+	if (r.Offset != "" || r.From != "") && !r.Diff && !r.JSON && !r.Patch && r.PatchDir == "" && !r.DryRun {
+		r.Write = true // as gorename does
+	}
+	if r.Force || r.Format || r.AllowBreaking {
+		opts := r.app.options
+		r.app.options = func(o *source.Options) {
+			if opts != nil {
+				opts(o)
+			}
+			o.RenameForce = o.RenameForce || r.Force
+			o.RenameFormat = o.RenameFormat || r.Format
+			o.RenameAllowBreakingExternal = o.RenameAllowBreakingExternal || r.AllowBreaking
+		}
 	}
 	conn, err := r.app.connect(ctx)
 	if err != nil {
@@ -60,35 +192,110 @@ func (r *rename) Run(ctx context.Context, args ...string) error {
 	}
 	defer conn.terminate(ctx)
 
-	from := span.Parse(args[0])
-	file := conn.AddFile(ctx, from.URI())
-	if file.err != nil {
-		return file.err
-	}
-	loc, err := file.mapper.Location(from)
-	if err != nil {
-		return err
+	var loc protocol.Location
+	if r.From != "" {
+		if loc, err = r.resolveFrom(ctx, conn); err != nil {
+			return err
+		}
+	} else {
+		from := span.Parse(position)
+		file := conn.AddFile(ctx, from.URI())
+		if file.err != nil {
+			return file.err
+		}
+		if loc, err = file.mapper.Location(from); err != nil {
+			return err
+		}
 	}
 	p := protocol.RenameParams{
 		TextDocument: protocol.TextDocumentIdentifier{URI: loc.URI},
 		Position:     loc.Range.Start,
-		NewName:      args[1],
+		NewName:      newName,
+	}
+	if r.DryRun {
+		return dryRunRename(ctx, conn, p)
 	}
 	edit, err := conn.Rename(ctx, &p)
 	if err != nil {
 		return err
 	}
+	apply, err := r.appliedAnnotations(edit.ChangeAnnotations)
+	if err != nil {
+		return err
+	}
+	var conflicts []string
+	for id, a := range edit.ChangeAnnotations {
+		if strings.HasPrefix(id, "conflict/") {
+			apply[id] = true
+			conflicts = append(conflicts, a.Description)
+		}
+	}
+	sort.Strings(conflicts)
+	for _, c := range conflicts {
+		fmt.Fprintf(os.Stderr, "conflict: %s\n", c)
+	}
+	if r.Verify {
+		if err := verifyRename(ctx, conn, p, apply); err != nil {
+			return err
+		}
+	}
+	if r.Manifest != "" {
+		if err := writeRenameManifest(ctx, conn, p, apply, r.Manifest); err != nil {
+			return err
+		}
+	}
 	var orderedURIs []string
 	edits := map[span.URI][]protocol.TextEdit{}
+	annotated := map[protocol.ChangeAnnotationIdentifier]map[span.URI][]protocol.TextEdit{}
+	var renames []protocol.RenameFile
+	enc := json.NewEncoder(os.Stdout)
 	for _, c := range edit.DocumentChanges {
+		if c.RenameFile != nil {
+			if id := c.RenameFile.AnnotationID; id == "" || apply[id] {
+				if r.JSON {
+					if err := enc.Encode(jsonEdit{OldURI: c.RenameFile.OldURI, NewURI: c.RenameFile.NewURI, AnnotationID: id}); err != nil {
+						return err
+					}
+					continue
+				}
+				renames = append(renames, *c.RenameFile)
+			}
+		}
 		if c.TextDocumentEdit != nil {
 			uri := fileURI(c.TextDocumentEdit.TextDocument.URI)
-			edits[uri] = append(edits[uri], c.TextDocumentEdit.Edits...)
-			orderedURIs = append(orderedURIs, string(uri))
+			// Edits computed against another version of the file
+			// would corrupt it.
+			if have, want := conn.fileVersion(uri), c.TextDocumentEdit.TextDocument.Version; have != want {
+				return fmt.Errorf("%s: rename computed against version %d, have version %d", uri.Filename(), want, have)
+			}
+			for _, e := range c.TextDocumentEdit.Edits {
+				if id := e.AnnotationID; id != "" && !apply[id] {
+					if annotated[id] == nil {
+						annotated[id] = map[span.URI][]protocol.TextEdit{}
+					}
+					annotated[id][uri] = append(annotated[id][uri], e)
+					continue
+				}
+				if r.JSON {
+					e := e
+					if err := enc.Encode(jsonEdit{URI: c.TextDocumentEdit.TextDocument.URI, Range: &e.Range, NewText: &e.NewText, AnnotationID: e.AnnotationID}); err != nil {
+						return err
+					}
+					continue
+				}
+				if _, ok := edits[uri]; !ok {
+					orderedURIs = append(orderedURIs, string(uri))
+				}
+				edits[uri] = append(edits[uri], e)
+			}
 		}
 	}
+	if r.JSON {
+		return nil
+	}
 	sort.Strings(orderedURIs)
 	changeCount := len(orderedURIs)
+	var patches []filePatch
 
 	for _, u := range orderedURIs {
 		uri := span.URIFromURI(u)
@@ -109,6 +316,8 @@ func (r *rename) Run(ctx context.Context, args ...string) error {
 				}
 			}
 			ioutil.WriteFile(filename, []byte(newContent), 0644)
+		case r.Patch || r.PatchDir != "":
+			patches = append(patches, filePatch{filename: filename, content: string(cmdFile.mapper.Content), edits: renameEdits})
 		case r.Diff:
 			unified, err := diff.ToUnified(filename+".orig", filename, string(cmdFile.mapper.Content), renameEdits)
 			if err != nil {
@@ -126,5 +335,359 @@ func (r *rename) Run(ctx context.Context, args ...string) error {
 			changeCount -= 1
 		}
 	}
+	if r.Write {
+		for _, fr := range renames {
+			oldPath, newPath := fileURI(fr.OldURI).Filename(), fileURI(fr.NewURI).Filename()
+			if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+				return err
+			}
+			if err := os.Rename(oldPath, newPath); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "%s -> %s\n", oldPath, newPath)
+		}
+	}
+	if r.Patch || r.PatchDir != "" {
+		if patches, err = addFileRenames(patches, renames); err != nil {
+			return err
+		}
+	}
+	if r.Patch {
+		patch, err := gitPatch(workspaceRoot(r.app.wd), patches)
+		if err != nil {
+			return err
+		}
+		fmt.Print(patch)
+	} else if len(patches) > 0 {
+		names, err := writeModulePatches(r.PatchDir, r.app.wd, patches)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			fmt.Fprintln(os.Stderr, name)
+		}
+	}
+	if r.Diff && r.Annotations {
+		return printAnnotatedDiffs(ctx, conn, edit.ChangeAnnotations, annotated)
+	}
+	return nil
+}
+
+// offsetRE matches the byte offsets of the -offset flag, such as
+// file.go:#123.
+var offsetRE = regexp.MustCompile(`^.+:#\d+$`)
+
+// positionAndName returns the position of the renamed identifier and its
+// new name, given by args, or by the -offset, -from and -to flags, which
+// take them as gorename does, in place of the first and last of args. The
+// position is empty with -from, whose specifier the server resolves.
+func (r *rename) positionAndName(args []string) (position, newName string, _ error) {
+	switch {
+	case r.Offset != "" && r.From != "":
+		return "", "", tool.CommandLineErrorf("-offset and -from are mutually exclusive")
+	case r.Offset != "":
+		if !offsetRE.MatchString(r.Offset) {
+			return "", "", tool.CommandLineErrorf("-offset %q is not a byte offset of the form file.go:#123", r.Offset)
+		}
+		args = append([]string{r.Offset}, args...)
+	case r.From != "":
+		args = append([]string{""}, args...)
+	}
+	if r.To != "" {
+		args = append(args, r.To)
+	}
+	if len(args) != 2 {
+		return "", "", tool.CommandLineErrorf("rename expects 2 arguments (position, new name), or the -offset or -from and -to flags")
+	}
+	return args[0], args[1], nil
+}
+
+// checkJSON reports an error if -json is combined with a flag selecting
+// another output, or shaping one, which it would leave unused.
+func (r *rename) checkJSON() error {
+	if !r.JSON {
+		return nil
+	}
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"-w", r.Write},
+		{"-d", r.Diff},
+		{"-preserve", r.Preserve},
+		{"-annotations", r.Annotations},
+		{"-patch", r.Patch},
+		{"-patch-dir", r.PatchDir != ""},
+		{"-dry-run", r.DryRun},
+	} {
+		if f.set {
+			return tool.CommandLineErrorf("-json and %s are mutually exclusive", f.name)
+		}
+	}
+	return nil
+}
+
+// resolveFrom returns the location of the declaration of the object that
+// the -from flag specifies. The filename of its json.go::x form is
+// relative to the working directory.
+func (r *rename) resolveFrom(ctx context.Context, conn *connection) (protocol.Location, error) {
+	spec := r.From
+	if i := strings.Index(spec, "::"); i >= 0 && strings.HasSuffix(spec[:i], ".go") && !filepath.IsAbs(spec[:i]) {
+		spec = filepath.Join(r.app.wd, spec[:i]) + spec[i:]
+	}
+	cmd, err := command.NewResolveRenameSpecCommand("", command.ResolveRenameSpecArgs{Spec: spec})
+	if err != nil {
+		return protocol.Location{}, err
+	}
+	res, err := conn.ExecuteCommand(ctx, &protocol.ExecuteCommandParams{Command: cmd.Command, Arguments: cmd.Arguments})
+	if err != nil {
+		return protocol.Location{}, err
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		return protocol.Location{}, err
+	}
+	var result command.ResolveRenameSpecResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return protocol.Location{}, err
+	}
+	if file := conn.AddFile(ctx, fileURI(result.Location.URI)); file.err != nil {
+		return protocol.Location{}, file.err
+	}
+	return result.Location, nil
+}
+
+// A jsonEdit is an edit of a rename, as written by the -json flag: either
+// the edit of the text of a file, or the renaming of a file or directory.
+type jsonEdit struct {
+	URI          protocol.DocumentURI                `json:"uri,omitempty"`
+	Range        *protocol.Range                     `json:"range,omitempty"`
+	NewText      *string                             `json:"newText,omitempty"`
+	OldURI       protocol.DocumentURI                `json:"oldUri,omitempty"`
+	NewURI       protocol.DocumentURI                `json:"newUri,omitempty"`
+	AnnotationID protocol.ChangeAnnotationIdentifier `json:"annotationId,omitempty"`
+}
+
+// appliedAnnotations returns the set of change annotations selected by the
+// -apply-annotations flag, among the given annotations of a rename. An
+// element of the flag selects the annotation of that id, or all the
+// annotations of that group.
+func (r *rename) appliedAnnotations(annotations map[protocol.ChangeAnnotationIdentifier]protocol.ChangeAnnotation) (map[protocol.ChangeAnnotationIdentifier]bool, error) {
+	apply := make(map[protocol.ChangeAnnotationIdentifier]bool)
+	switch r.Apply {
+	case "":
+		for id, a := range annotations {
+			apply[id] = !a.NeedsConfirmation
+		}
+	case "none":
+	case "all":
+		for id := range annotations {
+			apply[id] = true
+		}
+	default:
+		for _, sel := range strings.Split(r.Apply, ",") {
+			sel = strings.TrimSpace(sel)
+			found := false
+			for id := range annotations {
+				if id == sel || strings.HasPrefix(id, sel+"/") {
+					apply[id] = true
+					found = true
+				}
+			}
+			if !found {
+				var ids []string
+				for id := range annotations {
+					ids = append(ids, id)
+				}
+				sort.Strings(ids)
+				return nil, fmt.Errorf("unknown change annotation %q (the rename has %q)", sel, ids)
+			}
+		}
+	}
+	return apply, nil
+}
+
+// printAnnotatedDiffs prints the unified diffs of the optional edits of a
+// rename, in a section per change annotation headed by its label and
+// description. Each diff applies to the original files.
+func printAnnotatedDiffs(ctx context.Context, conn *connection, annotations map[protocol.ChangeAnnotationIdentifier]protocol.ChangeAnnotation, annotated map[protocol.ChangeAnnotationIdentifier]map[span.URI][]protocol.TextEdit) error {
+	var ids []string
+	for id := range annotated {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		a := annotations[id]
+		fmt.Printf("### annotation %s: %s\n", id, a.Label)
+		if a.Description != "" {
+			fmt.Printf("### %s\n", a.Description)
+		}
+		var uris []string
+		for uri := range annotated[id] {
+			uris = append(uris, string(uri))
+		}
+		sort.Strings(uris)
+		for _, u := range uris {
+			uri := span.URIFromURI(u)
+			cmdFile := conn.AddFile(ctx, uri)
+			filename := cmdFile.uri.Filename()
+			_, renameEdits, err := source.ApplyProtocolEdits(cmdFile.mapper, annotated[id][uri])
+			if err != nil {
+				return fmt.Errorf("%v: %v", annotated[id][uri], err)
+			}
+			unified, err := diff.ToUnified(filename+".orig", filename, string(cmdFile.mapper.Content), renameEdits)
+			if err != nil {
+				return err
+			}
+			fmt.Print(unified)
+		}
+	}
 	return nil
 }
+
+// dryRunRename prints the report of the effects of a rename.
+func dryRunRename(ctx context.Context, conn *connection, params protocol.RenameParams) error {
+	cmd, err := command.NewDryRunRenameCommand("", params)
+	if err != nil {
+		return err
+	}
+	res, err := conn.ExecuteCommand(ctx, &protocol.ExecuteCommandParams{Command: cmd.Command, Arguments: cmd.Arguments})
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	var report command.DryRunRenameResult
+	if err := json.Unmarshal(data, &report); err != nil {
+		return err
+	}
+	printFiles := func(files []command.RenameFileReport) {
+		for _, f := range files {
+			fmt.Printf("\t%s: %d edits\n", fileURI(f.URI).Filename(), f.Edits)
+		}
+	}
+	fmt.Println("edits:")
+	printFiles(report.Files)
+	for _, a := range report.Annotations {
+		confirm := ""
+		if a.NeedsConfirmation {
+			confirm = " (needs confirmation)"
+		}
+		fmt.Printf("annotation %s: %s%s\n", a.ID, a.Label, confirm)
+		if a.Description != "" {
+			fmt.Printf("\t%s\n", a.Description)
+		}
+		printFiles(a.Files)
+	}
+	for _, c := range report.Conflicts {
+		fmt.Printf("conflict: %s\n", c)
+	}
+	for _, w := range report.Warnings {
+		if !strings.HasPrefix(w, "conflict: ") {
+			fmt.Printf("warning: %s\n", w)
+		}
+	}
+	for _, skipped := range report.Skipped {
+		if skipped.Location.URI == "" {
+			fmt.Printf("skipped: %s: %s\n", skipped.Name, skipped.Reason)
+			continue
+		}
+		fmt.Printf("skipped: %s:%d:%d: %s: %s\n", fileURI(skipped.Location.URI).Filename(),
+			skipped.Location.Range.Start.Line+1, skipped.Location.Range.Start.Character+1, skipped.Name, skipped.Reason)
+	}
+	for _, pkg := range report.ExportData {
+		fmt.Printf("export data: %s\n", pkg.Path)
+		for _, loc := range pkg.References {
+			fmt.Printf("\t%s:%d:%d\n", fileURI(loc.URI).Filename(), loc.Range.Start.Line+1, loc.Range.Start.Character+1)
+		}
+	}
+	for _, ext := range report.ExternalReferences {
+		fmt.Printf("external: %s (%s): %d references\n", ext.Package, ext.Scope, ext.References)
+	}
+	if report.Exported {
+		fmt.Println("exported: importers outside the workspace may need updating")
+	}
+	if len(report.Packages) > 0 {
+		fmt.Println("packages:")
+		for _, id := range report.Packages {
+			fmt.Printf("\t%s\n", id)
+		}
+	}
+	return nil
+}
+
+// verifyRename type-checks the packages affected by a rename with its
+// required edits and the optional edits of the applied annotations, and
+// returns an error if they have errors, which are printed on stderr.
+func verifyRename(ctx context.Context, conn *connection, params protocol.RenameParams, apply map[protocol.ChangeAnnotationIdentifier]bool) error {
+	args := command.VerifyRenameArgs{Params: params}
+	for id, ok := range apply {
+		if ok {
+			args.Annotations = append(args.Annotations, id)
+		}
+	}
+	sort.Strings(args.Annotations)
+	cmd, err := command.NewVerifyRenameCommand("", args)
+	if err != nil {
+		return err
+	}
+	res, err := conn.ExecuteCommand(ctx, &protocol.ExecuteCommandParams{Command: cmd.Command, Arguments: cmd.Arguments})
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	var result command.VerifyRenameResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return err
+	}
+	if len(result.Errors) == 0 {
+		return nil
+	}
+	for _, e := range result.Errors {
+		if e.Location.URI == "" {
+			fmt.Fprintln(os.Stderr, e.Message)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%s:%d:%d: %s\n", fileURI(e.Location.URI).Filename(),
+			e.Location.Range.Start.Line+1, e.Location.Range.Start.Character+1, e.Message)
+	}
+	return fmt.Errorf("rename would introduce %d errors", len(result.Errors))
+}
+
+// writeRenameManifest writes the manifest of a rename with its required
+// edits and the optional edits of the applied annotations to the given file.
+func writeRenameManifest(ctx context.Context, conn *connection, params protocol.RenameParams, apply map[protocol.ChangeAnnotationIdentifier]bool, filename string) error {
+	args := command.RenameManifestArgs{Params: params}
+	for id, ok := range apply {
+		if ok {
+			args.Annotations = append(args.Annotations, id)
+		}
+	}
+	sort.Strings(args.Annotations)
+	cmd, err := command.NewRenameManifestCommand("", args)
+	if err != nil {
+		return err
+	}
+	res, err := conn.ExecuteCommand(ctx, &protocol.ExecuteCommandParams{Command: cmd.Command, Arguments: cmd.Arguments})
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	// Decoding the manifest keeps the order of its fields.
+	var manifest command.RenameManifestResult
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return err
+	}
+	if data, err = json.MarshalIndent(manifest, "", "\t"); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, append(data, '\n'), 0644)
+}
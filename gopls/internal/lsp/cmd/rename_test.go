@@ -0,0 +1,268 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/internal/testenv"
+	"golang.org/x/tools/internal/tool"
+)
+
+func TestRenamePositionAndName(t *testing.T) {
+	for _, test := range []struct {
+		offset, from, to string
+		args             []string
+		position         string
+		newName          string
+		wantErr          bool
+	}{
+		{args: []string{"a.go:3:6", "Foo"}, position: "a.go:3:6", newName: "Foo"},
+		{offset: "a.go:#53", to: "Foo", position: "a.go:#53", newName: "Foo"},
+		{offset: "a.go:#53", args: []string{"Foo"}, position: "a.go:#53", newName: "Foo"},
+		{to: "Foo", args: []string{"a.go:#53"}, position: "a.go:#53", newName: "Foo"},
+		// gorename takes only byte offsets.
+		{offset: "a.go:3:6", to: "Foo", wantErr: true},
+		{offset: "a.go:#53", to: "Foo", args: []string{"Bar"}, wantErr: true},
+		// The server resolves the specifiers of -from.
+		{from: `"example.com/a".T.M`, to: "Foo", newName: "Foo"},
+		{from: `"example.com/a".T.M`, args: []string{"Foo"}, newName: "Foo"},
+		{offset: "a.go:#53", from: `"example.com/a".T.M`, to: "Foo", wantErr: true},
+		{args: []string{"a.go:#53"}, wantErr: true},
+	} {
+		r := &rename{Offset: test.offset, From: test.from, To: test.to}
+		position, newName, err := r.positionAndName(test.args)
+		if (err != nil) != test.wantErr {
+			t.Errorf("positionAndName(%q) with -offset=%q -from=%q -to=%q: got error %v, want error: %t", test.args, test.offset, test.from, test.to, err, test.wantErr)
+			continue
+		}
+		if position != test.position || newName != test.newName {
+			t.Errorf("positionAndName(%q) with -offset=%q -from=%q -to=%q = %q, %q, want %q, %q", test.args, test.offset, test.from, test.to, position, newName, test.position, test.newName)
+		}
+	}
+}
+
+// writeModule writes the files of a module example.com to a temporary
+// directory, and returns the directory.
+func writeModule(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	files["go.mod"] = "module example.com\n\ngo 1.18\n"
+	for name, content := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// runGopls runs gopls with the given arguments in dir, with an in-process
+// server, and returns what it prints on stdout and its error.
+func runGopls(t *testing.T, dir string, args ...string) (string, error) {
+	testenv.NeedsGoBuild(t)
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout bytes.Buffer
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		io.Copy(&stdout, r)
+		wg.Done()
+	}()
+	oldStdout := os.Stdout
+	os.Stdout = w
+	app := New("gopls-test", dir, os.Environ(), nil)
+	err = tool.Run(context.Background(), flag.NewFlagSet(app.Name(), flag.ContinueOnError), app, args)
+	os.Stdout = oldStdout
+	w.Close()
+	wg.Wait()
+	r.Close()
+	return stdout.String(), err
+}
+
+func TestRenameAnnotatedDiffs(t *testing.T) {
+	dir := writeModule(t, map[string]string{"a.go": "package a\n\ntype I interface {\n\tM()\n}\n\ntype T struct{}\n\nfunc (T) M() {}\n"})
+	at := filepath.Join(dir, "a.go") + ":4:2"
+	got, err := runGopls(t, dir, "rename", "-d", "-annotations", at, "N")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The implementations need confirmation, so their renaming is
+	// optional, and displayed after the applied edits.
+	want := "--- $DIR/a.go.orig\n+++ $DIR/a.go\n" +
+		"@@ -1,7 +1,7 @@\n package a\n \n type I interface {\n-\tM()\n+\tN()\n }\n \n type T struct{}\n" +
+		"### annotation implementations/0: Rename implementations\n### example.com.T.M\n" +
+		"--- $DIR/a.go.orig\n+++ $DIR/a.go\n" +
+		"@@ -6,4 +6,4 @@\n \n type T struct{}\n \n-func (T) M() {}\n+func (T) N() {}\n"
+	if got = strings.ReplaceAll(got, dir, "$DIR"); got != want {
+		t.Errorf("rename -d -annotations printed:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Without -annotations, only the applied edits are displayed.
+	got, err = runGopls(t, dir, "rename", "-d", at, "N")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(got, "### annotation") {
+		t.Errorf("rename -d printed the optional edits:\n%s", got)
+	}
+}
+
+func TestRenameAppliedAnnotations(t *testing.T) {
+	annotations := map[protocol.ChangeAnnotationIdentifier]protocol.ChangeAnnotation{
+		"implementations/0": {NeedsConfirmation: true},
+		"implementations/1": {NeedsConfirmation: true},
+		"comments/0":        {},
+		"strings/reflect":   {NeedsConfirmation: true},
+	}
+	for _, test := range []struct {
+		apply   string
+		want    []string
+		wantErr bool
+	}{
+		{apply: "", want: []string{"comments/0"}},
+		{apply: "none", want: nil},
+		{apply: "all", want: []string{"comments/0", "implementations/0", "implementations/1", "strings/reflect"}},
+		{apply: "implementations", want: []string{"implementations/0", "implementations/1"}},
+		{apply: "implementations/1, strings/reflect", want: []string{"implementations/1", "strings/reflect"}},
+		// A group matches whole path elements of the ids.
+		{apply: "implementations/", wantErr: true},
+		{apply: "string", wantErr: true},
+	} {
+		r := &rename{Apply: test.apply}
+		apply, err := r.appliedAnnotations(annotations)
+		if (err != nil) != test.wantErr {
+			t.Errorf("appliedAnnotations with -apply-annotations=%q: got error %v, want error: %t", test.apply, err, test.wantErr)
+			continue
+		}
+		var got []string
+		for id, ok := range apply {
+			if ok {
+				got = append(got, id)
+			}
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("appliedAnnotations with -apply-annotations=%q = %q, want %q", test.apply, got, test.want)
+		}
+	}
+}
+
+func TestRenameApplyAnnotations(t *testing.T) {
+	const src = "package a\n\ntype I interface {\n\tM()\n}\n\ntype T struct{}\n\nfunc (T) M() {}\n"
+	dir := writeModule(t, map[string]string{"a.go": src})
+	filename := filepath.Join(dir, "a.go")
+	if _, err := runGopls(t, dir, "rename", "-w", "-apply-annotations=implementations", filename+":4:2", "N"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The implementations, which need confirmation, are renamed too.
+	if want := strings.ReplaceAll(src, "M()", "N()"); string(got) != want {
+		t.Errorf("rename -w -apply-annotations=implementations wrote:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRenameCheckJSON(t *testing.T) {
+	for _, test := range []struct {
+		r       rename
+		wantErr bool
+	}{
+		{r: rename{JSON: true}},
+		{r: rename{JSON: true, Apply: "all", Format: true, Manifest: "m.json"}},
+		{r: rename{Write: true, Diff: true}},
+		{r: rename{JSON: true, Write: true}, wantErr: true},
+		{r: rename{JSON: true, Diff: true}, wantErr: true},
+		{r: rename{JSON: true, Preserve: true}, wantErr: true},
+		{r: rename{JSON: true, Annotations: true}, wantErr: true},
+		{r: rename{JSON: true, Patch: true}, wantErr: true},
+		{r: rename{JSON: true, PatchDir: "patches"}, wantErr: true},
+		{r: rename{JSON: true, DryRun: true}, wantErr: true},
+	} {
+		if err := test.r.checkJSON(); (err != nil) != test.wantErr {
+			t.Errorf("checkJSON with %+v: got error %v, want error: %t", test.r, err, test.wantErr)
+		}
+	}
+}
+
+func TestRenameJSON(t *testing.T) {
+	dir := writeModule(t, map[string]string{"a.go": "package a\n\ntype I interface {\n\tM()\n}\n\ntype T struct{}\n\nfunc (T) M() {}\n"})
+	filename := filepath.Join(dir, "a.go")
+	uri := protocol.URIFromPath(filename)
+	edit := func(line, start, end uint32, annotation string) jsonEdit {
+		newText := "N"
+		return jsonEdit{
+			URI:          uri,
+			Range:        &protocol.Range{Start: protocol.Position{Line: line, Character: start}, End: protocol.Position{Line: line, Character: end}},
+			NewText:      &newText,
+			AnnotationID: annotation,
+		}
+	}
+	for _, test := range []struct {
+		apply string
+		want  []jsonEdit
+	}{
+		// The implementations need confirmation, so only the applied
+		// edit of the interface method is written.
+		{apply: "", want: []jsonEdit{edit(3, 1, 2, "")}},
+		{apply: "all", want: []jsonEdit{edit(3, 1, 2, ""), edit(8, 9, 10, "implementations/0")}},
+	} {
+		got, err := runGopls(t, dir, "rename", "-json", "-apply-annotations="+test.apply, filename+":4:2", "N")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Each line is an object with only the fields of an edit of a file.
+		var edits []jsonEdit
+		for _, line := range strings.Split(strings.TrimSuffix(got, "\n"), "\n") {
+			var fields map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &fields); err != nil {
+				t.Fatalf("rename -json printed %q, which is not a JSON object: %v", line, err)
+			}
+			for name := range fields {
+				switch name {
+				case "uri", "range", "newText", "annotationId":
+				default:
+					t.Errorf("rename -json printed %q, with the unexpected field %q", line, name)
+				}
+			}
+			var e jsonEdit
+			if err := json.Unmarshal([]byte(line), &e); err != nil {
+				t.Fatal(err)
+			}
+			edits = append(edits, e)
+		}
+		sort.Slice(edits, func(i, j int) bool { return edits[i].Range.Start.Line < edits[j].Range.Start.Line })
+		if !reflect.DeepEqual(edits, test.want) {
+			t.Errorf("rename -json -apply-annotations=%q printed:\n%s\nwant the edits %+v", test.apply, got, test.want)
+		}
+	}
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "M()") {
+		t.Errorf("rename -json changed a.go:\n%s", content)
+	}
+}
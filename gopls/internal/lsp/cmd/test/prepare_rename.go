@@ -6,6 +6,7 @@ package cmdtest
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"golang.org/x/tools/gopls/internal/lsp/cmd"
@@ -28,7 +29,7 @@ func (r *runner) PrepareRename(t *testing.T, src span.Span, want *source.Prepare
 	)
 
 	if want.Text == "" {
-		if stdErr != "" && stdErr != cmd.ErrInvalidRenamePosition.Error() {
+		if stdErr != "" && !strings.HasPrefix(stdErr, cmd.ErrInvalidRenamePosition.Error()) {
 			t.Errorf("prepare_rename failed for %s,\nexpected:\n`%v`\ngot:\n`%v`", target, expect, stdErr)
 		}
 		return
@@ -39,7 +40,7 @@ func (r *runner) PrepareRename(t *testing.T, src span.Span, want *source.Prepare
 		t.Errorf("prepare_rename failed: %v", err)
 	}
 
-	expect = r.Normalize(fmt.Sprintln(ws))
+	expect = r.Normalize(fmt.Sprintf("%v\t%s\n", ws, want.Text))
 	if expect != stdOut {
 		t.Errorf("prepare_rename failed for %s expected:\n`%s`\ngot:\n`%s`\n", target, expect, stdOut)
 	}
@@ -152,6 +152,15 @@ func (s *Server) codeAction(ctx context.Context, params *protocol.CodeActionPara
 				})
 			}
 		}
+		// Offer to update the imports of packages that were moved outside
+		// the editor.
+		if wanted[protocol.QuickFix] {
+			actions, err := movedPackageActions(ctx, snapshot, fh, params.Range, diagnostics)
+			if err != nil {
+				event.Error(ctx, "moved package fixes", err, tag.File.Of(fh.URI().Filename()))
+			}
+			codeActions = append(codeActions, actions...)
+		}
 		if ctx.Err() != nil {
 			return nil, ctx.Err()
 		}
@@ -219,6 +228,19 @@ func (s *Server) codeAction(ctx context.Context, params *protocol.CodeActionPara
 			codeActions = append(codeActions, fixes...)
 		}
 
+		if wanted[protocol.RefactorRewrite] {
+			actions, err := importShadowActions(ctx, snapshot, fh, params.Range)
+			if err != nil {
+				return nil, err
+			}
+			codeActions = append(codeActions, actions...)
+			actions, err = receiverAlignmentActions(ctx, snapshot, fh, params.Range)
+			if err != nil {
+				return nil, err
+			}
+			codeActions = append(codeActions, actions...)
+		}
+
 		if wanted[protocol.GoTest] {
 			fixes, err := goTest(ctx, snapshot, uri, params.Range)
 			if err != nil {
@@ -383,6 +405,100 @@ func documentChanges(fh source.VersionedFileHandle, edits []protocol.TextEdit) [
 	}
 }
 
+// movedPackageActions returns the code actions updating the imports of the
+// moved packages imported within rng of the file fh.
+func movedPackageActions(ctx context.Context, snapshot source.Snapshot, fh source.VersionedFileHandle, rng protocol.Range, diagnostics []protocol.Diagnostic) ([]protocol.CodeAction, error) {
+	moved, err := source.MovedPackages(ctx, snapshot, fh)
+	if err != nil {
+		return nil, err
+	}
+	var actions []protocol.CodeAction
+	for _, m := range moved {
+		if !protocol.Intersect(m.Range, rng) {
+			continue
+		}
+		title := fmt.Sprintf("Update imports of %s to %s", m.OldPath, m.NewPath)
+		cmd, err := command.NewUpdateMovedImportsCommand(title, command.UpdateMovedImportsArgs{
+			URI:     protocol.URIFromSpanURI(fh.URI()),
+			OldPath: m.OldPath,
+			NewPath: m.NewPath,
+		})
+		if err != nil {
+			return nil, err
+		}
+		var diags []protocol.Diagnostic
+		for _, d := range diagnostics {
+			if protocol.Intersect(d.Range, m.Range) {
+				diags = append(diags, d)
+			}
+		}
+		actions = append(actions, protocol.CodeAction{
+			Title:       title,
+			Kind:        protocol.QuickFix,
+			Command:     &cmd,
+			Diagnostics: diags,
+		})
+	}
+	return actions, nil
+}
+
+// importShadowActions returns the code actions renaming the local
+// variables within rng of the file fh that shadow an imported package.
+func importShadowActions(ctx context.Context, snapshot source.Snapshot, fh source.VersionedFileHandle, rng protocol.Range) ([]protocol.CodeAction, error) {
+	shadows, err := source.ImportShadows(ctx, snapshot, fh, rng)
+	if err != nil {
+		return nil, err
+	}
+	var actions []protocol.CodeAction
+	for _, shadow := range shadows {
+		title := fmt.Sprintf("Rename %s, which shadows an imported package, to %s", shadow.Name, shadow.NewName)
+		cmd, err := command.NewApplyFixCommand(title, command.ApplyFixArgs{
+			Fix:     source.RenameName,
+			URI:     shadow.Location.URI,
+			Range:   shadow.Location.Range,
+			NewName: shadow.NewName,
+		})
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, protocol.CodeAction{
+			Title:   title,
+			Kind:    protocol.RefactorRewrite,
+			Command: &cmd,
+		})
+	}
+	return actions, nil
+}
+
+// receiverAlignmentActions returns the code actions renaming the receivers
+// of all the methods of the type whose declaration, or the declaration of
+// one of whose methods, encloses rng of the file fh to a common name.
+func receiverAlignmentActions(ctx context.Context, snapshot source.Snapshot, fh source.VersionedFileHandle, rng protocol.Range) ([]protocol.CodeAction, error) {
+	alignments, err := source.ReceiverAlignments(ctx, snapshot, fh, rng)
+	if err != nil {
+		return nil, err
+	}
+	var actions []protocol.CodeAction
+	for _, a := range alignments {
+		title := fmt.Sprintf("Rename the receivers of %s to %s", a.TypeName, a.NewName)
+		cmd, err := command.NewApplyFixCommand(title, command.ApplyFixArgs{
+			Fix:     source.AlignReceivers,
+			URI:     a.Location.URI,
+			Range:   a.Location.Range,
+			NewName: a.NewName,
+		})
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, protocol.CodeAction{
+			Title:   title,
+			Kind:    protocol.RefactorRewrite,
+			Command: &cmd,
+		})
+	}
+	return actions, nil
+}
+
 func codeActionsMatchingDiagnostics(ctx context.Context, snapshot source.Snapshot, pdiags []protocol.Diagnostic, sdiags []*source.Diagnostic) ([]protocol.CodeAction, error) {
 	var actions []protocol.CodeAction
 	for _, sd := range sdiags {
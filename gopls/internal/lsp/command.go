@@ -142,6 +142,57 @@ func (c *commandHandler) ApplyFix(ctx context.Context, args command.ApplyFixArgs
 		// Note: no progress here. Applying fixes should be quick.
 		forURI: args.URI,
 	}, func(ctx context.Context, deps commandDeps) error {
+		// Renames go through the rename engine, so that all the
+		// references of the renamed identifiers are updated.
+		var (
+			edit  *protocol.WorkspaceEdit
+			label string
+		)
+		switch args.Fix {
+		case source.RenameName:
+			var err error
+			edit, err = c.s.rename(ctx, &protocol.RenameParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: args.URI},
+				Position:     args.Range.Start,
+				NewName:      args.NewName,
+			})
+			if err != nil {
+				return err
+			}
+			label = fmt.Sprintf("Rename to %s", args.NewName)
+		case source.AlignReceivers:
+			edits, err := source.ReceiverAlignmentEdits(ctx, deps.snapshot, deps.fh, args.Range.Start, args.NewName)
+			if err != nil {
+				return err
+			}
+			var uris []string
+			for uri := range edits {
+				uris = append(uris, string(uri))
+			}
+			sort.Strings(uris)
+			edit = &protocol.WorkspaceEdit{}
+			for _, u := range uris {
+				fh, err := deps.snapshot.GetVersionedFile(ctx, span.URI(u))
+				if err != nil {
+					return err
+				}
+				edit.DocumentChanges = append(edit.DocumentChanges, documentChanges(fh, edits[span.URI(u)])...)
+			}
+			label = fmt.Sprintf("Rename receivers to %s", args.NewName)
+		}
+		if edit != nil {
+			r, err := c.s.client.ApplyEdit(ctx, &protocol.ApplyWorkspaceEditParams{
+				Label: label,
+				Edit:  *edit,
+			})
+			if err != nil {
+				return err
+			}
+			if !r.Applied {
+				return errors.New(r.FailureReason)
+			}
+			return nil
+		}
 		edits, err := source.ApplyFix(ctx, args.Fix, deps.snapshot, deps.fh, args.Range)
 		if err != nil {
 			return err
@@ -783,6 +834,532 @@ func (c *commandHandler) ListImports(ctx context.Context, args command.URIArg) (
 	return result, err
 }
 
+func (c *commandHandler) TextOccurrences(ctx context.Context, args protocol.TextDocumentPositionParams) (command.TextOccurrencesResult, error) {
+	var result command.TextOccurrencesResult
+	err := c.run(ctx, commandConfig{
+		forURI: args.TextDocument.URI,
+	}, func(ctx context.Context, deps commandDeps) error {
+		locs, err := source.TextOccurrences(ctx, deps.snapshot, deps.fh, args.Position)
+		result.Locations = locs
+		return err
+	})
+	return result, err
+}
+
+func (c *commandHandler) DryRunRename(ctx context.Context, args protocol.RenameParams) (command.DryRunRenameResult, error) {
+	var result command.DryRunRenameResult
+	err := c.run(ctx, commandConfig{
+		forURI: args.TextDocument.URI,
+	}, func(ctx context.Context, deps commandDeps) error {
+		report, err := source.DryRunRename(ctx, deps.snapshot, deps.fh, args.Position, args.NewName)
+		if err != nil {
+			return err
+		}
+		result = dryRunRenameResult(report)
+		external, err := c.externalReferences(ctx, deps, args, report)
+		if err != nil {
+			return err
+		}
+		result.ExternalReferences = externalReferenceCounts(external)
+		return nil
+	})
+	return result, err
+}
+
+// externalReferences returns the numbers of references, by package, to
+// the object renamed by params outside the edit scope of its rename,
+// analyzed in report, unless it renames a package.
+func (c *commandHandler) externalReferences(ctx context.Context, deps commandDeps, params protocol.RenameParams, report *source.RenameReport) ([]source.ExternalReferenceCount, error) {
+	if report.Package {
+		return nil, nil
+	}
+	others, release := c.s.otherSnapshots(ctx, deps.snapshot)
+	defer release()
+	return source.ExternalReferences(ctx, deps.snapshot, deps.fh, params.Position, others)
+}
+
+// externalReferenceCounts returns the command results describing counts.
+func externalReferenceCounts(counts []source.ExternalReferenceCount) []command.ExternalReferenceCount {
+	var result []command.ExternalReferenceCount
+	for _, count := range counts {
+		result = append(result, command.ExternalReferenceCount{
+			Package:    count.Package,
+			Scope:      count.Scope,
+			References: count.References,
+		})
+	}
+	return result
+}
+
+func (c *commandHandler) VerifyRename(ctx context.Context, args command.VerifyRenameArgs) (command.VerifyRenameResult, error) {
+	var result command.VerifyRenameResult
+	err := c.run(ctx, commandConfig{
+		progress: "Verifying rename",
+		forURI:   args.Params.TextDocument.URI,
+	}, func(ctx context.Context, deps commandDeps) error {
+		// The analysis of the dry run separates the optional edits, and
+		// the edits despite conflicts, regardless of the client.
+		report, err := source.DryRunRename(ctx, deps.snapshot, deps.fh, args.Params.Position, args.Params.NewName)
+		if err != nil {
+			return err
+		}
+		edits := selectedRenameEdits(report, args.Annotations)
+		errs, err := source.VerifyRename(ctx, deps.snapshot, c.s.session.Overlays(), edits)
+		if err != nil {
+			return err
+		}
+		for _, e := range errs {
+			result.Errors = append(result.Errors, command.RenameError{
+				Location: e.Location,
+				Message:  e.Message,
+			})
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (c *commandHandler) RenameManifest(ctx context.Context, args command.RenameManifestArgs) (command.RenameManifestResult, error) {
+	result := command.RenameManifestResult{Version: 1, Renames: []command.ManifestRename{}}
+	err := c.run(ctx, commandConfig{
+		forURI: args.Params.TextDocument.URI,
+	}, func(ctx context.Context, deps commandDeps) error {
+		report, err := source.DryRunRename(ctx, deps.snapshot, deps.fh, args.Params.Position, args.Params.NewName)
+		if err != nil {
+			return err
+		}
+		edits := selectedRenameEdits(report, args.Annotations)
+		entries, err := source.RenameManifest(ctx, deps.snapshot, deps.fh, args.Params.Position, args.Params.NewName, report, edits)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			result.Renames = append(result.Renames, command.ManifestRename{
+				Kind:      e.Kind,
+				Old:       manifestName(e.Kind, e.Old),
+				New:       manifestName(e.Kind, e.New),
+				Positions: append([]protocol.Location{}, e.Positions...),
+			})
+		}
+		return nil
+	})
+	return result, err
+}
+
+// manifestName returns the manifest form of name, the name of an object or
+// package of the given kind.
+func manifestName(kind string, name source.ManifestName) command.ManifestName {
+	qualified := name.String()
+	if kind == "package" {
+		qualified = name.Package
+	}
+	return command.ManifestName{Qualified: qualified, Package: name.Package, Name: name.Name}
+}
+
+func (c *commandHandler) ResolveRenameSpec(ctx context.Context, args command.ResolveRenameSpecArgs) (command.ResolveRenameSpecResult, error) {
+	var result command.ResolveRenameSpecResult
+	err := c.run(ctx, commandConfig{}, func(ctx context.Context, deps commandDeps) error {
+		// The first view with the package or file named by the specifier
+		// resolves it.
+		for _, view := range c.s.session.Views() {
+			snapshot, release := view.Snapshot(ctx)
+			snapshot.AwaitInitialized(ctx)
+			loc, found, err := source.ResolveRenameSpec(ctx, snapshot, args.Spec)
+			release()
+			if err != nil {
+				return err
+			}
+			if found {
+				result.Location = loc
+				return nil
+			}
+		}
+		return fmt.Errorf("no package or file of the workspace matches -from %q", args.Spec)
+	})
+	return result, err
+}
+
+func (c *commandHandler) BeginRename(ctx context.Context, args protocol.RenameParams) (command.BeginRenameResult, error) {
+	var result command.BeginRenameResult
+	err := c.run(ctx, commandConfig{
+		forURI: args.TextDocument.URI,
+	}, func(ctx context.Context, deps commandDeps) error {
+		report, err := source.DryRunRename(ctx, deps.snapshot, deps.fh, args.Position, args.NewName)
+		if err != nil {
+			return err
+		}
+		external, err := c.externalReferences(ctx, deps, args, report)
+		if err != nil {
+			return err
+		}
+		result.Token = c.s.addRenameSession(&renameSession{
+			params:     args,
+			snapshotID: deps.snapshot.ID(),
+		})
+		result.Preview = dryRunRenameResult(report)
+		result.Preview.ExternalReferences = externalReferenceCounts(external)
+		return nil
+	})
+	return result, err
+}
+
+func (c *commandHandler) CommitRename(ctx context.Context, args command.CommitRenameArgs) (command.CommitRenameResult, error) {
+	var result command.CommitRenameResult
+	rs := c.s.takeRenameSession(args.Token)
+	if rs == nil {
+		return result, fmt.Errorf("unknown rename session %q", args.Token)
+	}
+	err := c.run(ctx, commandConfig{
+		forURI: rs.params.TextDocument.URI,
+	}, func(ctx context.Context, deps commandDeps) error {
+		// The edits are those of a plain rename, with the optional edits
+		// selected, so that conflicts fail the commit as they fail a
+		// rename, unless renames are forced.
+		result.Revalidated = deps.snapshot.ID() != rs.snapshotID
+		res, err := c.s.renameEdit(ctx, deps.snapshot, deps.fh, &rs.params, "", func(id protocol.ChangeAnnotationIdentifier) bool {
+			return annotationSelected(args.Annotations, id)
+		})
+		if err != nil {
+			return err
+		}
+		r, err := c.s.client.ApplyEdit(ctx, &protocol.ApplyWorkspaceEditParams{
+			Label: fmt.Sprintf("Rename to %s", rs.params.NewName),
+			Edit:  *res.edit,
+		})
+		if err != nil {
+			return err
+		}
+		if !r.Applied {
+			return errors.New(r.FailureReason)
+		}
+		result.Remaining = res.remaining
+		result.ExternalReferences = externalReferenceCounts(res.external)
+		c.s.recordComputedRename(ctx, deps.snapshot, deps.fh, &rs.params, res.isPkg, res.edits, res.remaining, true)
+		return nil
+	})
+	return result, err
+}
+
+func (c *commandHandler) RenameHistory(ctx context.Context) (command.RenameHistoryResult, error) {
+	c.s.renameHistoryMu.Lock()
+	defer c.s.renameHistoryMu.Unlock()
+	return command.RenameHistoryResult{
+		Renames: append([]command.RenameRecord(nil), c.s.renameHistory...),
+	}, nil
+}
+
+func (c *commandHandler) RenameRemainder(ctx context.Context) (command.RenameRemainderResult, error) {
+	c.s.renameHistoryMu.Lock()
+	defer c.s.renameHistoryMu.Unlock()
+	var result command.RenameRemainderResult
+	if n := len(c.s.renameHistory); n > 0 {
+		last := c.s.renameHistory[n-1]
+		result.OldName = last.OldName
+		result.NewName = last.NewName
+		result.Locations = append(result.Locations, last.Remaining...)
+	}
+	return result, nil
+}
+
+func (c *commandHandler) RenameCacheState(ctx context.Context, args command.URIArg) (command.RenameCacheStateResult, error) {
+	var result command.RenameCacheStateResult
+	err := c.run(ctx, commandConfig{
+		forURI: args.URI,
+	}, func(ctx context.Context, deps commandDeps) error {
+		state := deps.snapshot.View().RenameCacheState()
+		result.Hits = state.Hits
+		result.Misses = state.Misses
+		result.Evictions = state.Evictions
+		for _, e := range state.Entries {
+			result.Entries = append(result.Entries, command.RenameCacheEntry{
+				Kind:       e.Kind,
+				Package:    e.Package,
+				PackageKey: e.PackageKey,
+				Options:    e.Options,
+				Size:       e.Size,
+				Hits:       e.Hits,
+				Duration:   e.Duration.String(),
+			})
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (c *commandHandler) RenameProfile(ctx context.Context, args protocol.RenameParams) (command.RenameProfileResult, error) {
+	var result command.RenameProfileResult
+	err := c.run(ctx, commandConfig{
+		forURI: args.TextDocument.URI,
+	}, func(ctx context.Context, deps commandDeps) error {
+		profile, err := source.ProfileRename(ctx, deps.snapshot, deps.fh, args.Position, args.NewName)
+		if err != nil {
+			return err
+		}
+		for _, p := range profile.Phases {
+			result.Phases = append(result.Phases, command.RenamePhaseProfile{
+				Name:     p.Name,
+				Duration: p.Duration.String(),
+				Size:     p.Size,
+				Unit:     p.Unit,
+			})
+		}
+		result.Total = profile.Total.String()
+		return nil
+	})
+	return result, err
+}
+
+// dryRunRenameResult returns the description of the effects of a rename
+// reported by source.DryRunRename.
+func dryRunRenameResult(report *source.RenameReport) command.DryRunRenameResult {
+	result := command.DryRunRenameResult{
+		Files:    renameFileReports(report, report.Edits, ""),
+		Package:  report.Package,
+		Exported: report.Exported,
+	}
+	if opt := report.Optional; opt != nil {
+		for id, a := range opt.Annotations {
+			result.Annotations = append(result.Annotations, command.RenameAnnotationReport{
+				ID:                id,
+				Label:             a.Label,
+				Description:       a.Description,
+				NeedsConfirmation: a.NeedsConfirmation,
+				Files:             renameFileReports(report, opt.Edits, id),
+			})
+		}
+		sort.Slice(result.Annotations, func(i, j int) bool {
+			return result.Annotations[i].ID < result.Annotations[j].ID
+		})
+		result.Conflicts = opt.Conflicts
+		result.Warnings = opt.Warnings
+		for _, skipped := range opt.Skipped {
+			result.Skipped = append(result.Skipped, command.SkippedImplementation{
+				Location: skipped.Location,
+				Name:     skipped.Name,
+				Reason:   skipped.Reason,
+			})
+		}
+		for _, pkg := range opt.ExportData {
+			result.ExportData = append(result.ExportData, command.ExportDataPackage{
+				Path:       pkg.Path,
+				References: pkg.References,
+			})
+		}
+	}
+	seen := make(map[string]bool)
+	for _, ids := range report.Packages {
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				result.Packages = append(result.Packages, id)
+			}
+		}
+	}
+	sort.Strings(result.Packages)
+	return result
+}
+
+// selectedRenameEdits returns the required edits of a rename, along with
+// its optional edits whose change annotations are selected by sel.
+func selectedRenameEdits(report *source.RenameReport, sel []string) map[span.URI][]protocol.TextEdit {
+	edits := make(map[span.URI][]protocol.TextEdit)
+	for uri, e := range report.Edits {
+		edits[uri] = append(edits[uri], e...)
+	}
+	if optional := report.Optional; optional != nil {
+		for uri, e := range optional.Edits {
+			for _, te := range e {
+				if annotationSelected(sel, te.AnnotationID) {
+					edits[uri] = append(edits[uri], te)
+				}
+			}
+		}
+	}
+	return edits
+}
+
+// annotationSelected reports whether the change annotation id is selected
+// by one of sel, which are annotation ids or groups.
+func annotationSelected(sel []string, id protocol.ChangeAnnotationIdentifier) bool {
+	for _, s := range sel {
+		if id == s || strings.HasPrefix(id, s+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// renameFileReports returns the reports of the files changed by the edits with
+// the given annotation, sorted by URI.
+func renameFileReports(report *source.RenameReport, edits map[span.URI][]protocol.TextEdit, id protocol.ChangeAnnotationIdentifier) []command.RenameFileReport {
+	var reports []command.RenameFileReport
+	for uri, e := range edits {
+		n := 0
+		for _, te := range e {
+			if te.AnnotationID == id {
+				n++
+			}
+		}
+		if n > 0 {
+			reports = append(reports, command.RenameFileReport{
+				URI:      protocol.URIFromSpanURI(uri),
+				Edits:    n,
+				Packages: report.Packages[uri],
+			})
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].URI < reports[j].URI })
+	return reports
+}
+
+func (c *commandHandler) RenameCandidates(ctx context.Context, args protocol.TextDocumentPositionParams) (command.RenameCandidatesResult, error) {
+	var result command.RenameCandidatesResult
+	err := c.run(ctx, commandConfig{
+		forURI: args.TextDocument.URI,
+	}, func(ctx context.Context, deps commandDeps) error {
+		candidates, err := source.RenameCandidates(ctx, deps.snapshot, deps.fh, args.Position)
+		if err != nil {
+			return err
+		}
+		for _, cand := range candidates {
+			var reason string
+			if cand.Err != nil {
+				reason = cand.Err.Error()
+			}
+			result.Candidates = append(result.Candidates, command.RenameCandidate{
+				Name:     cand.Name,
+				Kind:     cand.Kind,
+				Location: cand.Location,
+				Reason:   reason,
+			})
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (c *commandHandler) PrepareRename(ctx context.Context, args protocol.TextDocumentPositionParams) (command.PrepareRenameResult, error) {
+	var result command.PrepareRenameResult
+	err := c.run(ctx, commandConfig{
+		forURI: args.TextDocument.URI,
+	}, func(ctx context.Context, deps commandDeps) error {
+		item, _, err := source.PrepareRename(ctx, deps.snapshot, deps.fh, args.Position)
+		var refusal *source.RefusalError
+		if errors.As(err, &refusal) {
+			result.Reason = string(refusal.Reason)
+			result.Message = refusal.Error()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		result.Range = item.Range
+		result.Placeholder = item.Text
+		for _, mode := range item.PackageModes {
+			result.PackageModes = append(result.PackageModes, string(mode))
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (c *commandHandler) RenamePackage(ctx context.Context, args command.RenamePackageArgs) error {
+	return c.run(ctx, commandConfig{
+		forURI: args.Rename.TextDocument.URI,
+	}, func(ctx context.Context, deps commandDeps) error {
+		res, err := c.s.renameEdit(ctx, deps.snapshot, deps.fh, &args.Rename, source.PackageRenameMode(args.Mode), nil)
+		if err != nil {
+			return err
+		}
+		r, err := c.s.client.ApplyEdit(ctx, &protocol.ApplyWorkspaceEditParams{
+			Label: fmt.Sprintf("Rename package to %s", args.Rename.NewName),
+			Edit:  *res.edit,
+		})
+		if err != nil {
+			return err
+		}
+		if !r.Applied {
+			return errors.New(r.FailureReason)
+		}
+		return nil
+	})
+}
+
+func (c *commandHandler) FixStutteringNames(ctx context.Context, args command.URIArg) (command.FixStutteringNamesResult, error) {
+	var result command.FixStutteringNamesResult
+	err := c.run(ctx, commandConfig{
+		progress: "Fixing stuttering names",
+		forURI:   args.URI,
+	}, func(ctx context.Context, deps commandDeps) error {
+		edits, annotations, renames, err := source.FixStutteringNames(ctx, deps.snapshot, deps.fh)
+		if err != nil {
+			return err
+		}
+		var uris []string
+		for uri := range edits {
+			uris = append(uris, string(uri))
+		}
+		sort.Strings(uris)
+		for _, u := range uris {
+			fh, err := deps.snapshot.GetVersionedFile(ctx, span.URI(u))
+			if err != nil {
+				return err
+			}
+			result.Edit.DocumentChanges = append(result.Edit.DocumentChanges, documentChanges(fh, edits[span.URI(u)])...)
+		}
+		result.Edit.ChangeAnnotations = annotations
+		for _, r := range renames {
+			var reason string
+			if r.Err != nil {
+				reason = r.Err.Error()
+			}
+			result.Renames = append(result.Renames, command.StutterRename{
+				OldName:  r.OldName,
+				NewName:  r.NewName,
+				Location: r.Location,
+				Reason:   reason,
+			})
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (c *commandHandler) UpdateMovedImports(ctx context.Context, args command.UpdateMovedImportsArgs) error {
+	return c.run(ctx, commandConfig{
+		progress: "Updating imports",
+		forURI:   args.URI,
+	}, func(ctx context.Context, deps commandDeps) error {
+		edits, err := source.MovedPackageEdits(ctx, deps.snapshot, args.OldPath, args.NewPath)
+		if err != nil {
+			return err
+		}
+		if len(edits) == 0 {
+			return nil
+		}
+		var changes []protocol.DocumentChanges
+		for uri, e := range edits {
+			fh, err := deps.snapshot.GetVersionedFile(ctx, uri)
+			if err != nil {
+				return err
+			}
+			changes = append(changes, documentChanges(fh, e)...)
+		}
+		r, err := c.s.client.ApplyEdit(ctx, &protocol.ApplyWorkspaceEditParams{
+			Edit: protocol.WorkspaceEdit{
+				DocumentChanges: changes,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if !r.Applied {
+			return errors.New(r.FailureReason)
+		}
+		return nil
+	})
+}
+
 func (c *commandHandler) AddImport(ctx context.Context, args command.AddImportArgs) error {
 	return c.run(ctx, commandConfig{
 		progress: "Adding import",
@@ -22,52 +22,84 @@ const (
 	AddDependency         Command = "add_dependency"
 	AddImport             Command = "add_import"
 	ApplyFix              Command = "apply_fix"
+	BeginRename           Command = "begin_rename"
 	CheckUpgrades         Command = "check_upgrades"
+	CommitRename          Command = "commit_rename"
+	DryRunRename          Command = "dry_run_rename"
 	EditGoDirective       Command = "edit_go_directive"
+	FixStutteringNames    Command = "fix_stuttering_names"
 	GCDetails             Command = "gc_details"
 	Generate              Command = "generate"
 	GenerateGoplsMod      Command = "generate_gopls_mod"
 	GoGetPackage          Command = "go_get_package"
 	ListImports           Command = "list_imports"
 	ListKnownPackages     Command = "list_known_packages"
+	PrepareRename         Command = "prepare_rename"
 	RegenerateCgo         Command = "regenerate_cgo"
 	RemoveDependency      Command = "remove_dependency"
+	RenameCacheState      Command = "rename_cache_state"
+	RenameCandidates      Command = "rename_candidates"
+	RenameHistory         Command = "rename_history"
+	RenameManifest        Command = "rename_manifest"
+	RenamePackage         Command = "rename_package"
+	RenameProfile         Command = "rename_profile"
+	RenameRemainder       Command = "rename_remainder"
 	ResetGoModDiagnostics Command = "reset_go_mod_diagnostics"
+	ResolveRenameSpec     Command = "resolve_rename_spec"
 	RunTests              Command = "run_tests"
 	RunVulncheckExp       Command = "run_vulncheck_exp"
 	StartDebugging        Command = "start_debugging"
 	Test                  Command = "test"
+	TextOccurrences       Command = "text_occurrences"
 	Tidy                  Command = "tidy"
 	ToggleGCDetails       Command = "toggle_gc_details"
 	UpdateGoSum           Command = "update_go_sum"
+	UpdateMovedImports    Command = "update_moved_imports"
 	UpgradeDependency     Command = "upgrade_dependency"
 	Vendor                Command = "vendor"
+	VerifyRename          Command = "verify_rename"
 )
 
 var Commands = []Command{
 	AddDependency,
 	AddImport,
 	ApplyFix,
+	BeginRename,
 	CheckUpgrades,
+	CommitRename,
+	DryRunRename,
 	EditGoDirective,
+	FixStutteringNames,
 	GCDetails,
 	Generate,
 	GenerateGoplsMod,
 	GoGetPackage,
 	ListImports,
 	ListKnownPackages,
+	PrepareRename,
 	RegenerateCgo,
 	RemoveDependency,
+	RenameCacheState,
+	RenameCandidates,
+	RenameHistory,
+	RenameManifest,
+	RenamePackage,
+	RenameProfile,
+	RenameRemainder,
 	ResetGoModDiagnostics,
+	ResolveRenameSpec,
 	RunTests,
 	RunVulncheckExp,
 	StartDebugging,
 	Test,
+	TextOccurrences,
 	Tidy,
 	ToggleGCDetails,
 	UpdateGoSum,
+	UpdateMovedImports,
 	UpgradeDependency,
 	Vendor,
+	VerifyRename,
 }
 
 func Dispatch(ctx context.Context, params *protocol.ExecuteCommandParams, s Interface) (interface{}, error) {
@@ -90,18 +122,42 @@ func Dispatch(ctx context.Context, params *protocol.ExecuteCommandParams, s Inte
 			return nil, err
 		}
 		return nil, s.ApplyFix(ctx, a0)
+	case "gopls.begin_rename":
+		var a0 protocol.RenameParams
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return s.BeginRename(ctx, a0)
 	case "gopls.check_upgrades":
 		var a0 CheckUpgradesArgs
 		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
 			return nil, err
 		}
 		return nil, s.CheckUpgrades(ctx, a0)
+	case "gopls.commit_rename":
+		var a0 CommitRenameArgs
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return s.CommitRename(ctx, a0)
+	case "gopls.dry_run_rename":
+		var a0 protocol.RenameParams
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return s.DryRunRename(ctx, a0)
 	case "gopls.edit_go_directive":
 		var a0 EditGoDirectiveArgs
 		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
 			return nil, err
 		}
 		return nil, s.EditGoDirective(ctx, a0)
+	case "gopls.fix_stuttering_names":
+		var a0 URIArg
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return s.FixStutteringNames(ctx, a0)
 	case "gopls.gc_details":
 		var a0 protocol.DocumentURI
 		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
@@ -138,6 +194,12 @@ func Dispatch(ctx context.Context, params *protocol.ExecuteCommandParams, s Inte
 			return nil, err
 		}
 		return s.ListKnownPackages(ctx, a0)
+	case "gopls.prepare_rename":
+		var a0 protocol.TextDocumentPositionParams
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return s.PrepareRename(ctx, a0)
 	case "gopls.regenerate_cgo":
 		var a0 URIArg
 		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
@@ -150,12 +212,52 @@ func Dispatch(ctx context.Context, params *protocol.ExecuteCommandParams, s Inte
 			return nil, err
 		}
 		return nil, s.RemoveDependency(ctx, a0)
+	case "gopls.rename_cache_state":
+		var a0 URIArg
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return s.RenameCacheState(ctx, a0)
+	case "gopls.rename_candidates":
+		var a0 protocol.TextDocumentPositionParams
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return s.RenameCandidates(ctx, a0)
+	case "gopls.rename_history":
+		return s.RenameHistory(ctx)
+	case "gopls.rename_manifest":
+		var a0 RenameManifestArgs
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return s.RenameManifest(ctx, a0)
+	case "gopls.rename_package":
+		var a0 RenamePackageArgs
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return nil, s.RenamePackage(ctx, a0)
+	case "gopls.rename_profile":
+		var a0 protocol.RenameParams
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return s.RenameProfile(ctx, a0)
+	case "gopls.rename_remainder":
+		return s.RenameRemainder(ctx)
 	case "gopls.reset_go_mod_diagnostics":
 		var a0 URIArg
 		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
 			return nil, err
 		}
 		return nil, s.ResetGoModDiagnostics(ctx, a0)
+	case "gopls.resolve_rename_spec":
+		var a0 ResolveRenameSpecArgs
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return s.ResolveRenameSpec(ctx, a0)
 	case "gopls.run_tests":
 		var a0 RunTestsArgs
 		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
@@ -182,6 +284,12 @@ func Dispatch(ctx context.Context, params *protocol.ExecuteCommandParams, s Inte
 			return nil, err
 		}
 		return nil, s.Test(ctx, a0, a1, a2)
+	case "gopls.text_occurrences":
+		var a0 protocol.TextDocumentPositionParams
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return s.TextOccurrences(ctx, a0)
 	case "gopls.tidy":
 		var a0 URIArgs
 		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
@@ -200,6 +308,12 @@ func Dispatch(ctx context.Context, params *protocol.ExecuteCommandParams, s Inte
 			return nil, err
 		}
 		return nil, s.UpdateGoSum(ctx, a0)
+	case "gopls.update_moved_imports":
+		var a0 UpdateMovedImportsArgs
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return nil, s.UpdateMovedImports(ctx, a0)
 	case "gopls.upgrade_dependency":
 		var a0 DependencyArgs
 		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
@@ -212,6 +326,12 @@ func Dispatch(ctx context.Context, params *protocol.ExecuteCommandParams, s Inte
 			return nil, err
 		}
 		return nil, s.Vendor(ctx, a0)
+	case "gopls.verify_rename":
+		var a0 VerifyRenameArgs
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return s.VerifyRename(ctx, a0)
 	}
 	return nil, fmt.Errorf("unsupported command %q", params.Command)
 }
@@ -252,6 +372,18 @@ func NewApplyFixCommand(title string, a0 ApplyFixArgs) (protocol.Command, error)
 	}, nil
 }
 
+func NewBeginRenameCommand(title string, a0 protocol.RenameParams) (protocol.Command, error) {
+	args, err := MarshalArgs(a0)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.begin_rename",
+		Arguments: args,
+	}, nil
+}
+
 func NewCheckUpgradesCommand(title string, a0 CheckUpgradesArgs) (protocol.Command, error) {
 	args, err := MarshalArgs(a0)
 	if err != nil {
@@ -264,6 +396,30 @@ func NewCheckUpgradesCommand(title string, a0 CheckUpgradesArgs) (protocol.Comma
 	}, nil
 }
 
+func NewCommitRenameCommand(title string, a0 CommitRenameArgs) (protocol.Command, error) {
+	args, err := MarshalArgs(a0)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.commit_rename",
+		Arguments: args,
+	}, nil
+}
+
+func NewDryRunRenameCommand(title string, a0 protocol.RenameParams) (protocol.Command, error) {
+	args, err := MarshalArgs(a0)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.dry_run_rename",
+		Arguments: args,
+	}, nil
+}
+
 func NewEditGoDirectiveCommand(title string, a0 EditGoDirectiveArgs) (protocol.Command, error) {
 	args, err := MarshalArgs(a0)
 	if err != nil {
@@ -276,6 +432,18 @@ func NewEditGoDirectiveCommand(title string, a0 EditGoDirectiveArgs) (protocol.C
 	}, nil
 }
 
+func NewFixStutteringNamesCommand(title string, a0 URIArg) (protocol.Command, error) {
+	args, err := MarshalArgs(a0)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.fix_stuttering_names",
+		Arguments: args,
+	}, nil
+}
+
 func NewGCDetailsCommand(title string, a0 protocol.DocumentURI) (protocol.Command, error) {
 	args, err := MarshalArgs(a0)
 	if err != nil {
@@ -348,6 +516,18 @@ func NewListKnownPackagesCommand(title string, a0 URIArg) (protocol.Command, err
 	}, nil
 }
 
+func NewPrepareRenameCommand(title string, a0 protocol.TextDocumentPositionParams) (protocol.Command, error) {
+	args, err := MarshalArgs(a0)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.prepare_rename",
+		Arguments: args,
+	}, nil
+}
+
 func NewRegenerateCgoCommand(title string, a0 URIArg) (protocol.Command, error) {
 	args, err := MarshalArgs(a0)
 	if err != nil {
@@ -372,6 +552,90 @@ func NewRemoveDependencyCommand(title string, a0 RemoveDependencyArgs) (protocol
 	}, nil
 }
 
+func NewRenameCacheStateCommand(title string, a0 URIArg) (protocol.Command, error) {
+	args, err := MarshalArgs(a0)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.rename_cache_state",
+		Arguments: args,
+	}, nil
+}
+
+func NewRenameCandidatesCommand(title string, a0 protocol.TextDocumentPositionParams) (protocol.Command, error) {
+	args, err := MarshalArgs(a0)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.rename_candidates",
+		Arguments: args,
+	}, nil
+}
+
+func NewRenameHistoryCommand(title string) (protocol.Command, error) {
+	args, err := MarshalArgs()
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.rename_history",
+		Arguments: args,
+	}, nil
+}
+
+func NewRenameManifestCommand(title string, a0 RenameManifestArgs) (protocol.Command, error) {
+	args, err := MarshalArgs(a0)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.rename_manifest",
+		Arguments: args,
+	}, nil
+}
+
+func NewRenamePackageCommand(title string, a0 RenamePackageArgs) (protocol.Command, error) {
+	args, err := MarshalArgs(a0)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.rename_package",
+		Arguments: args,
+	}, nil
+}
+
+func NewRenameProfileCommand(title string, a0 protocol.RenameParams) (protocol.Command, error) {
+	args, err := MarshalArgs(a0)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.rename_profile",
+		Arguments: args,
+	}, nil
+}
+
+func NewRenameRemainderCommand(title string) (protocol.Command, error) {
+	args, err := MarshalArgs()
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.rename_remainder",
+		Arguments: args,
+	}, nil
+}
+
 func NewResetGoModDiagnosticsCommand(title string, a0 URIArg) (protocol.Command, error) {
 	args, err := MarshalArgs(a0)
 	if err != nil {
@@ -384,6 +648,18 @@ func NewResetGoModDiagnosticsCommand(title string, a0 URIArg) (protocol.Command,
 	}, nil
 }
 
+func NewResolveRenameSpecCommand(title string, a0 ResolveRenameSpecArgs) (protocol.Command, error) {
+	args, err := MarshalArgs(a0)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.resolve_rename_spec",
+		Arguments: args,
+	}, nil
+}
+
 func NewRunTestsCommand(title string, a0 RunTestsArgs) (protocol.Command, error) {
 	args, err := MarshalArgs(a0)
 	if err != nil {
@@ -432,6 +708,18 @@ func NewTestCommand(title string, a0 protocol.DocumentURI, a1 []string, a2 []str
 	}, nil
 }
 
+func NewTextOccurrencesCommand(title string, a0 protocol.TextDocumentPositionParams) (protocol.Command, error) {
+	args, err := MarshalArgs(a0)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.text_occurrences",
+		Arguments: args,
+	}, nil
+}
+
 func NewTidyCommand(title string, a0 URIArgs) (protocol.Command, error) {
 	args, err := MarshalArgs(a0)
 	if err != nil {
@@ -468,6 +756,18 @@ func NewUpdateGoSumCommand(title string, a0 URIArgs) (protocol.Command, error) {
 	}, nil
 }
 
+func NewUpdateMovedImportsCommand(title string, a0 UpdateMovedImportsArgs) (protocol.Command, error) {
+	args, err := MarshalArgs(a0)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.update_moved_imports",
+		Arguments: args,
+	}, nil
+}
+
 func NewUpgradeDependencyCommand(title string, a0 DependencyArgs) (protocol.Command, error) {
 	args, err := MarshalArgs(a0)
 	if err != nil {
@@ -491,3 +791,15 @@ func NewVendorCommand(title string, a0 URIArg) (protocol.Command, error) {
 		Arguments: args,
 	}, nil
 }
+
+func NewVerifyRenameCommand(title string, a0 VerifyRenameArgs) (protocol.Command, error) {
+	args, err := MarshalArgs(a0)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.verify_rename",
+		Arguments: args,
+	}, nil
+}
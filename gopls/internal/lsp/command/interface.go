@@ -153,6 +153,143 @@ type Interface interface {
 	//
 	// Run vulnerability check (`govulncheck`).
 	RunVulncheckExp(context.Context, VulncheckArgs) error
+
+	// RenameCandidates: List rename candidates
+	//
+	// Returns the objects that a rename at the given position could apply to.
+	// More than one candidate is returned if the position is ambiguous, for
+	// example at an embedded field, which denotes both a field and a type.
+	// Clients may let the user choose, and request the rename at the
+	// declaration of the chosen candidate.
+	RenameCandidates(context.Context, protocol.TextDocumentPositionParams) (RenameCandidatesResult, error)
+
+	// PrepareRename: Prepare a rename
+	//
+	// Checks whether the identifier at the given position can be renamed,
+	// as textDocument/prepareRename does, and returns its range and name,
+	// or else the code of the reason why the rename is refused along with
+	// its message, so that clients can act upon specific refusals.
+	PrepareRename(context.Context, protocol.TextDocumentPositionParams) (PrepareRenameResult, error)
+
+	// RenamePackage: Rename a package in a given mode
+	//
+	// Renames the package whose package clause is at the given position,
+	// as textDocument/rename does, in one of the modes that
+	// gopls.prepare_rename reports there rather than the default one, and
+	// applies the edits through a workspace/applyEdit request.
+	RenamePackage(context.Context, RenamePackageArgs) error
+
+	// UpdateMovedImports: Update imports of a moved package
+	//
+	// Updates the imports of the workspace after the directory of a package
+	// was moved outside the editor, for example by git mv, as renaming the
+	// directory with gopls would have. Only imports that no longer denote a
+	// package are changed.
+	UpdateMovedImports(context.Context, UpdateMovedImportsArgs) error
+
+	// TextOccurrences: List occurrences in non-Go files
+	//
+	// Returns the locations, in non-Go files of the workspace such as
+	// documents, configuration and scripts, of the qualified name or import
+	// path of the object or package at the given position, so that those a
+	// rename does not edit may be updated by hand.
+	TextOccurrences(context.Context, protocol.TextDocumentPositionParams) (TextOccurrencesResult, error)
+
+	// DryRunRename: Report the effects of a rename
+	//
+	// Performs the analysis of a rename, including its conflicts, the
+	// implementations it affects and the optional edits it offers, and
+	// returns a report of its effects instead of its edits, so that a large
+	// rename can be reviewed before it is applied.
+	DryRunRename(context.Context, protocol.RenameParams) (DryRunRenameResult, error)
+
+	// VerifyRename: Verify a rename
+	//
+	// Loads and type-checks the packages affected by a rename, and their
+	// reverse dependencies, as they would be once its edits are applied,
+	// and returns their errors. No file is written.
+	VerifyRename(context.Context, VerifyRenameArgs) (VerifyRenameResult, error)
+
+	// RenameManifest: Compute the manifest of a rename
+	//
+	// Returns the manifest of a rename with the given optional edits: the
+	// names that its edits change, with their kinds and the positions of
+	// their declarations, so that the repositories depending on the
+	// renamed objects or packages can be updated by tools reading it. The
+	// JSON schema of the manifest is versioned by its version field.
+	RenameManifest(context.Context, RenameManifestArgs) (RenameManifestResult, error)
+
+	// ResolveRenameSpec: Resolve a gorename specifier
+	//
+	// Returns the location of the declaration of the object denoted by a
+	// specifier of the -from flag of gorename, such as
+	// "encoding/json".Decoder.Decode, among the packages of the workspace,
+	// for the scripts addressing renames as gorename does. The filename of
+	// the json.go::x form must be absolute.
+	ResolveRenameSpec(context.Context, ResolveRenameSpecArgs) (ResolveRenameSpecResult, error)
+
+	// RenameHistory: List recently computed renames
+	//
+	// Returns the journal of the renames computed by the server in this
+	// session, most recent last, so that they can be audited or undone.
+	// A rename is recorded when its edits are computed: the server can't
+	// tell whether the client applies the edits of a textDocument/rename
+	// response, so only the records marked Applied, of the renames whose
+	// edits the server applied itself, are known to have taken effect.
+	RenameHistory(context.Context) (RenameHistoryResult, error)
+
+	// BeginRename: Begin a rename session
+	//
+	// Performs the analysis of a rename and returns a preview of its
+	// effects, as gopls.dry_run_rename does, along with the token of a
+	// session to commit with gopls.commit_rename.
+	BeginRename(context.Context, protocol.RenameParams) (BeginRenameResult, error)
+
+	// CommitRename: Commit a rename session
+	//
+	// Applies the edits of a rename begun by gopls.begin_rename, along
+	// with the selected optional edits, through a workspace/applyEdit
+	// request. The edits are computed again as for textDocument/rename, in
+	// the workspace as it is at commit, so that a rename introducing
+	// conflicts fails unless the renameForce setting is set. A session can
+	// be committed once.
+	CommitRename(context.Context, CommitRenameArgs) (CommitRenameResult, error)
+
+	// RenameRemainder: List the occurrences left by the last rename
+	//
+	// Returns the locations of the occurrences that the last rename left
+	// unchanged in the files not open in the editor, as renames are
+	// restricted to open files when the renameOpenFilesOnly setting is
+	// set, for the client to present them as a location list.
+	RenameRemainder(context.Context) (RenameRemainderResult, error)
+
+	// RenameCacheState: Dump the rename analysis cache
+	//
+	// Returns the state of the cache of the per-package analyses of
+	// renames of the view of the given file, such as the interface
+	// satisfaction constraints of the packages, to diagnose slow or
+	// incorrect renames: its entries, with the keys they are valid for,
+	// and its numbers of hits, misses and evictions.
+	RenameCacheState(context.Context, URIArg) (RenameCacheStateResult, error)
+
+	// RenameProfile: Profile the analysis of a rename
+	//
+	// Runs again the analysis of the given rename, phase by phase, and
+	// returns the duration of each phase and the size of its results, to
+	// pinpoint what makes renames slow in a workspace. The phases run in
+	// order, so the packages type checked by one are cached for the next,
+	// as they are in a rename.
+	RenameProfile(context.Context, protocol.RenameParams) (RenameProfileResult, error)
+
+	// FixStutteringNames: Fix the stuttering names of a package
+	//
+	// Computes the renames of the exported names of the package of the
+	// given file that stutter with the package name, such as user.UserID,
+	// to the names without the prefix, such as user.ID, and returns them
+	// as one workspace edit, with a change annotation per rename. The
+	// renames colliding with one another, or refused by the rename engine,
+	// are left out of the edit and reported.
+	FixStutteringNames(context.Context, URIArg) (FixStutteringNamesResult, error)
 }
 
 type RunTestsArgs struct {
@@ -183,6 +320,10 @@ type ApplyFixArgs struct {
 	URI protocol.DocumentURI
 	// The document range to scan for fixes.
 	Range protocol.Range
+	// The new name of the identifier at the start of Range, for the
+	// rename_name fix, or of the receivers of the methods of the type
+	// named there, for the align_receivers fix.
+	NewName string
 }
 
 type URIArg struct {
@@ -303,6 +444,355 @@ type DebuggingResult struct {
 	URLs []string
 }
 
+type RenameCandidatesResult struct {
+	// Candidates lists the objects that may be renamed, in order of
+	// preference.
+	Candidates []RenameCandidate
+}
+
+type PrepareRenameResult struct {
+	// Range is the range of the identifier to rename, if it can be renamed.
+	Range protocol.Range
+	// Placeholder is the current name of the identifier.
+	Placeholder string `json:",omitempty"`
+	// Reason, if set, is the code of the reason why the rename is refused:
+	// "syntax_error", "ambiguous", "no_package", "main_package",
+	// "x_test_package", "no_module", "package_error", "embedded_field" or
+	// "blank".
+	Reason string `json:",omitempty"`
+	// Message describes the refusal, if any.
+	Message string `json:",omitempty"`
+	// PackageModes lists, at a package clause, the modes in which the
+	// package can be renamed by gopls.rename_package, the default first:
+	// "directory", which renames its directory and updates the import
+	// paths, and "clause", which renames only its package clauses and the
+	// names of its imports.
+	PackageModes []string `json:",omitempty"`
+}
+
+type RenamePackageArgs struct {
+	// The rename, at the package clause of a file of the package.
+	Rename protocol.RenameParams
+	// Mode is one of the modes that gopls.prepare_rename reports at the
+	// package clause.
+	Mode string
+}
+
+type RenameCandidate struct {
+	// Name is the current name of the object.
+	Name string
+	// Kind is the kind of the object, such as "field", "type" or "method".
+	Kind string
+	// Location is the declaration of the object. A rename requested at this
+	// location applies unambiguously to this candidate.
+	Location protocol.Location
+	// Reason, if set, explains why the object can't be renamed.
+	Reason string `json:",omitempty"`
+}
+
+type FixStutteringNamesResult struct {
+	// Edit performs the renames. The edits of each rename carry the
+	// change annotation "stutter/<old name>".
+	Edit protocol.WorkspaceEdit
+	// Renames lists the renames of the stuttering names, in the order of
+	// the names.
+	Renames []StutterRename
+}
+
+type StutterRename struct {
+	// OldName is the stuttering name, such as UserID.
+	OldName string
+	// NewName is the name without the prefix, such as ID.
+	NewName string
+	// Location is the declaration of the name.
+	Location protocol.Location
+	// Reason, if set, explains why the rename is left out of the edit.
+	Reason string `json:",omitempty"`
+}
+
+type UpdateMovedImportsArgs struct {
+	// The file whose broken import revealed the move.
+	URI protocol.DocumentURI
+	// The import path of the moved directory, before the move.
+	OldPath string
+	// The import path of the moved directory, after the move.
+	NewPath string
+}
+
+type TextOccurrencesResult struct {
+	// Locations lists the occurrences of the qualified name or import path.
+	Locations []protocol.Location
+}
+
+type DryRunRenameResult struct {
+	// Files lists the files that the required edits of the rename change.
+	Files []RenameFileReport
+	// Annotations lists the groups of optional edits, by change annotation.
+	Annotations []RenameAnnotationReport
+	// Conflicts describes the conflicts that the rename introduces, which
+	// make it fail unless forced.
+	Conflicts []string
+	// Warnings describes the consequences of the rename that its edits
+	// cannot address.
+	Warnings []string
+	// Skipped lists the implementations of a renamed interface method that
+	// the rename leaves unchanged, as they lie outside the workspace.
+	Skipped []SkippedImplementation
+	// ExportData lists the packages referring to the renamed object or
+	// package that the rename cannot edit, as they are loaded only from
+	// export data.
+	ExportData []ExportDataPackage
+	// ExternalReferences counts, by package, the references to a renamed
+	// exported object outside the edit scope of the rename, which it does
+	// not update.
+	ExternalReferences []ExternalReferenceCount
+	// Package reports whether the rename is of a package.
+	Package bool
+	// Packages lists the IDs of the packages whose files the required or
+	// optional edits change, which may need to be checked or tested again
+	// once the rename is applied.
+	Packages []string
+	// Exported reports whether the renamed object or package may be
+	// referred to by importers outside the workspace, which the rename
+	// cannot update.
+	Exported bool
+}
+
+type SkippedImplementation struct {
+	// Location is the declaration of the method, or the zero value if it
+	// is predeclared.
+	Location protocol.Location
+	// Name is the name of the method, qualified by its receiver type.
+	Name string
+	// Reason describes why the method is not renamed, such as "vendored"
+	// or "in the standard library".
+	Reason string
+}
+
+type ExportDataPackage struct {
+	// Path is the path of the package.
+	Path string
+	// References lists the known references of the package: the imports
+	// of the renamed package, and the references within its exported
+	// declarations.
+	References []protocol.Location
+}
+
+type ExternalReferenceCount struct {
+	// Package is the path of the package.
+	Package string
+	// Scope is where the package lies: "outside the workspace", for the
+	// packages of the module cache, or the name of another workspace
+	// folder.
+	Scope string
+	// References is the number of references of the package.
+	References int
+}
+
+type RenameFileReport struct {
+	URI protocol.DocumentURI
+	// Edits is the number of edits of the file.
+	Edits int
+	// Packages lists the IDs of the packages of the file, if it is a Go file.
+	Packages []string `json:",omitempty"`
+}
+
+type VerifyRenameArgs struct {
+	// Params is the rename to verify.
+	Params protocol.RenameParams
+	// Annotations lists the change annotations of the optional edits to
+	// apply along with the required ones, by id or by group.
+	Annotations []string
+}
+
+type VerifyRenameResult struct {
+	// Errors lists the errors of the affected packages after the rename.
+	Errors []RenameError
+}
+
+type RenameError struct {
+	// Location is the position of the error, or the zero value if it has
+	// none.
+	Location protocol.Location
+	Message  string
+}
+
+type RenameManifestArgs struct {
+	// Params is the rename.
+	Params protocol.RenameParams
+	// Annotations lists the change annotations of the optional edits
+	// applied along with the required ones, by id or by group.
+	Annotations []string
+}
+
+type RenameManifestResult struct {
+	// Version is the version of the schema of the manifest, currently 1.
+	Version int `json:"version"`
+	// Renames lists the names changed by the rename, ordered by their old
+	// qualified names. The objects that other packages cannot refer to,
+	// such as local variables, are left out.
+	Renames []ManifestRename `json:"renames"`
+}
+
+type ManifestRename struct {
+	// Kind is the kind of the renamed object: "const", "var", "func",
+	// "type", "field", "method", or "package".
+	Kind string `json:"kind"`
+	// Old and New are the names before and after the rename.
+	Old ManifestName `json:"old"`
+	New ManifestName `json:"new"`
+	// Positions lists the declarations of the old name, before the
+	// rename. It is empty for packages.
+	Positions []protocol.Location `json:"positions"`
+}
+
+type ManifestName struct {
+	// Qualified is the name qualified by the import path of its package,
+	// such as "example.com/a.T.M", or the import path of a package.
+	Qualified string `json:"qualified"`
+	// Package is the import path of the package of the object, or of the
+	// package itself.
+	Package string `json:"package"`
+	// Name is the name of the object, qualified by its type for fields and
+	// methods, such as "T.M", or the name of the package.
+	Name string `json:"name"`
+}
+
+type ResolveRenameSpecArgs struct {
+	// Spec is the specifier, in the form of the -from flag of gorename.
+	Spec string
+}
+
+type ResolveRenameSpecResult struct {
+	// Location is the declaring identifier of the object.
+	Location protocol.Location
+}
+
+type BeginRenameResult struct {
+	// Token identifies the rename session.
+	Token string
+	// Preview describes the effects of the rename.
+	Preview DryRunRenameResult
+}
+
+type CommitRenameArgs struct {
+	// Token identifies the rename session, as returned by
+	// gopls.begin_rename.
+	Token string
+	// Annotations lists the change annotations of the optional edits to
+	// apply along with the required ones, by id or by group.
+	Annotations []string
+}
+
+type CommitRenameResult struct {
+	// Revalidated reports whether the workspace changed since the
+	// session began, so that the edits may differ from its preview.
+	Revalidated bool
+	// Remaining lists the occurrences left unchanged in the files not
+	// open in the editor, if renames are restricted to open files.
+	Remaining []protocol.Location
+	// ExternalReferences counts, by package, the references to a renamed
+	// exported object outside the edit scope of the rename, which it did
+	// not update.
+	ExternalReferences []ExternalReferenceCount
+}
+
+type RenameRemainderResult struct {
+	OldName string
+	NewName string
+	// Locations lists the occurrences left unchanged by the last rename.
+	Locations []protocol.Location
+}
+
+type RenameCacheStateResult struct {
+	// Hits and Misses count the lookups of the cache that found a
+	// valid entry and that found none. Evictions counts the entries
+	// evicted as no longer valid, as their packages changed, or as the
+	// least recently used of a full cache.
+	Hits      int
+	Misses    int
+	Evictions int
+	// Entries lists the entries of the cache, by package and kind.
+	Entries []RenameCacheEntry
+}
+
+type RenameCacheEntry struct {
+	// Kind is the analysis, such as "constraints".
+	Kind string
+	// Package is the ID of the package analyzed.
+	Package string
+	// PackageKey and Options are the hashes of the files, metadata and
+	// dependencies of the package, and of the rename options of the view
+	// that the analysis depends on, which the entry is valid for.
+	PackageKey string
+	Options    string
+	// Size is the number of results of the analysis.
+	Size int
+	// Hits is the number of lookups that the entry served.
+	Hits int
+	// Duration is the time taken by the analysis, such as "1.5ms".
+	Duration string
+}
+
+type RenameProfileResult struct {
+	// Phases lists the phases of the analysis in order: "metadata",
+	// "load", "references", "satisfy", "implementations", "check" and
+	// "edits".
+	// The phases that do not apply to the renamed object or package,
+	// such as the implementations of a method, are left out.
+	Phases []RenamePhaseProfile
+	// Total is the time taken by the whole analysis, such as "1.5s".
+	Total string
+}
+
+type RenamePhaseProfile struct {
+	// Name is the name of the phase, such as "references".
+	Name string
+	// Duration is the time taken by the phase, such as "1.5ms".
+	Duration string
+	// Size is the number of results of the phase, which Unit names, such
+	// as "packages" or "references".
+	Size int
+	Unit string
+}
+
+type RenameHistoryResult struct {
+	// Renames lists the recorded renames, oldest first.
+	Renames []RenameRecord
+}
+
+type RenameRecord struct {
+	// Location is the position at which the rename was requested.
+	Location protocol.Location
+	OldName  string
+	NewName  string
+	// Package reports whether the rename is of a package.
+	Package bool
+	// Files lists the files that the edits of the rename change, including
+	// its optional edits if the client supports change annotations.
+	Files []protocol.DocumentURI
+	// Remaining lists the occurrences left unchanged in the files not
+	// open in the editor, if renames are restricted to open files.
+	Remaining []protocol.Location
+	// Applied reports whether the server applied the edits of the rename
+	// itself, as gopls.commit_rename does, and the client accepted them.
+	Applied bool
+	// Time is the time at which the rename was computed, in RFC 3339
+	// format.
+	Time string
+}
+
+type RenameAnnotationReport struct {
+	// ID is the identifier of the change annotation, of the form
+	// group/name, or group.
+	ID                string
+	Label             string
+	Description       string
+	NeedsConfirmation bool
+	// Files lists the files that the edits of the annotation change.
+	Files []RenameFileReport
+}
+
 type VulncheckArgs struct {
 	// Any document in the directory from which govulncheck will run.
 	URI protocol.DocumentURI
@@ -96,6 +96,14 @@ type EditorConfig struct {
 	// Whether to edit files with windows line endings.
 	WindowsLineEndings bool
 
+	// Whether the editor honors change annotations in rename edits. The
+	// editor applies annotated edits without asking for confirmation.
+	HonorsChangeAnnotations bool
+
+	// Whether the editor lacks support for renaming files in workspace
+	// edits.
+	NoFileRenames bool
+
 	// Map of language ID -> regexp to match, used to set the file type of new
 	// buffers. Applied as an overlay on top of the following defaults:
 	//  "go" -> ".*\.go"
@@ -258,6 +266,7 @@ func (e *Editor) initialize(ctx context.Context) error {
 
 	params.Capabilities.TextDocument.Completion.CompletionItem.SnippetSupport = true
 	params.Capabilities.TextDocument.SemanticTokens.Requests.Full = true
+	params.Capabilities.TextDocument.Rename.HonorsChangeAnnotations = e.config.HonorsChangeAnnotations
 	// copied from lsp/semantic.go to avoid import cycle in tests
 	params.Capabilities.TextDocument.SemanticTokens.TokenTypes = []string{
 		"namespace", "type", "class", "enum", "interface",
@@ -276,6 +285,9 @@ func (e *Editor) initialize(ctx context.Context) error {
 			"rename",
 		},
 	}
+	if e.config.NoFileRenames {
+		params.Capabilities.Workspace.WorkspaceEdit.ResourceOperations = nil
+	}
 
 	params.Trace = "messages"
 	// TODO: support workspace folders.
@@ -1229,6 +1241,31 @@ func (e *Editor) Implementations(ctx context.Context, path string, pos Pos) ([]p
 	return e.Server.Implementation(ctx, params)
 }
 
+// WillRenameFiles requests the edits accompanying the renaming of oldPath
+// to newPath from the connected LSP server, as a client does before renaming
+// files, and applies them. It does not perform the renaming itself.
+func (e *Editor) WillRenameFiles(ctx context.Context, oldPath, newPath string) error {
+	if e.Server == nil {
+		return nil
+	}
+	params := &protocol.RenameFilesParams{
+		Files: []protocol.FileRename{{
+			OldURI: string(e.sandbox.Workdir.URI(oldPath)),
+			NewURI: string(e.sandbox.Workdir.URI(newPath)),
+		}},
+	}
+	wsEdits, err := e.Server.WillRenameFiles(ctx, params)
+	if err != nil {
+		return err
+	}
+	for _, change := range wsEdits.DocumentChanges {
+		if err := e.applyDocumentChange(ctx, change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (e *Editor) RenameFile(ctx context.Context, oldPath, newPath string) error {
 	closed, opened, err := e.renameBuffers(ctx, oldPath, newPath)
 	if err != nil {
@@ -334,6 +334,10 @@ func (w *Workdir) RenameFile(ctx context.Context, oldPath, newPath string) error
 	oldAbs := w.AbsPath(oldPath)
 	newAbs := w.AbsPath(newPath)
 
+	// Like editors, create the missing parent directories of newPath.
+	if err := os.MkdirAll(filepath.Dir(newAbs), 0755); err != nil {
+		return err
+	}
 	if err := robustio.Rename(oldAbs, newAbs); err != nil {
 		return err
 	}
@@ -174,6 +174,14 @@ See https://github.com/golang/go/issues/45732 for more information.`,
 					Supported:           true,
 					ChangeNotifications: "workspace/didChangeWorkspaceFolders",
 				},
+				FileOperations: protocol.FileOperationOptions{
+					WillRename: &protocol.FileOperationRegistrationOptions{
+						Filters: []protocol.FileOperationFilter{
+							{Scheme: "file", Pattern: protocol.FileOperationPattern{Glob: "**/*.go", Matches: protocol.FilePattern}},
+							{Scheme: "file", Pattern: protocol.FileOperationPattern{Glob: "**", Matches: protocol.FolderPattern}},
+						},
+					},
+				},
 			},
 		},
 		ServerInfo: protocol.PServerInfoMsg_initialize{
@@ -201,6 +201,57 @@ func utf16len(s []byte) int {
 	return n
 }
 
+// A PositionCursor computes the protocol positions of offsets within the
+// content of a ColumnMapper. Positions of increasing offsets are computed
+// from the previous one, scanning only the content in between, so that
+// converting the sorted offsets of a file takes time linear in its size
+// rather than in the lengths of the lines they lie in.
+type PositionCursor struct {
+	m      *ColumnMapper
+	linear bool // no //line directive renumbers the lines of m.TokFile
+
+	offset int      // offset of the last position computed, or -1
+	pos    Position // position of offset
+}
+
+// Cursor returns a new PositionCursor for the content of m.
+func (m *ColumnMapper) Cursor() *PositionCursor {
+	c := &PositionCursor{m: m, offset: -1}
+	if size := m.TokFile.Size(); size == len(m.Content) {
+		if size == 0 {
+			c.linear = true
+		} else {
+			end := m.TokFile.Pos(size - 1)
+			c.linear = m.TokFile.PositionFor(end, true) == m.TokFile.PositionFor(end, false)
+		}
+	}
+	return c
+}
+
+// Position returns the protocol position of the specified offset within
+// the content of the mapper of c, as OffsetPosition does.
+func (c *PositionCursor) Position(offset int) (Position, error) {
+	if !c.linear || c.offset < 0 || offset < c.offset || offset >= len(c.m.Content) {
+		pos, err := c.m.OffsetPosition(offset)
+		if err != nil {
+			return Position{}, err
+		}
+		if offset < len(c.m.Content) {
+			c.offset, c.pos = offset, pos
+		}
+		return pos, nil
+	}
+	s := c.m.Content[c.offset:offset]
+	if i := bytes.LastIndexByte(s, '\n'); i >= 0 {
+		c.pos.Line += uint32(bytes.Count(s[:i+1], []byte("\n")))
+		c.pos.Character = uint32(utf16len(s[i+1:]))
+	} else {
+		c.pos.Character += uint32(utf16len(s))
+	}
+	c.offset = offset
+	return c.pos, nil
+}
+
 func (m *ColumnMapper) Span(l Location) (span.Span, error) {
 	return m.RangeSpan(l.Range)
 }
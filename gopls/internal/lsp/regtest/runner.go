@@ -177,6 +177,22 @@ func WindowsLineEndings() RunOption {
 	})
 }
 
+// HonorsChangeAnnotations configures the editor to honor change annotations
+// in rename edits, applying optional edits as though confirmed by the user.
+func HonorsChangeAnnotations() RunOption {
+	return optionSetter(func(opts *runConfig) {
+		opts.editor.HonorsChangeAnnotations = true
+	})
+}
+
+// NoFileRenames configures the editor not to support the renaming of files
+// in workspace edits.
+func NoFileRenames() RunOption {
+	return optionSetter(func(opts *runConfig) {
+		opts.editor.NoFileRenames = true
+	})
+}
+
 // Settings is a RunOption that sets user-provided configuration for the LSP
 // server.
 //
@@ -6,8 +6,14 @@ package lsp
 
 import (
 	"context"
+	"fmt"
+	"io/ioutil"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
+	"golang.org/x/tools/gopls/internal/lsp/command"
 	"golang.org/x/tools/gopls/internal/lsp/protocol"
 	"golang.org/x/tools/gopls/internal/lsp/source"
 	"golang.org/x/tools/gopls/internal/span"
@@ -19,16 +25,174 @@ func (s *Server) rename(ctx context.Context, params *protocol.RenameParams) (*pr
 	if !ok {
 		return nil, err
 	}
+	result, err := s.renameEdit(ctx, snapshot, fh, params, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return result.edit, nil
+}
+
+// A renameEditResult is the workspace edit of a rename computed by
+// renameEdit, with what its callers report about it.
+type renameEditResult struct {
+	edit      *protocol.WorkspaceEdit
+	isPkg     bool
+	edits     map[span.URI][]protocol.TextEdit // the text edits of edit
+	remaining []protocol.Location              // the occurrences left unchanged in files not open
+	external  []source.ExternalReferenceCount  // the references outside the edit scope
+}
+
+// renameEdit returns the workspace edit of the rename of params, which is
+// that of a package in the given mode, if set, or else in its default mode.
+//
+// If selected is nil, the edit is returned to the client, which applies
+// the optional edits according to their change annotations if it supports
+// them, and the rename is recorded as computed. Otherwise the edit is one
+// for the server to apply, as gopls.commit_rename does: its optional edits
+// are those whose annotations selected reports, stripped of them, and the
+// caller records the rename once applied.
+//
+// The edit is returned whole, not streamed in chunks as partial results:
+// textDocument/rename defines none, and the edits of the files all exist
+// only once source.Rename has checked the whole rename for conflicts, so
+// that chunks could not reach the client any sooner.
+func (s *Server) renameEdit(ctx context.Context, snapshot source.Snapshot, fh source.VersionedFileHandle, params *protocol.RenameParams, mode source.PackageRenameMode, selected func(protocol.ChangeAnnotationIdentifier) bool) (*renameEditResult, error) {
 	// Because we don't handle directory renaming within source.Rename, source.Rename returns
 	// boolean value isPkgRenaming to determine whether an DocumentChanges of type RenameFile should
 	// be added to the return protocol.WorkspaceEdit value.
-	edits, optionalEdits, isPkgRenaming, err := source.Rename(ctx, snapshot, fh, params.Position, params.NewName)
+	var (
+		edits         map[span.URI][]protocol.TextEdit
+		optionalEdits *source.OptionalEdits
+		isPkgRenaming bool
+		err           error
+	)
+	if mode != "" {
+		isPkgRenaming = true
+		edits, optionalEdits, err = source.RenamePackage(ctx, snapshot, fh, params.NewName, mode)
+	} else if selected != nil {
+		edits, optionalEdits, isPkgRenaming, err = source.RenameAnnotated(ctx, snapshot, fh, params.Position, params.NewName)
+	} else {
+		edits, optionalEdits, isPkgRenaming, err = source.Rename(ctx, snapshot, fh, params.Position, params.NewName)
+	}
 	if err != nil {
 		return nil, err
 	}
+	if isPkgRenaming && mode == "" {
+		modes, err := source.PackageRenameModes(ctx, snapshot, fh)
+		if err != nil {
+			return nil, err
+		}
+		mode = modes[0]
+	}
+	if err := checkEditedFiles(ctx, snapshot, edits); err != nil {
+		return nil, err
+	}
+	if err := checkDiskFiles(ctx, snapshot, edits); err != nil {
+		return nil, err
+	}
+	others, releaseOthers := s.otherSnapshots(ctx, snapshot)
+	defer releaseOthers()
+	// Only the directory renaming of a package moves its directory and
+	// changes its import path.
+	movesPkgDir := mode == source.DirectoryRename
+	var external []source.ExternalReferenceCount
+	if isPkgRenaming {
+		if movesPkgDir {
+			if optionalEdits, err = source.RenameVendoredPackage(ctx, snapshot, fh, params.NewName, others, optionalEdits); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		if external, err = source.ExternalReferences(ctx, snapshot, fh, params.Position, others); err != nil {
+			return nil, err
+		}
+		if err := source.CheckExternalReferences(snapshot, external); err != nil {
+			return nil, err
+		}
+		var vendored []span.URI
+		optionalEdits, vendored, err = source.RenameVendoredCopies(ctx, snapshot, fh, params.Position, params.NewName, others, optionalEdits)
+		if err != nil {
+			return nil, err
+		}
+		if len(vendored) > 0 && selected == nil && !snapshot.View().Options().SupportChangeAnnotations {
+			var files []string
+			for _, uri := range vendored {
+				files = append(files, uri.Filename())
+			}
+			return nil, fmt.Errorf("renaming would leave inconsistent the copies vendored in other workspace folders, in %s: update them with a client supporting change annotations", strings.Join(files, ", "))
+		}
+	}
 
-	var docChanges []protocol.DocumentChanges
+	if optionalEdits != nil && len(optionalEdits.Warnings) > 0 {
+		if err := s.client.ShowMessage(ctx, &protocol.ShowMessageParams{
+			Type:    protocol.Warning,
+			Message: fmt.Sprintf("Renaming to %s: %s.", params.NewName, strings.Join(optionalEdits.Warnings, "; ")),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	// Optional edits are merged with the others, as all edits of a
+	// document must refer to the same version of it.
 	var annotations map[protocol.ChangeAnnotationIdentifier]protocol.ChangeAnnotation
+	var fileRenames []protocol.RenameFile
+	if optionalEdits != nil && edits == nil {
+		edits = make(map[span.URI][]protocol.TextEdit)
+	}
+	switch {
+	case optionalEdits == nil:
+	case selected != nil:
+		for uri, e := range optionalEdits.Edits {
+			for _, te := range e {
+				if selected(te.AnnotationID) {
+					te.AnnotationID = ""
+					edits[uri] = append(edits[uri], te)
+				}
+			}
+		}
+		for _, fr := range optionalEdits.FileRenames {
+			if selected(fr.AnnotationID) {
+				fr.AnnotationID = ""
+				fileRenames = append(fileRenames, fr)
+			}
+		}
+	case snapshot.View().Options().ClientOptions.SupportChangeAnnotations:
+		for uri, e := range optionalEdits.Edits {
+			edits[uri] = append(edits[uri], e...)
+		}
+		fileRenames = optionalEdits.FileRenames
+		annotations = optionalEdits.Annotations
+	}
+	var remaining []protocol.Location
+	if snapshot.View().Options().RenameOpenFilesOnly {
+		if edits, remaining, err = openFileEdits(ctx, snapshot, edits); err != nil {
+			return nil, err
+		}
+		if movesPkgDir {
+			if err := checkDirMoveEdits(params.NewName, remaining); err != nil {
+				return nil, err
+			}
+		}
+		if len(remaining) > 0 {
+			if err := s.client.ShowMessage(ctx, &protocol.ShowMessageParams{
+				Type:    protocol.Info,
+				Message: fmt.Sprintf("Renaming to %s: %d occurrences in files not open in the editor are left unchanged; gopls.rename_remainder lists them.", params.NewName, len(remaining)),
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if movesPkgDir {
+		oldDir := filepath.Dir(fh.URI().Filename())
+		newDir := filepath.Join(filepath.Dir(oldDir), params.NewName)
+		if edits, err = addReplaceDirectiveEdits(ctx, snapshot, others, oldDir, newDir, edits); err != nil {
+			return nil, err
+		}
+	}
+	// Edits gathered from several snapshots may name a file by URIs
+	// differing in case.
+	source.CanonicalizeURIs(snapshot, edits)
+	var docChanges []protocol.DocumentChanges
 	for uri, e := range edits {
 		fh, err := snapshot.GetVersionedFile(ctx, uri)
 		if err != nil {
@@ -36,34 +200,290 @@ func (s *Server) rename(ctx context.Context, params *protocol.RenameParams) (*pr
 		}
 		docChanges = append(docChanges, documentChanges(fh, e)...)
 	}
-	if optionalEdits != nil && snapshot.View().Options().ClientOptions.SupportChangeAnnotations {
-		for uri, e := range optionalEdits.Edits {
-			fh, err := snapshot.GetVersionedFile(ctx, uri)
-			if err != nil {
-				return nil, err
-			}
-			docChanges = append(docChanges, documentChanges(fh, e)...)
+	if supportsFileRenames(snapshot) {
+		for i := range fileRenames {
+			docChanges = append(docChanges, protocol.DocumentChanges{RenameFile: &fileRenames[i]})
 		}
-		annotations = optionalEdits.Annotations
 	}
-	if isPkgRenaming {
-		uri := params.TextDocument.URI.SpanURI()
-		oldBase := filepath.Dir(span.URI.Filename(uri))
-		newURI := filepath.Join(filepath.Dir(oldBase), params.NewName)
-		docChanges = append(docChanges, protocol.DocumentChanges{
-			RenameFile: &protocol.RenameFile{
-				Kind:   "rename",
-				OldURI: protocol.URIFromPath(oldBase),
-				NewURI: protocol.URIFromPath(newURI),
-			},
-		})
-	}
-	return &protocol.WorkspaceEdit{
-		DocumentChanges:   docChanges,
-		ChangeAnnotations: annotations,
+	if selected == nil {
+		s.recordComputedRename(ctx, snapshot, fh, params, isPkgRenaming, edits, remaining, false)
+	}
+	if movesPkgDir {
+		dirChanges, err := packageDirRename(snapshot, params.TextDocument.URI.SpanURI(), params.NewName)
+		if err != nil {
+			return nil, err
+		}
+		docChanges = append(docChanges, dirChanges...)
+	}
+	return &renameEditResult{
+		edit: &protocol.WorkspaceEdit{
+			DocumentChanges:   docChanges,
+			ChangeAnnotations: annotations,
+		},
+		isPkg:     isPkgRenaming,
+		edits:     edits,
+		remaining: remaining,
+		external:  external,
 	}, nil
 }
 
+// otherSnapshots returns the snapshots of the views of the session other
+// than the view of snapshot, and a function releasing them.
+func (s *Server) otherSnapshots(ctx context.Context, snapshot source.Snapshot) ([]source.Snapshot, func()) {
+	var (
+		others   []source.Snapshot
+		releases []func()
+	)
+	for _, view := range s.session.Views() {
+		if view == snapshot.View() {
+			continue
+		}
+		other, release := view.Snapshot(ctx)
+		others = append(others, other)
+		releases = append(releases, release)
+	}
+	return others, func() {
+		for _, release := range releases {
+			release()
+		}
+	}
+}
+
+// addReplaceDirectiveEdits adds to edits, allocated if nil, the edits
+// updating the replace directives of the go.mod and go.work files of
+// snapshot and others that the move of oldDir to newDir invalidates.
+func addReplaceDirectiveEdits(ctx context.Context, snapshot source.Snapshot, others []source.Snapshot, oldDir, newDir string, edits map[span.URI][]protocol.TextEdit) (map[span.URI][]protocol.TextEdit, error) {
+	seen := make(map[span.URI]bool)
+	for _, snap := range append([]source.Snapshot{snapshot}, others...) {
+		replaceEdits, err := source.ReplaceDirectiveEdits(ctx, snap, oldDir, newDir)
+		if err != nil {
+			return nil, err
+		}
+		for uri, e := range replaceEdits {
+			if seen[uri] {
+				continue // a go.work file shared by several views
+			}
+			seen[uri] = true
+			if edits == nil {
+				edits = make(map[span.URI][]protocol.TextEdit)
+			}
+			edits[uri] = append(edits[uri], e...)
+		}
+	}
+	return edits, nil
+}
+
+// checkEditedFiles returns an error wrapping source.ErrWorkspaceChanged if
+// a file of edits, computed in snapshot, changed in the latest snapshot of
+// its view while they were computed.
+func checkEditedFiles(ctx context.Context, snapshot source.Snapshot, edits map[span.URI][]protocol.TextEdit) error {
+	current, release := snapshot.View().Snapshot(ctx)
+	defer release()
+	if current.ID() == snapshot.ID() {
+		return nil
+	}
+	var changed []string
+	for uri := range edits {
+		before, err := snapshot.GetFile(ctx, uri)
+		if err != nil {
+			return err
+		}
+		after, err := current.GetFile(ctx, uri)
+		if err != nil {
+			return err
+		}
+		if before.FileIdentity() != after.FileIdentity() {
+			changed = append(changed, filepath.Base(uri.Filename()))
+		}
+	}
+	if len(changed) > 0 {
+		sort.Strings(changed)
+		return fmt.Errorf("%w: %s changed", source.ErrWorkspaceChanged, strings.Join(changed, ", "))
+	}
+	return nil
+}
+
+// openFileEdits returns the edits of the files of edits that are open in
+// the editor, and the sorted locations of the edits of the other files,
+// which renames restricted to open files leave unchanged.
+func openFileEdits(ctx context.Context, snapshot source.Snapshot, edits map[span.URI][]protocol.TextEdit) (map[span.URI][]protocol.TextEdit, []protocol.Location, error) {
+	open := make(map[span.URI][]protocol.TextEdit)
+	var remaining []protocol.Location
+	for uri, e := range edits {
+		fh, err := snapshot.GetVersionedFile(ctx, uri)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, ok := fh.(source.Overlay); ok {
+			open[uri] = e
+			continue
+		}
+		for _, te := range e {
+			remaining = append(remaining, protocol.Location{URI: protocol.URIFromSpanURI(uri), Range: te.Range})
+		}
+	}
+	sort.Slice(remaining, func(i, j int) bool {
+		if remaining[i].URI != remaining[j].URI {
+			return remaining[i].URI < remaining[j].URI
+		}
+		return protocol.CompareRange(remaining[i].Range, remaining[j].Range) < 0
+	})
+	return open, remaining, nil
+}
+
+// checkDirMoveEdits returns an error if the rename to newName of a package
+// that moves its directory leaves edits unchanged in the files that are not
+// open in the editor, as their imports of the package would break.
+func checkDirMoveEdits(newName string, remaining []protocol.Location) error {
+	if len(remaining) == 0 {
+		return nil
+	}
+	var files []string
+	seen := make(map[protocol.DocumentURI]bool)
+	for _, loc := range remaining {
+		if !seen[loc.URI] {
+			seen[loc.URI] = true
+			files = append(files, filepath.Base(loc.URI.SpanURI().Filename()))
+		}
+	}
+	return fmt.Errorf("renaming the package to %s moves its directory, which would break the files not open in the editor that refer to it: %s; open them, or disable renameOpenFilesOnly", newName, strings.Join(files, ", "))
+}
+
+// checkDiskFiles returns an error wrapping source.ErrFileChangedOnDisk,
+// listing the files, if files of edits that are not open in the editor
+// no longer have on disk the content they were analyzed with in snapshot,
+// as when they are modified by another program without the client
+// notifying the change.
+func checkDiskFiles(ctx context.Context, snapshot source.Snapshot, edits map[span.URI][]protocol.TextEdit) error {
+	var changed []string
+	for uri := range edits {
+		fh, err := snapshot.GetVersionedFile(ctx, uri)
+		if err != nil {
+			return err
+		}
+		if _, ok := fh.(source.Overlay); ok {
+			continue
+		}
+		data, err := ioutil.ReadFile(uri.Filename())
+		if err != nil || source.HashOf(data) != fh.FileIdentity().Hash {
+			changed = append(changed, uri.Filename())
+		}
+	}
+	if len(changed) > 0 {
+		sort.Strings(changed)
+		return fmt.Errorf("%w: %s", source.ErrFileChangedOnDisk, strings.Join(changed, ", "))
+	}
+	return nil
+}
+
+// packageDirRename returns the renaming of the directory of the package
+// containing the file uri that accompanies the renaming of the package to
+// newName; see source.PackageDirRenames.
+func packageDirRename(snapshot source.Snapshot, uri span.URI, newName string) ([]protocol.DocumentChanges, error) {
+	oldBase := filepath.Dir(span.URI.Filename(uri))
+	renames, err := source.PackageDirRenames(snapshot, oldBase, filepath.Join(filepath.Dir(oldBase), newName))
+	if err != nil {
+		return nil, err
+	}
+	var changes []protocol.DocumentChanges
+	for i := range renames {
+		changes = append(changes, protocol.DocumentChanges{RenameFile: &renames[i]})
+	}
+	return changes, nil
+}
+
+// A renameSession holds a rename begun by the gopls.begin_rename command,
+// until it is committed.
+type renameSession struct {
+	params     protocol.RenameParams
+	snapshotID uint64 // the ID of the snapshot of the preview
+}
+
+// maxRenameSessions is the number of uncommitted rename sessions kept by
+// the server, beyond which the oldest are discarded.
+const maxRenameSessions = 10
+
+// addRenameSession records the rename session rs and returns its token.
+func (s *Server) addRenameSession(rs *renameSession) string {
+	s.renameSessionsMu.Lock()
+	defer s.renameSessionsMu.Unlock()
+	s.lastRenameSession++
+	token := fmt.Sprintf("rename-%d", s.lastRenameSession)
+	if s.renameSessions == nil {
+		s.renameSessions = make(map[string]*renameSession)
+	}
+	s.renameSessions[token] = rs
+	s.renameSessionTokens = append(s.renameSessionTokens, token)
+	for len(s.renameSessionTokens) > maxRenameSessions {
+		delete(s.renameSessions, s.renameSessionTokens[0])
+		s.renameSessionTokens = s.renameSessionTokens[1:]
+	}
+	return token
+}
+
+// takeRenameSession removes the rename session of the given token and
+// returns it, or nil if there is none.
+func (s *Server) takeRenameSession(token string) *renameSession {
+	s.renameSessionsMu.Lock()
+	defer s.renameSessionsMu.Unlock()
+	rs := s.renameSessions[token]
+	delete(s.renameSessions, token)
+	for i, t := range s.renameSessionTokens {
+		if t == token {
+			s.renameSessionTokens = append(s.renameSessionTokens[:i], s.renameSessionTokens[i+1:]...)
+			break
+		}
+	}
+	return rs
+}
+
+// maxRenameHistory is the number of renames recorded by the server.
+const maxRenameHistory = 100
+
+// recordComputedRename records the rename of params, whose edits of the
+// given files were just computed, in the rename history of the server.
+// The server doesn't learn whether the client applies the edits it
+// returns, so applied is set only by the callers that applied them.
+func (s *Server) recordComputedRename(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle, params *protocol.RenameParams, isPkg bool, edits map[span.URI][]protocol.TextEdit, remaining []protocol.Location, applied bool) {
+	record := command.RenameRecord{
+		Location: protocol.Location{
+			URI:   params.TextDocument.URI,
+			Range: protocol.Range{Start: params.Position, End: params.Position},
+		},
+		NewName:   params.NewName,
+		Package:   isPkg,
+		Remaining: remaining,
+		Applied:   applied,
+		Time:      time.Now().Format(time.RFC3339),
+	}
+	if item, _, err := source.PrepareRename(ctx, snapshot, fh, params.Position); err == nil {
+		record.Location.Range = item.Range
+		record.OldName = item.Text
+	}
+	for uri := range edits {
+		record.Files = append(record.Files, protocol.URIFromSpanURI(uri))
+	}
+	sort.Slice(record.Files, func(i, j int) bool { return record.Files[i] < record.Files[j] })
+
+	s.renameHistoryMu.Lock()
+	defer s.renameHistoryMu.Unlock()
+	s.renameHistory = append(s.renameHistory, record)
+	if n := len(s.renameHistory); n > maxRenameHistory {
+		s.renameHistory = append([]command.RenameRecord(nil), s.renameHistory[n-maxRenameHistory:]...)
+	}
+}
+
+// supportsFileRenames reports whether the client can rename files as part
+// of a workspace edit.
+func supportsFileRenames(snapshot source.Snapshot) bool {
+	for _, op := range snapshot.View().Options().SupportedResourceOperations {
+		if op == protocol.Rename {
+			return true
+		}
+	}
+	return false
+}
+
 // prepareRename implements the textDocument/prepareRename handler. It may
 // return (nil, nil) if there is no rename at the cursor position, but it is
 // not desirable to display an error to the user.
@@ -89,3 +509,55 @@ func (s *Server) prepareRename(ctx context.Context, params *protocol.PrepareRena
 		Placeholder: item.Text,
 	}, nil
 }
+
+// willRenameFiles implements the workspace/willRenameFiles handler. It
+// returns the edits that keep packages consistent with the renaming of files
+// and directories by the client, such as updated import paths and package
+// clauses. The edits apply to the files at their old locations.
+func (s *Server) willRenameFiles(ctx context.Context, params *protocol.RenameFilesParams) (*protocol.WorkspaceEdit, error) {
+	var docChanges []protocol.DocumentChanges
+	for _, file := range params.Files {
+		oldURI := span.URIFromURI(file.OldURI)
+		newURI := span.URIFromURI(file.NewURI)
+		if !oldURI.IsFile() || !newURI.IsFile() {
+			continue
+		}
+		changes, err := s.fileRenameChanges(ctx, oldURI, newURI)
+		if err != nil {
+			return nil, err
+		}
+		docChanges = append(docChanges, changes...)
+	}
+	return &protocol.WorkspaceEdit{DocumentChanges: docChanges}, nil
+}
+
+// fileRenameChanges returns the document changes accompanying the renaming
+// of the file or directory oldURI to newURI.
+func (s *Server) fileRenameChanges(ctx context.Context, oldURI, newURI span.URI) ([]protocol.DocumentChanges, error) {
+	view, err := s.session.ViewOf(oldURI)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, release := view.Snapshot(ctx)
+	defer release()
+
+	edits, err := source.RenameFiles(ctx, snapshot, oldURI, newURI)
+	if err != nil {
+		return nil, err
+	}
+	others, releaseOthers := s.otherSnapshots(ctx, snapshot)
+	defer releaseOthers()
+	if edits, err = addReplaceDirectiveEdits(ctx, snapshot, others, oldURI.Filename(), newURI.Filename(), edits); err != nil {
+		return nil, err
+	}
+	source.CanonicalizeURIs(snapshot, edits)
+	var docChanges []protocol.DocumentChanges
+	for uri, e := range edits {
+		fh, err := snapshot.GetVersionedFile(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		docChanges = append(docChanges, documentChanges(fh, e)...)
+	}
+	return docChanges, nil
+}
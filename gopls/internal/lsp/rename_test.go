@@ -0,0 +1,80 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/lsp/cache"
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestCheckEditedFiles(t *testing.T) {
+	testenv.NeedsGoBuild(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod": "module mod.com\n\ngo 1.18\n",
+		"a.go":   "package a\n\nfunc Hello() {}\n",
+		"b.go":   "package a\n\nfunc _() { Hello() }\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	session := cache.New(nil, nil, nil).NewSession(ctx)
+	options := source.DefaultOptions().Clone()
+	options.SetEnvSlice(os.Environ())
+	view, prepared, release, err := session.NewView(ctx, "a", span.URIFromPath(dir), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer view.Shutdown(ctx)
+	defer release()
+
+	a := span.URIFromPath(filepath.Join(dir, "a.go"))
+	b := span.URIFromPath(filepath.Join(dir, "b.go"))
+	edits := func(uris ...span.URI) map[span.URI][]protocol.TextEdit {
+		m := make(map[span.URI][]protocol.TextEdit)
+		for _, uri := range uris {
+			m[uri] = []protocol.TextEdit{{NewText: "Greet"}}
+		}
+		return m
+	}
+	if err := checkEditedFiles(ctx, prepared, edits(a, b)); err != nil {
+		t.Fatalf("unchanged workspace: %v", err)
+	}
+
+	// a.go is edited after the snapshot of the rename was taken.
+	if err := session.ModifyFiles(ctx, []source.FileModification{{
+		URI:        a,
+		Action:     source.Open,
+		Version:    1,
+		Text:       []byte("package a\n\nvar _ = 1\n\nfunc Hello() {}\n"),
+		LanguageID: "go",
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	err = checkEditedFiles(ctx, prepared, edits(a, b))
+	if !errors.Is(err, source.ErrWorkspaceChanged) {
+		t.Fatalf("edited a.go: got error %v, want %v", err, source.ErrWorkspaceChanged)
+	}
+	if !strings.Contains(err.Error(), "a.go changed") || strings.Contains(err.Error(), "b.go") {
+		t.Errorf("edited a.go: got error %q, want it to name a.go only", err)
+	}
+	// The edits of the files that didn't change still apply.
+	if err := checkEditedFiles(ctx, prepared, edits(b)); err != nil {
+		t.Errorf("edits of unchanged b.go: %v", err)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"sync"
 
 	"golang.org/x/tools/gopls/internal/lsp/cache"
+	"golang.org/x/tools/gopls/internal/lsp/command"
 	"golang.org/x/tools/gopls/internal/lsp/progress"
 	"golang.org/x/tools/gopls/internal/lsp/protocol"
 	"golang.org/x/tools/gopls/internal/lsp/source"
@@ -117,6 +118,20 @@ type Server struct {
 	// report with an error message.
 	criticalErrorStatusMu sync.Mutex
 	criticalErrorStatus   *progress.WorkDone
+
+	// renameHistory is the journal of the renames computed for the client,
+	// whether or not it applied them, oldest first, of at most
+	// maxRenameHistory records.
+	renameHistoryMu sync.Mutex
+	renameHistory   []command.RenameRecord
+
+	// renameSessions holds the rename sessions begun by the client and not
+	// yet committed, by token; renameSessionTokens lists their tokens,
+	// oldest first.
+	renameSessionsMu    sync.Mutex
+	renameSessions      map[string]*renameSession
+	renameSessionTokens []string
+	lastRenameSession   int
 }
 
 type pendingModificationSet struct {
@@ -300,8 +300,8 @@ func (s *Server) WillDeleteFiles(context.Context, *protocol.DeleteFilesParams) (
 	return nil, notImplemented("WillDeleteFiles")
 }
 
-func (s *Server) WillRenameFiles(context.Context, *protocol.RenameFilesParams) (*protocol.WorkspaceEdit, error) {
-	return nil, notImplemented("WillRenameFiles")
+func (s *Server) WillRenameFiles(ctx context.Context, params *protocol.RenameFilesParams) (*protocol.WorkspaceEdit, error) {
+	return s.willRenameFiles(ctx, params)
 }
 
 func (s *Server) WillSave(context.Context, *protocol.WillSaveTextDocumentParams) error {
@@ -218,6 +218,324 @@ var GeneratedAPIJSON = &APIJSON{
 				Status:    "advanced",
 				Hierarchy: "ui.navigation",
 			},
+			{
+				Name:      "renameIncludeImplementations",
+				Type:      "bool",
+				Doc:       "renameIncludeImplementations offers the renaming of the methods\ncoupled by interfaces to a renamed method: the implementations of a\nrenamed interface method, and the interface methods and sibling\nimplementations of a renamed concrete method.\n",
+				Default:   "true",
+				Status:    "experimental",
+				Hierarchy: "ui.navigation.rename",
+			},
+			{
+				Name: "renameImplementationsScope",
+				Type: "enum",
+				Doc:  "renameImplementationsScope limits the implementations offered for\nrenaming along with a method.\n",
+				EnumValues: []EnumValue{
+					{
+						Value: "\"package\"",
+						Doc:   "`\"package\"` offers only the implementations declared in\nthe package of the renamed method.\n",
+					},
+					{
+						Value: "\"workspace\"",
+						Doc:   "`\"workspace\"` offers the implementations declared in the\nworkspace modules.\n",
+					},
+				},
+				Default:   "\"workspace\"",
+				Status:    "experimental",
+				Hierarchy: "ui.navigation.rename",
+			},
+			{
+				Name:      "renameInComments",
+				Type:      "bool",
+				Doc:       "renameInComments updates the comments mentioning a renamed object,\nsuch as its doc comment and the doc links to it.\n",
+				Default:   "true",
+				Status:    "experimental",
+				Hierarchy: "ui.navigation.rename",
+			},
+			{
+				Name: "renameCommentScope",
+				Type: "enum",
+				Doc:  "renameCommentScope controls which parts of the comments mentioning\na renamed object are updated.\n",
+				EnumValues: []EnumValue{
+					{
+						Value: "\"all\"",
+						Doc:   "`\"all\"` updates every mention within comments.\n",
+					},
+					{
+						Value: "\"prose\"",
+						Doc:   "`\"prose\"` updates the prose of comments only, leaving unchanged\nthe mentions within code blocks, URLs, quoted text and directives,\nsuch as the examples embedded in doc comments.\n",
+					},
+					{
+						Value: "\"semantic\"",
+						Doc:   "`\"semantic\"` updates only the mentions that resolve to the\nrenamed object: doc links, qualified mentions such as pkg.Foo or\nT.Foo, and the leading name of its doc comment, for names that are\nalso common words, such as New, Get or Run.\n",
+					},
+				},
+				Default:   "\"prose\"",
+				Status:    "experimental",
+				Hierarchy: "ui.navigation.rename",
+			},
+			{
+				Name:      "renameInStrings",
+				Type:      "bool",
+				Doc:       "renameInStrings offers the updating of the string literals that name\na renamed object, such as reflective lookups, registrations by name,\nthe messages of the errors of its package and the keys of its\nstructured logging calls.\n",
+				Default:   "true",
+				Status:    "experimental",
+				Hierarchy: "ui.navigation.rename",
+			},
+			{
+				Name: "renameMatching",
+				Type: "map[string]bool",
+				Doc:  "renameMatching specifies the forms in which the updating of the\ncomments and string literals mentioning a renamed object, such as\nits doc comment and the error messages of its package, matches its\nold name.\n\nExample Usage:\n\n```json5\n\"gopls\": {\n...\n  \"renameMatching\": {\n    \"qualified\": false,\n    \"derived\": true,\n  }\n...\n}\n```\n",
+				EnumKeys: EnumKeys{
+					ValueType: "bool",
+					Keys: []EnumKey{
+						{
+							Name:    "\"caseInsensitive\"",
+							Doc:     "`\"caseInsensitive\"` matches the old name spelled in another case,\nsuch as foo or FOO for Foo, replaced by the new name in that case.\n",
+							Default: "false",
+						},
+						{
+							Name:    "\"derived\"",
+							Doc:     "`\"derived\"` matches the names derived from the old name, such as\nfoo_bar, foo-bar, fooBar and FOO_BAR for FooBar, replaced by the\nsame forms of the new name.\n",
+							Default: "false",
+						},
+						{
+							Name:    "\"exact\"",
+							Doc:     "`\"exact\"` matches the old name spelled exactly, as a whole word.\n",
+							Default: "true",
+						},
+						{
+							Name:    "\"qualified\"",
+							Doc:     "`\"qualified\"` matches the old name qualified by the name of a\npackage or type, as in pkg.Foo or T.Foo.\n",
+							Default: "true",
+						},
+					},
+				},
+				Default:   "{\"caseInsensitive\":false,\"derived\":false,\"exact\":true,\"qualified\":true}",
+				Status:    "experimental",
+				Hierarchy: "ui.navigation.rename",
+			},
+			{
+				Name: "renameGeneratedFilePolicy",
+				Type: "enum",
+				Doc:  "renameGeneratedFilePolicy controls the renaming of references in\ngenerated files, which regenerating them may revert. The platform\nwrappers, such as zsyscall_*.go and zz_generated.*.go files, are\nrenamed in any case, as their packages need them updated.\n",
+				EnumValues: []EnumValue{
+					{
+						Value: "\"annotate\"",
+						Doc:   "`\"annotate\"` offers the edits of generated files apart from the\nothers, for the user to confirm.\n",
+					},
+					{
+						Value: "\"edit\"",
+						Doc:   "`\"edit\"` edits generated files like the others.\n",
+					},
+					{
+						Value: "\"skip\"",
+						Doc:   "`\"skip\"` leaves generated files unchanged, with a warning.\n",
+					},
+				},
+				Default:   "\"annotate\"",
+				Status:    "experimental",
+				Hierarchy: "ui.navigation.rename",
+			},
+			{
+				Name:      "renameExcludePaths",
+				Type:      "[]string",
+				Doc:       "renameExcludePaths lists the files that renames never edit. Each\npattern is matched, as by path.Match, against the slash-separated\npaths relative to the workspace folder of the edited files and of\ntheir parent directories. A rename that must edit an excluded file\nfails; the optional edits of excluded files are dropped.\n\nExample Usage:\n\n```json5\n\"gopls\": {\n...\n  \"renameExcludePaths\": [\"third_party\", \"api/*.pb.go\"]\n...\n}\n```\n",
+				Default:   "[]",
+				Status:    "experimental",
+				Hierarchy: "ui.navigation.rename",
+			},
+			{
+				Name:      "renameSkipTestFiles",
+				Type:      "bool",
+				Doc:       "renameSkipTestFiles leaves the _test.go files unchanged by renames,\nreporting the test files that the rename would have edited, for\nrefactorings of production code whose tests are updated separately.\n",
+				Default:   "false",
+				Status:    "experimental",
+				Hierarchy: "ui.navigation.rename",
+			},
+			{
+				Name:      "renameOpenFilesOnly",
+				Type:      "bool",
+				Doc:       "renameOpenFilesOnly restricts the edits of renames to the files open\nin the editor, for huge workspaces where changing thousands of files\nin one edit is undesirable. The occurrences left unchanged in the\nother files are listed by the gopls.rename_remainder command. A\nrename of a package that moves its directory fails instead if it\nwould leave files unchanged, as their imports would break.\n",
+				Default:   "false",
+				Status:    "experimental",
+				Hierarchy: "ui.navigation.rename",
+			},
+			{
+				Name:      "renameMaxPackages",
+				Type:      "int",
+				Doc:       "renameMaxPackages is the number of packages that the analysis of a\nrename may type check, beyond which the rename fails with a\ndescription of its scale rather than exhaust the memory of the\nmachine. The limit applies to each set of packages that the\nanalysis loads: the packages of the renamed object or package, the\nreverse dependencies of the packages it searches and all the known\npackages, of its view to find the methods coupled to a renamed\nmethod, such as its implementations, and of the other views to find\nthe references to an exported object. Zero means no limit.\n",
+				Default:   "0",
+				Status:    "experimental",
+				Hierarchy: "ui.navigation.rename",
+			},
+			{
+				Name:      "renameTextOccurrences",
+				Type:      "bool",
+				Doc:       "renameTextOccurrences enables the search of non-Go files of the\nworkspace, such as Markdown documents, YAML configuration and shell\nscripts, for the qualified name or import path affected by a rename.\nOccurrences in documents and scripts are offered as optional edits,\nwhich the client must confirm.\n",
+				Default:   "false",
+				Status:    "experimental",
+				Hierarchy: "ui.navigation.rename",
+			},
+			{
+				Name:      "renameNameSensitiveCalls",
+				Type:      "[]string",
+				Doc:       "renameNameSensitiveCalls lists functions and methods whose string\narguments name Go objects that they look up dynamically, for example\nthrough reflection. Renaming an object passed by name to one of their\ncalls warns of the call, which would otherwise break only at run time.\n\nEach element is of the form `path.Func` or `path.Type.Method`.\n\nExample Usage:\n\n```json5\n\"gopls\": {\n...\n  \"renameNameSensitiveCalls\": [\"github.com/example/di.Container.Invoke\"]\n...\n}\n```\n",
+				Default:   "[]",
+				Status:    "experimental",
+				Hierarchy: "ui.navigation.rename",
+			},
+			{
+				Name: "renameConfirmations",
+				Type: "map[string]bool",
+				Doc:  "renameConfirmations specifies which groups of the optional edits of\na rename need the user's confirmation. Clients that honor change\nannotations apply the edits of the other groups without asking.\n\nExample Usage:\n\n```json5\n\"gopls\": {\n...\n  \"renameConfirmations\": {\n    \"implementations\": true,\n    \"accessors\": false,\n  }\n...\n}\n```\n",
+				EnumKeys: EnumKeys{
+					ValueType: "bool",
+					Keys: []EnumKey{
+						{
+							Name:    "\"accessors\"",
+							Doc:     "`\"accessors\"` controls the renaming of the accessors of a renamed\nfield or property, including the methods of builder types.\n",
+							Default: "true",
+						},
+						{
+							Name:    "\"aliases\"",
+							Doc:     "`\"aliases\"` controls the local names given to the imports of\na renamed package in the files where its new name is taken.\n",
+							Default: "false",
+						},
+						{
+							Name:    "\"almost\"",
+							Doc:     "`\"almost\"` controls the renaming of the methods\nthat have the name and signature of a renamed interface method but\nwhose types do not implement the interface.\n",
+							Default: "true",
+						},
+						{
+							Name:    "\"assertions\"",
+							Doc:     "`\"assertions\"` controls the renaming of the variables named after\na renamed interface that assert that types implement it, and the\nupdating of such assertions in generated files.\n",
+							Default: "true",
+						},
+						{
+							Name:    "\"comments\"",
+							Doc:     "`\"comments\"` controls the updating of comments, such as the doc\ncomments of renamed objects and the doc links to them.\n",
+							Default: "false",
+						},
+						{
+							Name:    "\"deprecations\"",
+							Doc:     "`\"deprecations\"` controls the updating of the deprecation notices\nthat refer to a renamed object by its bare name, such as\n\"Deprecated: use Foo instead.\".\n",
+							Default: "false",
+						},
+						{
+							Name:    "\"files\"",
+							Doc:     "`\"files\"` controls the renaming of the files named after a renamed\nobject.\n",
+							Default: "true",
+						},
+						{
+							Name:    "\"flags\"",
+							Doc:     "`\"flags\"` controls the renaming of the command-line flags and\nenvironment variables named after a renamed variable, which breaks\nthe command lines and environments that set them.\n",
+							Default: "true",
+						},
+						{
+							Name:    "\"generated\"",
+							Doc:     "`\"generated\"` controls the renaming of references within generated\nfiles, which their generator may overwrite.\n",
+							Default: "true",
+						},
+						{
+							Name:    "\"implementations\"",
+							Doc:     "`\"implementations\"` controls the renaming of the implementations\nof a renamed interface method.\n",
+							Default: "true",
+						},
+						{
+							Name:    "\"regenerate\"",
+							Doc:     "`\"regenerate\"` controls the renaming of references within platform\nwrappers, generated files such as zsyscall_*.go and\nzz_generated.*.go that the build needs updated, which are renamed\nwhatever the generated file policy, with a recommendation to\nregenerate them.\n",
+							Default: "false",
+						},
+						{
+							Name:    "\"siblings\"",
+							Doc:     "`\"siblings\"` controls the renaming of the methods coupled to a\nrenamed concrete method by the interfaces it implements: those of\nthe interfaces, and those of their other implementations.\n",
+							Default: "true",
+						},
+						{
+							Name:    "\"strings\"",
+							Doc:     "`\"strings\"` controls the updating of names in string literals,\nsuch as reflective lookups, registrations by name, error messages\nand logging keys.\n",
+							Default: "true",
+						},
+						{
+							Name:    "\"subtests\"",
+							Doc:     "`\"subtests\"` controls the renaming of a renamed object in the\nnames of the subtests of its package, such as t.Run(\"Foo\", ...).\n",
+							Default: "true",
+						},
+						{
+							Name:    "\"tags\"",
+							Doc:     "`\"tags\"` controls the updating of struct tags, such as the\ndatabase columns of renamed fields.\n",
+							Default: "true",
+						},
+						{
+							Name:    "\"testdata\"",
+							Doc:     "`\"testdata\"` controls the updating of the Go files under testdata\ndirectories, which are not loaded as packages, such as the inputs of\nthe tests of analyzers: their imports of a renamed package and their\nreferences to a renamed package-level object.\n",
+							Default: "true",
+						},
+						{
+							Name:    "\"text\"",
+							Doc:     "`\"text\"` controls the updating of occurrences in non-Go files.\n",
+							Default: "true",
+						},
+						{
+							Name:    "\"vendor\"",
+							Doc:     "`\"vendor\"` controls the renaming of the copies of a renamed object\nvendored in the modules of other workspace folders.\n",
+							Default: "true",
+						},
+					},
+				},
+				Default:   "{\"accessors\":true,\"aliases\":false,\"almost\":true,\"assertions\":true,\"comments\":false,\"deprecations\":false,\"files\":true,\"flags\":true,\"generated\":true,\"implementations\":true,\"regenerate\":false,\"siblings\":true,\"strings\":true,\"subtests\":true,\"tags\":true,\"testdata\":true,\"text\":true,\"vendor\":true}",
+				Status:    "experimental",
+				Hierarchy: "ui.navigation.rename",
+			},
+			{
+				Name:      "renameForce",
+				Type:      "bool",
+				Doc:       "renameForce makes a rename that introduces conflicts, such as the\nshadowing of a reference, produce its edits instead of failing, as\nthe -force flag of gorename does. The edits at the conflicting sites\nare annotated with a description of the conflict, for the user to\nconfirm.\n",
+				Default:   "false",
+				Status:    "experimental",
+				Hierarchy: "ui.navigation.rename",
+			},
+			{
+				Name:      "renameAllowBreakingExternal",
+				Type:      "bool",
+				Doc:       "renameAllowBreakingExternal lets the renames of exported objects\nreferred to outside their edit scope, by the packages of the module\ncache or of other workspace folders, proceed. Otherwise, such renames\nfail, listing the references that they would break, to protect the\nusers of a library from accidental changes of its API.\n",
+				Default:   "false",
+				Status:    "experimental",
+				Hierarchy: "ui.navigation.rename",
+			},
+			{
+				Name:      "renameMoveEachFile",
+				Type:      "bool",
+				Doc:       "renameMoveEachFile makes the renaming of a package directory move\neach file within it, its non-Go files such as testdata, READMEs, and\nSQL and template files included, for the clients that can rename\nfiles but not directories. The emptied directories are left behind.\nOtherwise, a single operation renames the whole directory.\n",
+				Default:   "false",
+				Status:    "experimental",
+				Hierarchy: "ui.navigation.rename",
+			},
+			{
+				Name:      "renameFormat",
+				Type:      "bool",
+				Doc:       "renameFormat formats the Go files changed by a rename and fixes\ntheir imports, as goimports does, as part of the rename's edits.\nThis tidies the import blocks left unsorted by the rewriting of\nimport paths and the insertion of import names.\n",
+				Default:   "false",
+				Status:    "experimental",
+				Hierarchy: "ui.navigation.rename",
+			},
+			{
+				Name:      "renameReceiverName",
+				Type:      "string",
+				Doc:       "renameReceiverName is the name, such as \"self\", that the code action\naligning the receiver names of the methods of a type offers, besides\nthe first letter of the name of the type in lower case.\n",
+				Default:   "\"\"",
+				Status:    "experimental",
+				Hierarchy: "ui.navigation.rename",
+			},
+			{
+				Name:      "renameImportAliases",
+				Type:      "map[string]string",
+				Doc:       "renameImportAliases maps import paths to the local names that the\nrenaming of a package to one of them gives to its imports in the files\nwhere the new package name is taken, instead of the new name followed\nby a number.\n\nExample Usage:\n\n```json5\n\"gopls\": {\n...\n  \"renameImportAliases\": {\n    \"example.com/internal/log\": \"xlog\",\n  }\n...\n}\n```\n",
+				Default:   "{}",
+				Status:    "experimental",
+				Hierarchy: "ui.navigation.rename",
+			},
 			{
 				Name: "analyses",
 				Type: "map[string]bool",
@@ -315,6 +633,11 @@ var GeneratedAPIJSON = &APIJSON{
 							Doc:     "check cancel func returned by context.WithCancel is called\n\nThe cancellation function returned by context.WithCancel, WithTimeout,\nand WithDeadline must be called or the new context will remain live\nuntil its parent context is cancelled.\n(The background context is never cancelled.)",
 							Default: "true",
 						},
+						{
+							Name:    "\"naming\"",
+							Doc:     "check that names follow the Go naming conventions\n\nThis checker reports the declared names that do not follow the Go naming\nconventions:\n\n\tvar user_name string // underscores: userName\n\tfunc ServeHttp()      // initialisms: ServeHTTP\n\nand the receivers whose names differ from those of the other methods of\ntheir type. In gopls, its suggested fixes rename the name with the rename\nengine, and thus update all of its references in the workspace.",
+							Default: "false",
+						},
 						{
 							Name:    "\"nilfunc\"",
 							Doc:     "check for useless comparisons between functions and nil\n\nA useless comparison is one like f == nil as opposed to f() == nil.",
@@ -683,7 +1006,14 @@ var GeneratedAPIJSON = &APIJSON{
 			Command: "gopls.apply_fix",
 			Title:   "Apply a fix",
 			Doc:     "Applies a fix to a region of source code.",
-			ArgDoc:  "{\n\t// The fix to apply.\n\t\"Fix\": string,\n\t// The file URI for the document to fix.\n\t\"URI\": string,\n\t// The document range to scan for fixes.\n\t\"Range\": {\n\t\t\"start\": {\n\t\t\t\"line\": uint32,\n\t\t\t\"character\": uint32,\n\t\t},\n\t\t\"end\": {\n\t\t\t\"line\": uint32,\n\t\t\t\"character\": uint32,\n\t\t},\n\t},\n}",
+			ArgDoc:  "{\n\t// The fix to apply.\n\t\"Fix\": string,\n\t// The file URI for the document to fix.\n\t\"URI\": string,\n\t// The document range to scan for fixes.\n\t\"Range\": {\n\t\t\"start\": {\n\t\t\t\"line\": uint32,\n\t\t\t\"character\": uint32,\n\t\t},\n\t\t\"end\": {\n\t\t\t\"line\": uint32,\n\t\t\t\"character\": uint32,\n\t\t},\n\t},\n\t// The new name of the identifier at the start of Range, for the\n\t// rename_name fix, or of the receivers of the methods of the type\n\t// named there, for the align_receivers fix.\n\t\"NewName\": string,\n}",
+		},
+		{
+			Command:   "gopls.begin_rename",
+			Title:     "Begin a rename session",
+			Doc:       "Performs the analysis of a rename and returns a preview of its\neffects, as gopls.dry_run_rename does, along with the token of a\nsession to commit with gopls.commit_rename.",
+			ArgDoc:    "{\n\t// The document to rename.\n\t\"textDocument\": {\n\t\t\"uri\": string,\n\t},\n\t// The position at which this request was sent.\n\t\"position\": {\n\t\t\"line\": uint32,\n\t\t\"character\": uint32,\n\t},\n\t// * The new name of the symbol. If the given name is not valid the\n\t// \t * request must return a [ResponseError](#ResponseError) with an\n\t// \t * appropriate message set.\n\t\"newName\": string,\n\t\"WorkDoneProgressParams\": {\n\t\t\"workDoneToken\": interface{},\n\t},\n}",
+			ResultDoc: "{\n\t// Token identifies the rename session.\n\t\"Token\": string,\n\t// Preview describes the effects of the rename.\n\t\"Preview\": {\n\t\t\"Files\": []{\n\t\t\t\"URI\": string,\n\t\t\t\"Edits\": int,\n\t\t\t\"Packages\": []string,\n\t\t},\n\t\t\"Annotations\": []{\n\t\t\t\"ID\": string,\n\t\t\t\"Label\": string,\n\t\t\t\"Description\": string,\n\t\t\t\"NeedsConfirmation\": bool,\n\t\t\t\"Files\": { ... },\n\t\t},\n\t\t\"Conflicts\": []string,\n\t\t\"Warnings\": []string,\n\t\t\"Skipped\": []{\n\t\t\t\"Location\": { ... },\n\t\t\t\"Name\": string,\n\t\t\t\"Reason\": string,\n\t\t},\n\t\t\"ExportData\": []{\n\t\t\t\"Path\": string,\n\t\t\t\"References\": { ... },\n\t\t},\n\t\t\"ExternalReferences\": []{\n\t\t\t\"Package\": string,\n\t\t\t\"Scope\": string,\n\t\t\t\"References\": int,\n\t\t},\n\t\t\"Package\": bool,\n\t\t\"Packages\": []string,\n\t\t\"Exported\": bool,\n\t},\n}",
 		},
 		{
 			Command: "gopls.check_upgrades",
@@ -691,12 +1021,33 @@ var GeneratedAPIJSON = &APIJSON{
 			Doc:     "Checks for module upgrades.",
 			ArgDoc:  "{\n\t// The go.mod file URI.\n\t\"URI\": string,\n\t// The modules to check.\n\t\"Modules\": []string,\n}",
 		},
+		{
+			Command:   "gopls.commit_rename",
+			Title:     "Commit a rename session",
+			Doc:       "Applies the edits of a rename begun by gopls.begin_rename, along\nwith the selected optional edits, through a workspace/applyEdit\nrequest. The edits are computed again as for textDocument/rename, in\nthe workspace as it is at commit, so that a rename introducing\nconflicts fails unless the renameForce setting is set. A session can\nbe committed once.",
+			ArgDoc:    "{\n\t// Token identifies the rename session, as returned by\n\t// gopls.begin_rename.\n\t\"Token\": string,\n\t// Annotations lists the change annotations of the optional edits to\n\t// apply along with the required ones, by id or by group.\n\t\"Annotations\": []string,\n}",
+			ResultDoc: "{\n\t// Revalidated reports whether the workspace changed since the\n\t// session began, so that the edits may differ from its preview.\n\t\"Revalidated\": bool,\n\t// Remaining lists the occurrences left unchanged in the files not\n\t// open in the editor, if renames are restricted to open files.\n\t\"Remaining\": []{\n\t\t\"uri\": string,\n\t\t\"range\": {\n\t\t\t\"start\": { ... },\n\t\t\t\"end\": { ... },\n\t\t},\n\t},\n\t// ExternalReferences counts, by package, the references to a renamed\n\t// exported object outside the edit scope of the rename, which it did\n\t// not update.\n\t\"ExternalReferences\": []{\n\t\t\"Package\": string,\n\t\t\"Scope\": string,\n\t\t\"References\": int,\n\t},\n}",
+		},
+		{
+			Command:   "gopls.dry_run_rename",
+			Title:     "Report the effects of a rename",
+			Doc:       "Performs the analysis of a rename, including its conflicts, the\nimplementations it affects and the optional edits it offers, and\nreturns a report of its effects instead of its edits, so that a large\nrename can be reviewed before it is applied.",
+			ArgDoc:    "{\n\t// The document to rename.\n\t\"textDocument\": {\n\t\t\"uri\": string,\n\t},\n\t// The position at which this request was sent.\n\t\"position\": {\n\t\t\"line\": uint32,\n\t\t\"character\": uint32,\n\t},\n\t// * The new name of the symbol. If the given name is not valid the\n\t// \t * request must return a [ResponseError](#ResponseError) with an\n\t// \t * appropriate message set.\n\t\"newName\": string,\n\t\"WorkDoneProgressParams\": {\n\t\t\"workDoneToken\": interface{},\n\t},\n}",
+			ResultDoc: "{\n\t// Files lists the files that the required edits of the rename change.\n\t\"Files\": []{\n\t\t\"URI\": string,\n\t\t\"Edits\": int,\n\t\t\"Packages\": []string,\n\t},\n\t// Annotations lists the groups of optional edits, by change annotation.\n\t\"Annotations\": []{\n\t\t\"ID\": string,\n\t\t\"Label\": string,\n\t\t\"Description\": string,\n\t\t\"NeedsConfirmation\": bool,\n\t\t\"Files\": []{\n\t\t\t\"URI\": string,\n\t\t\t\"Edits\": int,\n\t\t\t\"Packages\": []string,\n\t\t},\n\t},\n\t// Conflicts describes the conflicts that the rename introduces, which\n\t// make it fail unless forced.\n\t\"Conflicts\": []string,\n\t// Warnings describes the consequences of the rename that its edits\n\t// cannot address.\n\t\"Warnings\": []string,\n\t// Skipped lists the implementations of a renamed interface method that\n\t// the rename leaves unchanged, as they lie outside the workspace.\n\t\"Skipped\": []{\n\t\t\"Location\": {\n\t\t\t\"uri\": string,\n\t\t\t\"range\": { ... },\n\t\t},\n\t\t\"Name\": string,\n\t\t\"Reason\": string,\n\t},\n\t// ExportData lists the packages referring to the renamed object or\n\t// package that the rename cannot edit, as they are loaded only from\n\t// export data.\n\t\"ExportData\": []{\n\t\t\"Path\": string,\n\t\t\"References\": []{\n\t\t\t\"uri\": string,\n\t\t\t\"range\": { ... },\n\t\t},\n\t},\n\t// ExternalReferences counts, by package, the references to a renamed\n\t// exported object outside the edit scope of the rename, which it does\n\t// not update.\n\t\"ExternalReferences\": []{\n\t\t\"Package\": string,\n\t\t\"Scope\": string,\n\t\t\"References\": int,\n\t},\n\t// Package reports whether the rename is of a package.\n\t\"Package\": bool,\n\t// Packages lists the IDs of the packages whose files the required or\n\t// optional edits change, which may need to be checked or tested again\n\t// once the rename is applied.\n\t\"Packages\": []string,\n\t// Exported reports whether the renamed object or package may be\n\t// referred to by importers outside the workspace, which the rename\n\t// cannot update.\n\t\"Exported\": bool,\n}",
+		},
 		{
 			Command: "gopls.edit_go_directive",
 			Title:   "Run go mod edit -go=version",
 			Doc:     "Runs `go mod edit -go=version` for a module.",
 			ArgDoc:  "{\n\t// Any document URI within the relevant module.\n\t\"URI\": string,\n\t// The version to pass to `go mod edit -go`.\n\t\"Version\": string,\n}",
 		},
+		{
+			Command:   "gopls.fix_stuttering_names",
+			Title:     "Fix the stuttering names of a package",
+			Doc:       "Computes the renames of the exported names of the package of the\ngiven file that stutter with the package name, such as user.UserID,\nto the names without the prefix, such as user.ID, and returns them\nas one workspace edit, with a change annotation per rename. The\nrenames colliding with one another, or refused by the rename engine,\nare left out of the edit and reported.",
+			ArgDoc:    "{\n\t// The file URI.\n\t\"URI\": string,\n}",
+			ResultDoc: "{\n\t// Edit performs the renames. The edits of each rename carry the\n\t// change annotation \"stutter/<old name>\".\n\t\"Edit\": {\n\t\t\"changes\": map[golang.org/x/tools/gopls/internal/lsp/protocol.DocumentURI][]golang.org/x/tools/gopls/internal/lsp/protocol.TextEdit,\n\t\t\"documentChanges\": []{\n\t\t\t\"TextDocumentEdit\": { ... },\n\t\t\t\"RenameFile\": { ... },\n\t\t},\n\t\t\"changeAnnotations\": map[string]golang.org/x/tools/gopls/internal/lsp/protocol.ChangeAnnotation,\n\t},\n\t// Renames lists the renames of the stuttering names, in the order of\n\t// the names.\n\t\"Renames\": []{\n\t\t\"OldName\": string,\n\t\t\"NewName\": string,\n\t\t\"Location\": {\n\t\t\t\"uri\": string,\n\t\t\t\"range\": { ... },\n\t\t},\n\t\t\"Reason\": string,\n\t},\n}",
+		},
 		{
 			Command: "gopls.gc_details",
 			Title:   "Toggle gc_details",
@@ -735,6 +1086,13 @@ var GeneratedAPIJSON = &APIJSON{
 			ArgDoc:    "{\n\t// The file URI.\n\t\"URI\": string,\n}",
 			ResultDoc: "{\n\t// Packages is a list of packages relative\n\t// to the URIArg passed by the command request.\n\t// In other words, it omits paths that are already\n\t// imported or cannot be imported due to compiler\n\t// restrictions.\n\t\"Packages\": []string,\n}",
 		},
+		{
+			Command:   "gopls.prepare_rename",
+			Title:     "Prepare a rename",
+			Doc:       "Checks whether the identifier at the given position can be renamed,\nas textDocument/prepareRename does, and returns its range and name,\nor else the code of the reason why the rename is refused along with\nits message, so that clients can act upon specific refusals.",
+			ArgDoc:    "{\n\t// The text document.\n\t\"textDocument\": {\n\t\t\"uri\": string,\n\t},\n\t// The position inside the text document.\n\t\"position\": {\n\t\t\"line\": uint32,\n\t\t\"character\": uint32,\n\t},\n}",
+			ResultDoc: "{\n\t// Range is the range of the identifier to rename, if it can be renamed.\n\t\"Range\": {\n\t\t\"start\": {\n\t\t\t\"line\": uint32,\n\t\t\t\"character\": uint32,\n\t\t},\n\t\t\"end\": {\n\t\t\t\"line\": uint32,\n\t\t\t\"character\": uint32,\n\t\t},\n\t},\n\t// Placeholder is the current name of the identifier.\n\t\"Placeholder\": string,\n\t// Reason, if set, is the code of the reason why the rename is refused:\n\t// \"syntax_error\", \"ambiguous\", \"no_package\", \"main_package\",\n\t// \"x_test_package\", \"no_module\", \"package_error\", \"embedded_field\" or\n\t// \"blank\".\n\t\"Reason\": string,\n\t// Message describes the refusal, if any.\n\t\"Message\": string,\n\t// PackageModes lists, at a package clause, the modes in which the\n\t// package can be renamed by gopls.rename_package, the default first:\n\t// \"directory\", which renames its directory and updates the import\n\t// paths, and \"clause\", which renames only its package clauses and the\n\t// names of its imports.\n\t\"PackageModes\": []string,\n}",
+		},
 		{
 			Command: "gopls.regenerate_cgo",
 			Title:   "Regenerate cgo",
@@ -747,12 +1105,65 @@ var GeneratedAPIJSON = &APIJSON{
 			Doc:     "Removes a dependency from the go.mod file of a module.",
 			ArgDoc:  "{\n\t// The go.mod file URI.\n\t\"URI\": string,\n\t// The module path to remove.\n\t\"ModulePath\": string,\n\t\"OnlyDiagnostic\": bool,\n}",
 		},
+		{
+			Command:   "gopls.rename_cache_state",
+			Title:     "Dump the rename analysis cache",
+			Doc:       "Returns the state of the cache of the per-package analyses of\nrenames of the view of the given file, such as the interface\nsatisfaction constraints of the packages, to diagnose slow or\nincorrect renames: its entries, with the keys they are valid for,\nand its numbers of hits, misses and evictions.",
+			ArgDoc:    "{\n\t// The file URI.\n\t\"URI\": string,\n}",
+			ResultDoc: "{\n\t// Hits and Misses count the lookups of the cache that found a\n\t// valid entry and that found none. Evictions counts the entries\n\t// evicted as no longer valid, as their packages changed, or as the\n\t// least recently used of a full cache.\n\t\"Hits\": int,\n\t\"Misses\": int,\n\t\"Evictions\": int,\n\t// Entries lists the entries of the cache, by package and kind.\n\t\"Entries\": []{\n\t\t\"Kind\": string,\n\t\t\"Package\": string,\n\t\t\"PackageKey\": string,\n\t\t\"Options\": string,\n\t\t\"Size\": int,\n\t\t\"Hits\": int,\n\t\t\"Duration\": string,\n\t},\n}",
+		},
+		{
+			Command:   "gopls.rename_candidates",
+			Title:     "List rename candidates",
+			Doc:       "Returns the objects that a rename at the given position could apply to.\nMore than one candidate is returned if the position is ambiguous, for\nexample at an embedded field, which denotes both a field and a type.\nClients may let the user choose, and request the rename at the\ndeclaration of the chosen candidate.",
+			ArgDoc:    "{\n\t// The text document.\n\t\"textDocument\": {\n\t\t\"uri\": string,\n\t},\n\t// The position inside the text document.\n\t\"position\": {\n\t\t\"line\": uint32,\n\t\t\"character\": uint32,\n\t},\n}",
+			ResultDoc: "{\n\t// Candidates lists the objects that may be renamed, in order of\n\t// preference.\n\t\"Candidates\": []{\n\t\t\"Name\": string,\n\t\t\"Kind\": string,\n\t\t\"Location\": {\n\t\t\t\"uri\": string,\n\t\t\t\"range\": { ... },\n\t\t},\n\t\t\"Reason\": string,\n\t},\n}",
+		},
+		{
+			Command:   "gopls.rename_history",
+			Title:     "List recently computed renames",
+			Doc:       "Returns the journal of the renames computed by the server in this\nsession, most recent last, so that they can be audited or undone.\nA rename is recorded when its edits are computed: the server can't\ntell whether the client applies the edits of a textDocument/rename\nresponse, so only the records marked Applied, of the renames whose\nedits the server applied itself, are known to have taken effect.",
+			ResultDoc: "{\n\t// Renames lists the recorded renames, oldest first.\n\t\"Renames\": []{\n\t\t\"Location\": {\n\t\t\t\"uri\": string,\n\t\t\t\"range\": { ... },\n\t\t},\n\t\t\"OldName\": string,\n\t\t\"NewName\": string,\n\t\t\"Package\": bool,\n\t\t\"Files\": []string,\n\t\t\"Remaining\": []{\n\t\t\t\"uri\": string,\n\t\t\t\"range\": { ... },\n\t\t},\n\t\t\"Applied\": bool,\n\t\t\"Time\": string,\n\t},\n}",
+		},
+		{
+			Command:   "gopls.rename_manifest",
+			Title:     "Compute the manifest of a rename",
+			Doc:       "Returns the manifest of a rename with the given optional edits: the\nnames that its edits change, with their kinds and the positions of\ntheir declarations, so that the repositories depending on the\nrenamed objects or packages can be updated by tools reading it. The\nJSON schema of the manifest is versioned by its version field.",
+			ArgDoc:    "{\n\t// Params is the rename.\n\t\"Params\": {\n\t\t\"textDocument\": {\n\t\t\t\"uri\": string,\n\t\t},\n\t\t\"position\": {\n\t\t\t\"line\": uint32,\n\t\t\t\"character\": uint32,\n\t\t},\n\t\t\"newName\": string,\n\t\t\"WorkDoneProgressParams\": {\n\t\t\t\"workDoneToken\": interface{},\n\t\t},\n\t},\n\t// Annotations lists the change annotations of the optional edits\n\t// applied along with the required ones, by id or by group.\n\t\"Annotations\": []string,\n}",
+			ResultDoc: "{\n\t// Version is the version of the schema of the manifest, currently 1.\n\t\"version\": int,\n\t// Renames lists the names changed by the rename, ordered by their old\n\t// qualified names. The objects that other packages cannot refer to,\n\t// such as local variables, are left out.\n\t\"renames\": []{\n\t\t\"kind\": string,\n\t\t\"old\": {\n\t\t\t\"qualified\": string,\n\t\t\t\"package\": string,\n\t\t\t\"name\": string,\n\t\t},\n\t\t\"new\": {\n\t\t\t\"qualified\": string,\n\t\t\t\"package\": string,\n\t\t\t\"name\": string,\n\t\t},\n\t\t\"positions\": []{\n\t\t\t\"uri\": string,\n\t\t\t\"range\": { ... },\n\t\t},\n\t},\n}",
+		},
+		{
+			Command: "gopls.rename_package",
+			Title:   "Rename a package in a given mode",
+			Doc:     "Renames the package whose package clause is at the given position,\nas textDocument/rename does, in one of the modes that\ngopls.prepare_rename reports there rather than the default one, and\napplies the edits through a workspace/applyEdit request.",
+			ArgDoc:  "{\n\t// The rename, at the package clause of a file of the package.\n\t\"Rename\": {\n\t\t\"textDocument\": {\n\t\t\t\"uri\": string,\n\t\t},\n\t\t\"position\": {\n\t\t\t\"line\": uint32,\n\t\t\t\"character\": uint32,\n\t\t},\n\t\t\"newName\": string,\n\t\t\"WorkDoneProgressParams\": {\n\t\t\t\"workDoneToken\": interface{},\n\t\t},\n\t},\n\t// Mode is one of the modes that gopls.prepare_rename reports at the\n\t// package clause.\n\t\"Mode\": string,\n}",
+		},
+		{
+			Command:   "gopls.rename_profile",
+			Title:     "Profile the analysis of a rename",
+			Doc:       "Runs again the analysis of the given rename, phase by phase, and\nreturns the duration of each phase and the size of its results, to\npinpoint what makes renames slow in a workspace. The phases run in\norder, so the packages type checked by one are cached for the next,\nas they are in a rename.",
+			ArgDoc:    "{\n\t// The document to rename.\n\t\"textDocument\": {\n\t\t\"uri\": string,\n\t},\n\t// The position at which this request was sent.\n\t\"position\": {\n\t\t\"line\": uint32,\n\t\t\"character\": uint32,\n\t},\n\t// * The new name of the symbol. If the given name is not valid the\n\t// \t * request must return a [ResponseError](#ResponseError) with an\n\t// \t * appropriate message set.\n\t\"newName\": string,\n\t\"WorkDoneProgressParams\": {\n\t\t\"workDoneToken\": interface{},\n\t},\n}",
+			ResultDoc: "{\n\t// Phases lists the phases of the analysis in order: \"metadata\",\n\t// \"load\", \"references\", \"satisfy\", \"implementations\", \"check\" and\n\t// \"edits\".\n\t// The phases that do not apply to the renamed object or package,\n\t// such as the implementations of a method, are left out.\n\t\"Phases\": []{\n\t\t\"Name\": string,\n\t\t\"Duration\": string,\n\t\t\"Size\": int,\n\t\t\"Unit\": string,\n\t},\n\t// Total is the time taken by the whole analysis, such as \"1.5s\".\n\t\"Total\": string,\n}",
+		},
+		{
+			Command:   "gopls.rename_remainder",
+			Title:     "List the occurrences left by the last rename",
+			Doc:       "Returns the locations of the occurrences that the last rename left\nunchanged in the files not open in the editor, as renames are\nrestricted to open files when the renameOpenFilesOnly setting is\nset, for the client to present them as a location list.",
+			ResultDoc: "{\n\t\"OldName\": string,\n\t\"NewName\": string,\n\t// Locations lists the occurrences left unchanged by the last rename.\n\t\"Locations\": []{\n\t\t\"uri\": string,\n\t\t\"range\": {\n\t\t\t\"start\": { ... },\n\t\t\t\"end\": { ... },\n\t\t},\n\t},\n}",
+		},
 		{
 			Command: "gopls.reset_go_mod_diagnostics",
 			Title:   "Reset go.mod diagnostics",
 			Doc:     "Reset diagnostics in the go.mod file of a module.",
 			ArgDoc:  "{\n\t// The file URI.\n\t\"URI\": string,\n}",
 		},
+		{
+			Command:   "gopls.resolve_rename_spec",
+			Title:     "Resolve a gorename specifier",
+			Doc:       "Returns the location of the declaration of the object denoted by a\nspecifier of the -from flag of gorename, such as\n\"encoding/json\".Decoder.Decode, among the packages of the workspace,\nfor the scripts addressing renames as gorename does. The filename of\nthe json.go::x form must be absolute.",
+			ArgDoc:    "{\n\t// Spec is the specifier, in the form of the -from flag of gorename.\n\t\"Spec\": string,\n}",
+			ResultDoc: "{\n\t// Location is the declaring identifier of the object.\n\t\"Location\": {\n\t\t\"uri\": string,\n\t\t\"range\": {\n\t\t\t\"start\": { ... },\n\t\t\t\"end\": { ... },\n\t\t},\n\t},\n}",
+		},
 		{
 			Command: "gopls.run_tests",
 			Title:   "Run test(s)",
@@ -778,6 +1189,13 @@ var GeneratedAPIJSON = &APIJSON{
 			Doc:     "Runs `go test` for a specific set of test or benchmark functions.",
 			ArgDoc:  "string,\n[]string,\n[]string",
 		},
+		{
+			Command:   "gopls.text_occurrences",
+			Title:     "List occurrences in non-Go files",
+			Doc:       "Returns the locations, in non-Go files of the workspace such as\ndocuments, configuration and scripts, of the qualified name or import\npath of the object or package at the given position, so that those a\nrename does not edit may be updated by hand.",
+			ArgDoc:    "{\n\t// The text document.\n\t\"textDocument\": {\n\t\t\"uri\": string,\n\t},\n\t// The position inside the text document.\n\t\"position\": {\n\t\t\"line\": uint32,\n\t\t\"character\": uint32,\n\t},\n}",
+			ResultDoc: "{\n\t// Locations lists the occurrences of the qualified name or import path.\n\t\"Locations\": []{\n\t\t\"uri\": string,\n\t\t\"range\": {\n\t\t\t\"start\": { ... },\n\t\t\t\"end\": { ... },\n\t\t},\n\t},\n}",
+		},
 		{
 			Command: "gopls.tidy",
 			Title:   "Run go mod tidy",
@@ -796,6 +1214,12 @@ var GeneratedAPIJSON = &APIJSON{
 			Doc:     "Updates the go.sum file for a module.",
 			ArgDoc:  "{\n\t// The file URIs.\n\t\"URIs\": []string,\n}",
 		},
+		{
+			Command: "gopls.update_moved_imports",
+			Title:   "Update imports of a moved package",
+			Doc:     "Updates the imports of the workspace after the directory of a package\nwas moved outside the editor, for example by git mv, as renaming the\ndirectory with gopls would have. Only imports that no longer denote a\npackage are changed.",
+			ArgDoc:  "{\n\t// The file whose broken import revealed the move.\n\t\"URI\": string,\n\t// The import path of the moved directory, before the move.\n\t\"OldPath\": string,\n\t// The import path of the moved directory, after the move.\n\t\"NewPath\": string,\n}",
+		},
 		{
 			Command: "gopls.upgrade_dependency",
 			Title:   "Upgrade a dependency",
@@ -808,6 +1232,13 @@ var GeneratedAPIJSON = &APIJSON{
 			Doc:     "Runs `go mod vendor` for a module.",
 			ArgDoc:  "{\n\t// The file URI.\n\t\"URI\": string,\n}",
 		},
+		{
+			Command:   "gopls.verify_rename",
+			Title:     "Verify a rename",
+			Doc:       "Loads and type-checks the packages affected by a rename, and their\nreverse dependencies, as they would be once its edits are applied,\nand returns their errors. No file is written.",
+			ArgDoc:    "{\n\t// Params is the rename to verify.\n\t\"Params\": {\n\t\t\"textDocument\": {\n\t\t\t\"uri\": string,\n\t\t},\n\t\t\"position\": {\n\t\t\t\"line\": uint32,\n\t\t\t\"character\": uint32,\n\t\t},\n\t\t\"newName\": string,\n\t\t\"WorkDoneProgressParams\": {\n\t\t\t\"workDoneToken\": interface{},\n\t\t},\n\t},\n\t// Annotations lists the change annotations of the optional edits to\n\t// apply along with the required ones, by id or by group.\n\t\"Annotations\": []string,\n}",
+			ResultDoc: "{\n\t// Errors lists the errors of the affected packages after the rename.\n\t\"Errors\": []{\n\t\t\"Location\": {\n\t\t\t\"uri\": string,\n\t\t\t\"range\": { ... },\n\t\t},\n\t\t\"Message\": string,\n\t},\n}",
+		},
 	},
 	Lenses: []*LensJSON{
 		{
@@ -941,6 +1372,10 @@ var GeneratedAPIJSON = &APIJSON{
 			Doc:     "check cancel func returned by context.WithCancel is called\n\nThe cancellation function returned by context.WithCancel, WithTimeout,\nand WithDeadline must be called or the new context will remain live\nuntil its parent context is cancelled.\n(The background context is never cancelled.)",
 			Default: true,
 		},
+		{
+			Name: "naming",
+			Doc:  "check that names follow the Go naming conventions\n\nThis checker reports the declared names that do not follow the Go naming\nconventions:\n\n\tvar user_name string // underscores: userName\n\tfunc ServeHttp()      // initialisms: ServeHTTP\n\nand the receivers whose names differ from those of the other methods of\ntheir type. In gopls, its suggested fixes rename the name with the rename\nengine, and thus update all of its references in the workspace.",
+		},
 		{
 			Name:    "nilfunc",
 			Doc:     "check for useless comparisons between functions and nil\n\nA useless comparison is one like f == nil as opposed to f() == nil.",
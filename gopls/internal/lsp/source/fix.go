@@ -37,6 +37,16 @@ const (
 	ExtractVariable = "extract_variable"
 	ExtractFunction = "extract_function"
 	ExtractMethod   = "extract_method"
+
+	// RenameName is the fix renaming the identifier at the start of its
+	// range, with all of its references, to its new name. It implements
+	// the suggested fixes of the renamefix package, which the rename
+	// engine resolves only when they are applied.
+	RenameName = "rename_name"
+
+	// AlignReceivers is the fix renaming the receivers of all the methods
+	// of the type named at the start of its range to its new name.
+	AlignReceivers = "align_receivers"
 )
 
 // suggestedFixes maps a suggested fix command id to its handler.
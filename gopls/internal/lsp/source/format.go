@@ -346,14 +346,21 @@ func ToProtocolEdits(m *protocol.ColumnMapper, edits []diff.Edit) ([]protocol.Te
 	edits = append([]diff.Edit(nil), edits...)
 	diff.SortEdits(edits)
 
+	// The sorted edits are converted in a single pass over the content,
+	// which matters for large files.
+	cursor := m.Cursor()
 	result := make([]protocol.TextEdit, len(edits))
 	for i, edit := range edits {
-		rng, err := m.OffsetRange(edit.Start, edit.End)
+		start, err := cursor.Position(edit.Start)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("start: %v", err)
+		}
+		end, err := cursor.Position(edit.End)
+		if err != nil {
+			return nil, fmt.Errorf("end: %v", err)
 		}
 		result[i] = protocol.TextEdit{
-			Range:   rng,
+			Range:   protocol.Range{Start: start, End: end},
 			NewText: edit.New,
 		}
 	}
@@ -7,8 +7,12 @@ package source
 import (
 	"strings"
 	"testing"
+	"unicode/utf8"
 
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
 	"golang.org/x/tools/gopls/internal/lsp/tests/compare"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/diff"
 )
 
 func TestImportPrefix(t *testing.T) {
@@ -73,3 +77,31 @@ Hi description
 		}
 	}
 }
+
+func TestToProtocolEdits(t *testing.T) {
+	content := []byte("package a\n\n// héllo, 世界 𝄞\nvar x = \"𝄞\" // x\r\n\nvar y = x\n")
+	m := protocol.NewColumnMapper(span.URIFromPath("a.go"), content)
+
+	// Insertions, and replacements of each rune, at every rune boundary,
+	// in reverse order.
+	edits := []diff.Edit{{Start: len(content), End: len(content), New: "a"}}
+	for i := len(content); i > 0; {
+		_, size := utf8.DecodeLastRune(content[:i])
+		edits = append(edits, diff.Edit{Start: i - size, End: i, New: "b"}, diff.Edit{Start: i - size, End: i - size, New: "a"})
+		i -= size
+	}
+	got, err := ToProtocolEdits(m, edits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff.SortEdits(edits)
+	for i, edit := range edits {
+		want, err := m.OffsetRange(edit.Start, edit.End)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got[i].Range != want {
+			t.Errorf("range of [%d:%d] = %v, want %v", edit.Start, edit.End, got[i].Range, want)
+		}
+	}
+}
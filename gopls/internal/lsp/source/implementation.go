@@ -11,7 +11,9 @@ import (
 	"go/ast"
 	"go/token"
 	"go/types"
+	"path/filepath"
 	"sort"
+	"strings"
 
 	"golang.org/x/tools/gopls/internal/lsp/protocol"
 	"golang.org/x/tools/gopls/internal/lsp/safetoken"
@@ -23,7 +25,7 @@ func Implementation(ctx context.Context, snapshot Snapshot, f FileHandle, pp pro
 	ctx, done := event.Start(ctx, "source.Implementation")
 	defer done()
 
-	impls, err := implementations(ctx, snapshot, f, pp)
+	impls, err := implementations(ctx, snapshot, f, pp, "")
 	if err != nil {
 		return nil, err
 	}
@@ -61,13 +63,22 @@ var ErrNotAType = errors.New("not a type name or method")
 // interface, or the interfaces implemented by the specified concrete type.
 // It populates only the definition-related fields of qualifiedObject.
 // (Arguably it should return a smaller data type.)
-func implementations(ctx context.Context, s Snapshot, f FileHandle, pp protocol.Position) ([]qualifiedObject, error) {
+//
+// If renaming is set, the lookup is that of the renaming of the object
+// named renaming, which fails if it would type check more packages than
+// the renameMaxPackages setting allows.
+func implementations(ctx context.Context, s Snapshot, f FileHandle, pp protocol.Position, renaming string) ([]qualifiedObject, error) {
 	// Find all named types, even local types
 	// (which can have methods due to promotion).
 	var (
 		allNamed []*types.Named
 		pkgs     = make(map[*types.Package]Package)
 	)
+	if renaming != "" {
+		if err := checkKnownPackagesScale(ctx, s, renaming); err != nil {
+			return nil, err
+		}
+	}
 	knownPkgs, err := s.KnownPackages(ctx)
 	if err != nil {
 		return nil, err
@@ -162,6 +173,220 @@ func implementations(ctx context.Context, s Snapshot, f FileHandle, pp protocol.
 	return impls, nil
 }
 
+// interfacesWithMethod returns the interface types of the known packages,
+// declared at package level or within functions, that have a method of the
+// given name, once per declaration.
+func interfacesWithMethod(ctx context.Context, s Snapshot, name string) ([]*types.TypeName, error) {
+	knownPkgs, err := loadKnownPackages(ctx, s, name)
+	if err != nil {
+		return nil, err
+	}
+	var intfs []*types.TypeName
+	seen := make(map[token.Position]bool)
+	for _, pkg := range knownPkgs {
+		for _, tname := range interfaceTypeNames(pkg) {
+			if m, _, _ := types.LookupFieldOrMethod(tname.Type(), false, tname.Pkg(), name); m == nil {
+				continue
+			}
+			if pos := s.FileSet().Position(tname.Pos()); !seen[pos] {
+				seen[pos] = true
+				intfs = append(intfs, tname)
+			}
+		}
+	}
+	return intfs, nil
+}
+
+// almostImplementations returns the methods of the named types of the known
+// packages that have the name and the signature of the interface method
+// but that are not among its implementations impls, typically because their
+// types lack other methods of the interface, or are being written. They are
+// usually intended to implement it.
+func almostImplementations(ctx context.Context, s Snapshot, method *types.Func, impls []qualifiedObject) ([]qualifiedObject, error) {
+	knownPkgs, err := loadKnownPackages(ctx, s, method.Name())
+	if err != nil {
+		return nil, err
+	}
+	seen := map[token.Position]bool{s.FileSet().Position(method.Pos()): true}
+	for _, impl := range impls {
+		seen[s.FileSet().Position(impl.obj.Pos())] = true
+	}
+	sig := method.Type().(*types.Signature)
+	var almost []qualifiedObject
+	for _, pkg := range knownPkgs {
+		for _, obj := range pkg.GetTypesInfo().Defs {
+			tname, ok := obj.(*types.TypeName)
+			if !ok || tname.IsAlias() || IsInterface(tname.Type()) {
+				continue
+			}
+			named, ok := tname.Type().(*types.Named)
+			if !ok {
+				continue
+			}
+			for i := 0; i < named.NumMethods(); i++ {
+				m := named.Method(i)
+				if m.Name() != method.Name() || !types.Identical(m.Type(), sig) {
+					continue
+				}
+				if pos := s.FileSet().Position(m.Pos()); !seen[pos] {
+					seen[pos] = true
+					almost = append(almost, qualifiedObject{obj: m, pkg: pkg})
+				}
+			}
+		}
+	}
+	return almost, nil
+}
+
+// otherImplementedInterfaces returns the names of the interfaces among
+// intfs whose method of the same name as method impl implements, other than
+// those that declare or embed method itself.
+func otherImplementedInterfaces(s Snapshot, impl types.Object, method *types.Func, intfs []*types.TypeName) []string {
+	sig, ok := impl.Type().(*types.Signature)
+	if !ok || sig.Recv() == nil {
+		return nil
+	}
+	recv := ensurePointer(Deref(sig.Recv().Type()))
+	methodPos := s.FileSet().Position(method.Pos())
+	var names []string
+	for _, intf := range intfs {
+		m, _, _ := types.LookupFieldOrMethod(intf.Type(), false, method.Pkg(), method.Name())
+		if m == nil || s.FileSet().Position(m.Pos()) == methodPos {
+			continue
+		}
+		if types.Implements(recv, intf.Type().Underlying().(*types.Interface)) {
+			names = append(names, interfaceName(intf))
+		}
+	}
+	return names
+}
+
+// interfaceTypeNames returns the interface types declared by pkg: those
+// declared at package level, in order of name, then those declared within
+// functions, in order of declaration.
+func interfaceTypeNames(pkg Package) []*types.TypeName {
+	var tnames, local []*types.TypeName
+	scope := pkg.GetTypes().Scope()
+	for _, n := range scope.Names() {
+		if tname, ok := scope.Lookup(n).(*types.TypeName); ok && !tname.IsAlias() && IsInterface(tname.Type()) {
+			tnames = append(tnames, tname)
+		}
+	}
+	for _, obj := range pkg.GetTypesInfo().Defs {
+		if tname, ok := obj.(*types.TypeName); ok && !tname.IsAlias() && IsInterface(tname.Type()) && isLocal(tname) {
+			local = append(local, tname)
+		}
+	}
+	sort.Slice(local, func(i, j int) bool { return local[i].Pos() < local[j].Pos() })
+	return append(tnames, local...)
+}
+
+// interfaceName returns the name of the interface type tname qualified by
+// the name of its package and, if declared within a function or method, by
+// the name of the function, as in pkg.f.writer or pkg.T.Write.writer.
+func interfaceName(tname *types.TypeName) string {
+	name := tname.Pkg().Name() + "." + tname.Name()
+	if !isLocal(tname) {
+		return name
+	}
+	contains := func(fn *types.Func) bool {
+		return fn.Scope() != nil && fn.Scope().Pos() <= tname.Pos() && tname.Pos() < fn.Scope().End()
+	}
+	scope := tname.Pkg().Scope()
+	for _, n := range scope.Names() {
+		switch obj := scope.Lookup(n).(type) {
+		case *types.Func:
+			if contains(obj) {
+				return tname.Pkg().Name() + "." + obj.Name() + "." + tname.Name()
+			}
+		case *types.TypeName:
+			if named, ok := obj.Type().(*types.Named); ok && !obj.IsAlias() {
+				for i := 0; i < named.NumMethods(); i++ {
+					if m := named.Method(i); contains(m) {
+						return tname.Pkg().Name() + "." + obj.Name() + "." + m.Name() + "." + tname.Name()
+					}
+				}
+			}
+		}
+	}
+	return name // within a function literal of a package-level initializer
+}
+
+// excludedImplementation returns the reason why the rename of an interface
+// method leaves its implementation impl unchanged, or "" if it renames it:
+// only the implementations within the workspace modules, outside of their
+// vendor directories, and within the modules they replace by local
+// directories are renamed.
+func excludedImplementation(ctx context.Context, s Snapshot, impl qualifiedObject) string {
+	if impl.pkg == nil || impl.obj.Pkg() == nil {
+		return "predeclared"
+	}
+	filename := s.FileSet().Position(impl.obj.Pos()).Filename
+	if rel, ok := workspaceRelPath(s, filename); ok {
+		if isVendorPath(rel) {
+			return "vendored"
+		}
+		return ""
+	}
+	if metas, err := s.MetadataForFile(ctx, span.URIFromPath(filename)); err == nil && len(metas) > 0 && isLocalReplacement(metas[0]) {
+		return ""
+	}
+	if first := strings.Split(impl.obj.Pkg().Path(), "/")[0]; !strings.Contains(first, ".") {
+		return "in the standard library"
+	}
+	return "outside the workspace modules"
+}
+
+// workspaceRelPath returns the path of filename relative to the root of
+// the workspace module of s containing it, or to the folder of s if it has
+// no modules, and whether there is one.
+func workspaceRelPath(s Snapshot, filename string) (string, bool) {
+	var roots []string
+	for _, modURI := range s.ModFiles() {
+		roots = append(roots, filepath.Dir(modURI.Filename()))
+	}
+	if len(roots) == 0 {
+		roots = append(roots, s.View().Folder().Filename())
+	}
+	for _, root := range roots {
+		if rel, ok := relPath(root, filename); ok {
+			return rel, true
+		}
+	}
+	return "", false
+}
+
+// isVendorPath reports whether the relative path rel lies in a vendor
+// directory.
+func isVendorPath(rel string) bool {
+	return rel == "vendor" || strings.HasPrefix(rel, "vendor"+string(filepath.Separator)) ||
+		strings.Contains(rel, string(filepath.Separator)+"vendor"+string(filepath.Separator))
+}
+
+// inImplementationsScope reports whether the method impl, coupled to the
+// renamed method by an interface, lies within the implementations scope
+// of the options of s.
+func inImplementationsScope(s Snapshot, impl qualifiedObject, renamed types.Object) bool {
+	switch s.View().Options().RenameImplementationsScope {
+	case PackageImplementations:
+		return impl.obj.Pkg() != nil && renamed.Pkg() != nil && impl.obj.Pkg().Path() == renamed.Pkg().Path()
+	}
+	return true
+}
+
+// implementationName returns the name of the implementation impl of an
+// interface method, qualified by its receiver type. The methods of
+// interfaces declared within functions are qualified as by interfaceName.
+func implementationName(impl qualifiedObject) string {
+	if sig, ok := impl.obj.Type().(*types.Signature); ok && sig.Recv() != nil {
+		if named, ok := sig.Recv().Type().(*types.Named); ok && isLocal(named.Obj()) && IsInterface(named) {
+			return fmt.Sprintf("%s.%s", interfaceName(named.Obj()), impl.obj.Name())
+		}
+		return fmt.Sprintf("%s.%s", sig.Recv().Type().String(), impl.obj.Name())
+	}
+	return impl.obj.Name()
+}
+
 // concreteImplementsIntf returns true if a is an interface type implemented by
 // concrete type b, or vice versa.
 func concreteImplementsIntf(a, b types.Type) bool {
@@ -235,6 +460,31 @@ func qualifiedObjsAtProtocolPos(ctx context.Context, s Snapshot, uri span.URI, p
 	return qualifiedObjsAtLocation(ctx, s, positionKey{uri, offset}, map[positionKey]bool{})
 }
 
+// qualifiedObjVariants returns the variants of qo in all packages containing
+// its declaration, such as test variants, starting with qo itself. Renaming
+// all of them is necessary to update the references in every package, as
+// the references to a variant are found only in its reverse dependencies.
+func qualifiedObjVariants(ctx context.Context, s Snapshot, qo qualifiedObject) ([]qualifiedObject, error) {
+	if qo.pkg == nil {
+		return []qualifiedObject{qo}, nil
+	}
+	key, found := packagePositionKey(qo.pkg, qo.obj.Pos())
+	if !found {
+		return []qualifiedObject{qo}, nil
+	}
+	variants, err := qualifiedObjsAtLocation(ctx, s, key, make(map[positionKey]bool))
+	if err != nil {
+		return nil, err
+	}
+	result := []qualifiedObject{qo}
+	for _, v := range variants {
+		if v.obj != qo.obj {
+			result = append(result, v)
+		}
+	}
+	return result, nil
+}
+
 // A positionKey identifies a byte offset within a file (URI).
 //
 // When a file has been parsed multiple times in the same FileSet,
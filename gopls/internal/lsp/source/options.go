@@ -8,9 +8,11 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"path"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -53,6 +55,7 @@ import (
 	"golang.org/x/tools/gopls/internal/lsp/analysis/fillreturns"
 	"golang.org/x/tools/gopls/internal/lsp/analysis/fillstruct"
 	"golang.org/x/tools/gopls/internal/lsp/analysis/infertypeargs"
+	"golang.org/x/tools/gopls/internal/lsp/analysis/naming"
 	"golang.org/x/tools/gopls/internal/lsp/analysis/nonewvars"
 	"golang.org/x/tools/gopls/internal/lsp/analysis/noresultvalues"
 	"golang.org/x/tools/gopls/internal/lsp/analysis/simplifycompositelit"
@@ -142,6 +145,40 @@ func DefaultOptions() *Options {
 						ImportShortcut: Both,
 						SymbolMatcher:  SymbolFastFuzzy,
 						SymbolStyle:    DynamicSymbols,
+						RenameOptions: RenameOptions{
+							RenameIncludeImplementations: true,
+							RenameImplementationsScope:   WorkspaceImplementations,
+							RenameInComments:             true,
+							RenameCommentScope:           ProseComments,
+							RenameInStrings:              true,
+							RenameGeneratedFilePolicy:    AnnotateGenerated,
+							RenameMatching: map[MatchForm]bool{
+								ExactMatch:           true,
+								QualifiedMatch:       true,
+								CaseInsensitiveMatch: false,
+								DerivedMatch:         false,
+							},
+							RenameConfirmations: map[RenameGroup]bool{
+								ImplementationsGroup:       true,
+								AlmostImplementationsGroup: true,
+								SiblingsGroup:              true,
+								StringsGroup:               true,
+								TagsGroup:                  true,
+								AccessorsGroup:             true,
+								FilesGroup:                 true,
+								TextFilesGroup:             true,
+								CommentsGroup:              false,
+								GeneratedGroup:             true,
+								VendorGroup:                true,
+								ImportAliasesGroup:         false,
+								AssertionsGroup:            true,
+								DeprecationsGroup:          false,
+								FlagsGroup:                 true,
+								SubtestsGroup:              true,
+								TestdataGroup:              true,
+								RegenerateGroup:            false,
+							},
+						},
 					},
 					CompletionOptions: CompletionOptions{
 						Matcher:                        Fuzzy,
@@ -487,6 +524,198 @@ type NavigationOptions struct {
 	// }
 	// ```
 	SymbolStyle SymbolStyle `status:"advanced"`
+
+	RenameOptions
+}
+
+// RenameOptions holds the options of renaming. Besides their individual
+// names, they can be set together in a "rename" section whose keys are
+// their names without the "rename" prefix:
+//
+//	"rename": {"includeImplementations": false, "excludePaths": ["gen"]}
+type RenameOptions struct {
+	// RenameIncludeImplementations offers the renaming of the methods
+	// coupled by interfaces to a renamed method: the implementations of a
+	// renamed interface method, and the interface methods and sibling
+	// implementations of a renamed concrete method.
+	RenameIncludeImplementations bool `status:"experimental"`
+
+	// RenameImplementationsScope limits the implementations offered for
+	// renaming along with a method.
+	RenameImplementationsScope ImplementationsScope `status:"experimental"`
+
+	// RenameInComments updates the comments mentioning a renamed object,
+	// such as its doc comment and the doc links to it.
+	RenameInComments bool `status:"experimental"`
+
+	// RenameCommentScope controls which parts of the comments mentioning
+	// a renamed object are updated.
+	RenameCommentScope CommentScope `status:"experimental"`
+
+	// RenameInStrings offers the updating of the string literals that name
+	// a renamed object, such as reflective lookups, registrations by name,
+	// the messages of the errors of its package and the keys of its
+	// structured logging calls.
+	RenameInStrings bool `status:"experimental"`
+
+	// RenameMatching specifies the forms in which the updating of the
+	// comments and string literals mentioning a renamed object, such as
+	// its doc comment and the error messages of its package, matches its
+	// old name.
+	//
+	// Example Usage:
+	//
+	// ```json5
+	// "gopls": {
+	// ...
+	//   "renameMatching": {
+	//     "qualified": false,
+	//     "derived": true,
+	//   }
+	// ...
+	// }
+	// ```
+	RenameMatching map[MatchForm]bool `status:"experimental"`
+
+	// RenameGeneratedFilePolicy controls the renaming of references in
+	// generated files, which regenerating them may revert. The platform
+	// wrappers, such as zsyscall_*.go and zz_generated.*.go files, are
+	// renamed in any case, as their packages need them updated.
+	RenameGeneratedFilePolicy GeneratedFilePolicy `status:"experimental"`
+
+	// RenameExcludePaths lists the files that renames never edit. Each
+	// pattern is matched, as by path.Match, against the slash-separated
+	// paths relative to the workspace folder of the edited files and of
+	// their parent directories. A rename that must edit an excluded file
+	// fails; the optional edits of excluded files are dropped.
+	//
+	// Example Usage:
+	//
+	// ```json5
+	// "gopls": {
+	// ...
+	//   "renameExcludePaths": ["third_party", "api/*.pb.go"]
+	// ...
+	// }
+	// ```
+	RenameExcludePaths []string `status:"experimental"`
+
+	// RenameSkipTestFiles leaves the _test.go files unchanged by renames,
+	// reporting the test files that the rename would have edited, for
+	// refactorings of production code whose tests are updated separately.
+	RenameSkipTestFiles bool `status:"experimental"`
+
+	// RenameOpenFilesOnly restricts the edits of renames to the files open
+	// in the editor, for huge workspaces where changing thousands of files
+	// in one edit is undesirable. The occurrences left unchanged in the
+	// other files are listed by the gopls.rename_remainder command. A
+	// rename of a package that moves its directory fails instead if it
+	// would leave files unchanged, as their imports would break.
+	RenameOpenFilesOnly bool `status:"experimental"`
+
+	// RenameMaxPackages is the number of packages that the analysis of a
+	// rename may type check, beyond which the rename fails with a
+	// description of its scale rather than exhaust the memory of the
+	// machine. The limit applies to each set of packages that the
+	// analysis loads: the packages of the renamed object or package, the
+	// reverse dependencies of the packages it searches and all the known
+	// packages, of its view to find the methods coupled to a renamed
+	// method, such as its implementations, and of the other views to find
+	// the references to an exported object. Zero means no limit.
+	RenameMaxPackages int `status:"experimental"`
+
+	// RenameTextOccurrences enables the search of non-Go files of the
+	// workspace, such as Markdown documents, YAML configuration and shell
+	// scripts, for the qualified name or import path affected by a rename.
+	// Occurrences in documents and scripts are offered as optional edits,
+	// which the client must confirm.
+	RenameTextOccurrences bool `status:"experimental"`
+
+	// RenameNameSensitiveCalls lists functions and methods whose string
+	// arguments name Go objects that they look up dynamically, for example
+	// through reflection. Renaming an object passed by name to one of their
+	// calls warns of the call, which would otherwise break only at run time.
+	//
+	// Each element is of the form `path.Func` or `path.Type.Method`.
+	//
+	// Example Usage:
+	//
+	// ```json5
+	// "gopls": {
+	// ...
+	//   "renameNameSensitiveCalls": ["github.com/example/di.Container.Invoke"]
+	// ...
+	// }
+	// ```
+	RenameNameSensitiveCalls []string `status:"experimental"`
+
+	// RenameConfirmations specifies which groups of the optional edits of
+	// a rename need the user's confirmation. Clients that honor change
+	// annotations apply the edits of the other groups without asking.
+	//
+	// Example Usage:
+	//
+	// ```json5
+	// "gopls": {
+	// ...
+	//   "renameConfirmations": {
+	//     "implementations": true,
+	//     "accessors": false,
+	//   }
+	// ...
+	// }
+	// ```
+	RenameConfirmations map[RenameGroup]bool `status:"experimental"`
+
+	// RenameForce makes a rename that introduces conflicts, such as the
+	// shadowing of a reference, produce its edits instead of failing, as
+	// the -force flag of gorename does. The edits at the conflicting sites
+	// are annotated with a description of the conflict, for the user to
+	// confirm.
+	RenameForce bool `status:"experimental"`
+
+	// RenameAllowBreakingExternal lets the renames of exported objects
+	// referred to outside their edit scope, by the packages of the module
+	// cache or of other workspace folders, proceed. Otherwise, such renames
+	// fail, listing the references that they would break, to protect the
+	// users of a library from accidental changes of its API.
+	RenameAllowBreakingExternal bool `status:"experimental"`
+
+	// RenameMoveEachFile makes the renaming of a package directory move
+	// each file within it, its non-Go files such as testdata, READMEs, and
+	// SQL and template files included, for the clients that can rename
+	// files but not directories. The emptied directories are left behind.
+	// Otherwise, a single operation renames the whole directory.
+	RenameMoveEachFile bool `status:"experimental"`
+
+	// RenameFormat formats the Go files changed by a rename and fixes
+	// their imports, as goimports does, as part of the rename's edits.
+	// This tidies the import blocks left unsorted by the rewriting of
+	// import paths and the insertion of import names.
+	RenameFormat bool `status:"experimental"`
+
+	// RenameReceiverName is the name, such as "self", that the code action
+	// aligning the receiver names of the methods of a type offers, besides
+	// the first letter of the name of the type in lower case.
+	RenameReceiverName string `status:"experimental"`
+
+	// RenameImportAliases maps import paths to the local names that the
+	// renaming of a package to one of them gives to its imports in the files
+	// where the new package name is taken, instead of the new name followed
+	// by a number.
+	//
+	// Example Usage:
+	//
+	// ```json5
+	// "gopls": {
+	// ...
+	//   "renameImportAliases": {
+	//     "example.com/internal/log": "xlog",
+	//   }
+	// ...
+	// }
+	// ```
+	RenameImportAliases map[string]string `status:"experimental"`
 }
 
 // UserOptions holds custom Gopls configuration (not part of the LSP) that is
@@ -642,6 +871,70 @@ func (s ImportShortcut) ShowDefinition() bool {
 	return s == Both || s == Definition
 }
 
+type ImplementationsScope string
+
+const (
+	// WorkspaceImplementations offers the implementations declared in the
+	// workspace modules.
+	WorkspaceImplementations ImplementationsScope = "workspace"
+
+	// PackageImplementations offers only the implementations declared in
+	// the package of the renamed method.
+	PackageImplementations ImplementationsScope = "package"
+)
+
+type CommentScope string
+
+const (
+	// ProseComments updates the prose of comments only, leaving unchanged
+	// the mentions within code blocks, URLs, quoted text and directives,
+	// such as the examples embedded in doc comments.
+	ProseComments CommentScope = "prose"
+
+	// AllComments updates every mention within comments.
+	AllComments CommentScope = "all"
+
+	// SemanticComments updates only the mentions that resolve to the
+	// renamed object: doc links, qualified mentions such as pkg.Foo or
+	// T.Foo, and the leading name of its doc comment, for names that are
+	// also common words, such as New, Get or Run.
+	SemanticComments CommentScope = "semantic"
+)
+
+type MatchForm string
+
+const (
+	// ExactMatch matches the old name spelled exactly, as a whole word.
+	ExactMatch MatchForm = "exact"
+
+	// QualifiedMatch matches the old name qualified by the name of a
+	// package or type, as in pkg.Foo or T.Foo.
+	QualifiedMatch MatchForm = "qualified"
+
+	// CaseInsensitiveMatch matches the old name spelled in another case,
+	// such as foo or FOO for Foo, replaced by the new name in that case.
+	CaseInsensitiveMatch MatchForm = "caseInsensitive"
+
+	// DerivedMatch matches the names derived from the old name, such as
+	// foo_bar, foo-bar, fooBar and FOO_BAR for FooBar, replaced by the
+	// same forms of the new name.
+	DerivedMatch MatchForm = "derived"
+)
+
+type GeneratedFilePolicy string
+
+const (
+	// AnnotateGenerated offers the edits of generated files apart from the
+	// others, for the user to confirm.
+	AnnotateGenerated GeneratedFilePolicy = "annotate"
+
+	// EditGenerated edits generated files like the others.
+	EditGenerated GeneratedFilePolicy = "edit"
+
+	// SkipGenerated leaves generated files unchanged, with a warning.
+	SkipGenerated GeneratedFilePolicy = "skip"
+)
+
 type Matcher string
 
 const (
@@ -809,6 +1102,20 @@ func (o *Options) Clone() *Options {
 	}
 	result.Analyses = copyStringMap(o.Analyses)
 	result.Codelenses = copyStringMap(o.Codelenses)
+	result.RenameConfirmations = make(map[RenameGroup]bool)
+	for k, v := range o.RenameConfirmations {
+		result.RenameConfirmations[k] = v
+	}
+	result.RenameMatching = make(map[MatchForm]bool)
+	for k, v := range o.RenameMatching {
+		result.RenameMatching[k] = v
+	}
+	if o.RenameImportAliases != nil {
+		result.RenameImportAliases = make(map[string]string)
+		for k, v := range o.RenameImportAliases {
+			result.RenameImportAliases[k] = v
+		}
+	}
 
 	copySlice := func(src []string) []string {
 		dst := make([]string, len(src))
@@ -819,6 +1126,8 @@ func (o *Options) Clone() *Options {
 	result.BuildFlags = copySlice(o.BuildFlags)
 	result.DirectoryFilters = copySlice(o.DirectoryFilters)
 	result.StandaloneTags = copySlice(o.StandaloneTags)
+	result.RenameNameSensitiveCalls = copySlice(o.RenameNameSensitiveCalls)
+	result.RenameExcludePaths = copySlice(o.RenameExcludePaths)
 
 	copyAnalyzerMap := func(src map[string]*Analyzer) map[string]*Analyzer {
 		dst := make(map[string]*Analyzer)
@@ -1000,6 +1309,115 @@ func (o *Options) set(name string, value interface{}, seen map[string]struct{})
 	case "linkTarget":
 		result.setString(&o.LinkTarget)
 
+	case "renameTextOccurrences":
+		result.setBool(&o.RenameTextOccurrences)
+
+	case "renameNameSensitiveCalls":
+		if calls, ok := result.asStringSlice(); ok {
+			for _, call := range calls {
+				if _, err := parseCallMatcher(call); err != nil {
+					result.parseErrorf("%v", err)
+					return result
+				}
+			}
+			o.RenameNameSensitiveCalls = calls
+		}
+
+	case "renameForce":
+		result.setBool(&o.RenameForce)
+
+	case "renameAllowBreakingExternal":
+		result.setBool(&o.RenameAllowBreakingExternal)
+
+	case "renameMoveEachFile":
+		result.setBool(&o.RenameMoveEachFile)
+
+	case "renameFormat":
+		result.setBool(&o.RenameFormat)
+
+	case "renameReceiverName":
+		result.setString(&o.RenameReceiverName)
+
+	case "renameImportAliases":
+		aliases, ok := value.(map[string]interface{})
+		if !ok {
+			result.parseErrorf("invalid type %T, expect map", value)
+			break
+		}
+		o.RenameImportAliases = make(map[string]string)
+		for path, v := range aliases {
+			alias, ok := v.(string)
+			if !ok || !isValidIdentifier(alias) {
+				result.parseErrorf("invalid alias %v for import path %q", v, path)
+				continue
+			}
+			o.RenameImportAliases[path] = alias
+		}
+
+	case "renameConfirmations":
+		result.setRenameGroupMap(&o.RenameConfirmations)
+
+	case "renameIncludeImplementations":
+		result.setBool(&o.RenameIncludeImplementations)
+
+	case "renameImplementationsScope":
+		if s, ok := result.asOneOf(
+			string(WorkspaceImplementations),
+			string(PackageImplementations),
+		); ok {
+			o.RenameImplementationsScope = ImplementationsScope(s)
+		}
+
+	case "renameInComments":
+		result.setBool(&o.RenameInComments)
+
+	case "renameCommentScope":
+		if s, ok := result.asOneOf(
+			string(ProseComments),
+			string(AllComments),
+			string(SemanticComments),
+		); ok {
+			o.RenameCommentScope = CommentScope(s)
+		}
+
+	case "renameInStrings":
+		result.setBool(&o.RenameInStrings)
+
+	case "renameMatching":
+		result.setMatchFormMap(&o.RenameMatching)
+
+	case "renameGeneratedFilePolicy":
+		if s, ok := result.asOneOf(
+			string(AnnotateGenerated),
+			string(EditGenerated),
+			string(SkipGenerated),
+		); ok {
+			o.RenameGeneratedFilePolicy = GeneratedFilePolicy(s)
+		}
+
+	case "renameExcludePaths":
+		if patterns, ok := result.asStringSlice(); ok {
+			for _, pattern := range patterns {
+				if _, err := path.Match(pattern, ""); err != nil {
+					result.parseErrorf("invalid pattern %q: %v", pattern, err)
+					return result
+				}
+			}
+			o.RenameExcludePaths = patterns
+		}
+
+	case "renameSkipTestFiles":
+		result.setBool(&o.RenameSkipTestFiles)
+
+	case "renameOpenFilesOnly":
+		result.setBool(&o.RenameOpenFilesOnly)
+
+	case "renameMaxPackages":
+		result.setInt(&o.RenameMaxPackages)
+
+	case "rename":
+		o.setRenameSection(&result, seen)
+
 	case "linksInHover":
 		result.setBool(&o.LinksInHover)
 
@@ -1242,6 +1660,22 @@ func (r *OptionResult) setBool(b *bool) {
 	}
 }
 
+func (r *OptionResult) asInt() (int, bool) {
+	// JSON numbers are decoded as float64.
+	f, ok := r.Value.(float64)
+	if !ok || f != float64(int(f)) {
+		r.parseErrorf("invalid value %v, expect integer", r.Value)
+		return 0, false
+	}
+	return int(f), true
+}
+
+func (r *OptionResult) setInt(i *int) {
+	if v, ok := r.asInt(); ok {
+		*i = v
+	}
+}
+
 func (r *OptionResult) setDuration(d *time.Duration) {
 	if v, ok := r.asString(); ok {
 		parsed, err := time.ParseDuration(v)
@@ -1298,6 +1732,134 @@ func (r *OptionResult) setAnnotationMap(bm *map[Annotation]bool) {
 	*bm = m
 }
 
+// renameSettings holds the names of the settings of RenameOptions.
+var renameSettings = map[string]bool{
+	"renameIncludeImplementations": true,
+	"renameImplementationsScope":   true,
+	"renameInComments":             true,
+	"renameCommentScope":           true,
+	"renameInStrings":              true,
+	"renameMatching":               true,
+	"renameGeneratedFilePolicy":    true,
+	"renameExcludePaths":           true,
+	"renameSkipTestFiles":          true,
+	"renameOpenFilesOnly":          true,
+	"renameMaxPackages":            true,
+	"renameTextOccurrences":        true,
+	"renameNameSensitiveCalls":     true,
+	"renameConfirmations":          true,
+	"renameForce":                  true,
+	"renameAllowBreakingExternal":  true,
+	"renameMoveEachFile":           true,
+	"renameFormat":                 true,
+	"renameReceiverName":           true,
+	"renameImportAliases":          true,
+}
+
+// setRenameSection sets the rename options of the "rename" section of the
+// result, whose keys are the names of the settings of RenameOptions with or
+// without their "rename" prefix. The errors of its settings are reported
+// together.
+func (o *Options) setRenameSection(result *OptionResult, seen map[string]struct{}) {
+	section, ok := result.Value.(map[string]interface{})
+	if !ok {
+		result.parseErrorf("invalid type %T, expect map", result.Value)
+		return
+	}
+	keys := make([]string, 0, len(section))
+	for k := range section {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var errs []string
+	for _, k := range keys {
+		name := k
+		if !strings.HasPrefix(name, "rename") {
+			name = "rename" + strings.ToUpper(k[:1]) + k[1:]
+		}
+		if !renameSettings[name] {
+			errs = append(errs, fmt.Sprintf("unknown rename setting %q", k))
+			continue
+		}
+		if r := o.set(name, section[k], seen); r.Error != nil {
+			errs = append(errs, r.Error.Error())
+		}
+	}
+	if len(errs) > 0 {
+		result.parseErrorf("%s", strings.Join(errs, "; "))
+	}
+}
+
+// setRenameGroupMap overrides the entries of bm for the groups set by the
+// option, keeping the others.
+func (r *OptionResult) setRenameGroupMap(bm *map[RenameGroup]bool) {
+	all := r.asBoolMap()
+	if all == nil {
+		return
+	}
+	m := make(map[RenameGroup]bool)
+	for k, v := range *bm {
+		m[k] = v
+	}
+	for k, confirm := range all {
+		g, err := asOneOf(
+			k,
+			string(ImplementationsGroup),
+			string(AlmostImplementationsGroup),
+			string(SiblingsGroup),
+			string(StringsGroup),
+			string(TagsGroup),
+			string(AccessorsGroup),
+			string(FilesGroup),
+			string(TextFilesGroup),
+			string(CommentsGroup),
+			string(GeneratedGroup),
+			string(VendorGroup),
+			string(ImportAliasesGroup),
+			string(AssertionsGroup),
+			string(DeprecationsGroup),
+			string(FlagsGroup),
+			string(SubtestsGroup),
+			string(TestdataGroup),
+			string(RegenerateGroup),
+		)
+		if err != nil {
+			r.parseErrorf("%v", err)
+			continue
+		}
+		m[RenameGroup(g)] = confirm
+	}
+	*bm = m
+}
+
+// setMatchFormMap overrides the entries of bm for the forms set by the
+// option, keeping the others.
+func (r *OptionResult) setMatchFormMap(bm *map[MatchForm]bool) {
+	all := r.asBoolMap()
+	if all == nil {
+		return
+	}
+	m := make(map[MatchForm]bool)
+	for k, v := range *bm {
+		m[k] = v
+	}
+	for k, enabled := range all {
+		f, err := asOneOf(
+			k,
+			string(ExactMatch),
+			string(QualifiedMatch),
+			string(CaseInsensitiveMatch),
+			string(DerivedMatch),
+		)
+		if err != nil {
+			r.parseErrorf("%v", err)
+			continue
+		}
+		m[MatchForm(f)] = enabled
+	}
+	*bm = m
+}
+
 func (r *OptionResult) asBoolMap() map[string]bool {
 	all, ok := r.Value.(map[string]interface{})
 	if !ok {
@@ -1486,6 +2048,7 @@ func defaultAnalyzers() map[string]*Analyzer {
 		unusedparams.Analyzer.Name:     {Analyzer: unusedparams.Analyzer, Enabled: false},
 		unusedwrite.Analyzer.Name:      {Analyzer: unusedwrite.Analyzer, Enabled: false},
 		useany.Analyzer.Name:           {Analyzer: useany.Analyzer, Enabled: false},
+		naming.Analyzer.Name:           {Analyzer: naming.Analyzer, Enabled: false},
 		infertypeargs.Analyzer.Name:    {Analyzer: infertypeargs.Analyzer, Enabled: true},
 		embeddirective.Analyzer.Name:   {Analyzer: embeddirective.Analyzer, Enabled: true},
 		timeformat.Analyzer.Name:       {Analyzer: timeformat.Analyzer, Enabled: true},
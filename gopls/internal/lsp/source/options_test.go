@@ -156,6 +156,96 @@ func TestSetOption(t *testing.T) {
 				return len(o.DirectoryFilters) == 0
 			},
 		},
+		{
+			name:  "renameNameSensitiveCalls",
+			value: []interface{}{"example.com/di.Provide", "example.com/di.Container.Invoke"},
+			check: func(o Options) bool {
+				return len(o.RenameNameSensitiveCalls) == 2
+			},
+		},
+		{
+			name:      "renameNameSensitiveCalls",
+			value:     []interface{}{"example.com/di"},
+			wantError: true,
+			check: func(o Options) bool {
+				return len(o.RenameNameSensitiveCalls) == 0
+			},
+		},
+		{
+			name:  "renameConfirmations",
+			value: map[string]interface{}{"accessors": false},
+			check: func(o Options) bool {
+				confirm, ok := o.RenameConfirmations[AccessorsGroup]
+				return ok && !confirm
+			},
+		},
+		{
+			name:      "renameConfirmations",
+			value:     map[string]interface{}{"unknown": false},
+			wantError: true,
+			check: func(o Options) bool {
+				return len(o.RenameConfirmations) == 0
+			},
+		},
+		{
+			name:  "renameMaxPackages",
+			value: 500.0,
+			check: func(o Options) bool { return o.RenameMaxPackages == 500 },
+		},
+		{
+			name:      "renameMaxPackages",
+			value:     "500",
+			wantError: true,
+			check:     func(o Options) bool { return o.RenameMaxPackages == 0 },
+		},
+		{
+			name:  "renameMatching",
+			value: map[string]interface{}{"derived": true},
+			check: func(o Options) bool {
+				return o.RenameMatching[DerivedMatch] && !o.RenameMatching[CaseInsensitiveMatch]
+			},
+		},
+		{
+			name:      "renameMatching",
+			value:     map[string]interface{}{"fuzzy": true},
+			wantError: true,
+			check: func(o Options) bool {
+				return len(o.RenameMatching) == 0
+			},
+		},
+		{
+			name: "rename",
+			value: map[string]interface{}{
+				"includeImplementations": false,
+				"renameInComments":       false,
+				"generatedFilePolicy":    "skip",
+				"excludePaths":           []interface{}{"third_party"},
+			},
+			check: func(o Options) bool {
+				return !o.RenameIncludeImplementations && !o.RenameInComments &&
+					o.RenameGeneratedFilePolicy == SkipGenerated && len(o.RenameExcludePaths) == 1
+			},
+		},
+		{
+			name: "rename",
+			value: map[string]interface{}{
+				"implementationsScope": "everywhere",
+				"unknown":              true,
+				"inStrings":            true,
+			},
+			wantError: true,
+			check: func(o Options) bool {
+				return o.RenameImplementationsScope == "" && o.RenameInStrings
+			},
+		},
+		{
+			name:      "renameExcludePaths",
+			value:     []interface{}{"gen/["},
+			wantError: true,
+			check: func(o Options) bool {
+				return len(o.RenameExcludePaths) == 0
+			},
+		},
 		{
 			name: "annotations",
 			value: map[string]interface{}{
@@ -108,7 +108,7 @@ func References(ctx context.Context, s Snapshot, f FileHandle, pp protocol.Posit
 		return nil, err
 	}
 
-	refs, err := references(ctx, s, qualifiedObjs, includeDeclaration, true, false)
+	refs, err := references(ctx, s, qualifiedObjs, includeDeclaration, true, false, false)
 	if err != nil {
 		return nil, err
 	}
@@ -132,7 +132,9 @@ func References(ctx context.Context, s Snapshot, f FileHandle, pp protocol.Posit
 // if isDeclaration, the first result is an extra item for it.
 // Only the definition-related fields of qualifiedObject are used.
 // (Arguably it should accept a smaller data type.)
-func references(ctx context.Context, snapshot Snapshot, qos []qualifiedObject, includeDeclaration, includeInterfaceRefs, includeEmbeddedRefs bool) ([]*ReferenceInfo, error) {
+// If forRename, the ranges of the references are those that a rename
+// edits, as mapped by renameMappedRange.
+func references(ctx context.Context, snapshot Snapshot, qos []qualifiedObject, includeDeclaration, includeInterfaceRefs, includeEmbeddedRefs, forRename bool) ([]*ReferenceInfo, error) {
 	var (
 		references []*ReferenceInfo
 		seen       = make(map[positionKey]bool)
@@ -144,12 +146,27 @@ func references(ctx context.Context, snapshot Snapshot, qos []qualifiedObject, i
 	}
 	// Inv: qos[0].pkg != nil, since Pos is valid.
 	// Inv: qos[*].pkg != nil, since all qos are logically the same declaration.
-	filename := snapshot.FileSet().Position(pos).Filename
-	pgf, err := qos[0].pkg.File(span.URIFromPath(filename))
+	tokFile := snapshot.FileSet().File(pos)
+	pgf, err := qos[0].pkg.File(span.URIFromPath(tokFile.Position(pos).Filename))
+	if err != nil && forRename {
+		// The file named by a //line directive may not be part of the
+		// package, as when it is the grammar the file was generated from.
+		var physErr error
+		if pgf, physErr = qos[0].pkg.File(span.URIFromPath(tokFile.Name())); physErr == nil {
+			err = nil
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
+	mapRange := posToMappedRange
+	if forRename {
+		mapRange = renameMappedRange
+	}
 	declIdent, err := findIdentifier(ctx, snapshot, qos[0].pkg, pgf, qos[0].obj.Pos())
+	if err != nil && forRename {
+		declIdent, err = renameDeclIdentifier(snapshot, qos[0].pkg, pgf, qos[0].obj)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -174,6 +191,9 @@ func references(ctx context.Context, snapshot Snapshot, qos []qualifiedObject, i
 			if err != nil {
 				return nil, err
 			}
+			if reverseDeps, err = checkLocalReplacements(ctx, snapshot, reverseDeps); err != nil {
+				return nil, err
+			}
 			searchPkgs = append(searchPkgs, reverseDeps...)
 		}
 		// Add the package in which the identifier is declared.
@@ -208,7 +228,7 @@ func references(ctx context.Context, snapshot Snapshot, qos []qualifiedObject, i
 					continue
 				}
 				seen[key] = true
-				rng, err := posToMappedRange(snapshot.FileSet(), pkg, ident.Pos(), ident.End())
+				rng, err := mapRange(snapshot.FileSet(), pkg, ident.Pos(), ident.End())
 				if err != nil {
 					return nil, err
 				}
@@ -248,6 +268,45 @@ func references(ctx context.Context, snapshot Snapshot, qos []qualifiedObject, i
 	return references, nil
 }
 
+// checkLocalReplacements returns pkgs, with those of the modules replaced
+// by local directories, which are checked without their function bodies
+// as they lie outside the workspace, checked in full instead: the user
+// controls their source, so the references within them matter as much as
+// those of the workspace.
+func checkLocalReplacements(ctx context.Context, s Snapshot, pkgs []Package) ([]Package, error) {
+	for i, pkg := range pkgs {
+		if pkg.ParseMode() == ParseFull || len(pkg.CompiledGoFiles()) == 0 {
+			continue
+		}
+		uri := pkg.CompiledGoFiles()[0].URI
+		metas, err := s.MetadataForFile(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		if len(metas) == 0 || !isLocalReplacement(metas[0]) {
+			continue
+		}
+		full, err := s.PackagesForFile(ctx, uri, TypecheckFull, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range full {
+			if p.ID() == pkg.ID() {
+				pkgs[i] = p
+				break
+			}
+		}
+	}
+	return pkgs, nil
+}
+
+// isLocalReplacement reports whether m belongs to a module replaced by a
+// local directory, with a replace directive of a go.mod or go.work file.
+func isLocalReplacement(m Metadata) bool {
+	mod := m.ModuleInfo()
+	return mod != nil && mod.Replace != nil && mod.Replace.Version == "" && mod.Replace.Dir != ""
+}
+
 // equalOrigin reports whether obj1 and obj2 have equivalent origin object.
 // This may be the case even if obj1 != obj2, if one or both of them is
 // instantiated.
@@ -258,7 +317,7 @@ func equalOrigin(obj1, obj2 types.Object) bool {
 // interfaceReferences returns the references to the interfaces implemented by
 // the type or method at the given position.
 func interfaceReferences(ctx context.Context, s Snapshot, f FileHandle, pp protocol.Position) ([]*ReferenceInfo, error) {
-	implementations, err := implementations(ctx, s, f, pp)
+	implementations, err := implementations(ctx, s, f, pp, "")
 	if err != nil {
 		if errors.Is(err, ErrNotAType) {
 			return nil, nil
@@ -270,7 +329,7 @@ func interfaceReferences(ctx context.Context, s Snapshot, f FileHandle, pp proto
 	// since it treats the first qualifiedObject as a definition.
 	var refs []*ReferenceInfo
 	for _, impl := range implementations {
-		implRefs, err := references(ctx, s, []qualifiedObject{impl}, false, false, false)
+		implRefs, err := references(ctx, s, []qualifiedObject{impl}, false, false, false, false)
 		if err != nil {
 			return nil, err
 		}
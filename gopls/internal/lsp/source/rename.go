@@ -5,6 +5,7 @@
 package source
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -12,10 +13,11 @@ import (
 	"go/token"
 	"go/types"
 	"path"
-	"regexp"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"golang.org/x/tools/go/types/typeutil"
 	"golang.org/x/tools/gopls/internal/lsp/protocol"
@@ -23,33 +25,116 @@ import (
 	"golang.org/x/tools/gopls/internal/span"
 	"golang.org/x/tools/internal/diff"
 	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/typeparams"
 	"golang.org/x/tools/refactor/satisfy"
 )
 
 type renamer struct {
 	ctx                context.Context
+	snapshot           Snapshot
 	fset               *token.FileSet
 	refs               []*ReferenceInfo
 	objsToUpdate       map[types.Object]bool
 	hadConflicts       bool
 	errors             string
+	force              bool              // report conflicts instead of failing
+	conflicts          []*renameConflict // conflicts reported by errorf
 	from, to           string
 	satisfyConstraints map[satisfy.Constraint]bool
 	packages           map[*types.Package]Package // may include additional packages that are a dep of pkg.
 	msets              typeutil.MethodSetCache
 	changeMethods      bool
+	collisions         []*dotImportCollision       // collisions of r.to resolved in files that dot-import
+	qualifiedRefs      map[*ast.Ident]string       // the references qualified to resolve collisions, with their qualifiers
+	qualifiedFiles     map[span.URI]bool           // the files of qualifiedRefs
+	convertedImports   map[convertedDotImport]bool // the dot imports turned into regular imports to resolve collisions
+	cgoOnly            int                         // the number of references only in cgo-generated code, left unchanged
+}
+
+// A renameConflict is a conflict introduced by a renaming, such as the
+// shadowing of a reference, which a forced renaming reports along with the
+// edits at the positions it involves.
+type renameConflict struct {
+	msg   string                           // description, with the positions involved
+	pos   []token.Pos                      // positions involved
+	edits map[span.URI][]protocol.TextEdit // edits at pos
+
+	// resolved reports that edits resolve the conflict, which is thus no
+	// error: they are all those of a file in which the new name collides
+	// with another identifier, and that the renaming adjusts.
+	resolved bool
 }
 
 type PrepareItem struct {
 	Range protocol.Range
 	Text  string
+
+	// PackageModes lists, for a package clause, the modes in which the
+	// package can be renamed, the default first.
+	PackageModes []PackageRenameMode
+}
+
+// A PackageRenameMode is a way of renaming a package.
+type PackageRenameMode string
+
+const (
+	// DirectoryRename renames the package clauses of the package, the
+	// names and paths of its imports, and its directory.
+	DirectoryRename PackageRenameMode = "directory"
+	// ClauseRename renames only the package clauses of the package and
+	// the names of its imports, keeping its directory and import path.
+	ClauseRename PackageRenameMode = "clause"
+)
+
+// A RefusalReason is the code of the reason why PrepareRename refuses a
+// rename, for clients to act upon without parsing the error message.
+type RefusalReason string
+
+const (
+	// SyntaxErrorRefusal: the position lies in a region with syntax errors.
+	SyntaxErrorRefusal RefusalReason = "syntax_error"
+	// AmbiguousRefusal: the identifier denotes more than one object.
+	AmbiguousRefusal RefusalReason = "ambiguous"
+	// NoPackageRefusal: the file belongs to no package.
+	NoPackageRefusal RefusalReason = "no_package"
+	// MainPackageRefusal: the package is a main package.
+	MainPackageRefusal RefusalReason = "main_package"
+	// XTestPackageRefusal: the package is an x_test package.
+	XTestPackageRefusal RefusalReason = "x_test_package"
+	// NoModuleRefusal: the module of the package is unknown.
+	NoModuleRefusal RefusalReason = "no_module"
+	// PackageErrorRefusal: the package to rename failed to build.
+	PackageErrorRefusal RefusalReason = "package_error"
+	// EmbeddedFieldRefusal: the object is an embedded field.
+	EmbeddedFieldRefusal RefusalReason = "embedded_field"
+	// BlankRefusal: the identifier is blank.
+	BlankRefusal RefusalReason = "blank"
+)
+
+// A RefusalError is the error of a rename refused by PrepareRename, with
+// the code of its reason.
+type RefusalError struct {
+	Reason RefusalReason
+	Err    error
+}
+
+func (e *RefusalError) Error() string { return e.Err.Error() }
+func (e *RefusalError) Unwrap() error { return e.Err }
+
+// refuse returns the results of PrepareRename refusing a rename for
+// reason, with the user error err.
+func refuse(reason RefusalReason, err error) (*PrepareItem, error, error) {
+	err = &RefusalError{Reason: reason, Err: err}
+	return nil, err, err
 }
 
 // PrepareRename searches for a valid renaming at position pp.
 //
 // The returned usererr is intended to be displayed to the user to explain why
 // the prepare fails. Probably we could eliminate the redundancy in returning
-// two errors, but for now this is done defensively.
+// two errors, but for now this is done defensively. The errors of the
+// refusals of a rename, as opposed to failures to analyze it, are
+// *RefusalError values, which give the code of their reason.
 func PrepareRename(ctx context.Context, snapshot Snapshot, f FileHandle, pp protocol.Position) (_ *PrepareItem, usererr, err error) {
 	// Find position of the package name declaration.
 	ctx, done := event.Start(ctx, "source.PrepareRename")
@@ -58,65 +143,76 @@ func PrepareRename(ctx context.Context, snapshot Snapshot, f FileHandle, pp prot
 	if err != nil {
 		return nil, err, err
 	}
+	rs, rf, release, err := recoverSyntax(ctx, snapshot, f)
+	if err != nil {
+		return nil, err, err
+	}
+	defer release()
+	rpgf, err := rs.ParseGo(ctx, rf, ParseFull)
+	if err != nil {
+		return nil, err, err
+	}
+	if err := checkSyntax(pgf.ParseErr, rpgf, f, pp); err != nil {
+		return refuse(SyntaxErrorRefusal, err)
+	}
+	snapshot, f, pgf = rs, rf, rpgf
+	// A mention of an object in a comment is renamed as if the cursor were
+	// at the object's declaration.
+	mention, err := findCommentMention(ctx, snapshot, pgf, pp)
+	if err != nil {
+		return nil, err, err
+	}
+	if mention != nil {
+		fh, err := snapshot.GetFile(ctx, mention.uri)
+		if err != nil {
+			return nil, err, err
+		}
+		item, usererr, err := PrepareRename(ctx, snapshot, fh, mention.pos)
+		if item != nil {
+			item.Range = mention.rng
+		}
+		return item, usererr, err
+	}
 	inPackageName, err := isInPackageName(ctx, snapshot, f, pgf, pp)
 	if err != nil {
 		return nil, err, err
 	}
 
 	if inPackageName {
-		fileRenameSupported := false
-		for _, op := range snapshot.View().Options().SupportedResourceOperations {
-			if op == protocol.Rename {
-				fileRenameSupported = true
-				break
-			}
-		}
-
-		if !fileRenameSupported {
-			err := errors.New("can't rename package: LSP client does not support file renaming")
-			return nil, err, err
-		}
 		fileMeta, err := snapshot.MetadataForFile(ctx, f.URI())
 		if err != nil {
 			return nil, err, err
 		}
 
 		if len(fileMeta) == 0 {
-			err := fmt.Errorf("no packages found for file %q", f.URI())
-			return nil, err, err
+			return refuse(NoPackageRefusal, fmt.Errorf("no packages found for file %q", f.URI()))
 		}
 
 		meta := fileMeta[0]
 
 		if meta.PackageName() == "main" {
-			err := errors.New("can't rename package \"main\"")
-			return nil, err, err
+			return refuse(MainPackageRefusal, errors.New("can't rename package \"main\""))
 		}
 
 		if strings.HasSuffix(meta.PackageName(), "_test") {
-			err := errors.New("can't rename x_test packages")
-			return nil, err, err
+			return refuse(XTestPackageRefusal, errors.New("can't rename x_test packages"))
 		}
 
-		if meta.ModuleInfo() == nil {
-			err := fmt.Errorf("can't rename package: missing module information for package %q", meta.PackagePath())
-			return nil, err, err
+		modulePath, pkgPath, err := packageImportPaths(snapshot, meta, f.URI())
+		if err != nil {
+			return refuse(NoModuleRefusal, fmt.Errorf("can't rename package: %v", err))
 		}
 
-		if meta.ModuleInfo().Path == meta.PackagePath() {
-			err := fmt.Errorf("can't rename package: package path %q is the same as module path %q", meta.PackagePath(), meta.ModuleInfo().Path)
-			return nil, err, err
-		}
 		// TODO(rfindley): we should not need the package here.
 		pkg, err := snapshot.WorkspacePackageByID(ctx, meta.PackageID())
 		if err != nil {
-			err = fmt.Errorf("error building package to rename: %v", err)
-			return nil, err, err
+			return refuse(PackageErrorRefusal, fmt.Errorf("error building package to rename: %v", err))
 		}
 		result, err := computePrepareRenameResp(snapshot, pkg, pgf.File.Name, pkg.Name())
 		if err != nil {
 			return nil, nil, err
 		}
+		result.PackageModes = packageRenameModes(snapshot, modulePath, pkgPath)
 		return result, nil, nil
 	}
 
@@ -124,6 +220,9 @@ func PrepareRename(ctx context.Context, snapshot Snapshot, f FileHandle, pp prot
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := checkAmbiguous(snapshot, qos); err != nil {
+		return refuse(AmbiguousRefusal, err)
+	}
 	node, obj, pkg := qos[0].node, qos[0].obj, qos[0].sourcePkg
 	if err := checkRenamable(obj); err != nil {
 		return nil, nil, err
@@ -136,7 +235,7 @@ func PrepareRename(ctx context.Context, snapshot Snapshot, f FileHandle, pp prot
 }
 
 func computePrepareRenameResp(snapshot Snapshot, pkg Package, node ast.Node, text string) (*PrepareItem, error) {
-	mr, err := posToMappedRange(snapshot.FileSet(), pkg, node.Pos(), node.End())
+	mr, err := renameMappedRange(snapshot.FileSet(), pkg, node.Pos(), node.End())
 	if err != nil {
 		return nil, err
 	}
@@ -154,20 +253,551 @@ func computePrepareRenameResp(snapshot Snapshot, pkg Package, node ast.Node, tex
 	}, nil
 }
 
-// checkRenamable verifies if an obj may be renamed.
+// packageRenameModes returns the modes in which the package of the given
+// module and import paths can be renamed, the default first. The directory
+// of a package at the root of its module, or of any package if the client
+// can't rename files, can't be renamed.
+func packageRenameModes(s Snapshot, modulePath, pkgPath string) []PackageRenameMode {
+	if modulePath == pkgPath || !clientRenamesFiles(s) {
+		return []PackageRenameMode{ClauseRename}
+	}
+	return []PackageRenameMode{DirectoryRename, ClauseRename}
+}
+
+// clientRenamesFiles reports whether the client of s can rename files, and
+// thus directories, as part of a workspace edit.
+func clientRenamesFiles(s Snapshot) bool {
+	for _, op := range s.View().Options().SupportedResourceOperations {
+		if op == protocol.Rename {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRenamable verifies if an obj may be renamed. Its errors are
+// *RefusalError values.
 func checkRenamable(obj types.Object) error {
 	if v, ok := obj.(*types.Var); ok && v.Embedded() {
-		return errors.New("can't rename embedded fields: rename the type directly or name the field")
+		return &RefusalError{Reason: EmbeddedFieldRefusal, Err: errors.New("can't rename embedded fields: rename the type directly or name the field")}
 	}
 	if obj.Name() == "_" {
-		return errors.New("can't rename \"_\"")
+		return &RefusalError{Reason: BlankRefusal, Err: errors.New("can't rename \"_\"")}
 	}
 	return nil
 }
 
+// A RenameCandidate is one of the objects that a rename at a given position
+// could apply to.
+type RenameCandidate struct {
+	Name     string
+	Kind     string            // as reported by objectKind, e.g. "field"
+	Location protocol.Location // the declaration of the object
+	Err      error             // if non-nil, why the object can't be renamed
+}
+
+// RenameCandidates returns the distinct objects denoted by the identifier at
+// position pp, in order of preference.
+//
+// More than one candidate is returned if the position is ambiguous, as is
+// the case for an embedded field, which denotes both a field and a type. To
+// rename a particular candidate, clients request a rename at its Location.
+func RenameCandidates(ctx context.Context, snapshot Snapshot, f FileHandle, pp protocol.Position) ([]RenameCandidate, error) {
+	ctx, done := event.Start(ctx, "source.RenameCandidates")
+	defer done()
+
+	qos, err := qualifiedObjsAtProtocolPos(ctx, snapshot, f.URI(), pp)
+	if err != nil {
+		return nil, err
+	}
+	return renameCandidates(snapshot, qos)
+}
+
+func renameCandidates(snapshot Snapshot, qos []qualifiedObject) ([]RenameCandidate, error) {
+	var candidates []RenameCandidate
+	seen := make(map[token.Position]bool)
+	add := func(pkg Package, obj types.Object) error {
+		posn := snapshot.FileSet().Position(obj.Pos())
+		if seen[posn] {
+			return nil // e.g. the same object in a test variant
+		}
+		seen[posn] = true
+		rng, err := renameMappedRange(snapshot.FileSet(), pkg, obj.Pos(), objNameEnd(obj))
+		if err != nil {
+			return err
+		}
+		pr, err := rng.Range()
+		if err != nil {
+			return err
+		}
+		candidates = append(candidates, RenameCandidate{
+			Name:     obj.Name(),
+			Kind:     objectKind(obj),
+			Location: protocol.Location{URI: protocol.URIFromSpanURI(rng.URI()), Range: pr},
+			Err:      checkRenamable(obj),
+		})
+		return nil
+	}
+	for _, qo := range qos {
+		if err := add(qo.pkg, qo.obj); err != nil {
+			return nil, err
+		}
+		// An embedded field also denotes its type.
+		if v, ok := qo.obj.(*types.Var); ok && v.Embedded() {
+			if named, ok := Deref(v.Type()).(*types.Named); ok && named.Obj().Pkg() != nil {
+				tname := typeparams.NamedTypeOrigin(named).(*types.Named).Obj()
+				if err := add(qo.pkg, tname); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return candidates, nil
+}
+
+// checkAmbiguous returns an error describing the candidates if the objects
+// qos denote more than one distinct object.
+func checkAmbiguous(snapshot Snapshot, qos []qualifiedObject) error {
+	candidates, err := renameCandidates(snapshot, qos)
+	if err != nil || len(candidates) < 2 {
+		return err
+	}
+	// Each candidate gets a clause of its own, however many there are.
+	var descs []string
+	for _, c := range candidates {
+		desc := fmt.Sprintf("%s %s at %s:%d:%d", c.Kind, c.Name,
+			filepath.Base(c.Location.URI.SpanURI().Filename()),
+			c.Location.Range.Start.Line+1, c.Location.Range.Start.Character+1)
+		if c.Err != nil {
+			desc += ", which can't be renamed"
+		}
+		descs = append(descs, desc)
+	}
+	return fmt.Errorf("ambiguous rename of %q, which denotes %d objects: %s. Rename the intended object at its declaration",
+		candidates[0].Name, len(candidates), strings.Join(descs, "; "))
+}
+
+// A RenameGroup is a category of the optional edits of a rename. Whether
+// the edits of a group need the user's confirmation is configurable.
+type RenameGroup string
+
+const (
+	// ImplementationsGroup controls the renaming of the implementations
+	// of a renamed interface method.
+	ImplementationsGroup RenameGroup = "implementations"
+
+	// AlmostImplementationsGroup controls the renaming of the methods
+	// that have the name and signature of a renamed interface method but
+	// whose types do not implement the interface.
+	AlmostImplementationsGroup RenameGroup = "almost"
+
+	// SiblingsGroup controls the renaming of the methods coupled to a
+	// renamed concrete method by the interfaces it implements: those of
+	// the interfaces, and those of their other implementations.
+	SiblingsGroup RenameGroup = "siblings"
+
+	// StringsGroup controls the updating of names in string literals,
+	// such as reflective lookups, registrations by name, error messages
+	// and logging keys.
+	StringsGroup RenameGroup = "strings"
+
+	// TagsGroup controls the updating of struct tags, such as the
+	// database columns of renamed fields.
+	TagsGroup RenameGroup = "tags"
+
+	// AccessorsGroup controls the renaming of the accessors of a renamed
+	// field or property, including the methods of builder types.
+	AccessorsGroup RenameGroup = "accessors"
+
+	// FilesGroup controls the renaming of the files named after a renamed
+	// object.
+	FilesGroup RenameGroup = "files"
+
+	// TextFilesGroup controls the updating of occurrences in non-Go files.
+	TextFilesGroup RenameGroup = "text"
+
+	// CommentsGroup controls the updating of comments, such as the doc
+	// comments of renamed objects and the doc links to them.
+	CommentsGroup RenameGroup = "comments"
+
+	// GeneratedGroup controls the renaming of references within generated
+	// files, which their generator may overwrite.
+	GeneratedGroup RenameGroup = "generated"
+
+	// VendorGroup controls the renaming of the copies of a renamed object
+	// vendored in the modules of other workspace folders.
+	VendorGroup RenameGroup = "vendor"
+
+	// ImportAliasesGroup controls the local names given to the imports of
+	// a renamed package in the files where its new name is taken.
+	ImportAliasesGroup RenameGroup = "aliases"
+
+	// AssertionsGroup controls the renaming of the variables named after
+	// a renamed interface that assert that types implement it, and the
+	// updating of such assertions in generated files.
+	AssertionsGroup RenameGroup = "assertions"
+
+	// DeprecationsGroup controls the updating of the deprecation notices
+	// that refer to a renamed object by its bare name, such as
+	// "Deprecated: use Foo instead.".
+	DeprecationsGroup RenameGroup = "deprecations"
+
+	// FlagsGroup controls the renaming of the command-line flags and
+	// environment variables named after a renamed variable, which breaks
+	// the command lines and environments that set them.
+	FlagsGroup RenameGroup = "flags"
+
+	// SubtestsGroup controls the renaming of a renamed object in the
+	// names of the subtests of its package, such as t.Run("Foo", ...).
+	SubtestsGroup RenameGroup = "subtests"
+
+	// TestdataGroup controls the updating of the Go files under testdata
+	// directories, which are not loaded as packages, such as the inputs of
+	// the tests of analyzers: their imports of a renamed package and their
+	// references to a renamed package-level object.
+	TestdataGroup RenameGroup = "testdata"
+
+	// RegenerateGroup controls the renaming of references within platform
+	// wrappers, generated files such as zsyscall_*.go and
+	// zz_generated.*.go that the build needs updated, which are renamed
+	// whatever the generated file policy, with a recommendation to
+	// regenerate them.
+	RegenerateGroup RenameGroup = "regenerate"
+)
+
+// annotationID returns the identifier of the annotation name of a group.
+// Identifiers are of the form "group/name", or "group" for the annotation
+// of a group that has only one, so that clients can present the
+// annotations of a group together.
+func annotationID(group RenameGroup, name string) protocol.ChangeAnnotationIdentifier {
+	if name == "" {
+		return string(group)
+	}
+	return string(group) + "/" + name
+}
+
 type OptionalEdits struct {
 	Edits       map[span.URI][]protocol.TextEdit
 	Annotations map[protocol.ChangeAnnotationIdentifier]protocol.ChangeAnnotation
+	FileRenames []protocol.RenameFile // applied after Edits, which refer to the old names
+	Warnings    []string              // consequences of the rename that edits cannot address
+	Conflicts   []string              // conflicts introduced by a forced rename
+	Skipped     []SkippedImplementation
+	ExportData  []ExportDataPackage // importers that the rename cannot edit
+
+	confirmations map[RenameGroup]bool // groups needing confirmation; see Options.RenameConfirmations
+}
+
+// A SkippedImplementation is an implementation of a renamed interface
+// method that the rename leaves unchanged, as it lies outside the workspace
+// modules and the modules they replace by local directories.
+type SkippedImplementation struct {
+	Location protocol.Location // the zero value for predeclared methods
+	Name     string            // the qualified name of the method
+	Reason   string
+}
+
+// newOptionalEdits returns empty optional edits, whose annotations need
+// confirmation according to the options of snapshot s.
+func newOptionalEdits(s Snapshot) *OptionalEdits {
+	return &OptionalEdits{
+		Edits:         make(map[span.URI][]protocol.TextEdit),
+		Annotations:   make(map[protocol.ChangeAnnotationIdentifier]protocol.ChangeAnnotation),
+		confirmations: s.View().Options().RenameConfirmations,
+	}
+}
+
+// annotate records the annotation name of the given group, setting
+// whether it needs confirmation, and returns its identifier. Groups missing
+// from the options need confirmation.
+func (o *OptionalEdits) annotate(group RenameGroup, name string, annotation protocol.ChangeAnnotation) protocol.ChangeAnnotationIdentifier {
+	id := annotationID(group, name)
+	confirm, ok := o.confirmations[group]
+	annotation.NeedsConfirmation = confirm || !ok
+	o.Annotations[id] = annotation
+	return id
+}
+
+// addFileRename records the optional renaming of the file oldURI to the
+// file newName in the same directory, under an annotation of its own.
+func (o *OptionalEdits) addFileRename(oldURI span.URI, newName string) {
+	name := fmt.Sprint(len(o.FileRenames))
+	id := annotationID(FilesGroup, name)
+	newPath := filepath.Join(filepath.Dir(oldURI.Filename()), newName)
+	o.FileRenames = append(o.FileRenames, RenameSteps(protocol.RenameFile{
+		Kind:              "rename",
+		OldURI:            protocol.URIFromSpanURI(oldURI),
+		NewURI:            protocol.URIFromPath(newPath),
+		ResourceOperation: protocol.ResourceOperation{AnnotationID: id},
+	})...)
+	o.annotate(FilesGroup, name, protocol.ChangeAnnotation{
+		Label:       "Rename file",
+		Description: fmt.Sprintf("%s to %s", filepath.Base(oldURI.Filename()), newName),
+	})
+}
+
+// addAnnotatedEdits records edits under the annotation name of the given
+// group.
+func (o *OptionalEdits) addAnnotatedEdits(group RenameGroup, name string, annotation protocol.ChangeAnnotation, edits map[span.URI][]protocol.TextEdit) {
+	if len(edits) == 0 {
+		return
+	}
+	id := o.annotate(group, name, annotation)
+	for uri, e := range edits {
+		for _, te := range e {
+			te.AnnotationID = id
+			o.Edits[uri] = append(o.Edits[uri], te)
+		}
+	}
+}
+
+// separateEdits applies the options of snapshot s to the edits of result
+// that lie in generated files or in comments. If annotate is set, it moves
+// the edits of generated files, and those of comments in other files, to
+// annotated groups of their own, so that clients can review them apart from
+// the renaming of the code; the edits of generated files that lie within
+// assertions, those of a renamed interface, have a group of their own, and
+// so do those of platform wrappers, which are never skipped. The edits of
+// the file declaring the renamed object are never considered generated.
+func (o *OptionalEdits) separateEdits(ctx context.Context, s Snapshot, result map[span.URI][]protocol.TextEdit, declURI span.URI, assertions []interfaceAssertion, annotate bool) error {
+	opts := s.View().Options()
+	comments := make(map[span.URI][]protocol.TextEdit)
+	generated := make(map[span.URI][]protocol.TextEdit)
+	asserting := make(map[span.URI][]protocol.TextEdit)
+	var generatedFiles, assertionFiles, skippedFiles []string
+	wrappers, err := o.separateWrappers(ctx, s, result, declURI, annotate)
+	if err != nil {
+		return err
+	}
+	for uri, edits := range result {
+		if wrappers[uri] {
+			continue
+		}
+		if !SamePath(uri.Filename(), declURI.Filename()) && opts.RenameGeneratedFilePolicy != EditGenerated && IsGenerated(ctx, s, uri) {
+			if opts.RenameGeneratedFilePolicy == SkipGenerated {
+				skippedFiles = append(skippedFiles, filepath.Base(uri.Filename()))
+				delete(result, uri)
+				continue
+			}
+			if annotate {
+				for _, te := range edits {
+					if inAssertion(assertions, uri, te) {
+						asserting[uri] = append(asserting[uri], te)
+					} else {
+						generated[uri] = append(generated[uri], te)
+					}
+				}
+				if len(generated[uri]) > 0 {
+					generatedFiles = append(generatedFiles, filepath.Base(uri.Filename()))
+				}
+				if len(asserting[uri]) > 0 {
+					assertionFiles = append(assertionFiles, filepath.Base(uri.Filename()))
+				}
+				delete(result, uri)
+				continue
+			}
+		}
+		if !annotate && opts.RenameInComments {
+			continue
+		}
+		fh, err := s.GetFile(ctx, uri)
+		if err != nil {
+			return err
+		}
+		pgf, err := s.ParseGo(ctx, fh, ParseFull)
+		if err != nil {
+			return err
+		}
+		var code []protocol.TextEdit
+		for _, te := range edits {
+			pos, err := pgf.Mapper.Pos(te.Range.Start)
+			if err != nil {
+				return err
+			}
+			if !inComment(pgf.File, pos) {
+				code = append(code, te)
+			} else if opts.RenameInComments {
+				comments[uri] = append(comments[uri], te)
+			}
+		}
+		if !annotate {
+			code = append(code, comments[uri]...)
+			delete(comments, uri)
+		}
+		if len(code) > 0 {
+			result[uri] = code
+		} else {
+			delete(result, uri)
+		}
+	}
+	if len(skippedFiles) > 0 {
+		sort.Strings(skippedFiles)
+		o.Warnings = append(o.Warnings, fmt.Sprintf("generated files are not renamed: %s", strings.Join(skippedFiles, ", ")))
+	}
+	o.addAnnotatedEdits(CommentsGroup, "", protocol.ChangeAnnotation{
+		Label:       "Rename in comments",
+		Description: fmt.Sprintf("%d mentions in doc comments and doc links", countEdits(comments)),
+	}, comments)
+	sort.Strings(generatedFiles)
+	o.addAnnotatedEdits(GeneratedGroup, "", protocol.ChangeAnnotation{
+		Label:       "Rename in generated files",
+		Description: fmt.Sprintf("%s, which regenerating may revert", strings.Join(generatedFiles, ", ")),
+	}, generated)
+	sort.Strings(assertionFiles)
+	o.addAnnotatedEdits(AssertionsGroup, "generated", protocol.ChangeAnnotation{
+		Label:       "Rename in generated assertions",
+		Description: fmt.Sprintf("%d compile-time assertions in %s, which regenerating may revert", countEdits(asserting), strings.Join(assertionFiles, ", ")),
+	}, asserting)
+	return nil
+}
+
+// excludePaths applies the excluded paths of the options of s to a
+// rename: it fails if the required edits of result touch an excluded file,
+// and drops the optional edits and file renamings of excluded files, along
+// with the annotations left without edits.
+func (o *OptionalEdits) excludePaths(s Snapshot, result map[span.URI][]protocol.TextEdit) error {
+	patterns := s.View().Options().RenameExcludePaths
+	if len(patterns) == 0 {
+		return nil
+	}
+	excluded := func(uri span.URI) (string, bool) {
+		rel, ok := relPath(s.View().Folder().Filename(), uri.Filename())
+		if !ok {
+			return "", false
+		}
+		rel = filepath.ToSlash(rel)
+		for dir := rel; dir != "."; dir = path.Dir(dir) {
+			for _, pattern := range patterns {
+				if ok, _ := path.Match(pattern, dir); ok {
+					return rel, true
+				}
+			}
+		}
+		return "", false
+	}
+
+	var required []string
+	for uri := range result {
+		if rel, ok := excluded(uri); ok {
+			required = append(required, rel)
+		}
+	}
+	if len(required) > 0 {
+		sort.Strings(required)
+		return fmt.Errorf("renaming requires editing excluded files: %s", strings.Join(required, ", "))
+	}
+
+	for uri := range o.Edits {
+		if _, ok := excluded(uri); ok {
+			delete(o.Edits, uri)
+		}
+	}
+	o.dropFileRenames(func(uri span.URI) bool {
+		_, ok := excluded(uri)
+		return ok
+	})
+	o.pruneAnnotations()
+	return nil
+}
+
+// dropFileRenames drops the file renamings of the files for which drop
+// returns true, along with the other steps of their renamings, which share
+// their annotations; see RenameSteps.
+func (o *OptionalEdits) dropFileRenames(drop func(span.URI) bool) {
+	dropped := make(map[protocol.ChangeAnnotationIdentifier]bool)
+	for _, fr := range o.FileRenames {
+		if drop(fr.OldURI.SpanURI()) {
+			dropped[fr.AnnotationID] = true
+		}
+	}
+	renames := o.FileRenames[:0]
+	for _, fr := range o.FileRenames {
+		if !dropped[fr.AnnotationID] {
+			renames = append(renames, fr)
+		}
+	}
+	o.FileRenames = renames
+}
+
+// pruneAnnotations deletes the annotations left without edits or file
+// renamings.
+func (o *OptionalEdits) pruneAnnotations() {
+	used := make(map[protocol.ChangeAnnotationIdentifier]bool)
+	for _, edits := range o.Edits {
+		for _, te := range edits {
+			used[te.AnnotationID] = true
+		}
+	}
+	for _, fr := range o.FileRenames {
+		used[fr.AnnotationID] = true
+	}
+	for id := range o.Annotations {
+		if !used[id] {
+			delete(o.Annotations, id)
+		}
+	}
+}
+
+// skipTestFiles removes the edits of the _test.go files from result and
+// from the optional edits if the options of s skip test files, warning of
+// the files left unchanged. It fails if the renamed object is declared in
+// the test file declURI.
+func (o *OptionalEdits) skipTestFiles(s Snapshot, result map[span.URI][]protocol.TextEdit, declURI span.URI) error {
+	if !s.View().Options().RenameSkipTestFiles {
+		return nil
+	}
+	isTest := func(uri span.URI) bool {
+		return strings.HasSuffix(uri.Filename(), "_test.go")
+	}
+	if declURI != "" && isTest(declURI) {
+		return fmt.Errorf("cannot rename an object declared in test file %s when skipping test files", filepath.Base(declURI.Filename()))
+	}
+	skipped := make(map[string]bool)
+	for _, edits := range []map[span.URI][]protocol.TextEdit{result, o.Edits} {
+		for uri := range edits {
+			if isTest(uri) {
+				skipped[filepath.Base(uri.Filename())] = true
+				delete(edits, uri)
+			}
+		}
+	}
+	o.dropFileRenames(func(uri span.URI) bool {
+		if isTest(uri) {
+			skipped[filepath.Base(uri.Filename())] = true
+			return true
+		}
+		return false
+	})
+	if len(skipped) == 0 {
+		return nil
+	}
+	o.pruneAnnotations()
+	var names []string
+	for name := range skipped {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	o.Warnings = append(o.Warnings, fmt.Sprintf("test files are not renamed: %s", strings.Join(names, ", ")))
+	return nil
+}
+
+// inComment reports whether pos lies within a comment of f.
+func inComment(f *ast.File, pos token.Pos) bool {
+	for _, cg := range f.Comments {
+		if cg.Pos() <= pos && pos < cg.End() {
+			return true
+		}
+	}
+	return false
+}
+
+// countEdits returns the number of edits in edits.
+func countEdits(edits map[span.URI][]protocol.TextEdit) int {
+	n := 0
+	for _, e := range edits {
+		n += len(e)
+	}
+	return n
 }
 
 // Rename returns a map of TextEdits for each file modified when renaming a
@@ -177,101 +807,755 @@ func Rename(ctx context.Context, s Snapshot, f FileHandle, pp protocol.Position,
 	ctx, done := event.Start(ctx, "source.Rename")
 	defer done()
 
+	return renameTidied(ctx, s, f, pp, newName, s.View().Options().SupportChangeAnnotations)
+}
+
+// RenameAnnotated is like Rename, but separates the optional edits into
+// annotated groups regardless of the capabilities of the client, for the
+// caller to select among them.
+func RenameAnnotated(ctx context.Context, s Snapshot, f FileHandle, pp protocol.Position, newName string) (map[span.URI][]protocol.TextEdit, *OptionalEdits, bool, error) {
+	ctx, done := event.Start(ctx, "source.RenameAnnotated")
+	defer done()
+
+	return renameTidied(ctx, s, f, pp, newName, true)
+}
+
+// renameTidied implements Rename and RenameAnnotated.
+func renameTidied(ctx context.Context, s Snapshot, f FileHandle, pp protocol.Position, newName string, annotate bool) (map[span.URI][]protocol.TextEdit, *OptionalEdits, bool, error) {
+	edits, optional, isPkg, err := rename(ctx, s, f, pp, newName, s.View().Options().RenameForce, annotate)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	canonicalizeRenameURIs(s, edits, optional)
+	if err := tidyRenamedFiles(ctx, s, edits, optional); err != nil {
+		return nil, nil, false, err
+	}
+	return edits, optional, isPkg, nil
+}
+
+// RenamePackage returns the edits renaming the package of the file f to
+// newName in the given mode, which must be one of those that PrepareRename
+// reports at its package clause.
+func RenamePackage(ctx context.Context, s Snapshot, f FileHandle, newName string, mode PackageRenameMode) (map[span.URI][]protocol.TextEdit, *OptionalEdits, error) {
+	ctx, done := event.Start(ctx, "source.RenamePackage")
+	defer done()
+
+	edits, optional, err := renamePackageAt(ctx, s, f, newName, mode, s.View().Options().SupportChangeAnnotations)
+	if err != nil {
+		return nil, nil, err
+	}
+	canonicalizeRenameURIs(s, edits, optional)
+	if err := tidyRenamedFiles(ctx, s, edits, optional); err != nil {
+		return nil, nil, err
+	}
+	return edits, optional, nil
+}
+
+// PackageRenameModes returns the modes in which the package of the file f
+// can be renamed, the default first.
+func PackageRenameModes(ctx context.Context, s Snapshot, f FileHandle) ([]PackageRenameMode, error) {
+	fileMeta, err := s.MetadataForFile(ctx, f.URI())
+	if err != nil {
+		return nil, err
+	}
+	if len(fileMeta) == 0 {
+		return nil, fmt.Errorf("no packages found for file %q", f.URI())
+	}
+	modulePath, pkgPath, err := packageImportPaths(s, fileMeta[0], f.URI())
+	if err != nil {
+		return nil, err
+	}
+	return packageRenameModes(s, modulePath, pkgPath), nil
+}
+
+// tidyRenamedFiles formats the Go files changed by the edits of a rename,
+// or aligns them, according to the renameFormat setting.
+func tidyRenamedFiles(ctx context.Context, s Snapshot, edits map[span.URI][]protocol.TextEdit, optional *OptionalEdits) error {
+	if s.View().Options().RenameFormat {
+		return formatRenamedFiles(ctx, s, edits, optional)
+	}
+	return alignRenamedFiles(ctx, s, edits, optional)
+}
+
+// rename implements Rename. If force is set, conflicts do not make it fail.
+// If annotate is set, the edits in comments and generated files, and those
+// involved in conflicts, are optional.
+func rename(ctx context.Context, s Snapshot, f FileHandle, pp protocol.Position, newName string, force, annotate bool) (map[span.URI][]protocol.TextEdit, *OptionalEdits, bool, error) {
 	pgf, err := s.ParseGo(ctx, f, ParseFull)
 	if err != nil {
 		return nil, nil, false, err
 	}
+	rs, rf, release, err := recoverSyntax(ctx, s, f)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	defer release()
+	rpgf, err := rs.ParseGo(ctx, rf, ParseFull)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if err := checkSyntax(pgf.ParseErr, rpgf, f, pp); err != nil {
+		return nil, nil, false, err
+	}
+	s, f, pgf = rs, rf, rpgf
+	mention, err := findCommentMention(ctx, s, pgf, pp)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if mention != nil {
+		fh, err := s.GetFile(ctx, mention.uri)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		return rename(ctx, s, fh, mention.pos, newName, force, annotate)
+	}
 	inPackageName, err := isInPackageName(ctx, s, f, pgf, pp)
 	if err != nil {
 		return nil, nil, false, err
 	}
 
 	if inPackageName {
-		if !isValidIdentifier(newName) {
-			return nil, nil, true, fmt.Errorf("%q is not a valid identifier", newName)
+		edits, optional, err := renamePackageAt(ctx, s, f, newName, "", annotate)
+		return edits, optional, true, err
+	}
+
+	qos, err := qualifiedObjsAtProtocolPos(ctx, s, f.URI(), pp)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if err := checkAmbiguous(s, qos); err != nil {
+		return nil, nil, false, err
+	}
+	obj := qos[0].obj
+	if err := checkRenameScale(ctx, s, obj.Name(), qualifiedObjPackageIDs(qos), obj.Exported() && !isLocal(obj)); err != nil {
+		return nil, nil, false, err
+	}
+	// Packages that cannot be loaded, but cannot refer to the object
+	// either, do not prevent its renaming.
+	tolerant := newLoadTolerantSnapshot(s, qos[0].obj.Name())
+	s = tolerant
+	result, conflicts, cgoOnly, err := forceRenameObj(ctx, s, newName, qos, false, force)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	// A method of an anonymous interface type is renamed along with those
+	// of the identical anonymous interface types, such as the types of the
+	// parameters to which it is passed.
+	twins, err := anonymousInterfaceTwins(ctx, s, qos[0])
+	if err != nil {
+		return nil, nil, false, err
+	}
+	for _, twin := range twins {
+		variants, err := qualifiedObjVariants(ctx, s, twin)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		edits, err := renameObj(ctx, s, newName, variants, true)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		for uri, e := range newEdits(edits, result) {
+			result[uri] = append(result[uri], e...)
+		}
+	}
+	optional := newOptionalEdits(s)
+	// The files of the importers loaded only from export data cannot be
+	// edited, nor their references all found.
+	if obj.Exported() && obj.Pkg() != nil {
+		var ids []string
+		for _, qo := range qos {
+			ids = append(ids, qo.pkg.ID())
 		}
+		exportData, uris, err := exportDataPackages(ctx, s, obj.Name(), ids, obj.Pkg().Path(), obj)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		for uri := range uris {
+			delete(result, uri)
+		}
+		optional.addExportDataPackages(obj.Name(), exportData)
+	}
+	declPos := qos[0].obj.Pos()
+	declURI, _, _, err := editRange(s, s.FileSet().File(declPos), declPos, declPos)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	assertions, err := interfaceAssertions(ctx, s, qos[0])
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if err := optional.separateEdits(ctx, s, result, declURI, assertions, annotate); err != nil {
+		return nil, nil, false, err
+	}
+	generated, err := lineDirectiveSources(ctx, s, result)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if len(generated) > 0 {
+		optional.Warnings = append(optional.Warnings, "files generated with //line directives are renamed, but not their sources: "+strings.Join(generated, ", "))
+	}
+	if cgoOnly > 0 {
+		optional.Warnings = append(optional.Warnings, fmt.Sprintf("references only in the code generated by cgo are not renamed (%d)", cgoOnly))
+	}
+	// The edits involved in the conflicts of a forced renaming always need
+	// confirmation, if the client supports it.
+	const conflictGroup RenameGroup = "conflict"
+	const collisionGroup RenameGroup = "collision"
+	for i, c := range conflicts {
+		if c.resolved {
+			if !annotate {
+				for uri, edits := range c.edits {
+					result[uri] = append(result[uri], edits...)
+				}
+				continue
+			}
+			optional.addAnnotatedEdits(collisionGroup, fmt.Sprint(i), protocol.ChangeAnnotation{
+				Label:       "Resolve name collision",
+				Description: c.msg,
+			}, c.edits)
+			continue
+		}
+		optional.Warnings = append(optional.Warnings, "conflict: "+c.msg)
+		optional.Conflicts = append(optional.Conflicts, c.msg)
+		if !annotate {
+			for uri, edits := range c.edits {
+				result[uri] = append(result[uri], edits...)
+			}
+			continue
+		}
+		optional.addAnnotatedEdits(conflictGroup, fmt.Sprint(i), protocol.ChangeAnnotation{
+			Label:       "Rename despite conflict",
+			Description: c.msg,
+		}, c.edits)
+	}
+	opts := s.View().Options()
+	switch {
+	case !opts.RenameIncludeImplementations:
+		// The methods coupled to the renamed one are not offered.
 
-		fileMeta, err := s.MetadataForFile(ctx, f.URI())
+	// If renaming interface signature, then use optional annotation for interface implementations edits
+	case isInterfaceSignature(qos[0].obj):
+		impls, err := implementations(ctx, s, f, pp, qos[0].obj.Name())
+		if err != nil {
+			return nil, nil, false, err
+		}
+		method := qos[0].obj.(*types.Func)
+		intfs, err := interfacesWithMethod(ctx, s, method.Name())
 		if err != nil {
-			return nil, nil, true, err
+			return nil, nil, false, err
+		}
+		var (
+			shared  []string
+			inScope []qualifiedObject
+		)
+		for _, impl := range impls {
+			if !inImplementationsScope(s, impl, method) {
+				continue
+			}
+			reason := excludedImplementation(ctx, s, impl)
+			if reason == "" {
+				inScope = append(inScope, impl)
+				continue
+			}
+			skipped := SkippedImplementation{Name: implementationName(impl), Reason: reason}
+			if impl.pkg != nil {
+				rng, err := renameMappedRange(s.FileSet(), impl.pkg, impl.obj.Pos(), objNameEnd(impl.obj))
+				if err != nil {
+					return nil, nil, false, err
+				}
+				if skipped.Location.Range, err = rng.Range(); err != nil {
+					return nil, nil, false, err
+				}
+				skipped.Location.URI = protocol.URIFromSpanURI(rng.URI())
+			}
+			optional.Skipped = append(optional.Skipped, skipped)
+		}
+		if len(optional.Skipped) > 0 {
+			sort.Slice(optional.Skipped, func(i, j int) bool {
+				return optional.Skipped[i].Name < optional.Skipped[j].Name
+			})
+			var names []string
+			for _, skipped := range optional.Skipped {
+				names = append(names, fmt.Sprintf("%s (%s)", skipped.Name, skipped.Reason))
+			}
+			optional.Warnings = append(optional.Warnings, fmt.Sprintf("implementations outside the workspace are not renamed: %s", strings.Join(names, ", ")))
+		}
+		for implID, impl := range inScope {
+			variants, err := qualifiedObjVariants(ctx, s, impl)
+			if err != nil {
+				return nil, nil, false, err
+			}
+			subResult, err := renameObj(ctx, s, newName, variants, true)
+			if err != nil {
+				return nil, nil, false, err
+			}
+			name := implementationName(impl)
+			// Implementations of other interfaces are grouped separately,
+			// as renaming them breaks those interfaces' implementations.
+			annotation := protocol.ChangeAnnotation{
+				Label:       "Rename implementations",
+				Description: name,
+			}
+			if others := otherImplementedInterfaces(s, impl.obj, method, intfs); len(others) > 0 {
+				annotation.Label = "Rename implementations of other interfaces"
+				annotation.Description = fmt.Sprintf("%s, which also implements %s", name, strings.Join(others, ", "))
+				shared = append(shared, name)
+			}
+			optional.addAnnotatedEdits(ImplementationsGroup, fmt.Sprint(implID), annotation, subResult)
+		}
+		if len(shared) > 0 {
+			optional.Warnings = append(optional.Warnings, fmt.Sprintf("%s also implement other interfaces with a method %s, which renaming them would break",
+				strings.Join(shared, ", "), method.Name()))
 		}
 
-		if len(fileMeta) == 0 {
-			return nil, nil, true, fmt.Errorf("no packages found for file %q", f.URI())
+		// Offer to rename the methods of the types that almost implement
+		// the interface, separately from its implementations.
+		almost, err := almostImplementations(ctx, s, method, impls)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		i := 0
+		for _, m := range almost {
+			if !inImplementationsScope(s, m, method) || excludedImplementation(ctx, s, m) != "" {
+				continue
+			}
+			variants, err := qualifiedObjVariants(ctx, s, m)
+			if err != nil {
+				return nil, nil, false, err
+			}
+			subResult, err := renameObj(ctx, s, newName, variants, true)
+			if err != nil {
+				return nil, nil, false, err
+			}
+			recv := m.obj.Type().(*types.Signature).Recv().Type().String()
+			optional.addAnnotatedEdits(AlmostImplementationsGroup, fmt.Sprint(i), protocol.ChangeAnnotation{
+				Label:       "Rename methods almost implementing the interface",
+				Description: fmt.Sprintf("%s.%s, which has the signature of %s but whose type does not implement its interface", recv, m.obj.Name(), method.FullName()),
+			}, subResult)
+			i++
 		}
+	default:
+		// Offer to rename the methods coupled to a renamed concrete method
+		// by the interfaces it implements, as renaming one implementation
+		// usually implies that the interface and its other implementations
+		// follow.
+		abstract, siblings, err := siblingMethods(ctx, s, qos[0])
+		if err != nil {
+			return nil, nil, false, err
+		}
+		renamed := []map[span.URI][]protocol.TextEdit{result}
+		i := 0
+		for _, sm := range append(abstract, siblings...) {
+			if !inImplementationsScope(s, sm.qo, qos[0].obj) || excludedImplementation(ctx, s, sm.qo) != "" {
+				continue
+			}
+			variants, err := qualifiedObjVariants(ctx, s, sm.qo)
+			if err != nil {
+				return nil, nil, false, err
+			}
+			name := implementationName(sm.qo)
+			edits, err := renameObj(ctx, s, newName, variants, true)
+			if err != nil {
+				optional.Warnings = append(optional.Warnings, fmt.Sprintf("cannot rename %s to %s: %v", name, newName, err))
+				continue
+			}
+			edits = newEdits(edits, renamed...)
+			renamed = append(renamed, edits)
+			annotation := protocol.ChangeAnnotation{
+				Label:       "Rename sibling implementations",
+				Description: fmt.Sprintf("%s, which also implements %s", name, strings.Join(sm.intfs, ", ")),
+			}
+			if IsInterface(sm.qo.obj.Type().(*types.Signature).Recv().Type()) {
+				annotation.Label = "Rename interface method"
+				annotation.Description = fmt.Sprintf("%s, which %s implements", name, implementationName(qos[0]))
+			}
+			optional.addAnnotatedEdits(SiblingsGroup, fmt.Sprint(i), annotation, edits)
+			i++
+		}
+	}
 
-		// We need metadata for the relevant package and module paths. These should
-		// be the same for all packages containing the file.
-		//
-		// TODO(rfindley): we mix package path and import path here haphazardly.
-		// Fix this.
-		meta := fileMeta[0]
-		oldPath := meta.PackagePath()
-		var modulePath string
-		if mi := meta.ModuleInfo(); mi == nil {
-			return nil, nil, true, fmt.Errorf("cannot rename package: missing module information for package %q", meta.PackagePath())
-		} else {
-			modulePath = mi.Path
+	// Offer to rename the files named after the renamed object.
+	fileRenames, err := namesakeFileRenames(s, qos[0], newName)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	for _, fr := range fileRenames {
+		optional.addFileRename(fr.uri, fr.newName)
+	}
+
+	registrations, err := findRegistrations(ctx, s, qos[0], newName)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if opts.RenameInStrings {
+		// Offer to rename the names of members looked up through reflection,
+		// which would otherwise break only at run time.
+		reflectEdits, err := reflectiveNameEdits(ctx, s, qos[0], newName)
+		if err != nil {
+			return nil, nil, false, err
 		}
+		optional.addAnnotatedEdits(StringsGroup, "reflect", protocol.ChangeAnnotation{
+			Label:       "Rename reflective accesses",
+			Description: fmt.Sprintf("%d lookups of %q through package reflect, which fail at run time if not renamed", countEdits(reflectEdits), qos[0].obj.Name()),
+		}, reflectEdits)
+
+		// Offer to update the names under which the object is registered
+		// by string, or to preserve them on the wire.
+		optional.addAnnotatedEdits(StringsGroup, "register", protocol.ChangeAnnotation{
+			Label:       "Rename string registrations",
+			Description: fmt.Sprintf("%d registrations of %q by name, such as template functions or RPC methods", countEdits(registrations.update), qos[0].obj.Name()),
+		}, registrations.update)
+		optional.addAnnotatedEdits(StringsGroup, "wire", protocol.ChangeAnnotation{
+			Label:       "Preserve registered names",
+			Description: fmt.Sprintf("register %q under its old name explicitly, for wire compatibility", qos[0].obj.Name()),
+		}, registrations.preserve)
+
+		declPkgs, err := s.PackagesForFile(ctx, declURI, TypecheckWorkspace, false)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if !isLocal(qos[0].obj) {
+			// Offer to rename the old name in the messages of the errors
+			// constructed by the declaring package, which often mention it.
+			matcher := newNameMatcher(opts.RenameMatching, qos[0].obj.Name(), newName)
+			if err := optional.addErrorMessageEdits(declPkgs, matcher); err != nil {
+				return nil, nil, false, err
+			}
 
-		if strings.HasSuffix(newName, "_test") {
-			return nil, nil, true, fmt.Errorf("cannot rename to _test package")
+			// Offer to rename the old name in the names of the subtests
+			// of the declaring package, which often name the tested object.
+			subtestEdits, err := subtestNameEdits(ctx, s, filepath.Dir(declURI.Filename()), declPkgs, matcher)
+			if err != nil {
+				return nil, nil, false, err
+			}
+			optional.addAnnotatedEdits(SubtestsGroup, "", protocol.ChangeAnnotation{
+				Label:       "Rename in subtest names",
+				Description: fmt.Sprintf("%d occurrences of %q in the names of the subtests of package %s", countEdits(subtestEdits), qos[0].obj.Name(), qos[0].pkg.GetTypes().Name()),
+			}, subtestEdits)
+
+			// Offer to rename the keys under which the structured logging
+			// calls of the declaring package log a renamed field, keeping
+			// log schemas aligned with the code.
+			if field, ok := qos[0].obj.(*types.Var); ok && field.IsField() {
+				keyEdits, err := logKeyEdits(declPkgs, field, newName)
+				if err != nil {
+					return nil, nil, false, err
+				}
+				optional.addAnnotatedEdits(StringsGroup, "logging", protocol.ChangeAnnotation{
+					Label:       "Rename logging keys",
+					Description: fmt.Sprintf("%d keys derived from %s in structured logging calls, which changes the schema of the logs", countEdits(keyEdits), field.Name()),
+				}, keyEdits)
+			}
+		}
+
+		// Offer to rename the command-line flags and environment variables
+		// bound to a renamed variable and named after it. As outside code
+		// sets them, the renaming always needs confirmation.
+		if v, ok := qos[0].obj.(*types.Var); ok && !v.IsField() {
+			flagEdits, names, err := externalNameEdits(declPkgs, v, newName)
+			if err != nil {
+				return nil, nil, false, err
+			}
+			optional.addAnnotatedEdits(FlagsGroup, "", protocol.ChangeAnnotation{
+				Label:       "Rename flags and environment variables",
+				Description: externalNameWarning(names),
+			}, flagEdits)
+			if id := annotationID(FlagsGroup, ""); optional.Annotations[id].Label != "" {
+				a := optional.Annotations[id]
+				a.NeedsConfirmation = true
+				optional.Annotations[id] = a
+				optional.Warnings = append(optional.Warnings, externalNameWarning(names))
+			}
 		}
+	}
+	optional.Warnings = append(optional.Warnings, registrations.warnings...)
 
-		metadata, err := s.AllValidMetadata(ctx)
+	// Offer to rename the accessors of a renamed field or property.
+	for i, ar := range accessorRenames(qos[0], newName) {
+		edits, err := renameObj(ctx, s, ar.newName, []qualifiedObject{ar.qo}, false)
 		if err != nil {
-			return nil, nil, true, err
+			optional.Warnings = append(optional.Warnings, fmt.Sprintf("cannot rename %s to %s: %v", ar.qo.obj.Name(), ar.newName, err))
+			continue
+		}
+		annotation := protocol.ChangeAnnotation{
+			Label:       "Rename accessor",
+			Description: fmt.Sprintf("%s to %s", ar.qo.obj.Name(), ar.newName),
 		}
+		if ar.builder != nil {
+			annotation = protocol.ChangeAnnotation{
+				Label:       "Rename builder method",
+				Description: fmt.Sprintf("%s.%s to %s", ar.builder.Name(), ar.qo.obj.Name(), ar.newName),
+			}
+		}
+		optional.addAnnotatedEdits(AccessorsGroup, fmt.Sprint(i), annotation, edits)
+	}
 
-		renamingEdits, err := renamePackage(ctx, s, modulePath, oldPath, newName, metadata)
+	// Offer to rename the helper variables of the assertions of a renamed
+	// interface that are named after it, such as _I in var _I I = T{}.
+	for i, a := range assertions {
+		newVarName, ok := assertionHelperName(a.name.Name, qos[0].obj.Name(), newName)
+		if !ok {
+			continue
+		}
+		v := a.pkg.GetTypesInfo().Defs[a.name]
+		if v == nil {
+			continue
+		}
+		edits, err := renameObj(ctx, s, newVarName, []qualifiedObject{{obj: v, pkg: a.pkg}}, false)
 		if err != nil {
-			return nil, nil, true, err
+			optional.Warnings = append(optional.Warnings, fmt.Sprintf("cannot rename %s to %s: %v", a.name.Name, newVarName, err))
+			continue
 		}
+		optional.addAnnotatedEdits(AssertionsGroup, fmt.Sprint(i), protocol.ChangeAnnotation{
+			Label:       "Rename assertion variable",
+			Description: fmt.Sprintf("%s to %s, which asserts that a type implements %s", a.name.Name, newVarName, newName),
+		}, edits)
+	}
 
-		return renamingEdits, nil, true, nil
+	// Offer to update the deprecation notices that point their readers at
+	// the renamed object by its old name.
+	if opts.RenameInComments {
+		notices, err := deprecationNotices(ctx, s, qos[0], newName, result, optional.Edits)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		for i, n := range notices {
+			optional.addAnnotatedEdits(DeprecationsGroup, fmt.Sprint(i), protocol.ChangeAnnotation{
+				Label:       "Update deprecation notice",
+				Description: fmt.Sprintf("%s to %s in the deprecation notice of %s", qos[0].obj.Name(), newName, n.subject),
+			}, n.edits)
+		}
 	}
 
-	qos, err := qualifiedObjsAtProtocolPos(ctx, s, f.URI(), pp)
+	// Offer to update the database column of a renamed field, or to keep
+	// it by naming it explicitly.
+	updateColumn, keepColumn, err := columnTagEdits(s, qos[0], newName)
 	if err != nil {
 		return nil, nil, false, err
 	}
-	result, err := renameObj(ctx, s, newName, qos, false)
+	optional.addAnnotatedEdits(TagsGroup, "column", protocol.ChangeAnnotation{
+		Label:       "Rename database column",
+		Description: fmt.Sprintf("update the column tag of %s to match its new name; requires a schema migration", qos[0].obj.Name()),
+	}, updateColumn)
+	optional.addAnnotatedEdits(TagsGroup, "keepcolumn", protocol.ChangeAnnotation{
+		Label:       "Keep database column",
+		Description: fmt.Sprintf("map %s to its old column explicitly, leaving the schema unchanged", qos[0].obj.Name()),
+	}, keepColumn)
+	if id := annotationID(TagsGroup, "keepcolumn"); optional.Annotations[id].Label != "" {
+		// Keeping the column is the alternative to renaming it, whose
+		// edits conflict with it: it always needs confirmation.
+		a := optional.Annotations[id]
+		a.NeedsConfirmation = true
+		optional.Annotations[id] = a
+	}
+
+	// Warn of the calls of the name-sensitive functions configured by the
+	// user that are passed the old name.
+	warnings, err := nameSensitiveWarnings(ctx, s, qos[0])
 	if err != nil {
 		return nil, nil, false, err
 	}
-	// If renaming interface signature, then use optional annotation for interface implementations edits
-	if isInterfaceSignature(qos[0].obj) {
-		annotatedEdits := make(map[span.URI][]protocol.TextEdit)
-		annotations := make(map[protocol.ChangeAnnotationIdentifier]protocol.ChangeAnnotation)
-		impls, err := implementations(ctx, s, f, pp)
+	optional.Warnings = append(optional.Warnings, warnings...)
+
+	// Offer to rename the references in the Go files under testdata
+	// directories, which are only parsed.
+	if obj := qos[0].obj; obj.Pkg() != nil && obj.Parent() == obj.Pkg().Scope() {
+		if err := optional.addTestdataEdits(ctx, s, testdataRename{
+			oldPath:   obj.Pkg().Path(),
+			newPath:   obj.Pkg().Path(),
+			oldName:   obj.Pkg().Name(),
+			newName:   obj.Pkg().Name(),
+			oldMember: obj.Name(),
+			newMember: newName,
+		}, result); err != nil {
+			return nil, nil, false, err
+		}
+	}
+
+	// Offer to rename the occurrences of the qualified name in non-Go files.
+	if s.View().Options().RenameTextOccurrences {
+		occs, err := nonGoOccurrences(ctx, s, objectTextReplacements(qos[0].obj, newName))
 		if err != nil {
 			return nil, nil, false, err
 		}
-		for implID, impl := range impls {
-			subResult, err := renameObj(ctx, s, newName, []qualifiedObject{impl}, true)
-			if err != nil {
-				return nil, nil, false, err
-			}
-			for uri, res := range subResult {
-				for _, te := range res {
-					te.AnnotationID = fmt.Sprint(implID)
-					annotatedEdits[uri] = append(annotatedEdits[uri], te)
-				}
-			}
-			name := impl.obj.Name()
-			if sig, ok := impl.obj.Type().(*types.Signature); ok {
-				name = fmt.Sprintf("%s.%s", sig.Recv().Type().String(), name)
-			}
-			annotations[fmt.Sprint(implID)] = protocol.ChangeAnnotation{
-				Label:             fmt.Sprintf("Rename implementation #%d", implID+1),
-				NeedsConfirmation: true,
-				Description:       name,
-			}
+		if err := optional.addTextOccurrences(occs); err != nil {
+			return nil, nil, false, err
+		}
+	}
+
+	if err := checkVendoredEdits(s, result); err != nil {
+		return nil, nil, false, err
+	}
+	if err := optional.excludePaths(s, result); err != nil {
+		return nil, nil, false, err
+	}
+	if err := optional.skipTestFiles(s, result, declURI); err != nil {
+		return nil, nil, false, err
+	}
+	if w := tolerant.warning(); w != "" {
+		optional.Warnings = append(optional.Warnings, w)
+	}
+	if len(optional.Annotations) == 0 && len(optional.Warnings) == 0 {
+		return result, nil, false, nil
+	}
+	return result, optional, false, nil
+}
+
+// renamePackageAt implements RenamePackage, without formatting. An empty
+// mode is the default mode of the package. If annotate is set, the import
+// aliases that the renaming introduces are optional edits.
+func renamePackageAt(ctx context.Context, s Snapshot, f FileHandle, newName string, mode PackageRenameMode, annotate bool) (map[span.URI][]protocol.TextEdit, *OptionalEdits, error) {
+	if !isValidIdentifier(newName) {
+		return nil, nil, fmt.Errorf("%q is not a valid identifier", newName)
+	}
+
+	fileMeta, err := s.MetadataForFile(ctx, f.URI())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(fileMeta) == 0 {
+		return nil, nil, fmt.Errorf("no packages found for file %q", f.URI())
+	}
+	var pkgIDs []string
+	for _, m := range fileMeta {
+		pkgIDs = append(pkgIDs, m.PackageID())
+	}
+	if err := checkRenameScale(ctx, s, "package "+fileMeta[0].PackageName(), pkgIDs, true); err != nil {
+		return nil, nil, err
+	}
+
+	// We need metadata for the relevant package and module paths. These should
+	// be the same for all packages containing the file.
+	//
+	// TODO(rfindley): we mix package path and import path here haphazardly.
+	// Fix this.
+	meta := fileMeta[0]
+	modulePath, oldPath, err := packageImportPaths(s, meta, f.URI())
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot rename package: %v", err)
+	}
+
+	if strings.HasSuffix(newName, "_test") {
+		return nil, nil, fmt.Errorf("cannot rename to _test package")
+	}
+
+	metadata, err := s.AllValidMetadata(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	modes := packageRenameModes(s, modulePath, oldPath)
+	defaulted := mode == ""
+	if defaulted {
+		mode = modes[0]
+	} else if !containsMode(modes, mode) {
+		return nil, nil, fmt.Errorf("cannot rename package %s in mode %q", oldPath, mode)
+	}
+	var (
+		renamingEdits map[span.URI][]protocol.TextEdit
+		aliases       []importAlias
+	)
+	if mode == ClauseRename {
+		// The import paths are unchanged.
+		renamingEdits, aliases, err = updatePackagePaths(ctx, s, modulePath, oldPath, oldPath, newName, metadata)
+	} else {
+		renamingEdits, aliases, err = renamePackage(ctx, s, modulePath, oldPath, newName, metadata)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	optional := newOptionalEdits(s)
+	if mode == ClauseRename && defaulted && modulePath != oldPath {
+		optional.Warnings = append(optional.Warnings, fmt.Sprintf("the client can't rename files, so only the package clauses and import names are renamed: the directory of %s keeps its name", oldPath))
+	}
+	var ids []string
+	for _, m := range metadata {
+		if m.PackagePath() == oldPath {
+			ids = append(ids, m.PackageID())
+		}
+	}
+	exportData, _, err := exportDataPackages(ctx, s, "package "+fileMeta[0].PackageName(), ids, oldPath, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	optional.addExportDataPackages(oldPath, exportData)
+	note, err := testCycleNote(ctx, s, oldPath, metadata)
+	if err != nil {
+		return nil, nil, err
+	}
+	if note != "" {
+		optional.Warnings = append(optional.Warnings, note)
+	}
+	for i, a := range aliases {
+		if !annotate {
+			for uri, edits := range a.edits {
+				renamingEdits[uri] = append(renamingEdits[uri], edits...)
+			}
+			continue
+		}
+		optional.addAnnotatedEdits(ImportAliasesGroup, fmt.Sprint(i), protocol.ChangeAnnotation{
+			Label:       "Alias import",
+			Description: fmt.Sprintf("%s imports %s as %s: %s", filepath.Base(a.uri.Filename()), a.path, a.alias, a.reason),
+		}, a.edits)
+	}
+	if s.View().Options().RenameInStrings {
+		pkgs, err := s.PackagesForFile(ctx, f.URI(), TypecheckWorkspace, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(pkgs) > 0 {
+			matcher := newNameMatcher(s.View().Options().RenameMatching, pkgs[0].GetTypes().Name(), newName)
+			if err := optional.addErrorMessageEdits(pkgs, matcher); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	newPath := oldPath
+	if mode == DirectoryRename {
+		newPath = path.Join(path.Dir(oldPath), newName)
+	}
+	if err := optional.addTestdataEdits(ctx, s, testdataRename{
+		oldPath: oldPath,
+		newPath: newPath,
+		oldName: meta.PackageName(),
+		newName: newName,
+	}, renamingEdits); err != nil {
+		return nil, nil, err
+	}
+	if s.View().Options().RenameTextOccurrences && mode == DirectoryRename {
+		occs, err := nonGoOccurrences(ctx, s, []textReplacement{packageTextReplacement(string(oldPath), newName)})
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := optional.addTextOccurrences(occs); err != nil {
+			return nil, nil, err
 		}
-		return result, &OptionalEdits{Annotations: annotations, Edits: annotatedEdits}, false, nil
 	}
+	if err := checkVendoredEdits(s, renamingEdits); err != nil {
+		return nil, nil, err
+	}
+	if err := optional.excludePaths(s, renamingEdits); err != nil {
+		return nil, nil, err
+	}
+	if err := optional.skipTestFiles(s, renamingEdits, ""); err != nil {
+		return nil, nil, err
+	}
+	if len(optional.Annotations) == 0 && len(optional.Warnings) == 0 {
+		return renamingEdits, nil, nil
+	}
+	return renamingEdits, optional, nil
+}
 
-	return result, nil, false, nil
+// containsMode reports whether modes contains mode.
+func containsMode(modes []PackageRenameMode, mode PackageRenameMode) bool {
+	for _, m := range modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
 }
 
 // renamePackage computes all workspace edits required to rename the package
@@ -281,14 +1565,48 @@ func Rename(ctx context.Context, s Snapshot, f FileHandle, pp protocol.Position,
 // It updates package clauses and import paths for the renamed package as well
 // as any other packages affected by the directory renaming among packages
 // described by allMetadata.
-func renamePackage(ctx context.Context, s Snapshot, modulePath, oldPath, newName string, allMetadata []Metadata) (map[span.URI][]protocol.TextEdit, error) {
+func renamePackage(ctx context.Context, s Snapshot, modulePath, oldPath, newName string, allMetadata []Metadata) (map[span.URI][]protocol.TextEdit, []importAlias, error) {
 	if modulePath == oldPath {
-		return nil, fmt.Errorf("cannot rename package: module path %q is the same as the package path, so renaming the package directory would have no effect", modulePath)
+		return nil, nil, fmt.Errorf("cannot rename package: module path %q is the same as the package path, so renaming the package directory would have no effect", modulePath)
 	}
 
-	newPathPrefix := path.Join(path.Dir(oldPath), newName)
+	return updatePackagePaths(ctx, s, modulePath, oldPath, path.Join(path.Dir(oldPath), newName), newName, allMetadata)
+}
+
+// packageImportPaths returns the path of the module of the package
+// described by meta, which uri belongs to, and the import path of the
+// package. Outside of a module, in GOPATH mode, the module path is empty
+// and the import path is that of the directory of uri relative to the src
+// directory of the GOPATH entry containing it.
+func packageImportPaths(s Snapshot, meta Metadata, uri span.URI) (modulePath, pkgPath string, _ error) {
+	if mi := meta.ModuleInfo(); mi != nil {
+		return mi.Path, meta.PackagePath(), nil
+	}
+	dir := filepath.Dir(uri.Filename())
+	for _, gopath := range s.View().GOPATH() {
+		src := filepath.Join(gopath, "src")
+		if rel, ok := relPath(src, dir); ok && rel != "." {
+			return "", filepath.ToSlash(rel), nil
+		}
+	}
+	return "", "", fmt.Errorf("missing module information for package %q", meta.PackagePath())
+}
 
+// updatePackagePaths computes the edits required to change the import path
+// of the package oldPath, and of the packages nested within it, to be
+// prefixed by newPathPrefix instead. The package oldPath is also renamed to
+// newName, if that differs from its current name.
+//
+// Only packages of the module modulePath, among those described by
+// allMetadata, are affected, or the packages outside of modules if
+// modulePath is empty, in GOPATH mode.
+//
+// The edits that give an import of a renamed package a local name, as its
+// new name is taken in the importing file, are returned apart, one alias
+// at a time.
+func updatePackagePaths(ctx context.Context, s Snapshot, modulePath, oldPath, newPathPrefix, newName string, allMetadata []Metadata) (map[span.URI][]protocol.TextEdit, []importAlias, error) {
 	edits := make(map[span.URI][]protocol.TextEdit)
+	var aliases []importAlias
 	seen := make(seenPackageRename) // track per-file import renaming we've already processed
 
 	// Rename imports to the renamed package from other packages.
@@ -298,9 +1616,12 @@ func renamePackage(ctx context.Context, s Snapshot, modulePath, oldPath, newName
 		// renamed.
 		if m.PackagePath() == oldPath+"_test" {
 			newTestName := newName + "_test"
+			if newTestName == m.PackageName() {
+				continue
+			}
 
 			if err := renamePackageClause(ctx, m, s, newTestName, seen, edits); err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			continue
 		}
@@ -313,10 +1634,10 @@ func renamePackage(ctx context.Context, s Snapshot, modulePath, oldPath, newName
 		}
 
 		if m.ModuleInfo() == nil {
-			return nil, fmt.Errorf("cannot rename package: missing module information for package %q", m.PackagePath())
-		}
-
-		if modulePath != m.ModuleInfo().Path {
+			if modulePath != "" {
+				return nil, nil, fmt.Errorf("cannot rename package: missing module information for package %q", m.PackagePath())
+			}
+		} else if modulePath != m.ModuleInfo().Path {
 			continue // don't edit imports if nested package and renaming package have different module paths
 		}
 
@@ -325,20 +1646,56 @@ func renamePackage(ctx context.Context, s Snapshot, modulePath, oldPath, newName
 		newPath := newPathPrefix + suffix
 
 		pkgName := m.PackageName()
-		if m.PackagePath() == oldPath {
+		if m.PackagePath() == oldPath && newName != pkgName {
 			pkgName = newName
 
 			if err := renamePackageClause(ctx, m, s, newName, seen, edits); err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 		}
 
-		if err := renameImports(ctx, s, m, newPath, pkgName, seen, edits); err != nil {
-			return nil, err
+		fileAliases, err := renameImports(ctx, s, m, newPath, pkgName, seen, edits)
+		if err != nil {
+			return nil, nil, err
 		}
+		aliases = append(aliases, fileAliases...)
 	}
 
-	return edits, nil
+	return edits, aliases, nil
+}
+
+// testCycleNote returns a note on the packages that the tests of the
+// package oldPath depend on, and that depend on it in turn, or "" if there
+// are none. Such cycles give the packages intermediate test variants, such as
+// b [a.test] for the package b imported by the tests of a, importing a
+// [a.test]; their files are renamed once, with the packages themselves.
+func testCycleNote(ctx context.Context, s Snapshot, oldPath string, allMetadata []Metadata) (string, error) {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, m := range allMetadata {
+		if m.PackagePath() != oldPath {
+			continue
+		}
+		rdeps, err := loadReverseDependencies(ctx, s, "package "+m.PackageName(), m.PackageID())
+		if err != nil {
+			return "", err
+		}
+		for _, dep := range rdeps {
+			if p := dep.PkgPath(); dep.ForTest() == oldPath && p != oldPath && p != oldPath+"_test" && !seen[p] {
+				seen[p] = true
+				paths = append(paths, p)
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return "", nil
+	}
+	sort.Strings(paths)
+	verb := "depends"
+	if len(paths) > 1 {
+		verb = "depend"
+	}
+	return fmt.Sprintf("the tests of %s depend on %s, which %s on %s in turn: their files are renamed once, not once per test variant", oldPath, strings.Join(paths, ", "), verb, oldPath), nil
 }
 
 // seenPackageRename tracks import path renamings that have already been
@@ -396,25 +1753,86 @@ func renamePackageClause(ctx context.Context, m Metadata, s Snapshot, newName st
 			Range:   rng,
 			NewText: newName,
 		})
+
+		// Keep the package documentation sentence "Package foo ..." in
+		// sync with the package clause.
+		pos, err := packageDocNamePos(f)
+		if err != nil {
+			return err
+		}
+		if pos.IsValid() {
+			rng, err := NewMappedRange(f.Tok, f.Mapper, pos, pos+token.Pos(len(f.File.Name.Name))).Range()
+			if err != nil {
+				return err
+			}
+			edits[f.URI] = append(edits[f.URI], protocol.TextEdit{
+				Range:   rng,
+				NewText: newName,
+			})
+		}
 	}
 
 	return nil
 }
 
+// packageDocNamePos returns the position of the package name in the leading
+// "Package foo" phrase of the package doc comment of pgf, or token.NoPos if
+// the comment does not begin with that phrase.
+func packageDocNamePos(pgf *ParsedGoFile) (token.Pos, error) {
+	file := pgf.File
+	if file.Doc == nil || len(file.Doc.List) == 0 {
+		return token.NoPos, nil
+	}
+	c := file.Doc.List[0]
+	text, err := commentSource(pgf, c)
+	if err != nil {
+		return token.NoPos, err
+	}
+	text = text[2:] // strip the comment marker
+	i := len(text) - len(strings.TrimLeft(text, " \t\r\n"))
+	rest := text[i:]
+	prefix := "Package " + file.Name.Name
+	if !strings.HasPrefix(rest, prefix) {
+		return token.NoPos, nil
+	}
+	if len(rest) > len(prefix) {
+		if r, _ := utf8.DecodeRuneInString(rest[len(prefix):]); isIdentRune(r) {
+			return token.NoPos, nil // a longer name
+		}
+	}
+	return c.Pos() + token.Pos(2+i+len("Package ")), nil
+}
+
+// An importAlias is the local name that the renaming of a package gives to
+// an import of it, in a file where its new name is taken.
+type importAlias struct {
+	uri    span.URI // the importing file
+	path   string   // the new import path
+	alias  string
+	reason string                           // why the new name is taken
+	edits  map[span.URI][]protocol.TextEdit // the naming of the import and the renaming of its references
+}
+
 // renameImports computes the set of edits to imports resulting from renaming
 // the package described by the given metadata, to a package with import path
 // newPath and name newName.
 //
-// Edits are written into the edits map.
-func renameImports(ctx context.Context, s Snapshot, m Metadata, newPath, newName string, seen seenPackageRename, edits map[span.URI][]protocol.TextEdit) error {
+// Edits are written into the edits map, except those of the imports given a
+// local name, as newName is taken in their file, which are returned. The
+// local name is the one of the renameImportAliases setting for newPath, if
+// it is free, or else newName followed by the first free number. It is
+// decided for each file, including those excluded from the build
+// configuration.
+func renameImports(ctx context.Context, s Snapshot, m Metadata, newPath, newName string, seen seenPackageRename, edits map[span.URI][]protocol.TextEdit) ([]importAlias, error) {
 	// TODO(rfindley): we should get reverse dependencies as metadata first,
 	// rather then building the package immediately. We don't need reverse
 	// dependencies if they are intermediate test variants.
-	rdeps, err := s.GetReverseDependencies(ctx, m.PackageID())
+	rdeps, err := loadReverseDependencies(ctx, s, "package "+m.PackageName(), m.PackageID())
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	var aliases []importAlias
 	for _, dep := range rdeps {
 		// Subtle: don't perform renaming in this package if it is not fully
 		// parsed. This can occur inside the workspace if dep is an intermediate
@@ -439,17 +1857,20 @@ func renameImports(ctx context.Context, s Snapshot, m Metadata, newPath, newName
 					continue // not the import we're looking for
 				}
 
-				// Create text edit for the import path (string literal).
-				impPathMappedRange := NewMappedRange(f.Tok, f.Mapper, imp.Path.Pos(), imp.Path.End())
-				rng, err := impPathMappedRange.Range()
-				if err != nil {
-					return err
+				// Create text edit for the import path (string literal),
+				// unless it is unchanged.
+				if newPath != m.PackagePath() {
+					impPathMappedRange := NewMappedRange(f.Tok, f.Mapper, imp.Path.Pos(), imp.Path.End())
+					rng, err := impPathMappedRange.Range()
+					if err != nil {
+						return nil, err
+					}
+					newText := strconv.Quote(newPath)
+					edits[f.URI] = append(edits[f.URI], protocol.TextEdit{
+						Range:   rng,
+						NewText: newText,
+					})
 				}
-				newText := strconv.Quote(newPath)
-				edits[f.URI] = append(edits[f.URI], protocol.TextEdit{
-					Range:   rng,
-					NewText: newText,
-				})
 
 				// If the package name of an import has not changed or if its import
 				// path already has a local package name, then we don't need to update
@@ -465,18 +1886,40 @@ func renameImports(ctx context.Context, s Snapshot, m Metadata, newPath, newName
 				pkgScope := dep.GetTypes().Scope()
 				fileScope := dep.GetTypesInfo().Scopes[f.File]
 
-				var changes map[span.URI][]protocol.TextEdit
+				// taken returns why name is not free in the file, or "". A
+				// name that would shadow a predeclared object used in the
+				// file, such as error, is not free either.
+				taken := func(name string) string {
+					if obj := fileScope.Lookup(name); obj != nil {
+						if pkgName, ok := obj.(*types.PkgName); ok {
+							return fmt.Sprintf("%s would collide with the import of %s", name, pkgName.Imported().Path())
+						}
+						return fmt.Sprintf("%s would collide with the dot-imported %s %s", name, objectKind(obj), name)
+					}
+					if obj := pkgScope.Lookup(name); obj != nil {
+						return fmt.Sprintf("%s would collide with the package-level %s %s", name, objectKind(obj), name)
+					}
+					if obj := types.Universe.Lookup(name); obj != nil && usesObject(dep.GetTypesInfo(), f.File, obj) {
+						return fmt.Sprintf("%s would shadow the predeclared %s %s", name, objectKind(obj), name)
+					}
+					return ""
+				}
 				localName := newName
-				try := 0
-
-				// Keep trying with fresh names until one succeeds.
-				for fileScope.Lookup(localName) != nil || pkgScope.Lookup(localName) != nil {
-					try++
-					localName = fmt.Sprintf("%s%d", newName, try)
+				reason := taken(newName)
+				if reason != "" {
+					localName = ""
+					if preferred := s.View().Options().RenameImportAliases[newPath]; preferred != "" && taken(preferred) == "" {
+						localName = preferred
+					}
+					for try := 1; localName == ""; try++ {
+						if name := fmt.Sprintf("%s%d", newName, try); taken(name) == "" {
+							localName = name
+						}
+					}
 				}
-				changes, err = renameObj(ctx, s, localName, qos, false)
+				changes, err := renameObj(ctx, s, localName, qos, false)
 				if err != nil {
-					return err
+					return nil, err
 				}
 
 				// If the chosen local package name matches the package's new name, delete the
@@ -489,6 +1932,10 @@ func renameImports(ctx context.Context, s Snapshot, m Metadata, newPath, newName
 					})
 					changes[f.URI] = v[1:]
 				}
+				if reason != "" {
+					aliases = append(aliases, importAlias{uri: f.URI, path: newPath, alias: localName, reason: reason, edits: changes})
+					continue
+				}
 				for uri, changeEdits := range changes {
 					edits[uri] = append(edits[uri], changeEdits...)
 				}
@@ -496,36 +1943,207 @@ func renameImports(ctx context.Context, s Snapshot, m Metadata, newPath, newName
 		}
 	}
 
-	return nil
+	constrained, err := renameConstrainedImports(ctx, s, rdeps, m, newPath, newName, seen, edits)
+	if err != nil {
+		return nil, err
+	}
+	return append(aliases, constrained...), nil
 }
 
+// ErrWorkspaceChanged is returned when files change during the analysis
+// of a rename, so that its edits may not apply to them.
+var ErrWorkspaceChanged = errors.New("workspace changed during rename, retry")
+
+// ErrFileChangedOnDisk is returned when files that are not open in the
+// editor changed on disk since their content was read for the analysis of
+// a rename, so that its edits may not apply to them.
+var ErrFileChangedOnDisk = errors.New("file changed on disk")
+
 // renameObj returns a map of TextEdits for renaming an identifier within a file
 // and boolean value of true if there is no renaming conflicts and false otherwise.
 func renameObj(ctx context.Context, s Snapshot, newName string, qos []qualifiedObject, renameImpls bool) (map[span.URI][]protocol.TextEdit, error) {
+	result, conflicts, _, err := forceRenameObj(ctx, s, newName, qos, renameImpls, false)
+	if err != nil {
+		return nil, err
+	}
+	// Without force, the only conflicts are those that are resolved.
+	for _, c := range conflicts {
+		for uri, edits := range c.edits {
+			result[uri] = append(result[uri], edits...)
+		}
+	}
+	return result, nil
+}
+
+// forceRenameObj is like renameObj, but if force is set, a renaming that
+// introduces conflicts does not fail: the edits at the positions involved
+// in each conflict are returned with the conflict instead of the others.
+// It also returns the number of the references that only the code
+// generated by cgo has, which no edit can rename.
+func forceRenameObj(ctx context.Context, s Snapshot, newName string, qos []qualifiedObject, renameImpls, force bool) (map[span.URI][]protocol.TextEdit, []*renameConflict, int, error) {
 	obj := qos[0].obj
 
 	if err := checkRenamable(obj); err != nil {
-		return nil, err
+		return nil, nil, 0, err
 	}
 	if obj.Name() == newName {
-		return nil, fmt.Errorf("old and new names are the same: %s", newName)
+		return nil, nil, 0, fmt.Errorf("old and new names are the same: %s", newName)
 	}
 	if !isValidIdentifier(newName) {
-		return nil, fmt.Errorf("invalid identifier to rename: %q", newName)
+		return nil, nil, 0, fmt.Errorf("invalid identifier to rename: %q", newName)
 	}
 
-	refs, err := references(ctx, s, qos, true, false, true)
+	refs, err := references(ctx, s, qos, true, false, true, true)
 	if err != nil {
-		return nil, err
+		return nil, nil, 0, err
+	}
+	r, err := newRenamer(ctx, s, obj, newName, refs, renameImpls, force)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	// Check that the renaming of the identifier is ok.
+	for _, ref := range refs {
+		r.check(ref.obj)
+		if r.hadConflicts && !force { // one error is enough.
+			break
+		}
+	}
+	if r.hadConflicts && !force {
+		return nil, nil, 0, fmt.Errorf(r.errors)
+	}
+
+	changes, err := r.update()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	// Set aside the edits involved in conflicts.
+	conflictEdits := make(map[*renameConflict]map[span.URI][]diff.Edit)
+	for _, c := range r.conflicts {
+		conflictEdits[c] = make(map[span.URI][]diff.Edit)
+		for _, pos := range c.pos {
+			uri, offset, _, err := editRange(r.snapshot, r.fset.File(pos), pos, pos)
+			if err == errCgoGenerated {
+				continue
+			}
+			if err != nil {
+				return nil, nil, 0, err
+			}
+			if _, ok := changes[uri]; !ok {
+				continue
+			}
+			edits := changes[uri][:0]
+			for _, e := range changes[uri] {
+				if e.Start == offset {
+					conflictEdits[c][uri] = append(conflictEdits[c][uri], e)
+				} else {
+					edits = append(edits, e)
+				}
+			}
+			changes[uri] = edits
+		}
+	}
+
+	// The edits are offsets in the content of the files analyzed, which
+	// the files of the snapshot must still have.
+	analyzed := make(map[span.URI][]byte)
+	for _, ref := range refs {
+		if ref.m != nil {
+			analyzed[ref.URI()] = ref.m.Content
+		}
+	}
+
+	// toProtocolEdits converts the edits of changes to protocol edits. The
+	// mappers of the files are built once for all the conversions, as
+	// building one takes time linear in the size of the file.
+	// The offsets of the files that the parser repaired are those of their
+	// repaired source, recorded in repaired.
+	mappers := make(map[span.URI]*protocol.ColumnMapper)
+	repaired := make(map[span.URI][]byte)
+	toProtocolEdits := func(changes map[span.URI][]diff.Edit) (map[span.URI][]protocol.TextEdit, error) {
+		result := make(map[span.URI][]protocol.TextEdit)
+		for uri, edits := range changes {
+			m, ok := mappers[uri]
+			if !ok {
+				fh, err := s.GetFile(ctx, uri)
+				if err != nil {
+					return nil, err
+				}
+				data, err := fh.Read()
+				if err != nil {
+					return nil, err
+				}
+				if content, ok := analyzed[uri]; ok && !bytes.Equal(content, data) {
+					if !isRepairedSource(ctx, s, fh, content) {
+						return nil, fmt.Errorf("%w: %s changed", ErrWorkspaceChanged, filepath.Base(uri.Filename()))
+					}
+					repaired[uri] = content
+				}
+				m = protocol.NewColumnMapper(uri, data)
+				mappers[uri] = m
+			}
+			if src, ok := repaired[uri]; ok {
+				var err error
+				if edits, err = fromRepairedSource(src, m.Content, edits); err != nil {
+					return nil, fmt.Errorf("%s: %v", filepath.Base(uri.Filename()), err)
+				}
+			}
+			protocolEdits, err := ToProtocolEdits(m, edits)
+			if err != nil {
+				return nil, err
+			}
+			result[uri] = protocolEdits
+		}
+		return result, nil
+	}
+	for _, c := range r.conflicts {
+		if c.edits, err = toProtocolEdits(conflictEdits[c]); err != nil {
+			return nil, nil, 0, err
+		}
+	}
+	// Set aside all the edits of the files in which the renaming resolves
+	// a collision.
+	for _, c := range r.collisions {
+		fileEdits := map[span.URI][]diff.Edit{c.uri: changes[c.uri]}
+		delete(changes, c.uri)
+		if c.importEdit != nil {
+			fileEdits[c.uri] = append(fileEdits[c.uri], *c.importEdit)
+		}
+		fileEdits[c.uri] = append(fileEdits[c.uri], c.qualifyEdits...)
+		edits, err := toProtocolEdits(fileEdits)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		for uri, e := range c.aliasEdits {
+			edits[uri] = append(edits[uri], e...)
+		}
+		r.conflicts = append(r.conflicts, &renameConflict{msg: c.msg, edits: edits, resolved: true})
+	}
+	result, err := toProtocolEdits(changes)
+	if err != nil {
+		return nil, nil, 0, err
 	}
-	r := renamer{
-		ctx:          ctx,
-		fset:         s.FileSet(),
-		refs:         refs,
-		objsToUpdate: make(map[types.Object]bool),
-		from:         obj.Name(),
-		to:           newName,
-		packages:     make(map[*types.Package]Package),
+	return result, r.conflicts, r.cgoOnly, nil
+}
+
+// newRenamer returns the renamer of obj to newName, at its references
+// refs, with the dot import collisions of newName resolved, ready to check
+// the renaming.
+func newRenamer(ctx context.Context, s Snapshot, obj types.Object, newName string, refs []*ReferenceInfo, renameImpls, force bool) (*renamer, error) {
+	r := &renamer{
+		ctx:              ctx,
+		snapshot:         s,
+		fset:             s.FileSet(),
+		refs:             refs,
+		objsToUpdate:     make(map[types.Object]bool),
+		from:             obj.Name(),
+		to:               newName,
+		packages:         make(map[*types.Package]Package),
+		force:            force,
+		qualifiedRefs:    make(map[*ast.Ident]string),
+		qualifiedFiles:   make(map[span.URI]bool),
+		convertedImports: make(map[convertedDotImport]bool),
 	}
 
 	// A renaming initiated at an interface method indicates the
@@ -547,48 +2165,17 @@ func renameObj(ctx context.Context, s Snapshot, newName string, qos []qualifiedO
 	for _, from := range refs {
 		r.packages[from.pkg.GetTypes()] = from.pkg
 	}
-
-	// Check that the renaming of the identifier is ok.
-	for _, ref := range refs {
-		r.check(ref.obj)
-		if r.hadConflicts { // one error is enough.
-			break
-		}
-	}
-	if r.hadConflicts {
-		return nil, fmt.Errorf(r.errors)
-	}
-
-	changes, err := r.update()
-	if err != nil {
+	if err := r.resolveDotImportCollisions(); err != nil {
 		return nil, err
 	}
-
-	result := make(map[span.URI][]protocol.TextEdit)
-	for uri, edits := range changes {
-		// These edits should really be associated with FileHandles for maximal correctness.
-		// For now, this is good enough.
-		fh, err := s.GetFile(ctx, uri)
-		if err != nil {
-			return nil, err
-		}
-		data, err := fh.Read()
-		if err != nil {
-			return nil, err
-		}
-		m := protocol.NewColumnMapper(uri, data)
-		protocolEdits, err := ToProtocolEdits(m, edits)
-		if err != nil {
-			return nil, err
-		}
-		result[uri] = protocolEdits
-	}
-	return result, nil
+	return r, nil
 }
 
 func isInterfaceSignature(obj types.Object) bool {
 	if obj, ok := obj.(*types.Func); ok {
-		return types.IsInterface(obj.Type().(*types.Signature).Recv().Type().Underlying())
+		if recv := obj.Type().(*types.Signature).Recv(); recv != nil {
+			return types.IsInterface(recv.Type().Underlying())
+		}
 	}
 	return false
 }
@@ -596,21 +2183,22 @@ func isInterfaceSignature(obj types.Object) bool {
 // Rename all references to the identifier.
 func (r *renamer) update() (map[span.URI][]diff.Edit, error) {
 	result := make(map[span.URI][]diff.Edit)
-	seen := make(map[span.Span]bool)
+	seen := make(map[positionKey]bool)
 
-	docRegexp, err := regexp.Compile(`\b` + r.from + `\b`)
-	if err != nil {
-		return nil, err
-	}
 	for _, ref := range r.refs {
-		refSpan, err := ref.Span()
+		uri, start, end, err := editRange(r.snapshot, ref.spanRange.TokFile, ref.spanRange.Start, ref.spanRange.End)
+		if err == errCgoGenerated {
+			r.cgoOnly++
+			continue
+		}
 		if err != nil {
 			return nil, err
 		}
-		if seen[refSpan] {
+		key := positionKey{uri, start}
+		if seen[key] {
 			continue
 		}
-		seen[refSpan] = true
+		seen[key] = true
 
 		// Renaming a types.PkgName may result in the addition or removal of an identifier,
 		// so we deal with this separately.
@@ -619,18 +2207,22 @@ func (r *renamer) update() (map[span.URI][]diff.Edit, error) {
 			if err != nil {
 				return nil, err
 			}
-			result[refSpan.URI()] = append(result[refSpan.URI()], *edit)
+			result[uri] = append(result[uri], *edit)
 			continue
 		}
 
-		// Replace the identifier with r.to.
+		// Replace the identifier with r.to, qualified if it would
+		// otherwise collide.
 		edit := diff.Edit{
-			Start: refSpan.Start().Offset(),
-			End:   refSpan.End().Offset(),
+			Start: start,
+			End:   end,
 			New:   r.to,
 		}
+		if q := r.qualifiedRefs[ref.ident]; q != "" {
+			edit.New = q + "." + r.to
+		}
 
-		result[refSpan.URI()] = append(result[refSpan.URI()], edit)
+		result[uri] = append(result[uri], edit)
 
 		if !ref.isDeclaration || ref.ident == nil { // uses do not have doc comments to update.
 			continue
@@ -642,38 +2234,224 @@ func (r *renamer) update() (map[span.URI][]diff.Edit, error) {
 		}
 
 		// Perform the rename in doc comments declared in the original package.
-		// go/parser strips out \r\n returns from the comment text, so go
-		// line-by-line through the comment text to get the correct positions.
+		// go/parser strips the \r of CRLF line endings from the comment text,
+		// so the offsets of mentions are computed in the file content.
+		scope := r.snapshot.View().Options().RenameCommentScope
+		matcher := newNameMatcher(r.snapshot.View().Options().RenameMatching, r.from, r.to)
 		for _, comment := range doc.List {
 			if isDirective(comment.Text) {
 				continue
 			}
-			// TODO(adonovan): why are we looping over lines?
-			// Just run the loop body once over the entire multiline comment.
-			lines := strings.Split(comment.Text, "\n")
 			tokFile := r.fset.File(comment.Pos())
-			commentLine := tokFile.Line(comment.Pos())
 			uri := span.URIFromPath(tokFile.Name())
-			for i, line := range lines {
-				lineStart := comment.Pos()
-				if i > 0 {
-					lineStart = tokFile.LineStart(commentLine + i)
-				}
-				for _, locs := range docRegexp.FindAllIndex([]byte(line), -1) {
-					// The File.Offset static check complains
-					// even though these uses are manifestly safe.
-					start, _ := safetoken.Offset(tokFile, lineStart+token.Pos(locs[0]))
-					end, _ := safetoken.Offset(tokFile, lineStart+token.Pos(locs[1]))
-					result[uri] = append(result[uri], diff.Edit{
-						Start: start,
-						End:   end,
-						New:   r.to,
-					})
+			pgf, err := ref.pkg.File(uri)
+			if err != nil {
+				return nil, err
+			}
+			text, err := commentSource(pgf, comment)
+			if err != nil {
+				return nil, err
+			}
+			skip := nonProse(tokFile, doc, scope)
+			for _, mention := range docMentions(text, matcher, ref.pkg, pgf.File, ref.obj.Pkg()) {
+				pos := comment.Pos() + token.Pos(mention.start)
+				if skip(pos) || scope == SemanticComments && !isLeadingName(tokFile, doc, pos) {
+					continue
+				}
+				uri, start, end, err := editRange(r.snapshot, tokFile, pos, pos+token.Pos(mention.end-mention.start))
+				if err == errCgoGenerated {
+					continue
+				}
+				if err != nil {
+					return nil, err
+				}
+				result[uri] = append(result[uri], diff.Edit{
+					Start: start,
+					End:   end,
+					New:   mention.newText,
+				})
+			}
+		}
+	}
+
+	// Keyed composite literals of renamed fields may also appear in files
+	// that are excluded from the current build configuration.
+	variantEdits, err := r.updateBuildVariants()
+	if err != nil {
+		return nil, err
+	}
+	for uri, edits := range variantEdits {
+		result[uri] = append(result[uri], edits...)
+	}
+
+	// Doc links and qualified mentions may refer to renamed objects from
+	// any comment, not just the doc comments of their declarations, which
+	// were updated above.
+	linkEdits, err := r.updateCommentReferences()
+	if err != nil {
+		return nil, err
+	}
+	for uri, edits := range linkEdits {
+		existing := make(map[int]bool)
+		for _, edit := range result[uri] {
+			existing[edit.Start] = true
+		}
+		for _, edit := range edits {
+			if !existing[edit.Start] {
+				result[uri] = append(result[uri], edit)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// editRange returns the file and offsets of the edit of the range
+// [start, end) of tokFile, a file of s.
+//
+// The positions of files with //line directives, such as the code generated
+// from goyacc grammars, are adjusted to the locations the directives name,
+// whereas the edits apply to the files themselves, so the physical offsets
+// are used, unless the file lies outside the workspace. This is the case of
+// the files generated by cgo, whose directives locate the edited source of
+// their code. The ranges of the code that cgo generated, rather than copied
+// from its source, fail with errCgoGenerated.
+func editRange(s Snapshot, tokFile *token.File, start, end token.Pos) (span.URI, int, int, error) {
+	adjusted, physical := tokFile.PositionFor(start, true), tokFile.PositionFor(start, false)
+	_, inWorkspace := workspaceRelPath(s, physical.Filename)
+	if !inWorkspace && adjusted == physical && isCgoGenerated(s, span.URIFromPath(physical.Filename)) {
+		return "", 0, 0, errCgoGenerated
+	}
+	if adjusted == physical || inWorkspace {
+		startOffset, err := safetoken.Offset(tokFile, start)
+		if err != nil {
+			return "", 0, 0, err
+		}
+		endOffset, err := safetoken.Offset(tokFile, end)
+		if err != nil {
+			return "", 0, 0, err
+		}
+		return span.URIFromPath(tokFile.Name()), startOffset, endOffset, nil
+	}
+	return directiveEditRange(s, tokFile, start, end)
+}
+
+// renameMappedRange is like posToMappedRange, for the ranges of the
+// identifiers that a rename edits. The ranges of a file whose //line
+// directives name a file that is not part of the package, such as the
+// grammar the file was generated from, or renumber the lines of the file
+// itself, are mapped to the file itself, to which the edits apply.
+func renameMappedRange(fset *token.FileSet, pkg Package, pos, end token.Pos) (MappedRange, error) {
+	if tokFile := fset.File(pos); tokFile != nil {
+		if adjusted := tokFile.PositionFor(pos, true); adjusted != tokFile.PositionFor(pos, false) {
+			if _, _, err := findFileInDeps(pkg, span.URIFromPath(adjusted.Filename)); err != nil || adjusted.Filename == tokFile.Name() {
+				physical, _, err := findFileInDeps(pkg, span.URIFromPath(tokFile.Name()))
+				if err != nil {
+					return MappedRange{}, err
 				}
+				return physicalMappedRange(tokFile, physical.Mapper, pos, end)
 			}
 		}
 	}
+	return posToMappedRange(fset, pkg, pos, end)
+}
+
+// renameDeclIdentifier returns the identifier of the declaration of obj in
+// pgf, with the range that renaming obj edits, for the declarations that
+// findIdentifier can't map, in files whose //line directives name files
+// that are not part of pkg.
+func renameDeclIdentifier(snapshot Snapshot, pkg Package, pgf *ParsedGoFile, obj types.Object) (*IdentifierInfo, error) {
+	path := pathEnclosingObjNode(pgf.File, obj.Pos())
+	if path == nil {
+		return nil, ErrNoIdentFound
+	}
+	ident, _ := path[0].(*ast.Ident)
+	if ident == nil {
+		return nil, ErrNoIdentFound
+	}
+	rng, err := renameMappedRange(snapshot.FileSet(), pkg, ident.Pos(), ident.End())
+	if err != nil {
+		return nil, err
+	}
+	return &IdentifierInfo{
+		Name:        ident.Name,
+		ident:       ident,
+		MappedRange: rng,
+		pkg:         pkg,
+		Snapshot:    snapshot,
+		Declaration: Declaration{obj: obj},
+	}, nil
+}
+
+// physicalMappedRange returns the MappedRange of [pos, end) in the file of
+// tokFile, mapped by m, ignoring the //line directives of the file.
+func physicalMappedRange(tokFile *token.File, m *protocol.ColumnMapper, pos, end token.Pos) (MappedRange, error) {
+	start, err := safetoken.Offset(tokFile, pos)
+	if err != nil {
+		return MappedRange{}, err
+	}
+	endOffset, err := safetoken.Offset(tokFile, end)
+	if err != nil {
+		return MappedRange{}, err
+	}
+	// The token.File of a new mapper has no line directives.
+	m = protocol.NewColumnMapper(m.URI, m.Content)
+	return MappedRange{
+		spanRange: span.NewRange(m.TokFile, m.TokFile.Pos(start), m.TokFile.Pos(endOffset)),
+		m:         m,
+	}, nil
+}
 
+// lineDirectiveSources returns the descriptions, such as "y.go (from
+// parser.y)", of the Go files of edits whose //line directives name other
+// files, the sources they were generated from, which renaming leaves
+// unchanged.
+func lineDirectiveSources(ctx context.Context, s Snapshot, edits map[span.URI][]protocol.TextEdit) ([]string, error) {
+	var result []string
+	for uri := range edits {
+		if !strings.HasSuffix(uri.Filename(), ".go") {
+			continue
+		}
+		fh, err := s.GetFile(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		pgf, err := s.ParseGo(ctx, fh, ParseFull)
+		if err != nil {
+			return nil, err
+		}
+		sources := make(map[string]bool)
+		for _, cg := range pgf.File.Comments {
+			for _, c := range cg.List {
+				text := strings.TrimSuffix(c.Text, "*/")
+				if !strings.HasPrefix(text, "//line ") && !strings.HasPrefix(text, "/*line ") {
+					continue
+				}
+				// The directive is line filename:line[:col], where the
+				// filename may be empty to keep the current one.
+				name := strings.TrimSpace(text[len("//line "):])
+				for i := 0; i < 2; i++ {
+					if colon := strings.LastIndexByte(name, ':'); colon >= 0 {
+						if _, err := strconv.Atoi(name[colon+1:]); err == nil {
+							name = name[:colon]
+						}
+					}
+				}
+				if name != "" && filepath.Base(name) != filepath.Base(uri.Filename()) {
+					sources[name] = true
+				}
+			}
+		}
+		if len(sources) > 0 {
+			var names []string
+			for name := range sources {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			result = append(result, fmt.Sprintf("%s (from %s)", filepath.Base(uri.Filename()), strings.Join(names, ", ")))
+		}
+	}
+	sort.Strings(result)
 	return result, nil
 }
 
@@ -0,0 +1,158 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// An accessorRename is the renaming of a member accompanying the renaming
+// of the property it gives access to.
+type accessorRename struct {
+	qo      qualifiedObject
+	newName string
+	builder *types.TypeName // the builder type declaring the member, if not that of the property
+}
+
+// accessorRenames returns the renamings of the members that follow the
+// accessor conventions for the property of the named type that is renamed
+// by renaming the object of qo to newName.
+//
+// The property is either a field foo, or a property-style method Foo
+// without parameters and with a single result. Its conventional members are
+// the field foo, the getters Foo and GetFoo, and the setters SetFoo and
+// WithFoo, declared directly by the same type.
+//
+// The conventional methods of a field also include those of the builder
+// and options types of the same package, named with the suffix Builder or
+// Options, whose bodies use the field.
+func accessorRenames(qo qualifiedObject, newName string) []accessorRename {
+	var named *types.Named
+	switch obj := qo.obj.(type) {
+	case *types.Var:
+		if !obj.IsField() || obj.Pkg() == nil {
+			return nil
+		}
+		if name := declaringStructName(obj); name != "" {
+			named, _ = obj.Pkg().Scope().Lookup(name).Type().(*types.Named)
+		}
+	case *types.Func:
+		sig := obj.Type().(*types.Signature)
+		if sig.Recv() == nil || sig.Params().Len() != 0 || sig.Results().Len() != 1 {
+			return nil
+		}
+		named, _ = Deref(sig.Recv().Type()).(*types.Named)
+	}
+	if named == nil || IsInterface(named) {
+		return nil
+	}
+
+	oldUpper, newUpper := capitalize(qo.obj.Name()), capitalize(newName)
+	conventions := map[string]string{
+		uncapitalize(oldUpper): uncapitalize(newUpper),
+		oldUpper:               newUpper,
+		"Get" + oldUpper:       "Get" + newUpper,
+		"Set" + oldUpper:       "Set" + newUpper,
+		"With" + oldUpper:      "With" + newUpper,
+	}
+	var renames []accessorRename
+	add := func(obj types.Object) {
+		if newName, ok := conventions[obj.Name()]; ok && obj != qo.obj && obj.Name() != newName {
+			renames = append(renames, accessorRename{qualifiedObject{obj: obj, pkg: qo.pkg}, newName, nil})
+		}
+	}
+	if st, ok := named.Underlying().(*types.Struct); ok {
+		for i := 0; i < st.NumFields(); i++ {
+			if f := st.Field(i); !f.Embedded() {
+				add(f)
+			}
+		}
+	}
+	for i := 0; i < named.NumMethods(); i++ {
+		add(named.Method(i))
+	}
+	if field, ok := qo.obj.(*types.Var); ok && qo.pkg != nil {
+		for _, m := range builderMethods(qo.pkg, named, field) {
+			if newName, ok := conventions[m.fn.Name()]; ok && m.fn.Name() != newName {
+				renames = append(renames, accessorRename{qualifiedObject{obj: m.fn, pkg: qo.pkg}, newName, m.builder})
+			}
+		}
+	}
+	return renames
+}
+
+// A builderMethod is a method of a builder type.
+type builderMethod struct {
+	fn      *types.Func
+	builder *types.TypeName
+}
+
+// builderSuffixes are the suffixes of the names of the builder and options
+// types, whose methods set the fields of other types.
+var builderSuffixes = []string{"Builder", "Options"}
+
+// builderMethods returns the methods, declared in the files of pkg, of the
+// builder and options types of pkg other than named, whose bodies use
+// field.
+func builderMethods(pkg Package, named *types.Named, field *types.Var) []builderMethod {
+	var methods []builderMethod
+	info := pkg.GetTypesInfo()
+	for _, pgf := range pkg.CompiledGoFiles() {
+		for _, decl := range pgf.File.Decls {
+			decl, ok := decl.(*ast.FuncDecl)
+			if !ok || decl.Recv == nil || decl.Body == nil {
+				continue
+			}
+			fn, ok := info.Defs[decl.Name].(*types.Func)
+			if !ok {
+				continue
+			}
+			tn := receiverTypeName(fn)
+			if tn == nil || tn == named.Obj() || !isBuilderName(tn.Name()) || !usesObject(info, decl.Body, field) {
+				continue
+			}
+			methods = append(methods, builderMethod{fn, tn})
+		}
+	}
+	return methods
+}
+
+// isBuilderName reports whether name is that of a builder or options type.
+func isBuilderName(name string) bool {
+	for _, suffix := range builderSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// usesObject reports whether n refers to obj.
+func usesObject(info *types.Info, n ast.Node, obj types.Object) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && info.Uses[id] == obj {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// capitalize returns name with its first letter in upper case.
+func capitalize(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[size:]
+}
+
+// uncapitalize returns name with its first letter in lower case.
+func uncapitalize(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(r)) + name[size:]
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+)
+
+// anonymousInterfaceTwins returns, if qo.obj is a method declared in an
+// anonymous interface type, such as the Write of
+//
+//	func f(w interface{ Write(p []byte) (int, error) })
+//
+// the methods of the same name of the structurally identical anonymous
+// interface types of the known packages, once per declaration, in order.
+// The values of identical interface types are assignable to one another,
+// which renaming the method in only one of them would break. The methods
+// of types outside the workspace modules are left out.
+func anonymousInterfaceTwins(ctx context.Context, s Snapshot, qo qualifiedObject) ([]qualifiedObject, error) {
+	fn, ok := qo.obj.(*types.Func)
+	if !ok {
+		return nil, nil
+	}
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return nil, nil
+	}
+	iface, ok := recv.Type().(*types.Interface)
+	if !ok {
+		return nil, nil // a named interface
+	}
+
+	knownPkgs, err := loadKnownPackages(ctx, s, qo.obj.Name())
+	if err != nil {
+		return nil, err
+	}
+	seen := map[token.Position]bool{s.FileSet().Position(fn.Pos()): true}
+	var twins []qualifiedObject
+	for _, pkg := range knownPkgs {
+		for e, tv := range pkg.GetTypesInfo().Types {
+			if _, ok := e.(*ast.InterfaceType); !ok {
+				continue
+			}
+			t, ok := tv.Type.(*types.Interface)
+			if !ok || !types.Identical(t, iface) {
+				continue
+			}
+			for i := 0; i < t.NumExplicitMethods(); i++ {
+				m := t.ExplicitMethod(i)
+				if m.Name() != fn.Name() {
+					continue
+				}
+				// The interface types of type declarations are not
+				// anonymous: their methods have named receivers.
+				if _, ok := m.Type().(*types.Signature).Recv().Type().(*types.Interface); !ok {
+					continue
+				}
+				pos := s.FileSet().Position(m.Pos())
+				if seen[pos] {
+					continue
+				}
+				seen[pos] = true
+				if twin := (qualifiedObject{obj: m, pkg: pkg}); excludedImplementation(ctx, s, twin) == "" {
+					twins = append(twins, twin)
+				}
+			}
+		}
+	}
+	sort.Slice(twins, func(i, j int) bool {
+		pi, pj := s.FileSet().Position(twins[i].obj.Pos()), s.FileSet().Position(twins[j].obj.Pos())
+		if pi.Filename != pj.Filename {
+			return pi.Filename < pj.Filename
+		}
+		return pi.Offset < pj.Offset
+	})
+	return twins, nil
+}
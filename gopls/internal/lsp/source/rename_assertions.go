@@ -0,0 +1,119 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/safetoken"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+// An interfaceAssertion is the declaration of a package-level variable
+// asserting at compile time that a type implements an interface, such as
+//
+//	var _ I = (*T)(nil)
+//
+// Its variable is blank, or a helper whose name starts with an underscore,
+// such as _I.
+type interfaceAssertion struct {
+	pkg  Package
+	uri  span.URI
+	rng  protocol.Range // the declaration of the variable
+	name *ast.Ident
+}
+
+// interfaceAssertions returns the assertions of the interface type qo.obj
+// in the package declaring it and its reverse dependencies, in order, or
+// nil if qo.obj is not an interface type.
+func interfaceAssertions(ctx context.Context, s Snapshot, qo qualifiedObject) ([]interfaceAssertion, error) {
+	tn, ok := qo.obj.(*types.TypeName)
+	if !ok || !IsInterface(tn.Type()) {
+		return nil, nil
+	}
+	pkgs, err := loadReverseDependencies(ctx, s, qo.obj.Name(), qo.pkg.ID())
+	if err != nil {
+		return nil, err
+	}
+	pkgs = append(pkgs, qo.pkg)
+
+	var assertions []interfaceAssertion
+	seen := make(map[positionKey]bool) // files may belong to several packages
+	for _, pkg := range pkgs {
+		info := pkg.GetTypesInfo()
+		for _, pgf := range pkg.CompiledGoFiles() {
+			for _, decl := range pgf.File.Decls {
+				decl, ok := decl.(*ast.GenDecl)
+				if !ok || decl.Tok != token.VAR {
+					continue
+				}
+				for _, spec := range decl.Specs {
+					spec := spec.(*ast.ValueSpec)
+					if spec.Type == nil || len(spec.Values) != len(spec.Names) {
+						continue
+					}
+					named, ok := info.TypeOf(spec.Type).(*types.Named)
+					if !ok || !equalOrigin(named.Obj(), tn) {
+						continue
+					}
+					for _, id := range spec.Names {
+						if !strings.HasPrefix(id.Name, "_") {
+							continue
+						}
+						offset, err := safetoken.Offset(pgf.Tok, id.Pos())
+						if err != nil {
+							return nil, err
+						}
+						key := positionKey{pgf.URI, offset}
+						if seen[key] {
+							continue
+						}
+						seen[key] = true
+						rng, err := pgf.Mapper.PosRange(spec.Pos(), spec.End())
+						if err != nil {
+							return nil, err
+						}
+						assertions = append(assertions, interfaceAssertion{pkg: pkg, uri: pgf.URI, rng: rng, name: id})
+					}
+				}
+			}
+		}
+	}
+	return assertions, nil
+}
+
+// inAssertion reports whether the edit te of the file uri lies within one
+// of assertions.
+func inAssertion(assertions []interfaceAssertion, uri span.URI, te protocol.TextEdit) bool {
+	for _, a := range assertions {
+		if a.uri == uri &&
+			protocol.ComparePosition(a.rng.Start, te.Range.Start) <= 0 &&
+			protocol.ComparePosition(te.Range.End, a.rng.End) <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// assertionHelperName returns the name of the helper variable of an
+// assertion named name after the renaming of its interface from oldName to
+// newName, such as _Writer for _Reader, and whether name is named after
+// the interface.
+func assertionHelperName(name, oldName, newName string) (string, bool) {
+	if name == "_" {
+		return "", false
+	}
+	for _, pair := range [][2]string{{oldName, newName}, {uncapitalize(oldName), uncapitalize(newName)}} {
+		if i := strings.Index(name, pair[0]); i >= 0 {
+			return name[:i] + pair[1] + name[i+len(pair[0]):], true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,430 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/safetoken"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/diff"
+	"golang.org/x/tools/internal/typeparams"
+)
+
+// updateBuildVariants returns edits renaming the keys of keyed composite
+// literals of renamed struct fields, in Go files that are excluded from the
+// current build configuration (for example, files constrained to another
+// GOOS or GOARCH, or guarded by a build tag).
+//
+// Such files are not type-checked, so they are matched syntactically: a key
+// is renamed if the literal's type, or the element type of an enclosing
+// slice, array or map literal, names a struct type declaring the renamed
+// field. Conflicts within these files are not checked.
+func (r *renamer) updateBuildVariants() (map[span.URI][]diff.Edit, error) {
+	// Find the named struct types declaring the renamed fields, by package.
+	structs := make(map[*types.Package]map[string]bool)
+	for obj := range r.objsToUpdate {
+		v, ok := obj.(*types.Var)
+		if !ok || !v.IsField() || v.Pkg() == nil {
+			continue
+		}
+		if name := declaringStructName(v); name != "" {
+			if structs[v.Pkg()] == nil {
+				structs[v.Pkg()] = make(map[string]bool)
+			}
+			structs[v.Pkg()][name] = true
+		}
+	}
+	if len(structs) == 0 {
+		return nil, nil
+	}
+
+	// Files that are type-checked in some package are handled by update;
+	// only the remaining Go files in the directories of the declaring
+	// package and its importers need to be considered.
+	known := make(map[span.URI]bool)
+	dirs := make(map[string]bool)
+	addPkg := func(pkg Package) {
+		for _, pgf := range pkg.CompiledGoFiles() {
+			known[pgf.URI] = true
+			dirs[filepath.Dir(pgf.URI.Filename())] = true
+		}
+	}
+	for _, pkg := range r.packages {
+		addPkg(pkg)
+	}
+	// The files of the directories of the declaring packages refer to
+	// their types unqualified, unlike the importers, which may share the
+	// name of a declaring package nonetheless.
+	pkgDirs := make(map[*types.Package]map[string]bool)
+	for tpkg := range structs {
+		pkg, ok := r.packages[tpkg]
+		if !ok {
+			continue
+		}
+		pkgDirs[tpkg] = make(map[string]bool)
+		for _, pgf := range pkg.CompiledGoFiles() {
+			pkgDirs[tpkg][filepath.Dir(pgf.URI.Filename())] = true
+		}
+		rdeps, err := loadReverseDependencies(r.ctx, r.snapshot, r.from, pkg.ID())
+		if err != nil {
+			return nil, err
+		}
+		for _, dep := range rdeps {
+			addPkg(dep)
+		}
+	}
+
+	result := make(map[span.URI][]diff.Edit)
+	for dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue // the directory may have been removed
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") {
+				continue
+			}
+			uri := span.URIFromPath(filepath.Join(dir, entry.Name()))
+			if known[uri] {
+				continue
+			}
+			fh, err := r.snapshot.GetFile(r.ctx, uri)
+			if err != nil {
+				return nil, err
+			}
+			pgf, err := r.snapshot.ParseGo(r.ctx, fh, ParseFull)
+			if err != nil || pgf.File.Name == nil {
+				continue // not a Go file we can make sense of
+			}
+			for tpkg, names := range structs {
+				for _, key := range variantFieldKeys(pgf.File, tpkg, pkgDirs[tpkg][dir], names, r.from) {
+					start, err := safetoken.Offset(pgf.Tok, key.Pos())
+					if err != nil {
+						return nil, err
+					}
+					end, err := safetoken.Offset(pgf.Tok, key.End())
+					if err != nil {
+						return nil, err
+					}
+					result[uri] = append(result[uri], diff.Edit{Start: start, End: end, New: r.to})
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// declaringStructName returns the name of the package-level named struct
+// type that declares field, or "" if there is none.
+func declaringStructName(field *types.Var) string {
+	scope := field.Pkg().Scope()
+	for _, name := range scope.Names() {
+		tname, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || tname.IsAlias() {
+			continue
+		}
+		if T, ok := tname.Type().Underlying().(*types.Struct); ok {
+			for i := 0; i < T.NumFields(); i++ {
+				if T.Field(i) == field {
+					return tname.Name()
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// variantFieldKeys returns the keys named fieldName of composite literals in
+// f whose type syntactically denotes one of the named types of pkg. inPkgDir
+// reports whether f is in a directory of pkg.
+func variantFieldKeys(f *ast.File, pkg *types.Package, inPkgDir bool, names map[string]bool, fieldName string) []*ast.Ident {
+	// Determine how f refers to pkg: unqualified if f belongs to pkg itself
+	// or dot-imports it, otherwise through the local names of its imports.
+	unqualified := inPkgDir && f.Name.Name == pkg.Name()
+	qualifiers := make(map[string]bool)
+	for _, imp := range f.Imports {
+		if ImportPath(imp) != pkg.Path() {
+			continue
+		}
+		switch {
+		case imp.Name == nil:
+			qualifiers[pkg.Name()] = true
+		case imp.Name.Name == ".":
+			unqualified = true
+		case imp.Name.Name != "_":
+			qualifiers[imp.Name.Name] = true
+		}
+	}
+
+	// denotes reports whether the type expression e names a matching type.
+	denotes := func(e ast.Expr) bool {
+		if star, ok := e.(*ast.StarExpr); ok {
+			e = star.X
+		}
+		if x, _, _, _ := typeparams.UnpackIndexExpr(e); x != nil {
+			e = x
+		}
+		switch e := e.(type) {
+		case *ast.Ident:
+			return unqualified && names[e.Name]
+		case *ast.SelectorExpr:
+			if id, ok := e.X.(*ast.Ident); ok {
+				return qualifiers[id.Name] && names[e.Sel.Name]
+			}
+		}
+		return false
+	}
+
+	var keys []*ast.Ident
+	var visit func(lit *ast.CompositeLit, typ ast.Expr)
+	visit = func(lit *ast.CompositeLit, typ ast.Expr) {
+		if lit.Type != nil {
+			typ = lit.Type
+		}
+		if typ == nil {
+			return
+		}
+		// For slice, array and map literals, element literals may elide
+		// their type.
+		var elemType ast.Expr
+		switch t := typ.(type) {
+		case *ast.ArrayType:
+			elemType = t.Elt
+		case *ast.MapType:
+			elemType = t.Value
+		}
+		for _, elt := range lit.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				if key, ok := kv.Key.(*ast.Ident); ok && key.Name == fieldName && elemType == nil && denotes(typ) {
+					keys = append(keys, key)
+				}
+				elt = kv.Value
+			}
+			if u, ok := elt.(*ast.UnaryExpr); ok {
+				elt = u.X
+			}
+			if inner, ok := elt.(*ast.CompositeLit); ok && inner.Type == nil {
+				visit(inner, elemType)
+			}
+		}
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.CompositeLit); ok && lit.Type != nil {
+			visit(lit, nil)
+		}
+		return true
+	})
+	return keys
+}
+
+// renameConstrainedImports is like renameImports for the Go files of the
+// directories of rdeps, the reverse dependencies of the renamed package
+// described by m, that are excluded from the current build configuration,
+// such as the foo_windows.go of an importer type-checked on Linux. An
+// importer may give the renamed package different local names in its
+// files for different platforms, so the local name of each import is
+// decided on its own, from the names that its file declares and imports.
+//
+// Such files are not type-checked, so they are matched syntactically: a
+// reference to the import is a selector whose operand is an unresolved
+// identifier of the package name. Any identifier of the new name declared
+// within the file makes the name taken in it.
+func renameConstrainedImports(ctx context.Context, s Snapshot, rdeps []Package, m Metadata, newPath, newName string, seen seenPackageRename, edits map[span.URI][]protocol.TextEdit) ([]importAlias, error) {
+	known := make(map[span.URI]bool)
+	dirs := make(map[string][]Package) // the fully parsed packages of each directory
+	for _, dep := range rdeps {
+		for _, pgf := range dep.CompiledGoFiles() {
+			known[pgf.URI] = true
+			if dir := filepath.Dir(pgf.URI.Filename()); dep.ParseMode() == ParseFull && !containsPackage(dirs[dir], dep) {
+				dirs[dir] = append(dirs[dir], dep)
+			}
+		}
+	}
+
+	var sorted []string
+	for dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+	sort.Strings(sorted)
+
+	var aliases []importAlias
+	for _, dir := range sorted {
+		pkgs := dirs[dir]
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue // the directory may have been removed
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") {
+				continue
+			}
+			uri := span.URIFromPath(filepath.Join(dir, entry.Name()))
+			if known[uri] || seen.add(uri, m.PackagePath()) {
+				continue
+			}
+			fh, err := s.GetFile(ctx, uri)
+			if err != nil {
+				return nil, err
+			}
+			pgf, err := s.ParseGo(ctx, fh, ParseFull)
+			if err != nil || pgf.File.Name == nil {
+				continue // not a Go file we can make sense of
+			}
+			// The package of the file, if it is type-checked for another
+			// file of the directory.
+			var pkg Package
+			for _, p := range pkgs {
+				if p.Name() == pgf.File.Name.Name {
+					pkg = p
+				}
+			}
+			for _, imp := range pgf.File.Imports {
+				if ImportPath(imp) != m.PackagePath() {
+					continue
+				}
+				if newPath != m.PackagePath() {
+					rng, err := NewMappedRange(pgf.Tok, pgf.Mapper, imp.Path.Pos(), imp.Path.End()).Range()
+					if err != nil {
+						return nil, err
+					}
+					edits[uri] = append(edits[uri], protocol.TextEdit{Range: rng, NewText: strconv.Quote(newPath)})
+				}
+				if newName == m.PackageName() || imp.Name != nil {
+					continue
+				}
+
+				taken := func(name string) string {
+					return constrainedNameTaken(pgf.File, pkg, imp, name)
+				}
+				localName := newName
+				reason := taken(newName)
+				if reason != "" {
+					localName = ""
+					if preferred := s.View().Options().RenameImportAliases[newPath]; preferred != "" && taken(preferred) == "" {
+						localName = preferred
+					}
+					for try := 1; localName == ""; try++ {
+						if name := fmt.Sprintf("%s%d", newName, try); taken(name) == "" {
+							localName = name
+						}
+					}
+				}
+
+				var changes []protocol.TextEdit
+				if localName != newName {
+					rng, err := NewMappedRange(pgf.Tok, pgf.Mapper, imp.Path.Pos(), imp.Path.Pos()).Range()
+					if err != nil {
+						return nil, err
+					}
+					changes = append(changes, protocol.TextEdit{Range: rng, NewText: localName + " "})
+				}
+				for _, id := range importReferences(pgf.File, m.PackageName()) {
+					rng, err := NewMappedRange(pgf.Tok, pgf.Mapper, id.Pos(), id.End()).Range()
+					if err != nil {
+						return nil, err
+					}
+					changes = append(changes, protocol.TextEdit{Range: rng, NewText: localName})
+				}
+				if reason != "" {
+					aliases = append(aliases, importAlias{uri: uri, path: newPath, alias: localName, reason: reason, edits: map[span.URI][]protocol.TextEdit{uri: changes}})
+					continue
+				}
+				edits[uri] = append(edits[uri], changes...)
+			}
+		}
+	}
+	return aliases, nil
+}
+
+// containsPackage reports whether pkgs contains pkg.
+func containsPackage(pkgs []Package, pkg Package) bool {
+	for _, p := range pkgs {
+		if p.ID() == pkg.ID() {
+			return true
+		}
+	}
+	return false
+}
+
+// importReferences returns the operands of the selectors of f that refer
+// to the import of the package named name: the identifiers of the name that
+// the parser leaves unresolved, as they are declared by no scope of f.
+func importReferences(f *ast.File, name string) []*ast.Ident {
+	var refs []*ast.Ident
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok && id.Name == name && id.Obj == nil {
+				refs = append(refs, id)
+			}
+		}
+		return true
+	})
+	return refs
+}
+
+// constrainedNameTaken returns why name is not free in f, a file excluded
+// from the build configuration importing a renamed package with imp, or
+// "". pkg is the type-checked package of the other files of f, if any.
+func constrainedNameTaken(f *ast.File, pkg Package, imp *ast.ImportSpec, name string) string {
+	for _, other := range f.Imports {
+		if other == imp {
+			continue
+		}
+		local := ""
+		if other.Name != nil {
+			local = other.Name.Name
+		} else if pkg != nil {
+			if dep, err := pkg.ResolveImportPath(ImportPath(other)); err == nil {
+				local = dep.Name()
+			}
+		}
+		if local == "" {
+			local = path.Base(ImportPath(other))
+		}
+		if local == name {
+			return fmt.Sprintf("%s would collide with the import of %s", name, ImportPath(other))
+		}
+	}
+	if obj := f.Scope.Lookup(name); obj != nil {
+		return fmt.Sprintf("%s would collide with the package-level %s %s", name, obj.Kind, name)
+	}
+	if pkg != nil {
+		if obj := pkg.GetTypes().Scope().Lookup(name); obj != nil {
+			return fmt.Sprintf("%s would collide with the package-level %s %s", name, objectKind(obj), name)
+		}
+	}
+
+	// Selectors are left unresolved: they are not uses of name.
+	sels := make(map[*ast.Ident]bool)
+	reason := ""
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			sels[n.Sel] = true
+		case *ast.Ident:
+			if n.Name != name || sels[n] {
+				break
+			}
+			if n.Obj != nil && n.Obj.Pos() == n.Pos() {
+				reason = fmt.Sprintf("%s would collide with the local %s %s", name, n.Obj.Kind, name)
+			} else if obj := types.Universe.Lookup(name); n.Obj == nil && obj != nil {
+				reason = fmt.Sprintf("%s would shadow the predeclared %s %s", name, objectKind(obj), name)
+			}
+		}
+		return reason == ""
+	})
+	return reason
+}
@@ -0,0 +1,64 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"time"
+
+	"golang.org/x/tools/refactor/satisfy"
+)
+
+// Kinds of the analyses of renames cached by View.RenameAnalysis.
+const (
+	constraintsAnalysis = "constraints" // the interface satisfaction constraints of a package
+)
+
+// renameConstraints returns the interface satisfaction constraints
+// established by files, the files of pkg that satisfy.Finder can visit,
+// cached by the view of s across its snapshots.
+func renameConstraints(s Snapshot, pkg Package, files []*ast.File) map[satisfy.Constraint]bool {
+	return s.View().RenameAnalysis(constraintsAnalysis, pkg, func() (interface{}, int) {
+		var f satisfy.Finder
+		f.Find(pkg.GetTypesInfo(), files)
+		return f.Result, len(f.Result)
+	}).(map[satisfy.Constraint]bool)
+}
+
+// RenameOptionsHash returns the hash of the options that the cached
+// analyses of renames may depend on: those selecting the methods and
+// files that a rename considers. Options that only shape the edits of a
+// rename, such as RenameInComments, are left out, so that changing them
+// keeps the cache.
+func RenameOptionsHash(options *Options) Hash {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "includeImplementations=%t\n", options.RenameIncludeImplementations)
+	fmt.Fprintf(&b, "implementationsScope=%s\n", options.RenameImplementationsScope)
+	fmt.Fprintf(&b, "generatedFilePolicy=%s\n", options.RenameGeneratedFilePolicy)
+	fmt.Fprintf(&b, "skipTestFiles=%t\n", options.RenameSkipTestFiles)
+	fmt.Fprintf(&b, "excludePaths=%q\n", options.RenameExcludePaths)
+	return HashOf(b.Bytes())
+}
+
+// RenameCacheState describes the content of the cache of the analyses of
+// renames of a view.
+type RenameCacheState struct {
+	Hits, Misses, Evictions int
+	Entries                 []RenameCacheEntry // ordered by package ID and kind
+}
+
+// A RenameCacheEntry describes an entry of the cache of the analyses of
+// renames of a view.
+type RenameCacheEntry struct {
+	Kind       string
+	Package    string // the ID of the package
+	PackageKey string // the hash of the files, metadata and dependencies of the package
+	Options    string // the hash of the options
+	Size       int
+	Hits       int
+	Duration   time.Duration
+}
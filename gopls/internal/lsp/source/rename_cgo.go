@@ -0,0 +1,135 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"errors"
+	"go/token"
+	"io/ioutil"
+	"unicode/utf8"
+
+	"golang.org/x/tools/gopls/internal/lsp/safetoken"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+// errCgoGenerated reports that the text of a range of a file generated by
+// cgo does not appear in the Go file it was generated from.
+var errCgoGenerated = errors.New("text only in cgo-generated code")
+
+// isCgoGenerated reports whether the file uri of s is a Go file generated
+// by cgo: the processed copy of a file that imports "C", or one of the
+// files declaring what these copies refer to. The go command names them
+// after their hashes in its build cache, but cgo marks them as generated
+// in their headers.
+func isCgoGenerated(s Snapshot, uri span.URI) bool {
+	content, err := fileContent(s, uri)
+	if err != nil {
+		return false
+	}
+	if i := bytes.Index(content, []byte("\npackage ")); i >= 0 {
+		content = content[:i]
+	}
+	return bytes.Contains(content, []byte("// Code generated by cmd/cgo; DO NOT EDIT."))
+}
+
+// directiveEditRange returns the file and offsets of the edit of the range
+// [start, end) of tokFile, a file of s, in the file that its //line
+// directives name, such as the file importing "C" of which tokFile is the
+// processed copy. The range must have the same text there: cgo rewrites
+// the references to C in the lines that it copies, which shifts the columns
+// of the rest of them, so the text is looked for on the line nearest the
+// column of the directives. It fails with errCgoGenerated if the text is
+// not on the line, or if the directives name another generated file.
+func directiveEditRange(s Snapshot, tokFile *token.File, start, end token.Pos) (span.URI, int, int, error) {
+	startOffset, err := safetoken.Offset(tokFile, start)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	endOffset, err := safetoken.Offset(tokFile, end)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	generated, err := fileContent(s, span.URIFromPath(tokFile.Name()))
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if endOffset > len(generated) {
+		return "", 0, 0, ErrWorkspaceChanged
+	}
+	text := generated[startOffset:endOffset]
+
+	adjusted := tokFile.PositionFor(start, true)
+	uri := span.URIFromPath(adjusted.Filename)
+	if isCgoGenerated(s, uri) {
+		return "", 0, 0, errCgoGenerated
+	}
+	content, err := fileContent(s, uri)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	srcFile := span.NewTokenFile(adjusted.Filename, content)
+	if adjusted.Line > srcFile.LineCount() {
+		return "", 0, 0, errCgoGenerated
+	}
+	lineStart, err := safetoken.Offset(srcFile, srcFile.LineStart(adjusted.Line))
+	if err != nil {
+		return "", 0, 0, err
+	}
+	lineEnd := len(content)
+	if i := bytes.IndexByte(content[lineStart:], '\n'); i >= 0 {
+		lineEnd = lineStart + i
+	}
+	offset, ok := nearestOccurrence(content[lineStart:lineEnd], text, adjusted.Column-1)
+	if !ok {
+		return "", 0, 0, errCgoGenerated
+	}
+	return uri, lineStart + offset, lineStart + offset + len(text), nil
+}
+
+// nearestOccurrence returns the offset in line of the occurrence of text
+// nearest to col, and reports whether there is one. An identifier only
+// occurs as a whole word.
+func nearestOccurrence(line, text []byte, col int) (int, bool) {
+	if len(text) == 0 {
+		return col, col >= 0 && col <= len(line)
+	}
+	ident := isValidIdentifier(string(text))
+	best, found := 0, false
+	for i := 0; i+len(text) <= len(line); i++ {
+		if !bytes.HasPrefix(line[i:], text) {
+			continue
+		}
+		if ident {
+			before, _ := utf8.DecodeLastRune(line[:i])
+			after, _ := utf8.DecodeRune(line[i+len(text):])
+			if isIdentRune(before) || isIdentRune(after) {
+				continue
+			}
+		}
+		if !found || abs(i-col) < abs(best-col) {
+			best, found = i, true
+		}
+	}
+	return best, found
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// fileContent returns the content of the file uri, as s has it if it knows
+// the file, or else as the file system does. Reading a Go file through s
+// that it does not know would make it an orphaned file of s, which gopls
+// would then load as a package.
+func fileContent(s Snapshot, uri span.URI) ([]byte, error) {
+	if fh := s.FindFile(uri); fh != nil {
+		return fh.Read()
+	}
+	return ioutil.ReadFile(uri.Filename())
+}
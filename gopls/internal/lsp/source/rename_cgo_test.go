@@ -0,0 +1,34 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import "testing"
+
+func TestNearestOccurrence(t *testing.T) {
+	for _, test := range []struct {
+		line, text string
+		col        int
+		want       int // -1 if there is no occurrence
+	}{
+		// cgo maps the columns following a reference to C by its length
+		// in the generated code.
+		{"return int(C.fortytwo()) + offset", "offset", 26, 27},
+		{"x := offset + offset", "offset", 13, 14},
+		{"x := offset + offset", "offset", 4, 5},
+		{"x := offsets + offset", "offset", 5, 15},
+		{"x := _offset", "offset", 5, -1},
+		{"x := a.b", ".", 6, 6},
+		{"x", "", 1, 1},
+		{"x", "", 2, -1},
+	} {
+		got, ok := nearestOccurrence([]byte(test.line), []byte(test.text), test.col)
+		if !ok {
+			got = -1
+		}
+		if got != test.want {
+			t.Errorf("nearestOccurrence(%q, %q, %d) = %d, want %d", test.line, test.text, test.col, got, test.want)
+		}
+	}
+}
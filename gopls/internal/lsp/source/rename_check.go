@@ -11,19 +11,39 @@ import (
 	"go/ast"
 	"go/token"
 	"go/types"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
 	"unicode"
 
 	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/gopls/internal/span"
 	"golang.org/x/tools/refactor/satisfy"
 )
 
-// errorf reports an error (e.g. conflict) and prevents file modification.
+// errorf reports an error (e.g. conflict) and prevents file modification,
+// unless the renaming is forced. A message starting with a tab continues
+// the description of the previous conflict.
 func (r *renamer) errorf(pos token.Pos, format string, args ...interface{}) {
 	r.hadConflicts = true
-	r.errors += fmt.Sprintf(format, args...)
+	msg := fmt.Sprintf(format, args...)
+	r.errors += msg
+	if !strings.HasPrefix(msg, "\t") || len(r.conflicts) == 0 {
+		r.conflicts = append(r.conflicts, &renameConflict{})
+	}
+	c := r.conflicts[len(r.conflicts)-1]
+	if c.msg != "" {
+		c.msg += " "
+	}
+	if !pos.IsValid() {
+		// The predeclared objects have no position, nor edits.
+		c.msg += strings.TrimSpace(msg)
+		return
+	}
+	posn := r.fset.Position(pos)
+	c.msg += fmt.Sprintf("%s (%s:%d:%d)", strings.TrimSpace(msg), filepath.Base(posn.Filename), posn.Line, posn.Column)
+	c.pos = append(c.pos, pos)
 }
 
 // check performs safety checks of the renaming of the 'from' object to r.to.
@@ -115,10 +135,15 @@ func (r *renamer) checkInPackageBlock(from types.Object) {
 		}
 	}
 
-	// Check for conflicts between package block and all file blocks.
+	// Check for conflicts between package block and all file blocks. A
+	// conflict with a dot-imported object is resolved by turning the dot
+	// import into a regular import.
 	for _, f := range pkg.GetSyntax() {
 		fileScope := pkg.GetTypesInfo().Scopes[f]
 		b, prev := fileScope.LookupParent(r.to, token.NoPos)
+		if b == fileScope && r.resolveByDotImport(pkg, f.Pos(), prev, fmt.Sprintf("%s would collide with the dot-imported %s %s", r.to, objectKind(prev), r.to)) {
+			continue
+		}
 		if b == fileScope {
 			r.errorf(from.Pos(), "renaming this %s %q to %q would conflict", objectKind(from), from.Name(), r.to)
 			var prevPos token.Pos
@@ -195,13 +220,21 @@ func (r *renamer) checkInLexicalScope(from types.Object, pkg Package) {
 			// Is that name referenced from within this block?
 			forEachLexicalRef(pkg, to, func(id *ast.Ident, block *types.Scope) bool {
 				_, obj := block.LookupParent(from.Name(), id.Pos())
+				if obj == from && r.resolveByDotImport(pkg, id.Pos(), to, fmt.Sprintf("%s would shadow the references to the dot-imported %s %s", r.to, objectKind(to), r.to)) {
+					return true
+				}
 				if obj == from {
 					// super-block conflict
 					r.errorf(from.Pos(), "renaming this %s %q to %q",
 						objectKind(from), from.Name(), r.to)
 					r.errorf(id.Pos(), "\twould shadow this reference")
-					r.errorf(to.Pos(), "\tto the %s declared here",
-						objectKind(to))
+					if toBlock == types.Universe {
+						r.errorf(token.NoPos, "\tto the predeclared %s %s",
+							objectKind(to), to.Name())
+					} else {
+						r.errorf(to.Pos(), "\tto the %s declared here",
+							objectKind(to))
+					}
 					return false // stop
 				}
 				return true
@@ -212,12 +245,15 @@ func (r *renamer) checkInLexicalScope(from types.Object, pkg Package) {
 	// Is there an intervening definition of r.to between
 	// the block defining 'from' and some reference to it?
 	forEachLexicalRef(pkg, from, func(id *ast.Ident, block *types.Scope) bool {
+		if r.qualifiedFiles[span.URIFromPath(r.fset.File(id.Pos()).Name())] {
+			return true // the reference will be qualified
+		}
 		// Find the block that defines the found reference.
 		// It may be an ancestor.
 		fromBlock, _ := block.LookupParent(from.Name(), id.Pos())
 		// See what r.to would resolve to in the same scope.
 		toBlock, to := block.LookupParent(r.to, id.Pos())
-		if to != nil {
+		if to != nil && !r.isConvertedDotImport(id, to) {
 			// sub-block conflict
 			if deeper(toBlock, fromBlock) {
 				r.errorf(from.Pos(), "renaming this %s %q to %q",
@@ -748,8 +784,11 @@ func (r *renamer) checkMethod(from *types.Func) {
 				}
 				r.errorf(pos, "\twould make %s no longer assignable to %s",
 					key.RHS, iface)
-				r.errorf(imeth.Pos(), "\t(rename %s.%s if you intend to change both types)",
-					I, from.Name())
+				hint := fmt.Sprintf("%s.%s", I, from.Name())
+				if named, ok := I.(*types.Named); ok && isLocal(named.Obj()) {
+					hint = interfaceName(named.Obj()) + "." + from.Name()
+				}
+				r.errorf(imeth.Pos(), "\t(rename %s if you intend to change both types)", hint)
 				return // one error is enough
 			}
 
@@ -781,8 +820,9 @@ func (r *renamer) checkExport(id *ast.Ident, pkg *types.Package, from types.Obje
 // satisfy returns the set of interface satisfaction constraints.
 func (r *renamer) satisfy() map[satisfy.Constraint]bool {
 	if r.satisfyConstraints == nil {
-		// Compute on demand: it's expensive.
-		var f satisfy.Finder
+		// Compute on demand: it's expensive. The constraints of each
+		// package are cached across snapshots.
+		constraints := make(map[satisfy.Constraint]bool)
 		for _, pkg := range r.packages {
 			// From satisfy.Finder documentation:
 			//
@@ -790,20 +830,73 @@ func (r *renamer) satisfy() map[satisfy.Constraint]bool {
 			// info.{Defs,Uses,Selections,Types} must have been populated by the
 			// type-checker.
 			//
-			// Only proceed if all packages have no errors.
-			if pkg.HasListOrParseErrors() || pkg.HasTypeErrors() {
+			// Only proceed if all packages have no errors, other than the
+			// syntax errors of files left out of the search.
+			files, ok := satisfiableFiles(pkg)
+			if !ok {
 				r.errorf(token.NoPos, // we don't have a position for this error.
 					"renaming %q to %q not possible because %q has errors",
 					r.from, r.to, pkg.PkgPath())
 				return nil
 			}
-			f.Find(pkg.GetTypesInfo(), pkg.GetSyntax())
+			for c := range renameConstraints(r.snapshot, pkg, files) {
+				constraints[c] = true
+			}
+		}
+
+		// Assignability may also be established in importers that never
+		// mention the renamed method, for example by a closure, goroutine or
+		// deferred function literal assigning the receiver type to an
+		// interface. The Finder visits such function bodies, so it suffices
+		// to scan the reverse dependencies too. Unlike the packages above,
+		// they are skipped rather than rejected if they have errors.
+		rdeps, err := r.reverseDependencies()
+		if err != nil {
+			r.errorf(token.NoPos, "%v", err)
+			return nil
+		}
+		for _, rdep := range rdeps {
+			if files, ok := satisfiableFiles(rdep); ok {
+				for c := range renameConstraints(r.snapshot, rdep, files) {
+					constraints[c] = true
+				}
+			}
 		}
-		r.satisfyConstraints = f.Result
+		r.satisfyConstraints = constraints
 	}
 	return r.satisfyConstraints
 }
 
+// reverseDependencies returns the reverse dependencies of r.packages that
+// are not themselves in r.packages.
+func (r *renamer) reverseDependencies() ([]Package, error) {
+	seen := make(map[*types.Package]bool)
+	for typ := range r.packages {
+		seen[typ] = true
+	}
+	var ids []string
+	for _, pkg := range r.packages {
+		ids = append(ids, pkg.ID())
+	}
+	if err := checkRenameScale(r.ctx, r.snapshot, r.from, ids, true); err != nil {
+		return nil, err
+	}
+	var rdeps []Package
+	for _, pkg := range r.packages {
+		pkgRdeps, err := r.snapshot.GetReverseDependencies(r.ctx, pkg.ID())
+		if err != nil {
+			return nil, err
+		}
+		for _, rdep := range pkgRdeps {
+			if !seen[rdep.GetTypes()] {
+				seen[rdep.GetTypes()] = true
+				rdeps = append(rdeps, rdep)
+			}
+		}
+	}
+	return rdeps, nil
+}
+
 // -- helpers ----------------------------------------------------------
 
 // recv returns the method's receiver.
@@ -0,0 +1,517 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/safetoken"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/diff"
+)
+
+// A commentMention is a mention of a declared object within a comment,
+// either as a word of the object's doc comment or as a doc link.
+type commentMention struct {
+	uri span.URI          // file declaring the object
+	pos protocol.Position // start of the object's declaring identifier
+	rng protocol.Range    // the mention itself
+}
+
+// findCommentMention returns the mention of a declared object at position
+// pp of the file pgf, or nil if pp is not within a comment or does not
+// denote an object.
+//
+// Within a doc comment, a word is a mention of the declaration it documents
+// if it is spelled the same as the declared name. In any comment, the
+// segments of a doc link such as [T], [T.M] or [pkg.T] mention the objects
+// they denote from the file.
+func findCommentMention(ctx context.Context, snapshot Snapshot, pgf *ParsedGoFile, pp protocol.Position) (*commentMention, error) {
+	pos, err := pgf.Mapper.Pos(pp)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		group   *ast.CommentGroup
+		comment *ast.Comment
+	)
+	for _, cg := range pgf.File.Comments {
+		for _, c := range cg.List {
+			if c.Pos() <= pos && pos < c.End() {
+				group, comment = cg, c
+			}
+		}
+	}
+	if comment == nil || isDirective(comment.Text) {
+		return nil, nil
+	}
+
+	// Find the identifier-like word under the cursor.
+	text, err := commentSource(pgf, comment)
+	if err != nil {
+		return nil, err
+	}
+	offset := int(pos - comment.Pos())
+	start, end := offset, offset
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(text[:start])
+		if !isIdentRune(r) {
+			break
+		}
+		start -= size
+	}
+	for end < len(text) {
+		r, size := utf8.DecodeRuneInString(text[end:])
+		if !isIdentRune(r) {
+			break
+		}
+		end += size
+	}
+	if start == end || !isValidIdentifier(text[start:end]) {
+		return nil, nil
+	}
+	mentionRange := func() (protocol.Range, error) {
+		return NewMappedRange(pgf.Tok, pgf.Mapper, comment.Pos()+token.Pos(start), comment.Pos()+token.Pos(end)).Range()
+	}
+
+	if link, i := docLinkAt(text, start, end); link != nil {
+		pkg, err := snapshot.PackageForFile(ctx, pgf.URI, TypecheckWorkspace, NarrowestPackage)
+		if err != nil {
+			return nil, err
+		}
+		typed, err := pkg.File(pgf.URI)
+		if err != nil {
+			return nil, err
+		}
+		obj := resolveDocLink(pkg, typed.File, link[:i+1])
+		if obj == nil || obj.Pkg() == nil || !obj.Pos().IsValid() {
+			return nil, nil
+		}
+		declRange, err := renameMappedRange(snapshot.FileSet(), pkg, obj.Pos(), objNameEnd(obj))
+		if err != nil {
+			return nil, err
+		}
+		declRng, err := declRange.Range()
+		if err != nil {
+			return nil, err
+		}
+		rng, err := mentionRange()
+		if err != nil {
+			return nil, err
+		}
+		return &commentMention{uri: declRange.URI(), pos: declRng.Start, rng: rng}, nil
+	}
+
+	// Otherwise, the word must name the declaration that group documents.
+	word := text[start:end]
+	var decl *ast.Ident
+	ast.Inspect(pgf.File, func(n ast.Node) bool {
+		if decl != nil {
+			return false
+		}
+		var (
+			doc   *ast.CommentGroup
+			names []*ast.Ident
+		)
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			doc, names = n.Doc, []*ast.Ident{n.Name}
+		case *ast.GenDecl:
+			doc = n.Doc
+			for _, spec := range n.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					names = append(names, spec.Name)
+				case *ast.ValueSpec:
+					names = append(names, spec.Names...)
+				}
+			}
+		case *ast.TypeSpec:
+			doc, names = n.Doc, []*ast.Ident{n.Name}
+		case *ast.ValueSpec:
+			doc, names = n.Doc, n.Names
+		case *ast.Field:
+			doc, names = n.Doc, n.Names
+		}
+		if doc == group {
+			for _, id := range names {
+				if id.Name == word {
+					decl = id
+				}
+			}
+		}
+		return true
+	})
+	if decl == nil {
+		return nil, nil
+	}
+	declRng, err := NewMappedRange(pgf.Tok, pgf.Mapper, decl.Pos(), decl.End()).Range()
+	if err != nil {
+		return nil, err
+	}
+	rng, err := mentionRange()
+	if err != nil {
+		return nil, err
+	}
+	return &commentMention{uri: pgf.URI, pos: declRng.Start, rng: rng}, nil
+}
+
+// docLinkAt reports whether the word text[start:end] is a segment of a doc
+// link such as [T], [T.M] or [*T]. If so, it returns the segments of the
+// link and the index of the word among them.
+func docLinkAt(text string, start, end int) ([]string, int) {
+	lbrack := strings.LastIndexByte(text[:start], '[')
+	rbrack := strings.IndexByte(text[end:], ']')
+	if lbrack < 0 || rbrack < 0 {
+		return nil, 0
+	}
+	link := strings.TrimPrefix(text[lbrack+1:end+rbrack], "*")
+	segs := strings.Split(link, ".")
+	for _, seg := range segs {
+		if !isValidIdentifier(seg) {
+			return nil, 0
+		}
+	}
+	return segs, strings.Count(text[lbrack+1:start], ".")
+}
+
+// resolveDocLink returns the object denoted by the segments of a doc link
+// in file of pkg, or nil if there is none. The first segment is either a
+// package-level name of pkg, as in [T] or [T.M], or the name of a package
+// imported by file, as in [pkg.T] or [pkg.T.M].
+func resolveDocLink(pkg Package, file *ast.File, segs []string) types.Object {
+	scope := pkg.GetTypes().Scope()
+	if scope.Lookup(segs[0]) == nil && len(segs) > 1 {
+		imported := importedPackage(pkg, file, segs[0])
+		if imported == nil {
+			return nil
+		}
+		scope, segs = imported.Scope(), segs[1:]
+	}
+	obj := scope.Lookup(segs[0])
+	switch {
+	case obj == nil || len(segs) > 2:
+		return nil
+	case len(segs) == 1:
+		return obj
+	}
+	tname, ok := obj.(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	obj, _, _ = types.LookupFieldOrMethod(tname.Type(), true, tname.Pkg(), segs[1])
+	return obj
+}
+
+// importedPackage returns the package imported by file under the given
+// name, or nil if there is none.
+func importedPackage(pkg Package, file *ast.File, name string) *types.Package {
+	for _, imp := range file.Imports {
+		obj := pkg.GetTypesInfo().Implicits[imp]
+		if imp.Name != nil {
+			obj = pkg.GetTypesInfo().Defs[imp.Name]
+		}
+		if pkgName, ok := obj.(*types.PkgName); ok && pkgName.Name() == name {
+			return pkgName.Imported()
+		}
+	}
+	return nil
+}
+
+// docLinkRegexp matches doc links, capturing their text.
+var docLinkRegexp = regexp.MustCompile(`\[\*?([\pL_][\pL\pN_]*(?:\.[\pL_][\pL\pN_]*)*)\]`)
+
+// qualifiedRegexp matches the qualified identifiers of comments, such as
+// pkg.T, T.M or pkg.T.M.
+var qualifiedRegexp = regexp.MustCompile(`[\pL_][\pL\pN_]*(?:\.[\pL_][\pL\pN_]*)+`)
+
+// updateCommentReferences returns edits renaming the segments of doc links,
+// such as [T.M] or [pkg.T], and of qualified mentions, such as pkg.T or
+// T.M, that denote a renamed object, in the comments of the packages that
+// refer to it or may do so. A qualified mention must resolve as a whole, as
+// a doc link would, so that prose such as "e.g." is left alone.
+func (r *renamer) updateCommentReferences() (map[span.URI][]diff.Edit, error) {
+	renamed := make(map[token.Position]bool)
+	for obj := range r.objsToUpdate {
+		renamed[r.fset.Position(obj.Pos())] = true
+	}
+	rdeps, err := r.reverseDependencies()
+	if err != nil {
+		return nil, err
+	}
+	pkgs := rdeps
+	for _, pkg := range r.packages {
+		pkgs = append(pkgs, pkg)
+	}
+
+	result := make(map[span.URI][]diff.Edit)
+	seen := make(map[positionKey]bool) // files may belong to several packages
+	for _, pkg := range pkgs {
+		for _, pgf := range pkg.CompiledGoFiles() {
+			// rename renames the segments of the reference c.Text[start:end]
+			// that denote a renamed object.
+			rename := func(c *ast.Comment, text string, start, end int) error {
+				segs := strings.Split(text[start:end], ".")
+				for i, seg := range segs {
+					obj := resolveDocLink(pkg, pgf.File, segs[:i+1])
+					if obj != nil && seg == r.from && renamed[r.fset.Position(obj.Pos())] {
+						pos := c.Pos() + token.Pos(start)
+						uri, offset, end, err := editRange(r.snapshot, pgf.Tok, pos, pos+token.Pos(len(seg)))
+						if err != nil && err != errCgoGenerated {
+							return err
+						}
+						if key := (positionKey{uri, offset}); err == nil && !seen[key] {
+							seen[key] = true
+							result[uri] = append(result[uri], diff.Edit{Start: offset, End: end, New: r.to})
+						}
+					}
+					start += len(seg) + len(".")
+				}
+				return nil
+			}
+			for _, cg := range pgf.File.Comments {
+				skip := nonProse(pgf.Tok, cg, r.snapshot.View().Options().RenameCommentScope)
+				for _, c := range cg.List {
+					if isDirective(c.Text) {
+						continue
+					}
+					text, err := commentSource(pgf, c)
+					if err != nil {
+						return nil, err
+					}
+					links := docLinkRegexp.FindAllStringSubmatchIndex(text, -1)
+					for _, m := range links {
+						if skip(c.Pos() + token.Pos(m[0])) {
+							continue
+						}
+						if err := rename(c, text, m[2], m[3]); err != nil {
+							return nil, err
+						}
+					}
+				mentions:
+					for _, m := range qualifiedRegexp.FindAllStringIndex(text, -1) {
+						for _, link := range links {
+							if link[0] <= m[0] && m[0] < link[1] {
+								continue mentions
+							}
+						}
+						if prev, _ := utf8.DecodeLastRuneInString(text[:m[0]]); m[0] > 0 && (isIdentRune(prev) || prev == '.' || prev == '/') {
+							continue // within a longer word, such as a domain name or an import path
+						}
+						segs := strings.Split(text[m[0]:m[1]], ".")
+						if skip(c.Pos()+token.Pos(m[0])) || resolveDocLink(pkg, pgf.File, segs) == nil {
+							continue
+						}
+						if err := rename(c, text, m[0], m[1]); err != nil {
+							return nil, err
+						}
+					}
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// docMentions returns the matches within text, the source of a comment of
+// file in pkg, of the words that mention an object of package objPkg under
+// the old name of m.
+//
+// A mention is a whole word, so that other words containing the name, such
+// as NewFoo or Foo_bar for Foo, are not mentions, and it must be spelled
+// in one of the forms of m, by default exactly as the name. Words qualified
+// by the name of another package imported by file, as in bytes.Buffer,
+// denote objects of that package and are not mentions either. Words within
+// doc links are skipped, as updateCommentReferences renames those that
+// resolve to the object.
+func docMentions(text string, m *nameMatcher, pkg Package, file *ast.File, objPkg *types.Package) []nameMatch {
+	links := docLinkRegexp.FindAllStringIndex(text, -1)
+	inLink := func(offset int) bool {
+		for _, link := range links {
+			if link[0] <= offset && offset < link[1] {
+				return true
+			}
+		}
+		return false
+	}
+
+	var mentions []nameMatch
+	for _, match := range m.matches(text) {
+		if !inLink(match.start) && !qualifiedByOtherPackage(text, match.start, pkg, file, objPkg) {
+			mentions = append(mentions, match)
+		}
+	}
+	return mentions
+}
+
+// qualifiedByOtherPackage reports whether the word at offset start of text
+// is qualified, as in pkg.Name, by the name of a package other than objPkg
+// imported by file.
+func qualifiedByOtherPackage(text string, start int, pkg Package, file *ast.File, objPkg *types.Package) bool {
+	if start == 0 || text[start-1] != '.' || file == nil {
+		return false
+	}
+	end := start - 1
+	qualStart := end
+	for qualStart > 0 {
+		r, size := utf8.DecodeLastRuneInString(text[:qualStart])
+		if !isIdentRune(r) {
+			break
+		}
+		qualStart -= size
+	}
+	if qualStart == end {
+		return false
+	}
+	imported := importedPackage(pkg, file, text[qualStart:end])
+	return imported != nil && imported != objPkg
+}
+
+// commentSource returns the source of the comment c of pgf. Unlike c.Text,
+// from which go/parser strips the \r of CRLF line endings, it has the
+// offsets of the file content.
+func commentSource(pgf *ParsedGoFile, c *ast.Comment) (string, error) {
+	start, err := safetoken.Offset(pgf.Tok, c.Pos())
+	if err != nil {
+		return "", err
+	}
+	src := pgf.Src[start:]
+	if strings.HasPrefix(c.Text, "//") {
+		if i := bytes.IndexByte(src, '\n'); i >= 0 {
+			src = src[:i]
+		}
+		return string(bytes.TrimSuffix(src, []byte("\r"))), nil
+	}
+	i := bytes.Index(src, []byte("*/"))
+	if i < 0 {
+		return "", fmt.Errorf("unterminated comment at offset %d of %s", start, pgf.URI)
+	}
+	return string(src[:i+len("*/")]), nil
+}
+
+// A commentLine is a text line of a comment group, without its comment
+// marker.
+type commentLine struct {
+	text      string
+	pos       token.Pos // the position of text
+	directive bool      // a //-style directive, such as //go:generate
+}
+
+// commentLines returns the text lines of the comments of cg, which belongs
+// to tokFile.
+func commentLines(tokFile *token.File, cg *ast.CommentGroup) []commentLine {
+	var lines []commentLine
+	for _, c := range cg.List {
+		if strings.HasPrefix(c.Text, "//") {
+			text, pos := c.Text[len("//"):], c.Pos()+token.Pos(len("//"))
+			if strings.HasPrefix(text, " ") {
+				text, pos = text[1:], pos+1
+			}
+			lines = append(lines, commentLine{text, pos, isDirective(c.Text)})
+			continue
+		}
+		// go/parser strips the \r of /*-style comments, so the positions of
+		// their lines are those of the file.
+		line := tokFile.Line(c.Pos())
+		for i, text := range strings.Split(c.Text[len("/*"):len(c.Text)-len("*/")], "\n") {
+			pos := c.Pos() + token.Pos(len("/*"))
+			if i > 0 {
+				pos = tokFile.LineStart(line + i)
+			}
+			lines = append(lines, commentLine{text, pos, false})
+		}
+	}
+	return lines
+}
+
+// isLeadingName reports whether the position pos of the doc comment group
+// doc of tokFile lies at its leading word, which by convention names the
+// documented declaration, possibly after an article as in "A Foo is".
+func isLeadingName(tokFile *token.File, doc *ast.CommentGroup, pos token.Pos) bool {
+	var before []string
+	for _, l := range commentLines(tokFile, doc) {
+		if l.pos <= pos && pos <= l.pos+token.Pos(len(l.text)) {
+			before = append(before, l.text[:pos-l.pos])
+			break
+		}
+		before = append(before, l.text)
+	}
+	switch words := strings.Fields(strings.Join(before, " ")); len(words) {
+	case 0:
+		return true
+	case 1:
+		return words[0] == "A" || words[0] == "An" || words[0] == "The"
+	}
+	return false
+}
+
+// quotedRegexp matches the double-quoted text of comments.
+var quotedRegexp = regexp.MustCompile(`"[^"]*"`)
+
+// nonProse returns a function reporting whether a position of the comment
+// group cg of tokFile lies outside its prose: within a code block, indented
+// or fenced by ```, a URL, double-quoted text or a directive line. If scope
+// is AllComments, no position of cg is reported.
+func nonProse(tokFile *token.File, cg *ast.CommentGroup, scope CommentScope) func(token.Pos) bool {
+	if scope == AllComments || cg == nil {
+		return func(token.Pos) bool { return false }
+	}
+	lines := commentLines(tokFile, cg)
+	texts := make([]string, len(lines))
+	for i, l := range lines {
+		texts[i] = l.text
+	}
+	code, urls := commentStructure(texts)
+
+	type interval struct{ start, end token.Pos }
+	var skipped []interval
+	fenced := false
+	for i, l := range lines {
+		fence := strings.HasPrefix(strings.TrimSpace(l.text), "```")
+		if code[i] || fenced || fence || l.directive {
+			skipped = append(skipped, interval{l.pos, l.pos + token.Pos(len(l.text))})
+			if fence {
+				fenced = !fenced
+			}
+			continue
+		}
+		for _, url := range urls {
+			for offset := 0; url != ""; {
+				i := strings.Index(l.text[offset:], url)
+				if i < 0 {
+					break
+				}
+				start := l.pos + token.Pos(offset+i)
+				skipped = append(skipped, interval{start, start + token.Pos(len(url))})
+				offset += i + len(url)
+			}
+		}
+		for _, q := range quotedRegexp.FindAllStringIndex(l.text, -1) {
+			skipped = append(skipped, interval{l.pos + token.Pos(q[0]), l.pos + token.Pos(q[1])})
+		}
+	}
+	return func(pos token.Pos) bool {
+		for _, iv := range skipped {
+			if iv.start <= pos && pos < iv.end {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,29 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !go1.19
+// +build !go1.19
+
+package source
+
+import (
+	"regexp"
+	"strings"
+)
+
+// urlRegexp matches the URLs of comments.
+var urlRegexp = regexp.MustCompile(`[a-z][a-z0-9+.-]*://[^\s"'<>]*[^\s"'<>.,:;!?)\]]`)
+
+// commentStructure reports which of lines, the text lines of a comment
+// group without their comment markers, belong to code blocks, and returns
+// the URLs of the comment. Without go/doc/comment, code blocks are
+// recognized as indented lines, as by go1.18's go/doc.
+func commentStructure(lines []string) (code []bool, urls []string) {
+	code = make([]bool, len(lines))
+	for i, line := range lines {
+		code[i] = strings.TrimSpace(line) != "" && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t"))
+		urls = append(urls, urlRegexp.FindAllString(line, -1)...)
+	}
+	return code, urls
+}
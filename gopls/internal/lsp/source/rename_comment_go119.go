@@ -0,0 +1,69 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.19
+// +build go1.19
+
+package source
+
+import (
+	"go/doc/comment"
+	"strings"
+)
+
+// commentStructure parses lines, the text lines of a comment group without
+// their comment markers, as a doc comment. It reports which lines belong to
+// code blocks, and returns the URLs of the links of the comment.
+func commentStructure(lines []string) (code []bool, urls []string) {
+	var p comment.Parser
+	doc := p.Parse(strings.Join(lines, "\n"))
+
+	code = make([]bool, len(lines))
+	next := 0 // the blocks of doc appear in the order of lines
+	var addURLs func(text []comment.Text)
+	addURLs = func(text []comment.Text) {
+		for _, t := range text {
+			switch t := t.(type) {
+			case *comment.Link:
+				if t.Auto {
+					urls = append(urls, t.URL)
+				}
+				addURLs(t.Text)
+			case *comment.DocLink:
+				addURLs(t.Text)
+			}
+		}
+	}
+	for _, block := range doc.Content {
+		switch block := block.(type) {
+		case *comment.Paragraph:
+			addURLs(block.Text)
+		case *comment.Heading:
+			addURLs(block.Text)
+		case *comment.List:
+			for _, item := range block.Items {
+				for _, b := range item.Content {
+					if para, ok := b.(*comment.Paragraph); ok {
+						addURLs(para.Text)
+					}
+				}
+			}
+		case *comment.Code:
+			for _, line := range strings.Split(strings.TrimSuffix(block.Text, "\n"), "\n") {
+				line = strings.TrimSpace(line)
+				for i := next; i < len(lines); i++ {
+					if strings.TrimSpace(lines[i]) == line {
+						code[i] = true
+						next = i + 1
+						break
+					}
+				}
+			}
+		}
+	}
+	for _, def := range doc.Links {
+		urls = append(urls, def.URL)
+	}
+	return code, urls
+}
@@ -0,0 +1,224 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"go/ast"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+// columnTagKeys holds the keys of the struct tags that map fields to
+// database columns, as used by sqlx (db), GORM (gorm) and Bun (bun).
+var columnTagKeys = []string{"db", "gorm", "bun"}
+
+// columnTagEdits returns the alternative edits of the column tags of the
+// field obj when it is renamed to newName.
+//
+// If a tag names the column after the field, as in `db:"foo_bar"` for a
+// field FooBar, update renames the column to match the new field name,
+// which requires a migration of the schema. If the field has no column tag
+// although other fields of its struct do, its column is implicitly named
+// after the field, and keep names the old column explicitly, leaving the
+// schema unchanged.
+func columnTagEdits(s Snapshot, qo qualifiedObject, newName string) (update, keep map[span.URI][]protocol.TextEdit, _ error) {
+	v, ok := qo.obj.(*types.Var)
+	if !ok || !v.IsField() || v.Pkg() == nil {
+		return nil, nil, nil
+	}
+	uri, _, _, err := editRange(s, s.FileSet().File(v.Pos()), v.Pos(), v.Pos())
+	if err != nil {
+		return nil, nil, err
+	}
+	pgf, err := qo.pkg.File(uri)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		field *ast.Field
+		st    *ast.StructType
+	)
+	ast.Inspect(pgf.File, func(n ast.Node) bool {
+		if s, ok := n.(*ast.StructType); ok && field == nil {
+			for _, f := range s.Fields.List {
+				if len(f.Names) == 1 && f.Names[0].Pos() == v.Pos() {
+					field, st = f, s
+				}
+			}
+		}
+		return field == nil
+	})
+	if field == nil {
+		return nil, nil, nil
+	}
+	var tag string
+	if field.Tag != nil {
+		tag, _ = strconv.Unquote(field.Tag.Value)
+	}
+
+	updated, kept := tag, tag
+	for _, key := range columnTagKeys {
+		start, end, ok := tagValueSpan(kept, key)
+		if ok && kept[start:end] == "-" {
+			continue // not mapped
+		}
+		if ok {
+			if colStart, colEnd, ok := columnSpan(key, kept[start:end]); ok {
+				// An explicit column: offer to rename it.
+				col := kept[start+colStart : start+colEnd]
+				var newCol string
+				switch col {
+				case snakeCase(v.Name()):
+					newCol = snakeCase(newName)
+				case strings.ToLower(v.Name()):
+					newCol = strings.ToLower(newName)
+				default:
+					continue
+				}
+				ustart, uend, _ := tagValueSpan(updated, key)
+				value := updated[ustart:uend]
+				uc, ue, _ := columnSpan(key, value)
+				updated = updated[:ustart] + value[:uc] + newCol + value[ue:] + updated[uend:]
+				continue
+			}
+		} else if !structUsesTag(st, key) {
+			continue
+		}
+
+		// An implicit column: offer to name it explicitly.
+		col := snakeCase(v.Name())
+		if key == "db" {
+			col = strings.ToLower(v.Name()) // the default mapping of sqlx
+		}
+		switch {
+		case !ok:
+			if key == "gorm" {
+				col = "column:" + col
+			}
+			kept = strings.TrimSpace(kept + " " + key + `:"` + col + `"`)
+		case key == "gorm":
+			sep := ";"
+			if start == end {
+				sep = ""
+			}
+			kept = kept[:start] + "column:" + col + sep + kept[start:]
+		default:
+			kept = kept[:start] + col + kept[start:]
+		}
+	}
+
+	// tagEdits returns the edit of field's tag to newTag, if it changed.
+	tagEdits := func(newTag string) (map[span.URI][]protocol.TextEdit, error) {
+		if newTag == tag {
+			return nil, nil
+		}
+		lit := "`" + newTag + "`"
+		if strings.Contains(newTag, "`") {
+			lit = strconv.Quote(newTag)
+		}
+		start, end := field.Type.End(), field.Type.End()
+		if field.Tag != nil {
+			start, end = field.Tag.Pos(), field.Tag.End()
+		} else {
+			lit = " " + lit
+		}
+		rng, err := NewMappedRange(pgf.Tok, pgf.Mapper, start, end).Range()
+		if err != nil {
+			return nil, err
+		}
+		return map[span.URI][]protocol.TextEdit{uri: {{Range: rng, NewText: lit}}}, nil
+	}
+	if update, err = tagEdits(updated); err != nil {
+		return nil, nil, err
+	}
+	if keep, err = tagEdits(kept); err != nil {
+		return nil, nil, err
+	}
+	return update, keep, nil
+}
+
+// structUsesTag reports whether a field of st has a tag with the given key.
+func structUsesTag(st *ast.StructType, key string) bool {
+	for _, f := range st.Fields.List {
+		if f.Tag == nil {
+			continue
+		}
+		if tag, err := strconv.Unquote(f.Tag.Value); err == nil {
+			if _, _, ok := tagValueSpan(tag, key); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tagValueSpan returns the byte range of the value of key in a struct tag
+// in the conventional format key:"value" key2:"value2", excluding quotes.
+func tagValueSpan(tag, key string) (start, end int, ok bool) {
+	i := 0
+	for i < len(tag) {
+		for i < len(tag) && tag[i] == ' ' {
+			i++
+		}
+		j := i
+		for j < len(tag) && tag[j] > ' ' && tag[j] != ':' && tag[j] != '"' && tag[j] != 0x7f {
+			j++
+		}
+		if j == i || j+1 >= len(tag) || tag[j] != ':' || tag[j+1] != '"' {
+			return 0, 0, false
+		}
+		k := j + 2
+		for k < len(tag) && tag[k] != '"' {
+			if tag[k] == '\\' {
+				k++
+			}
+			k++
+		}
+		if k >= len(tag) {
+			return 0, 0, false
+		}
+		if tag[i:j] == key {
+			return j + 2, k, true
+		}
+		i = k + 1
+	}
+	return 0, 0, false
+}
+
+// columnSpan returns the byte range of the column name within the value of
+// a column tag with the given key, if it names one: the first element of
+// `db:"name,opts"` and `bun:"name,opts"`, and the column setting of
+// `gorm:"column:name;opts"`.
+func columnSpan(key, value string) (start, end int, ok bool) {
+	switch key {
+	case "gorm":
+		for start < len(value) {
+			end = strings.IndexByte(value[start:], ';')
+			if end < 0 {
+				end = len(value)
+			} else {
+				end += start
+			}
+			if setting := value[start:end]; strings.HasPrefix(strings.ToLower(setting), "column:") {
+				return start + len("column:"), end, true
+			}
+			start = end + 1
+		}
+		return 0, 0, false
+	default:
+		end = strings.IndexByte(value, ',')
+		if end < 0 {
+			end = len(value)
+		}
+		if end == 0 || strings.Contains(value[:end], ":") {
+			return 0, 0, false // no name, or a setting such as bun's table:name
+		}
+		return 0, end, true
+	}
+}
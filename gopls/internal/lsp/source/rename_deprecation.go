@@ -0,0 +1,184 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/safetoken"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+// A deprecationNotice is a paragraph of a comment, starting with
+// "Deprecated:", that points its readers at a renamed object by its bare
+// name, as in "Deprecated: use Foo instead.", with the edits renaming its
+// mentions.
+type deprecationNotice struct {
+	subject string // the deprecated declaration, or the position of the comment, such as a.go:12
+	edits   map[span.URI][]protocol.TextEdit
+}
+
+// mentionRegexp matches the possibly qualified identifiers of comments,
+// such as T, pkg.T or T.M.
+var mentionRegexp = regexp.MustCompile(`[\pL_][\pL\pN_]*(?:\.[\pL_][\pL\pN_]*)*`)
+
+// deprecationNotices returns the deprecation notices of the comments of the
+// package declaring qo.obj and, if it is exported, of its reverse
+// dependencies that mention it by its bare name, in order, with the edits renaming the mentions to
+// newName. The name of a method or field also mentions it in the notices of
+// the other members of its type. The mentions already edited by one of
+// edited, such as qualified ones and doc links, are left out, as are those
+// outside the prose of the comments, according to the renameCommentScope
+// setting.
+func deprecationNotices(ctx context.Context, s Snapshot, qo qualifiedObject, newName string, edited ...map[span.URI][]protocol.TextEdit) ([]deprecationNotice, error) {
+	var pkgs []Package
+	if qo.obj.Exported() && !isLocal(qo.obj) {
+		rdeps, err := loadReverseDependencies(ctx, s, qo.obj.Name(), qo.pkg.ID())
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, rdeps...)
+	}
+	pkgs = append(pkgs, qo.pkg)
+	declPos := s.FileSet().Position(qo.obj.Pos())
+	isEdited := func(uri span.URI, rng protocol.Range) bool {
+		for _, edits := range edited {
+			for _, te := range edits[uri] {
+				if protocol.CompareRange(te.Range, rng) == 0 {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	var notices []deprecationNotice
+	seen := make(map[positionKey]bool) // files may belong to several packages
+	for _, pkg := range pkgs {
+		for _, pgf := range pkg.CompiledGoFiles() {
+			subjects := docSubjects(pgf.File)
+			for _, cg := range pgf.File.Comments {
+				subject, ok := subjects[cg]
+				if !ok {
+					subject = docSubject{name: fmt.Sprintf("%s:%d", filepath.Base(pgf.URI.Filename()), pgf.Tok.Line(cg.Pos()))}
+				}
+				skip := nonProse(pgf.Tok, cg, s.View().Options().RenameCommentScope)
+				notice := deprecationNotice{subject: subject.name, edits: make(map[span.URI][]protocol.TextEdit)}
+				inNotice := false
+				for _, l := range commentLines(pgf.Tok, cg) {
+					text := strings.TrimSpace(l.text)
+					switch {
+					case text == "":
+						inNotice = false
+						continue
+					case strings.HasPrefix(text, "Deprecated:"):
+						inNotice = true
+					}
+					if !inNotice || l.directive {
+						continue
+					}
+					for _, m := range mentionRegexp.FindAllStringIndex(l.text, -1) {
+						if prev, _ := utf8.DecodeLastRuneInString(l.text[:m[0]]); m[0] > 0 && (isIdentRune(prev) || prev == '/') {
+							continue // within a longer word, such as an import path
+						}
+						start := m[0]
+						segs := strings.Split(l.text[m[0]:m[1]], ".")
+						for i, seg := range segs {
+							if seg == qo.obj.Name() && !skip(l.pos+token.Pos(start)) {
+								obj := resolveDocLink(pkg, pgf.File, segs[:i+1])
+								if obj == nil && i == 0 && len(segs) == 1 && subject.typeName != "" {
+									obj = resolveDocLink(pkg, pgf.File, []string{subject.typeName, seg})
+								}
+								if obj != nil && s.FileSet().Position(obj.Pos()) == declPos {
+									pos := l.pos + token.Pos(start)
+									offset, err := safetoken.Offset(pgf.Tok, pos)
+									if err != nil {
+										return nil, err
+									}
+									key := positionKey{pgf.URI, offset}
+									rng, err := pgf.Mapper.PosRange(pos, pos+token.Pos(len(seg)))
+									if err != nil {
+										return nil, err
+									}
+									if !seen[key] && !isEdited(pgf.URI, rng) {
+										seen[key] = true
+										notice.edits[pgf.URI] = append(notice.edits[pgf.URI], protocol.TextEdit{Range: rng, NewText: newName})
+									}
+								}
+							}
+							start += len(seg) + len(".")
+						}
+					}
+				}
+				if len(notice.edits) > 0 {
+					notices = append(notices, notice)
+				}
+			}
+		}
+	}
+	return notices, nil
+}
+
+// A docSubject is the declaration documented by a doc comment.
+type docSubject struct {
+	name     string // such as F, T or T.M
+	typeName string // the type of a method or field, whose other members its doc may name bare
+}
+
+// docSubjects returns the declarations documented by the doc comments of f.
+func docSubjects(f *ast.File) map[*ast.CommentGroup]docSubject {
+	subjects := make(map[*ast.CommentGroup]docSubject)
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Doc == nil {
+				continue
+			}
+			subject := docSubject{name: decl.Name.Name}
+			if decl.Recv != nil {
+				if recv := receiverName(decl); recv != "" {
+					subject = docSubject{name: recv + "." + decl.Name.Name, typeName: recv}
+				}
+			}
+			subjects[decl.Doc] = subject
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				var (
+					doc  *ast.CommentGroup
+					name string
+				)
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					doc, name = spec.Doc, spec.Name.Name
+					ast.Inspect(spec.Type, func(n ast.Node) bool {
+						if field, ok := n.(*ast.Field); ok && field.Doc != nil && len(field.Names) > 0 {
+							subjects[field.Doc] = docSubject{name: name + "." + field.Names[0].Name, typeName: name}
+						}
+						return true
+					})
+				case *ast.ValueSpec:
+					doc, name = spec.Doc, spec.Names[0].Name
+				default:
+					continue
+				}
+				if doc == nil && len(decl.Specs) == 1 {
+					doc = decl.Doc
+				}
+				if doc != nil {
+					subjects[doc] = docSubject{name: name}
+				}
+			}
+		}
+	}
+	return subjects
+}
@@ -0,0 +1,437 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/safetoken"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/diff"
+)
+
+// A dotImportCollision is the collision of the new name of a package-level
+// object with an identifier of a file that dot-imports its package: another
+// import of the file, or a local object that would shadow the references
+// to the object. The renaming resolves it instead of failing, by naming
+// the colliding import afresh, and by qualifying the references of the
+// file with a regular import of the package, which it adds if needed.
+//
+// The new name may also collide with a package-level object of the file's
+// package, or with an object of another dot import of the file, even if
+// the file does not refer to the renamed object: the dot import declares
+// the name in the file block all the same. The renaming then turns the dot
+// import into a regular import, qualifying all the uses of the package in
+// the file.
+type dotImportCollision struct {
+	msg          string
+	uri          span.URI
+	importEdit   *diff.Edit                       // the addition of the regular import, if any
+	qualifyEdits []diff.Edit                      // the qualification of the other uses of the package, if any
+	aliasEdits   map[span.URI][]protocol.TextEdit // the renaming of the colliding import, if any
+}
+
+// resolveDotImportCollisions records in r the collisions of the new name
+// in the files that dot-import the packages of the renamed objects, and
+// how the renaming resolves them.
+func (r *renamer) resolveDotImportCollisions() error {
+	refsByFile := make(map[span.URI][]*ReferenceInfo)
+	var uris []span.URI
+	var imported *types.Package // the package of the renamed objects, if package-level
+	for _, ref := range r.refs {
+		if ref.isDeclaration || ref.ident == nil || !isPackageLevel(ref.obj) {
+			continue
+		}
+		imported = ref.obj.Pkg()
+		if ref.obj.Pkg() == ref.pkg.GetTypes() {
+			continue
+		}
+		if refsByFile[ref.URI()] == nil {
+			uris = append(uris, ref.URI())
+		}
+		refsByFile[ref.URI()] = append(refsByFile[ref.URI()], ref)
+	}
+	if imported == nil || !ast.IsExported(r.to) {
+		return nil
+	}
+	pkgsByFile, err := r.dotImportingFiles(imported.Path())
+	if err != nil {
+		return err
+	}
+	var others []span.URI // the dot-importing files without references
+	for uri := range pkgsByFile {
+		if refsByFile[uri] == nil {
+			others = append(others, uri)
+		}
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i] < others[j] })
+
+	for _, uri := range append(uris, others...) {
+		refs := refsByFile[uri]
+		pkg := pkgsByFile[uri] // any variant of the package of the file
+		if pkg == nil {
+			continue
+		}
+		pgf, err := pkg.File(uri)
+		if err != nil {
+			return err
+		}
+		dotImport := dotImportSpec(pgf.File, imported.Path())
+		info := pkg.GetTypesInfo()
+		scope := pkg.GetTypes().Scope()
+		fileScope := info.Scopes[pgf.File]
+		filename := filepath.Base(pgf.Tok.Name())
+
+		// The references that the dot import resolves are those that
+		// are not selectors, at the same offsets in all the variants.
+		selected := selectorOffsets(pgf)
+		var unqualified []*ReferenceInfo
+		var idents []*ast.Ident // those of pgf
+		for _, ref := range refs {
+			if selected[ref.spanRange.TokFile.Offset(ref.ident.Pos())] {
+				continue
+			}
+			unqualified = append(unqualified, ref)
+			if ref.pkg == pkg {
+				idents = append(idents, ref.ident)
+			}
+		}
+
+		// The new name collides with a package-level object, or with an
+		// object of another dot import: only a regular import avoids
+		// declaring it in the file block.
+		reason := ""
+		if obj := scope.Lookup(r.to); obj != nil {
+			reason = fmt.Sprintf("%s would collide with the package-level %s %s", r.to, objectKind(obj), r.to)
+		} else if obj := fileScope.Lookup(r.to); obj != nil && obj.Pkg() != nil && obj.Pkg().Path() != imported.Path() {
+			if _, ok := obj.(*types.PkgName); !ok {
+				reason = fmt.Sprintf("%s would collide with the %s %s dot-imported from %s", r.to, objectKind(obj), r.to, obj.Pkg().Path())
+			}
+		}
+		if reason != "" {
+			c, err := r.convertDotImport(pgf, pkg, dotImport, imported, selected, unqualified)
+			if err != nil {
+				return err
+			}
+			c.msg = fmt.Sprintf("the dot import of %s in %s is named %s, as %s", imported.Path(), filename, c.importEdit.New, reason)
+			r.collisions = append(r.collisions, c)
+			r.convertedImports[convertedDotImport{uri, imported.Path()}] = true
+			continue
+		}
+		if len(idents) == 0 {
+			continue
+		}
+
+		c := &dotImportCollision{uri: uri}
+		var reasons []string
+
+		// The new name collides with the name of another import.
+		if pkgName, ok := fileScope.Lookup(r.to).(*types.PkgName); ok {
+			alias := freshImportName(pkgName.Name(), fileScope, scope)
+			edits, err := renameObj(r.ctx, r.snapshot, alias, []qualifiedObject{{obj: pkgName, pkg: pkg}}, false)
+			if err != nil {
+				r.errorf(idents[0].Pos(), "renaming this %s %q to %q would conflict", objectKind(refs[0].obj), r.from, r.to)
+				r.errorf(pkgName.Pos(), "\twith this import, which cannot be named %s: %v", alias, err)
+				continue
+			}
+			c.aliasEdits = edits
+			reasons = append(reasons, fmt.Sprintf("the import of %s in %s is named %s, as %s would collide with it", pkgName.Imported().Path(), filename, alias, r.to))
+		}
+
+		// The new name would be shadowed at some of the references.
+		shadowed := false
+		for _, id := range idents {
+			if _, obj := lexicalScope(scope, id).LookupParent(r.to, id.Pos()); obj != nil && isLocal(obj) {
+				shadowed = true
+				break
+			}
+		}
+		if shadowed {
+			qualifier := regularImportName(info, pgf.File, imported.Path())
+			if qualifier == "" {
+				qualifier = freshQualifier(imported.Name(), idents, fileScope, scope)
+				c.importEdit, err = regularImportEdit(pgf, info, dotImport, imported, qualifier, len(idents))
+				if err != nil {
+					return err
+				}
+			}
+			for _, ref := range unqualified {
+				r.qualifiedRefs[ref.ident] = qualifier
+			}
+			r.qualifiedFiles[uri] = true
+			reasons = append(reasons, fmt.Sprintf("the references in %s are qualified by %s, as %s would be shadowed there", filename, qualifier, r.to))
+		}
+		if len(reasons) > 0 {
+			c.msg = strings.Join(reasons, "; ")
+			r.collisions = append(r.collisions, c)
+		}
+	}
+	return nil
+}
+
+// dotImportingFiles returns the files of the packages of r and of their
+// reverse dependencies that dot-import the package path, each with one of
+// the packages it belongs to.
+func (r *renamer) dotImportingFiles(path string) (map[span.URI]Package, error) {
+	rdeps, err := r.reverseDependencies()
+	if err != nil {
+		return nil, err
+	}
+	pkgs := rdeps
+	for _, pkg := range r.packages {
+		pkgs = append(pkgs, pkg)
+	}
+	files := make(map[span.URI]Package)
+	for _, pkg := range pkgs {
+		for _, pgf := range pkg.CompiledGoFiles() {
+			if files[pgf.URI] == nil && dotImportSpec(pgf.File, path) != nil {
+				files[pgf.URI] = pkg
+			}
+		}
+	}
+	return files, nil
+}
+
+// convertDotImport returns the resolution of a collision of the new name in
+// the file pgf of pkg by turning dotImport, its dot import of imported, into
+// a regular import: the naming of the import, and the qualification of all
+// the uses of imported in the file, the references being renamed, refs,
+// included. selected holds the offsets of the selectors of the file.
+func (r *renamer) convertDotImport(pgf *ParsedGoFile, pkg Package, dotImport *ast.ImportSpec, imported *types.Package, selected map[int]bool, refs []*ReferenceInfo) (*dotImportCollision, error) {
+	info := pkg.GetTypesInfo()
+	renamed := make(map[*ast.Ident]bool)
+	for _, ref := range refs {
+		renamed[ref.ident] = true
+	}
+	var uses []*ast.Ident
+	for id, obj := range info.Uses {
+		if obj.Pkg() == nil || obj.Pkg().Path() != imported.Path() || !isPackageLevel(obj) {
+			continue
+		}
+		if pgf.File.Pos() <= id.Pos() && id.Pos() < pgf.File.End() && !selected[pgf.Tok.Offset(id.Pos())] {
+			uses = append(uses, id)
+		}
+	}
+	sort.Slice(uses, func(i, j int) bool { return uses[i].Pos() < uses[j].Pos() })
+
+	qualifier := freshQualifier(imported.Name(), uses, info.Scopes[pgf.File], pkg.GetTypes().Scope())
+	c := &dotImportCollision{uri: pgf.URI}
+	start, err := safetoken.Offset(pgf.Tok, dotImport.Name.Pos())
+	if err != nil {
+		return nil, err
+	}
+	c.importEdit = &diff.Edit{Start: start, End: start + len("."), New: qualifier}
+	for _, id := range uses {
+		if renamed[id] {
+			continue // qualified along with its renaming
+		}
+		offset, err := safetoken.Offset(pgf.Tok, id.Pos())
+		if err != nil {
+			return nil, err
+		}
+		c.qualifyEdits = append(c.qualifyEdits, diff.Edit{Start: offset, End: offset, New: qualifier + "."})
+	}
+	for _, ref := range refs {
+		r.qualifiedRefs[ref.ident] = qualifier
+	}
+	if len(refs) > 0 {
+		r.qualifiedFiles[pgf.URI] = true
+	}
+	return c, nil
+}
+
+// A convertedDotImport identifies a dot import turned into a regular import
+// to resolve a collision: that of the package path in the file uri.
+type convertedDotImport struct {
+	uri  span.URI
+	path string
+}
+
+// resolveByDotImport resolves a conflict of the renaming with obj, the
+// object of another package that the file of pos, in pkg, dot-imports,
+// such as the shadowing of a reference to obj at pos, by turning the dot
+// import into a regular import, and reports whether it could. The
+// resolution is offered as that of a collision; reason describes the
+// conflict.
+func (r *renamer) resolveByDotImport(pkg Package, pos token.Pos, obj types.Object, reason string) bool {
+	if obj == nil || obj.Pkg() == nil || obj.Pkg() == pkg.GetTypes() || !isPackageLevel(obj) {
+		return false
+	}
+	uri := span.URIFromPath(r.fset.File(pos).Name())
+	key := convertedDotImport{uri, obj.Pkg().Path()}
+	if r.convertedImports[key] {
+		return true
+	}
+	for _, c := range r.collisions {
+		if c.uri == uri {
+			return false // resolved otherwise
+		}
+	}
+	pgf, err := pkg.File(uri)
+	if err != nil {
+		return false
+	}
+	dotImport := dotImportSpec(pgf.File, obj.Pkg().Path())
+	if dotImport == nil {
+		return false
+	}
+	c, err := r.convertDotImport(pgf, pkg, dotImport, obj.Pkg(), selectorOffsets(pgf), nil)
+	if err != nil {
+		return false
+	}
+	c.msg = fmt.Sprintf("the dot import of %s in %s is named %s, as %s", obj.Pkg().Path(), filepath.Base(pgf.Tok.Name()), c.importEdit.New, reason)
+	r.collisions = append(r.collisions, c)
+	r.convertedImports[key] = true
+	return true
+}
+
+// isConvertedDotImport reports whether obj, found at the reference id, is
+// the object of a dot import that is turned into a regular import.
+func (r *renamer) isConvertedDotImport(id *ast.Ident, obj types.Object) bool {
+	if obj == nil || obj.Pkg() == nil {
+		return false
+	}
+	uri := span.URIFromPath(r.fset.File(id.Pos()).Name())
+	return r.convertedImports[convertedDotImport{uri, obj.Pkg().Path()}]
+}
+
+// selectorOffsets returns the offsets of the selectors of the selector
+// expressions of pgf.
+func selectorOffsets(pgf *ParsedGoFile) map[int]bool {
+	selected := make(map[int]bool)
+	ast.Inspect(pgf.File, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			selected[pgf.Tok.Offset(sel.Sel.Pos())] = true
+		}
+		return true
+	})
+	return selected
+}
+
+// regularImportEdit returns the edit that imports the package imported,
+// dot-imported by dotImport in the file pgf, under the name qualifier:
+// the naming of the dot import itself, if the n references that will be
+// qualified are its only uses, or else the addition of an import.
+func regularImportEdit(pgf *ParsedGoFile, info *types.Info, dotImport *ast.ImportSpec, imported *types.Package, qualifier string, n int) (*diff.Edit, error) {
+	uses := 0
+	for id, obj := range info.Uses {
+		if obj.Pkg() == imported && isPackageLevel(obj) && pgf.File.Pos() <= id.Pos() && id.Pos() < pgf.File.End() {
+			uses++
+		}
+	}
+	var (
+		pos, end token.Pos
+		text     string
+	)
+	switch decl := enclosingImportDecl(pgf.File, dotImport); {
+	case uses == n:
+		pos, end, text = dotImport.Name.Pos(), dotImport.Name.End(), qualifier
+	case decl.Lparen.IsValid():
+		pos, end, text = dotImport.End(), dotImport.End(), fmt.Sprintf("\n\t%s %s", qualifier, strconv.Quote(imported.Path()))
+	default:
+		pos, end, text = decl.End(), decl.End(), fmt.Sprintf("\nimport %s %s", qualifier, strconv.Quote(imported.Path()))
+	}
+	start, err := safetoken.Offset(pgf.Tok, pos)
+	if err != nil {
+		return nil, err
+	}
+	endOffset, err := safetoken.Offset(pgf.Tok, end)
+	if err != nil {
+		return nil, err
+	}
+	return &diff.Edit{Start: start, End: endOffset, New: text}, nil
+}
+
+// lexicalScope returns the innermost scope of the package of pkgScope
+// enclosing id.
+func lexicalScope(pkgScope *types.Scope, id *ast.Ident) *types.Scope {
+	if scope := pkgScope.Innermost(id.Pos()); scope != nil {
+		return scope
+	}
+	return pkgScope
+}
+
+// dotImportSpec returns the dot import of the package path in f, or nil.
+func dotImportSpec(f *ast.File, path string) *ast.ImportSpec {
+	for _, imp := range f.Imports {
+		if imp.Name != nil && imp.Name.Name == "." && ImportPath(imp) == path {
+			return imp
+		}
+	}
+	return nil
+}
+
+// regularImportName returns the name of an import of the package path in
+// f that is neither a dot nor a blank import, or "" if there is none.
+func regularImportName(info *types.Info, f *ast.File, path string) string {
+	for _, imp := range f.Imports {
+		if ImportPath(imp) != path || imp.Name != nil && (imp.Name.Name == "." || imp.Name.Name == "_") {
+			continue
+		}
+		if imp.Name != nil {
+			return imp.Name.Name
+		}
+		if pkgName, ok := info.Implicits[imp].(*types.PkgName); ok {
+			return pkgName.Name()
+		}
+	}
+	return ""
+}
+
+// enclosingImportDecl returns the import declaration of imp in f.
+func enclosingImportDecl(f *ast.File, imp *ast.ImportSpec) *ast.GenDecl {
+	for _, decl := range f.Decls {
+		if decl, ok := decl.(*ast.GenDecl); ok && decl.Tok == token.IMPORT {
+			for _, spec := range decl.Specs {
+				if spec == imp {
+					return decl
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// freshImportName returns the first of name1, name2, and so on, that is
+// declared neither in fileScope nor in pkgScope.
+func freshImportName(name string, fileScope, pkgScope *types.Scope) string {
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s%d", name, i)
+		if fileScope.Lookup(candidate) == nil && pkgScope.Lookup(candidate) == nil {
+			return candidate
+		}
+	}
+}
+
+// freshQualifier returns name, or else the first of name1, name2, and so
+// on, that is declared neither in fileScope nor in pkgScope, and visible
+// at none of the references ids in the package of pkgScope, so that it can
+// qualify them.
+func freshQualifier(name string, ids []*ast.Ident, fileScope, pkgScope *types.Scope) string {
+	for i := 0; ; i++ {
+		candidate := name
+		if i > 0 {
+			candidate = fmt.Sprintf("%s%d", name, i)
+		}
+		free := fileScope.Lookup(candidate) == nil && pkgScope.Lookup(candidate) == nil
+		for _, id := range ids {
+			if _, obj := lexicalScope(pkgScope, id).LookupParent(candidate, id.Pos()); obj != nil {
+				free = false
+				break
+			}
+		}
+		if free {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/types/typeutil"
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+// errorConstructors identifies the functions constructing errors from a
+// message.
+var errorConstructors = []callMatcher{
+	{"errors", "", "New"},
+	{"fmt", "", "Errorf"},
+}
+
+// errorMessageEdits returns the edits renaming the old name of m in the
+// messages of the errors constructed in the files of pkgs, such as
+// errors.New("Foo failed") or fmt.Errorf("foo: %v", err): within the
+// string literals of the messages, possibly concatenated, the matches of m.
+// Files shared by several packages are visited once.
+func errorMessageEdits(pkgs []Package, m *nameMatcher) (map[span.URI][]protocol.TextEdit, error) {
+	edits := make(map[span.URI][]protocol.TextEdit)
+	seen := make(map[*ast.File]bool)
+	for _, pkg := range pkgs {
+		info := pkg.GetTypesInfo()
+		for _, pgf := range pkg.CompiledGoFiles() {
+			if seen[pgf.File] {
+				continue
+			}
+			seen[pgf.File] = true
+			var lits []*ast.BasicLit
+			ast.Inspect(pgf.File, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok || len(call.Args) == 0 {
+					return true
+				}
+				fn, ok := typeutil.Callee(info, call).(*types.Func)
+				if !ok {
+					return true
+				}
+				for _, m := range errorConstructors {
+					if m.matches(fn) {
+						lits = append(lits, messageLiterals(call.Args[0])...)
+						break
+					}
+				}
+				return true
+			})
+			for _, lit := range lits {
+				if err := addLiteralMatches(edits, pgf, lit, m); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return edits, nil
+}
+
+// messageLiterals returns the string literals of the message e, a literal
+// or a concatenation of literals, or nil if it is neither.
+func messageLiterals(e ast.Expr) []*ast.BasicLit {
+	switch e := e.(type) {
+	case *ast.ParenExpr:
+		return messageLiterals(e.X)
+	case *ast.BasicLit:
+		if e.Kind == token.STRING {
+			return []*ast.BasicLit{e}
+		}
+	case *ast.BinaryExpr:
+		if e.Op == token.ADD {
+			x, y := messageLiterals(e.X), messageLiterals(e.Y)
+			if x != nil && y != nil {
+				return append(x, y...)
+			}
+		}
+	}
+	return nil
+}
+
+// wordOffsets returns the offsets of the occurrences of word as a whole
+// word in lit, the source of a string literal: those neither preceded nor
+// followed by an identifier character, other than the letter of an escape
+// sequence such as \n.
+func wordOffsets(lit, word string) []int {
+	var offsets []int
+	for start := 0; ; {
+		i := strings.Index(lit[start:], word)
+		if i < 0 {
+			return offsets
+		}
+		i += start
+		start = i + len(word)
+		if prev, size := utf8.DecodeLastRuneInString(lit[:i]); i > 0 && isIdentRune(prev) && !(lit[0] == '"' && i-size > 0 && lit[i-size-1] == '\\') {
+			continue
+		}
+		if next, _ := utf8.DecodeRuneInString(lit[start:]); start < len(lit) && isIdentRune(next) {
+			continue
+		}
+		offsets = append(offsets, i)
+	}
+}
+
+// addLiteralMatches adds to edits those replacing the matches of m in lit,
+// a string literal of pgf.
+func addLiteralMatches(edits map[span.URI][]protocol.TextEdit, pgf *ParsedGoFile, lit *ast.BasicLit, m *nameMatcher) error {
+	for _, match := range m.matches(lit.Value) {
+		rng, err := pgf.Mapper.PosRange(lit.Pos()+token.Pos(match.start), lit.Pos()+token.Pos(match.end))
+		if err != nil {
+			return err
+		}
+		edits[pgf.URI] = append(edits[pgf.URI], protocol.TextEdit{Range: rng, NewText: match.newText})
+	}
+	return nil
+}
+
+// addErrorMessageEdits records the edits renaming the old name of m in the
+// error messages of pkgs, the variants of the declaring package, under an
+// annotation of the strings group.
+func (o *OptionalEdits) addErrorMessageEdits(pkgs []Package, m *nameMatcher) error {
+	edits, err := errorMessageEdits(pkgs, m)
+	if err != nil {
+		return err
+	}
+	o.addAnnotatedEdits(StringsGroup, "errors", protocol.ChangeAnnotation{
+		Label:       "Rename in error messages",
+		Description: fmt.Sprintf("%d occurrences of %q in the error messages of package %s", countEdits(edits), m.oldName, pkgs[0].GetTypes().Name()),
+	}, edits)
+	return nil
+}
@@ -0,0 +1,32 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWordOffsets(t *testing.T) {
+	for _, tt := range []struct {
+		lit, word string
+		want      []int
+	}{
+		{`"foo: %v"`, "foo", []int{1}},
+		{`"Foo failed"`, "Foo", []int{1}},
+		{`"FooBar failed"`, "Foo", nil},
+		{`"NewFoo failed"`, "Foo", nil},
+		{`"reading foo_bar"`, "foo", nil},
+		{`"foo: foo failed"`, "foo", []int{1, 6}},
+		{`"error\nfoo: failed"`, "foo", []int{8}},
+		{"`raw\\nfoo`", "foo", nil},
+		{"`foo`", "foo", []int{1}},
+		{`"éfoo"`, "foo", nil},
+	} {
+		if got := wordOffsets(tt.lit, tt.word); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("wordOffsets(%s, %q) = %v, want %v", tt.lit, tt.word, got, tt.want)
+		}
+	}
+}
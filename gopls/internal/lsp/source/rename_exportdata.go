@@ -0,0 +1,130 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+// An ExportDataPackage is a package referring to a renamed object or
+// package that the rename cannot edit, as it is loaded only from export
+// data: it lies outside the workspace, so gopls checks only its exported
+// declarations, without its function bodies.
+type ExportDataPackage struct {
+	Path       string              // the package path
+	References []protocol.Location // the known references, including the imports of the renamed package
+}
+
+// exportDataPackages returns the reverse dependencies of the packages ids,
+// those declaring a renamed object or the variants of a renamed package of
+// path path, that are loaded only from export data and that import path or
+// refer to obj, unless it is nil, in their exported declarations. They are
+// ordered by path, and their references by position. The URIs of their
+// files are returned too. The reverse dependencies are loaded within the
+// renameMaxPackages setting, for the renaming of what.
+//
+// Intermediate test variants, which are also checked from their exported
+// declarations, are left out: their files are those of full packages.
+func exportDataPackages(ctx context.Context, s Snapshot, what string, ids []string, path string, obj types.Object) ([]ExportDataPackage, map[span.URI]bool, error) {
+	var pkgs []ExportDataPackage
+	uris := make(map[span.URI]bool)
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		rdeps, err := loadReverseDependencies(ctx, s, what, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if rdeps, err = checkLocalReplacements(ctx, s, rdeps); err != nil {
+			return nil, nil, err
+		}
+		for _, dep := range rdeps {
+			if dep.ParseMode() == ParseFull || dep.ForTest() != "" || seen[dep.PkgPath()] {
+				continue
+			}
+			seen[dep.PkgPath()] = true
+			refs, err := exportDataReferences(dep, path, obj)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(refs) == 0 {
+				continue
+			}
+			pkgs = append(pkgs, ExportDataPackage{Path: dep.PkgPath(), References: refs})
+			for _, pgf := range dep.CompiledGoFiles() {
+				uris[pgf.URI] = true
+			}
+		}
+	}
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].Path < pkgs[j].Path })
+	return pkgs, uris, nil
+}
+
+// exportDataReferences returns the locations of the imports of path in the
+// files of pkg, and of the references to obj, unless it is nil, that its
+// exported declarations contain.
+func exportDataReferences(pkg Package, path string, obj types.Object) ([]protocol.Location, error) {
+	var locs []protocol.Location
+	for _, pgf := range pkg.CompiledGoFiles() {
+		var nodes []ast.Node
+		for _, imp := range pgf.File.Imports {
+			if impPath, _ := strconv.Unquote(imp.Path.Value); impPath == path {
+				nodes = append(nodes, imp.Path)
+			}
+		}
+		if obj != nil {
+			ast.Inspect(pgf.File, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok {
+					if use := pkg.GetTypesInfo().Uses[id]; use != nil && equalOrigin(use, obj) {
+						nodes = append(nodes, id)
+					}
+				}
+				return true
+			})
+		}
+		for _, n := range nodes {
+			rng, err := NewMappedRange(pgf.Tok, pgf.Mapper, n.Pos(), n.End()).Range()
+			if err != nil {
+				return nil, err
+			}
+			locs = append(locs, protocol.Location{URI: protocol.URIFromSpanURI(pgf.URI), Range: rng})
+		}
+	}
+	sort.Slice(locs, func(i, j int) bool {
+		if locs[i].URI != locs[j].URI {
+			return locs[i].URI < locs[j].URI
+		}
+		return protocol.ComparePosition(locs[i].Range.Start, locs[j].Range.Start) < 0
+	})
+	return locs, nil
+}
+
+// addExportDataPackages records pkgs, the packages referring to the
+// renamed object or package name that the rename cannot edit, along with
+// a warning listing them and their known references.
+func (o *OptionalEdits) addExportDataPackages(name string, pkgs []ExportDataPackage) {
+	if len(pkgs) == 0 {
+		return
+	}
+	o.ExportData = append(o.ExportData, pkgs...)
+	var descs []string
+	for _, pkg := range pkgs {
+		var positions []string
+		for _, loc := range pkg.References {
+			positions = append(positions, fmt.Sprintf("%s:%d:%d", filepath.Base(loc.URI.SpanURI().Filename()), loc.Range.Start.Line+1, loc.Range.Start.Character+1))
+		}
+		descs = append(descs, fmt.Sprintf("%s (%s)", pkg.Path, strings.Join(positions, ", ")))
+	}
+	o.Warnings = append(o.Warnings, fmt.Sprintf("packages loaded only from export data refer to %s and are not renamed: %s", name, strings.Join(descs, ", ")))
+}
@@ -0,0 +1,193 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/types/objectpath"
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/event"
+)
+
+// OutsideWorkspace is the scope of the external references of the packages
+// that lie outside the workspace, such as those of the module cache.
+const OutsideWorkspace = "outside the workspace"
+
+// An ExternalReferenceCount is the number of references to a renamed
+// object of a package outside the edit scope of the rename, which the
+// rename does not update.
+type ExternalReferenceCount struct {
+	Package    string // the package path
+	Scope      string // OutsideWorkspace, or the name of the view of another workspace folder
+	References int
+}
+
+// ExternalReferences returns the numbers of references to the exported
+// object at position pp of f of the known packages outside the edit scope
+// of its rename: the importers outside the workspace, loaded only from
+// export data, which are checked in full to count them, and the packages
+// of others, the snapshots of the other views, except those of the
+// references to vendored copies of the object, which RenameVendoredCopies
+// renames. The counts of the packages outside the workspace come first,
+// then those of each of others, each ordered by package path; packages
+// without references are left out.
+func ExternalReferences(ctx context.Context, s Snapshot, f FileHandle, pp protocol.Position, others []Snapshot) ([]ExternalReferenceCount, error) {
+	ctx, done := event.Start(ctx, "source.ExternalReferences")
+	defer done()
+
+	pgf, err := s.ParseGo(ctx, f, ParseFull)
+	if err != nil {
+		return nil, err
+	}
+	uri := f.URI()
+	mention, err := findCommentMention(ctx, s, pgf, pp)
+	if err != nil {
+		return nil, err
+	}
+	if mention != nil {
+		uri, pp = mention.uri, mention.pos
+	}
+	qos, err := qualifiedObjsAtProtocolPos(ctx, s, uri, pp)
+	if err != nil {
+		return nil, err
+	}
+	obj := qos[0].obj
+	if obj.Pkg() == nil || !exportedMember(obj) {
+		return nil, nil
+	}
+	path, err := objectpath.For(obj)
+	if err != nil {
+		return nil, nil // not accessible from other packages
+	}
+	target := externalTarget{pkgPath: obj.Pkg().Path(), name: obj.Name(), path: path}
+
+	var counts []ExternalReferenceCount
+	var ids []string
+	for _, qo := range qos {
+		ids = append(ids, qo.pkg.ID())
+	}
+	exportData, _, err := exportDataPackages(ctx, s, obj.Name(), ids, target.pkgPath, obj)
+	if err != nil {
+		return nil, err
+	}
+	for _, ed := range exportData {
+		pkgs, err := s.PackagesForFile(ctx, ed.References[0].URI.SpanURI(), TypecheckFull, false)
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[externalRef]bool)
+		for _, pkg := range pkgs {
+			if pkg.PkgPath() == ed.Path {
+				if n := target.count(s, pkg, seen); n > 0 {
+					counts = append(counts, ExternalReferenceCount{Package: ed.Path, Scope: OutsideWorkspace, References: n})
+				}
+				break
+			}
+		}
+	}
+
+	for _, other := range others {
+		pkgs, err := loadKnownPackages(ctx, other, obj.Name())
+		if err != nil {
+			return nil, err
+		}
+		byPath := make(map[string]int)
+		seen := make(map[externalRef]bool)
+		for _, pkg := range pkgs {
+			if pkg.ParseMode() != ParseFull {
+				continue
+			}
+			if n := target.count(other, pkg, seen); n > 0 {
+				byPath[pkg.PkgPath()] += n
+			}
+		}
+		var paths []string
+		for p := range byPath {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		for _, p := range paths {
+			counts = append(counts, ExternalReferenceCount{Package: p, Scope: other.View().Name(), References: byPath[p]})
+		}
+	}
+	return counts, nil
+}
+
+// CheckExternalReferences returns an error listing counts, the references
+// to an object outside the edit scope of its rename, which the rename
+// would break, unless there are none or the renameAllowBreakingExternal
+// setting of s lets it proceed.
+func CheckExternalReferences(s Snapshot, counts []ExternalReferenceCount) error {
+	if len(counts) == 0 || s.View().Options().RenameAllowBreakingExternal {
+		return nil
+	}
+	total := 0
+	var descs []string
+	for _, c := range counts {
+		total += c.References
+		descs = append(descs, fmt.Sprintf("%s (%s): %d", c.Package, c.Scope, c.References))
+	}
+	return fmt.Errorf("renaming would break %d references outside its edit scope, in %s; set rename.allowBreakingExternal to rename anyway", total, strings.Join(descs, ", "))
+}
+
+// An externalTarget identifies a renamed object across views, by the path
+// and name of its package and its object path.
+type externalTarget struct {
+	pkgPath, name string
+	path          objectpath.Path
+}
+
+// An externalRef is the position of a reference, by file and offset, so
+// that the files of several variants of a package count once.
+type externalRef struct {
+	uri    span.URI
+	offset int
+}
+
+// count returns the number of references to t of the files of pkg, a
+// package of snapshot s, that are not in seen, which it adds them to. The
+// references to vendored copies of t are not counted.
+func (t externalTarget) count(s Snapshot, pkg Package, seen map[externalRef]bool) int {
+	n := 0
+	info := pkg.GetTypesInfo()
+	for _, pgf := range pkg.CompiledGoFiles() {
+		ast.Inspect(pgf.File, func(node ast.Node) bool {
+			id, ok := node.(*ast.Ident)
+			if !ok || id.Name != t.name {
+				return true
+			}
+			if use := info.Uses[id]; use == nil || !t.matches(s, use) {
+				return true
+			}
+			ref := externalRef{pgf.URI, pgf.Tok.Offset(id.Pos())}
+			if !seen[ref] {
+				seen[ref] = true
+				n++
+			}
+			return true
+		})
+	}
+	return n
+}
+
+// matches reports whether obj, an object of snapshot s, is t, rather than
+// a vendored copy of it.
+func (t externalTarget) matches(s Snapshot, obj types.Object) bool {
+	if obj.Pkg() == nil || obj.Pkg().Path() != t.pkgPath {
+		return false
+	}
+	if path, err := objectpath.For(obj); err != nil || path != t.path {
+		return false
+	}
+	rel, ok := workspaceRelPath(s, s.FileSet().Position(obj.Pos()).Filename)
+	return !ok || !isVendorPath(rel)
+}
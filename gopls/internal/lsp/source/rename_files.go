@@ -0,0 +1,677 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/typeparams"
+)
+
+// RenameFiles returns the edits that must accompany the renaming of the file
+// or directory oldURI to newURI, so that the affected packages remain
+// consistent with their new location. It must be called before the renaming
+// takes place, and the edits apply to the files at their old locations.
+//
+// Renaming a directory updates the import paths of the packages within it,
+// as renaming a package does, and also renames the package in the directory
+// if its name matched the directory name. Moving a Go file into another
+// package's directory updates its package clause.
+func RenameFiles(ctx context.Context, s Snapshot, oldURI, newURI span.URI) (map[span.URI][]protocol.TextEdit, error) {
+	ctx, done := event.Start(ctx, "source.RenameFiles")
+	defer done()
+
+	info, err := os.Stat(oldURI.Filename())
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return renameDirectory(ctx, s, oldURI.Filename(), newURI.Filename())
+	}
+	if !strings.HasSuffix(oldURI.Filename(), ".go") {
+		return nil, nil
+	}
+	return moveGoFile(ctx, s, oldURI, newURI)
+}
+
+// renameDirectory computes the edits for renaming the directory oldDir,
+// which may contain packages, to newDir.
+func renameDirectory(ctx context.Context, s Snapshot, oldDir, newDir string) (map[span.URI][]protocol.TextEdit, error) {
+	mod, err := moduleOfDir(ctx, s, oldDir)
+	if err != nil {
+		return nil, err
+	}
+	if mod == nil {
+		return nil, nil // no packages are affected
+	}
+	newRel, ok := relPath(mod.Dir, newDir)
+	if !ok {
+		return nil, fmt.Errorf("cannot move packages out of module %s", mod.Path)
+	}
+	oldRel, _ := relPath(mod.Dir, oldDir)
+	oldPath := path.Join(mod.Path, filepath.ToSlash(oldRel))
+	newPath := path.Join(mod.Path, filepath.ToSlash(newRel))
+	if oldPath == mod.Path {
+		return nil, nil // import paths are relative to the module root
+	}
+
+	metadata, err := s.AllValidMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The package in the directory, if any, keeps its name unless it was
+	// named after the directory.
+	var newName string
+	for _, m := range metadata {
+		if m.PackagePath() == oldPath {
+			newName = m.PackageName()
+			if base := path.Base(newPath); newName == path.Base(oldPath) && newName != "main" && isValidIdentifier(base) {
+				newName = base
+			}
+			break
+		}
+	}
+	edits, aliases, err := updatePackagePaths(ctx, s, mod.Path, oldPath, newPath, newName, metadata)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range aliases {
+		for uri, e := range a.edits {
+			edits[uri] = append(edits[uri], e...)
+		}
+	}
+	return edits, nil
+}
+
+// moduleOfDir returns the module information for the packages in the tree
+// rooted at dir, or nil if the tree contains no packages.
+func moduleOfDir(ctx context.Context, s Snapshot, dir string) (*packages.Module, error) {
+	var mod *packages.Module
+	errFound := errors.New("found")
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		metadata, err := s.MetadataForFile(ctx, span.URIFromPath(path))
+		if err != nil {
+			return err
+		}
+		for _, m := range metadata {
+			if mi := m.ModuleInfo(); mi != nil {
+				if _, ok := relPath(mi.Dir, dir); ok {
+					mod = mi
+					return errFound
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil && err != errFound {
+		return nil, err
+	}
+	return mod, nil
+}
+
+// ReplaceDirectiveEdits returns the edits updating the replace directives of
+// the go.mod and go.work files of s whose directory paths are invalidated by
+// the move of the file or directory oldDir to newDir, either because their
+// target moves or because the file holding them does. Relative paths remain
+// relative.
+func ReplaceDirectiveEdits(ctx context.Context, s Snapshot, oldDir, newDir string) (map[span.URI][]protocol.TextEdit, error) {
+	edits := make(map[span.URI][]protocol.TextEdit)
+	for _, uri := range s.ModFiles() {
+		fh, err := s.GetFile(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		pm, err := s.ParseMod(ctx, fh)
+		if err != nil || pm.File == nil {
+			continue // a broken go.mod file has no directives to update
+		}
+		e, err := replaceEdits(pm.Mapper, uri, pm.File.Replace, oldDir, newDir)
+		if err != nil {
+			return nil, err
+		}
+		if len(e) > 0 {
+			edits[uri] = e
+		}
+	}
+	if uri := s.WorkFile(); uri != "" {
+		fh, err := s.GetFile(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		if pw, err := s.ParseWork(ctx, fh); err == nil && pw.File != nil {
+			e, err := replaceEdits(pw.Mapper, uri, pw.File.Replace, oldDir, newDir)
+			if err != nil {
+				return nil, err
+			}
+			if len(e) > 0 {
+				edits[uri] = e
+			}
+		}
+	}
+	return edits, nil
+}
+
+// replaceEdits returns the edits updating the directory paths of replaces,
+// the replace directives of the file uri mapped by m, after the move of
+// oldDir to newDir.
+func replaceEdits(m *protocol.ColumnMapper, uri span.URI, replaces []*modfile.Replace, oldDir, newDir string) ([]protocol.TextEdit, error) {
+	moved := func(p string) string {
+		if rel, ok := relPath(oldDir, p); ok {
+			return filepath.Join(newDir, rel)
+		}
+		return p
+	}
+	dir := filepath.Dir(uri.Filename())
+	var edits []protocol.TextEdit
+	for _, r := range replaces {
+		if r.New.Version != "" || r.Syntax == nil || len(r.Syntax.Token) == 0 {
+			continue // not a directory replacement
+		}
+		target := filepath.FromSlash(r.New.Path)
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, target)
+		}
+		newTarget, newFileDir := moved(target), moved(dir)
+		if newTarget == target && newFileDir == dir {
+			continue
+		}
+		newPath := newTarget
+		if !filepath.IsAbs(filepath.FromSlash(r.New.Path)) {
+			rel, err := filepath.Rel(newFileDir, newTarget)
+			if err != nil {
+				continue
+			}
+			newPath = filepath.ToSlash(rel)
+			if newPath != ".." && !strings.HasPrefix(newPath, "../") {
+				newPath = "./" + newPath
+			}
+		}
+		if newPath == r.New.Path {
+			continue
+		}
+
+		// The path is the last token of the directive.
+		tok := r.Syntax.Token[len(r.Syntax.Token)-1]
+		line := m.Content[r.Syntax.Start.Byte:r.Syntax.End.Byte]
+		i := strings.LastIndex(string(line), tok)
+		if i < 0 {
+			continue
+		}
+		start := r.Syntax.Start.Byte + i
+		rng, err := m.OffsetRange(start, start+len(tok))
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, protocol.TextEdit{Range: rng, NewText: modfile.AutoQuote(newPath)})
+	}
+	return edits, nil
+}
+
+// PackageDirRenames returns the operations moving dir, the directory of a
+// renamed package, to newDir along with all it contains, its non-Go files
+// such as testdata, READMEs, and SQL and template files included: the
+// renaming of dir, or that of each file within it if the
+// renameMoveEachFile setting of s is set. A directory whose name only
+// changes case is renamed whole regardless, as the renaming of its files
+// cannot change it.
+func PackageDirRenames(s Snapshot, dir, newDir string) ([]protocol.RenameFile, error) {
+	rename := func(from, to string) protocol.RenameFile {
+		return protocol.RenameFile{
+			Kind:   "rename",
+			OldURI: protocol.URIFromPath(from),
+			NewURI: protocol.URIFromPath(to),
+		}
+	}
+	if !s.View().Options().RenameMoveEachFile || strings.EqualFold(dir, newDir) {
+		return RenameSteps(rename(dir, newDir)), nil
+	}
+	var renames []protocol.RenameFile
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		renames = append(renames, rename(path, filepath.Join(newDir, rel)))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return renames, nil
+}
+
+// moveGoFile computes the edits for moving the Go file oldURI to newURI. If
+// the file moves to a directory holding a different package, its package
+// clause is updated to match.
+func moveGoFile(ctx context.Context, s Snapshot, oldURI, newURI span.URI) (map[span.URI][]protocol.TextEdit, error) {
+	newDir := filepath.Dir(newURI.Filename())
+	if SamePath(filepath.Dir(oldURI.Filename()), newDir) {
+		return nil, nil
+	}
+	fh, err := s.GetFile(ctx, oldURI)
+	if err != nil {
+		return nil, err
+	}
+	pgf, err := s.ParseGo(ctx, fh, ParseHeader)
+	if err != nil {
+		return nil, err
+	}
+	if pgf.File.Name == nil {
+		return nil, nil
+	}
+	newName, err := packageNameInDir(ctx, s, newDir)
+	if err != nil || newName == "" {
+		return nil, err
+	}
+	if strings.HasSuffix(pgf.File.Name.Name, "_test") && strings.HasSuffix(newURI.Filename(), "_test.go") {
+		newName += "_test" // preserve external tests
+	}
+	if newName == pgf.File.Name.Name {
+		return nil, nil
+	}
+	rng, err := NewMappedRange(pgf.Tok, pgf.Mapper, pgf.File.Name.Pos(), pgf.File.Name.End()).Range()
+	if err != nil {
+		return nil, err
+	}
+	return map[span.URI][]protocol.TextEdit{
+		oldURI: {{Range: rng, NewText: newName}},
+	}, nil
+}
+
+// packageNameInDir returns the name declared by the non-test Go files in
+// dir, or "" if there are none.
+func packageNameInDir(ctx context.Context, s Snapshot, dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		fh, err := s.GetFile(ctx, span.URIFromPath(filepath.Join(dir, name)))
+		if err != nil {
+			return "", err
+		}
+		pgf, err := s.ParseGo(ctx, fh, ParseHeader)
+		if err != nil || pgf.File.Name == nil {
+			continue
+		}
+		return pgf.File.Name.Name, nil
+	}
+	return "", nil
+}
+
+// A MovedPackage describes an import of a package that no longer exists
+// but appears to have moved to another directory of its module, for example
+// because its directory was renamed outside the editor.
+type MovedPackage struct {
+	ImportPath string         // the broken import path
+	Range      protocol.Range // the import path in the importing file
+
+	// OldPath and NewPath are the import paths of the moved directory,
+	// before and after the move. They are prefixes of ImportPath and of the
+	// path of the package it moved to.
+	OldPath, NewPath string
+}
+
+// MovedPackages returns the moved packages imported by the file fh.
+//
+// An import is considered moved if it denotes no package of the module of
+// fh, and exactly one other package of that module has the same name and
+// declares all the names that fh uses from it.
+func MovedPackages(ctx context.Context, s Snapshot, fh FileHandle) ([]MovedPackage, error) {
+	fileMeta, err := s.MetadataForFile(ctx, fh.URI())
+	if err != nil || len(fileMeta) == 0 || fileMeta[0].ModuleInfo() == nil {
+		return nil, err
+	}
+	modulePath := fileMeta[0].ModuleInfo().Path
+	metadata, err := s.AllValidMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool)
+	for _, m := range metadata {
+		known[m.PackagePath()] = true
+	}
+	pgf, err := s.ParseGo(ctx, fh, ParseFull)
+	if err != nil {
+		return nil, err
+	}
+
+	var moved []MovedPackage
+	for _, imp := range pgf.File.Imports {
+		importPath := ImportPath(imp)
+		if known[importPath] || !strings.HasPrefix(importPath, modulePath+"/") {
+			continue
+		}
+		// Without type information for the missing package, the file refers
+		// to it by the last element of its path, unless the import is named.
+		localName := path.Base(importPath)
+		if imp.Name != nil {
+			localName = imp.Name.Name
+		}
+		if localName == "_" || localName == "." {
+			continue
+		}
+		used := make(map[string]bool)
+		ast.Inspect(pgf.File, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				if id, ok := sel.X.(*ast.Ident); ok && id.Name == localName {
+					used[sel.Sel.Name] = true
+				}
+			}
+			return true
+		})
+
+		var newPath string
+	candidates:
+		for _, m := range metadata {
+			if m.ModuleInfo() == nil || m.ModuleInfo().Path != modulePath || strings.HasSuffix(m.PackagePath(), "_test") {
+				continue
+			}
+			if imp.Name == nil && m.PackageName() != localName {
+				continue
+			}
+			pkg, err := s.WorkspacePackageByID(ctx, m.PackageID())
+			if err != nil {
+				continue
+			}
+			for name := range used {
+				if obj := pkg.GetTypes().Scope().Lookup(name); obj == nil || !obj.Exported() {
+					continue candidates
+				}
+			}
+			if newPath != "" && newPath != m.PackagePath() {
+				newPath = "" // ambiguous
+				break
+			}
+			newPath = m.PackagePath()
+		}
+		if newPath == "" {
+			continue
+		}
+
+		// Attribute the move to the outermost directory whose path changed,
+		// as nested packages are likely to have moved along with it.
+		oldPath := importPath
+		for path.Base(oldPath) == path.Base(newPath) && path.Dir(oldPath) != modulePath && path.Dir(newPath) != modulePath {
+			oldPath, newPath = path.Dir(oldPath), path.Dir(newPath)
+		}
+		rng, err := NewMappedRange(pgf.Tok, pgf.Mapper, imp.Path.Pos(), imp.Path.End()).Range()
+		if err != nil {
+			return nil, err
+		}
+		moved = append(moved, MovedPackage{
+			ImportPath: importPath,
+			Range:      rng,
+			OldPath:    oldPath,
+			NewPath:    newPath,
+		})
+	}
+	return moved, nil
+}
+
+// MovedPackageEdits returns the edits updating the imports of the workspace
+// after the directory of import path oldPath moved to newPath, as renaming
+// the directory with gopls would have.
+//
+// Only imports that denote no package, and would denote one after the
+// update, are changed. Imports whose package name differs from the last
+// element of their new path are given an explicit name, so that references
+// to them remain valid.
+func MovedPackageEdits(ctx context.Context, s Snapshot, oldPath, newPath string) (map[span.URI][]protocol.TextEdit, error) {
+	metadata, err := s.AllValidMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string) // package path -> name
+	for _, m := range metadata {
+		names[m.PackagePath()] = m.PackageName()
+	}
+	pkgs, err := s.ActivePackages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	edits := make(map[span.URI][]protocol.TextEdit)
+	seen := make(map[span.URI]bool)
+	for _, pkg := range pkgs {
+		for _, pgf := range pkg.CompiledGoFiles() {
+			if seen[pgf.URI] {
+				continue
+			}
+			seen[pgf.URI] = true
+			for _, imp := range pgf.File.Imports {
+				importPath := ImportPath(imp)
+				if importPath != oldPath && !strings.HasPrefix(importPath, oldPath+"/") {
+					continue
+				}
+				if _, ok := names[importPath]; ok {
+					continue // not broken
+				}
+				// A move keeps the name of the package, which is the local
+				// name of the unaliased imports: only the path changes.
+				updated := newPath + strings.TrimPrefix(importPath, oldPath)
+				if _, ok := names[updated]; !ok {
+					continue
+				}
+				rng, err := NewMappedRange(pgf.Tok, pgf.Mapper, imp.Path.Pos(), imp.Path.End()).Range()
+				if err != nil {
+					return nil, err
+				}
+				edits[pgf.URI] = append(edits[pgf.URI], protocol.TextEdit{Range: rng, NewText: strconv.Quote(updated)})
+			}
+		}
+	}
+	return edits, nil
+}
+
+// A fileRename is the renaming of a file within its directory.
+type fileRename struct {
+	uri     span.URI
+	newName string // new base name
+}
+
+// namesakeFileRenames returns the renamings of the files named after the
+// object of qo that may accompany its renaming to newName.
+//
+// The file declaring the object is named after it if, once stripped of any
+// _test and build constraint suffixes such as _linux or _windows_amd64, its
+// name is foobar or foo_bar for an object FooBar. If that file declares
+// little else, that is, its only types and functions are the object itself,
+// the object's methods, and constructors such as NewFooBar, it is renamed
+// along with every other file of its directory named after the object, and
+// their suffixes are preserved. Files whose new name already exists are not
+// renamed.
+func namesakeFileRenames(s Snapshot, qo qualifiedObject, newName string) ([]fileRename, error) {
+	obj := qo.obj
+	if obj.Pkg() == nil || obj.Parent() != obj.Pkg().Scope() {
+		return nil, nil // not package-level
+	}
+	switch obj.(type) {
+	case *types.TypeName, *types.Func:
+	default:
+		return nil, nil
+	}
+	uri, _, _, err := editRange(s, s.FileSet().File(obj.Pos()), obj.Pos(), obj.Pos())
+	if err != nil {
+		return nil, err
+	}
+	pgf, err := qo.pkg.File(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	// newStem returns the stem replacing stem, or "" if stem is not
+	// named after the object.
+	newStem := func(stem string) string {
+		base, suffix := splitBuildSuffix(stem)
+		switch base {
+		case strings.ToLower(obj.Name()):
+			return strings.ToLower(newName) + suffix
+		case snakeCase(obj.Name()):
+			return snakeCase(newName) + suffix
+		}
+		return ""
+	}
+	if newStem(strings.TrimSuffix(filepath.Base(uri.Filename()), ".go")) == "" {
+		return nil, nil
+	}
+
+	for _, decl := range pgf.File.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv != nil {
+				if _, isType := obj.(*types.TypeName); !isType || receiverName(decl) != obj.Name() {
+					return nil, nil
+				}
+				continue
+			}
+			if name := decl.Name.Name; name != obj.Name() && !strings.EqualFold(name, "new"+obj.Name()) {
+				return nil, nil
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				if spec, ok := spec.(*ast.TypeSpec); ok && spec.Name.Name != obj.Name() {
+					return nil, nil
+				}
+			}
+		}
+	}
+
+	// Files excluded by build constraints are not part of the package, so
+	// scan the directory itself.
+	dir := filepath.Dir(uri.Filename())
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var renames []fileRename
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		stem := newStem(strings.TrimSuffix(name, ".go"))
+		if stem == "" || stem+".go" == name {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, stem+".go")); err == nil {
+			continue // don't overwrite
+		}
+		renames = append(renames, fileRename{
+			uri:     span.URIFromPath(filepath.Join(dir, name)),
+			newName: stem + ".go",
+		})
+	}
+	return renames, nil
+}
+
+// splitBuildSuffix splits a file name stem into its base and the suffix
+// that constrains the build, such as _test, _linux or _windows_amd64_test.
+func splitBuildSuffix(stem string) (base, suffix string) {
+	base = strings.TrimSuffix(stem, "_test")
+	elems := strings.Split(base, "_")
+	n := len(elems)
+	if n > 1 && knownArch[elems[n-1]] {
+		n--
+	}
+	if n > 1 && knownOS[elems[n-1]] {
+		n--
+	}
+	base = strings.Join(elems[:n], "_")
+	return base, stem[len(base):]
+}
+
+// knownOS and knownArch are the values of GOOS and GOARCH recognized in file
+// names by the go command.
+var (
+	knownOS = map[string]bool{
+		"aix": true, "android": true, "darwin": true, "dragonfly": true,
+		"freebsd": true, "hurd": true, "illumos": true, "ios": true,
+		"js": true, "linux": true, "nacl": true, "netbsd": true,
+		"openbsd": true, "plan9": true, "solaris": true, "windows": true,
+		"zos": true,
+	}
+	knownArch = map[string]bool{
+		"386": true, "amd64": true, "amd64p32": true, "arm": true,
+		"armbe": true, "arm64": true, "arm64be": true, "loong64": true,
+		"mips": true, "mipsle": true, "mips64": true, "mips64le": true,
+		"mips64p32": true, "mips64p32le": true, "ppc": true, "ppc64": true,
+		"ppc64le": true, "riscv": true, "riscv64": true, "s390": true,
+		"s390x": true, "sparc": true, "sparc64": true, "wasm": true,
+	}
+)
+
+// receiverName returns the name of the receiver base type of the method
+// decl, or "" if it can't be determined.
+func receiverName(decl *ast.FuncDecl) string {
+	if len(decl.Recv.List) == 0 {
+		return ""
+	}
+	typ := decl.Recv.List[0].Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	if x, _, _, _ := typeparams.UnpackIndexExpr(typ); x != nil {
+		typ = x
+	}
+	if id, ok := typ.(*ast.Ident); ok {
+		return id.Name
+	}
+	return ""
+}
+
+// snakeCase returns the lower-case form of name, with words separated by
+// underscores; for example, "HTTPServer" becomes "http_server".
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,158 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/types/typeutil"
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+// externalNameForms are the functions deriving, from the name of a
+// variable, the names of the flags and environment variables conventionally
+// bound to it: those of logging keys, such as max-conns for maxConns, and
+// MAX_CONNS.
+var externalNameForms = append([]func(string) string{
+	func(name string) string { return strings.ToUpper(snakeCase(name)) },
+}, keyForms...)
+
+// externalNameEdits returns the edits renaming, in the files of pkgs, the
+// names of the command-line flags and environment variables bound to the
+// variable v that are derived from its name, such as "max-conns" in
+//
+//	maxConns := flag.Int("max-conns", 10, "")
+//	flag.IntVar(&maxConns, "max-conns", 10, "")
+//	maxConns := os.Getenv("MAX_CONNS")
+//
+// to the same form of newName, and the descriptions of the names, such as
+// flag -max-conns. Files shared by several packages are visited once.
+func externalNameEdits(pkgs []Package, v *types.Var, newName string) (map[span.URI][]protocol.TextEdit, []string, error) {
+	edits := make(map[span.URI][]protocol.TextEdit)
+	var names []string
+	seen := make(map[*ast.File]bool)
+	for _, pkg := range pkgs {
+		info := pkg.GetTypesInfo()
+		for _, pgf := range pkg.CompiledGoFiles() {
+			if seen[pgf.File] {
+				continue
+			}
+			seen[pgf.File] = true
+			// bind records the name arg of a flag or an environment
+			// variable, if derived from the name of v.
+			var bindErr error
+			bind := func(kind string, arg ast.Expr) {
+				lit, ok := astutil.Unparen(arg).(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					return
+				}
+				value, err := strconv.Unquote(lit.Value)
+				if err != nil {
+					return
+				}
+				for _, form := range externalNameForms {
+					if value != form(v.Name()) {
+						continue
+					}
+					rng, err := pgf.Mapper.PosRange(lit.Pos(), lit.End())
+					if err != nil {
+						bindErr = err
+						return
+					}
+					edits[pgf.URI] = append(edits[pgf.URI], protocol.TextEdit{Range: rng, NewText: strconv.Quote(form(newName))})
+					if kind == "flag" {
+						names = append(names, "flag -"+value)
+					} else {
+						names = append(names, "environment variable "+value)
+					}
+					return
+				}
+			}
+			// bound binds the names of the calls of rhs whose results are
+			// assigned to v among lhs.
+			bound := func(lhs []ast.Expr, rhs []ast.Expr) {
+				for i, e := range lhs {
+					id, ok := e.(*ast.Ident)
+					if !ok || !sameVariantObj(info.ObjectOf(id), v) {
+						continue
+					}
+					var init ast.Expr
+					switch {
+					case len(rhs) == len(lhs):
+						init = rhs[i]
+					case len(rhs) == 1 && i == 0:
+						init = rhs[0] // as in v, ok := os.LookupEnv("V")
+					default:
+						continue
+					}
+					if call, ok := init.(*ast.CallExpr); ok && len(call.Args) > 0 {
+						if kind := bindingKind(info, call); kind != "" {
+							bind(kind, call.Args[0])
+						}
+					}
+				}
+			}
+			ast.Inspect(pgf.File, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.ValueSpec:
+					lhs := make([]ast.Expr, len(n.Names))
+					for i, id := range n.Names {
+						lhs[i] = id
+					}
+					bound(lhs, n.Values)
+				case *ast.AssignStmt:
+					bound(n.Lhs, n.Rhs)
+				case *ast.CallExpr:
+					// flag.IntVar(&v, "name", ...) and its kin.
+					fn, ok := typeutil.Callee(info, n).(*types.Func)
+					if ok && fn.Pkg() != nil && fn.Pkg().Path() == "flag" && strings.HasSuffix(fn.Name(), "Var") && len(n.Args) >= 2 {
+						if refersToVariant(info, unparenAddr(n.Args[0]), v) {
+							bind("flag", n.Args[1])
+						}
+					}
+				}
+				return true
+			})
+			if bindErr != nil {
+				return nil, nil, bindErr
+			}
+		}
+	}
+	return edits, names, nil
+}
+
+// bindingKind returns "flag" if call defines a command-line flag whose
+// value it returns, such as flag.Int, "env" if it looks up an environment
+// variable, such as os.Getenv, and "" otherwise.
+func bindingKind(info *types.Info, call *ast.CallExpr) string {
+	fn, ok := typeutil.Callee(info, call).(*types.Func)
+	if !ok || fn.Pkg() == nil {
+		return ""
+	}
+	switch path := fn.Pkg().Path(); {
+	case path == "flag" && fn.Name() != "Lookup" && fn.Name() != "Set" && !strings.HasSuffix(fn.Name(), "Var"):
+		if sig := fn.Type().(*types.Signature); sig.Results().Len() == 1 {
+			if _, ok := sig.Results().At(0).Type().(*types.Pointer); ok {
+				return "flag"
+			}
+		}
+	case path == "os" && (fn.Name() == "Getenv" || fn.Name() == "LookupEnv"):
+		return "env"
+	}
+	return ""
+}
+
+// externalNameWarning returns the warning of the renaming of the flags and
+// environment variables of names.
+func externalNameWarning(names []string) string {
+	return fmt.Sprintf("renaming %s breaks the command lines, scripts and environments that set them", strings.Join(names, ", "))
+}
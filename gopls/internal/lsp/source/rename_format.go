@@ -0,0 +1,209 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"go/ast"
+	"go/format"
+	"strings"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/safetoken"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/diff"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/imports"
+)
+
+// formatRenamedFiles replaces the edits of each Go file in edits by edits
+// that also format the file and fix its imports once renamed, as goimports
+// does. Generated files, files that do not parse once renamed, and files
+// whose formatting would touch the optional edits of the rename are left
+// unformatted.
+func formatRenamedFiles(ctx context.Context, s Snapshot, edits map[span.URI][]protocol.TextEdit, optional *OptionalEdits) error {
+	ctx, done := event.Start(ctx, "source.formatRenamedFiles")
+	defer done()
+
+	for uri, e := range edits {
+		if !strings.HasSuffix(uri.Filename(), ".go") || IsGenerated(ctx, s, uri) {
+			continue
+		}
+		fh, err := s.GetFile(ctx, uri)
+		if err != nil {
+			return err
+		}
+		data, err := fh.Read()
+		if err != nil {
+			return err
+		}
+		m := protocol.NewColumnMapper(uri, data)
+		renamed, _, err := ApplyProtocolEdits(m, e)
+		if err != nil {
+			return err
+		}
+		var formatted []byte
+		if err := s.RunProcessEnvFunc(ctx, func(opts *imports.Options) error {
+			formatted, err = imports.Process(uri.Filename(), []byte(renamed), opts)
+			return err
+		}); err != nil {
+			event.Error(ctx, "formatting renamed file", err)
+			continue
+		}
+		formatEdits, err := ToProtocolEdits(m, s.View().Options().ComputeEdits(string(data), string(formatted)))
+		if err != nil {
+			return err
+		}
+		if optional != nil && overlapsAny(formatEdits, optional.Edits[uri]) {
+			continue
+		}
+		edits[uri] = formatEdits
+	}
+	return nil
+}
+
+// alignRenamedFiles adds to the edits of each Go file in edits the changes
+// of whitespace that restore the alignment, as gofmt does it, of the
+// multi-line struct types and composite literals that they change, which
+// renaming a field or key typically breaks. The rest of the file is left
+// as it is. Files are left unchanged if they are generated, do not parse
+// once renamed, or if the realignment would touch optional edits.
+func alignRenamedFiles(ctx context.Context, s Snapshot, edits map[span.URI][]protocol.TextEdit, optional *OptionalEdits) error {
+	ctx, done := event.Start(ctx, "source.alignRenamedFiles")
+	defer done()
+
+files:
+	for uri, e := range edits {
+		if !strings.HasSuffix(uri.Filename(), ".go") || IsGenerated(ctx, s, uri) {
+			continue
+		}
+		fh, err := s.GetFile(ctx, uri)
+		if err != nil {
+			return err
+		}
+		pgf, err := s.ParseGo(ctx, fh, ParseFull)
+		if err != nil {
+			return err
+		}
+		nodes, err := alignedNodes(pgf, e)
+		if err != nil {
+			return err
+		}
+		if len(nodes) == 0 {
+			continue
+		}
+		renamed, _, err := ApplyProtocolEdits(pgf.Mapper, e)
+		if err != nil {
+			return err
+		}
+		formatted, err := format.Source([]byte(renamed))
+		if err != nil {
+			continue
+		}
+
+		// Within the aligned nodes, the edits are those that turn the
+		// source into its formatted form; elsewhere, the renaming edits.
+		within := func(start, end int) (in, partly bool) {
+			for _, n := range nodes {
+				if n[0] <= start && end <= n[1] {
+					return true, false
+				}
+				if start < n[1] && n[0] < end {
+					partly = true
+				}
+			}
+			return false, partly
+		}
+		var aligned []diff.Edit
+		for _, d := range s.View().Options().ComputeEdits(string(pgf.Src), string(formatted)) {
+			in, partly := within(d.Start, d.End)
+			if partly {
+				continue files
+			}
+			if in {
+				aligned = append(aligned, d)
+			}
+		}
+		result, err := ToProtocolEdits(pgf.Mapper, aligned)
+		if err != nil {
+			return err
+		}
+		for _, te := range e {
+			start, err := pgf.Mapper.Offset(te.Range.Start)
+			if err != nil {
+				return err
+			}
+			end, err := pgf.Mapper.Offset(te.Range.End)
+			if err != nil {
+				return err
+			}
+			if in, partly := within(start, end); partly {
+				continue files
+			} else if !in {
+				result = append(result, te)
+			}
+		}
+		if optional != nil && overlapsAny(result, optional.Edits[uri]) {
+			continue
+		}
+		edits[uri] = result
+	}
+	return nil
+}
+
+// alignedNodes returns the byte ranges of the innermost multi-line struct
+// types and composite literals of pgf that contain the start of an edit,
+// whose fields or elements gofmt aligns in columns.
+func alignedNodes(pgf *ParsedGoFile, edits []protocol.TextEdit) ([][2]int, error) {
+	var nodes [][2]int
+	seen := make(map[ast.Node]bool)
+	for _, te := range edits {
+		pos, err := pgf.Mapper.Pos(te.Range.Start)
+		if err != nil {
+			return nil, err
+		}
+		var innermost ast.Node
+		ast.Inspect(pgf.File, func(n ast.Node) bool {
+			if n == nil || pos < n.Pos() || n.End() <= pos {
+				return false
+			}
+			switch n.(type) {
+			case *ast.StructType, *ast.CompositeLit:
+				if pgf.Tok.Line(n.Pos()) != pgf.Tok.Line(n.End()) {
+					innermost = n
+				}
+			}
+			return true
+		})
+		if innermost == nil || seen[innermost] {
+			continue
+		}
+		seen[innermost] = true
+		start, err := safetoken.Offset(pgf.Tok, innermost.Pos())
+		if err != nil {
+			return nil, err
+		}
+		end, err := safetoken.Offset(pgf.Tok, innermost.End())
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, [2]int{start, end})
+	}
+	return nodes, nil
+}
+
+// overlapsAny reports whether an edit of a overlaps or abuts an edit of b,
+// so that applying both would be ambiguous.
+func overlapsAny(a, b []protocol.TextEdit) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if protocol.ComparePosition(x.Range.End, y.Range.Start) >= 0 &&
+				protocol.ComparePosition(y.Range.End, x.Range.Start) >= 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// A callMatcher identifies a name-sensitive function or method: one whose
+// string arguments may name Go objects that it finds dynamically, so that
+// renaming those objects breaks its calls at run time.
+type callMatcher struct {
+	pkgPath string
+	recv    string // name of the receiver type of a method, or ""
+	name    string
+}
+
+// reflectMatchers identifies the lookups by name of package reflect.
+var reflectMatchers = []callMatcher{
+	{"reflect", "Value", "FieldByName"},
+	{"reflect", "Value", "MethodByName"},
+	{"reflect", "Type", "FieldByName"},
+	{"reflect", "Type", "MethodByName"},
+}
+
+// parseCallMatcher parses a matcher of the form "path.Func" or
+// "path.Type.Method", as in "github.com/example/di.Container.Invoke".
+func parseCallMatcher(s string) (callMatcher, error) {
+	slash := strings.LastIndexByte(s, '/')
+	parts := strings.Split(s[slash+1:], ".")
+	for _, part := range parts {
+		if part == "" {
+			return callMatcher{}, fmt.Errorf("invalid function %q, want path.Func or path.Type.Method", s)
+		}
+	}
+	m := callMatcher{pkgPath: s[:slash+1] + parts[0]}
+	switch len(parts) {
+	case 2:
+		m.name = parts[1]
+	case 3:
+		m.recv, m.name = parts[1], parts[2]
+	default:
+		return callMatcher{}, fmt.Errorf("invalid function %q, want path.Func or path.Type.Method", s)
+	}
+	return m, nil
+}
+
+// matches reports whether fn is the function or method identified by m.
+func (m callMatcher) matches(fn *types.Func) bool {
+	if fn.Pkg() == nil || fn.Pkg().Path() != m.pkgPath || fn.Name() != m.name {
+		return false
+	}
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return m.recv == ""
+	}
+	named, ok := Deref(recv.Type()).(*types.Named)
+	return ok && named.Obj().Name() == m.recv
+}
+
+// A nameArgument is a string literal naming an object in a call of a
+// name-sensitive function.
+type nameArgument struct {
+	pgf    *ParsedGoFile
+	lit    *ast.BasicLit
+	callee *types.Func
+}
+
+// nameArguments returns the string literal arguments with value name of the
+// calls, in the files of pkgs, of the functions identified by matchers.
+// Files shared by several packages are visited once.
+func nameArguments(pkgs []Package, matchers []callMatcher, name string) []nameArgument {
+	if len(matchers) == 0 {
+		return nil
+	}
+	var args []nameArgument
+	seen := make(map[*ast.File]bool)
+	for _, pkg := range pkgs {
+		info := pkg.GetTypesInfo()
+		for _, pgf := range pkg.CompiledGoFiles() {
+			if seen[pgf.File] {
+				continue
+			}
+			seen[pgf.File] = true
+			ast.Inspect(pgf.File, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				fn, ok := typeutil.Callee(info, call).(*types.Func)
+				if !ok {
+					return true
+				}
+				for _, m := range matchers {
+					if !m.matches(fn) {
+						continue
+					}
+					for _, arg := range call.Args {
+						if lit := stringLitNamed(arg, name); lit != nil {
+							args = append(args, nameArgument{pgf: pgf, lit: lit, callee: fn})
+						}
+					}
+					break
+				}
+				return true
+			})
+		}
+	}
+	return args
+}
+
+// nameSensitiveWarnings returns warnings about the calls of the
+// name-sensitive functions configured by the user that are passed the old
+// name of qo, the renamed object: those in its package and, if they can
+// refer to it, in the reverse dependencies of its package, which are only
+// loaded if such functions are configured.
+func nameSensitiveWarnings(ctx context.Context, s Snapshot, qo qualifiedObject) ([]string, error) {
+	var matchers []callMatcher
+	for _, f := range s.View().Options().RenameNameSensitiveCalls {
+		if m, err := parseCallMatcher(f); err == nil { // validated with the options
+			matchers = append(matchers, m)
+		}
+	}
+	if len(matchers) == 0 {
+		return nil, nil
+	}
+	pkgs := []Package{qo.pkg}
+	if qo.obj.Exported() && !isLocal(qo.obj) {
+		rdeps, err := loadReverseDependencies(ctx, s, qo.obj.Name(), qo.pkg.ID())
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, rdeps...)
+	}
+	oldName := qo.obj.Name()
+	var warnings []string
+	for _, arg := range nameArguments(pkgs, matchers, oldName) {
+		posn := s.FileSet().Position(arg.lit.Pos())
+		warnings = append(warnings, fmt.Sprintf("%s:%d: %s is passed the name %q",
+			filepath.Base(posn.Filename), posn.Line, arg.callee.FullName(), oldName))
+	}
+	return warnings, nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// A loadTolerantSnapshot is a Snapshot for the renaming of an object, whose
+// methods returning several packages leave out, rather than fail on, the
+// packages that cannot be type checked if they cannot refer to the object:
+// those whose files do not mention its name. The packages left out are
+// recorded, to warn about.
+type loadTolerantSnapshot struct {
+	Snapshot
+	name   string
+	failed map[string]*PackageError // by package ID
+}
+
+func newLoadTolerantSnapshot(s Snapshot, name string) *loadTolerantSnapshot {
+	return &loadTolerantSnapshot{Snapshot: s, name: name, failed: make(map[string]*PackageError)}
+}
+
+func (s *loadTolerantSnapshot) GetReverseDependencies(ctx context.Context, id string) ([]Package, error) {
+	ids, err := s.ReverseDependencyIDs(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.checkPackages(ctx, ids)
+}
+
+func (s *loadTolerantSnapshot) KnownPackages(ctx context.Context) ([]Package, error) {
+	ids, err := s.KnownPackageIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.checkPackages(ctx, ids)
+}
+
+func (s *loadTolerantSnapshot) ActivePackages(ctx context.Context) ([]Package, error) {
+	ids, err := s.ActivePackageIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.checkPackages(ctx, ids)
+}
+
+// checkPackages returns the packages ids, type checked, leaving out those
+// that cannot be checked but cannot refer to the renamed object either.
+func (s *loadTolerantSnapshot) checkPackages(ctx context.Context, ids []string) ([]Package, error) {
+	pkgs, err := s.CheckPackages(ctx, ids)
+	if err := s.tolerate(ctx, err); err != nil {
+		return nil, err
+	}
+	return pkgs, nil
+}
+
+// tolerate returns err unless it is a PackagesError whose packages cannot
+// refer to the renamed object, which it records.
+func (s *loadTolerantSnapshot) tolerate(ctx context.Context, err error) error {
+	var errs PackagesError
+	if !errors.As(err, &errs) {
+		return err
+	}
+	for _, e := range errs {
+		for _, uri := range e.Files {
+			fh, err := s.GetFile(ctx, uri)
+			if err != nil {
+				return err
+			}
+			data, err := fh.Read()
+			if err != nil {
+				continue // the file cannot refer to it either
+			}
+			if mentionsIdent(data, s.name) {
+				return fmt.Errorf("%s, which may refer to %s, could not be loaded: %v", e.Path, s.name, e.Err)
+			}
+		}
+		s.failed[e.ID] = e
+	}
+	return nil
+}
+
+// warning returns the warning about the packages left out, if any.
+func (s *loadTolerantSnapshot) warning() string {
+	if len(s.failed) == 0 {
+		return ""
+	}
+	seen := make(map[string]bool)
+	var paths []string
+	for _, e := range s.failed {
+		if !seen[e.Path] {
+			seen[e.Path] = true
+			paths = append(paths, e.Path)
+		}
+	}
+	sort.Strings(paths)
+	return fmt.Sprintf("packages that could not be loaded, and do not mention %s, were not searched: %s", s.name, strings.Join(paths, ", "))
+}
+
+// mentionsIdent reports whether src contains name as a whole identifier.
+func mentionsIdent(src []byte, name string) bool {
+	for i := 0; ; {
+		j := bytes.Index(src[i:], []byte(name))
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(name)
+		before, _ := utf8.DecodeLastRune(src[:start])
+		after, _ := utf8.DecodeRune(src[end:])
+		if (start == 0 || !isIdentRune(before)) && (end == len(src) || !isIdentRune(after)) {
+			return true
+		}
+		i = start + 1
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import "testing"
+
+func TestMentionsIdent(t *testing.T) {
+	for _, tt := range []struct {
+		src, name string
+		want      bool
+	}{
+		{"package a\n\nvar _ = x.Foo\n", "Foo", true},
+		{"Foo", "Foo", true},
+		{"package a\n\nfunc FooBar() {}\n", "Foo", false},
+		{"package a\n\nfunc BarFoo() {}\n", "Foo", false},
+		{"package a\n\nvar _Foo, Foo_ = 1, 2\n", "Foo", false},
+		{"package a\n\nvar _ = FooBar + Foo\n", "Foo", true},
+		{"package a\n\nvar _ = éFoo + Fooé\n", "Foo", false},
+		{"package a\n\n// Foo.\n", "Foo", true},
+		{"package a\n", "Foo", false},
+	} {
+		if got := mentionsIdent([]byte(tt.src), tt.name); got != tt.want {
+			t.Errorf("mentionsIdent(%q, %q) = %t, want %t", tt.src, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/types/typeutil"
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+// loggingPackages are the paths of the structured logging packages whose
+// calls take keys naming the values they log.
+var loggingPackages = []string{
+	"log/slog",
+	"golang.org/x/exp/slog",
+	"go.uber.org/zap",
+	"github.com/sirupsen/logrus",
+}
+
+// keyForms are the functions deriving, from the name of a field, the keys
+// that commonly log it: for RetryCount, retry_count, retry-count,
+// retryCount, retrycount and RetryCount.
+var keyForms = []func(string) string{
+	snakeCase,
+	func(name string) string { return strings.ReplaceAll(snakeCase(name), "_", "-") },
+	uncapitalize,
+	strings.ToLower,
+	func(name string) string { return name },
+}
+
+// A logKey is the key of a value logged by a structured logging call,
+// such as "retry_count" in slog.Int("retry_count", x.RetryCount).
+type logKey struct {
+	pgf *ParsedGoFile
+	lit *ast.BasicLit
+}
+
+// logKeyEdits returns the edits renaming, in the files of pkgs, the keys
+// of the calls of the structured logging packages that log the field obj
+// and are derived from its name, such as "retry_count" for RetryCount, to
+// the same form of newName. A key logs the field if it is followed, as
+// an argument of the call or as the key of a logrus.Fields literal, by a
+// selection of the field. Files shared by several packages are visited
+// once.
+func logKeyEdits(pkgs []Package, obj *types.Var, newName string) (map[span.URI][]protocol.TextEdit, error) {
+	if !obj.IsField() {
+		return nil, nil
+	}
+	var keys []logKey
+	seen := make(map[*ast.File]bool)
+	for _, pkg := range pkgs {
+		info := pkg.GetTypesInfo()
+		for _, pgf := range pkg.CompiledGoFiles() {
+			if seen[pgf.File] {
+				continue
+			}
+			seen[pgf.File] = true
+			// key records the key k of the value v, if v selects obj.
+			key := func(k, v ast.Expr) {
+				lit, ok := k.(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING || !refersToVariant(info, unparenAddr(v), obj) {
+					return
+				}
+				keys = append(keys, logKey{pgf: pgf, lit: lit})
+			}
+			ast.Inspect(pgf.File, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.CallExpr:
+					fn, ok := typeutil.Callee(info, n).(*types.Func)
+					if !ok || fn.Pkg() == nil || !isLoggingPackage(fn.Pkg().Path()) {
+						return true
+					}
+					for i := 0; i+1 < len(n.Args); i++ {
+						key(n.Args[i], n.Args[i+1])
+					}
+				case *ast.CompositeLit:
+					if !isNamedType(info.TypeOf(n), "Fields", "github.com/sirupsen/logrus") {
+						return true
+					}
+					for _, elt := range n.Elts {
+						if kv, ok := elt.(*ast.KeyValueExpr); ok {
+							key(kv.Key, kv.Value)
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+
+	edits := make(map[span.URI][]protocol.TextEdit)
+	for _, k := range keys {
+		value, err := strconv.Unquote(k.lit.Value)
+		if err != nil {
+			continue
+		}
+		for _, form := range keyForms {
+			if value != form(obj.Name()) {
+				continue
+			}
+			rng, err := k.pgf.Mapper.PosRange(k.lit.Pos(), k.lit.End())
+			if err != nil {
+				return nil, err
+			}
+			edits[k.pgf.URI] = append(edits[k.pgf.URI], protocol.TextEdit{Range: rng, NewText: strconv.Quote(form(newName))})
+			break
+		}
+	}
+	return edits, nil
+}
+
+// isLoggingPackage reports whether path is that of one of the structured
+// logging packages.
+func isLoggingPackage(path string) bool {
+	for _, p := range loggingPackages {
+		if path == p {
+			return true
+		}
+	}
+	return false
+}
+
+// refersToVariant reports whether e is an identifier or selector denoting
+// obj, or the same object in another variant of its package.
+func refersToVariant(info *types.Info, e ast.Expr, obj types.Object) bool {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return sameVariantObj(info.Uses[e], obj)
+	case *ast.SelectorExpr:
+		return sameVariantObj(info.Uses[e.Sel], obj)
+	}
+	return false
+}
+
+// sameVariantObj reports whether obj1 and obj2 are the same package-level
+// object, field or method, or local variable, in possibly different
+// variants of its package, which share its syntax.
+func sameVariantObj(obj1, obj2 types.Object) bool {
+	return obj1 != nil && obj2 != nil && obj1.Pos() == obj2.Pos() && obj1.Name() == obj2.Name() &&
+		obj1.Pkg() != nil && obj2.Pkg() != nil && obj1.Pkg().Path() == obj2.Pkg().Path()
+}
+
+// unparenAddr returns e without its parentheses and address operator, as
+// in &(x.F).
+func unparenAddr(e ast.Expr) ast.Expr {
+	for {
+		switch x := e.(type) {
+		case *ast.ParenExpr:
+			e = x.X
+		case *ast.UnaryExpr:
+			if x.Op != token.AND {
+				return e
+			}
+			e = x.X
+		default:
+			return e
+		}
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"go/ast"
+	"go/types"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/event"
+)
+
+// A ManifestEntry describes a name changed by a rename, for the tools that
+// update the dependents of the renamed objects and packages elsewhere.
+type ManifestEntry struct {
+	Kind      string              // the kind of the object, such as "method", or "package"
+	Old, New  ManifestName        // the names before and after the rename
+	Positions []protocol.Location // the declarations of the old name; none for packages
+}
+
+// A ManifestName is the name of an object qualified by its package, or of
+// a package along with its import path.
+type ManifestName struct {
+	Package string // the import path of the package
+	Name    string // the name, qualified by its type for fields and methods, such as "T.M"; the package name for packages
+}
+
+// String returns the qualified name n, such as "example.com/a.T.M".
+func (n ManifestName) String() string {
+	return n.Package + "." + n.Name
+}
+
+// RenameManifest returns the names changed by edits, the edits of the
+// rename of the object or package at position pp of f to newName, which
+// report describes, ordered by old name. The objects that other packages
+// cannot refer to, such as local variables, are left out.
+func RenameManifest(ctx context.Context, s Snapshot, f FileHandle, pp protocol.Position, newName string, report *RenameReport, edits map[span.URI][]protocol.TextEdit) ([]ManifestEntry, error) {
+	ctx, done := event.Start(ctx, "source.RenameManifest")
+	defer done()
+
+	var entries []ManifestEntry
+	var err error
+	if report.Package {
+		entries, err = packageManifest(ctx, s, f, pp, newName)
+	} else {
+		entries, err = objectManifest(ctx, s, edits)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Old.String() < entries[j].Old.String()
+	})
+	return entries, nil
+}
+
+// packageManifest returns the packages whose import paths or names change
+// with the rename of the package of f to newName: the package itself and,
+// if its directory is renamed, the packages of its module nested within it.
+func packageManifest(ctx context.Context, s Snapshot, f FileHandle, pp protocol.Position, newName string) ([]ManifestEntry, error) {
+	pgf, err := s.ParseGo(ctx, f, ParseFull)
+	if err != nil {
+		return nil, err
+	}
+	mention, err := findCommentMention(ctx, s, pgf, pp)
+	if err != nil {
+		return nil, err
+	}
+	uri := f.URI()
+	if mention != nil {
+		uri = mention.uri
+	}
+	fileMeta, err := s.MetadataForFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	if len(fileMeta) == 0 {
+		return nil, nil
+	}
+	modulePath, oldPath, err := packageImportPaths(s, fileMeta[0], uri)
+	if err != nil {
+		return nil, err
+	}
+	newPathPrefix := oldPath
+	if packageRenameModes(s, modulePath, oldPath)[0] == DirectoryRename {
+		newPathPrefix = path.Join(path.Dir(oldPath), newName)
+	}
+	metadata, err := s.AllValidMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var entries []ManifestEntry
+	seen := make(map[string]bool)
+	for _, m := range metadata {
+		pkgPath := m.PackagePath()
+		if seen[pkgPath] || !strings.HasPrefix(pkgPath+"/", oldPath+"/") {
+			continue
+		}
+		if mi := m.ModuleInfo(); mi != nil && mi.Path != modulePath {
+			continue // not renamed along with the package
+		}
+		seen[pkgPath] = true
+		entry := ManifestEntry{
+			Kind: "package",
+			Old:  ManifestName{Package: pkgPath, Name: m.PackageName()},
+			New:  ManifestName{Package: newPathPrefix + strings.TrimPrefix(pkgPath, oldPath), Name: m.PackageName()},
+		}
+		if pkgPath == oldPath {
+			entry.New.Name = newName
+		}
+		if entry.New != entry.Old {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// objectManifest returns the objects declared by the identifiers that
+// edits rename, which other packages can refer to.
+func objectManifest(ctx context.Context, s Snapshot, edits map[span.URI][]protocol.TextEdit) ([]ManifestEntry, error) {
+	byName := make(map[string]*ManifestEntry)
+	seen := make(map[protocol.Location]bool)
+	for uri, e := range edits {
+		if !strings.HasSuffix(uri.Filename(), ".go") {
+			continue
+		}
+		pkgs, err := s.PackagesForFile(ctx, uri, TypecheckWorkspace, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, pkg := range pkgs {
+			pgf, err := pkg.File(uri)
+			if err != nil {
+				return nil, err
+			}
+			// The edits renaming an identifier replace it whole.
+			renamed := make(map[int]protocol.TextEdit) // by start offset
+			ends := make(map[int]int)
+			for _, te := range e {
+				start, err := pgf.Mapper.Offset(te.Range.Start)
+				if err != nil {
+					return nil, err
+				}
+				end, err := pgf.Mapper.Offset(te.Range.End)
+				if err != nil {
+					return nil, err
+				}
+				renamed[start], ends[start] = te, end
+			}
+			var declErr error
+			ast.Inspect(pgf.File, func(n ast.Node) bool {
+				id, ok := n.(*ast.Ident)
+				if !ok || declErr != nil {
+					return declErr == nil
+				}
+				start := pgf.Tok.Offset(id.Pos())
+				te, ok := renamed[start]
+				if !ok || ends[start] != start+len(id.Name) || te.NewText == id.Name {
+					return true
+				}
+				obj := pkg.GetTypesInfo().Defs[id]
+				if obj == nil {
+					return true
+				}
+				old, ok := manifestName(obj)
+				if !ok {
+					return true
+				}
+				rng, err := NewMappedRange(pgf.Tok, pgf.Mapper, id.Pos(), id.End()).Range()
+				if err != nil {
+					declErr = err
+					return false
+				}
+				loc := protocol.Location{URI: protocol.URIFromSpanURI(uri), Range: rng}
+				entry := byName[old.String()]
+				if entry == nil {
+					entry = &ManifestEntry{Kind: objectKind(obj), Old: old, New: old}
+					entry.New.Name = strings.TrimSuffix(old.Name, obj.Name()) + te.NewText
+					byName[old.String()] = entry
+				}
+				if !seen[loc] {
+					seen[loc] = true
+					entry.Positions = append(entry.Positions, loc)
+				}
+				return true
+			})
+			if declErr != nil {
+				return nil, declErr
+			}
+		}
+	}
+	var entries []ManifestEntry
+	for _, entry := range byName {
+		sort.Slice(entry.Positions, func(i, j int) bool {
+			pi, pj := entry.Positions[i], entry.Positions[j]
+			if pi.URI != pj.URI {
+				return pi.URI < pj.URI
+			}
+			return protocol.ComparePosition(pi.Range.Start, pj.Range.Start) < 0
+		})
+		entries = append(entries, *entry)
+	}
+	return entries, nil
+}
+
+// manifestName returns the name of obj qualified by its package, and by
+// its type if it is a field or method, and reports whether other packages
+// can refer to obj by that name: local objects, and the fields and methods
+// of unnamed types, have no such name.
+func manifestName(obj types.Object) (ManifestName, bool) {
+	if obj.Pkg() == nil {
+		return ManifestName{}, false
+	}
+	name := ManifestName{Package: obj.Pkg().Path(), Name: obj.Name()}
+	switch obj := obj.(type) {
+	case *types.Var:
+		if obj.IsField() {
+			owner := fieldOwner(obj)
+			if owner == nil {
+				return ManifestName{}, false
+			}
+			name.Name = owner.Name() + "." + obj.Name()
+			return name, true
+		}
+	case *types.Func:
+		if recv := obj.Type().(*types.Signature).Recv(); recv != nil {
+			t := recv.Type()
+			if ptr, ok := t.(*types.Pointer); ok {
+				t = ptr.Elem()
+			}
+			named, ok := t.(*types.Named)
+			if !ok || named.Obj().Parent() != obj.Pkg().Scope() {
+				return ManifestName{}, false
+			}
+			name.Name = named.Obj().Name() + "." + obj.Name()
+			return name, true
+		}
+	}
+	return name, obj.Parent() == obj.Pkg().Scope()
+}
+
+// fieldOwner returns the package-level type whose struct type declares the
+// field, or nil if there is none, as it is a field of an unnamed or local
+// struct type.
+func fieldOwner(field *types.Var) *types.TypeName {
+	scope := field.Pkg().Scope()
+	for _, name := range scope.Names() {
+		tname, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || tname.IsAlias() {
+			continue
+		}
+		if st, ok := tname.Type().Underlying().(*types.Struct); ok {
+			for i := 0; i < st.NumFields(); i++ {
+				if st.Field(i) == field {
+					return tname
+				}
+			}
+		}
+	}
+	return nil
+}
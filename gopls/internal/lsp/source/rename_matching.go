@@ -0,0 +1,112 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// A nameMatcher finds the occurrences of a renamed name in the text of
+// comments and string literals, in the forms enabled by the
+// renameMatching setting, with their replacements.
+type nameMatcher struct {
+	forms            map[MatchForm]bool
+	oldName, newName string
+}
+
+// A nameMatch is an occurrence of a renamed name in a text.
+type nameMatch struct {
+	start, end int    // offsets of the occurrence in the text
+	newText    string // its replacement, in the same form as the occurrence
+}
+
+func newNameMatcher(forms map[MatchForm]bool, oldName, newName string) *nameMatcher {
+	return &nameMatcher{forms: forms, oldName: oldName, newName: newName}
+}
+
+// matches returns the occurrences of the old name as a whole word in text,
+// the source of a comment or of a string literal, in order.
+func (m *nameMatcher) matches(text string) []nameMatch {
+	seen := make(map[int]bool)
+	var matches []nameMatch
+	add := func(start int, old, new string) {
+		if !seen[start] {
+			seen[start] = true
+			matches = append(matches, nameMatch{start: start, end: start + len(old), newText: new})
+		}
+	}
+	for _, offset := range wordOffsets(text, m.oldName) {
+		if qualified := offset > 0 && text[offset-1] == '.'; qualified && m.forms[QualifiedMatch] || !qualified && m.forms[ExactMatch] {
+			add(offset, m.oldName, m.newName)
+		}
+	}
+	if m.forms[DerivedMatch] {
+		for _, form := range externalNameForms {
+			if old := form(m.oldName); old != m.oldName {
+				for _, offset := range wordOffsets(text, old) {
+					add(offset, old, form(m.newName))
+				}
+			}
+		}
+	}
+	if m.forms[CaseInsensitiveMatch] {
+		for _, w := range words(text) {
+			if word := text[w[0]:w[1]]; word != m.oldName && strings.EqualFold(word, m.oldName) {
+				add(w[0], word, matchCase(word, m.oldName, m.newName))
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+	return matches
+}
+
+// words returns the start and end offsets of the maximal runs of identifier
+// characters of text, leaving out the letters of the escape sequences of a
+// string literal, such as the n of \n.
+func words(text string) [][2]int {
+	var words [][2]int
+	for start := 0; start < len(text); {
+		r, size := utf8.DecodeRuneInString(text[start:])
+		if !isIdentRune(r) {
+			start += size
+			continue
+		}
+		if text[0] == '"' && start > 0 && text[start-1] == '\\' {
+			start += size
+		}
+		end := start
+		for end < len(text) {
+			r, size := utf8.DecodeRuneInString(text[end:])
+			if !isIdentRune(r) {
+				break
+			}
+			end += size
+		}
+		if end > start {
+			words = append(words, [2]int{start, end})
+		}
+		start = end
+	}
+	return words
+}
+
+// matchCase returns newName spelled in the case of word, a spelling of
+// oldName in another case: in lower or upper case, or with its first
+// letter changed, and as newName otherwise.
+func matchCase(word, oldName, newName string) string {
+	switch word {
+	case strings.ToLower(oldName):
+		return strings.ToLower(newName)
+	case strings.ToUpper(oldName):
+		return strings.ToUpper(newName)
+	case uncapitalize(oldName):
+		return uncapitalize(newName)
+	case capitalize(oldName):
+		return capitalize(newName)
+	}
+	return newName
+}
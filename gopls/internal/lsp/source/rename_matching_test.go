@@ -0,0 +1,42 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNameMatcher(t *testing.T) {
+	for _, tt := range []struct {
+		forms []MatchForm
+		text  string
+		want  string
+	}{
+		{[]MatchForm{ExactMatch, QualifiedMatch}, `"RetryCount of pkg.RetryCount"`, `"MaxAttempts of pkg.MaxAttempts"`},
+		{[]MatchForm{ExactMatch}, `"RetryCount of pkg.RetryCount"`, `"MaxAttempts of pkg.RetryCount"`},
+		{[]MatchForm{QualifiedMatch}, `"RetryCount of pkg.RetryCount"`, `"RetryCount of pkg.MaxAttempts"`},
+		{[]MatchForm{ExactMatch}, `"retrycount, RETRYCOUNT, retryCount"`, `"retrycount, RETRYCOUNT, retryCount"`},
+		{[]MatchForm{CaseInsensitiveMatch}, `"retrycount, RETRYCOUNT, retryCount, Retrycount"`, `"maxattempts, MAXATTEMPTS, maxAttempts, MaxAttempts"`},
+		{[]MatchForm{CaseInsensitiveMatch}, `"error\nretrycount"`, `"error\nmaxattempts"`},
+		{[]MatchForm{DerivedMatch}, `"retry_count, retry-count, RETRY_COUNT, RetryCount"`, `"max_attempts, max-attempts, MAX_ATTEMPTS, RetryCount"`},
+		{[]MatchForm{DerivedMatch, ExactMatch}, `"retry_count_max, RetryCounter"`, `"retry_count_max, RetryCounter"`},
+	} {
+		forms := make(map[MatchForm]bool)
+		for _, f := range tt.forms {
+			forms[f] = true
+		}
+		var b strings.Builder
+		last := 0
+		for _, m := range newNameMatcher(forms, "RetryCount", "MaxAttempts").matches(tt.text) {
+			b.WriteString(tt.text[last:m.start] + m.newText)
+			last = m.end
+		}
+		b.WriteString(tt.text[last:])
+		if got := b.String(); got != tt.want {
+			t.Errorf("matches(%s) with %v renamed to %s, want %s", tt.text, tt.forms, got, tt.want)
+		}
+	}
+}
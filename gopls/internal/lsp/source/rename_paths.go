@@ -0,0 +1,186 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+// caseInsensitivePaths reports whether the file systems of the host are
+// case-insensitive by default, so that paths differing only in case denote
+// the same file. It is a variable for testing.
+var caseInsensitivePaths = runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+
+// SamePath reports whether the file names a and b denote the same file
+// lexically: their volume names, such as drive letters, are compared
+// regardless of case, and so are the rest of them on case-insensitive file
+// systems. It does not consult the file system.
+func SamePath(a, b string) bool {
+	return pathKey(a) == pathKey(b)
+}
+
+// pathKey returns the form of the file name p that is the same for all the
+// names matched by SamePath.
+func pathKey(p string) string {
+	p = filepath.Clean(p)
+	v := filepath.VolumeName(p)
+	if caseInsensitivePaths {
+		return strings.ToLower(p)
+	}
+	return strings.ToLower(v) + p[len(v):]
+}
+
+// relPath returns the path of the file name p relative to the directory
+// dir, as matched by SamePath, and reports whether p lies in dir. The
+// relative path keeps the case of p.
+func relPath(dir, p string) (string, bool) {
+	dir, p = filepath.Clean(dir), filepath.Clean(p)
+	if SamePath(dir, p) {
+		return ".", true
+	}
+	n := len(dir) // the length of dir and the separator following it in p
+	if !strings.HasSuffix(dir, string(filepath.Separator)) {
+		n++
+	}
+	if len(p) <= n || p[n-1] != filepath.Separator || !SamePath(dir, p[:n]) {
+		return "", false
+	}
+	return p[n:], true
+}
+
+// CanonicalizeURIs merges, within each of edits, the entries of the URIs
+// that denote the same file, as matched by SamePath, such as those whose
+// drive letters or, on case-insensitive file systems, whose paths differ in
+// case. A client would apply the edits of each entry to the document
+// separately, against the same version of it. The entries are merged under
+// the URI of the file known to snapshot s, if there is one, or else the
+// least of them, the same in each of edits; identical edits are kept once.
+func CanonicalizeURIs(s Snapshot, edits ...map[span.URI][]protocol.TextEdit) {
+	canonicalizeURIs(func(uri span.URI) bool { return s.FindFile(uri) != nil }, edits...)
+}
+
+// canonicalizeURIs implements CanonicalizeURIs, where known reports
+// whether a URI is that of a file known to the snapshot.
+func canonicalizeURIs(known func(span.URI) bool, edits ...map[span.URI][]protocol.TextEdit) {
+	if !caseInsensitivePaths && runtime.GOOS != "windows" {
+		// Case-sensitive file systems without volume names have a
+		// single URI for each file.
+		return
+	}
+	var uris []span.URI
+	seen := make(map[span.URI]bool)
+	for _, m := range edits {
+		for uri := range m {
+			if !seen[uri] {
+				seen[uri] = true
+				uris = append(uris, uri)
+			}
+		}
+	}
+	if len(uris) < 2 {
+		return
+	}
+	sort.Slice(uris, func(i, j int) bool { return uris[i] < uris[j] })
+
+	// Group the URIs by file, and map each to the canonical URI of its
+	// group.
+	var groups [][]span.URI
+	index := make(map[string]int) // group index, by pathKey
+	for _, uri := range uris {
+		key := pathKey(uri.Filename())
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], uri)
+	}
+	if len(groups) == len(uris) {
+		return
+	}
+	canonical := make(map[span.URI]span.URI)
+	for _, group := range groups {
+		c := group[0]
+		for _, uri := range group {
+			if known(uri) {
+				c = uri
+				break
+			}
+		}
+		for _, uri := range group {
+			canonical[uri] = c
+		}
+	}
+
+	for _, m := range edits {
+		for _, uri := range uris {
+			c := canonical[uri]
+			e, ok := m[uri]
+			if !ok || c == uri {
+				continue
+			}
+			delete(m, uri)
+			m[c] = appendNewEdits(m[c], e)
+		}
+	}
+}
+
+// appendNewEdits appends the edits of more that are not in edits to it.
+func appendNewEdits(edits, more []protocol.TextEdit) []protocol.TextEdit {
+	have := make(map[protocol.TextEdit]bool)
+	for _, e := range edits {
+		have[e] = true
+	}
+	for _, e := range more {
+		if !have[e] {
+			have[e] = true
+			edits = append(edits, e)
+		}
+	}
+	return edits
+}
+
+// canonicalizeRenameURIs applies CanonicalizeURIs to the edits of a
+// rename, required and optional.
+func canonicalizeRenameURIs(s Snapshot, edits map[span.URI][]protocol.TextEdit, optional *OptionalEdits) {
+	if optional == nil {
+		CanonicalizeURIs(s, edits)
+		return
+	}
+	CanonicalizeURIs(s, edits, optional.Edits)
+}
+
+// RenameSteps returns the operations performing the renaming r of a file
+// or directory: r itself, unless its old and new names differ only in
+// case. Case-insensitive file systems find such a new name taken already,
+// so that the renaming would fail or do nothing; it goes through a
+// temporary name instead, on all file systems alike, for the edit to apply
+// everywhere.
+func RenameSteps(r protocol.RenameFile) []protocol.RenameFile {
+	oldPath, newPath := r.OldURI.SpanURI().Filename(), r.NewURI.SpanURI().Filename()
+	if oldPath == newPath || !strings.EqualFold(oldPath, newPath) {
+		return []protocol.RenameFile{r}
+	}
+	tmp := newPath + ".tmp"
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(tmp); os.IsNotExist(err) {
+			break
+		}
+		tmp = fmt.Sprintf("%s.tmp%d", newPath, i)
+	}
+	first, second := r, r
+	first.NewURI = protocol.URIFromPath(tmp)
+	second.OldURI = first.NewURI
+	return []protocol.RenameFile{first, second}
+}
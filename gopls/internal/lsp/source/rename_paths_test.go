@@ -0,0 +1,132 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+// withCaseInsensitivePaths sets caseInsensitivePaths to insensitive for
+// the duration of the test t.
+func withCaseInsensitivePaths(t *testing.T, insensitive bool) {
+	saved := caseInsensitivePaths
+	caseInsensitivePaths = insensitive
+	t.Cleanup(func() { caseInsensitivePaths = saved })
+}
+
+func TestRelPath(t *testing.T) {
+	for _, insensitive := range []bool{false, true} {
+		withCaseInsensitivePaths(t, insensitive)
+		for _, test := range []struct {
+			dir, path string
+			want      string // "" if path is not in dir
+			folded    bool   // path is in dir only on case-insensitive file systems
+		}{
+			{"/src/mod", "/src/mod/a/a.go", "a/a.go", false},
+			{"/src/mod/", "/src/mod/a.go", "a.go", false},
+			{"/src/mod", "/src/mod", ".", false},
+			{"/", "/src/a.go", "src/a.go", false},
+			{"/src/mod", "/src/module/a.go", "", false},
+			{"/src/mod", "/src/a.go", "", false},
+			{"/src/Mod", "/src/mod/A/a.go", "A/a.go", true},
+			{"/SRC/MOD", "/src/mod", ".", true},
+		} {
+			dir, path := filepath.FromSlash(test.dir), filepath.FromSlash(test.path)
+			want := test.want
+			if test.folded && !insensitive {
+				want = ""
+			}
+			got, ok := relPath(dir, path)
+			if ok != (want != "") || got != filepath.FromSlash(want) {
+				t.Errorf("relPath(%q, %q) with case-insensitive paths %t = %q, %t; want %q, %t", dir, path, insensitive, got, ok, want, want != "")
+			}
+		}
+	}
+}
+
+func TestCanonicalizeURIs(t *testing.T) {
+	withCaseInsensitivePaths(t, true)
+	edit := func(line uint32, text string) protocol.TextEdit {
+		return protocol.TextEdit{
+			Range:   protocol.Range{Start: protocol.Position{Line: line}, End: protocol.Position{Line: line, Character: 1}},
+			NewText: text,
+		}
+	}
+	open := span.URIFromPath(filepath.FromSlash("/src/Mod/a.go"))
+	other := span.URIFromPath(filepath.FromSlash("/src/mod/a.go"))
+	b := span.URIFromPath(filepath.FromSlash("/src/mod/b.go"))
+	edits := map[span.URI][]protocol.TextEdit{
+		other: {edit(1, "x"), edit(2, "y")},
+		b:     {edit(1, "x")},
+	}
+	optional := map[span.URI][]protocol.TextEdit{
+		open:  {edit(1, "x"), edit(3, "z")},
+		other: {edit(4, "w")},
+	}
+	canonicalizeURIs(func(uri span.URI) bool { return uri == open }, edits, optional)
+
+	wantEdits := map[span.URI][]protocol.TextEdit{
+		open: {edit(1, "x"), edit(2, "y")},
+		b:    {edit(1, "x")},
+	}
+	if !reflect.DeepEqual(edits, wantEdits) {
+		t.Errorf("got edits %v, want %v", edits, wantEdits)
+	}
+	wantOptional := map[span.URI][]protocol.TextEdit{
+		open: {edit(1, "x"), edit(3, "z"), edit(4, "w")},
+	}
+	if !reflect.DeepEqual(optional, wantOptional) {
+		t.Errorf("got optional edits %v, want %v", optional, wantOptional)
+	}
+
+	// On case-sensitive file systems, the URIs denote different files.
+	if runtime.GOOS != "windows" {
+		withCaseInsensitivePaths(t, false)
+		edits := map[span.URI][]protocol.TextEdit{
+			open:  {edit(1, "x")},
+			other: {edit(1, "x")},
+		}
+		want := map[span.URI][]protocol.TextEdit{
+			open:  {edit(1, "x")},
+			other: {edit(1, "x")},
+		}
+		canonicalizeURIs(func(uri span.URI) bool { return uri == open }, edits)
+		if !reflect.DeepEqual(edits, want) {
+			t.Errorf("with case-sensitive paths, got edits %v, want %v", edits, want)
+		}
+	}
+}
+
+func TestRenameSteps(t *testing.T) {
+	dir := t.TempDir()
+	rename := func(from, to string) protocol.RenameFile {
+		return protocol.RenameFile{
+			Kind:              "rename",
+			OldURI:            protocol.URIFromPath(filepath.Join(dir, from)),
+			NewURI:            protocol.URIFromPath(filepath.Join(dir, to)),
+			ResourceOperation: protocol.ResourceOperation{AnnotationID: "files"},
+		}
+	}
+	if got, want := RenameSteps(rename("a", "b")), []protocol.RenameFile{rename("a", "b")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RenameSteps(a, b) = %v, want %v", got, want)
+	}
+
+	// The temporary name is one that is not taken.
+	if err := os.Mkdir(filepath.Join(dir, "util.tmp"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	got := RenameSteps(rename("Util", "util"))
+	want := []protocol.RenameFile{rename("Util", "util.tmp1"), rename("util.tmp1", "util")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RenameSteps(Util, util) = %v, want %v", got, want)
+	}
+}
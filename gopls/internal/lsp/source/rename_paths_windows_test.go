@@ -0,0 +1,67 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build windows
+// +build windows
+
+package source
+
+import (
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+func TestSamePathWindows(t *testing.T) {
+	for _, test := range []struct {
+		a, b string
+		want bool
+	}{
+		{`C:\src\mod\a.go`, `c:\src\mod\a.go`, true},
+		{`C:\src\mod\a.go`, `C:\SRC\Mod\a.go`, true},
+		{`C:\src\mod\a.go`, `C:/src/mod/a.go`, true},
+		{`C:\src\mod\a.go`, `D:\src\mod\a.go`, false},
+		{`C:\src\mod\a.go`, `C:\src\mod\b.go`, false},
+	} {
+		if got := SamePath(test.a, test.b); got != test.want {
+			t.Errorf("SamePath(%q, %q) = %t, want %t", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestRelPathWindows(t *testing.T) {
+	for _, test := range []struct {
+		dir, path string
+		want      string
+		ok        bool
+	}{
+		{`C:\Users\Gopher\mod`, `c:\users\gopher\mod\a\a.go`, `a\a.go`, true},
+		{`c:\`, `C:\mod\a.go`, `mod\a.go`, true},
+		{`C:\mod`, `D:\mod\a.go`, "", false},
+		{`C:\mod`, `C:\module\a.go`, "", false},
+	} {
+		got, ok := relPath(test.dir, test.path)
+		if got != test.want || ok != test.ok {
+			t.Errorf("relPath(%q, %q) = %q, %t; want %q, %t", test.dir, test.path, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+// TestCanonicalizeURIsWindows checks that the edits of a file whose URIs,
+// as sent by a client and as loaded by go list, differ in the case of
+// their drive letters and directories are applied to a single document.
+func TestCanonicalizeURIsWindows(t *testing.T) {
+	open := span.URI("file:///c%3A/Users/Gopher/mod/a.go")
+	loaded := span.URIFromPath(`C:\users\gopher\mod\a.go`)
+	edit := protocol.TextEdit{NewText: "x"}
+	edits := map[span.URI][]protocol.TextEdit{
+		open:   {edit},
+		loaded: {edit},
+	}
+	canonicalizeURIs(func(uri span.URI) bool { return uri == open }, edits)
+	if len(edits) != 1 || len(edits[open]) != 1 {
+		t.Errorf("got edits %v, want a single edit of %s", edits, open)
+	}
+}
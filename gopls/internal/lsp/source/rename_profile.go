@@ -0,0 +1,197 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/types"
+	"time"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/internal/event"
+)
+
+// A RenameProfile holds the timings of the phases of the analysis of a
+// rename, as measured by ProfileRename.
+type RenameProfile struct {
+	Phases []RenamePhase
+	Total  time.Duration
+}
+
+// A RenamePhase is the profile of one phase of the analysis of a rename.
+type RenamePhase struct {
+	Name     string
+	Duration time.Duration
+	Size     int    // the number of results of the phase
+	Unit     string // what Size counts, such as "packages"
+}
+
+// ProfileRename runs the analysis of the renaming to newName of the object
+// or package at position pp of f phase by phase, and returns the duration
+// and the size of the results of each: the metadata of the packages
+// involved, the loading of the packages, the search of the references,
+// the computation of the interface satisfaction constraints and of the
+// implementations of a renamed method, the check of the conflicts of the
+// renaming, and the generation of its edits. The phases run in order in
+// s, once each, so the packages type checked by one phase are cached for
+// the next, as they are in a rename. The optional edits of a renamed
+// object, such as those of the string literals naming it, are left out.
+func ProfileRename(ctx context.Context, s Snapshot, f FileHandle, pp protocol.Position, newName string) (*RenameProfile, error) {
+	ctx, done := event.Start(ctx, "source.ProfileRename")
+	defer done()
+
+	profile := new(RenameProfile)
+	start := time.Now()
+	phase := func(name, unit string, run func() (int, error)) error {
+		t := time.Now()
+		size, err := run()
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		profile.Phases = append(profile.Phases, RenamePhase{Name: name, Duration: time.Since(t), Size: size, Unit: unit})
+		return nil
+	}
+
+	var ids []string
+	if err := phase("metadata", "packages", func() (int, error) {
+		metas, err := s.MetadataForFile(ctx, f.URI())
+		if err != nil {
+			return 0, err
+		}
+		pkgs := make(map[string]bool)
+		for _, m := range metas {
+			ids = append(ids, m.PackageID())
+			pkgs[m.PackageID()] = true
+			rdeps, err := s.ReverseDependencyIDs(ctx, m.PackageID())
+			if err != nil {
+				return 0, err
+			}
+			for _, id := range rdeps {
+				pkgs[id] = true
+			}
+		}
+		return len(pkgs), nil
+	}); err != nil {
+		return nil, err
+	}
+
+	pgf, err := s.ParseGo(ctx, f, ParseFull)
+	if err != nil {
+		return nil, err
+	}
+	inPackageName, err := isInPackageName(ctx, s, f, pgf, pp)
+	if err != nil {
+		return nil, err
+	}
+	var qos []qualifiedObject
+	if err := phase("load", "packages", func() (int, error) {
+		if !inPackageName {
+			var err error
+			if qos, err = qualifiedObjsAtProtocolPos(ctx, s, f.URI(), pp); err != nil {
+				return 0, err
+			}
+			ids = qualifiedObjPackageIDs(qos)
+		}
+		pkgs := make(map[string]bool)
+		for _, id := range ids {
+			pkgs[id] = true
+			rdepIDs, err := s.ReverseDependencyIDs(ctx, id)
+			if err != nil {
+				return 0, err
+			}
+			rdeps, err := s.CheckPackages(ctx, rdepIDs)
+			var errs PackagesError
+			if err != nil && !errors.As(err, &errs) {
+				return 0, err
+			}
+			for _, rdep := range rdeps {
+				pkgs[rdep.ID()] = true
+			}
+		}
+		return len(pkgs), nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if inPackageName {
+		if err := phase("edits", "edits", func() (int, error) {
+			edits, optional, err := renamePackageAt(ctx, s, f, newName, "", true)
+			if err != nil {
+				return 0, err
+			}
+			n := countEdits(edits)
+			if optional != nil {
+				n += countEdits(optional.Edits)
+			}
+			return n, nil
+		}); err != nil {
+			return nil, err
+		}
+	} else if len(qos) > 0 {
+		var refs []*ReferenceInfo
+		if err := phase("references", "references", func() (int, error) {
+			var err error
+			refs, err = references(ctx, s, qos, true, false, true, true)
+			return len(refs), err
+		}); err != nil {
+			return nil, err
+		}
+
+		// The remaining phases are those of the renamer, which keeps the
+		// results of each for the next, as in a rename: the constraints
+		// computed by the satisfy phase are those that the check phase
+		// looks up. Conflicts are reported rather than fatal, so that a
+		// rename with conflicts can be profiled too.
+		r, err := newRenamer(ctx, s, qos[0].obj, newName, refs, false, true)
+		if err != nil {
+			return nil, err
+		}
+		if err := phase("satisfy", "constraints", func() (int, error) {
+			constraints := r.satisfy()
+			if r.hadConflicts {
+				return 0, errors.New(r.errors)
+			}
+			return len(constraints), nil
+		}); err != nil {
+			return nil, err
+		}
+
+		if fn, ok := qos[0].obj.(*types.Func); ok && fn.Type().(*types.Signature).Recv() != nil {
+			if err := phase("implementations", "implementations", func() (int, error) {
+				impls, err := implementations(ctx, s, f, pp, qos[0].obj.Name())
+				return len(impls), err
+			}); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := phase("check", "conflicts", func() (int, error) {
+			for _, ref := range refs {
+				r.check(ref.obj)
+			}
+			return len(r.conflicts), nil
+		}); err != nil {
+			return nil, err
+		}
+
+		if err := phase("edits", "edits", func() (int, error) {
+			changes, err := r.update()
+			if err != nil {
+				return 0, err
+			}
+			n := 0
+			for _, edits := range changes {
+				n += len(edits)
+			}
+			return n, nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	profile.Total = time.Since(start)
+	return profile, nil
+}
@@ -0,0 +1,224 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/event"
+)
+
+// A ReceiverAlignment is the renaming of the receivers of all the methods
+// of a type to a common name.
+type ReceiverAlignment struct {
+	TypeName, NewName string
+	Location          protocol.Location // the name of the declaration of the type
+}
+
+// A methodReceiver is the receiver of a method declaration.
+type methodReceiver struct {
+	pgf   *ParsedGoFile
+	decl  *ast.FuncDecl
+	field *ast.Field
+}
+
+// name returns the identifier of the receiver, or nil if it is unnamed.
+func (r methodReceiver) name() *ast.Ident {
+	if len(r.field.Names) == 0 {
+		return nil
+	}
+	return r.field.Names[0]
+}
+
+// ReceiverAlignments returns the renamings of the receivers of the type
+// whose declaration, or the declaration of one of whose methods, encloses
+// rng of the Go file fh: to the first letter of the name of the type in
+// lower case, and to the name of the renameReceiverName setting, if any.
+// The names that all the receivers already have are left out.
+func ReceiverAlignments(ctx context.Context, s Snapshot, fh FileHandle, rng protocol.Range) ([]ReceiverAlignment, error) {
+	ctx, done := event.Start(ctx, "source.ReceiverAlignments")
+	defer done()
+
+	pkg, pgf, err := GetParsedFile(ctx, s, fh, WidestPackage)
+	if err != nil {
+		return nil, err
+	}
+	srng, err := pgf.Mapper.RangeToSpanRange(rng)
+	if err != nil {
+		return nil, err
+	}
+	info := pkg.GetTypesInfo()
+	var tn *types.TypeName
+	path, _ := astutil.PathEnclosingInterval(pgf.File, srng.Start, srng.End)
+	for _, n := range path {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			// Only the receiver and the name of a method select its type.
+			if n.Recv != nil && srng.End <= n.Name.End() {
+				if fn, ok := info.Defs[n.Name].(*types.Func); ok {
+					tn = receiverTypeName(fn)
+				}
+			}
+		case *ast.TypeSpec:
+			if srng.End <= n.Name.End() {
+				tn, _ = info.Defs[n.Name].(*types.TypeName)
+			}
+		default:
+			continue
+		}
+		break
+	}
+	if tn == nil {
+		return nil, nil
+	}
+	receivers := methodReceivers(pkg, tn)
+	if len(receivers) == 0 {
+		return nil, nil
+	}
+	rngType, err := renameMappedRange(s.FileSet(), pkg, tn.Pos(), objNameEnd(tn))
+	if err != nil {
+		return nil, err
+	}
+	declRng, err := rngType.Range()
+	if err != nil {
+		return nil, err
+	}
+	first, _ := utf8.DecodeRuneInString(tn.Name())
+	var alignments []ReceiverAlignment
+	for _, name := range []string{string(unicode.ToLower(first)), s.View().Options().RenameReceiverName} {
+		if name == "" || (len(alignments) > 0 && alignments[0].NewName == name) {
+			continue
+		}
+		aligned := true
+		for _, r := range receivers {
+			if id := r.name(); id == nil || id.Name != name {
+				aligned = false
+				break
+			}
+		}
+		if !aligned {
+			alignments = append(alignments, ReceiverAlignment{
+				TypeName: tn.Name(),
+				NewName:  name,
+				Location: protocol.Location{URI: protocol.URIFromSpanURI(rngType.URI()), Range: declRng},
+			})
+		}
+	}
+	return alignments, nil
+}
+
+// ReceiverAlignmentEdits returns the edits renaming the receivers of all the
+// methods of the type named at pp of the Go file fh to newName. The named
+// receivers are renamed by the rename engine, so that their references
+// within the methods are updated; the unnamed and blank receivers are
+// named newName.
+func ReceiverAlignmentEdits(ctx context.Context, s Snapshot, fh FileHandle, pp protocol.Position, newName string) (map[span.URI][]protocol.TextEdit, error) {
+	ctx, done := event.Start(ctx, "source.ReceiverAlignmentEdits")
+	defer done()
+
+	if !isValidIdentifier(newName) {
+		return nil, fmt.Errorf("invalid identifier to rename: %q", newName)
+	}
+	pkg, pgf, err := GetParsedFile(ctx, s, fh, WidestPackage)
+	if err != nil {
+		return nil, err
+	}
+	pos, err := pgf.Mapper.Pos(pp)
+	if err != nil {
+		return nil, err
+	}
+	path, _ := astutil.PathEnclosingInterval(pgf.File, pos, pos)
+	var tn *types.TypeName
+	if len(path) > 0 {
+		if id, ok := path[0].(*ast.Ident); ok {
+			tn, _ = pkg.GetTypesInfo().ObjectOf(id).(*types.TypeName)
+		}
+	}
+	if tn == nil {
+		return nil, fmt.Errorf("no type name at %d:%d", pp.Line+1, pp.Character+1)
+	}
+
+	edits := make(map[span.URI][]protocol.TextEdit)
+	for _, r := range methodReceivers(pkg, tn) {
+		id := r.name()
+		if id != nil && id.Name == newName {
+			continue
+		}
+		if id == nil || id.Name == "_" {
+			// There are no references to rename.
+			start, end := r.field.Type.Pos(), r.field.Type.Pos()
+			newText := newName + " "
+			if id != nil {
+				start, end, newText = id.Pos(), id.End(), newName
+			}
+			rng, err := r.pgf.Mapper.PosRange(start, end)
+			if err != nil {
+				return nil, err
+			}
+			edits[r.pgf.URI] = append(edits[r.pgf.URI], protocol.TextEdit{Range: rng, NewText: newText})
+			continue
+		}
+		rng, err := r.pgf.Mapper.PosRange(id.Pos(), id.End())
+		if err != nil {
+			return nil, err
+		}
+		recvFh, err := s.GetFile(ctx, r.pgf.URI)
+		if err != nil {
+			return nil, err
+		}
+		renameEdits, _, _, err := rename(ctx, s, recvFh, rng.Start, newName, false, false)
+		if err != nil {
+			return nil, fmt.Errorf("renaming the receiver of %s.%s: %v", tn.Name(), r.decl.Name.Name, err)
+		}
+		for uri, e := range renameEdits {
+			edits[uri] = append(edits[uri], e...)
+		}
+	}
+	return edits, nil
+}
+
+// methodReceivers returns the receivers of the declarations of the methods
+// of tn in the files of pkg, in order.
+func methodReceivers(pkg Package, tn *types.TypeName) []methodReceiver {
+	var receivers []methodReceiver
+	info := pkg.GetTypesInfo()
+	for _, pgf := range pkg.CompiledGoFiles() {
+		for _, decl := range pgf.File.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || len(fn.Recv.List) == 0 {
+				continue
+			}
+			if obj, ok := info.Defs[fn.Name].(*types.Func); ok && receiverTypeName(obj) == tn {
+				receivers = append(receivers, methodReceiver{pgf: pgf, decl: fn, field: fn.Recv.List[0]})
+			}
+		}
+	}
+	return receivers
+}
+
+// receiverTypeName returns the name of the receiver base type of the
+// method fn, or nil if it has none.
+func receiverTypeName(fn *types.Func) *types.TypeName {
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return nil
+	}
+	t := recv.Type()
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	if named, ok := t.(*types.Named); ok {
+		return named.Obj()
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/safetoken"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+// reflectiveNameEdits returns edits renaming the string literals that denote
+// the field or method obj by name through package reflect, in the package
+// declaring obj and its reverse dependencies: the arguments of lookups such
+// as reflect.Value.MethodByName("Foo") or reflect.Type.FieldByName("Foo"),
+// and the operands of comparisons with the Name of a reflect.StructField or
+// reflect.Method, such as f.Name == "Foo".
+//
+// The type being inspected is generally unknown statically, so every such
+// literal spelled like obj is a candidate. Unlike compile errors, stale names
+// break only at run time, which is why they are reported at all.
+func reflectiveNameEdits(ctx context.Context, s Snapshot, qo qualifiedObject, newName string) (map[span.URI][]protocol.TextEdit, error) {
+	switch obj := qo.obj.(type) {
+	case *types.Var:
+		if !obj.IsField() {
+			return nil, nil
+		}
+	case *types.Func:
+		if sig, ok := obj.Type().(*types.Signature); !ok || sig.Recv() == nil {
+			return nil, nil
+		}
+	default:
+		return nil, nil
+	}
+	pkgs, err := loadReverseDependencies(ctx, s, qo.obj.Name(), qo.pkg.ID())
+	if err != nil {
+		return nil, err
+	}
+	pkgs = append(pkgs, qo.pkg)
+
+	// Find the lookups, then the comparisons.
+	lits := nameArguments(pkgs, reflectMatchers, qo.obj.Name())
+	for _, pkg := range pkgs {
+		info := pkg.GetTypesInfo()
+		for _, pgf := range pkg.CompiledGoFiles() {
+			ast.Inspect(pgf.File, func(n ast.Node) bool {
+				if n, ok := n.(*ast.BinaryExpr); ok && (n.Op == token.EQL || n.Op == token.NEQ) {
+					for _, pair := range [][2]ast.Expr{{n.X, n.Y}, {n.Y, n.X}} {
+						if isReflectName(info, pair[0]) {
+							if lit := stringLitNamed(pair[1], qo.obj.Name()); lit != nil {
+								lits = append(lits, nameArgument{pgf: pgf, lit: lit})
+							}
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+
+	edits := make(map[span.URI][]protocol.TextEdit)
+	seen := make(map[positionKey]bool) // files may belong to several packages
+	for _, arg := range lits {
+		pgf, lit := arg.pgf, arg.lit
+		offset, err := safetoken.Offset(pgf.Tok, lit.Pos())
+		if err != nil {
+			return nil, err
+		}
+		key := positionKey{pgf.URI, offset}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		rng, err := NewMappedRange(pgf.Tok, pgf.Mapper, lit.Pos(), lit.End()).Range()
+		if err != nil {
+			return nil, err
+		}
+		edits[pgf.URI] = append(edits[pgf.URI], protocol.TextEdit{Range: rng, NewText: strconv.Quote(newName)})
+	}
+	return edits, nil
+}
+
+// isReflectName reports whether e selects the Name field of a
+// reflect.StructField or reflect.Method.
+func isReflectName(info *types.Info, e ast.Expr) bool {
+	sel, ok := e.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Name" {
+		return false
+	}
+	named, ok := info.TypeOf(sel.X).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Path() != "reflect" {
+		return false
+	}
+	switch named.Obj().Name() {
+	case "StructField", "Method":
+		return true
+	}
+	return false
+}
+
+// stringLitNamed returns e if it is a string literal whose value is name,
+// and nil otherwise.
+func stringLitNamed(e ast.Expr, name string) *ast.BasicLit {
+	for {
+		paren, ok := e.(*ast.ParenExpr)
+		if !ok {
+			break
+		}
+		e = paren.X
+	}
+	lit, ok := e.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return nil
+	}
+	if value, err := strconv.Unquote(lit.Value); err != nil || value != name {
+		return nil
+	}
+	return lit
+}
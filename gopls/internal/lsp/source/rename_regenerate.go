@@ -0,0 +1,112 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+// isPlatformWrapper reports whether filename is that of a generated file
+// wrapping platform or framework APIs for the rest of its package, such as
+// the zsyscall_linux_amd64.go files of mksyscall and the
+// zz_generated.deepcopy.go files of the Kubernetes code generators. Unlike
+// other generated files, these routinely refer to the objects that renames
+// change, and must be updated for the package to keep building.
+func isPlatformWrapper(filename string) bool {
+	base := filepath.Base(filename)
+	if !strings.HasSuffix(base, ".go") {
+		return false
+	}
+	return strings.HasPrefix(base, "zsyscall_") || strings.HasPrefix(base, "zz_generated.")
+}
+
+// generatorRx matches the comment marking generated files, capturing the
+// generator it names.
+var generatorRx = regexp.MustCompile(`^// Code generated by (.+?)[.;,]? DO NOT EDIT\.?$`)
+
+// generatorOf returns the generator that the header of the Go file content
+// names, or "" if it names none. The mksyscall generators name the command
+// line on the previous line of their headers, "the command above".
+func generatorOf(content []byte) string {
+	var prev string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+		if m := generatorRx.FindStringSubmatch(line); m != nil {
+			if strings.HasPrefix(m[1], "the command above") && strings.HasPrefix(prev, "// ") {
+				return strings.TrimPrefix(prev, "// ")
+			}
+			return m[1]
+		}
+		prev = line
+	}
+	return ""
+}
+
+// separateWrappers applies the policy of platform wrappers, generated files
+// whose edits are required whatever the generated file policy, to the edits
+// of result: if annotate is set, it moves the edits of each generator's
+// files to an annotation recommending to regenerate them, or else it keeps
+// them in result with a warning. It returns the files of these edits, which
+// are never considered generated otherwise. The file declaring the renamed
+// object, declURI, is no platform wrapper.
+func (o *OptionalEdits) separateWrappers(ctx context.Context, s Snapshot, result map[span.URI][]protocol.TextEdit, declURI span.URI, annotate bool) (map[span.URI]bool, error) {
+	wrappers := make(map[span.URI]bool)
+	byGenerator := make(map[string][]span.URI)
+	for uri := range result {
+		if SamePath(uri.Filename(), declURI.Filename()) || !isPlatformWrapper(uri.Filename()) || !IsGenerated(ctx, s, uri) {
+			continue
+		}
+		content, err := fileContent(s, uri)
+		if err != nil {
+			return nil, err
+		}
+		gen := generatorOf(content)
+		wrappers[uri] = true
+		byGenerator[gen] = append(byGenerator[gen], uri)
+	}
+
+	var generators []string
+	for gen := range byGenerator {
+		generators = append(generators, gen)
+	}
+	sort.Strings(generators)
+	for i, gen := range generators {
+		uris := byGenerator[gen]
+		sort.Slice(uris, func(i, j int) bool { return uris[i] < uris[j] })
+		var files []string
+		for _, uri := range uris {
+			files = append(files, filepath.Base(uri.Filename()))
+		}
+		generated := strings.Join(files, ", ")
+		if gen != "" {
+			generated += ", generated by " + gen
+		}
+		if !annotate {
+			o.Warnings = append(o.Warnings, "regenerate recommended: "+generated)
+			continue
+		}
+		edits := make(map[span.URI][]protocol.TextEdit)
+		for _, uri := range uris {
+			edits[uri] = result[uri]
+			delete(result, uri)
+		}
+		o.addAnnotatedEdits(RegenerateGroup, fmt.Sprint(i), protocol.ChangeAnnotation{
+			Label:       "Regenerate recommended",
+			Description: fmt.Sprintf("%d edits in %s: regenerate them once renamed", countEdits(edits), generated),
+		}, edits)
+	}
+	return wrappers, nil
+}
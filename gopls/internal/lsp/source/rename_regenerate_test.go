@@ -0,0 +1,24 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import "testing"
+
+func TestGeneratorOf(t *testing.T) {
+	for _, test := range []struct {
+		header, want string
+	}{
+		{"// Code generated by controller-gen. DO NOT EDIT.\n\npackage a\n", "controller-gen"},
+		{"//go:build windows\n\n// Code generated by 'go generate'; DO NOT EDIT.\n\npackage a\n", "'go generate'"},
+		{"// go run mksyscall.go -tags linux,amd64 syscall_linux.go\n// Code generated by the command above; see README.md. DO NOT EDIT.\n\npackage unix\n", "go run mksyscall.go -tags linux,amd64 syscall_linux.go"},
+		{"// Code generated by mkwinsyscall. DO NOT EDIT.\r\n\r\npackage windows\r\n", "mkwinsyscall"},
+		{"// DO NOT EDIT.\n\npackage a\n", ""},
+		{"package a\n\n// Code generated by gen. DO NOT EDIT.\n", ""},
+	} {
+		if got := generatorOf([]byte(test.header)); got != test.want {
+			t.Errorf("generatorOf(%q) = %q, want %q", test.header, got, test.want)
+		}
+	}
+}
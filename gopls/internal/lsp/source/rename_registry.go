@@ -0,0 +1,243 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/types/typeutil"
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+// registrationEdits holds the edits and warnings concerning the names under
+// which a renamed object is registered by string.
+type registrationEdits struct {
+	update   map[span.URI][]protocol.TextEdit // edits renaming the registrations
+	preserve map[span.URI][]protocol.TextEdit // edits keeping the old wire names
+	warnings []string
+}
+
+// findRegistrations returns the edits and warnings concerning the strings
+// under which the object of qo is registered, in its package and their
+// reverse dependencies, when renamed to newName:
+//
+//   - the keys of text/template and html/template FuncMaps spelled like the
+//     function they map to, which templates call by name;
+//   - the names passed to gob.RegisterName with a value of a renamed type;
+//   - the service methods named "Type.Method" in the calls of a net/rpc
+//     Client, for a renamed method or service type;
+//   - the calls to gob.Register and rpc.Register with a value of a renamed
+//     type, which derive the name they register from the type's name.
+//
+// Names in the first three cases may be updated, breaking the templates,
+// streams or remote clients that use the old name. Calls in the last case
+// may be changed to register the old name explicitly, preserving wire
+// compatibility.
+func findRegistrations(ctx context.Context, s Snapshot, qo qualifiedObject, newName string) (*registrationEdits, error) {
+	result := &registrationEdits{
+		update:   make(map[span.URI][]protocol.TextEdit),
+		preserve: make(map[span.URI][]protocol.TextEdit),
+	}
+	obj := qo.obj
+	switch obj.(type) {
+	case *types.Func, *types.TypeName:
+	default:
+		return result, nil
+	}
+	pkgs, err := loadReverseDependencies(ctx, s, qo.obj.Name(), qo.pkg.ID())
+	if err != nil {
+		return nil, err
+	}
+	pkgs = append(pkgs, qo.pkg)
+
+	// isRenamedType reports whether t is, or points to, the renamed type.
+	isRenamedType := func(t types.Type) (bool, bool) {
+		ptr, isPtr := t.(*types.Pointer)
+		if isPtr {
+			t = ptr.Elem()
+		}
+		named, ok := t.(*types.Named)
+		return ok && equalOrigin(named.Obj(), obj), isPtr
+	}
+
+	seen := make(map[span.URI]map[token.Pos]bool) // files may belong to several packages
+	for _, pkg := range pkgs {
+		info := pkg.GetTypesInfo()
+		for _, pgf := range pkg.CompiledGoFiles() {
+			if seen[pgf.URI] == nil {
+				seen[pgf.URI] = make(map[token.Pos]bool)
+			}
+			edit := func(edits map[span.URI][]protocol.TextEdit, start, end token.Pos, text string) error {
+				if seen[pgf.URI][start] {
+					return nil
+				}
+				seen[pgf.URI][start] = true
+				rng, err := NewMappedRange(pgf.Tok, pgf.Mapper, start, end).Range()
+				if err != nil {
+					return err
+				}
+				edits[pgf.URI] = append(edits[pgf.URI], protocol.TextEdit{Range: rng, NewText: text})
+				return nil
+			}
+			var err error
+			ast.Inspect(pgf.File, func(n ast.Node) bool {
+				if err != nil {
+					return false
+				}
+				switch n := n.(type) {
+				case *ast.CompositeLit:
+					if !isNamedType(info.TypeOf(n), "FuncMap", "text/template", "html/template") {
+						break
+					}
+					for _, elt := range n.Elts {
+						kv, ok := elt.(*ast.KeyValueExpr)
+						if !ok || !refersTo(info, kv.Value, obj) {
+							continue
+						}
+						if lit := stringLitNamed(kv.Key, obj.Name()); lit != nil {
+							err = edit(result.update, lit.Pos(), lit.End(), strconv.Quote(newName))
+							result.warnings = append(result.warnings,
+								fmt.Sprintf("templates calling the function %q must be updated", obj.Name()))
+						}
+					}
+
+				case *ast.CallExpr:
+					fn, ok := typeutil.Callee(info, n).(*types.Func)
+					if !ok || fn.Pkg() == nil {
+						break
+					}
+					sig := fn.Type().(*types.Signature)
+					switch path, name := fn.Pkg().Path(), fn.Name(); {
+					case path == "encoding/gob" && name == "RegisterName" && len(n.Args) == 2:
+						if renamed, _ := isRenamedType(info.TypeOf(n.Args[1])); !renamed {
+							break
+						}
+						lit, ok := n.Args[0].(*ast.BasicLit)
+						if !ok || lit.Kind != token.STRING {
+							break
+						}
+						value, _ := strconv.Unquote(lit.Value)
+						if prefix := strings.TrimSuffix(value, obj.Name()); prefix != value && (prefix == "" || strings.HasSuffix(prefix, ".") || prefix == "*") {
+							err = edit(result.update, lit.Pos(), lit.End(), strconv.Quote(prefix+newName))
+							result.warnings = append(result.warnings,
+								fmt.Sprintf("gob streams using the registered name %q will no longer decode if it is updated", value))
+						}
+
+					case (path == "encoding/gob" || path == "net/rpc") && name == "Register" && len(n.Args) == 1:
+						renamed, isPtr := isRenamedType(info.TypeOf(n.Args[0]))
+						sel, ok := n.Fun.(*ast.SelectorExpr)
+						if !renamed || !ok {
+							break
+						}
+						wireName := obj.Name() // rpc service name
+						if path == "encoding/gob" {
+							wireName = obj.Pkg().Path() + "." + obj.Name()
+							if isPtr {
+								wireName = "*" + wireName
+							}
+						}
+						if err = edit(result.preserve, sel.Sel.Pos(), sel.Sel.End(), "RegisterName"); err == nil {
+							err = edit(result.preserve, n.Args[0].Pos(), n.Args[0].Pos(), strconv.Quote(wireName)+", ")
+						}
+						result.warnings = append(result.warnings,
+							fmt.Sprintf("%s.Register registers %q under a name derived from its type, which the rename changes", importPathBase(path), wireName))
+
+					case path == "net/rpc" && sig.Recv() != nil && (name == "Call" || name == "Go") && len(n.Args) > 0:
+						lit, ok := n.Args[0].(*ast.BasicLit)
+						if !ok || lit.Kind != token.STRING {
+							break
+						}
+						value, _ := strconv.Unquote(lit.Value)
+						dot := strings.IndexByte(value, '.')
+						if dot < 0 {
+							break
+						}
+						service, method := value[:dot], value[dot+1:]
+						switch obj := obj.(type) {
+						case *types.TypeName:
+							if service != obj.Name() {
+								return true
+							}
+							service = newName
+						case *types.Func:
+							recv := obj.Type().(*types.Signature).Recv()
+							if recv == nil || method != obj.Name() {
+								return true
+							}
+							if named, ok := Deref(recv.Type()).(*types.Named); !ok || named.Obj().Name() != service {
+								return true
+							}
+							method = newName
+						}
+						err = edit(result.update, lit.Pos(), lit.End(), strconv.Quote(service+"."+method))
+						result.warnings = append(result.warnings,
+							fmt.Sprintf("remote clients calling %q must be updated", value))
+					}
+				}
+				return true
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	result.warnings = dedupeStrings(result.warnings)
+	return result, nil
+}
+
+// dedupeStrings removes the repeated elements of a, preserving their order.
+func dedupeStrings(a []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, s := range a {
+		if !seen[s] {
+			seen[s] = true
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// isNamedType reports whether t is the named type name declared in one of
+// the packages with the given paths.
+func isNamedType(t types.Type, name string, paths ...string) bool {
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Name() != name || named.Obj().Pkg() == nil {
+		return false
+	}
+	for _, path := range paths {
+		if named.Obj().Pkg().Path() == path {
+			return true
+		}
+	}
+	return false
+}
+
+// refersTo reports whether e is an identifier or selector denoting obj.
+func refersTo(info *types.Info, e ast.Expr, obj types.Object) bool {
+	var id *ast.Ident
+	switch e := e.(type) {
+	case *ast.Ident:
+		id = e
+	case *ast.SelectorExpr:
+		id = e.Sel
+	default:
+		return false
+	}
+	used := info.Uses[id]
+	return used != nil && equalOrigin(used, obj)
+}
+
+// importPathBase returns the last element of an import path.
+func importPathBase(path string) string {
+	return path[strings.LastIndexByte(path, '/')+1:]
+}
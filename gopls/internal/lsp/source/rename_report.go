@@ -0,0 +1,135 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/event"
+)
+
+// A RenameReport describes the effects of a rename, computed by
+// DryRunRename without applying them.
+type RenameReport struct {
+	Edits    map[span.URI][]protocol.TextEdit // the required edits
+	Optional *OptionalEdits                   // the optional edits, warnings and conflicts, or nil
+	Package  bool                             // whether a package is renamed
+	Packages map[span.URI][]string            // IDs of the packages of each edited Go file
+
+	// Exported reports whether the renamed object or package may be
+	// referred to by importers outside the workspace, which the rename
+	// cannot update.
+	Exported bool
+}
+
+// DryRunRename performs the analysis of renaming the object or package at
+// position pp to newName, as Rename does, and reports its effects. Unlike
+// Rename, it reports the conflicts of the renaming instead of failing, and
+// separates all the optional edits into annotated groups, regardless of the
+// capabilities of the client.
+func DryRunRename(ctx context.Context, s Snapshot, f FileHandle, pp protocol.Position, newName string) (*RenameReport, error) {
+	ctx, done := event.Start(ctx, "source.DryRunRename")
+	defer done()
+
+	pgf, err := s.ParseGo(ctx, f, ParseFull)
+	if err != nil {
+		return nil, err
+	}
+	mention, err := findCommentMention(ctx, s, pgf, pp)
+	if err != nil {
+		return nil, err
+	}
+	if mention != nil {
+		if f, err = s.GetFile(ctx, mention.uri); err != nil {
+			return nil, err
+		}
+		pp = mention.pos
+	}
+	edits, optional, isPkg, err := rename(ctx, s, f, pp, newName, true, true)
+	if err != nil {
+		return nil, err
+	}
+	canonicalizeRenameURIs(s, edits, optional)
+	report := &RenameReport{
+		Edits:    edits,
+		Optional: optional,
+		Package:  isPkg,
+		Packages: make(map[span.URI][]string),
+	}
+	addPackages := func(edits map[span.URI][]protocol.TextEdit) error {
+		for uri := range edits {
+			if _, ok := report.Packages[uri]; ok || !strings.HasSuffix(uri.Filename(), ".go") {
+				continue
+			}
+			metas, err := s.MetadataForFile(ctx, uri)
+			if err != nil {
+				return err
+			}
+			ids := []string{} // non-nil, to visit each file once
+			for _, m := range metas {
+				ids = append(ids, m.PackageID())
+			}
+			report.Packages[uri] = ids
+		}
+		return nil
+	}
+	if err := addPackages(edits); err != nil {
+		return nil, err
+	}
+	if optional != nil {
+		if err := addPackages(optional.Edits); err != nil {
+			return nil, err
+		}
+	}
+
+	if isPkg {
+		metas, err := s.MetadataForFile(ctx, f.URI())
+		if err != nil {
+			return nil, err
+		}
+		if len(metas) == 0 {
+			return nil, fmt.Errorf("no packages found for file %q", f.URI())
+		}
+		report.Exported = importable(metas[0].PackagePath(), metas[0].PackageName())
+		return report, nil
+	}
+	qos, err := qualifiedObjsAtProtocolPos(ctx, s, f.URI(), pp)
+	if err != nil {
+		return nil, err
+	}
+	obj := qos[0].obj
+	report.Exported = obj.Pkg() != nil && importable(obj.Pkg().Path(), obj.Pkg().Name()) && exportedMember(obj)
+	return report, nil
+}
+
+// importable reports whether the package of the given path and name may be
+// imported from outside its module: it is neither a command nor internal.
+func importable(path, name string) bool {
+	return name != "main" && !strings.Contains("/"+path+"/", "/internal/")
+}
+
+// exportedMember reports whether obj is exported from its package: it is
+// an exported package-level object, or an exported field or method.
+func exportedMember(obj types.Object) bool {
+	if !obj.Exported() {
+		return false
+	}
+	switch obj := obj.(type) {
+	case *types.Var:
+		if obj.IsField() {
+			return true
+		}
+	case *types.Func:
+		if obj.Type().(*types.Signature).Recv() != nil {
+			return true
+		}
+	}
+	return obj.Parent() == obj.Pkg().Scope()
+}
@@ -0,0 +1,97 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+)
+
+// checkRenameScale returns an error describing the scale of the renaming
+// of what if its analysis would type check more packages than the
+// renameMaxPackages setting allows: the packages ids and, if exported is
+// set, their reverse dependencies. The packages are counted from their
+// metadata, before any of them is type checked.
+//
+// The packages of the renamed object are checked before its analysis
+// starts, and each further set of packages the analysis loads, such as
+// the reverse dependencies of the packages it searches or all the known
+// packages for the implementations of a method, is checked again before
+// it is loaded.
+func checkRenameScale(ctx context.Context, s Snapshot, what string, ids []string, exported bool) error {
+	if s.View().Options().RenameMaxPackages <= 0 {
+		return nil
+	}
+	pkgs := make(map[string]bool)
+	for _, id := range ids {
+		pkgs[id] = true
+		if !exported {
+			continue
+		}
+		rdeps, err := s.ReverseDependencyIDs(ctx, id)
+		if err != nil {
+			return err
+		}
+		for _, rdep := range rdeps {
+			pkgs[rdep] = true
+		}
+	}
+	return renameScaleError(s, what, len(pkgs))
+}
+
+// checkKnownPackagesScale is like checkRenameScale, for the analysis
+// loading all the packages known to s.
+func checkKnownPackagesScale(ctx context.Context, s Snapshot, what string) error {
+	if s.View().Options().RenameMaxPackages <= 0 {
+		return nil
+	}
+	ids, err := s.KnownPackageIDs(ctx)
+	if err != nil {
+		return err
+	}
+	return renameScaleError(s, what, len(ids))
+}
+
+// loadReverseDependencies returns the reverse dependencies of the package
+// id, for the analysis of the renaming of what, once checkRenameScale has
+// found them within the renameMaxPackages setting.
+func loadReverseDependencies(ctx context.Context, s Snapshot, what, id string) ([]Package, error) {
+	if err := checkRenameScale(ctx, s, what, []string{id}, true); err != nil {
+		return nil, err
+	}
+	return s.GetReverseDependencies(ctx, id)
+}
+
+// loadKnownPackages is like loadReverseDependencies, for all the packages
+// known to s.
+func loadKnownPackages(ctx context.Context, s Snapshot, what string) ([]Package, error) {
+	if err := checkKnownPackagesScale(ctx, s, what); err != nil {
+		return nil, err
+	}
+	return s.KnownPackages(ctx)
+}
+
+// renameScaleError returns the error of checkRenameScale if n packages
+// are more than the renameMaxPackages setting allows.
+func renameScaleError(s Snapshot, what string, n int) error {
+	max := s.View().Options().RenameMaxPackages
+	if max <= 0 || n <= max {
+		return nil
+	}
+	return fmt.Errorf("renaming %s would type check %d packages, more than the %d that the renameMaxPackages setting allows: restrict the scope of the rename, as by opening a narrower workspace folder or excluding directories with the directoryFilters setting, or raise the limit", what, n, max)
+}
+
+// qualifiedObjPackageIDs returns the IDs of the packages of qos.
+func qualifiedObjPackageIDs(qos []qualifiedObject) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, qo := range qos {
+		if id := qo.pkg.ID(); !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,117 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/internal/event"
+)
+
+// An ImportShadow is a local variable that shadows the name of a package
+// imported by its file, with a new name for it that conflicts with none.
+type ImportShadow struct {
+	Name, NewName string
+	Location      protocol.Location // the declaration of the variable
+}
+
+// ImportShadows returns the local variables, declared or referred to
+// within rng of the Go file fh, that shadow the name of a package imported
+// by fh, in the order of their first mention within rng.
+func ImportShadows(ctx context.Context, s Snapshot, fh FileHandle, rng protocol.Range) ([]ImportShadow, error) {
+	ctx, done := event.Start(ctx, "source.ImportShadows")
+	defer done()
+
+	pkg, pgf, err := GetParsedFile(ctx, s, fh, NarrowestPackage)
+	if err != nil {
+		return nil, err
+	}
+	info := pkg.GetTypesInfo()
+	imported := make(map[string]bool)
+	for _, imp := range pgf.File.Imports {
+		var obj types.Object
+		if imp.Name != nil {
+			obj = info.Defs[imp.Name]
+		} else {
+			obj = info.Implicits[imp]
+		}
+		if pkgName, ok := obj.(*types.PkgName); ok && pkgName.Name() != "_" && pkgName.Name() != "." {
+			imported[pkgName.Name()] = true
+		}
+	}
+	if len(imported) == 0 {
+		return nil, nil
+	}
+	srng, err := pgf.Mapper.RangeToSpanRange(rng)
+	if err != nil {
+		return nil, err
+	}
+
+	var used map[string]bool // the names in use in the file, computed lazily
+	var shadows []ImportShadow
+	seen := make(map[types.Object]bool)
+	var inspectErr error
+	ast.Inspect(pgf.File, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok || inspectErr != nil || id.End() < srng.Start || srng.End < id.Pos() {
+			return inspectErr == nil
+		}
+		v, ok := info.ObjectOf(id).(*types.Var)
+		if !ok || v.IsField() || v.Parent() == nil || v.Parent() == pkg.GetTypes().Scope() || !imported[v.Name()] || seen[v] {
+			return true
+		}
+		seen[v] = true
+		if used == nil {
+			used = namesInUse(pkg, pgf.File)
+		}
+		declRng, err := pgf.Mapper.PosRange(v.Pos(), v.Pos()+token.Pos(len(v.Name())))
+		if err != nil {
+			inspectErr = err
+			return false
+		}
+		newName := freeName(v.Name(), used)
+		used[newName] = true
+		shadows = append(shadows, ImportShadow{
+			Name:     v.Name(),
+			NewName:  newName,
+			Location: protocol.Location{URI: protocol.URIFromSpanURI(fh.URI()), Range: declRng},
+		})
+		return true
+	})
+	return shadows, inspectErr
+}
+
+// namesInUse returns the names of the identifiers of file, and those of the
+// package-level and predeclared objects, which a new local name may
+// conflict with.
+func namesInUse(pkg Package, file *ast.File) map[string]bool {
+	used := make(map[string]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			used[id.Name] = true
+		}
+		return true
+	})
+	for _, scope := range []*types.Scope{pkg.GetTypes().Scope(), types.Universe} {
+		for _, name := range scope.Names() {
+			used[name] = true
+		}
+	}
+	return used
+}
+
+// freeName returns the first of name2, name3, and so on, not in used.
+func freeName(name string, used map[string]bool) string {
+	for i := 2; ; i++ {
+		if candidate := fmt.Sprintf("%s%d", name, i); !used[candidate] {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+// A siblingMethod is a method coupled to a renamed concrete method by the
+// interfaces that its type implements: either the method of one of these
+// interfaces, or the method of the same name of another type implementing
+// one of them.
+type siblingMethod struct {
+	qo    qualifiedObject
+	intfs []string // the names of the interfaces that couple it to the renamed method
+}
+
+// siblingMethods returns the methods coupled to the concrete method of qo
+// by the interfaces of the known packages that its receiver type
+// implements, declared at package level or within functions: the methods of these interfaces, and the methods of the
+// other named types implementing them, once per declaration.
+func siblingMethods(ctx context.Context, s Snapshot, qo qualifiedObject) (abstract, siblings []siblingMethod, _ error) {
+	fn, ok := qo.obj.(*types.Func)
+	if !ok {
+		return nil, nil, nil
+	}
+	sig := fn.Type().(*types.Signature)
+	if sig.Recv() == nil || IsInterface(sig.Recv().Type()) {
+		return nil, nil, nil
+	}
+	recvType := ensurePointer(Deref(sig.Recv().Type()))
+
+	knownPkgs, err := loadKnownPackages(ctx, s, qo.obj.Name())
+	if err != nil {
+		return nil, nil, err
+	}
+	pkgs := make(map[*types.Package]Package)
+	for _, pkg := range knownPkgs {
+		pkgs[pkg.GetTypes()] = pkg
+	}
+	seen := map[token.Position]bool{s.FileSet().Position(fn.Pos()): true}
+
+	type intf struct {
+		name  string
+		iface *types.Interface
+	}
+	var intfs []intf
+	seenIntfs := make(map[token.Position]bool)
+	for _, pkg := range knownPkgs {
+		for _, tname := range interfaceTypeNames(pkg) {
+			m, _, _ := types.LookupFieldOrMethod(tname.Type(), false, fn.Pkg(), fn.Name())
+			iface := tname.Type().Underlying().(*types.Interface)
+			if m == nil || !types.Implements(recvType, iface) {
+				continue
+			}
+			pos := s.FileSet().Position(tname.Pos())
+			if seenIntfs[pos] {
+				continue
+			}
+			seenIntfs[pos] = true
+			name := interfaceName(tname)
+			intfs = append(intfs, intf{name, iface})
+			if pos := s.FileSet().Position(m.Pos()); !seen[pos] {
+				seen[pos] = true
+				abstract = append(abstract, siblingMethod{qualifiedObject{obj: m, pkg: pkgs[m.Pkg()]}, []string{name}})
+			}
+		}
+	}
+	if len(intfs) == 0 {
+		return nil, nil, nil
+	}
+
+	for _, pkg := range knownPkgs {
+		for _, obj := range pkg.GetTypesInfo().Defs {
+			tname, ok := obj.(*types.TypeName)
+			if !ok || tname.IsAlias() || IsInterface(tname.Type()) {
+				continue
+			}
+			if _, ok := tname.Type().(*types.Named); !ok {
+				continue
+			}
+			T := ensurePointer(tname.Type())
+			var names []string
+			for _, i := range intfs {
+				if types.Implements(T, i.iface) {
+					names = append(names, i.name)
+				}
+			}
+			if len(names) == 0 {
+				continue
+			}
+			sel := types.NewMethodSet(T).Lookup(fn.Pkg(), fn.Name())
+			if sel == nil {
+				continue
+			}
+			m := sel.Obj()
+			if pos := s.FileSet().Position(m.Pos()); !seen[pos] {
+				seen[pos] = true
+				siblings = append(siblings, siblingMethod{qualifiedObject{obj: m, pkg: pkgs[m.Pkg()]}, names})
+			}
+		}
+	}
+	sort.Slice(siblings, func(i, j int) bool {
+		return implementationName(siblings[i].qo) < implementationName(siblings[j].qo)
+	})
+	return abstract, siblings, nil
+}
+
+// newEdits returns the edits of edits that are in none of olds, such as
+// the edits of a coupled method already renamed along with another one.
+func newEdits(edits map[span.URI][]protocol.TextEdit, olds ...map[span.URI][]protocol.TextEdit) map[span.URI][]protocol.TextEdit {
+	result := make(map[span.URI][]protocol.TextEdit)
+	for uri, e := range edits {
+	edits:
+		for _, te := range e {
+			for _, old := range olds {
+				for _, ote := range old[uri] {
+					if ote.Range == te.Range {
+						continue edits
+					}
+				}
+			}
+			result[uri] = append(result[uri], te)
+		}
+	}
+	return result
+}
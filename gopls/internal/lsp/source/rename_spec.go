@@ -0,0 +1,291 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/event"
+)
+
+// A renameSpec is a specifier of a renamed object, in the form of the -from
+// flag of gorename:
+//
+//	"encoding/json".Decoder.Decode        method of a package-level named type
+//	(*"encoding/json".Decoder).Decode     the same, in another syntax
+//	"encoding/json".Decoder.buf           field of a package-level named struct type
+//	"encoding/json".HTMLEscape            package member (const, func, var, type)
+//	"encoding/json".Decoder.Decode::x     local object x within a method
+//	"encoding/json".HTMLEscape::x         local object x within a function
+//	"encoding/json"::x                    object x anywhere within a package
+//	json.go::x                            object x within the file json.go
+//
+// The quotes of single-segment import paths, such as fmt, may be omitted.
+type renameSpec struct {
+	pkg        string // the import path of the package, unless filename is set
+	filename   string // the file, for the json.go::x form
+	pkgMember  string // e.g. "Decoder", in "encoding/json".Decoder.Decode
+	typeMember string // e.g. "Decode", in "encoding/json".Decoder.Decode
+	searchFor  string // the ::x suffix, if any
+}
+
+// parseRenameSpec parses from as a specifier of the -from flag of gorename.
+func parseRenameSpec(from string) (*renameSpec, error) {
+	var spec renameSpec
+	main := from // sans "::x" suffix
+	switch parts := strings.Split(from, "::"); len(parts) {
+	case 1:
+	case 2:
+		main, spec.searchFor = parts[0], parts[1]
+		if !isValidIdentifier(spec.searchFor) {
+			return nil, fmt.Errorf("-from %q: invalid identifier %q", from, spec.searchFor)
+		}
+	default:
+		return nil, fmt.Errorf("-from %q: invalid identifier specification", from)
+	}
+
+	if strings.HasSuffix(main, ".go") {
+		if spec.searchFor == "" {
+			return nil, fmt.Errorf("-from %q: filename %q must have a ::name suffix", from, main)
+		}
+		spec.filename = main
+		return &spec, nil
+	}
+
+	// main is one of:
+	//  "importpath"
+	//  "importpath".member
+	//  (*"importpath".type).fieldormethod  (parens and star optional)
+	e, _ := parser.ParseExpr(main)
+	if pkg := specImportPath(e); pkg != "" {
+		if spec.searchFor == "" {
+			return nil, fmt.Errorf("-from %q: package import path %q must have a ::name suffix", from, main)
+		}
+		spec.pkg = pkg
+		return &spec, nil
+	}
+	if sel, ok := e.(*ast.SelectorExpr); ok {
+		x := astutil.Unparen(sel.X)
+		if star, ok := x.(*ast.StarExpr); ok {
+			x = star.X
+		}
+		if pkg := specImportPath(x); pkg != "" {
+			// A package member, e.g. "encoding/json".HTMLEscape.
+			spec.pkg, spec.pkgMember = pkg, sel.Sel.Name
+			return &spec, nil
+		}
+		if x, ok := x.(*ast.SelectorExpr); ok {
+			// A field or method, e.g. ("encoding/json".Decoder).Decode.
+			if pkg := specImportPath(astutil.Unparen(x.X)); pkg != "" {
+				spec.pkg, spec.pkgMember, spec.typeMember = pkg, x.Sel.Name, sel.Sel.Name
+				return &spec, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("-from %q: invalid expression", from)
+}
+
+// specImportPath returns the import path denoted by e, a string literal or,
+// for single-segment import paths, an identifier, or "" if it denotes none.
+func specImportPath(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.BasicLit:
+		if e.Kind == token.STRING {
+			path, _ := strconv.Unquote(e.Value)
+			return path
+		}
+	}
+	return ""
+}
+
+// ResolveRenameSpec returns the location of the declaration of the object
+// denoted by from, a specifier of the -from flag of gorename, among the
+// workspace packages of s, and reports whether s has the package or the
+// file that from names. The filename of the json.go::x form must be
+// absolute.
+func ResolveRenameSpec(ctx context.Context, s Snapshot, from string) (protocol.Location, bool, error) {
+	ctx, done := event.Start(ctx, "source.ResolveRenameSpec")
+	defer done()
+
+	spec, err := parseRenameSpec(from)
+	if err != nil {
+		return protocol.Location{}, false, err
+	}
+	var pkgs []Package
+	if spec.filename != "" {
+		if pkgs, err = s.PackagesForFile(ctx, span.URIFromPath(spec.filename), TypecheckWorkspace, false); err != nil {
+			return protocol.Location{}, false, nil // not a file of s
+		}
+	} else {
+		active, err := s.ActivePackages(ctx)
+		if err != nil {
+			return protocol.Location{}, false, err
+		}
+		for _, pkg := range active {
+			if pkg.PkgPath() == spec.pkg {
+				pkgs = append(pkgs, pkg)
+			}
+		}
+	}
+	if len(pkgs) == 0 {
+		return protocol.Location{}, false, nil
+	}
+
+	// The variants of a package declare distinct objects at the same
+	// positions.
+	var (
+		objs    []qualifiedObject
+		findErr error
+	)
+	seen := make(map[token.Pos]bool)
+	for _, pkg := range pkgs {
+		found, err := spec.find(pkg)
+		if err != nil {
+			findErr = err
+			continue
+		}
+		for _, obj := range found {
+			if !seen[obj.Pos()] {
+				seen[obj.Pos()] = true
+				objs = append(objs, qualifiedObject{obj: obj, pkg: pkg})
+			}
+		}
+	}
+	switch len(objs) {
+	case 0:
+		return protocol.Location{}, true, findErr
+	case 1:
+	default:
+		sort.Slice(objs, func(i, j int) bool { return objs[i].obj.Pos() < objs[j].obj.Pos() })
+		var matches []string
+		for _, qo := range objs {
+			posn := s.FileSet().Position(qo.obj.Pos())
+			matches = append(matches, fmt.Sprintf("%s at %s:%d:%d", objectKind(qo.obj), filepath.Base(posn.Filename), posn.Line, posn.Column))
+		}
+		return protocol.Location{}, true, fmt.Errorf("ambiguous specifier %s matches %s", objs[0].obj.Name(), strings.Join(matches, ", "))
+	}
+	rng, err := renameMappedRange(s.FileSet(), objs[0].pkg, objs[0].obj.Pos(), objNameEnd(objs[0].obj))
+	if err != nil {
+		return protocol.Location{}, true, err
+	}
+	prng, err := rng.Range()
+	if err != nil {
+		return protocol.Location{}, true, err
+	}
+	return protocol.Location{URI: protocol.URIFromSpanURI(rng.URI()), Range: prng}, true, nil
+}
+
+// find returns the objects of pkg that spec denotes, at least one. It
+// fails if there are none, or if spec names a member of pkg that does not
+// exist, or that objects cannot be searched for within.
+func (spec *renameSpec) find(pkg Package) ([]types.Object, error) {
+	info := pkg.GetTypesInfo()
+	if spec.filename != "" {
+		pgf, err := pkg.File(span.URIFromPath(spec.filename))
+		if err != nil {
+			return nil, err
+		}
+		if objs := searchDefs(info, spec.searchFor, pgf.File.Pos(), pgf.File.End()); len(objs) > 0 {
+			return objs, nil
+		}
+		return nil, fmt.Errorf("no object %q declared in file %s", spec.searchFor, spec.filename)
+	}
+	if spec.pkgMember == "" {
+		if objs := searchDefs(info, spec.searchFor, token.NoPos, token.NoPos); len(objs) > 0 {
+			return objs, nil
+		}
+		return nil, fmt.Errorf("no object %q declared in package %q", spec.searchFor, pkg.PkgPath())
+	}
+
+	member := pkg.GetTypes().Scope().Lookup(spec.pkgMember)
+	if member == nil {
+		return nil, fmt.Errorf("package %q has no member %q", pkg.PkgPath(), spec.pkgMember)
+	}
+	var searchFunc *types.Func
+	if spec.typeMember == "" {
+		if spec.searchFor == "" {
+			return []types.Object{member}, nil
+		}
+		if searchFunc, _ = member.(*types.Func); searchFunc == nil {
+			return nil, fmt.Errorf("cannot search for %q within %s %q", spec.searchFor, objectKind(member), member.Name())
+		}
+	} else {
+		tname, _ := member.(*types.TypeName)
+		if tname == nil {
+			return nil, fmt.Errorf("%s.%s is a %s, not a type", pkg.PkgPath(), member.Name(), objectKind(member))
+		}
+		obj, _, _ := types.LookupFieldOrMethod(tname.Type(), true, pkg.GetTypes(), spec.typeMember)
+		if obj == nil {
+			return nil, fmt.Errorf("cannot find field or method %q of %s.%s", spec.typeMember, pkg.PkgPath(), tname.Name())
+		}
+		if spec.searchFor == "" {
+			// An embedded field denotes its type, as in gorename.
+			if v, ok := obj.(*types.Var); ok && v.Anonymous() {
+				t := v.Type()
+				if ptr, ok := t.(*types.Pointer); ok {
+					t = ptr.Elem()
+				}
+				if named, ok := t.(*types.Named); ok {
+					return []types.Object{named.Obj()}, nil
+				}
+			}
+			return []types.Object{obj}, nil
+		}
+		if searchFunc, _ = obj.(*types.Func); searchFunc == nil {
+			return nil, fmt.Errorf("cannot search for local name %q within %s (%s.%s).%s; need a function", spec.searchFor, objectKind(obj), pkg.PkgPath(), tname.Name(), obj.Name())
+		}
+		if IsInterface(tname.Type()) {
+			return nil, fmt.Errorf("cannot search for local name %q within abstract method (%s.%s).%s", spec.searchFor, pkg.PkgPath(), tname.Name(), searchFunc.Name())
+		}
+	}
+
+	for _, f := range pkg.GetSyntax() {
+		for _, decl := range f.Decls {
+			if decl, ok := decl.(*ast.FuncDecl); ok && info.Defs[decl.Name] == searchFunc {
+				var objs []types.Object
+				for _, obj := range searchDefs(info, spec.searchFor, decl.Pos(), decl.End()) {
+					if obj != searchFunc {
+						objs = append(objs, obj)
+					}
+				}
+				if len(objs) == 0 {
+					return nil, fmt.Errorf("no local definition of %q within %s", spec.searchFor, searchFunc.Name())
+				}
+				return objs, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("cannot find the declaration of %s", searchFunc.Name())
+}
+
+// searchDefs returns the objects named name that info defines, within the
+// range from start to end, unless they are token.NoPos.
+func searchDefs(info *types.Info, name string, start, end token.Pos) []types.Object {
+	var objs []types.Object
+	for id, obj := range info.Defs {
+		if obj == nil || id.Name != name {
+			continue
+		}
+		if start.IsValid() && (obj.Pos() < start || obj.Pos() >= end) {
+			continue
+		}
+		objs = append(objs, obj)
+	}
+	return objs
+}
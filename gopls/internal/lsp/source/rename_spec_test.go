@@ -0,0 +1,42 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import "testing"
+
+func TestParseRenameSpec(t *testing.T) {
+	for _, tt := range []struct {
+		from string
+		want renameSpec // the zero value if from is invalid
+	}{
+		{`"encoding/json".Decoder.Decode`, renameSpec{pkg: "encoding/json", pkgMember: "Decoder", typeMember: "Decode"}},
+		{`(*"encoding/json".Decoder).Decode`, renameSpec{pkg: "encoding/json", pkgMember: "Decoder", typeMember: "Decode"}},
+		{`"encoding/json".HTMLEscape`, renameSpec{pkg: "encoding/json", pkgMember: "HTMLEscape"}},
+		{`"encoding/json".Decoder.Decode::x`, renameSpec{pkg: "encoding/json", pkgMember: "Decoder", typeMember: "Decode", searchFor: "x"}},
+		{`"encoding/json"::x`, renameSpec{pkg: "encoding/json", searchFor: "x"}},
+		{`fmt.Println`, renameSpec{pkg: "fmt", pkgMember: "Println"}},
+		{`json.go::x`, renameSpec{filename: "json.go", searchFor: "x"}},
+		{`json.go`, renameSpec{}},
+		{`"encoding/json"`, renameSpec{}},
+		{`"encoding/json"::x::y`, renameSpec{}},
+		{`"encoding/json"::1x`, renameSpec{}},
+		{`f()`, renameSpec{}},
+	} {
+		spec, err := parseRenameSpec(tt.from)
+		if tt.want == (renameSpec{}) {
+			if err == nil {
+				t.Errorf("parseRenameSpec(%s) = %+v, want error", tt.from, *spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseRenameSpec(%s) failed: %v", tt.from, err)
+			continue
+		}
+		if *spec != tt.want {
+			t.Errorf("parseRenameSpec(%s) = %+v, want %+v", tt.from, *spec, tt.want)
+		}
+	}
+}
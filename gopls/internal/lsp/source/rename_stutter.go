@@ -0,0 +1,155 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/event"
+)
+
+// A StutterRename is the renaming of an exported name of a package that
+// stutters with the name of the package, such as user.UserID, to the name
+// without the prefix, such as user.ID.
+type StutterRename struct {
+	OldName, NewName string
+	Location         protocol.Location // the declaration of the name
+	Err              error             // if non-nil, why the rename is left out of the edits
+}
+
+// stutterAnnotationID returns the identifier of the change annotation of
+// the edits of the renaming of the stuttering name oldName.
+func stutterAnnotationID(oldName string) protocol.ChangeAnnotationIdentifier {
+	return "stutter/" + oldName
+}
+
+// FixStutteringNames computes, as a batch, the renames of the exported
+// package-level names of the package of file f that stutter with its name,
+// in the order of the names, and returns their combined edits. The edits of
+// each rename carry a change annotation of their own, of identifier
+// "stutter/<old name>".
+//
+// A rename is left out of the edits, with the reason, if another rename has
+// the same new name, if its new name is that of another stuttering name, if
+// its edits overlap those of a previous rename, or if the rename engine
+// refuses it, for example as it would conflict with another name.
+func FixStutteringNames(ctx context.Context, s Snapshot, f FileHandle) (map[span.URI][]protocol.TextEdit, map[protocol.ChangeAnnotationIdentifier]protocol.ChangeAnnotation, []StutterRename, error) {
+	ctx, done := event.Start(ctx, "source.FixStutteringNames")
+	defer done()
+
+	pkg, _, err := GetParsedFile(ctx, s, f, NarrowestPackage)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	pkgName := pkg.GetTypes().Name()
+	if pkgName == "main" {
+		return nil, nil, nil, nil // its names are not qualified
+	}
+	scope := pkg.GetTypes().Scope()
+	var renames []StutterRename
+	stuttering := make(map[string]bool)
+	newNames := make(map[string]int)
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		newName, ok := destutter(pkgName, name)
+		if !ok || !obj.Exported() {
+			continue
+		}
+		rng, err := renameMappedRange(s.FileSet(), pkg, obj.Pos(), objNameEnd(obj))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		pr, err := rng.Range()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		renames = append(renames, StutterRename{
+			OldName:  name,
+			NewName:  newName,
+			Location: protocol.Location{URI: protocol.URIFromSpanURI(rng.URI()), Range: pr},
+		})
+		stuttering[name] = true
+		newNames[newName]++
+	}
+
+	edits := make(map[span.URI][]protocol.TextEdit)
+	annotations := make(map[protocol.ChangeAnnotationIdentifier]protocol.ChangeAnnotation)
+	owners := make(map[span.URI][]string) // the old names of the renames of edits
+	for i := range renames {
+		r := &renames[i]
+		if newNames[r.NewName] > 1 {
+			r.Err = fmt.Errorf("%s is the new name of another stuttering name", r.NewName)
+			continue
+		}
+		if stuttering[r.NewName] {
+			r.Err = fmt.Errorf("%s is another stuttering name", r.NewName)
+			continue
+		}
+		fh, err := s.GetFile(ctx, r.Location.URI.SpanURI())
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		renameEdits, _, _, err := rename(ctx, s, fh, r.Location.Range.Start, r.NewName, false, false)
+		if err != nil {
+			r.Err = err
+			continue
+		}
+		if other := overlappingRename(edits, owners, renameEdits); other != "" {
+			r.Err = fmt.Errorf("its edits overlap those of the renaming of %s", other)
+			continue
+		}
+		id := stutterAnnotationID(r.OldName)
+		annotations[id] = protocol.ChangeAnnotation{
+			Label:       fmt.Sprintf("Rename %s to %s", r.OldName, r.NewName),
+			Description: fmt.Sprintf("%s.%s stutters with the package name", pkgName, r.OldName),
+		}
+		for uri, e := range renameEdits {
+			for _, te := range e {
+				te.AnnotationID = id
+				edits[uri] = append(edits[uri], te)
+				owners[uri] = append(owners[uri], r.OldName)
+			}
+		}
+	}
+	return edits, annotations, renames, nil
+}
+
+// overlappingRename returns the old name of the rename whose edit, among
+// edits, overlaps one of renameEdits, or "" if there is none. owners holds
+// the old names of the renames of edits.
+func overlappingRename(edits map[span.URI][]protocol.TextEdit, owners map[span.URI][]string, renameEdits map[span.URI][]protocol.TextEdit) string {
+	for uri, e := range renameEdits {
+		for _, te := range e {
+			for i, other := range edits[uri] {
+				if protocol.ComparePosition(te.Range.Start, other.Range.End) < 0 &&
+					protocol.ComparePosition(other.Range.Start, te.Range.End) < 0 ||
+					protocol.CompareRange(te.Range, other.Range) == 0 {
+					return owners[uri][i]
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// destutter returns name without the prefix that stutters with the package
+// name pkgName, such as ID for UserID in package user, and whether name
+// stutters: whether the rest of it starts with an upper case letter.
+func destutter(pkgName, name string) (string, bool) {
+	if len(name) <= len(pkgName) || !strings.EqualFold(name[:len(pkgName)], pkgName) {
+		return "", false
+	}
+	rest := name[len(pkgName):]
+	if r, _ := utf8.DecodeRuneInString(rest); !unicode.IsUpper(r) {
+		return "", false
+	}
+	return rest, true
+}
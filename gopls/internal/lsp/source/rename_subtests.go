@@ -0,0 +1,133 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"go/ast"
+	"go/types"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/types/typeutil"
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+// subtestRunners identifies the methods running subtests and
+// sub-benchmarks.
+var subtestRunners = []callMatcher{
+	{"testing", "T", "Run"},
+	{"testing", "B", "Run"},
+}
+
+// subtestNameEdits returns the edits renaming the old name of m, that of
+// the renamed object, in the names of the subtests run by the test files of
+// its package, those of its directory dir, which declPkgs, the variants of
+// the declaring package, and their reverse dependencies compile: within
+// the string literals passed to t.Run, or stored in the field of a test
+// table passed to it, as in
+//
+//	for _, tt := range []struct{ name string }{{"Foo"}, {name: "Foo twice"}} {
+//		t.Run(tt.name, ...)
+//	}
+//
+// the matches of m. Files shared by several packages are visited once.
+func subtestNameEdits(ctx context.Context, s Snapshot, dir string, declPkgs []Package, m *nameMatcher) (map[span.URI][]protocol.TextEdit, error) {
+	pkgs := append([]Package(nil), declPkgs...)
+	for _, pkg := range declPkgs {
+		rdeps, err := loadReverseDependencies(ctx, s, m.oldName, pkg.ID())
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, rdeps...) // x_test packages import the test variants
+	}
+
+	edits := make(map[span.URI][]protocol.TextEdit)
+	seen := make(map[span.URI]bool)
+	for _, pkg := range pkgs {
+		info := pkg.GetTypesInfo()
+		for _, pgf := range pkg.CompiledGoFiles() {
+			filename := pgf.URI.Filename()
+			if seen[pgf.URI] || !strings.HasSuffix(filename, "_test.go") || filepath.Dir(filename) != dir {
+				continue
+			}
+			seen[pgf.URI] = true
+			var lits []*ast.BasicLit
+			fields := make(map[*types.Var]bool) // fields of test tables naming subtests
+			ast.Inspect(pgf.File, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok || len(call.Args) == 0 {
+					return true
+				}
+				fn, ok := typeutil.Callee(info, call).(*types.Func)
+				if !ok {
+					return true
+				}
+				for _, m := range subtestRunners {
+					if !m.matches(fn) {
+						continue
+					}
+					lits = append(lits, messageLiterals(call.Args[0])...)
+					if sel, ok := call.Args[0].(*ast.SelectorExpr); ok {
+						if field, ok := info.Uses[sel.Sel].(*types.Var); ok && field.IsField() {
+							fields[field] = true
+						}
+					}
+					break
+				}
+				return true
+			})
+			if len(fields) > 0 {
+				lits = append(lits, tableLiterals(info, pgf.File, fields)...)
+			}
+			for _, lit := range lits {
+				if err := addLiteralMatches(edits, pgf, lit, m); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return edits, nil
+}
+
+// tableLiterals returns the string literals given to one of fields by the
+// struct literals of f, keyed or not.
+func tableLiterals(info *types.Info, f *ast.File, fields map[*types.Var]bool) []*ast.BasicLit {
+	var lits []*ast.BasicLit
+	ast.Inspect(f, func(n ast.Node) bool {
+		lit, ok := n.(*ast.CompositeLit)
+		if !ok {
+			return true
+		}
+		t := info.TypeOf(lit)
+		if t == nil {
+			return true
+		}
+		st, ok := Deref(t).Underlying().(*types.Struct)
+		if !ok {
+			return true
+		}
+		for i, elt := range lit.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				if id, ok := kv.Key.(*ast.Ident); ok && fields[asField(info.Uses[id])] {
+					lits = append(lits, messageLiterals(kv.Value)...)
+				}
+			} else if i < st.NumFields() && fields[st.Field(i)] {
+				lits = append(lits, messageLiterals(elt)...)
+			}
+		}
+		return true
+	})
+	return lits
+}
+
+// asField returns obj if it is a field, and nil otherwise.
+func asField(obj types.Object) *types.Var {
+	if v, ok := obj.(*types.Var); ok && v.IsField() {
+		return v
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/scanner"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/safetoken"
+	"golang.org/x/tools/internal/diff"
+)
+
+// isRepairedSource reports whether src is the source of the file fh as
+// the parser repaired it to recover from its syntax errors, in which case
+// the offsets of the analysis of the file are those of src rather than of
+// its content.
+func isRepairedSource(ctx context.Context, s Snapshot, fh FileHandle, src []byte) bool {
+	pgf, err := s.ParseGo(ctx, fh, ParseFull)
+	return err == nil && pgf.ParseErr != nil && bytes.Equal(pgf.Src, src)
+}
+
+// fromRepairedSource maps edits, at offsets of src, the repaired source of
+// a file, to the offsets of its content. It fails if an edit changes text
+// that the repair introduced.
+func fromRepairedSource(src, content []byte, edits []diff.Edit) ([]diff.Edit, error) {
+	repairs := diff.Bytes(content, src)
+	result := make([]diff.Edit, len(edits))
+	for i, edit := range edits {
+		start, ok := contentOffset(repairs, edit.Start)
+		if !ok {
+			return nil, fmt.Errorf("cannot rename text that was repaired to parse the file")
+		}
+		end, ok := contentOffset(repairs, edit.End)
+		if !ok {
+			return nil, fmt.Errorf("cannot rename text that was repaired to parse the file")
+		}
+		result[i] = diff.Edit{Start: start, End: end, New: edit.New}
+	}
+	return result, nil
+}
+
+// contentOffset returns the offset in the content of a file of offset, an
+// offset of its source once changed by repairs, the sorted edits of its
+// content. It reports false if offset lies within text that they insert.
+func contentOffset(repairs []diff.Edit, offset int) (int, bool) {
+	delta := 0
+	for _, r := range repairs {
+		start := r.Start + delta
+		if offset <= start {
+			break
+		}
+		if end := start + len(r.New); offset < end {
+			return 0, false
+		}
+		delta += len(r.New) - (r.End - r.Start)
+	}
+	return offset - delta, true
+}
+
+// recoverSyntax returns the snapshot derived from s by
+// RecoverSwallowedDecls, in which to rename so that the syntax errors of
+// the open files hide as little of them as possible, the file of f in it,
+// and a function to release it.
+func recoverSyntax(ctx context.Context, s Snapshot, f FileHandle) (Snapshot, FileHandle, func(), error) {
+	rs, release, err := s.RecoverSwallowedDecls(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	rf, err := rs.GetFile(ctx, f.URI())
+	if err != nil {
+		release()
+		return nil, nil, nil, err
+	}
+	return rs, rf, release, nil
+}
+
+// checkSyntax returns an error if the position pp of the file fh, with
+// the syntax errors errs, lies within a region of it that they prevent
+// from analyzing in its recovered version, parsed as pgf: the parts of it
+// that could not be parsed, or that were discarded so that the
+// declarations following them could be. The error describes the region
+// and the syntax errors within it.
+func checkSyntax(errs scanner.ErrorList, pgf *ParsedGoFile, fh FileHandle, pp protocol.Position) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	content, err := fh.Read()
+	if err != nil {
+		return err
+	}
+	m := protocol.NewColumnMapper(pgf.URI, content)
+	offset, err := m.Offset(pp)
+	if err != nil {
+		return err
+	}
+	for _, region := range brokenRegions(pgf, content) {
+		if offset < region[0] || region[1] <= offset {
+			continue
+		}
+		start, err := m.OffsetPosition(region[0])
+		if err != nil {
+			return err
+		}
+		end, err := m.OffsetPosition(region[1])
+		if err != nil {
+			return err
+		}
+		var msgs []string
+		for _, e := range errs {
+			if region[0] <= e.Pos.Offset && e.Pos.Offset <= region[1] {
+				msgs = append(msgs, fmt.Sprintf("%d:%d: %s", e.Pos.Line, e.Pos.Column, e.Msg))
+			}
+		}
+		if len(msgs) == 0 {
+			msgs = append(msgs, errs[0].Error())
+		}
+		return fmt.Errorf("cannot rename within %s:%d:%d-%d:%d, which has syntax errors: %s",
+			filepath.Base(pgf.URI.Filename()), start.Line+1, start.Character+1, end.Line+1, end.Character+1, strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// brokenRegions returns the sorted regions of content, the content of the
+// file of pgf, that the parser could not parse or discarded: the bad
+// nodes of its syntax tree, and the text that its repair changed. The
+// regions are byte offset intervals of content.
+func brokenRegions(pgf *ParsedGoFile, content []byte) [][2]int {
+	repairs := diff.Bytes(content, pgf.Src)
+	var regions [][2]int
+	add := func(start, end int) {
+		// Regions separated only by space, as the lines of a discarded
+		// declaration are, form one region.
+		if n := len(regions); n > 0 {
+			last := &regions[n-1]
+			if start <= last[1] || len(bytes.TrimFunc(content[last[1]:start], unicode.IsSpace)) == 0 {
+				if end > last[1] {
+					last[1] = end
+				}
+				return
+			}
+		}
+		regions = append(regions, [2]int{start, end})
+	}
+	var bad [][2]int
+	ast.Inspect(pgf.File, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.BadExpr, *ast.BadStmt, *ast.BadDecl:
+			start, err := safetoken.Offset(pgf.Tok, n.Pos())
+			if err != nil {
+				return false
+			}
+			end, err := safetoken.Offset(pgf.Tok, n.End())
+			if err != nil {
+				return false
+			}
+			start, ok1 := contentOffset(repairs, start)
+			end, ok2 := contentOffset(repairs, end)
+			if ok1 && ok2 && start < end {
+				bad = append(bad, [2]int{start, end})
+			}
+			return false
+		}
+		return true
+	})
+	for _, r := range repairs {
+		for len(bad) > 0 && bad[0][0] < r.Start {
+			add(bad[0][0], bad[0][1])
+			bad = bad[1:]
+		}
+		if r.Start < r.End {
+			add(r.Start, r.End)
+		}
+	}
+	for _, b := range bad {
+		add(b[0], b[1])
+	}
+	for i, r := range regions {
+		text := content[r[0]:r[1]]
+		start := len(text) - len(bytes.TrimLeftFunc(text, unicode.IsSpace))
+		end := len(bytes.TrimRightFunc(text, unicode.IsSpace))
+		if start < end {
+			regions[i] = [2]int{r[0] + start, r[0] + end}
+		}
+	}
+	return regions
+}
+
+// satisfiableFiles returns the files of pkg that satisfy.Finder can
+// analyze: all of them if pkg has no errors, or else, if its errors are
+// only the syntax errors of some files and the type errors within these,
+// the files without type errors. It reports false if pkg has other errors.
+func satisfiableFiles(pkg Package) ([]*ast.File, bool) {
+	if !pkg.HasListOrParseErrors() && !pkg.HasTypeErrors() {
+		return pkg.GetSyntax(), true
+	}
+	broken := make(map[*token.File]bool)
+	for _, pgf := range pkg.CompiledGoFiles() {
+		if pgf.ParseErr != nil {
+			broken[pgf.Tok] = true
+		}
+	}
+	if len(broken) == 0 {
+		return nil, false // errors of go list
+	}
+	untyped := make(map[*token.File]bool)
+	for _, e := range pkg.GetTypeErrors() {
+		tf := e.Fset.File(e.Pos)
+		if !broken[tf] {
+			return nil, false
+		}
+		untyped[tf] = true
+	}
+	var files []*ast.File
+	for _, pgf := range pkg.CompiledGoFiles() {
+		if !untyped[pgf.Tok] {
+			files = append(files, pgf.File)
+		}
+	}
+	return files, true
+}
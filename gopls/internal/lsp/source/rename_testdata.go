@@ -0,0 +1,193 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+// A testdataRename describes a rename to the Go files under the testdata
+// directories of the workspace, such as the packages that the tests of
+// analyzers check with the analysistest framework. The go command ignores
+// these directories, so gopls does not load their files as packages: they
+// are only parsed, and their references to renamed objects found by name.
+type testdataRename struct {
+	oldPath, newPath     string // the import path of the package, whose nested packages follow it
+	oldName, newName     string // the name of the package, which its imports declare implicitly
+	oldMember, newMember string // the renamed package-level object, if any
+}
+
+// edits returns the edits updating the Go files under the testdata
+// directories of the workspace folder of s: their imports of the package
+// and of the packages nested in it, and their references to its members
+// through these imports. The files of skip, which the rename edits as
+// they are loaded as packages nonetheless, such as open ones, are left out.
+func (r testdataRename) edits(ctx context.Context, s Snapshot, skip map[span.URI][]protocol.TextEdit) (map[span.URI][]protocol.TextEdit, error) {
+	edits := make(map[span.URI][]protocol.TextEdit)
+	root := s.View().Folder().Filename()
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // skip unreadable files and directories
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "vendor" || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".go") || !inTestdata(root, path) {
+			return nil
+		}
+		uri := span.URIFromPath(path)
+		if _, ok := skip[uri]; ok {
+			return nil
+		}
+		content, err := fileContent(s, uri)
+		if err != nil || !bytes.Contains(content, []byte(r.oldPath)) {
+			return nil // unreadable, or not importing the package
+		}
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, path, content, 0)
+		if err != nil {
+			return nil // unparsable, as test inputs may be
+		}
+		fileEdits, err := r.fileEdits(fset.File(file.Pos()), file, protocol.NewColumnMapper(uri, content))
+		if err != nil {
+			return err
+		}
+		if len(fileEdits) > 0 {
+			edits[uri] = fileEdits
+		}
+		return nil
+	})
+	return edits, err
+}
+
+// inTestdata reports whether the file path lies in a testdata directory
+// within root.
+func inTestdata(root, path string) bool {
+	rel, ok := relPath(root, path)
+	if !ok {
+		return false
+	}
+	for _, dir := range strings.Split(filepath.Dir(rel), string(filepath.Separator)) {
+		if dir == "testdata" {
+			return true
+		}
+	}
+	return false
+}
+
+// fileEdits returns the edits of r in the file f, of the token file tok
+// and the mapper m.
+func (r testdataRename) fileEdits(tok *token.File, f *ast.File, m *protocol.ColumnMapper) ([]protocol.TextEdit, error) {
+	var edits []protocol.TextEdit
+	replace := func(n ast.Node, text string) error {
+		rng, err := m.OffsetRange(tok.Offset(n.Pos()), tok.Offset(n.End()))
+		if err != nil {
+			return err
+		}
+		edits = append(edits, protocol.TextEdit{Range: rng, NewText: text})
+		return nil
+	}
+
+	// qualifier is the name under which the file imports the package, if
+	// it refers to its members. Only the implicit names of imports change
+	// with the package name.
+	var (
+		qualifier string
+		implicit  bool
+	)
+	for _, imp := range f.Imports {
+		impPath, err := strconv.Unquote(imp.Path.Value)
+		if err != nil || (impPath != r.oldPath && !strings.HasPrefix(impPath, r.oldPath+"/")) {
+			continue
+		}
+		if r.newPath != r.oldPath {
+			if err := replace(imp.Path, strconv.Quote(r.newPath+strings.TrimPrefix(impPath, r.oldPath))); err != nil {
+				return nil, err
+			}
+		}
+		if impPath != r.oldPath {
+			continue
+		}
+		switch {
+		case imp.Name == nil:
+			qualifier, implicit = r.oldName, true
+		case imp.Name.Name != "_" && imp.Name.Name != ".":
+			qualifier = imp.Name.Name
+		}
+	}
+	if qualifier == "" {
+		return edits, nil
+	}
+
+	newQualifier := qualifier
+	if implicit {
+		newQualifier = r.newName
+	}
+	var err error
+	ast.Inspect(f, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok || err != nil {
+			return err == nil
+		}
+		// The parser resolves the identifiers declared in the file, which
+		// shadow the import, but not the names of imports.
+		x, ok := sel.X.(*ast.Ident)
+		if !ok || x.Name != qualifier || x.Obj != nil {
+			return true
+		}
+		if newQualifier != qualifier {
+			if err = replace(x, newQualifier); err != nil {
+				return false
+			}
+		}
+		if r.oldMember != "" && sel.Sel.Name == r.oldMember {
+			err = replace(sel.Sel, r.newMember)
+		}
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return edits, nil
+}
+
+// addTestdataEdits records the edits of r, under an annotation of their
+// own, except in the files of result, the required edits of the rename.
+func (o *OptionalEdits) addTestdataEdits(ctx context.Context, s Snapshot, r testdataRename, result map[span.URI][]protocol.TextEdit) error {
+	edits, err := r.edits(ctx, s, result)
+	if err != nil {
+		return err
+	}
+	var files []string
+	for uri := range edits {
+		files = append(files, filepath.Base(uri.Filename()))
+	}
+	sort.Strings(files)
+	o.addAnnotatedEdits(TestdataGroup, "", protocol.ChangeAnnotation{
+		Label:       "Update testdata files",
+		Description: fmt.Sprintf("%d edits in the Go files under testdata directories: %s", countEdits(edits), strings.Join(files, ", ")),
+	}, edits)
+	return nil
+}
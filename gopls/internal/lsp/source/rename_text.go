@@ -0,0 +1,272 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/types"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/diff"
+)
+
+// A textReplacement replaces a qualified name or import path affected by a
+// rename in the text of non-Go files.
+type textReplacement struct {
+	old, new string
+}
+
+// A textOccurrence is an occurrence of the old text of a replacement in a
+// non-Go file.
+type textOccurrence struct {
+	uri        span.URI
+	mapper     *protocol.ColumnMapper
+	start, end int // byte offsets
+	new        string
+	editable   bool // whether the occurrence may be replaced
+}
+
+// editableTextExts holds the extensions of the non-Go files in which
+// occurrences are offered as edits: documents, configuration and scripts,
+// where they are likely to refer to Go code. Occurrences in other text
+// files are only reported.
+var editableTextExts = map[string]bool{
+	".md":       true,
+	".markdown": true,
+	".txt":      true,
+	".yaml":     true,
+	".yml":      true,
+	".sh":       true,
+	".bash":     true,
+}
+
+// reportedTextExts holds the extensions of the other non-Go files that are
+// searched for occurrences.
+var reportedTextExts = map[string]bool{
+	".json":  true,
+	".toml":  true,
+	".proto": true,
+	".html":  true,
+	".tmpl":  true,
+	".mk":    true,
+}
+
+// maxTextFileSize bounds the size of the non-Go files that are searched.
+const maxTextFileSize = 1 << 20
+
+// TextOccurrences returns the locations of the occurrences, in non-Go files
+// of the workspace, of the qualified name or import path of the object or
+// package at position pp, whether or not a rename would edit them.
+func TextOccurrences(ctx context.Context, s Snapshot, f FileHandle, pp protocol.Position) ([]protocol.Location, error) {
+	reps, err := renameTextReplacements(ctx, s, f, pp, "")
+	if err != nil {
+		return nil, err
+	}
+	occs, err := nonGoOccurrences(ctx, s, reps)
+	if err != nil {
+		return nil, err
+	}
+	var locs []protocol.Location
+	for _, occ := range occs {
+		rng, err := occ.mapper.OffsetRange(occ.start, occ.end)
+		if err != nil {
+			return nil, err
+		}
+		locs = append(locs, protocol.Location{URI: protocol.URIFromSpanURI(occ.uri), Range: rng})
+	}
+	return locs, nil
+}
+
+// renameTextReplacements returns the textual replacements accompanying the
+// renaming of the object or package at position pp to newName.
+func renameTextReplacements(ctx context.Context, s Snapshot, f FileHandle, pp protocol.Position, newName string) ([]textReplacement, error) {
+	pgf, err := s.ParseGo(ctx, f, ParseFull)
+	if err != nil {
+		return nil, err
+	}
+	inPackageName, err := isInPackageName(ctx, s, f, pgf, pp)
+	if err != nil {
+		return nil, err
+	}
+	if inPackageName {
+		fileMeta, err := s.MetadataForFile(ctx, f.URI())
+		if err != nil {
+			return nil, err
+		}
+		if len(fileMeta) == 0 {
+			return nil, fmt.Errorf("no packages found for file %q", f.URI())
+		}
+		return []textReplacement{packageTextReplacement(string(fileMeta[0].PackagePath()), newName)}, nil
+	}
+	qos, err := qualifiedObjsAtProtocolPos(ctx, s, f.URI(), pp)
+	if err != nil {
+		return nil, err
+	}
+	return objectTextReplacements(qos[0].obj, newName), nil
+}
+
+// packageTextReplacement returns the replacement of the import path of a
+// package renamed to newName.
+func packageTextReplacement(oldPath, newName string) textReplacement {
+	return textReplacement{old: oldPath, new: path.Join(path.Dir(oldPath), newName)}
+}
+
+// objectTextReplacements returns the replacements of the qualified names
+// of obj renamed to newName: pkg.Name for package-level objects, and T.Name
+// for the fields and methods of named types. Other objects are not referred
+// to outside Go code.
+func objectTextReplacements(obj types.Object, newName string) []textReplacement {
+	if obj.Pkg() == nil {
+		return nil
+	}
+	var qualifiers []string
+	if obj.Parent() == obj.Pkg().Scope() {
+		qualifiers = append(qualifiers, obj.Pkg().Name())
+		if path := obj.Pkg().Path(); !strings.HasSuffix(path, "/"+obj.Pkg().Name()) && path != obj.Pkg().Name() {
+			qualifiers = append(qualifiers, path)
+		}
+	} else {
+		var recv types.Type
+		switch obj := obj.(type) {
+		case *types.Func:
+			if sig, ok := obj.Type().(*types.Signature); ok && sig.Recv() != nil {
+				recv = sig.Recv().Type()
+			}
+		case *types.Var:
+			if obj.IsField() {
+				if name := declaringStructName(obj); name != "" {
+					qualifiers = append(qualifiers, name)
+				}
+			}
+		}
+		if ptr, ok := recv.(*types.Pointer); ok {
+			recv = ptr.Elem()
+		}
+		if named, ok := recv.(*types.Named); ok {
+			qualifiers = append(qualifiers, named.Obj().Name())
+		}
+	}
+	var reps []textReplacement
+	for _, q := range qualifiers {
+		reps = append(reps, textReplacement{old: q + "." + obj.Name(), new: q + "." + newName})
+	}
+	return reps
+}
+
+// nonGoOccurrences returns the occurrences of the old text of reps in the
+// non-Go files of the workspace. An occurrence must not be part of a longer
+// identifier or qualified name: "a.Foo" does not occur in "data.Foo" or
+// "a.Foobar", but does in "example.com/a.Foo".
+func nonGoOccurrences(ctx context.Context, s Snapshot, reps []textReplacement) ([]textOccurrence, error) {
+	if len(reps) == 0 {
+		return nil, nil
+	}
+	var occs []textOccurrence
+	root := s.View().Folder().Filename()
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // skip unreadable files and directories
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "vendor" || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := filepath.Ext(name)
+		editable := editableTextExts[ext]
+		if !editable && !reportedTextExts[ext] && name != "Makefile" && name != "Dockerfile" {
+			return nil
+		}
+		if info, err := d.Info(); err != nil || info.Size() > maxTextFileSize {
+			return nil
+		}
+		uri := span.URIFromPath(path)
+		fh, err := s.GetFile(ctx, uri)
+		if err != nil {
+			return err
+		}
+		content, err := fh.Read()
+		if err != nil || bytes.IndexByte(content, 0) >= 0 {
+			return nil // unreadable or binary
+		}
+		var mapper *protocol.ColumnMapper
+		text := string(content)
+		for _, rep := range reps {
+			for i := 0; ; {
+				j := strings.Index(text[i:], rep.old)
+				if j < 0 {
+					break
+				}
+				start, end := i+j, i+j+len(rep.old)
+				i = end
+				if before, _ := utf8.DecodeLastRuneInString(text[:start]); isIdentRune(before) || before == '.' {
+					continue
+				}
+				if after, _ := utf8.DecodeRuneInString(text[end:]); isIdentRune(after) {
+					continue
+				}
+				if mapper == nil {
+					mapper = protocol.NewColumnMapper(uri, content)
+				}
+				occs = append(occs, textOccurrence{
+					uri:      uri,
+					mapper:   mapper,
+					start:    start,
+					end:      end,
+					new:      rep.new,
+					editable: editable,
+				})
+			}
+		}
+		return nil
+	})
+	return occs, err
+}
+
+// addTextOccurrences records the replacement of the editable occurrences
+// occs, under an annotation of their own.
+func (o *OptionalEdits) addTextOccurrences(occs []textOccurrence) error {
+	edits := make(map[span.URI][]diff.Edit)
+	mappers := make(map[span.URI]*protocol.ColumnMapper)
+	var files []string
+	for _, occ := range occs {
+		if !occ.editable {
+			continue
+		}
+		if _, ok := edits[occ.uri]; !ok {
+			files = append(files, filepath.Base(occ.uri.Filename()))
+		}
+		edits[occ.uri] = append(edits[occ.uri], diff.Edit{Start: occ.start, End: occ.end, New: occ.new})
+		mappers[occ.uri] = occ.mapper
+	}
+	if len(edits) == 0 {
+		return nil
+	}
+	protocolEdits := make(map[span.URI][]protocol.TextEdit)
+	for uri, e := range edits {
+		var err error
+		if protocolEdits[uri], err = ToProtocolEdits(mappers[uri], e); err != nil {
+			return err
+		}
+	}
+	o.addAnnotatedEdits(TextFilesGroup, "", protocol.ChangeAnnotation{
+		Label:       "Rename in non-Go files",
+		Description: strings.Join(files, ", "),
+	}, protocolEdits)
+	return nil
+}
@@ -0,0 +1,221 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/types/objectpath"
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/event"
+)
+
+// checkVendoredEdits returns an error listing the files of edits that lie
+// in the vendor directories of the workspace modules of s, which go mod
+// vendor overwrites: renaming within vendored packages is refused rather
+// than left inconsistent with their modules.
+func checkVendoredEdits(s Snapshot, edits map[span.URI][]protocol.TextEdit) error {
+	var vendored []string
+	for uri := range edits {
+		if rel, ok := workspaceRelPath(s, uri.Filename()); ok && isVendorPath(rel) {
+			vendored = append(vendored, filepath.ToSlash(rel))
+		}
+	}
+	if len(vendored) == 0 {
+		return nil
+	}
+	sort.Strings(vendored)
+	return fmt.Errorf("renaming would edit vendored files, which go mod vendor overwrites: %s; rename in the vendored module instead", strings.Join(vendored, ", "))
+}
+
+// RenameVendoredCopies adds to optional, allocated if nil, the edits that
+// rename the copies of the exported object at position pp of f vendored in
+// the modules of others, the snapshots of the other views, along with the
+// references to these copies there, so that the vendored copies of a
+// renamed module can be kept consistent with it. The edits of each copy are
+// recorded under an annotation of the vendor group. RenameVendoredCopies
+// returns the optional edits and the files of the other views that they
+// change.
+func RenameVendoredCopies(ctx context.Context, s Snapshot, f FileHandle, pp protocol.Position, newName string, others []Snapshot, optional *OptionalEdits) (*OptionalEdits, []span.URI, error) {
+	ctx, done := event.Start(ctx, "source.RenameVendoredCopies")
+	defer done()
+
+	pgf, err := s.ParseGo(ctx, f, ParseFull)
+	if err != nil {
+		return nil, nil, err
+	}
+	uri := f.URI()
+	mention, err := findCommentMention(ctx, s, pgf, pp)
+	if err != nil {
+		return nil, nil, err
+	}
+	if mention != nil {
+		uri, pp = mention.uri, mention.pos
+	}
+	qos, err := qualifiedObjsAtProtocolPos(ctx, s, uri, pp)
+	if err != nil {
+		return nil, nil, err
+	}
+	obj := qos[0].obj
+	if obj.Pkg() == nil || !obj.Exported() {
+		return optional, nil, nil
+	}
+	path, err := objectpath.For(obj)
+	if err != nil {
+		return optional, nil, nil // not accessible from other packages
+	}
+
+	var changed []span.URI
+	i := 0
+	for _, other := range others {
+		pkgs, err := loadKnownPackages(ctx, other, obj.Name())
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, pkg := range pkgs {
+			if pkg.PkgPath() != obj.Pkg().Path() || len(pkg.CompiledGoFiles()) == 0 || pkg.ForTest() != "" {
+				continue
+			}
+			rel, ok := workspaceRelPath(other, pkg.CompiledGoFiles()[0].URI.Filename())
+			if !ok || !isVendorPath(rel) {
+				continue
+			}
+			copied, err := objectpath.Object(pkg.GetTypes(), path)
+			if err != nil || copied.Name() != obj.Name() {
+				continue // the vendored version differs
+			}
+			edits, err := renameObj(ctx, other, newName, []qualifiedObject{{obj: copied, pkg: pkg}}, false)
+			if err != nil {
+				return nil, nil, fmt.Errorf("renaming the copy of %s vendored in %s: %v", obj.Name(), other.View().Name(), err)
+			}
+			if optional == nil {
+				optional = newOptionalEdits(s)
+			}
+			optional.addAnnotatedEdits(VendorGroup, fmt.Sprint(i), protocol.ChangeAnnotation{
+				Label:       "Rename vendored copies",
+				Description: fmt.Sprintf("the copy of %s.%s vendored in %s, and its references there", obj.Pkg().Path(), obj.Name(), other.View().Name()),
+			}, edits)
+			for uri := range edits {
+				changed = append(changed, uri)
+			}
+			i++
+		}
+	}
+	sort.Slice(changed, func(i, j int) bool { return changed[i] < changed[j] })
+	return optional, changed, nil
+}
+
+// RenameVendoredPackage adds to optional, allocated if nil, the updates
+// that renaming the package of f to newName implies in the modules of
+// others, the snapshots of the other views, that vendor it: its lines in
+// their vendor/modules.txt, the location, package clause and imports of the
+// vendored copy, and the imports of the modules. The updates of each module
+// are recorded under an annotation of the vendor group. If the client does
+// not support annotations, a warning instructs to update the vendoring
+// instead.
+func RenameVendoredPackage(ctx context.Context, s Snapshot, f FileHandle, newName string, others []Snapshot, optional *OptionalEdits) (*OptionalEdits, error) {
+	ctx, done := event.Start(ctx, "source.RenameVendoredPackage")
+	defer done()
+
+	metas, err := s.MetadataForFile(ctx, f.URI())
+	if err != nil {
+		return nil, err
+	}
+	if len(metas) == 0 || metas[0].ModuleInfo() == nil {
+		return optional, nil
+	}
+	modulePath := metas[0].ModuleInfo().Path
+	oldPath := metas[0].PackagePath()
+	newPath := path.Join(path.Dir(oldPath), newName)
+
+	i := 0
+	for _, other := range others {
+		for _, modURI := range other.ModFiles() {
+			vendorDir := filepath.Join(filepath.Dir(modURI.Filename()), "vendor")
+			txtURI := span.URIFromPath(filepath.Join(vendorDir, "modules.txt"))
+			txtEdits, err := modulesTxtEdits(ctx, other, txtURI, oldPath, newPath)
+			if err != nil {
+				return nil, err
+			}
+			if len(txtEdits) == 0 {
+				continue // the package is not vendored by this module
+			}
+			all, err := other.AllValidMetadata(ctx)
+			if err != nil {
+				return nil, err
+			}
+			edits, aliases, err := updatePackagePaths(ctx, other, modulePath, oldPath, newPath, newName, all)
+			if err != nil {
+				return nil, err
+			}
+			for _, a := range aliases {
+				for uri, e := range a.edits {
+					edits[uri] = append(edits[uri], e...)
+				}
+			}
+			edits[txtURI] = append(edits[txtURI], txtEdits...)
+
+			if optional == nil {
+				optional = newOptionalEdits(s)
+			}
+			name := fmt.Sprint(i)
+			i++
+			optional.addAnnotatedEdits(VendorGroup, name, protocol.ChangeAnnotation{
+				Label:       "Update vendored copies",
+				Description: fmt.Sprintf("the vendoring of %s in %s: its vendor/modules.txt, the vendored copy and its imports", oldPath, other.View().Name()),
+			}, edits)
+			oldDir := filepath.Join(vendorDir, filepath.FromSlash(oldPath))
+			if _, err := os.Stat(oldDir); err == nil {
+				optional.FileRenames = append(optional.FileRenames, RenameSteps(protocol.RenameFile{
+					Kind:              "rename",
+					OldURI:            protocol.URIFromPath(oldDir),
+					NewURI:            protocol.URIFromPath(filepath.Join(vendorDir, filepath.FromSlash(newPath))),
+					ResourceOperation: protocol.ResourceOperation{AnnotationID: annotationID(VendorGroup, name)},
+				})...)
+			}
+			if !s.View().Options().SupportChangeAnnotations {
+				optional.Warnings = append(optional.Warnings, fmt.Sprintf("%s vendors %s: update its imports to %s and run go mod vendor once it requires the renamed module", other.View().Name(), oldPath, newPath))
+			}
+		}
+	}
+	return optional, nil
+}
+
+// modulesTxtEdits returns the edits replacing the import path oldPath, and
+// the paths of the packages nested within it, by newPath in the package
+// lines of the vendor/modules.txt file uri, if it exists.
+func modulesTxtEdits(ctx context.Context, s Snapshot, uri span.URI, oldPath, newPath string) ([]protocol.TextEdit, error) {
+	fh, err := s.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	data, err := fh.Read()
+	if err != nil {
+		return nil, nil // not vendoring
+	}
+	m := protocol.NewColumnMapper(uri, data)
+	var edits []protocol.TextEdit
+	offset := 0
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		pkg := string(bytes.TrimRight(line, "\r\n"))
+		if pkg == oldPath || strings.HasPrefix(pkg, oldPath+"/") {
+			rng, err := m.OffsetRange(offset, offset+len(oldPath))
+			if err != nil {
+				return nil, err
+			}
+			edits = append(edits, protocol.TextEdit{Range: rng, NewText: newPath})
+		}
+		offset += len(line)
+	}
+	return edits, nil
+}
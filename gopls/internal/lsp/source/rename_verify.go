@@ -0,0 +1,147 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/event"
+)
+
+// A RenameError is an error of the packages affected by a rename, once its
+// edits are applied.
+type RenameError struct {
+	Location protocol.Location // the zero value if the error has no position
+	Message  string
+}
+
+// VerifyRename loads and type-checks the packages affected by the edits of
+// a rename, and their reverse dependencies, as they would be once the edits
+// are applied, and returns their errors. No file is written: the edited
+// files, like the given overlays of unsaved files, are loaded from memory.
+func VerifyRename(ctx context.Context, s Snapshot, overlays []Overlay, edits map[span.URI][]protocol.TextEdit) ([]RenameError, error) {
+	ctx, done := event.Start(ctx, "source.VerifyRename")
+	defer done()
+
+	contents := make(map[span.URI][]byte)
+	for _, o := range overlays {
+		data, err := o.Read()
+		if err != nil {
+			return nil, err
+		}
+		contents[o.URI()] = data
+	}
+
+	var patterns []string
+	seen := make(map[string]bool)
+	addPattern := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			patterns = append(patterns, path)
+		}
+	}
+	for uri, e := range edits {
+		fh, err := s.GetFile(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		data, err := fh.Read()
+		if err != nil {
+			return nil, err
+		}
+		newContent, _, err := ApplyProtocolEdits(protocol.NewColumnMapper(uri, data), e)
+		if err != nil {
+			return nil, err
+		}
+		contents[uri] = []byte(newContent)
+
+		if !strings.HasSuffix(uri.Filename(), ".go") {
+			continue
+		}
+		metas, err := s.MetadataForFile(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		addPattern("file=" + uri.Filename())
+		for _, m := range metas {
+			rdeps, err := s.GetReverseDependencies(ctx, m.PackageID())
+			if err != nil {
+				return nil, err
+			}
+			for _, rdep := range rdeps {
+				addPattern("file=" + rdep.CompiledGoFiles()[0].URI.Filename())
+			}
+		}
+	}
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	sort.Strings(patterns)
+
+	opts := s.View().Options()
+	overlay := make(map[string][]byte)
+	for uri, data := range contents {
+		overlay[uri.Filename()] = data
+	}
+	cfg := &packages.Config{
+		Context: ctx,
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles | packages.NeedImports |
+			packages.NeedDeps | packages.NeedTypes | packages.NeedSyntax | packages.NeedTypesInfo,
+		Dir:        s.View().Folder().Filename(),
+		Env:        append(os.Environ(), opts.EnvSlice()...),
+		BuildFlags: opts.BuildFlags,
+		Tests:      true,
+		Overlay:    overlay,
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []RenameError
+	reported := make(map[string]bool) // errors are repeated by test variants
+	for _, pkg := range pkgs {
+		for _, e := range pkg.Errors {
+			key := e.Pos + ": " + e.Msg
+			if reported[key] {
+				continue
+			}
+			reported[key] = true
+			errs = append(errs, RenameError{
+				Location: errorLocation(contents, e.Pos),
+				Message:  e.Msg,
+			})
+		}
+	}
+	return errs, nil
+}
+
+// errorLocation returns the location of the position "file:line:col" of
+// a package error, in the given contents of the file if any, or the zero
+// location if it cannot be determined.
+func errorLocation(contents map[span.URI][]byte, pos string) protocol.Location {
+	if pos == "" || pos == "-" {
+		return protocol.Location{}
+	}
+	spn := span.Parse(pos)
+	data, ok := contents[spn.URI()]
+	if !ok {
+		var err error
+		if data, err = os.ReadFile(spn.URI().Filename()); err != nil {
+			return protocol.Location{}
+		}
+	}
+	loc, err := protocol.NewColumnMapper(spn.URI(), data).Location(spn)
+	if err != nil {
+		return protocol.Location{}
+	}
+	return loc
+}
@@ -126,6 +126,11 @@ func IsGenerated(ctx context.Context, snapshot Snapshot, uri span.URI) bool {
 }
 
 func objToMappedRange(fset *token.FileSet, pkg Package, obj types.Object) (MappedRange, error) {
+	return posToMappedRange(fset, pkg, obj.Pos(), objNameEnd(obj))
+}
+
+// objNameEnd returns the end of the name of obj in its declaration.
+func objNameEnd(obj types.Object) token.Pos {
 	nameLen := len(obj.Name())
 	if pkgName, ok := obj.(*types.PkgName); ok {
 		// An imported Go package has a package-local, unqualified name.
@@ -142,7 +147,7 @@ func objToMappedRange(fset *token.FileSet, pkg Package, obj types.Object) (Mappe
 			nameLen = len(pkgName.Imported().Path()) + len(`""`)
 		}
 	}
-	return posToMappedRange(fset, pkg, obj.Pos(), obj.Pos()+token.Pos(nameLen))
+	return obj.Pos() + token.Pos(nameLen)
 }
 
 // posToMappedRange returns the MappedRange for the given [start, end) span,
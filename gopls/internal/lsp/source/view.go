@@ -79,6 +79,14 @@ type Snapshot interface {
 	// If the file is not available, returns nil and an error.
 	ParseGo(ctx context.Context, fh FileHandle, mode ParseMode) (*ParsedGoFile, error)
 
+	// RecoverSwallowedDecls returns a snapshot like this one in which the
+	// open Go files whose syntax errors swallow the declarations that
+	// follow them have the offending code blanked out, so that these
+	// declarations can be type checked, and a function to release it.
+	// The offsets and lines of the files are unchanged. It returns this
+	// snapshot itself if no file needs recovering.
+	RecoverSwallowedDecls(ctx context.Context) (Snapshot, func(), error)
+
 	// DiagnosePackage returns basic diagnostics, including list, parse, and type errors
 	// for pkg, grouped by file.
 	DiagnosePackage(ctx context.Context, pkg Package) (map[span.URI][]*Diagnostic, error)
@@ -151,6 +159,10 @@ type Snapshot interface {
 	// dependencies of this file's package, checked in TypecheckWorkspace mode.
 	GetReverseDependencies(ctx context.Context, id string) ([]Package, error)
 
+	// ReverseDependencyIDs returns the IDs of the packages depending, directly
+	// or not, on the package id, without type checking them.
+	ReverseDependencyIDs(ctx context.Context, id string) ([]string, error)
+
 	// CachedImportPaths returns all the imported packages loaded in this
 	// snapshot, indexed by their package path (not import path, despite the name)
 	// and checked in TypecheckWorkspace mode.
@@ -160,12 +172,25 @@ type Snapshot interface {
 	// in TypecheckWorkspace mode.
 	KnownPackages(ctx context.Context) ([]Package, error)
 
+	// KnownPackageIDs returns the IDs of the packages of KnownPackages,
+	// without type checking them.
+	KnownPackageIDs(ctx context.Context) ([]string, error)
+
 	// ActivePackages returns the packages considered 'active' in the workspace.
 	//
 	// In normal memory mode, this is all workspace packages. In degraded memory
 	// mode, this is just the reverse transitive closure of open packages.
 	ActivePackages(ctx context.Context) ([]Package, error)
 
+	// ActivePackageIDs returns the IDs of the packages of ActivePackages,
+	// without type checking them.
+	ActivePackageIDs(ctx context.Context) ([]string, error)
+
+	// CheckPackages returns the packages ids, checked in TypecheckWorkspace
+	// mode. Unlike the methods above, it does not fail if some of them
+	// cannot be checked, but returns the others along with a PackagesError.
+	CheckPackages(ctx context.Context, ids []string) ([]Package, error)
+
 	// AllValidMetadata returns all valid metadata loaded for the snapshot.
 	AllValidMetadata(ctx context.Context) ([]Metadata, error)
 
@@ -264,6 +289,9 @@ type View interface {
 	// by the GOPRIVATE environment variable.
 	IsGoPrivatePath(path string) bool
 
+	// GOPATH returns the entries of the GOPATH of the view.
+	GOPATH() []string
+
 	// ModuleUpgrades returns known module upgrades for the dependencies of
 	// modfile.
 	ModuleUpgrades(modfile span.URI) map[string]string
@@ -289,6 +317,17 @@ type View interface {
 
 	// GoVersion returns the configured Go version for this view.
 	GoVersion() int
+
+	// RenameAnalysis returns the result of the analysis kind of pkg, a
+	// package of a snapshot of this view, from the cache of the analyses
+	// of renames shared by its snapshots if it holds one valid for pkg,
+	// and computed by compute otherwise, along with the number of its
+	// results.
+	RenameAnalysis(kind string, pkg Package, compute func() (interface{}, int)) interface{}
+
+	// RenameCacheState returns the description of the content of the
+	// cache of RenameAnalysis.
+	RenameCacheState() RenameCacheState
 }
 
 // A FileSource maps uris to FileHandles. This abstraction exists both for
@@ -613,9 +652,35 @@ type Package interface {
 	Version() *module.Version
 	HasListOrParseErrors() bool
 	HasTypeErrors() bool
+	GetTypeErrors() []types.Error
 	ParseMode() ParseMode
 }
 
+// A PackageError reports a package that could not be type checked.
+type PackageError struct {
+	ID    string     // logically a cache.PackageID
+	Path  string     // logically a cache.PackagePath
+	Files []span.URI // the compiled Go files of the package
+	Err   error
+}
+
+func (e *PackageError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+// PackagesError is the error that Snapshot.CheckPackages returns, along
+// with the packages that could be type checked, when some of them could
+// not be.
+type PackagesError []*PackageError
+
+func (e PackagesError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
 // A CriticalError is a workspace-wide error that generally prevents gopls from
 // functioning correctly. In the presence of critical errors, other diagnostics
 // in the workspace may not make sense.
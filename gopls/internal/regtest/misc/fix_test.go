@@ -105,3 +105,48 @@ func Foo() error {
 		env.Await(EmptyDiagnostics("main.go"))
 	})
 }
+
+func TestNamingFixRenamesReferences(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.12
+-- a/a.go --
+package a
+
+func ParseJson() {}
+-- b/b.go --
+package b
+
+import "mod.com/a"
+
+func _() {
+	a.ParseJson()
+}
+`
+	WithOptions(
+		Settings{"analyses": map[string]bool{"naming": true}},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		var d protocol.PublishDiagnosticsParams
+		env.Await(OnceMet(
+			env.DiagnosticAtRegexpWithMessage("a/a.go", `ParseJson`, "func ParseJson should be ParseJSON"),
+			ReadDiagnostics("a/a.go", &d),
+		))
+		// The rename is computed only when the fix is applied.
+		actions := env.GetQuickFixes("a/a.go", d.Diagnostics)
+		if len(actions) != 1 || actions[0].Title != "Rename to ParseJSON" || actions[0].Command == nil || len(actions[0].Edit.DocumentChanges) > 0 {
+			t.Fatalf("quick fixes = %+v, want a single command renaming to ParseJSON", actions)
+		}
+		env.ApplyQuickFixes("a/a.go", d.Diagnostics)
+		// The fix renames the function with the rename engine, which also
+		// updates its references in the other packages.
+		if got, want := env.Editor.BufferText("b/b.go"), "package b\n\nimport \"mod.com/a\"\n\nfunc _() {\n\ta.ParseJSON()\n}\n"; got != want {
+			t.Errorf("b/b.go after the fix:\n%s\nwant:\n%s", got, want)
+		}
+		if got := env.Editor.BufferText("a/a.go"); got != "package a\n\nfunc ParseJSON() {}\n" {
+			t.Errorf("a/a.go after the fix:\n%s", got)
+		}
+	})
+}
@@ -5,9 +5,17 @@
 package misc
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
 	"strings"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+
+	"golang.org/x/tools/gopls/internal/lsp/command"
+	"golang.org/x/tools/gopls/internal/lsp/fake"
 	"golang.org/x/tools/gopls/internal/lsp/protocol"
 	. "golang.org/x/tools/gopls/internal/lsp/regtest"
 	"golang.org/x/tools/gopls/internal/lsp/tests/compare"
@@ -787,6 +795,70 @@ const _ = bar.Bar + baz.Baz + foox.Foo
 	})
 }
 
+func TestRenamePackage_TestCycle(t *testing.T) {
+	// The external test of a imports b, which imports a: b has an
+	// intermediate test variant, b [a.test], importing a [a.test].
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+const A = 1
+-- a/a_test.go --
+package a
+
+const T = A
+-- a/x_test.go --
+package a_test
+
+import (
+	"mod.com/a"
+	"mod.com/b"
+)
+
+const _ = a.A + b.B
+-- b/b.go --
+package b
+
+import "mod.com/a"
+
+const B = a.A
+-- testdata/c/a.go --
+package c
+
+const A = 1
+-- testdata/c/a_test.go --
+package c
+
+const T = A
+-- testdata/c/x_test.go --
+package c_test
+
+import (
+	"mod.com/c"
+	"mod.com/b"
+)
+
+const _ = c.A + b.B
+-- testdata/b/b.go --
+package b
+
+import "mod.com/c"
+
+const B = c.A
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		env.Rename("a/a.go", env.RegexpSearch("a/a.go", "package (a)"), "c")
+		env.Await(ShownMessage("the tests of mod.com/a depend on mod.com/b, which depends on mod.com/a in turn"))
+
+		checkTestdata(t, env)
+	})
+}
+
 func TestRenamePackage_Nesting(t *testing.T) {
 	testenv.NeedsGo1Point(t, 17)
 	const files = `
@@ -865,20 +937,5135 @@ const A = 1 + nested.B
 	})
 }
 
-// checkTestdata checks that current buffer contents match their corresponding
-// expected content in the testdata directory.
-func checkTestdata(t *testing.T, env *Env) {
-	t.Helper()
-	files := env.ListFiles("testdata")
-	if len(files) == 0 {
-		t.Fatal("no files in testdata directory")
+func TestRenamePackage_CaseOnly(t *testing.T) {
+	testenv.NeedsGo1Point(t, 17)
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- Util/a.go --
+package Util
+
+const A = 1
+-- main.go --
+package main
+
+import "mod.com/Util"
+
+var _ = Util.A
+`
+
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("Util/a.go")
+		pos := env.RegexpSearch("Util/a.go", "package (Util)")
+		env.Rename("Util/a.go", pos, "util")
+
+		env.RegexpSearch("util/a.go", "package util")
+		env.RegexpSearch("main.go", `"mod.com/util"`)
+		for _, file := range env.ListFiles(".") {
+			if !strings.HasPrefix(file, "util/") && file != "main.go" && file != "go.mod" {
+				t.Errorf("unexpected file %s after renaming Util to util", file)
+			}
+		}
+	})
+}
+
+func TestRenamePackage_Assets(t *testing.T) {
+	testenv.NeedsGo1Point(t, 17)
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- lib/a.go --
+package lib
+
+const A = 1
+-- lib/README.md --
+Package lib.
+-- lib/schema.sql --
+CREATE TABLE t (id INT);
+-- lib/testdata/golden.txt --
+golden
+-- main.go --
+package main
+
+import "mod.com/lib"
+
+var _ = lib.A
+`
+
+	for _, moveEachFile := range []bool{false, true} {
+		t.Run(fmt.Sprintf("moveEachFile=%t", moveEachFile), func(t *testing.T) {
+			WithOptions(
+				Settings{"renameMoveEachFile": moveEachFile},
+			).Run(t, files, func(t *testing.T, env *Env) {
+				env.OpenFile("lib/a.go")
+				pos := env.RegexpSearch("lib/a.go", "package (lib)")
+				env.Rename("lib/a.go", pos, "util")
+
+				env.RegexpSearch("util/a.go", "package util")
+				got := env.ListFiles(".")
+				want := []string{"go.mod", "main.go", "util/README.md", "util/a.go", "util/schema.sql", "util/testdata/golden.txt"}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("files after the rename (-want +got):\n%s", diff)
+				}
+			})
+		})
 	}
-	for _, file := range files {
-		suffix := strings.TrimPrefix(file, "testdata/")
-		got := env.Editor.BufferText(suffix)
-		want := env.ReadWorkspaceFile(file)
-		if diff := compare.Text(want, got); diff != "" {
-			t.Errorf("Rename: unexpected buffer content for %s (-want +got):\n%s", suffix, diff)
+}
+
+func TestRenameFieldInBuildVariants(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+type T struct {
+	Field int
+}
+
+var _ = T{Field: 1}
+-- a/a_other.go --
+//go:build othertag
+
+package a
+
+var _ = []*T{{Field: 2}, &T{Field: 3}}
+-- b/b_other.go --
+//go:build othertag
+
+package b
+
+import x "mod.com/a"
+
+var _ = map[string]x.T{"k": {Field: 4}}
+-- b/b.go --
+package b
+
+import "mod.com/a"
+
+var _ = a.T{Field: 5}
+-- c/c.go --
+package a
+
+import x "mod.com/a"
+
+var _ = x.T{}
+-- c/c_other.go --
+//go:build othertag
+
+package a
+
+type T struct{ Field int }
+
+var _ = T{Field: 6}
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		pos := env.RegexpSearch("a/a.go", "Field")
+		env.Rename("a/a.go", pos, "Renamed")
+
+		// The importer c shares the name of a, but not its types.
+		if got := env.Editor.BufferText("c/c_other.go"); strings.Contains(got, "Renamed") {
+			t.Errorf("c/c_other.go: the field of its own T was renamed:\n%s", got)
+		}
+
+		for file, want := range map[string]string{
+			"a/a_other.go": "var _ = []*T{{Renamed: 2}, &T{Renamed: 3}}",
+			"b/b_other.go": `var _ = map[string]x.T{"k": {Renamed: 4}}`,
+			"b/b.go":       "var _ = a.T{Renamed: 5}",
+		} {
+			if got := env.Editor.BufferText(file); !strings.Contains(got, want) {
+				t.Errorf("%s: missing %q after rename:\n%s", file, want, got)
+			}
+		}
+	})
+}
+
+func TestRenameMethodAssignedInClosure(t *testing.T) {
+	// Interface assignments inside function literals of an importer that
+	// never mentions the renamed method must still be checked.
+	for name, body := range map[string]string{
+		"go":      "func f() { go func() { var w io.Writer = a.T{}; _ = w }() }",
+		"defer":   "func f() { defer func() { var w io.Writer = a.T{}; _ = w }() }",
+		"funcvar": "var f = func() io.Writer { return a.T{} }",
+	} {
+		t.Run(name, func(t *testing.T) {
+			files := `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+type T struct{}
+
+func (T) Write(p []byte) (int, error) { return 0, nil }
+-- b/b.go --
+package b
+
+import (
+	"io"
+
+	"mod.com/a"
+)
+
+` + body + `
+`
+			Run(t, files, func(t *testing.T, env *Env) {
+				env.OpenFile("a/a.go")
+				pos := env.RegexpSearch("a/a.go", `\) (Write)`)
+				err := env.Editor.Rename(env.Ctx, "a/a.go", pos, "Write2")
+				if err == nil || !strings.Contains(err.Error(), "no longer assignable") {
+					t.Errorf("Rename(Write, Write2) returned error %v, want assignability conflict", err)
+				}
+			})
+		})
+	}
+}
+
+func TestRenameCandidates(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a.go --
+package a
+
+type T int
+
+type S struct{ T }
+
+var _ = S{}.T
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a.go")
+		pos := env.RegexpSearch("a.go", `S\{\}\.(T)`)
+		err := env.Editor.Rename(env.Ctx, "a.go", pos, "U")
+		const want = `ambiguous rename of "T", which denotes 2 objects: field T at a.go:5:16, which can't be renamed; type T at a.go:3:6.`
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Rename(T, U) returned error %v, want %q", err, want)
+		}
+
+		cmd, err := command.NewRenameCandidatesCommand("", protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: env.Sandbox.Workdir.URI("a.go")},
+			Position:     pos.ToProtocolPosition(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result command.RenameCandidatesResult
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   command.RenameCandidates.ID(),
+			Arguments: cmd.Arguments,
+		}, &result)
+		var got []string
+		for _, c := range result.Candidates {
+			got = append(got, c.Kind+" "+c.Name)
+		}
+		if want := []string{"field T", "type T"}; strings.Join(got, ", ") != strings.Join(want, ", ") {
+			t.Fatalf("RenameCandidates: got %v, want %v", got, want)
+		}
+		if result.Candidates[0].Reason == "" {
+			t.Errorf("RenameCandidates: embedded field has no reason for being unrenamable")
 		}
+
+		// Renaming at the declaration of the type is unambiguous.
+		loc := result.Candidates[1].Location
+		if err := env.Editor.Rename(env.Ctx, "a.go", fake.Pos{Line: int(loc.Range.Start.Line), Column: int(loc.Range.Start.Character)}, "U"); err != nil {
+			t.Fatal(err)
+		}
+		if got := env.Editor.BufferText("a.go"); !strings.Contains(got, "type S struct{ U }") {
+			t.Errorf("Rename(T, U) at declaration: got\n%s", got)
+		}
+	})
+}
+
+func TestRenameFromComment(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a.go --
+package a
+
+// Foo returns a new T; see also [T.Bar].
+func Foo() T { return T{} }
+
+type T struct{}
+
+func (T) Bar() {}
+
+var _ = Foo
+`
+	for _, test := range []struct {
+		name, re, newName, want string
+	}{
+		{"doc comment", `// (Foo) returns`, "Baz", `// Baz returns a new T; see also [T.Bar].
+func Baz() T { return T{} }`},
+		{"doc link type", `\[(T)\.Bar`, "U", `// Foo returns a new T; see also [U.Bar].
+func Foo() U { return U{} }`},
+		{"doc link method", `\[T\.(Bar)`, "Qux", `func (T) Qux() {}`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			Run(t, files, func(t *testing.T, env *Env) {
+				env.OpenFile("a.go")
+				pos := env.RegexpSearch("a.go", test.re)
+				env.Rename("a.go", pos, test.newName)
+				if got := env.Editor.BufferText("a.go"); !strings.Contains(got, test.want) {
+					t.Errorf("Rename(%s) from comment: got\n%s\nwant it to contain\n%s", test.re, got, test.want)
+				}
+			})
+		})
 	}
 }
+
+func TestRenameFromDocLinkInOtherPackage(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+// Foo does nothing.
+func Foo() {}
+-- b/b.go --
+package b
+
+import "mod.com/a"
+
+// B calls [a.Foo], like [mod.com/a.Foo] would.
+func B() { a.Foo() }
+-- c/c.go --
+package c
+
+import _ "mod.com/a"
+
+// See [a.Foo].
+func C() {}
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("b/b.go")
+		pos := env.RegexpSearch("b/b.go", `\[a\.(Foo)\]`)
+		env.Rename("b/b.go", pos, "Bar")
+		for file, want := range map[string]string{
+			"a/a.go": "// Bar does nothing.\nfunc Bar() {}",
+			"b/b.go": "// B calls [a.Bar], like [mod.com/a.Foo] would.\nfunc B() { a.Bar() }",
+			"c/c.go": "// See [a.Foo].", // the blank import has no name to link with
+		} {
+			env.OpenFile(file)
+			if got := env.Editor.BufferText(file); !strings.Contains(got, want) {
+				t.Errorf("%s after rename: got\n%s\nwant it to contain\n%s", file, got, want)
+			}
+		}
+	})
+}
+
+func TestWillRenameFiles(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- lib/lib.go --
+package lib
+
+const C = 1
+-- lib/nested/nested.go --
+package nested
+
+const D = 2
+-- other/other.go --
+package other
+
+const E = 3
+-- main.go --
+package main
+
+import (
+	"mod.com/lib"
+	"mod.com/lib/nested"
+)
+
+func main() {
+	println(lib.C, nested.D)
+}
+-- extra.go --
+package main
+
+const F = 4
+`
+	t.Run("directory", func(t *testing.T) {
+		Run(t, files, func(t *testing.T, env *Env) {
+			if err := env.Editor.WillRenameFiles(env.Ctx, "lib", "other/newlib"); err != nil {
+				t.Fatal(err)
+			}
+			env.RenameFile("lib", "other/newlib")
+			for file, want := range map[string]string{
+				"main.go":                       "\"mod.com/other/newlib\"\n\t\"mod.com/other/newlib/nested\"\n)\n\nfunc main() {\n\tprintln(newlib.C, nested.D)",
+				"other/newlib/lib.go":           "package newlib",
+				"other/newlib/nested/nested.go": "package nested",
+			} {
+				env.OpenFile(file)
+				if got := env.Editor.BufferText(file); !strings.Contains(got, want) {
+					t.Errorf("%s after directory rename: got\n%s\nwant it to contain\n%s", file, got, want)
+				}
+			}
+		})
+	})
+	t.Run("file", func(t *testing.T) {
+		Run(t, files, func(t *testing.T, env *Env) {
+			if err := env.Editor.WillRenameFiles(env.Ctx, "extra.go", "other/extra.go"); err != nil {
+				t.Fatal(err)
+			}
+			env.RenameFile("extra.go", "other/extra.go")
+			env.OpenFile("other/extra.go")
+			if got, want := env.Editor.BufferText("other/extra.go"), "package other\n"; !strings.HasPrefix(got, want) {
+				t.Errorf("other/extra.go after move: got\n%s\nwant prefix %q", got, want)
+			}
+		})
+	})
+}
+
+func TestUpdateMovedImports(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- lib/lib.go --
+package lib
+
+const C = 1
+-- lib/nested/nested.go --
+package nested
+
+const D = 2
+-- lib/go-yaml/yaml.go --
+package yaml
+
+const E = 3
+-- main.go --
+package main
+
+import (
+	"mod.com/lib"
+	"mod.com/lib/go-yaml"
+	"mod.com/lib/nested"
+)
+
+func main() {
+	println(lib.C, nested.D, yaml.E)
+}
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("main.go")
+
+		// Move the lib directory behind the editor's back.
+		env.WriteWorkspaceFiles(map[string]string{
+			"newlib/lib.go":           env.ReadWorkspaceFile("lib/lib.go"),
+			"newlib/nested/nested.go": env.ReadWorkspaceFile("lib/nested/nested.go"),
+			"newlib/go-yaml/yaml.go":  env.ReadWorkspaceFile("lib/go-yaml/yaml.go"),
+		})
+		env.RemoveWorkspaceFile("lib/lib.go")
+		env.RemoveWorkspaceFile("lib/nested/nested.go")
+		env.RemoveWorkspaceFile("lib/go-yaml/yaml.go")
+		env.Await(env.DiagnosticAtRegexp("main.go", `"mod.com/lib"`))
+
+		pos := env.RegexpSearch("main.go", `"mod.com/lib"`)
+		rng := protocol.Range{Start: pos.ToProtocolPosition(), End: pos.ToProtocolPosition()}
+		actions, err := env.Editor.CodeAction(env.Ctx, "main.go", &rng, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		const title = "Update imports of mod.com/lib to mod.com/newlib"
+		var found bool
+		for _, action := range actions {
+			if action.Title == title {
+				env.ApplyCodeAction(action)
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("no code action %q among %v", title, actions)
+		}
+		// The imports keep their local names, even those of packages
+		// named unlike their directories, such as yaml.
+		want := "import (\n\t\"mod.com/newlib\"\n\t\"mod.com/newlib/go-yaml\"\n\t\"mod.com/newlib/nested\"\n)"
+		if got := env.Editor.BufferText("main.go"); !strings.Contains(got, want) {
+			t.Errorf("main.go after update: got\n%s\nwant it to contain\n%s", got, want)
+		}
+	})
+}
+
+func TestRenameNamesakeFile(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- foo_bar.go --
+package a
+
+type FooBar struct{}
+
+func NewFooBar() *FooBar { return &FooBar{} }
+
+func (*FooBar) M() {}
+-- other.go --
+package a
+
+type Other struct{}
+
+func helper() {}
+`
+	WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("foo_bar.go")
+		env.Rename("foo_bar.go", env.RegexpSearch("foo_bar.go", "type (FooBar)"), "BazQux")
+		env.OpenFile("other.go")
+		env.Rename("other.go", env.RegexpSearch("other.go", "type (Other)"), "Another")
+
+		files := strings.Join(env.ListFiles("."), " ")
+		if want := "baz_qux.go go.mod other.go"; files != want {
+			t.Errorf("after renames, files are %s, want %s", files, want)
+		}
+		if got := env.Editor.BufferText("baz_qux.go"); !strings.Contains(got, "func NewFooBar() *BazQux") {
+			t.Errorf("baz_qux.go: got\n%s", got)
+		}
+	})
+}
+
+func TestRenameNamesakeFileVariants(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- foo.go --
+package a
+
+func Foo() {}
+-- foo_test.go --
+package a
+
+import "testing"
+
+func TestFoo(t *testing.T) { Foo() }
+-- foo_linux.go --
+package a
+
+func fooLinux() {}
+-- foo_windows_amd64.go --
+package a
+
+func fooWindows() {}
+-- food.go --
+package a
+
+var food = 1
+`
+	WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("foo.go")
+		env.Rename("foo.go", env.RegexpSearch("foo.go", "func (Foo)"), "Bar")
+
+		files := strings.Join(env.ListFiles("."), " ")
+		if want := "bar.go bar_linux.go bar_test.go bar_windows_amd64.go food.go go.mod"; files != want {
+			t.Errorf("after rename, files are %s, want %s", files, want)
+		}
+	})
+}
+
+func TestRenameTextOccurrences(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+type FooBar struct{}
+-- README.md --
+Use a.FooBar, not data.FooBar or a.FooBarBaz.
+-- config.json --
+{"type": "mod.com/a.FooBar"}
+`
+	WithOptions(
+		HonorsChangeAnnotations(),
+		Settings{"renameTextOccurrences": true},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		pos := env.RegexpSearch("a/a.go", "type (FooBar)")
+
+		cmd, err := command.NewTextOccurrencesCommand("", protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: env.Sandbox.Workdir.URI("a/a.go")},
+			Position:     pos.ToProtocolPosition(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result command.TextOccurrencesResult
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   command.TextOccurrences.ID(),
+			Arguments: cmd.Arguments,
+		}, &result)
+		var got []string
+		for _, loc := range result.Locations {
+			got = append(got, env.Sandbox.Workdir.URIToPath(loc.URI))
+		}
+		if want := "README.md config.json"; strings.Join(got, " ") != want {
+			t.Errorf("TextOccurrences: got %v, want %s", got, want)
+		}
+
+		// Only the occurrence in the document is edited.
+		env.Rename("a/a.go", pos, "BazQux")
+		if got, want := env.Editor.BufferText("README.md"), "Use a.BazQux, not data.FooBar or a.FooBarBaz.\n"; got != want {
+			t.Errorf("README.md: got %q, want %q", got, want)
+		}
+		if env.Editor.HasBuffer("config.json") {
+			t.Errorf("config.json was edited")
+		}
+	})
+}
+
+func TestRenameReflectiveAccesses(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+type T struct{ Foo int }
+
+func (T) Bar() {}
+-- b/b.go --
+package b
+
+import (
+	"reflect"
+
+	"mod.com/a"
+)
+
+func _() {
+	v := reflect.ValueOf(a.T{})
+	_ = v.FieldByName("Foo")
+	_ = v.MethodByName("Bar")
+	f, _ := v.Type().FieldByName("Foo")
+	_ = f.Name == "Foo"
+	_ = "Foo"
+}
+`
+	WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		env.Rename("a/a.go", env.RegexpSearch("a/a.go", "(Foo) int"), "Baz")
+		env.Rename("a/a.go", env.RegexpSearch("a/a.go", "(Bar)"), "Qux")
+
+		const want = `package b
+
+import (
+	"reflect"
+
+	"mod.com/a"
+)
+
+func _() {
+	v := reflect.ValueOf(a.T{})
+	_ = v.FieldByName("Baz")
+	_ = v.MethodByName("Qux")
+	f, _ := v.Type().FieldByName("Baz")
+	_ = f.Name == "Baz"
+	_ = "Foo"
+}
+`
+		if got := env.Editor.BufferText("b/b.go"); got != want {
+			t.Errorf("b/b.go after renames:\n%s", compare.Text(want, got))
+		}
+	})
+}
+
+func TestRenameStringRegistrations(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+import (
+	"encoding/gob"
+	"net/rpc"
+	"text/template"
+)
+
+type T struct{}
+
+func (T) M(int, *int) error { return nil }
+
+func foo() string { return "" }
+
+var funcs = template.FuncMap{"foo": foo}
+
+func _(c *rpc.Client) {
+	gob.Register(T{})
+	c.Call("T.M", 1, nil)
+}
+`
+	WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		env.Rename("a/a.go", env.RegexpSearch("a/a.go", "func (foo)"), "bar")
+		env.Await(ShownMessage(`templates calling the function "foo" must be updated`))
+		env.Rename("a/a.go", env.RegexpSearch("a/a.go", "func \\(T\\) (M)"), "N")
+		env.Rename("a/a.go", env.RegexpSearch("a/a.go", "type (T)"), "U")
+
+		got := env.Editor.BufferText("a/a.go")
+		for _, want := range []string{
+			`template.FuncMap{"bar": bar}`,
+			`gob.RegisterName("mod.com/a.T", U{})`,
+			`c.Call("U.N", 1, nil)`,
+		} {
+			if !strings.Contains(got, want) {
+				t.Errorf("a/a.go after renames does not contain %s:\n%s", want, got)
+			}
+		}
+	})
+}
+
+func TestRenameNameSensitiveCalls(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- di/di.go --
+package di
+
+type Container struct{}
+
+func (*Container) Invoke(name string) {}
+-- a/a.go --
+package a
+
+import "mod.com/di"
+
+func Foo() {}
+
+func _(c *di.Container) {
+	c.Invoke("Foo")
+}
+-- b/b.go --
+package b
+
+import (
+	"mod.com/a"
+	"mod.com/di"
+)
+
+var _ = a.Foo
+
+func _(c *di.Container) {
+	c.Invoke("Foo")
+}
+`
+	WithOptions(
+		Settings{"renameNameSensitiveCalls": []interface{}{"mod.com/di.Container.Invoke"}},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		env.Rename("a/a.go", env.RegexpSearch("a/a.go", "func (Foo)"), "Bar")
+		// The calls of the importers of an exported object are searched too.
+		env.Await(
+			ShownMessage(`a.go:8: (*mod.com/di.Container).Invoke is passed the name "Foo"`),
+			ShownMessage(`b.go:11: (*mod.com/di.Container).Invoke is passed the name "Foo"`),
+		)
+	})
+}
+
+func TestRenameColumnTags(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+type User struct {
+	ID       int    ` + "`db:\"id\"`" + `
+	UserName string ` + "`db:\"user_name\" gorm:\"column:user_name;size:64\"`" + `
+	Email    string
+}
+`
+	WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		env.Rename("a/a.go", env.RegexpSearch("a/a.go", "(UserName) string"), "Login")
+		env.Rename("a/a.go", env.RegexpSearch("a/a.go", "(Email)"), "Mail")
+
+		got := env.Editor.BufferText("a/a.go")
+		for _, want := range []string{
+			"Login string `db:\"login\" gorm:\"column:login;size:64\"`",
+			"Mail  string `db:\"email\" gorm:\"column:email\"`",
+		} {
+			if !strings.Contains(got, want) {
+				t.Errorf("a/a.go after renames does not contain %s:\n%s", want, got)
+			}
+		}
+	})
+}
+
+func TestRenameAccessors(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+type T struct{ foo int }
+
+func (t *T) Foo() int { return t.foo }
+
+func (t *T) SetFoo(v int) { t.foo = v }
+
+func _(t *T) { t.SetFoo(t.Foo()) }
+`
+	WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		env.Rename("a/a.go", env.RegexpSearch("a/a.go", "(foo) int"), "bar")
+		env.Rename("a/a.go", env.RegexpSearch("a/a.go", "func \\(t \\*T\\) (Bar)"), "Baz")
+
+		const want = `package a
+
+type T struct{ baz int }
+
+func (t *T) Baz() int { return t.baz }
+
+func (t *T) SetBaz(v int) { t.baz = v }
+
+func _(t *T) { t.SetBaz(t.Baz()) }
+`
+		if got := env.Editor.BufferText("a/a.go"); got != want {
+			t.Errorf("a/a.go after renames:\n%s", compare.Text(want, got))
+		}
+	})
+}
+
+func TestRenameImplementationsInTestsAndWorkspace(t *testing.T) {
+	testenv.NeedsGo1Point(t, 18)
+	const files = `
+-- go.work --
+go 1.18
+
+use (
+	./a
+	./b
+)
+-- a/go.mod --
+module example.com/a
+
+go 1.18
+-- a/a.go --
+package a
+
+type I interface{ M() }
+
+func Use(i I) { i.M() }
+-- a/a_test.go --
+package a
+
+type internal struct{}
+
+func (internal) M() {}
+
+var _ I = internal{}
+-- a/x_test.go --
+package a_test
+
+import "example.com/a"
+
+type external struct{}
+
+func (external) M() {}
+
+func _() { a.Use(external{}); external{}.M() }
+-- b/go.mod --
+module example.com/b
+
+go 1.18
+
+require example.com/a v0.0.0
+-- b/b.go --
+package b
+
+import "example.com/a"
+
+type T struct{}
+
+func (T) M() {}
+
+func _() { a.Use(T{}); T{}.M() }
+-- b/b_test.go --
+package b
+
+func _() { T{}.M() }
+`
+	WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		env.Rename("a/a.go", env.RegexpSearch("a/a.go", "interface{ (M)"), "N")
+
+		for file, want := range map[string]string{
+			"a/a_test.go": "func (internal) N() {}",
+			"a/x_test.go": "func _() { a.Use(external{}); external{}.N() }",
+			"b/b.go":      "func _() { a.Use(T{}); T{}.N() }",
+			"b/b_test.go": "func _() { T{}.N() }",
+		} {
+			env.OpenFile(file)
+			if got := env.Editor.BufferText(file); !strings.Contains(got, want) {
+				t.Errorf("%s after rename does not contain %s:\n%s", file, want, got)
+			}
+		}
+	})
+}
+
+func TestRenameSharedImplementations(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+type Resource interface{ Release() }
+
+type Pool interface {
+	Release()
+	Size() int
+}
+
+type A struct{}
+
+func (A) Release() {}
+
+type B struct{}
+
+func (B) Release() {}
+
+func (B) Size() int { return 0 }
+`
+	WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		pos := env.RegexpSearch("a/a.go", "interface{ (Release)")
+		edit, err := env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+			TextDocument: env.Editor.TextDocumentIdentifier("a/a.go"),
+			Position:     pos.ToProtocolPosition(),
+			NewName:      "Free",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, a := range edit.ChangeAnnotations {
+			got = append(got, a.Label+": "+a.Description)
+		}
+		sort.Strings(got)
+		want := []string{
+			"Rename implementations of other interfaces: mod.com/a.B.Release, which also implements a.Pool",
+			"Rename implementations: mod.com/a.A.Release",
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("annotations mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestRenameConfirmations(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+type Resource interface{ Release() }
+
+type A struct{ count int }
+
+func (A) Release() {}
+
+func (a A) Count() int { return a.count }
+`
+	WithOptions(
+		HonorsChangeAnnotations(),
+		Settings{"renameConfirmations": map[string]interface{}{"implementations": false}},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		for _, test := range []struct {
+			re, newName, label string
+			want               bool
+		}{
+			{"interface{ (Release)", "Free", "Rename implementations", false},
+			{"struct{ (count)", "total", "Rename accessor", true},
+		} {
+			pos := env.RegexpSearch("a/a.go", test.re)
+			edit, err := env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+				TextDocument: env.Editor.TextDocumentIdentifier("a/a.go"),
+				Position:     pos.ToProtocolPosition(),
+				NewName:      test.newName,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			found := false
+			for _, a := range edit.ChangeAnnotations {
+				if a.Label == test.label {
+					found = true
+					if a.NeedsConfirmation != test.want {
+						t.Errorf("%s: NeedsConfirmation = %t, want %t", a.Label, a.NeedsConfirmation, test.want)
+					}
+				}
+			}
+			if !found {
+				t.Errorf("renaming to %s: no annotation %q", test.newName, test.label)
+			}
+		}
+	})
+}
+
+func TestRenameGroups(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+// Widget is a Widget.
+type Widget struct{}
+-- a/a_gen.go --
+// Code generated by widgetgen. DO NOT EDIT.
+
+package a
+
+var _ Widget
+`
+	WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		pos := env.RegexpSearch("a/a.go", "type (Widget)")
+		edit, err := env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+			TextDocument: env.Editor.TextDocumentIdentifier("a/a.go"),
+			Position:     pos.ToProtocolPosition(),
+			NewName:      "Gadget",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]string)
+		for _, c := range edit.DocumentChanges {
+			if c.TextDocumentEdit == nil {
+				continue
+			}
+			for _, e := range c.TextDocumentEdit.Edits {
+				a := edit.ChangeAnnotations[e.AnnotationID]
+				got[e.AnnotationID] += fmt.Sprintf("%s (confirm: %t) ", a.Label, a.NeedsConfirmation)
+			}
+		}
+		want := map[string]string{
+			"":          " (confirm: false) ",
+			"comments":  "Rename in comments (confirm: false) Rename in comments (confirm: false) ",
+			"generated": "Rename in generated files (confirm: true) ",
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("edits by annotation mismatch (-want +got):\n%s", diff)
+		}
+		env.Rename("a/a.go", pos, "Gadget")
+		env.RegexpSearch("a/a.go", "// Gadget is a Gadget.")
+		env.RegexpSearch("a/a_gen.go", "var _ Gadget")
+	})
+}
+
+func TestRenameAlmostImplementations(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+type I interface {
+	M(int) error
+	N()
+}
+
+type A struct{}
+
+func (A) M(int) error { return nil }
+func (A) N()          {}
+
+type B struct{}
+
+func (*B) M(int) error { return nil }
+
+type C struct{}
+
+func (C) M(string) error { return nil }
+`
+	WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		pos := env.RegexpSearch("a/a.go", "\t(M)\\(int\\) error")
+		edit, err := env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+			TextDocument: env.Editor.TextDocumentIdentifier("a/a.go"),
+			Position:     pos.ToProtocolPosition(),
+			NewName:      "Do",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]int)
+		for _, c := range edit.DocumentChanges {
+			if c.TextDocumentEdit == nil {
+				continue
+			}
+			for _, e := range c.TextDocumentEdit.Edits {
+				got[e.AnnotationID]++
+			}
+		}
+		want := map[string]int{
+			"":                  1,
+			"implementations/0": 1,
+			"almost/0":          1,
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("edits by annotation mismatch (-want +got):\n%s", diff)
+		}
+		a := edit.ChangeAnnotations["almost/0"]
+		if !a.NeedsConfirmation || !strings.Contains(a.Description, "B.M") {
+			t.Errorf("got annotation %+v, want one for B.M needing confirmation", a)
+		}
+	})
+}
+
+func TestRenameSiblingImplementations(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+type Shape interface{ Area() float64 }
+
+type Square struct{ side float64 }
+
+func (s Square) Area() float64 { return s.side * s.side }
+
+type Circle struct{ r float64 }
+
+func (c *Circle) Area() float64 { return 3 * c.r * c.r }
+
+var _ Shape = &Circle{}
+
+type Plot struct{}
+
+func (Plot) Area() int { return 0 }
+
+func total(shapes []Shape) (t float64) {
+	for _, s := range shapes {
+		t += s.Area()
+	}
+	return t
+}
+`
+	WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		pos := env.RegexpSearch("a/a.go", "Square\\) (Area)")
+		edit, err := env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+			TextDocument: env.Editor.TextDocumentIdentifier("a/a.go"),
+			Position:     pos.ToProtocolPosition(),
+			NewName:      "Surface",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]string)
+		for _, c := range edit.DocumentChanges {
+			if c.TextDocumentEdit == nil {
+				continue
+			}
+			for _, e := range c.TextDocumentEdit.Edits {
+				a := edit.ChangeAnnotations[e.AnnotationID]
+				got[e.AnnotationID] = a.Label + ": " + a.Description
+			}
+		}
+		want := map[string]string{
+			"":           ": ",
+			"siblings/0": "Rename interface method: mod.com/a.Shape.Area, which mod.com/a.Square.Area implements",
+			"siblings/1": "Rename sibling implementations: *mod.com/a.Circle.Area, which also implements a.Shape",
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("edits by annotation mismatch (-want +got):\n%s", diff)
+		}
+		if len(edit.ChangeAnnotations) != 2 {
+			t.Errorf("got annotations %v, want 2", edit.ChangeAnnotations)
+		}
+	})
+}
+
+func TestRenameForce(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+func f() int {
+	x := 1
+	y := 2
+	return x + y
+}
+`
+	WithOptions(
+		HonorsChangeAnnotations(),
+		Settings{"renameForce": true},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		pos := env.RegexpSearch("a/a.go", "(x) :=")
+		edit, err := env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+			TextDocument: env.Editor.TextDocumentIdentifier("a/a.go"),
+			Position:     pos.ToProtocolPosition(),
+			NewName:      "y",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, ok := edit.ChangeAnnotations["conflict/0"]
+		if !ok || !a.NeedsConfirmation {
+			t.Fatalf("got annotations %v, want conflict/0 needing confirmation", edit.ChangeAnnotations)
+		}
+		if want := "conflicts with var in same block"; !strings.Contains(a.Description, want) {
+			t.Errorf("conflict description %q does not contain %q", a.Description, want)
+		}
+		env.Await(ShownMessage("conflict: renaming this var"))
+	})
+}
+
+func TestDryRunRename(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+type Resource interface{ Release() }
+
+type A struct{}
+
+func (A) Release() {}
+
+func f() int {
+	x := 1
+	y := 2
+	return x + y
+}
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		dryRun := func(re, newName string) command.DryRunRenameResult {
+			t.Helper()
+			pos := env.RegexpSearch("a/a.go", re)
+			cmd, err := command.NewDryRunRenameCommand("", protocol.RenameParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: env.Sandbox.Workdir.URI("a/a.go")},
+				Position:     pos.ToProtocolPosition(),
+				NewName:      newName,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var result command.DryRunRenameResult
+			env.ExecuteCommand(&protocol.ExecuteCommandParams{
+				Command:   command.DryRunRename.ID(),
+				Arguments: cmd.Arguments,
+			}, &result)
+			return result
+		}
+
+		result := dryRun("interface{ (Release)", "Free")
+		if len(result.Files) != 1 || result.Files[0].Edits != 1 {
+			t.Errorf("got files %v, want 1 edit in a.go", result.Files)
+		}
+		if len(result.Annotations) != 1 || result.Annotations[0].ID != "implementations/0" {
+			t.Errorf("got annotations %v, want implementations/0", result.Annotations)
+		}
+		if !result.Exported {
+			t.Error("Release is not reported as exported")
+		}
+
+		result = dryRun("(x) :=", "y")
+		if len(result.Conflicts) != 1 || !strings.Contains(result.Conflicts[0], "conflicts with var in same block") {
+			t.Errorf("got conflicts %q, want a conflict with var y", result.Conflicts)
+		}
+		if result.Exported {
+			t.Error("local variable x is reported as exported")
+		}
+
+		// A dry run changes nothing.
+		env.RegexpSearch("a/a.go", "interface{ Release")
+		env.RegexpSearch("a/a.go", "x := 1")
+	})
+}
+
+func TestDryRunRenamePackages(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+func Hello() {}
+-- b/b.go --
+package b
+
+import "mod.com/a"
+
+func _() { a.Hello() }
+-- c/c.go --
+package c
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		pos := env.RegexpSearch("a/a.go", "func (Hello)")
+		cmd, err := command.NewDryRunRenameCommand("", protocol.RenameParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: env.Sandbox.Workdir.URI("a/a.go")},
+			Position:     pos.ToProtocolPosition(),
+			NewName:      "Greet",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result command.DryRunRenameResult
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   command.DryRunRename.ID(),
+			Arguments: cmd.Arguments,
+		}, &result)
+		want := []string{"mod.com/a", "mod.com/b"}
+		if diff := cmp.Diff(want, result.Packages); diff != "" {
+			t.Errorf("affected packages mismatch (-want +got):\n%s", diff)
+		}
+		for _, f := range result.Files {
+			if len(f.Packages) != 1 {
+				t.Errorf("%s: got packages %v, want one", f.URI, f.Packages)
+			}
+		}
+	})
+}
+
+func TestRenameExportDataImporters(t *testing.T) {
+	// The module cache package example.com/dep imports mod.com/a, which
+	// the main module provides: it is loaded only from export data.
+	const proxy = `
+-- example.com@v1.2.3/go.mod --
+module example.com
+
+go 1.12
+-- example.com@v1.2.3/dep/dep.go --
+package dep
+
+import "mod.com/a"
+
+var V = a.Foo
+
+func F() int { return a.Foo }
+`
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.12
+
+require example.com v1.2.3
+-- go.sum --
+example.com v1.2.3 h1:1qJPO6oIGxnPSLlKoTsryfRiU4n1AcW1oMysSK/6/bs=
+example.com v1.2.3/go.mod h1:Y2Rc5rVWjWur0h3pd9aEvK5Pof8YKDANh9gHA2Maujo=
+-- a/a.go --
+package a
+
+const Foo = 1
+-- main.go --
+package main
+
+import (
+	"example.com/dep"
+	"mod.com/a"
+)
+
+var _ = dep.V + a.Foo
+`
+	WithOptions(
+		ProxyFiles(proxy),
+		Settings{"renameAllowBreakingExternal": true},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		pos := env.RegexpSearch("a/a.go", "Foo")
+		cmd, err := command.NewDryRunRenameCommand("", protocol.RenameParams{
+			TextDocument: env.Editor.TextDocumentIdentifier("a/a.go"),
+			Position:     pos.ToProtocolPosition(),
+			NewName:      "Bar",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result command.DryRunRenameResult
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   command.DryRunRename.ID(),
+			Arguments: cmd.Arguments,
+		}, &result)
+		if len(result.ExportData) != 1 || result.ExportData[0].Path != "example.com/dep" {
+			t.Fatalf("got export data packages %v, want example.com/dep", result.ExportData)
+		}
+		// The import and the reference of the variable declaration are
+		// known, unlike that of the body of F.
+		if got := len(result.ExportData[0].References); got != 2 {
+			t.Errorf("got %d references in example.com/dep, want 2", got)
+		}
+		for _, f := range result.Files {
+			if !strings.HasPrefix(string(f.URI), string(env.Sandbox.Workdir.URI(""))) {
+				t.Errorf("edits of %s, outside the workspace", f.URI)
+			}
+		}
+
+		env.Rename("a/a.go", pos, "Bar")
+		env.Await(ShownMessage("packages loaded only from export data refer to Foo and are not renamed: example.com/dep (dep.go:3:8, dep.go:5:11)"))
+		env.RegexpSearch("main.go", "a.Bar")
+
+		env.Rename("a/a.go", env.RegexpSearch("a/a.go", "package (a)"), "b")
+		env.Await(ShownMessage("packages loaded only from export data refer to mod.com/a and are not renamed: example.com/dep (dep.go:3:8)"))
+	})
+}
+
+func TestRenameExternalReferences(t *testing.T) {
+	const proxy = `
+-- example.com@v1.2.3/go.mod --
+module example.com
+
+go 1.12
+-- example.com@v1.2.3/dep/dep.go --
+package dep
+
+import "mod.com/a"
+
+var V = a.Foo
+
+func F() int { return a.Foo }
+`
+	const files = `
+-- main/go.mod --
+module mod.com
+
+go 1.12
+
+require example.com v1.2.3
+-- main/go.sum --
+example.com v1.2.3 h1:1qJPO6oIGxnPSLlKoTsryfRiU4n1AcW1oMysSK/6/bs=
+example.com v1.2.3/go.mod h1:Y2Rc5rVWjWur0h3pd9aEvK5Pof8YKDANh9gHA2Maujo=
+-- main/a/a.go --
+package a
+
+const Foo = 1
+
+const foo = Foo
+-- main/main.go --
+package main
+
+import (
+	"example.com/dep"
+	"mod.com/a"
+)
+
+var _ = dep.V + a.Foo
+-- other/go.mod --
+module other.com
+
+go 1.12
+
+require mod.com v0.0.0
+
+replace mod.com => ../main
+-- other/go.sum --
+example.com v1.2.3 h1:1qJPO6oIGxnPSLlKoTsryfRiU4n1AcW1oMysSK/6/bs=
+example.com v1.2.3/go.mod h1:Y2Rc5rVWjWur0h3pd9aEvK5Pof8YKDANh9gHA2Maujo=
+-- other/o.go --
+package other
+
+import "mod.com/a"
+
+var X, Y = a.Foo, a.Foo + 1
+`
+	want := []command.ExternalReferenceCount{
+		{Package: "example.com/dep", Scope: "outside the workspace", References: 2},
+		{Package: "other.com", Scope: "other", References: 2},
+	}
+	beginRename := func(env *Env, params protocol.RenameParams) command.BeginRenameResult {
+		t.Helper()
+		cmd, err := command.NewBeginRenameCommand("", params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var begin command.BeginRenameResult
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   command.BeginRename.ID(),
+			Arguments: cmd.Arguments,
+		}, &begin)
+		if diff := cmp.Diff(want, begin.Preview.ExternalReferences); diff != "" {
+			t.Errorf("external references of the preview (-want +got):\n%s", diff)
+		}
+		return begin
+	}
+	commitParams := func(token string) *protocol.ExecuteCommandParams {
+		t.Helper()
+		cmd, err := command.NewCommitRenameCommand("", command.CommitRenameArgs{Token: token})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &protocol.ExecuteCommandParams{Command: command.CommitRename.ID(), Arguments: cmd.Arguments}
+	}
+
+	t.Run("refused", func(t *testing.T) {
+		WithOptions(
+			Modes(Default),
+			ProxyFiles(proxy),
+			WorkspaceFolders("main", "other"),
+		).Run(t, files, func(t *testing.T, env *Env) {
+			env.OpenFile("main/a/a.go")
+			params := protocol.RenameParams{
+				TextDocument: env.Editor.TextDocumentIdentifier("main/a/a.go"),
+				Position:     env.RegexpSearch("main/a/a.go", "Foo").ToProtocolPosition(),
+				NewName:      "Bar",
+			}
+			const wantErr = "renaming would break 4 references outside its edit scope, in example.com/dep (outside the workspace): 2, other.com (other): 2"
+			if _, err := env.Editor.Server.Rename(env.Ctx, &params); err == nil || !strings.Contains(err.Error(), wantErr) {
+				t.Errorf("renaming Foo: got error %v, want %q", err, wantErr)
+			}
+			begin := beginRename(env, params)
+			if _, err := env.Editor.ExecuteCommand(env.Ctx, commitParams(begin.Token)); err == nil || !strings.Contains(err.Error(), wantErr) {
+				t.Errorf("committing the rename of Foo: got error %v, want %q", err, wantErr)
+			}
+			env.RegexpSearch("main/a/a.go", "const Foo")
+		})
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		WithOptions(
+			Modes(Default),
+			ProxyFiles(proxy),
+			WorkspaceFolders("main", "other"),
+			Settings{"rename": map[string]interface{}{"allowBreakingExternal": true}},
+		).Run(t, files, func(t *testing.T, env *Env) {
+			env.OpenFile("main/a/a.go")
+			params := protocol.RenameParams{
+				TextDocument: env.Editor.TextDocumentIdentifier("main/a/a.go"),
+				Position:     env.RegexpSearch("main/a/a.go", "Foo").ToProtocolPosition(),
+				NewName:      "Bar",
+			}
+			begin := beginRename(env, params)
+			var commit command.CommitRenameResult
+			env.ExecuteCommand(commitParams(begin.Token), &commit)
+			if diff := cmp.Diff(want, commit.ExternalReferences); diff != "" {
+				t.Errorf("external references of the result (-want +got):\n%s", diff)
+			}
+			env.RegexpSearch("main/a/a.go", "const Bar")
+
+			// Unexported objects have no external references.
+			params.Position = env.RegexpSearch("main/a/a.go", "foo").ToProtocolPosition()
+			params.NewName = "baz"
+			cmd, err := command.NewDryRunRenameCommand("", params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var dryRun command.DryRunRenameResult
+			env.ExecuteCommand(&protocol.ExecuteCommandParams{
+				Command:   command.DryRunRename.ID(),
+				Arguments: cmd.Arguments,
+			}, &dryRun)
+			if len(dryRun.ExternalReferences) > 0 {
+				t.Errorf("got external references %v for an unexported constant", dryRun.ExternalReferences)
+			}
+		})
+	})
+}
+
+func TestVerifyRename(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+func F() int {
+	x := 1
+	y := 2
+	return x + y
+}
+-- b/b.go --
+package b
+
+import "mod.com/a"
+
+var _ = a.F()
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		verify := func(re, newName string, annotations ...string) command.VerifyRenameResult {
+			t.Helper()
+			pos := env.RegexpSearch("a/a.go", re)
+			cmd, err := command.NewVerifyRenameCommand("", command.VerifyRenameArgs{
+				Params: protocol.RenameParams{
+					TextDocument: protocol.TextDocumentIdentifier{URI: env.Sandbox.Workdir.URI("a/a.go")},
+					Position:     pos.ToProtocolPosition(),
+					NewName:      newName,
+				},
+				Annotations: annotations,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var result command.VerifyRenameResult
+			env.ExecuteCommand(&protocol.ExecuteCommandParams{
+				Command:   command.VerifyRename.ID(),
+				Arguments: cmd.Arguments,
+			}, &result)
+			return result
+		}
+
+		if result := verify("func (F)", "G"); len(result.Errors) != 0 {
+			t.Errorf("renaming F to G: got errors %v, want none", result.Errors)
+		}
+		result := verify("(x) :=", "y", "conflict")
+		if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, "no new variables") {
+			t.Fatalf("renaming x to y despite the conflict: got errors %v, want no new variables", result.Errors)
+		}
+		if got := result.Errors[0].Location.URI; got != env.Sandbox.Workdir.URI("a/a.go") {
+			t.Errorf("got error in %s, want a/a.go", got)
+		}
+
+		// The files on disk are not changed.
+		if got := env.ReadWorkspaceFile("a/a.go"); !strings.Contains(got, "x := 1") {
+			t.Errorf("a/a.go was changed on disk:\n%s", got)
+		}
+	})
+}
+
+func TestRenameFormat(t *testing.T) {
+	testenv.NeedsGo1Point(t, 17)
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- b/b.go --
+package b
+
+const B = 1
+-- lib/a.go --
+package lib
+
+const A = 1
+-- main.go --
+package main
+
+import (
+	"mod.com/b"
+	"mod.com/lib"
+)
+
+func main() {
+	println(b.B, lib.A)
+}
+`
+	for _, format := range []bool{false, true} {
+		t.Run(fmt.Sprint(format), func(t *testing.T) {
+			WithOptions(
+				Settings{"renameFormat": format},
+			).Run(t, files, func(t *testing.T, env *Env) {
+				env.OpenFile("lib/a.go")
+				pos := env.RegexpSearch("lib/a.go", "lib")
+				env.Rename("lib/a.go", pos, "a")
+
+				want := "\"mod.com/b\"\n\t\"mod.com/a\""
+				if format {
+					want = "\"mod.com/a\"\n\t\"mod.com/b\""
+				}
+				if got := env.Editor.BufferText("main.go"); !strings.Contains(got, want) {
+					t.Errorf("renameFormat=%v: got main.go\n%s\nwant imports %s", format, got, want)
+				}
+			})
+		})
+	}
+}
+
+func TestRenameFieldAlignment(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+type T struct {
+	A    int    // a
+	Bcde string // bcde
+}
+
+var t = T{
+	A:    1,
+	Bcde: "x",
+}
+
+var _ = struct {
+	X  int
+	Yz int
+}{}
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		env.Rename("a/a.go", env.RegexpSearch("a/a.go", "(A) +int"), "Abcdefg")
+
+		const want = `package a
+
+type T struct {
+	Abcdefg int    // a
+	Bcde    string // bcde
+}
+
+var t = T{
+	Abcdefg: 1,
+	Bcde:    "x",
+}
+
+var _ = struct {
+	X  int
+	Yz int
+}{}
+`
+		if got := env.Editor.BufferText("a/a.go"); got != want {
+			t.Errorf("a/a.go after rename:\n%s", compare.Text(want, got))
+		}
+	})
+}
+
+func TestDryRunRenameSkippedImplementations(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+import "errors"
+
+type Failure interface{ Error() string }
+
+type A struct{}
+
+func (A) Error() string { return "" }
+
+var _ = errors.New
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		pos := env.RegexpSearch("a/a.go", "interface{ (Error)")
+		cmd, err := command.NewDryRunRenameCommand("", protocol.RenameParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: env.Sandbox.Workdir.URI("a/a.go")},
+			Position:     pos.ToProtocolPosition(),
+			NewName:      "Message",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result command.DryRunRenameResult
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   command.DryRunRename.ID(),
+			Arguments: cmd.Arguments,
+		}, &result)
+
+		// The implementations in the standard library, which errors imports,
+		// are left unchanged.
+		if len(result.Skipped) == 0 {
+			t.Error("no skipped implementations, want those of the standard library")
+		}
+		for _, skipped := range result.Skipped {
+			if skipped.Reason != "in the standard library" || skipped.Location.URI == "" {
+				t.Errorf("got skipped implementation %+v, want one in the standard library", skipped)
+			}
+		}
+		var ids []string
+		for _, a := range result.Annotations {
+			ids = append(ids, a.ID)
+		}
+		if diff := cmp.Diff([]string{"implementations/0"}, ids); diff != "" {
+			t.Errorf("annotations mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestRenameHistory(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+func Hello() {}
+-- b/b.go --
+package b
+
+import "mod.com/a"
+
+func _() { a.Hello() }
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		env.Rename("a/a.go", env.RegexpSearch("a/a.go", "Hello"), "Greet")
+		env.Rename("a/a.go", env.RegexpSearch("a/a.go", "Greet"), "Welcome")
+
+		// The server applies the edits of a committed rename session
+		// itself.
+		cmd, err := command.NewBeginRenameCommand("", protocol.RenameParams{
+			TextDocument: env.Editor.TextDocumentIdentifier("a/a.go"),
+			Position:     env.RegexpSearch("a/a.go", "Welcome").ToProtocolPosition(),
+			NewName:      "Salute",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var begin command.BeginRenameResult
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   command.BeginRename.ID(),
+			Arguments: cmd.Arguments,
+		}, &begin)
+		if cmd, err = command.NewCommitRenameCommand("", command.CommitRenameArgs{Token: begin.Token}); err != nil {
+			t.Fatal(err)
+		}
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   command.CommitRename.ID(),
+			Arguments: cmd.Arguments,
+		}, nil)
+
+		cmd, err = command.NewRenameHistoryCommand("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result command.RenameHistoryResult
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   command.RenameHistory.ID(),
+			Arguments: cmd.Arguments,
+		}, &result)
+
+		var got []string
+		for _, r := range result.Renames {
+			var files []string
+			for _, uri := range r.Files {
+				files = append(files, env.Sandbox.Workdir.URIToPath(uri))
+			}
+			got = append(got, fmt.Sprintf("%s -> %s: %s (applied: %t)", r.OldName, r.NewName, strings.Join(files, ", "), r.Applied))
+			if r.Time == "" {
+				t.Errorf("rename %s -> %s has no time", r.OldName, r.NewName)
+			}
+		}
+		// The edits of textDocument/rename are recorded as computed,
+		// whether or not the client applies them.
+		want := []string{
+			"Hello -> Greet: a/a.go, b/b.go (applied: false)",
+			"Greet -> Welcome: a/a.go, b/b.go (applied: false)",
+			"Welcome -> Salute: a/a.go, b/b.go (applied: true)",
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("rename history mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestRenameSession(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+// Hello says hello.
+func Hello() {}
+
+func _() { Hello() }
+
+func Other() {}
+
+type T struct {
+	A   int
+	Bcd int
+}
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		begin := func(re, newName string) command.BeginRenameResult {
+			t.Helper()
+			pos := env.RegexpSearch("a/a.go", re)
+			cmd, err := command.NewBeginRenameCommand("", protocol.RenameParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: env.Sandbox.Workdir.URI("a/a.go")},
+				Position:     pos.ToProtocolPosition(),
+				NewName:      newName,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var result command.BeginRenameResult
+			env.ExecuteCommand(&protocol.ExecuteCommandParams{
+				Command:   command.BeginRename.ID(),
+				Arguments: cmd.Arguments,
+			}, &result)
+			return result
+		}
+		commitParams := func(token string, annotations ...string) *protocol.ExecuteCommandParams {
+			t.Helper()
+			cmd, err := command.NewCommitRenameCommand("", command.CommitRenameArgs{
+				Token:       token,
+				Annotations: annotations,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return &protocol.ExecuteCommandParams{
+				Command:   command.CommitRename.ID(),
+				Arguments: cmd.Arguments,
+			}
+		}
+
+		session := begin("func (Hello)", "Greet")
+		if session.Token == "" || len(session.Preview.Files) != 1 {
+			t.Fatalf("got session %+v, want a token and a preview of the edits of a.go", session)
+		}
+		var result command.CommitRenameResult
+		env.ExecuteCommand(commitParams(session.Token, "comments"), &result)
+		if result.Revalidated {
+			t.Error("unchanged workspace: the commit reported a change")
+		}
+		env.RegexpSearch("a/a.go", "// Greet says hello.")
+		env.RegexpSearch("a/a.go", "_\\(\\) { Greet\\(\\) }")
+		if _, err := env.Editor.ExecuteCommand(env.Ctx, commitParams(session.Token)); err == nil {
+			t.Error("committing a session twice succeeded")
+		}
+
+		// A change of the workspace since the session began causes the
+		// rename to be validated again.
+		session = begin("func (Greet)", "Welcome")
+		env.EditBuffer("a/a.go", fake.NewEdit(5, 0, 5, 0, "var _ = 1\n"))
+		env.ExecuteCommand(commitParams(session.Token), &result)
+		if !result.Revalidated {
+			t.Error("changed workspace: the commit reported no change")
+		}
+		env.RegexpSearch("a/a.go", "_\\(\\) { Welcome\\(\\) }")
+
+		// The committed edits are those of a plain rename: the fields
+		// are aligned again, and conflicts, which the preview reports,
+		// fail the commit.
+		session = begin("(A) +int", "Abcdef")
+		env.ExecuteCommand(commitParams(session.Token), &result)
+		env.RegexpSearch("a/a.go", "\tAbcdef int\n\tBcd    int")
+		session = begin("func (Welcome)", "Other")
+		if _, err := env.Editor.ExecuteCommand(env.Ctx, commitParams(session.Token)); err == nil || !strings.Contains(err.Error(), "conflicts with") {
+			t.Errorf("committing a conflicting rename: got error %v, want a conflict", err)
+		}
+		env.RegexpSearch("a/a.go", "_\\(\\) { Welcome\\(\\) }")
+	})
+}
+
+func TestRenameVersionedEdits(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+func Hello() {}
+-- a/b.go --
+package a
+
+func _() { Hello() }
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		env.EditBuffer("a/a.go", fake.NewEdit(3, 0, 3, 0, "var _ = 1\n"))
+		pos := env.RegexpSearch("a/a.go", "func (Hello)")
+		edit, err := env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+			TextDocument: env.Editor.TextDocumentIdentifier("a/a.go"),
+			Position:     pos.ToProtocolPosition(),
+			NewName:      "Greet",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		versions := make(map[string]int32)
+		for _, c := range edit.DocumentChanges {
+			if c.TextDocumentEdit != nil {
+				doc := c.TextDocumentEdit.TextDocument
+				versions[env.Sandbox.Workdir.URIToPath(doc.URI)] = doc.Version
+			}
+		}
+		want := map[string]int32{
+			"a/a.go": int32(env.Editor.BufferVersion("a/a.go")),
+			"a/b.go": 0, // not open: the version of the file on disk
+		}
+		if diff := cmp.Diff(want, versions); diff != "" {
+			t.Errorf("unexpected document versions (-want +got):\n%s", diff)
+		}
+
+		// Once the buffer changed, the edits no longer apply.
+		env.EditBuffer("a/a.go", fake.NewEdit(3, 0, 3, 0, "var _ = 2\n"))
+		if _, err := env.Editor.Client().ApplyEdit(env.Ctx, &protocol.ApplyWorkspaceEditParams{Edit: *edit}); err == nil {
+			t.Error("edits of an older version of a.go were applied")
+		}
+		if got := env.Editor.BufferText("a/a.go"); strings.Contains(got, "Greet") {
+			t.Errorf("a.go was renamed on a changed buffer:\n%s", got)
+		}
+	})
+}
+
+func TestRenameFileChangedOnDisk(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+func Hello() {}
+-- a/b.go --
+package a
+
+func _() { Hello() }
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		env.Await(env.DoneWithOpen())
+
+		// Modify b.go on disk without notifying the server, as another
+		// program may do before the client reports the change.
+		path := env.Sandbox.Workdir.AbsPath("a/b.go")
+		if err := os.WriteFile(path, []byte("package a\n\n// moved\nfunc _() { Hello() }\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		pos := env.RegexpSearch("a/a.go", "func (Hello)")
+		_, err := env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+			TextDocument: env.Editor.TextDocumentIdentifier("a/a.go"),
+			Position:     pos.ToProtocolPosition(),
+			NewName:      "Greet",
+		})
+		if err == nil || !strings.Contains(err.Error(), "file changed on disk") || !strings.Contains(err.Error(), "b.go") {
+			t.Errorf("Rename: got error %v, want b.go reported as changed on disk", err)
+		}
+	})
+}
+
+func TestRenameSettingsSection(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+type Shape interface{ Area() int }
+
+type Square struct{}
+
+// Area returns the area.
+func (Square) Area() int { return 0 }
+-- a/a_gen.go --
+// Code generated by shapegen. DO NOT EDIT.
+
+package a
+
+var _ = Square{}.Area
+-- third_party/t/t.go --
+package t
+
+import "mod.com/a"
+
+var _ = a.Square{}
+`
+	WithOptions(
+		HonorsChangeAnnotations(),
+		Settings{"rename": map[string]interface{}{
+			"includeImplementations": false,
+			"generatedFilePolicy":    "skip",
+			"inComments":             false,
+			"excludePaths":           []interface{}{"third_party"},
+		}},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		rename := func(re, newName string) (*protocol.WorkspaceEdit, error) {
+			pos := env.RegexpSearch("a/a.go", re)
+			return env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+				TextDocument: env.Editor.TextDocumentIdentifier("a/a.go"),
+				Position:     pos.ToProtocolPosition(),
+				NewName:      newName,
+			})
+		}
+
+		edit, err := rename("func \\(Square\\) (Area)", "Size")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var files []string
+		for _, c := range edit.DocumentChanges {
+			if c.TextDocumentEdit != nil {
+				path := env.Sandbox.Workdir.URIToPath(c.TextDocumentEdit.TextDocument.URI)
+				files = append(files, fmt.Sprintf("%s:%d", path, len(c.TextDocumentEdit.Edits)))
+			}
+		}
+		// Neither the doc comment, nor the generated file, nor the
+		// interface method is renamed.
+		if diff := cmp.Diff([]string{"a/a.go:1"}, files); diff != "" {
+			t.Errorf("unexpected edited files (-want +got):\n%s", diff)
+		}
+		if len(edit.ChangeAnnotations) > 0 {
+			t.Errorf("got annotations %v, want none", edit.ChangeAnnotations)
+		}
+
+		if _, err := rename("type (Square)", "Cube"); err == nil || !strings.Contains(err.Error(), "third_party/t/t.go") {
+			t.Errorf("renaming Square: got error %v, want third_party/t/t.go reported as excluded", err)
+		}
+	})
+}
+
+func TestRenameInPlatformWrappers(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+type Handle int
+-- a/zz_generated.deepcopy.go --
+// Code generated by controller-gen. DO NOT EDIT.
+
+package a
+
+func (in Handle) DeepCopy() Handle { return in }
+-- a/handle_string.go --
+// Code generated by stringer. DO NOT EDIT.
+
+package a
+
+func (h Handle) String() string { return "" }
+`
+	WithOptions(
+		HonorsChangeAnnotations(),
+		Settings{"renameGeneratedFilePolicy": "skip"},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		pos := env.RegexpSearch("a/a.go", "type (Handle)")
+		edit, err := env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+			TextDocument: env.Editor.TextDocumentIdentifier("a/a.go"),
+			Position:     pos.ToProtocolPosition(),
+			NewName:      "Fd",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := protocol.ChangeAnnotation{
+			Label:       "Regenerate recommended",
+			Description: "2 edits in zz_generated.deepcopy.go, generated by controller-gen: regenerate them once renamed",
+		}
+		if got := edit.ChangeAnnotations["regenerate/0"]; got != want {
+			t.Errorf("regenerate annotation = %+v, want %+v", got, want)
+		}
+
+		// The platform wrapper is renamed despite the policy, unlike the
+		// other generated file.
+		env.Rename("a/a.go", pos, "Fd")
+		env.Await(ShownMessage("generated files are not renamed: handle_string.go"))
+		env.OpenFile("a/zz_generated.deepcopy.go")
+		env.RegexpSearch("a/zz_generated.deepcopy.go", `func \(in Fd\) DeepCopy\(\) Fd`)
+		env.OpenFile("a/handle_string.go")
+		env.RegexpSearch("a/handle_string.go", `func \(h Handle\)`)
+	})
+}
+
+func TestRenameSkipTestFiles(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+func Hello() {}
+-- a/a_test.go --
+package a
+
+func helper() { Hello() }
+`
+	WithOptions(
+		Settings{"rename": map[string]interface{}{"skipTestFiles": true}},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		env.Rename("a/a.go", env.RegexpSearch("a/a.go", "func (Hello)"), "Greet")
+		env.Await(ShownMessage("test files are not renamed: a_test.go"))
+		env.RegexpSearch("a/a.go", "func Greet")
+		if got := env.ReadWorkspaceFile("a/a_test.go"); !strings.Contains(got, "Hello()") {
+			t.Errorf("a_test.go was renamed:\n%s", got)
+		}
+
+		env.OpenFile("a/a_test.go")
+		pos := env.RegexpSearch("a/a_test.go", "func (helper)")
+		if err := env.Editor.Rename(env.Ctx, "a/a_test.go", pos, "assist"); err == nil {
+			t.Error("renaming a function declared in a test file succeeded")
+		}
+	})
+}
+
+func TestRenameOpenFilesOnly(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+func Hello() {}
+-- a/b.go --
+package a
+
+func _() { Hello() }
+`
+	WithOptions(
+		Settings{"renameOpenFilesOnly": true},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		env.Rename("a/a.go", env.RegexpSearch("a/a.go", "func (Hello)"), "Greet")
+		env.Await(ShownMessage("1 occurrences in files not open in the editor are left unchanged"))
+		env.RegexpSearch("a/a.go", "func Greet")
+		if got := env.ReadWorkspaceFile("a/b.go"); !strings.Contains(got, "Hello()") {
+			t.Errorf("b.go, not open, was renamed:\n%s", got)
+		}
+
+		cmd, err := command.NewRenameRemainderCommand("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result command.RenameRemainderResult
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   command.RenameRemainder.ID(),
+			Arguments: cmd.Arguments,
+		}, &result)
+		if result.OldName != "Hello" || result.NewName != "Greet" || len(result.Locations) != 1 {
+			t.Fatalf("got remainder %+v, want the occurrence of Hello in b.go", result)
+		}
+		if got := env.Sandbox.Workdir.URIToPath(result.Locations[0].URI); got != "a/b.go" {
+			t.Errorf("remaining occurrence in %s, want a/b.go", got)
+		}
+	})
+}
+
+func TestRenameOpenFilesOnlyMovedPackage(t *testing.T) {
+	testenv.NeedsGo1Point(t, 17)
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- lib/a.go --
+package lib
+
+const A = 1
+-- main.go --
+package main
+
+import "mod.com/lib"
+
+func main() {
+	println(lib.A)
+}
+`
+	WithOptions(
+		Settings{"renameOpenFilesOnly": true},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("lib/a.go")
+		pos := env.RegexpSearch("lib/a.go", "lib")
+		// Moving the directory of lib would break the import of
+		// main.go, which is not open.
+		err := env.Editor.Rename(env.Ctx, "lib/a.go", pos, "lib1")
+		if err == nil || !strings.Contains(err.Error(), "main.go") {
+			t.Fatalf("Rename with main.go closed: got error %v, want the refusal to break main.go", err)
+		}
+		if got := env.ReadWorkspaceFile("main.go"); !strings.Contains(got, `"mod.com/lib"`) {
+			t.Errorf("main.go was changed:\n%s", got)
+		}
+
+		// Once main.go is open, its import is edited with the move.
+		env.OpenFile("main.go")
+		env.Rename("lib/a.go", pos, "lib1")
+		env.RegexpSearch("lib1/a.go", "package lib1")
+		env.RegexpSearch("main.go", `"mod.com/lib1"`)
+	})
+}
+
+func TestRenameMaxPackages(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+func Hello() {}
+
+func hello() {}
+
+func _() { hello() }
+
+type T struct{}
+
+func (T) m() {}
+-- b/b.go --
+package b
+
+import "mod.com/a"
+
+func _() { a.Hello() }
+-- c/c.go --
+package c
+
+import "mod.com/a"
+
+func _() { a.Hello() }
+-- d/d.go --
+package d
+
+type I interface{ n() }
+-- e/e.go --
+package e
+
+type S struct{}
+
+func (S) p() {}
+
+func f(x interface{ q() }) {}
+`
+	WithOptions(
+		Settings{"renameMaxPackages": 2},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		// Hello is referred to by two packages besides its own.
+		err := env.Editor.Rename(env.Ctx, "a/a.go", env.RegexpSearch("a/a.go", "func (Hello)"), "Greet")
+		if err == nil || !strings.Contains(err.Error(), "would type check 3 packages, more than the 2") {
+			t.Errorf("Rename(Hello, Greet) returned error %v, want the scale of the rename", err)
+		}
+		err = env.Editor.Rename(env.Ctx, "a/a.go", env.RegexpSearch("a/a.go", "package (a)"), "z")
+		if err == nil || !strings.Contains(err.Error(), "renaming package a would type check 3 packages") {
+			t.Errorf("Rename(a, z) returned error %v, want the scale of the rename", err)
+		}
+		// Unexported objects are renamed in their package only, but their
+		// analysis loads the reverse dependencies of the package, for the
+		// comments mentioning them and the types assignable to interfaces,
+		// and all the known packages, for the implementations of an
+		// interface method, the interfaces coupling a concrete method to
+		// others and the twins of a method of an anonymous interface.
+		env.OpenFile("d/d.go")
+		env.OpenFile("e/e.go")
+		for _, test := range []struct {
+			file, re, from, to string
+			pkgs               int
+		}{
+			{"a/a.go", "func (hello)", "hello", "greet", 3},
+			{"a/a.go", `\) (m)\(\)`, "m", "m2", 3},
+			{"d/d.go", `interface\{ (n)\(\) \}`, "n", "n2", 5},
+			{"e/e.go", `\) (p)\(\)`, "p", "p2", 5},
+			{"e/e.go", `interface\{ (q)\(\) \}`, "q", "q2", 5},
+		} {
+			err := env.Editor.Rename(env.Ctx, test.file, env.RegexpSearch(test.file, test.re), test.to)
+			if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("renaming %s would type check %d packages", test.from, test.pkgs)) {
+				t.Errorf("Rename(%s, %s) returned error %v, want the scale of the packages it loads", test.from, test.to, err)
+			}
+		}
+	})
+	// The twins of a method of an anonymous interface are searched for
+	// even if its implementations are not.
+	WithOptions(
+		Settings{"renameMaxPackages": 2, "renameIncludeImplementations": false},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("e/e.go")
+		err := env.Editor.Rename(env.Ctx, "e/e.go", env.RegexpSearch("e/e.go", `interface\{ (q)\(\) \}`), "q2")
+		if err == nil || !strings.Contains(err.Error(), "renaming q would type check 5 packages") {
+			t.Errorf("Rename(q, q2) returned error %v, want the scale of the packages it loads", err)
+		}
+	})
+}
+
+func TestRenameVendored(t *testing.T) {
+	const files = `
+-- a/go.mod --
+module example.com/lib
+
+go 1.18
+-- a/lib.go --
+package lib
+
+func Do() {}
+-- b/go.mod --
+module mod.com/b
+
+go 1.18
+
+require example.com/lib v1.0.0
+-- b/vendor/modules.txt --
+# example.com/lib v1.0.0
+## explicit
+example.com/lib
+-- b/vendor/example.com/lib/lib.go --
+package lib
+
+func Do() {}
+-- b/main.go --
+package main
+
+import "example.com/lib"
+
+func main() { lib.Do() }
+`
+	// Vendoring is used by default, unless GOFLAGS says otherwise. It is
+	// not supported in experimental workspace module mode.
+	defaultModFlag := EnvVars{"GOFLAGS": ""}
+	rename := func(env *Env, path, re string) (*protocol.WorkspaceEdit, error) {
+		pos := env.RegexpSearch(path, re)
+		return env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+			TextDocument: env.Editor.TextDocumentIdentifier(path),
+			Position:     pos.ToProtocolPosition(),
+			NewName:      "Run",
+		})
+	}
+
+	t.Run("in vendor", func(t *testing.T) {
+		WithOptions(Modes(Default), WorkspaceFolders("b"), defaultModFlag).Run(t, files, func(t *testing.T, env *Env) {
+			env.OpenFile("b/main.go")
+			_, err := rename(env, "b/main.go", "lib.(Do)")
+			if err == nil || !strings.Contains(err.Error(), "vendor/example.com/lib/lib.go") {
+				t.Errorf("renaming a vendored function: got error %v, want its vendored file listed", err)
+			}
+		})
+	})
+
+	t.Run("vendored copies", func(t *testing.T) {
+		WithOptions(
+			Modes(Default),
+			WorkspaceFolders("a", "b"),
+			HonorsChangeAnnotations(),
+			defaultModFlag,
+		).Run(t, files, func(t *testing.T, env *Env) {
+			env.OpenFile("a/lib.go")
+			edit, err := rename(env, "a/lib.go", "func (Do)")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var vendorID string
+			for id, a := range edit.ChangeAnnotations {
+				if a.Label == "Rename vendored copies" {
+					vendorID = id
+				}
+			}
+			if vendorID == "" {
+				t.Fatalf("no annotation for the vendored copy among %v", edit.ChangeAnnotations)
+			}
+			var files []string
+			for _, c := range edit.DocumentChanges {
+				if c.TextDocumentEdit != nil && c.TextDocumentEdit.Edits[0].AnnotationID == vendorID {
+					files = append(files, env.Sandbox.Workdir.URIToPath(c.TextDocumentEdit.TextDocument.URI))
+				}
+			}
+			sort.Strings(files)
+			if diff := cmp.Diff([]string{"b/main.go", "b/vendor/example.com/lib/lib.go"}, files); diff != "" {
+				t.Errorf("unexpected files of the vendored copy (-want +got):\n%s", diff)
+			}
+		})
+	})
+
+	t.Run("vendored copies without annotations", func(t *testing.T) {
+		WithOptions(Modes(Default), WorkspaceFolders("a", "b"), defaultModFlag).Run(t, files, func(t *testing.T, env *Env) {
+			env.OpenFile("a/lib.go")
+			if _, err := rename(env, "a/lib.go", "func (Do)"); err == nil || !strings.Contains(err.Error(), "lib.go") {
+				t.Errorf("got error %v, want the vendored copies listed", err)
+			}
+		})
+	})
+}
+
+func TestRenameVendoredPackage(t *testing.T) {
+	const files = `
+-- a/go.mod --
+module mod.com/a
+
+go 1.18
+-- a/pkg/pkg.go --
+package pkg
+
+func Do() {}
+-- b/go.mod --
+module mod.com/b
+
+go 1.18
+
+require mod.com/a v1.0.0
+-- b/vendor/modules.txt --
+# mod.com/a v1.0.0
+## explicit
+mod.com/a/pkg
+-- b/vendor/mod.com/a/pkg/pkg.go --
+package pkg
+
+func Do() {}
+-- b/main.go --
+package main
+
+import "mod.com/a/pkg"
+
+func main() { pkg.Do() }
+`
+	WithOptions(
+		Modes(Default),
+		WorkspaceFolders("a", "b"),
+		HonorsChangeAnnotations(),
+		EnvVars{"GOFLAGS": ""},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/pkg/pkg.go")
+		pos := env.RegexpSearch("a/pkg/pkg.go", "package (pkg)")
+		edit, err := env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+			TextDocument: env.Editor.TextDocumentIdentifier("a/pkg/pkg.go"),
+			Position:     pos.ToProtocolPosition(),
+			NewName:      "util",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var vendorID string
+		for id, a := range edit.ChangeAnnotations {
+			if a.Label == "Update vendored copies" {
+				vendorID = id
+			}
+		}
+		if vendorID == "" {
+			t.Fatalf("no annotation for the vendoring among %v", edit.ChangeAnnotations)
+		}
+		var files []string
+		var renamed bool
+		for _, c := range edit.DocumentChanges {
+			switch {
+			case c.TextDocumentEdit != nil && c.TextDocumentEdit.Edits[0].AnnotationID == vendorID:
+				files = append(files, env.Sandbox.Workdir.URIToPath(c.TextDocumentEdit.TextDocument.URI))
+			case c.RenameFile != nil && c.RenameFile.AnnotationID == vendorID:
+				renamed = env.Sandbox.Workdir.URIToPath(c.RenameFile.OldURI) == "b/vendor/mod.com/a/pkg" &&
+					env.Sandbox.Workdir.URIToPath(c.RenameFile.NewURI) == "b/vendor/mod.com/a/util"
+			}
+		}
+		sort.Strings(files)
+		want := []string{"b/main.go", "b/vendor/mod.com/a/pkg/pkg.go", "b/vendor/modules.txt"}
+		if diff := cmp.Diff(want, files); diff != "" {
+			t.Errorf("unexpected files of the vendoring (-want +got):\n%s", diff)
+		}
+		if !renamed {
+			t.Errorf("the vendored copy of the package is not moved to b/vendor/mod.com/a/util")
+		}
+	})
+}
+
+func TestRenameReplaceDirectives(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+
+require example.com/other v1.0.0
+
+replace example.com/other => ./third_party/other
+-- main.go --
+package main
+
+import "example.com/other"
+
+func main() { other.Do() }
+-- third_party/other/go.mod --
+module example.com/other
+
+go 1.18
+-- third_party/other/other.go --
+package other
+
+func Do() {}
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		if err := env.Editor.WillRenameFiles(env.Ctx, "third_party", "deps"); err != nil {
+			t.Fatal(err)
+		}
+		env.OpenFile("go.mod")
+		if got, want := env.Editor.BufferText("go.mod"), "replace example.com/other => ./deps/other\n"; !strings.HasSuffix(got, want) {
+			t.Errorf("go.mod after directory rename: got\n%s\nwant it to end with\n%s", got, want)
+		}
+	})
+}
+
+func TestRenamePackageDoc(t *testing.T) {
+	testenv.NeedsGo1Point(t, 17)
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- lib/doc.go --
+// Package lib provides constants.
+package lib
+-- lib/a.go --
+/*
+Package lib defines A.
+*/
+package lib
+
+const A = 1
+-- lib/b.go --
+// Package library is not the package doc of lib.
+package lib
+
+const B = 1
+-- main.go --
+package main
+
+import "mod.com/lib"
+
+func main() {
+	println(lib.A)
+}
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("lib/a.go")
+		env.Rename("lib/a.go", env.RegexpSearch("lib/a.go", "package (lib)"), "util")
+
+		for file, want := range map[string]string{
+			"util/doc.go": "// Package util provides constants.\npackage util\n",
+			"util/a.go":   "/*\nPackage util defines A.\n*/\npackage util\n",
+			"util/b.go":   "// Package library is not the package doc of lib.\npackage util\n",
+		} {
+			env.OpenFile(file)
+			if got := env.Editor.BufferText(file); !strings.HasPrefix(got, want) {
+				t.Errorf("%s after package rename: got\n%s\nwant it to begin with\n%s", file, got, want)
+			}
+		}
+	})
+}
+
+func TestRenameDocMentions(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a.go --
+package a
+
+import "bytes"
+
+// Buffer wraps a bytes.Buffer. A Buffer is not a Buffers, a NewBuffer or
+// a Bufferé; see [Buffer] and a.Buffer.
+type Buffer struct{ b bytes.Buffer }
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a.go")
+		env.Rename("a.go", env.RegexpSearch("a.go", "type (Buffer)"), "Buf")
+		want := "// Buf wraps a bytes.Buffer. A Buf is not a Buffers, a NewBuffer or\n// a Bufferé; see [Buf] and a.Buf.\ntype Buf struct{ b bytes.Buffer }\n"
+		if got := env.Editor.BufferText("a.go"); !strings.HasSuffix(got, want) {
+			t.Errorf("after renaming Buffer: got\n%s\nwant it to end with\n%s", got, want)
+		}
+	})
+}
+
+func TestRenameCommentScope(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a.go --
+package a
+
+// Parse parses s, as "Parse" does in the example:
+//
+//	v := Parse(s)
+//
+// See https://example.com/Parse and [Parse].
+//
+// ` + "```" + `
+// Parse(x)
+// ` + "```" + `
+func Parse(s string) int { return 0 }
+`
+	for _, test := range []struct {
+		scope string
+		want  string
+	}{
+		{"prose", "// Decode parses s, as \"Parse\" does in the example:\n//\n//\tv := Parse(s)\n//\n// See https://example.com/Parse and [Decode].\n//\n// ```\n// Parse(x)\n// ```\nfunc Decode("},
+		{"all", "// Decode parses s, as \"Decode\" does in the example:\n//\n//\tv := Decode(s)\n//\n// See https://example.com/Decode and [Decode].\n//\n// ```\n// Decode(x)\n// ```\nfunc Decode("},
+	} {
+		t.Run(test.scope, func(t *testing.T) {
+			WithOptions(Settings{"renameCommentScope": test.scope}).Run(t, files, func(t *testing.T, env *Env) {
+				env.OpenFile("a.go")
+				env.Rename("a.go", env.RegexpSearch("a.go", "func (Parse)"), "Decode")
+				if got := env.Editor.BufferText("a.go"); !strings.Contains(got, test.want) {
+					t.Errorf("after renaming Parse: got\n%s\nwant it to contain\n%s", got, test.want)
+				}
+			})
+		})
+	}
+}
+
+func TestRenameSemanticComments(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+// Run runs the job. Run it again with [Run] if it fails.
+func Run() {}
+-- main.go --
+package main
+
+import "mod.com/a"
+
+// main calls a.Run, as Run is the job.
+func main() { a.Run() }
+`
+	WithOptions(Settings{"renameCommentScope": "semantic"}).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		env.Rename("a/a.go", env.RegexpSearch("a/a.go", "func (Run)"), "Start")
+		for file, want := range map[string]string{
+			"a/a.go":  "// Start runs the job. Run it again with [Start] if it fails.\nfunc Start() {}\n",
+			"main.go": "// main calls a.Start, as Run is the job.\nfunc main() { a.Start() }\n",
+		} {
+			env.OpenFile(file)
+			if got := env.Editor.BufferText(file); !strings.HasSuffix(got, want) {
+				t.Errorf("%s after renaming Run: got\n%s\nwant it to end with\n%s", file, got, want)
+			}
+		}
+	})
+}
+
+func TestRenameCommentsCRLF(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a.go --
+package a
+
+/*
+Foo does things.
+See [Foo] and the Foo method of T.
+*/
+func Foo() {}
+
+// Bar calls Foo,
+// as [Foo] does.
+func Bar() { Foo() }
+`
+	WithOptions(WindowsLineEndings()).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a.go")
+		env.Rename("a.go", env.RegexpSearch("a.go", "func (Foo)"), "Baz")
+		want := "package a\r\n\r\n/*\r\nBaz does things.\r\nSee [Baz] and the Baz method of T.\r\n*/\r\nfunc Baz() {}\r\n\r\n// Bar calls Foo,\r\n// as [Baz] does.\r\nfunc Bar() { Baz() }\r\n"
+		if got := env.Editor.BufferText("a.go"); got != want {
+			t.Errorf("after renaming Foo: got\n%q\nwant\n%q", got, want)
+		}
+	})
+}
+
+func TestRenameLineDirectives(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- parser.y --
+Parse is declared here.
+-- y.go --
+package a
+
+//line parser.y:1
+func Parse() int { return 1 }
+-- a.go --
+package a
+
+func use() int { return Parse() }
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a.go")
+		env.Rename("a.go", env.RegexpSearch("a.go", "(Parse)\\(\\)"), "Start")
+		env.Await(ShownMessage("files generated with //line directives are renamed, but not their sources: y.go (from parser.y)"))
+		env.OpenFile("y.go")
+		for file, want := range map[string]string{
+			"a.go": "func use() int { return Start() }",
+			"y.go": "//line parser.y:1\nfunc Start() int { return 1 }",
+		} {
+			if got := env.Editor.BufferText(file); !strings.Contains(got, want) {
+				t.Errorf("%s after renaming Parse: got\n%s\nwant it to contain\n%s", file, got, want)
+			}
+		}
+		if got := env.ReadWorkspaceFile("parser.y"); got != "Parse is declared here.\n" {
+			t.Errorf("parser.y was changed to %q", got)
+		}
+	})
+}
+
+func TestRenameInCgoFiles(t *testing.T) {
+	testenv.NeedsTool(t, "cgo")
+
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- cgo.go --
+package a
+
+/*
+int fortytwo() { return 42; }
+*/
+import "C"
+
+var offset = 1
+
+func Answer() int { return int(C.fortytwo()) + offset }
+
+//export Callback
+func Callback() { Answer() }
+-- a.go --
+package a
+
+func use() int { return offset }
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a.go")
+		env.Rename("a.go", env.RegexpSearch("a.go", "return (offset)"), "delta")
+		env.OpenFile("cgo.go")
+		want := "func Answer() int { return int(C.fortytwo()) + delta }"
+		if got := env.Editor.BufferText("cgo.go"); !strings.Contains(got, want) {
+			t.Errorf("cgo.go after renaming offset: got\n%s\nwant it to contain\n%s", got, want)
+		}
+
+		// The function that cgo generates for the exported Callback calls
+		// it, which the renaming cannot update.
+		env.Rename("cgo.go", env.RegexpSearch("cgo.go", "func (Callback)"), "Handler")
+		env.Await(ShownMessage("references only in the code generated by cgo are not renamed (1)"))
+		want = "//export Callback\nfunc Handler() { Answer() }"
+		if got := env.Editor.BufferText("cgo.go"); !strings.Contains(got, want) {
+			t.Errorf("cgo.go after renaming Callback: got\n%s\nwant it to contain\n%s", got, want)
+		}
+	})
+}
+
+func TestRenameBrokenBuffer(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+type T struct{}
+
+func (T) M() {}
+
+func Foo(t T) { t.M() }
+-- a/b.go --
+package a
+
+type I interface{ M() }
+
+var _ I = T{}
+`
+	const broken = `package a
+
+type T struct{}
+
+func (T) M() {}
+
+func Bar() {
+	println()
+	if x := 1; x > {
+	}
+}
+
+func Foo(t T) { t.M() }
+
+func Quux(t T) {
+	t.
+}
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		env.SetBufferContent("a/a.go", broken)
+		rename := func(re, newName string) error {
+			pos := env.RegexpSearch("a/a.go", re)
+			_, err := env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+				TextDocument: env.Editor.TextDocumentIdentifier("a/a.go"),
+				Position:     pos.ToProtocolPosition(),
+				NewName:      newName,
+			})
+			return err
+		}
+
+		// The unsaved syntax errors of Bar and Quux do not prevent renaming
+		// the declarations that follow them, nor checking the methods of
+		// the package against its interfaces.
+		env.Rename("a/a.go", env.RegexpSearch("a/a.go", "func (Foo)"), "Run")
+		env.Rename("a/a.go", env.RegexpSearch("a/a.go", "Quux\\((t)"), "u")
+		want := strings.NewReplacer("Foo(t T)", "Run(t T)", "Quux(t T) {\n\tt.", "Quux(u T) {\n\tu.").Replace(broken)
+		if got := env.Editor.BufferText("a/a.go"); got != want {
+			t.Errorf("after renaming: got\n%s\nwant\n%s", got, want)
+		}
+		if err := rename("func \\(T\\) (M)", "N"); err == nil || !strings.Contains(err.Error(), "no longer assignable to interface I") {
+			t.Errorf("renaming M: got error %v, want the conflict with I", err)
+		}
+
+		// Within the broken part of Bar, the errors are reported.
+		want = "cannot rename within a.go:9:2-11:2, which has syntax errors: 9:17: expected operand, found '{'"
+		if err := rename("x > ", "y"); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("renaming x in Bar: got error %v, want %q", err, want)
+		}
+	})
+}
+
+func TestRenameInGOPATH(t *testing.T) {
+	const files = `
+-- lib/a.go --
+package lib
+
+const A = 1
+-- lib/nested/a.go --
+package nested
+
+const C = 1
+-- app/main.go --
+package main
+
+import (
+	"lib"
+	"lib/nested"
+)
+
+func main() {
+	println(lib.A, nested.C)
+}
+`
+	WithOptions(
+		InGOPATH(),
+		EnvVars{"GO111MODULE": "off"},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("lib/a.go")
+		env.Rename("lib/a.go", env.RegexpSearch("lib/a.go", "A"), "B")
+		env.RegexpSearch("app/main.go", "lib.B")
+
+		env.Rename("lib/a.go", env.RegexpSearch("lib/a.go", "lib"), "lib1")
+		env.RegexpSearch("lib1/a.go", "package lib1")
+		env.RegexpSearch("app/main.go", `"lib1"`)
+		env.RegexpSearch("app/main.go", `"lib1/nested"`)
+		env.RegexpSearch("app/main.go", "lib1.B")
+	})
+}
+
+func TestRenameInStandaloneFiles(t *testing.T) {
+	testenv.NeedsGo1Point(t, 16) // Standalone files are only supported at Go 1.16 and later.
+
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.16
+-- lib/lib.go --
+package lib
+
+func F() int { return 1 }
+-- gen.go --
+//go:build ignore
+// +build ignore
+
+package main
+
+import "mod.com/lib"
+
+func helper() int { return lib.F() }
+
+func main() {
+	println(helper())
+}
+-- gen2.go --
+//go:build ignore
+// +build ignore
+
+package main
+
+func helper() {}
+
+func main() { helper() }
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("gen.go")
+		env.OpenFile("gen2.go")
+		env.OpenFile("lib/lib.go")
+
+		// References from open standalone files follow their imports.
+		env.Rename("lib/lib.go", env.RegexpSearch("lib/lib.go", "F"), "G")
+		env.RegexpSearch("gen.go", `lib\.G\(\)`)
+
+		// Each standalone file is a package of its own.
+		env.Rename("gen.go", env.RegexpSearch("gen.go", "helper"), "compute")
+		env.RegexpSearch("gen.go", "func compute")
+		env.RegexpSearch("gen.go", `println\(compute\(\)\)`)
+		env.RegexpSearch("gen2.go", "func helper")
+		env.RegexpSearch("gen2.go", `helper\(\) }`)
+
+		env.Rename("gen2.go", env.RegexpSearch("gen2.go", "helper"), "noop")
+		env.RegexpSearch("gen2.go", "func noop")
+		env.RegexpSearch("gen2.go", `noop\(\) }`)
+	})
+}
+
+func TestRenameInLocalReplacement(t *testing.T) {
+	const files = `
+-- main/go.mod --
+module mod.com
+
+go 1.18
+
+require example.com/dep v1.0.0
+
+replace example.com/dep => ../dep
+-- main/lib/lib.go --
+package lib
+
+func H() {}
+-- main/main.go --
+package main
+
+import "example.com/dep"
+
+func main() {
+	dep.F()
+}
+-- dep/go.mod --
+module example.com/dep
+
+go 1.18
+
+require mod.com v1.0.0
+
+replace mod.com => ../main
+-- dep/dep.go --
+package dep
+
+import "mod.com/lib"
+
+func F() {
+	lib.H()
+}
+`
+	WithOptions(
+		WorkspaceFolders("main"),
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("main/lib/lib.go")
+		env.Rename("main/lib/lib.go", env.RegexpSearch("main/lib/lib.go", "H"), "H2")
+		env.RegexpSearch("dep/dep.go", `lib\.H2\(\)`)
+
+		// The rename may be initiated from the replacement.
+		env.OpenFile("dep/dep.go")
+		env.Rename("dep/dep.go", env.RegexpSearch("dep/dep.go", "H2"), "H3")
+		env.RegexpSearch("main/lib/lib.go", "func H3")
+		env.RegexpSearch("dep/dep.go", `lib\.H3\(\)`)
+	})
+}
+
+func TestRenameDeclaredInLocalReplacement(t *testing.T) {
+	const files = `
+-- main/go.mod --
+module mod.com
+
+go 1.18
+
+require example.com/dep v1.0.0
+
+replace example.com/dep => ../dep
+-- main/lib/lib.go --
+package lib
+
+type I interface{ M() }
+-- main/main.go --
+package main
+
+import "example.com/dep"
+
+func main() {
+	dep.F()
+	dep.T{}.M()
+}
+-- dep/go.mod --
+module example.com/dep
+
+go 1.18
+
+require mod.com v1.0.0
+
+replace mod.com => ../main
+-- dep/dep.go --
+package dep
+
+import "mod.com/lib"
+
+type T struct{}
+
+func (T) M() {}
+
+var _ lib.I = T{}
+
+func F() {
+	T{}.M()
+}
+`
+	WithOptions(
+		WorkspaceFolders("main"),
+		HonorsChangeAnnotations(),
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("main/main.go")
+		env.Rename("main/main.go", env.RegexpSearch("main/main.go", `dep\.(F)`), "G")
+		env.RegexpSearch("dep/dep.go", "func G")
+		env.RegexpSearch("main/main.go", `dep\.G\(\)`)
+
+		// The implementations within the replacement are renamed too.
+		env.OpenFile("main/lib/lib.go")
+		env.Rename("main/lib/lib.go", env.RegexpSearch("main/lib/lib.go", "interface{ (M)"), "N")
+		env.RegexpSearch("dep/dep.go", `func \(T\) N\(\)`)
+		env.RegexpSearch("dep/dep.go", `T{}\.N\(\)`)
+		env.RegexpSearch("main/main.go", `dep\.T{}\.N\(\)`)
+	})
+}
+
+func TestRenameInGoWorkReplacement(t *testing.T) {
+	testenv.NeedsGo1Point(t, 18) // uses go.work
+
+	const files = `
+-- ws/go.work --
+go 1.18
+
+use ./main
+
+replace example.com/dep => ../dep
+-- ws/main/go.mod --
+module mod.com
+
+go 1.18
+
+require example.com/dep v1.0.0
+-- ws/main/lib/lib.go --
+package lib
+
+func H() {}
+-- ws/main/main.go --
+package main
+
+import "example.com/dep"
+
+func main() {
+	dep.F()
+}
+-- dep/go.mod --
+module example.com/dep
+
+go 1.18
+
+require mod.com v1.0.0
+-- dep/dep.go --
+package dep
+
+import "mod.com/lib"
+
+func F() {
+	lib.H()
+}
+`
+	WithOptions(
+		WorkspaceFolders("ws"),
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("ws/main/lib/lib.go")
+		env.Rename("ws/main/lib/lib.go", env.RegexpSearch("ws/main/lib/lib.go", "H"), "H2")
+		env.RegexpSearch("dep/dep.go", `lib\.H2\(\)`)
+	})
+}
+
+// checkTestdata checks that current buffer contents match their corresponding
+// expected content in the testdata directory.
+func checkTestdata(t *testing.T, env *Env) {
+	t.Helper()
+	files := env.ListFiles("testdata")
+	if len(files) == 0 {
+		t.Fatal("no files in testdata directory")
+	}
+	for _, file := range files {
+		suffix := strings.TrimPrefix(file, "testdata/")
+		got := env.Editor.BufferText(suffix)
+		want := env.ReadWorkspaceFile(file)
+		if diff := compare.Text(want, got); diff != "" {
+			t.Errorf("Rename: unexpected buffer content for %s (-want +got):\n%s", suffix, diff)
+		}
+	}
+}
+
+func TestFixStutteringNames(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- user/user.go --
+package user
+
+type UserID int
+
+func UserName(id UserID) string { return "" }
+
+// UserServer can't become Server, which exists.
+type UserServer struct{}
+
+type Server struct{}
+
+// UserKey and USERKey would both become Key.
+const (
+	UserKey = "key"
+	USERKey = "KEY"
+)
+
+var Username string
+-- main.go --
+package main
+
+import "mod.com/user"
+
+func main() {
+	_ = user.UserName(user.UserID(0))
+}
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		cmd, err := command.NewFixStutteringNamesCommand("", command.URIArg{URI: env.Sandbox.Workdir.URI("user/user.go")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result command.FixStutteringNamesResult
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   command.FixStutteringNames.ID(),
+			Arguments: cmd.Arguments,
+		}, &result)
+		var got []string
+		for _, r := range result.Renames {
+			desc := r.OldName + " -> " + r.NewName
+			if r.Reason != "" {
+				desc += " (skipped)"
+			}
+			got = append(got, desc)
+		}
+		want := []string{"USERKey -> Key (skipped)", "UserID -> ID", "UserKey -> Key (skipped)", "UserName -> Name", "UserServer -> Server (skipped)"}
+		if strings.Join(got, ", ") != strings.Join(want, ", ") {
+			t.Fatalf("FixStutteringNames: got %v, want %v", got, want)
+		}
+		var ids []string
+		for id := range result.Edit.ChangeAnnotations {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		if want := []string{"stutter/UserID", "stutter/UserName"}; strings.Join(ids, ", ") != strings.Join(want, ", ") {
+			t.Errorf("FixStutteringNames: annotations %v, want %v", ids, want)
+		}
+
+		if _, err := env.Editor.Client().ApplyEdit(env.Ctx, &protocol.ApplyWorkspaceEditParams{Edit: result.Edit}); err != nil {
+			t.Fatal(err)
+		}
+		if got := env.Editor.BufferText("main.go"); !strings.Contains(got, "user.Name(user.ID(0))") {
+			t.Errorf("FixStutteringNames: main.go once edited:\n%s", got)
+		}
+		if got := env.Editor.BufferText("user/user.go"); !strings.Contains(got, "func Name(id ID) string") || !strings.Contains(got, "type UserServer struct{}") {
+			t.Errorf("FixStutteringNames: user/user.go once edited:\n%s", got)
+		}
+	})
+}
+
+func TestRenameImportShadow(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.12
+-- main.go --
+package main
+
+import "net/url"
+
+func host(s string) string {
+	url, err := url.Parse(s)
+	if err != nil {
+		return ""
+	}
+	return url.Host
+}
+
+func main() {
+	url2 := host("https://golang.org")
+	println(url2)
+}
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("main.go")
+		pos := env.RegexpSearch("main.go", `url\.Host`)
+		rng := protocol.Range{Start: pos.ToProtocolPosition(), End: pos.ToProtocolPosition()}
+		actions, err := env.Editor.CodeAction(env.Ctx, "main.go", &rng, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// url2 is in use in the file, so the local is renamed to url3.
+		const title = "Rename url, which shadows an imported package, to url3"
+		var found bool
+		for _, action := range actions {
+			if action.Title == title {
+				env.ApplyCodeAction(action)
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("no code action %q among %v", title, actions)
+		}
+		want := "\turl3, err := url.Parse(s)\n\tif err != nil {\n\t\treturn \"\"\n\t}\n\treturn url3.Host\n"
+		if got := env.Editor.BufferText("main.go"); !strings.Contains(got, want) {
+			t.Errorf("main.go after rename: got\n%s\nwant it to contain\n%s", got, want)
+		}
+	})
+}
+
+func TestAlignReceiverNames(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.12
+-- server.go --
+package server
+
+type Server struct{ addr string }
+
+func (srv *Server) Addr() string { return srv.addr }
+
+func (s Server) Start() { _ = s.Addr() }
+
+func (*Server) Stop() {}
+
+func (_ Server) Close() {}
+-- handler.go --
+package server
+
+func (x *Server) Handle() { x.Stop() }
+`
+	WithOptions(
+		Settings{"renameReceiverName": "self"},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("server.go")
+		pos := env.RegexpSearch("server.go", `Start`)
+		rng := protocol.Range{Start: pos.ToProtocolPosition(), End: pos.ToProtocolPosition()}
+		actions, err := env.Editor.CodeAction(env.Ctx, "server.go", &rng, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var titles []string
+		var align protocol.CodeAction
+		for _, action := range actions {
+			if strings.HasPrefix(action.Title, "Rename the receivers of") {
+				titles = append(titles, action.Title)
+			}
+			if action.Title == "Rename the receivers of Server to s" {
+				align = action
+			}
+		}
+		want := []string{"Rename the receivers of Server to s", "Rename the receivers of Server to self"}
+		if diff := cmp.Diff(want, titles); diff != "" {
+			t.Fatalf("receiver alignment actions mismatch (-want +got):\n%s", diff)
+		}
+		env.ApplyCodeAction(align)
+		wantServer := `package server
+
+type Server struct{ addr string }
+
+func (s *Server) Addr() string { return s.addr }
+
+func (s Server) Start() { _ = s.Addr() }
+
+func (s *Server) Stop() {}
+
+func (s Server) Close() {}
+`
+		if got := env.Editor.BufferText("server.go"); got != wantServer {
+			t.Errorf("server.go after the alignment:\n%s\nwant:\n%s", got, wantServer)
+		}
+		if got, want := env.Editor.BufferText("handler.go"), "package server\n\nfunc (s *Server) Handle() { s.Stop() }\n"; got != want {
+			t.Errorf("handler.go after the alignment:\n%s\nwant:\n%s", got, want)
+		}
+	})
+}
+
+func TestRenameBuilderMethods(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+import "time"
+
+type Client struct{ timeout time.Duration }
+
+func (c *Client) WithTimeout(d time.Duration) *Client { c.timeout = d; return c }
+
+type ClientBuilder struct{ c Client }
+
+func (b *ClientBuilder) Timeout(d time.Duration) *ClientBuilder { b.c.timeout = d; return b }
+
+func (b *ClientBuilder) SetTimeout(d time.Duration) {}
+
+type Timer struct{}
+
+func (Timer) SetTimeout(c *Client) { c.timeout = 0 }
+`
+	WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		pos := env.RegexpSearch("a/a.go", "struct{ (timeout)")
+		edit, err := env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+			TextDocument: env.Editor.TextDocumentIdentifier("a/a.go"),
+			Position:     pos.ToProtocolPosition(),
+			NewName:      "deadline",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, a := range edit.ChangeAnnotations {
+			if strings.HasPrefix(a.Label, "Rename accessor") || strings.HasPrefix(a.Label, "Rename builder") {
+				got = append(got, a.Label+": "+a.Description)
+			}
+		}
+		sort.Strings(got)
+		// ClientBuilder.SetTimeout does not use the field, and Timer is
+		// not a builder type.
+		want := []string{
+			"Rename accessor: WithTimeout to WithDeadline",
+			"Rename builder method: ClientBuilder.Timeout to Deadline",
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("annotations mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestRenamePredeclaredNames(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- errs/errs.go --
+package errs
+
+type E struct{}
+
+func (E) Error() string { return "" }
+
+var count = 1
+
+func Len(s []int) int {
+	n := 0
+	n += len(s)
+	return n + count
+}
+
+func Size(s []int) int {
+	n := len(s)
+	return n
+}
+
+func copyAll(dst, src []int) int { return copy(dst, src) }
+
+func Copy(dst, src []int) int { return copyAll(dst, src) }
+-- main.go --
+package main
+
+import "mod.com/errs"
+
+func check(err error) {
+	_ = errs.E{}
+}
+
+func main() {
+	check(nil)
+}
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("errs/errs.go")
+		for _, test := range []struct {
+			re, newName, wantErr string
+		}{
+			// The package-level var would shadow the predeclared len.
+			{"var (count)", "len", "would shadow this reference\tto the predeclared builtin len"},
+			// The local would shadow the predeclared len.
+			{"(n) := 0", "len", "would shadow this reference\tto the predeclared builtin len"},
+			// The scope of the local starts after its declaration.
+			{"(n) := len", "len", ""},
+			// The exported func, which is no longer accessible as
+			// errs.copy, would also shadow the predeclared copy.
+			{"func (Copy)", "copy", "to the predeclared builtin copy"},
+		} {
+			pos := env.RegexpSearch("errs/errs.go", test.re)
+			err := env.Editor.Rename(env.Ctx, "errs/errs.go", pos, test.newName)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("renaming %q to %s: %v", test.re, test.newName, err)
+				}
+				continue
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("renaming %q to %s: got error %v, want it to contain %q", test.re, test.newName, err, test.wantErr)
+			}
+		}
+
+		// The importers of a package renamed to a predeclared name that
+		// they use import it under another name.
+		env.OpenFile("main.go")
+		env.Rename("errs/errs.go", env.RegexpSearch("errs/errs.go", "package (errs)"), "error")
+		want := "import error1 \"mod.com/error\"\n\nfunc check(err error) {\n\t_ = error1.E{}\n}"
+		if got := env.Editor.BufferText("main.go"); !strings.Contains(got, want) {
+			t.Errorf("main.go after the package rename:\n%s\nwant it to contain:\n%s", got, want)
+		}
+	})
+}
+
+func TestForceRenamePredeclaredConflict(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+func Size(s []int) int {
+	n := 0
+	n += len(s)
+	return n
+}
+`
+	WithOptions(
+		HonorsChangeAnnotations(),
+		Settings{"renameForce": true},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		pos := env.RegexpSearch("a/a.go", "(n) := 0")
+		edit, err := env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+			TextDocument: env.Editor.TextDocumentIdentifier("a/a.go"),
+			Position:     pos.ToProtocolPosition(),
+			NewName:      "len",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, ok := edit.ChangeAnnotations["conflict/0"]
+		if !ok || !a.NeedsConfirmation {
+			t.Fatalf("got annotations %v, want conflict/0 needing confirmation", edit.ChangeAnnotations)
+		}
+		if want := "to the predeclared builtin len"; !strings.Contains(a.Description, want) {
+			t.Errorf("conflict description %q does not contain %q", a.Description, want)
+		}
+	})
+}
+
+func TestRenameDotImportCollisions(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- lib/lib.go --
+package lib
+
+func Foo() int { return 1 }
+
+func Other() int { return 2 }
+-- bar/bar.go --
+package bar
+
+const X = 1
+-- a/a.go --
+package a
+
+import (
+	. "mod.com/lib"
+	Bar "mod.com/bar"
+)
+
+var _ = Foo() + Bar.X
+-- b/b.go --
+package b
+
+import . "mod.com/lib"
+
+func F() int {
+	Bar := Other()
+	return Foo() + Bar
+}
+-- c/c.go --
+package c
+
+import . "mod.com/lib"
+
+func G() int {
+	Bar := 1
+	return Foo() + Bar
+}
+`
+	const (
+		wantA = `package a
+
+import (
+	. "mod.com/lib"
+	Bar1 "mod.com/bar"
+)
+
+var _ = Bar() + Bar1.X
+`
+		wantB = `package b
+
+import . "mod.com/lib"
+import lib "mod.com/lib"
+
+func F() int {
+	Bar := Other()
+	return lib.Bar() + Bar
+}
+`
+		wantC = `package c
+
+import lib "mod.com/lib"
+
+func G() int {
+	Bar := 1
+	return lib.Bar() + Bar
+}
+`
+	)
+	t.Run("edits", func(t *testing.T) {
+		Run(t, files, func(t *testing.T, env *Env) {
+			env.OpenFile("lib/lib.go")
+			env.Rename("lib/lib.go", env.RegexpSearch("lib/lib.go", "func (Foo)"), "Bar")
+			for file, want := range map[string]string{"a/a.go": wantA, "b/b.go": wantB, "c/c.go": wantC} {
+				if got := env.Editor.BufferText(file); got != want {
+					t.Errorf("%s after rename:\n%s\nwant:\n%s", file, got, want)
+				}
+			}
+		})
+	})
+	t.Run("annotations", func(t *testing.T) {
+		WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+			env.OpenFile("lib/lib.go")
+			pos := env.RegexpSearch("lib/lib.go", "func (Foo)")
+			edit, err := env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+				TextDocument: env.Editor.TextDocumentIdentifier("lib/lib.go"),
+				Position:     pos.ToProtocolPosition(),
+				NewName:      "Bar",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, a := range edit.ChangeAnnotations {
+				if a.Label == "Resolve name collision" {
+					if !a.NeedsConfirmation {
+						t.Errorf("%s: NeedsConfirmation = false, want true", a.Description)
+					}
+					got = append(got, a.Description)
+				}
+			}
+			sort.Strings(got)
+			want := []string{
+				"the import of mod.com/bar in a.go is named Bar1, as Bar would collide with it",
+				"the references in b.go are qualified by lib, as Bar would be shadowed there",
+				"the references in c.go are qualified by lib, as Bar would be shadowed there",
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("collision annotations mismatch (-want +got):\n%s", diff)
+			}
+		})
+	})
+}
+
+func TestRenameDotImportDeclarationCollisions(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- lib/lib.go --
+package lib
+
+func Foo() int { return 1 }
+
+func Other() int { return 2 }
+-- other/other.go --
+package other
+
+const Bar = 3
+-- d/d.go --
+package d
+
+import . "mod.com/lib"
+
+func Bar() int { return Foo() + Other() }
+-- e/e.go --
+package e
+
+import (
+	. "mod.com/lib"
+	. "mod.com/other"
+)
+
+var _ = Other() + Bar
+`
+	const (
+		wantD = `package d
+
+import lib "mod.com/lib"
+
+func Bar() int { return lib.Bar() + lib.Other() }
+`
+		wantE = `package e
+
+import (
+	lib "mod.com/lib"
+	. "mod.com/other"
+)
+
+var _ = lib.Other() + Bar
+`
+	)
+	t.Run("edits", func(t *testing.T) {
+		Run(t, files, func(t *testing.T, env *Env) {
+			env.OpenFile("lib/lib.go")
+			env.Rename("lib/lib.go", env.RegexpSearch("lib/lib.go", "func (Foo)"), "Bar")
+			for file, want := range map[string]string{"d/d.go": wantD, "e/e.go": wantE} {
+				if got := env.Editor.BufferText(file); got != want {
+					t.Errorf("%s after rename:\n%s\nwant:\n%s", file, got, want)
+				}
+			}
+		})
+	})
+	t.Run("annotations", func(t *testing.T) {
+		WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+			env.OpenFile("lib/lib.go")
+			pos := env.RegexpSearch("lib/lib.go", "func (Foo)")
+			edit, err := env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+				TextDocument: env.Editor.TextDocumentIdentifier("lib/lib.go"),
+				Position:     pos.ToProtocolPosition(),
+				NewName:      "Bar",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, a := range edit.ChangeAnnotations {
+				if a.Label == "Resolve name collision" {
+					got = append(got, a.Description)
+				}
+			}
+			sort.Strings(got)
+			want := []string{
+				"the dot import of mod.com/lib in d.go is named lib, as Bar would collide with the package-level func Bar",
+				"the dot import of mod.com/lib in e.go is named lib, as Bar would collide with the const Bar dot-imported from mod.com/other",
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("collision annotations mismatch (-want +got):\n%s", diff)
+			}
+		})
+	})
+}
+
+func TestRenameToDotImportedName(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- lib/lib.go --
+package lib
+
+func Foo() int { return 1 }
+
+func Other() int { return 2 }
+-- p/p.go --
+package p
+
+import . "mod.com/lib"
+
+var X = 1
+
+func F() int {
+	y := 2
+	return Foo() + X + y + Other()
+}
+`
+	for _, test := range []struct {
+		name, re   string
+		want, desc string
+	}{
+		{
+			name: "package-level",
+			re:   "var (X)",
+			want: `package p
+
+import lib "mod.com/lib"
+
+var Foo = 1
+
+func F() int {
+	y := 2
+	return lib.Foo() + Foo + y + lib.Other()
+}
+`,
+			desc: "the dot import of mod.com/lib in p.go is named lib, as Foo would collide with the dot-imported func Foo",
+		},
+		{
+			name: "local",
+			re:   "(y) :=",
+			want: `package p
+
+import lib "mod.com/lib"
+
+var X = 1
+
+func F() int {
+	Foo := 2
+	return lib.Foo() + X + Foo + lib.Other()
+}
+`,
+			desc: "the dot import of mod.com/lib in p.go is named lib, as Foo would shadow the references to the dot-imported func Foo",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			Run(t, files, func(t *testing.T, env *Env) {
+				env.OpenFile("p/p.go")
+				env.Rename("p/p.go", env.RegexpSearch("p/p.go", test.re), "Foo")
+				if got := env.Editor.BufferText("p/p.go"); got != test.want {
+					t.Errorf("p.go after rename:\n%s\nwant:\n%s", got, test.want)
+				}
+			})
+			WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+				env.OpenFile("p/p.go")
+				pos := env.RegexpSearch("p/p.go", test.re)
+				edit, err := env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+					TextDocument: env.Editor.TextDocumentIdentifier("p/p.go"),
+					Position:     pos.ToProtocolPosition(),
+					NewName:      "Foo",
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got []string
+				for _, a := range edit.ChangeAnnotations {
+					if a.Label == "Resolve name collision" {
+						got = append(got, a.Description)
+					}
+				}
+				if diff := cmp.Diff([]string{test.desc}, got); diff != "" {
+					t.Errorf("collision annotations mismatch (-want +got):\n%s", diff)
+				}
+			})
+		})
+	}
+}
+
+func TestRenamePackageImportAliases(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- lib/a.go --
+package lib
+
+const A = 1
+-- other/nested/a.go --
+package nested
+
+const B = 1
+-- main.go --
+package main
+
+import (
+	"mod.com/lib"
+	"mod.com/other/nested"
+)
+
+func main() {
+	println(lib.A, nested.B)
+}
+-- b/b.go --
+package b
+
+import "mod.com/lib"
+
+var nested = lib.A
+`
+	t.Run("annotations", func(t *testing.T) {
+		WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+			env.OpenFile("lib/a.go")
+			pos := env.RegexpSearch("lib/a.go", "lib")
+			edit, err := env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+				TextDocument: env.Editor.TextDocumentIdentifier("lib/a.go"),
+				Position:     pos.ToProtocolPosition(),
+				NewName:      "nested",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, a := range edit.ChangeAnnotations {
+				if a.Label == "Alias import" {
+					if a.NeedsConfirmation {
+						t.Errorf("%s: NeedsConfirmation = true, want false", a.Description)
+					}
+					got = append(got, a.Description)
+				}
+			}
+			sort.Strings(got)
+			want := []string{
+				"b.go imports mod.com/nested as nested1: nested would collide with the package-level var nested",
+				"main.go imports mod.com/nested as nested1: nested would collide with the import of mod.com/other/nested",
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("alias annotations mismatch (-want +got):\n%s", diff)
+			}
+		})
+	})
+	t.Run("preferred", func(t *testing.T) {
+		WithOptions(
+			Settings{"renameImportAliases": map[string]interface{}{"mod.com/nested": "nst"}},
+		).Run(t, files, func(t *testing.T, env *Env) {
+			env.OpenFile("lib/a.go")
+			env.Rename("lib/a.go", env.RegexpSearch("lib/a.go", "lib"), "nested")
+			env.RegexpSearch("main.go", `nst "mod.com/nested"`)
+			env.RegexpSearch("main.go", `nst\.A, nested\.B`)
+			env.RegexpSearch("b/b.go", `var nested = nst\.A`)
+		})
+	})
+}
+
+func TestRenamePackageConstrainedImportAliases(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- lib/a.go --
+package lib
+
+const A = 1
+-- other/util/a.go --
+package util
+
+const B = 1
+-- a/on.go --
+package a
+
+import "mod.com/lib"
+
+var _ = lib.A
+-- a/off.go --
+//go:build off
+
+package a
+
+import (
+	"mod.com/lib"
+	"mod.com/other/util"
+)
+
+var _ = lib.A + util.B
+`
+	WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("lib/a.go")
+		pos := env.RegexpSearch("lib/a.go", "lib")
+		edit, err := env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+			TextDocument: env.Editor.TextDocumentIdentifier("lib/a.go"),
+			Position:     pos.ToProtocolPosition(),
+			NewName:      "util",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, a := range edit.ChangeAnnotations {
+			if a.Label == "Alias import" {
+				got = append(got, a.Description)
+			}
+		}
+		want := []string{"off.go imports mod.com/util as util1: util would collide with the import of mod.com/other/util"}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("alias annotations mismatch (-want +got):\n%s", diff)
+		}
+
+		env.Rename("lib/a.go", pos, "util")
+		env.RegexpSearch("a/on.go", `import "mod.com/util"`)
+		env.RegexpSearch("a/on.go", `util\.A`)
+		env.RegexpSearch("a/off.go", `util1 "mod.com/util"`)
+		env.RegexpSearch("a/off.go", `util1\.A \+ util\.B`)
+	})
+}
+
+func TestPrepareRenameReasons(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- main.go --
+package main
+
+type T int
+
+type S struct{ T }
+
+var _ = S{}.T
+
+func main() {}
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("main.go")
+		prepare := func(re string) command.PrepareRenameResult {
+			t.Helper()
+			cmd, err := command.NewPrepareRenameCommand("", protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: env.Sandbox.Workdir.URI("main.go")},
+				Position:     env.RegexpSearch("main.go", re).ToProtocolPosition(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var result command.PrepareRenameResult
+			env.ExecuteCommand(&protocol.ExecuteCommandParams{
+				Command:   command.PrepareRename.ID(),
+				Arguments: cmd.Arguments,
+			}, &result)
+			return result
+		}
+		for _, test := range []struct {
+			re, reason string
+		}{
+			{`package (main)`, "main_package"},
+			{`S\{\}\.(T)`, "ambiguous"},
+		} {
+			got := prepare(test.re)
+			if got.Reason != test.reason || got.Message == "" {
+				t.Errorf("PrepareRename(%s) = reason %q, message %q, want reason %q and a message", test.re, got.Reason, got.Message, test.reason)
+			}
+		}
+		if got := prepare(`type (T)`); got.Reason != "" || got.Placeholder != "T" {
+			t.Errorf("PrepareRename(T) = placeholder %q, reason %q, want placeholder T", got.Placeholder, got.Reason)
+		}
+	})
+}
+
+func TestRenamePackageClauseOnly(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- lib/a.go --
+package lib
+
+const A = 1
+-- main.go --
+package main
+
+import "mod.com/lib"
+
+func main() {
+	println(lib.A)
+}
+`
+	WithOptions(NoFileRenames()).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("lib/a.go")
+		env.Rename("lib/a.go", env.RegexpSearch("lib/a.go", "lib"), "nested")
+		env.Await(ShownMessage("only the package clauses and import names are renamed"))
+
+		env.RegexpSearch("lib/a.go", "package nested")
+		env.RegexpSearch("main.go", `import "mod.com/lib"`)
+		env.RegexpSearch("main.go", `nested\.A`)
+		if files := strings.Join(env.ListFiles("."), " "); files != "go.mod lib/a.go main.go" {
+			t.Errorf("after rename, files are %s, want the lib directory unchanged", files)
+		}
+	})
+}
+
+func TestRenamePackageModes(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- lib/a.go --
+package lib
+
+const A = 1
+-- main.go --
+package main
+
+import "mod.com/lib"
+
+func main() {
+	println(lib.A)
+}
+`
+	prepare := func(t *testing.T, env *Env) command.PrepareRenameResult {
+		t.Helper()
+		cmd, err := command.NewPrepareRenameCommand("", protocol.TextDocumentPositionParams{
+			TextDocument: env.Editor.TextDocumentIdentifier("lib/a.go"),
+			Position:     env.RegexpSearch("lib/a.go", "lib").ToProtocolPosition(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result command.PrepareRenameResult
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   command.PrepareRename.ID(),
+			Arguments: cmd.Arguments,
+		}, &result)
+		return result
+	}
+	t.Run("clause", func(t *testing.T) {
+		Run(t, files, func(t *testing.T, env *Env) {
+			env.OpenFile("lib/a.go")
+			if got, want := strings.Join(prepare(t, env).PackageModes, " "), "directory clause"; got != want {
+				t.Fatalf("PrepareRename(lib): package modes are %s, want %s", got, want)
+			}
+			cmd, err := command.NewRenamePackageCommand("", command.RenamePackageArgs{
+				Rename: protocol.RenameParams{
+					TextDocument: env.Editor.TextDocumentIdentifier("lib/a.go"),
+					Position:     env.RegexpSearch("lib/a.go", "lib").ToProtocolPosition(),
+					NewName:      "nested",
+				},
+				Mode: "clause",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			env.ExecuteCommand(&protocol.ExecuteCommandParams{
+				Command:   command.RenamePackage.ID(),
+				Arguments: cmd.Arguments,
+			}, nil)
+			env.RegexpSearch("lib/a.go", "package nested")
+			env.RegexpSearch("main.go", `import "mod.com/lib"`)
+			env.RegexpSearch("main.go", `nested\.A`)
+			if files := strings.Join(env.ListFiles("."), " "); files != "go.mod lib/a.go main.go" {
+				t.Errorf("after rename, files are %s, want the lib directory unchanged", files)
+			}
+		})
+	})
+	t.Run("no file renames", func(t *testing.T) {
+		WithOptions(NoFileRenames()).Run(t, files, func(t *testing.T, env *Env) {
+			env.OpenFile("lib/a.go")
+			if got, want := strings.Join(prepare(t, env).PackageModes, " "), "clause"; got != want {
+				t.Errorf("PrepareRename(lib): package modes are %s, want %s", got, want)
+			}
+		})
+	})
+}
+
+func TestRenameInterfaceAssertions(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+type Checker interface{ Check() }
+
+type T struct{}
+
+func (T) Check() {}
+
+var _ Checker = T{}
+
+var _Checker Checker = (*T)(nil)
+-- a/zz_generated_assertions.go --
+// Code generated by assertgen. DO NOT EDIT.
+
+package a
+
+var _ Checker = &T{}
+-- b/b.go --
+package b
+
+import "mod.com/a"
+
+type U struct{}
+
+func (U) Check() {}
+
+var _checkerU a.Checker = U{}
+`
+	WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		edit, err := env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+			TextDocument: env.Editor.TextDocumentIdentifier("a/a.go"),
+			Position:     env.RegexpSearch("a/a.go", "Checker interface").ToProtocolPosition(),
+			NewName:      "Validator",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for id, a := range edit.ChangeAnnotations {
+			if strings.HasPrefix(id, "assertions") {
+				if !a.NeedsConfirmation {
+					t.Errorf("%s: NeedsConfirmation = false, want true", a.Description)
+				}
+				got = append(got, a.Label+": "+a.Description)
+			}
+		}
+		sort.Strings(got)
+		want := []string{
+			"Rename assertion variable: _Checker to _Validator, which asserts that a type implements Validator",
+			"Rename assertion variable: _checkerU to _validatorU, which asserts that a type implements Validator",
+			"Rename in generated assertions: 1 compile-time assertions in zz_generated_assertions.go, which regenerating may revert",
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("assertion annotations mismatch (-want +got):\n%s", diff)
+		}
+		for _, c := range edit.DocumentChanges {
+			if c.TextDocumentEdit == nil || env.Sandbox.Workdir.URIToPath(c.TextDocumentEdit.TextDocument.URI) != "a/a.go" {
+				continue
+			}
+			for _, te := range c.TextDocumentEdit.Edits {
+				if te.NewText == "Validator" && te.AnnotationID != "" {
+					t.Errorf("edit of %v in a/a.go is annotated %s, want it required", te.Range, te.AnnotationID)
+				}
+			}
+		}
+	})
+}
+
+func TestRenameDeprecationNotices(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+// Foo does things.
+func Foo() {}
+
+// OldFoo does things.
+//
+// Deprecated: use Foo instead.
+func OldFoo() { Foo() }
+
+// Legacy is kept for compatibility.
+//
+// Deprecated: Foo replaces it; see [Foo].
+var Legacy = Foo
+
+type T struct{}
+
+// Get does nothing.
+func (T) Get() {}
+
+// Fetch does nothing.
+//
+// Deprecated: use Get.
+func (T) Fetch() {}
+
+func helper() {}
+
+// Deprecated: use helper.
+func oldHelper() { helper() }
+-- b/b.go --
+package b
+
+import "mod.com/a"
+
+// Old calls Foo.
+//
+// Deprecated: use a.Foo.
+func Old() { a.Foo() }
+
+func helper() {}
+
+// Deprecated: use helper.
+func legacyHelper() { helper() }
+`
+	annotations := func(t *testing.T, env *Env, re, newName string) []string {
+		t.Helper()
+		edit, err := env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+			TextDocument: env.Editor.TextDocumentIdentifier("a/a.go"),
+			Position:     env.RegexpSearch("a/a.go", re).ToProtocolPosition(),
+			NewName:      newName,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for id, a := range edit.ChangeAnnotations {
+			if strings.HasPrefix(id, "deprecations") {
+				got = append(got, a.Label+": "+a.Description)
+			}
+		}
+		sort.Strings(got)
+		return got
+	}
+	WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		want := []string{
+			"Update deprecation notice: Foo to Bar in the deprecation notice of Legacy",
+			"Update deprecation notice: Foo to Bar in the deprecation notice of OldFoo",
+		}
+		if diff := cmp.Diff(want, annotations(t, env, `func (Foo)`, "Bar")); diff != "" {
+			t.Errorf("deprecation annotations of Foo mismatch (-want +got):\n%s", diff)
+		}
+		want = []string{
+			"Update deprecation notice: Get to Retrieve in the deprecation notice of T.Fetch",
+		}
+		if diff := cmp.Diff(want, annotations(t, env, `\) (Get)`, "Retrieve")); diff != "" {
+			t.Errorf("deprecation annotations of Get mismatch (-want +got):\n%s", diff)
+		}
+		// The notices of other packages cannot refer to an unexported
+		// object: the helper of b is another one.
+		want = []string{
+			"Update deprecation notice: helper to aid in the deprecation notice of oldHelper",
+		}
+		if diff := cmp.Diff(want, annotations(t, env, `func (helper)`, "aid")); diff != "" {
+			t.Errorf("deprecation annotations of helper mismatch (-want +got):\n%s", diff)
+		}
+
+		env.Rename("a/a.go", env.RegexpSearch("a/a.go", `func (Foo)`), "Bar")
+		env.RegexpSearch("a/a.go", `Deprecated: use Bar instead\.`)
+		env.RegexpSearch("a/a.go", `Deprecated: Bar replaces it; see \[Bar\]\.`)
+		env.RegexpSearch("b/b.go", `Deprecated: use a\.Bar\.`)
+	})
+}
+
+func TestRenameInErrorMessages(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- store/store.go --
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrMissing = errors.New("store: Load failed: " + "missing")
+
+func Load(key string) error {
+	return fmt.Errorf("store: Load %s: %w (Loader, LoadAll)", key, ErrMissing)
+}
+-- main.go --
+package main
+
+import (
+	"errors"
+
+	"mod.com/store"
+)
+
+var errMain = errors.New("store: Load failed in main")
+
+func main() { _ = store.Load("k") }
+`
+	WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("store/store.go")
+		env.Rename("store/store.go", env.RegexpSearch("store/store.go", `func (Load)`), "Fetch")
+		env.RegexpSearch("store/store.go", `errors\.New\("store: Fetch failed: " \+ "missing"\)`)
+		env.RegexpSearch("store/store.go", `fmt\.Errorf\("store: Fetch %s: %w \(Loader, LoadAll\)"`)
+		env.RegexpSearch("main.go", `errors\.New\("store: Load failed in main"\)`)
+
+		env.Rename("store/store.go", env.RegexpSearch("store/store.go", `package (store)`), "kv")
+		env.RegexpSearch("kv/store.go", `errors\.New\("kv: Fetch failed: " \+ "missing"\)`)
+		env.RegexpSearch("kv/store.go", `fmt\.Errorf\("kv: Fetch %s`)
+		env.RegexpSearch("main.go", `errors\.New\("store: Load failed in main"\)`)
+	})
+}
+
+func TestRenameLoggingKeys(t *testing.T) {
+	testenv.NeedsGo1Point(t, 21) // uses log/slog
+
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.21
+
+require github.com/sirupsen/logrus v1.0.0
+
+replace github.com/sirupsen/logrus => ./third_party/logrus
+-- third_party/logrus/go.mod --
+module github.com/sirupsen/logrus
+
+go 1.18
+-- third_party/logrus/logrus.go --
+package logrus
+
+type Fields map[string]interface{}
+
+type Entry struct{}
+
+func WithFields(Fields) *Entry { return &Entry{} }
+
+func (*Entry) Info(...interface{}) {}
+-- job/job.go --
+package job
+
+import (
+	"log/slog"
+
+	"github.com/sirupsen/logrus"
+)
+
+type Job struct {
+	RetryCount int
+	Name       string
+}
+
+func (j *Job) Log() {
+	slog.Info("retrying", slog.Int("retry_count", j.RetryCount), "retryCount", j.RetryCount, "name", j.Name)
+	slog.Info("static", "retry_count", 0)
+	logrus.WithFields(logrus.Fields{"retry-count": &j.RetryCount}).Info("retrying")
+}
+`
+	WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("job/job.go")
+		env.Rename("job/job.go", env.RegexpSearch("job/job.go", `(RetryCount) int`), "AttemptCount")
+		env.RegexpSearch("job/job.go", `slog\.Int\("attempt_count", j\.AttemptCount\), "attemptCount", j\.AttemptCount, "name", j\.Name\)`)
+		env.RegexpSearch("job/job.go", `slog\.Info\("static", "retry_count", 0\)`)
+		env.RegexpSearch("job/job.go", `logrus\.Fields\{"attempt-count": &j\.AttemptCount\}`)
+	})
+}
+
+func TestRenameFlagsAndEnvVars(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- main.go --
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	maxConns := flag.Int("max-conns", 10, "maximum number of connections")
+	var retryLimit int
+	flag.IntVar(&retryLimit, "retry-limit", 3, "")
+	dbURL := os.Getenv("DB_URL")
+	fmt.Println(*maxConns, retryLimit, dbURL, "max-conns")
+}
+`
+	WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("main.go")
+		env.Rename("main.go", env.RegexpSearch("main.go", `(maxConns) :=`), "connLimit")
+		env.Await(ShownMessage("renaming flag -max-conns breaks the command lines, scripts and environments that set them"))
+		env.RegexpSearch("main.go", `connLimit := flag\.Int\("conn-limit", 10`)
+		env.RegexpSearch("main.go", `dbURL, "max-conns"\)`)
+		env.Rename("main.go", env.RegexpSearch("main.go", `var (retryLimit)`), "maxRetries")
+		env.RegexpSearch("main.go", `flag\.IntVar\(&maxRetries, "max-retries", 3`)
+		env.Rename("main.go", env.RegexpSearch("main.go", `(dbURL) :=`), "databaseURL")
+		env.RegexpSearch("main.go", `databaseURL := os\.Getenv\("DATABASE_URL"\)`)
+	})
+}
+
+func TestRenameSubtestNames(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- calc/calc.go --
+package calc
+
+func Sum(xs ...int) int {
+	n := 0
+	for _, x := range xs {
+		n += x
+	}
+	return n
+}
+-- calc/calc_test.go --
+package calc
+
+import "testing"
+
+func TestSum(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		xs   []int
+	}{
+		{"Sum of nothing", nil},
+		{name: "Sum", xs: []int{1, 2}},
+		{name: "Summary", xs: []int{3}},
+	} {
+		t.Run(tt.name, func(t *testing.T) { Sum(tt.xs...) })
+	}
+	t.Run("Sum twice", func(t *testing.T) { Sum(Sum()) })
+}
+-- calc/calc_x_test.go --
+package calc_test
+
+import (
+	"testing"
+
+	"mod.com/calc"
+)
+
+func BenchmarkSum(b *testing.B) {
+	b.Run("Sum", func(b *testing.B) { calc.Sum() })
+}
+`
+	WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("calc/calc.go")
+		pos := env.RegexpSearch("calc/calc.go", `func (Sum)`)
+		edit, err := env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+			TextDocument: env.Editor.TextDocumentIdentifier("calc/calc.go"),
+			Position:     pos.ToProtocolPosition(),
+			NewName:      "Total",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a, ok := edit.ChangeAnnotations["subtests"]; !ok || a.Description != `4 occurrences of "Sum" in the names of the subtests of package calc` {
+			t.Errorf("subtests annotation = %+v, want 4 occurrences", a)
+		}
+		env.Rename("calc/calc.go", pos, "Total")
+		env.RegexpSearch("calc/calc_test.go", `\{"Total of nothing", nil\}`)
+		env.RegexpSearch("calc/calc_test.go", `\{name: "Total", xs`)
+		env.RegexpSearch("calc/calc_test.go", `\{name: "Summary", xs`)
+		env.RegexpSearch("calc/calc_test.go", `t\.Run\("Total twice"`)
+		env.RegexpSearch("calc/calc_x_test.go", `b\.Run\("Total"`)
+	})
+}
+
+func TestRenameInTestdata(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- lib/lib.go --
+package lib
+
+func Check() {}
+-- lib/nested/nested.go --
+package nested
+-- analyzer/testdata/src/a/a.go --
+package a
+
+import (
+	"mod.com/lib"
+	"mod.com/lib/nested"
+)
+
+func _() {
+	lib.Check()
+	var _ = nested.X
+}
+
+func _(lib struct{ Check int }) {
+	_ = lib.Check // a parameter, not the package
+}
+-- analyzer/testdata/src/b/b.go --
+package b
+
+import l "mod.com/lib"
+
+var _ = l.Check
+`
+	WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("lib/lib.go")
+		pos := env.RegexpSearch("lib/lib.go", `func (Check)`)
+		edit, err := env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+			TextDocument: env.Editor.TextDocumentIdentifier("lib/lib.go"),
+			Position:     pos.ToProtocolPosition(),
+			NewName:      "Verify",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a, ok := edit.ChangeAnnotations["testdata"]; !ok || a.Description != "2 edits in the Go files under testdata directories: a.go, b.go" {
+			t.Errorf("testdata annotation = %+v, want 2 edits in a.go and b.go", a)
+		}
+		env.Rename("lib/lib.go", pos, "Verify")
+		env.RegexpSearch("analyzer/testdata/src/a/a.go", `lib\.Verify\(\)`)
+		env.RegexpSearch("analyzer/testdata/src/a/a.go", `_ = lib\.Check // a parameter`)
+		env.RegexpSearch("analyzer/testdata/src/b/b.go", `l\.Verify`)
+
+		pos = env.RegexpSearch("lib/lib.go", `package (lib)`)
+		env.Rename("lib/lib.go", pos, "util")
+		env.RegexpSearch("analyzer/testdata/src/a/a.go", `"mod\.com/util"`)
+		env.RegexpSearch("analyzer/testdata/src/a/a.go", `"mod\.com/util/nested"`)
+		env.RegexpSearch("analyzer/testdata/src/a/a.go", `util\.Verify\(\)`)
+		env.RegexpSearch("analyzer/testdata/src/a/a.go", `_ = lib\.Check // a parameter`)
+		env.RegexpSearch("analyzer/testdata/src/b/b.go", `import l "mod\.com/util"`)
+	})
+}
+
+func TestRenameMatchingForms(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- cache/cache.go --
+package cache
+
+import "errors"
+
+// MaxEntries bounds the cache; see cache.MaxEntries. The max_entries
+// setting and the CACHE_MAX_ENTRIES variable override maxentries.
+var MaxEntries = 100
+
+var errFull = errors.New("cache: max-entries reached (MAX_ENTRIES)")
+`
+	WithOptions(
+		HonorsChangeAnnotations(),
+		Settings{"renameMatching": map[string]interface{}{"qualified": false, "derived": true, "caseInsensitive": true}},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("cache/cache.go")
+		env.Rename("cache/cache.go", env.RegexpSearch("cache/cache.go", `var (MaxEntries)`), "Capacity")
+		env.RegexpSearch("cache/cache.go", `// Capacity bounds the cache; see cache\.MaxEntries\. The capacity`)
+		env.RegexpSearch("cache/cache.go", `CACHE_MAX_ENTRIES variable override capacity\.`)
+		env.RegexpSearch("cache/cache.go", `errors\.New\("cache: capacity reached \(CAPACITY\)"\)`)
+	})
+}
+
+func TestRenameAnonymousInterfaceMethod(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+func F(w interface{ Write(p []byte) (int, error) }) { G(w) }
+
+func G(w interface{ Write(p []byte) (int, error) }) { w.Write(nil) }
+
+type S struct {
+	W interface{ Write(p []byte) (int, error) }
+}
+
+func (s S) Flush() { s.W.Write(nil) }
+
+// Other interfaces are left unchanged.
+func H(w interface {
+	Write(p []byte) (int, error)
+	Close() error
+}) {
+}
+-- b/b.go --
+package b
+
+import "mod.com/a"
+
+type T struct{}
+
+func (T) Write(p []byte) (int, error) { return 0, nil }
+
+func use(w interface{ Write(p []byte) (int, error) }) { a.F(w); _ = a.S{W: T{}} }
+`
+	WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		env.Rename("a/a.go", env.RegexpSearch("a/a.go", `func F\(w interface{ (Write)`), "Put")
+		env.RegexpSearch("a/a.go", `func F\(w interface{ Put\(p \[\]byte\) \(int, error\) }\)`)
+		env.RegexpSearch("a/a.go", `func G\(w interface{ Put\(p \[\]byte\) \(int, error\) }\) { w\.Put\(nil\) }`)
+		env.RegexpSearch("a/a.go", `W interface{ Put\(p`)
+		env.RegexpSearch("a/a.go", `s\.W\.Put\(nil\)`)
+		env.RegexpSearch("a/a.go", `\tWrite\(p \[\]byte\) \(int, error\)\n\tClose\(\) error`)
+		env.OpenFile("b/b.go")
+		env.RegexpSearch("b/b.go", `func use\(w interface{ Put\(p`)
+		env.RegexpSearch("b/b.go", `func \(T\) Put\(p`)
+	})
+}
+
+func TestRenameLocalInterfaceMethods(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+type T struct{}
+
+func (T) Write(p []byte) (int, error) { return 0, nil }
+
+type U struct{}
+
+func (U) Write(p []byte) (int, error) { return 0, nil }
+
+type V struct{}
+
+func (V) Close() error { return nil }
+
+func F() {
+	type writer interface{ Write(p []byte) (int, error) }
+	var w writer = T{}
+	w.Write(nil)
+}
+
+func G() {
+	type writer interface{ Write(p []byte) (int, error) }
+	type flusher interface {
+		writer
+		Flush()
+	}
+	var f flusher
+	f.Write(nil)
+}
+
+func (V) H() {
+	type closer interface{ Close() error }
+	var c closer
+	_ = c
+}
+`
+	WithOptions(HonorsChangeAnnotations()).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		rename := func(re string) (*protocol.WorkspaceEdit, error) {
+			pos := env.RegexpSearch("a/a.go", re)
+			return env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+				TextDocument: env.Editor.TextDocumentIdentifier("a/a.go"),
+				Position:     pos.ToProtocolPosition(),
+				NewName:      "Put",
+			})
+		}
+
+		// The implementations of a local interface also implement the
+		// other local interfaces with the method.
+		edit, err := rename(`w\.(Write)`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var descs []string
+		for _, a := range edit.ChangeAnnotations {
+			if a.Label == "Rename implementations of other interfaces" {
+				descs = append(descs, a.Description)
+			}
+		}
+		sort.Strings(descs)
+		want := []string{"mod.com/a.T.Write, which also implements a.G.writer", "mod.com/a.U.Write, which also implements a.G.writer"}
+		if diff := cmp.Diff(want, descs); diff != "" {
+			t.Errorf("implementations of other interfaces mismatch (-want +got):\n%s", diff)
+		}
+
+		// The concrete method assigned to a local interface is coupled to it.
+		if _, err := rename(`func \(T\) (Write)`); err == nil || !strings.Contains(err.Error(), "rename a.F.writer.Write if you intend to change both types") {
+			t.Errorf("renaming T.Write: got error %v, want coupling to a.F.writer.Write", err)
+		}
+
+		// A local interface that a concrete method implements is offered as
+		// its sibling.
+		edit, err = rename(`func \(V\) (Close)`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a := edit.ChangeAnnotations["siblings/0"]; a.Description != "a.V.H.closer.Close, which mod.com/a.V.Close implements" {
+			t.Errorf("siblings/0 = %+v, want the method of a.V.H.closer", a)
+		}
+
+		// References through embedding local interfaces are renamed.
+		env.Rename("a/a.go", env.RegexpSearch("a/a.go", `f\.(Write)`), "Put")
+		env.RegexpSearch("a/a.go", `type writer interface{ Write\(p \[\]byte\) \(int, error\) }\n\tvar w writer = T{}\n\tw\.Write\(nil\)`)
+		env.RegexpSearch("a/a.go", `type writer interface{ Put\(p \[\]byte\) \(int, error\) }\n\ttype flusher`)
+		env.RegexpSearch("a/a.go", `f\.Put\(nil\)`)
+		env.RegexpSearch("a/a.go", `func \(T\) Put\(p`)
+	})
+}
+
+func TestRenameCacheState(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+type I interface{ M() }
+
+type T struct{}
+
+func (T) M() {}
+
+var _ I = T{}
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		rename := func() {
+			t.Helper()
+			pos := env.RegexpSearch("a/a.go", `interface\{ (M)\(\) \}`)
+			if _, err := env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+				TextDocument: env.Editor.TextDocumentIdentifier("a/a.go"),
+				Position:     pos.ToProtocolPosition(),
+				NewName:      "N",
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		state := func() command.RenameCacheStateResult {
+			t.Helper()
+			cmd, err := command.NewRenameCacheStateCommand("", command.URIArg{URI: env.Sandbox.Workdir.URI("a/a.go")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var result command.RenameCacheStateResult
+			env.ExecuteCommand(&protocol.ExecuteCommandParams{
+				Command:   command.RenameCacheState.ID(),
+				Arguments: cmd.Arguments,
+			}, &result)
+			return result
+		}
+
+		rename()
+		rename()
+		got := state()
+		if got.Hits == 0 || got.Misses == 0 || got.Evictions != 0 {
+			t.Errorf("after renaming twice, got %d hits, %d misses and %d evictions, want hits and misses only", got.Hits, got.Misses, got.Evictions)
+		}
+		var found bool
+		for _, e := range got.Entries {
+			if e.Kind == "constraints" && e.Package == "mod.com/a" {
+				found = true
+				if e.Size == 0 || e.Hits == 0 {
+					t.Errorf("got entry %+v, want the constraint of T and I, used once", e)
+				}
+			}
+		}
+		if !found {
+			t.Fatalf("no entry for the constraints of mod.com/a in %+v", got.Entries)
+		}
+
+		env.RegexpReplace("a/a.go", "var _ I", "var _, _ I")
+		env.RegexpReplace("a/a.go", "T{}", "T{}, T{}")
+		rename()
+		if got := state(); got.Evictions == 0 {
+			t.Errorf("entries not evicted after an edit: %+v", got)
+		}
+	})
+}
+
+func TestRenameProfile(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+type I interface{ M() }
+
+type T struct{}
+
+func (T) M() {}
+
+var _ I = T{}
+-- b/b.go --
+package b
+
+import "mod.com/a"
+
+func _() { a.T{}.M() }
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		profile := func(re, newName string) map[string]command.RenamePhaseProfile {
+			t.Helper()
+			cmd, err := command.NewRenameProfileCommand("", protocol.RenameParams{
+				TextDocument: env.Editor.TextDocumentIdentifier("a/a.go"),
+				Position:     env.RegexpSearch("a/a.go", re).ToProtocolPosition(),
+				NewName:      newName,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var result command.RenameProfileResult
+			env.ExecuteCommand(&protocol.ExecuteCommandParams{
+				Command:   command.RenameProfile.ID(),
+				Arguments: cmd.Arguments,
+			}, &result)
+			phases := make(map[string]command.RenamePhaseProfile)
+			for _, p := range result.Phases {
+				phases[p.Name] = p
+			}
+			return phases
+		}
+
+		phases := profile(`func \(T\) (M)`, "N")
+		for name, want := range map[string]int{
+			"metadata":        2, // mod.com/a and mod.com/b
+			"load":            2,
+			"references":      2,
+			"implementations": 1, // I
+			"check":           1, // T no longer implements I
+			"edits":           2,
+		} {
+			if got := phases[name].Size; got != want {
+				t.Errorf("phase %s: got size %d, want %d", name, got, want)
+			}
+		}
+		if _, ok := phases["satisfy"]; !ok {
+			t.Error("missing satisfy phase")
+		}
+
+		// The implementations of a type are not searched.
+		phases = profile(`type (T)`, "U")
+		if _, ok := phases["implementations"]; ok {
+			t.Errorf("got implementations phase for a type: %+v", phases["implementations"])
+		}
+		if got := phases["edits"].Size; got != 4 {
+			t.Errorf("edits of the renaming of T: got %d, want 4", got)
+		}
+	})
+}
+
+func TestRenameManifest(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+type T struct{ F int }
+
+func (*T) M() {}
+
+func G() {
+	x := 1
+	_ = x
+}
+-- b/b.go --
+package b
+
+import "mod.com/a"
+
+type S struct{ a.T }
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		manifest := func(re, newName string) []string {
+			t.Helper()
+			pos := env.RegexpSearch("a/a.go", re)
+			cmd, err := command.NewRenameManifestCommand("", command.RenameManifestArgs{
+				Params: protocol.RenameParams{
+					TextDocument: protocol.TextDocumentIdentifier{URI: env.Sandbox.Workdir.URI("a/a.go")},
+					Position:     pos.ToProtocolPosition(),
+					NewName:      newName,
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var result command.RenameManifestResult
+			env.ExecuteCommand(&protocol.ExecuteCommandParams{
+				Command:   command.RenameManifest.ID(),
+				Arguments: cmd.Arguments,
+			}, &result)
+			if result.Version != 1 {
+				t.Errorf("got manifest version %d, want 1", result.Version)
+			}
+			var renames []string
+			for _, r := range result.Renames {
+				var positions []string
+				for _, loc := range r.Positions {
+					positions = append(positions, fmt.Sprintf("%s:%d", env.Sandbox.Workdir.URIToPath(loc.URI), loc.Range.Start.Line+1))
+				}
+				renames = append(renames, fmt.Sprintf("%s %s -> %s %v", r.Kind, r.Old.Qualified, r.New.Qualified, positions))
+			}
+			return renames
+		}
+
+		for _, test := range []struct {
+			re, newName string
+			want        []string
+		}{
+			{`\*T\) (M)`, "N", []string{"method mod.com/a.T.M -> mod.com/a.T.N [a/a.go:5]"}},
+			{`\{ (F) int`, "G", []string{"field mod.com/a.T.F -> mod.com/a.T.G [a/a.go:3]"}},
+			// The field embedding T is renamed along with it.
+			{`type (T)`, "U", []string{
+				"type mod.com/a.T -> mod.com/a.U [a/a.go:3]",
+				"field mod.com/b.S.T -> mod.com/b.S.U [b/b.go:5]",
+			}},
+			{`(x) :=`, "y", nil},
+		} {
+			if diff := cmp.Diff(test.want, manifest(test.re, test.newName)); diff != "" {
+				t.Errorf("manifest of the renaming of %s to %s: unexpected renames (-want +got):\n%s", test.re, test.newName, diff)
+			}
+		}
+		want := []string{"package mod.com/a -> mod.com/c []"}
+		if diff := cmp.Diff(want, manifest(`package (a)`, "c")); diff != "" {
+			t.Errorf("manifest of the renaming of package a: unexpected renames (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestResolveRenameSpec(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+type T struct{ f int }
+
+func (*T) M() {
+	x := 1
+	_ = x
+}
+
+func F() {
+	x := 2
+	_ = x
+}
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		resolve := func(spec string) (protocol.Location, error) {
+			t.Helper()
+			cmd, err := command.NewResolveRenameSpecCommand("", command.ResolveRenameSpecArgs{Spec: spec})
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := env.Editor.ExecuteCommand(env.Ctx, &protocol.ExecuteCommandParams{
+				Command:   command.ResolveRenameSpec.ID(),
+				Arguments: cmd.Arguments,
+			})
+			if err != nil {
+				return protocol.Location{}, err
+			}
+			var result command.ResolveRenameSpecResult
+			data, _ := json.Marshal(res)
+			if err := json.Unmarshal(data, &result); err != nil {
+				t.Fatal(err)
+			}
+			return result.Location, nil
+		}
+
+		for _, test := range []struct {
+			spec, want string
+		}{
+			{`"mod.com/a".T.M`, `func \(\*T\) (M)`},
+			{`(*"mod.com/a".T).M`, `func \(\*T\) (M)`},
+			{`"mod.com/a".T.f`, `struct\{ (f) int`},
+			{`"mod.com/a".F`, `func (F)`},
+			{`"mod.com/a".T.M::x`, `(x) := 1`},
+			{`"mod.com/a".F::x`, `(x) := 2`},
+			{env.Sandbox.Workdir.AbsPath("a/a.go") + "::F", `func (F)`},
+		} {
+			loc, err := resolve(test.spec)
+			if err != nil {
+				t.Errorf("resolving %s: %v", test.spec, err)
+				continue
+			}
+			want := env.RegexpSearch("a/a.go", test.want).ToProtocolPosition()
+			if loc.URI != env.Sandbox.Workdir.URI("a/a.go") || loc.Range.Start != want {
+				t.Errorf("resolving %s: got %v, want a/a.go at %v", test.spec, loc, want)
+			}
+		}
+
+		for _, test := range []struct {
+			spec, wantErr string
+		}{
+			{`"mod.com/a"::x`, "ambiguous specifier x"},
+			{`"mod.com/a".G`, `package "mod.com/a" has no member "G"`},
+			{`"mod.com/b".F`, "no package or file of the workspace matches"},
+		} {
+			if _, err := resolve(test.spec); err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("resolving %s: got error %v, want %q", test.spec, err, test.wantErr)
+			}
+		}
+	})
+}
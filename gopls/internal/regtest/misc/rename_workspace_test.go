@@ -0,0 +1,263 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package misc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	. "golang.org/x/tools/gopls/internal/lsp/regtest"
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/lsp/tests/compare"
+	"golang.org/x/tools/internal/testenv"
+)
+
+// renameWorkspace is a go.work workspace of two modules: a, whose package
+// lib has a nested package, in-package and external tests, and a nested
+// module replaced by the go.work file, and b, which imports them all.
+const renameWorkspace = `
+-- ws/go.work --
+go 1.18
+
+use (
+	./a
+	./b
+)
+
+replace example.com/plugin => ./a/lib/plugin
+-- ws/a/go.mod --
+module example.com/a
+
+go 1.18
+-- ws/a/lib/lib.go --
+package lib
+
+func F() int { return 1 }
+-- ws/a/lib/lib_test.go --
+package lib
+
+import "testing"
+
+func TestF(t *testing.T) { _ = F() }
+-- ws/a/lib/lib_x_test.go --
+package lib_test
+
+import (
+	"testing"
+
+	"example.com/a/lib"
+)
+
+func TestXF(t *testing.T) { _ = lib.F() }
+-- ws/a/lib/nested/nested.go --
+package nested
+
+import "example.com/a/lib"
+
+func G() int { return lib.F() }
+-- ws/a/lib/plugin/go.mod --
+module example.com/plugin
+
+go 1.18
+-- ws/a/lib/plugin/plugin.go --
+package plugin
+
+func P() {}
+-- ws/b/go.mod --
+module example.com/b
+
+go 1.18
+
+require example.com/plugin v1.0.0
+-- ws/b/b.go --
+package b
+
+import (
+	"example.com/a/lib"
+	"example.com/a/lib/nested"
+	"example.com/plugin"
+)
+
+func B() int {
+	plugin.P()
+	return lib.F() + nested.G()
+}
+`
+
+func TestRenamePackageInWorkspace(t *testing.T) {
+	testenv.NeedsGo1Point(t, 18) // uses go.work
+
+	tests := []struct {
+		name string
+		opts []RunOption
+		want string
+	}{
+		{
+			name: "directory",
+			want: `
+-- ws/a/lib/lib.go --
+package util
+
+func F() int { return 1 }
+-- ws/a/lib/lib_test.go --
+package util
+
+import "testing"
+
+func TestF(t *testing.T) { _ = F() }
+-- ws/a/lib/lib_x_test.go --
+package util_test
+
+import (
+	"testing"
+
+	"example.com/a/util"
+)
+
+func TestXF(t *testing.T) { _ = util.F() }
+-- ws/a/lib/nested/nested.go --
+package nested
+
+import "example.com/a/util"
+
+func G() int { return util.F() }
+-- ws/b/b.go --
+package b
+
+import (
+	"example.com/a/util"
+	"example.com/a/util/nested"
+	"example.com/plugin"
+)
+
+func B() int {
+	plugin.P()
+	return util.F() + nested.G()
+}
+-- ws/go.work --
+go 1.18
+
+use (
+	./a
+	./b
+)
+
+replace example.com/plugin => ./a/util/plugin
+rename ws/a/lib -> ws/a/util
+`,
+		},
+		{
+			name: "clause",
+			opts: []RunOption{NoFileRenames()},
+			want: `
+-- ws/a/lib/lib.go --
+package util
+
+func F() int { return 1 }
+-- ws/a/lib/lib_test.go --
+package util
+
+import "testing"
+
+func TestF(t *testing.T) { _ = F() }
+-- ws/a/lib/lib_x_test.go --
+package util_test
+
+import (
+	"testing"
+
+	"example.com/a/lib"
+)
+
+func TestXF(t *testing.T) { _ = util.F() }
+-- ws/a/lib/nested/nested.go --
+package nested
+
+import "example.com/a/lib"
+
+func G() int { return util.F() }
+-- ws/b/b.go --
+package b
+
+import (
+	"example.com/a/lib"
+	"example.com/a/lib/nested"
+	"example.com/plugin"
+)
+
+func B() int {
+	plugin.P()
+	return util.F() + nested.G()
+}
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := append([]RunOption{WorkspaceFolders("ws")}, test.opts...)
+			WithOptions(opts...).Run(t, renameWorkspace, func(t *testing.T, env *Env) {
+				env.OpenFile("ws/a/lib/lib.go")
+				edit, err := env.Editor.Server.Rename(env.Ctx, &protocol.RenameParams{
+					TextDocument: env.Editor.TextDocumentIdentifier("ws/a/lib/lib.go"),
+					Position:     env.RegexpSearch("ws/a/lib/lib.go", "package (lib)").ToProtocolPosition(),
+					NewName:      "util",
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if diff := compare.Text(test.want, formatWorkspaceEdit(t, env, edit)); diff != "" {
+					t.Errorf("Rename(lib, util): unexpected workspace edit (-want +got):\n%s", diff)
+				}
+			})
+		})
+	}
+}
+
+// formatWorkspaceEdit formats edit for comparison with a golden: the
+// contents of each edited file after its edits, in txtar format and in the
+// order of the files, followed by the file renames, in order.
+func formatWorkspaceEdit(t *testing.T, env *Env, edit *protocol.WorkspaceEdit) string {
+	t.Helper()
+	contents := make(map[string]string)
+	var files, renames []string
+	for _, c := range edit.DocumentChanges {
+		switch {
+		case c.TextDocumentEdit != nil:
+			uri := c.TextDocumentEdit.TextDocument.URI
+			path := env.Sandbox.Workdir.URIToPath(uri)
+			content, ok := contents[path]
+			if !ok {
+				var err error
+				if content, err = env.Sandbox.Workdir.ReadFile(path); err != nil {
+					t.Fatal(err)
+				}
+				files = append(files, path)
+			}
+			m := protocol.NewColumnMapper(uri.SpanURI(), []byte(content))
+			content, _, err := source.ApplyProtocolEdits(m, c.TextDocumentEdit.Edits)
+			if err != nil {
+				t.Fatalf("applying the edits of %s: %v", path, err)
+			}
+			contents[path] = content
+		case c.RenameFile != nil:
+			renames = append(renames, fmt.Sprintf("rename %s -> %s\n",
+				env.Sandbox.Workdir.URIToPath(c.RenameFile.OldURI),
+				env.Sandbox.Workdir.URIToPath(c.RenameFile.NewURI)))
+		}
+	}
+	sort.Strings(files)
+	var b strings.Builder
+	b.WriteString("\n")
+	for _, path := range files {
+		fmt.Fprintf(&b, "-- %s --\n%s", path, contents[path])
+	}
+	for _, r := range renames {
+		b.WriteString(r)
+	}
+	return b.String()
+}